@@ -23,6 +23,10 @@ type Config struct {
 	LogLevel            logging.Level
 	Client              continuoustest.ClientConfig
 	WriteReadSeriesTest continuoustest.WriteReadSeriesTestConfig
+	ExemplarsTest       continuoustest.WriteReadExemplarsTestConfig
+	MetadataTest        continuoustest.WriteReadMetadataTestConfig
+	RecordingRuleTest   continuoustest.RecordingRuleTestConfig
+	AlertingTest        continuoustest.AlertingTestConfig
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
@@ -30,6 +34,10 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.LogLevel.RegisterFlags(f)
 	cfg.Client.RegisterFlags(f)
 	cfg.WriteReadSeriesTest.RegisterFlags(f)
+	cfg.ExemplarsTest.RegisterFlags(f)
+	cfg.MetadataTest.RegisterFlags(f)
+	cfg.RecordingRuleTest.RegisterFlags(f)
+	cfg.AlertingTest.RegisterFlags(f)
 }
 
 func main() {
@@ -63,6 +71,18 @@ func main() {
 	// Run continuous testing.
 	m := continuoustest.NewManager()
 	m.AddTest(continuoustest.NewWriteReadSeriesTest(cfg.WriteReadSeriesTest, client, logger, registry))
+	if cfg.ExemplarsTest.Enabled {
+		m.AddTest(continuoustest.NewWriteReadExemplarsTest(cfg.ExemplarsTest, client, logger, registry))
+	}
+	if cfg.MetadataTest.Enabled {
+		m.AddTest(continuoustest.NewWriteReadMetadataTest(cfg.MetadataTest, client, logger, registry))
+	}
+	if cfg.RecordingRuleTest.Enabled {
+		m.AddTest(continuoustest.NewRecordingRuleTest(cfg.RecordingRuleTest, client, logger, registry))
+	}
+	if cfg.AlertingTest.Enabled {
+		m.AddTest(continuoustest.NewAlertingTest(cfg.AlertingTest, client, logger, registry))
+	}
 	if err := m.Run(context.Background()); err != nil {
 		level.Error(logger).Log("msg", "Failed to run continuous test", "err", err.Error())
 		os.Exit(1)
@@ -368,3 +368,21 @@ func TestFieldCategoryOverridesNotStale(t *testing.T) {
 
 	require.Empty(t, overrides, "There are category overrides for configuration options that no longer exist")
 }
+
+func TestFieldCategoryOverridesCoverReplicationStorage(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+
+	var cfg mimir.Config
+	cfg.RegisterFlags(fs, log.NewNopLogger())
+
+	// The replication storage is a bucket.Config, whose fields can't be categorized through struct
+	// tags since they're shared with the other storages.
+	fs.VisitAll(func(fl *flag.Flag) {
+		if !strings.HasPrefix(fl.Name, "compactor.replication.storage.") {
+			return
+		}
+		category, ok := fieldcategory.GetOverride(fl.Name)
+		assert.True(t, ok, "missing category override for %s", fl.Name)
+		assert.Equal(t, fieldcategory.Experimental, category, fl.Name)
+	})
+}
@@ -21,6 +21,8 @@ var (
 	alertCommand          commands.AlertCommand
 	alertmanagerCommand   commands.AlertmanagerCommand
 	analyzeCommand        commands.AnalyzeCommand
+	blocksCommand         commands.BlocksCommand
+	bucketCommand         commands.BucketCommand
 	bucketValidateCommand commands.BucketValidationCommand
 	configCommand         commands.ConfigCommand
 	loadgenCommand        commands.LoadgenCommand
@@ -38,6 +40,8 @@ func main() {
 	alertCommand.Register(app, envVars)
 	alertmanagerCommand.Register(app, envVars)
 	analyzeCommand.Register(app, envVars)
+	blocksCommand.Register(app, envVars)
+	bucketCommand.Register(app, envVars)
 	bucketValidateCommand.Register(app, envVars)
 	configCommand.Register(app, envVars)
 	loadgenCommand.Register(app, envVars)
@@ -84,6 +84,9 @@ type Config struct {
 	Replicator        Replicator
 	Store             alertstore.AlertStore
 	PersisterConfig   PersisterConfig
+
+	// Limiters of the notifications of the tenant, per integration. If nil, they're created from Limits.
+	NotificationLimiters *tenantNotificationLimiters
 }
 
 // An Alertmanager manages the alerts for one user.
@@ -117,6 +120,7 @@ type Alertmanager struct {
 	// hence we need to generate the metric ourselves.
 	configHashMetric prometheus.Gauge
 
+	notificationLimiters     *tenantNotificationLimiters
 	rateLimitedNotifications *prometheus.CounterVec
 }
 
@@ -181,6 +185,13 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 
 	}
 
+	if cfg.Limits != nil {
+		am.notificationLimiters = cfg.NotificationLimiters
+		if am.notificationLimiters == nil {
+			am.notificationLimiters = newTenantNotificationLimiters(cfg.UserID, cfg.Limits)
+		}
+	}
+
 	am.registry = reg
 	am.state = newReplicatedStates(cfg.UserID, cfg.ReplicationFactor, cfg.Replicator, cfg.Store, am.logger, am.registry)
 	am.persister = newStatePersister(cfg.PersisterConfig, cfg.UserID, am.state, cfg.Store, am.logger, am.registry)
@@ -343,14 +354,13 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 	firewallDialer := util_net.NewFirewallDialer(newFirewallDialerConfigProvider(userID, am.cfg.Limits))
 
 	integrationsMap, err := buildIntegrationsMap(conf.Receivers, tmpl, firewallDialer, am.logger, func(integrationName string, notifier notify.Notifier) notify.Notifier {
-		if am.cfg.Limits != nil {
-			rl := &tenantRateLimits{
-				tenant:      userID,
-				limits:      am.cfg.Limits,
-				integration: integrationName,
+		if am.notificationLimiters != nil {
+			// The limiter is shared by all the notifiers of the integration, and across configuration reloads.
+			return &rateLimitedNotifier{
+				upstream: notifier,
+				limiter:  am.notificationLimiters.get(integrationName),
+				counter:  am.rateLimitedNotifications.WithLabelValues(integrationName),
 			}
-
-			return newRateLimitedNotifier(notifier, rl, 10*time.Second, am.rateLimitedNotifications.WithLabelValues(integrationName))
 		}
 		return notifier
 	})
@@ -13,6 +13,7 @@ import (
 	"sync"
 
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/gogo/protobuf/proto"
 	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/runutil"
@@ -21,6 +22,7 @@ import (
 
 	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
 	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 const (
@@ -34,6 +36,11 @@ const (
 	//     alertmanager/<user-id>/<object>
 	alertmanagerPrefix = "alertmanager"
 
+	// The bucket prefix under which the history of all tenants alertmanager configs is stored.
+	// Note that objects stored under this prefix follow the pattern:
+	//     alerts-history/<user-id>/<version>
+	alertsHistoryPrefix = "alerts-history"
+
 	// The name of alertmanager full state objects (notification log + silences).
 	fullStateName = "fullstate"
 
@@ -44,18 +51,24 @@ const (
 // BucketAlertStore is used to support the AlertStore interface against an object storage backend. It is implemented
 // using the Thanos objstore.Bucket interface
 type BucketAlertStore struct {
-	alertsBucket objstore.Bucket
-	amBucket     objstore.Bucket
-	cfgProvider  bucket.TenantConfigProvider
-	logger       log.Logger
+	alertsBucket  objstore.Bucket
+	amBucket      objstore.Bucket
+	historyBucket objstore.Bucket
+	cfgProvider   bucket.TenantConfigProvider
+	maxVersions   int
+	logger        log.Logger
 }
 
-func NewBucketAlertStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *BucketAlertStore {
+// NewBucketAlertStore returns a BucketAlertStore keeping up to maxVersions versions in the history
+// of each alertmanager config. The history is disabled if maxVersions is 0.
+func NewBucketAlertStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, maxVersions int, logger log.Logger) *BucketAlertStore {
 	return &BucketAlertStore{
-		alertsBucket: bucket.NewPrefixedBucketClient(bkt, alertsPrefix),
-		amBucket:     bucket.NewPrefixedBucketClient(bkt, alertmanagerPrefix),
-		cfgProvider:  cfgProvider,
-		logger:       logger,
+		alertsBucket:  bucket.NewPrefixedBucketClient(bkt, alertsPrefix),
+		amBucket:      bucket.NewPrefixedBucketClient(bkt, alertmanagerPrefix),
+		historyBucket: bucket.NewPrefixedBucketClient(bkt, alertsHistoryPrefix),
+		cfgProvider:   cfgProvider,
+		maxVersions:   maxVersions,
+		logger:        logger,
 	}
 }
 
@@ -115,7 +128,13 @@ func (s *BucketAlertStore) SetAlertConfig(ctx context.Context, cfg alertspb.Aler
 		return err
 	}
 
-	return s.getUserBucket(cfg.User).Upload(ctx, cfg.User, bytes.NewBuffer(cfgBytes))
+	err = s.getUserBucket(cfg.User).Upload(ctx, cfg.User, bytes.NewBuffer(cfgBytes))
+	if err != nil {
+		return err
+	}
+
+	s.addAlertConfigVersion(ctx, cfg.User, cfgBytes)
+	return nil
 }
 
 // DeleteAlertConfig implements alertstore.AlertStore.
@@ -123,10 +142,45 @@ func (s *BucketAlertStore) DeleteAlertConfig(ctx context.Context, userID string)
 	userBkt := s.getUserBucket(userID)
 
 	err := userBkt.Delete(ctx, userID)
-	if userBkt.IsObjNotFoundErr(err) {
-		return nil
+	if err != nil && !userBkt.IsObjNotFoundErr(err) {
+		return err
 	}
-	return err
+
+	return s.DeleteAlertConfigHistory(ctx, userID)
+}
+
+// DeleteAlertConfigHistory implements alertstore.AlertStore.
+func (s *BucketAlertStore) DeleteAlertConfigHistory(ctx context.Context, userID string) error {
+	userBkt := bucket.NewUserBucketClient(userID, s.historyBucket, s.cfgProvider)
+
+	deleted, err := bucket.DeletePrefix(ctx, userBkt, "", s.logger)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete alertmanager config history for user %s", userID)
+	}
+	if deleted > 0 {
+		level.Debug(s.logger).Log("msg", "deleted alertmanager config history", "user", userID, "objects", deleted)
+	}
+	return nil
+}
+
+// ListAlertConfigVersions implements alertstore.AlertStore.
+func (s *BucketAlertStore) ListAlertConfigVersions(ctx context.Context, userID string) ([]versioning.Version, error) {
+	return s.getAlertConfigHistory(userID).List(ctx)
+}
+
+// GetAlertConfigVersion implements alertstore.AlertStore.
+func (s *BucketAlertStore) GetAlertConfigVersion(ctx context.Context, userID string, id int) (versioning.Version, alertspb.AlertConfigDesc, error) {
+	cfg := alertspb.AlertConfigDesc{}
+
+	v, data, err := s.getAlertConfigHistory(userID).Get(ctx, id)
+	if err != nil || v.Deleted {
+		return v, cfg, err
+	}
+
+	if err := cfg.Unmarshal(data); err != nil {
+		return v, cfg, errors.Wrapf(err, "failed to deserialize version %d of alertmanager config for user %s", id, userID)
+	}
+	return v, cfg, nil
 }
 
 // ListUsersWithFullState implements alertstore.AlertStore.
@@ -204,6 +258,23 @@ func (s *BucketAlertStore) get(ctx context.Context, bkt objstore.Bucket, name st
 	return nil
 }
 
+// addAlertConfigVersion adds a version to the history of the alertmanager config of the user.
+// A failure doesn't fail the change of the config.
+func (s *BucketAlertStore) addAlertConfigVersion(ctx context.Context, userID string, data []byte) {
+	if s.maxVersions <= 0 {
+		return
+	}
+
+	_, err := s.getAlertConfigHistory(userID).Add(ctx, data, false)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to add version to alertmanager config history", "user", userID, "err", err)
+	}
+}
+
+func (s *BucketAlertStore) getAlertConfigHistory(userID string) *versioning.History {
+	return versioning.NewHistory(bucket.NewUserBucketClient(userID, s.historyBucket, s.cfgProvider), s.maxVersions, s.logger)
+}
+
 func (s *BucketAlertStore) getUserBucket(userID string) objstore.Bucket {
 	// Inject server-side encryption based on the tenant config.
 	return bucket.NewSSEBucketClient(userID, s.alertsBucket, s.cfgProvider)
@@ -16,6 +16,8 @@ import (
 type Config struct {
 	bucket.Config `yaml:",inline"`
 	Local         local.StoreConfig `yaml:"local"`
+
+	MaxVersions int `yaml:"max_versions" category:"experimental"`
 }
 
 // RegisterFlags registers the backend storage config.
@@ -25,6 +27,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.ExtraBackends = []string{local.Name}
 	cfg.Local.RegisterFlagsWithPrefix(prefix, f)
 	cfg.RegisterFlagsWithPrefixAndDefaultDirectory(prefix, "alertmanager", f)
+	f.IntVar(&cfg.MaxVersions, prefix+"max-versions", 0, "Maximum number of versions to keep in the history of each Alertmanager configuration. The oldest versions are deleted once the limit is exceeded. 0 to disable the history.")
 }
 
 // IsFullStateSupported returns if the given configuration supports access to FullState objects.
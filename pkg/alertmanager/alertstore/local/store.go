@@ -17,6 +17,7 @@ import (
 	"github.com/prometheus/alertmanager/config"
 
 	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 const (
@@ -26,6 +27,7 @@ const (
 var (
 	errReadOnly = errors.New("local alertmanager config storage is read-only")
 	errState    = errors.New("local alertmanager storage does not support state persistency")
+	errHistory  = errors.New("local alertmanager config storage does not support history")
 )
 
 // StoreConfig configures a static file alertmanager store
@@ -106,6 +108,22 @@ func (f *Store) DeleteAlertConfig(_ context.Context, user string) error {
 	return errReadOnly
 }
 
+// ListAlertConfigVersions implements alertstore.AlertStore.
+func (f *Store) ListAlertConfigVersions(_ context.Context, user string) ([]versioning.Version, error) {
+	return nil, errHistory
+}
+
+// GetAlertConfigVersion implements alertstore.AlertStore.
+func (f *Store) GetAlertConfigVersion(_ context.Context, user string, id int) (versioning.Version, alertspb.AlertConfigDesc, error) {
+	return versioning.Version{}, alertspb.AlertConfigDesc{}, errHistory
+}
+
+// DeleteAlertConfigHistory implements alertstore.AlertStore.
+func (f *Store) DeleteAlertConfigHistory(_ context.Context, user string) error {
+	// The local store is read-only, so it has no history.
+	return nil
+}
+
 // ListUsersWithFullState implements alertstore.AlertStore.
 func (f *Store) ListUsersWithFullState(ctx context.Context) ([]string, error) {
 	return nil, errState
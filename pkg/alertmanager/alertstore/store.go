@@ -16,6 +16,7 @@ import (
 	"github.com/grafana/mimir/pkg/alertmanager/alertstore/bucketclient"
 	"github.com/grafana/mimir/pkg/alertmanager/alertstore/local"
 	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 // AlertStore stores and configures users rule configs
@@ -34,10 +35,21 @@ type AlertStore interface {
 	// SetAlertConfig stores the alertmanager configuration for an user.
 	SetAlertConfig(ctx context.Context, cfg alertspb.AlertConfigDesc) error
 
-	// DeleteAlertConfig deletes the alertmanager configuration for an user.
+	// DeleteAlertConfig deletes the alertmanager configuration for an user, and its history.
 	// If configuration for the user doesn't exist, no error is reported.
 	DeleteAlertConfig(ctx context.Context, user string) error
 
+	// ListAlertConfigVersions returns the versions in the history of the alertmanager configuration
+	// for an user, sorted by ID.
+	ListAlertConfigVersions(ctx context.Context, user string) ([]versioning.Version, error)
+
+	// GetAlertConfigVersion returns a version in the history of the alertmanager configuration for an user.
+	// The returned configuration is empty if the version records the deletion of the configuration.
+	GetAlertConfigVersion(ctx context.Context, user string, id int) (versioning.Version, alertspb.AlertConfigDesc, error)
+
+	// DeleteAlertConfigHistory deletes the history of the alertmanager configuration for an user.
+	DeleteAlertConfigHistory(ctx context.Context, user string) error
+
 	// ListUsersWithFullState returns the list of users which have had state written.
 	ListUsersWithFullState(ctx context.Context) ([]string, error)
 
@@ -67,5 +79,5 @@ func NewAlertStore(ctx context.Context, cfg Config, cfgProvider bucket.TenantCon
 		return nil, err
 	}
 
-	return bucketclient.NewBucketAlertStore(bucketClient, cfgProvider, logger), nil
+	return bucketclient.NewBucketAlertStore(bucketClient, cfgProvider, cfg.MaxVersions, logger), nil
 }
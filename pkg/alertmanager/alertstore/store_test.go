@@ -17,11 +17,12 @@ import (
 
 	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
 	"github.com/grafana/mimir/pkg/alertmanager/alertstore/bucketclient"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 func TestAlertStore_ListAllUsers(t *testing.T) {
 	bucket := objstore.NewInMemBucket()
-	store := bucketclient.NewBucketAlertStore(bucket, nil, log.NewNopLogger())
+	store := bucketclient.NewBucketAlertStore(bucket, nil, 0, log.NewNopLogger())
 
 	ctx := context.Background()
 	user1Cfg := alertspb.AlertConfigDesc{User: "user-1", RawConfig: "content-1"}
@@ -47,7 +48,7 @@ func TestAlertStore_ListAllUsers(t *testing.T) {
 
 func TestAlertStore_SetAndGetAlertConfig(t *testing.T) {
 	bucket := objstore.NewInMemBucket()
-	store := bucketclient.NewBucketAlertStore(bucket, nil, log.NewNopLogger())
+	store := bucketclient.NewBucketAlertStore(bucket, nil, 0, log.NewNopLogger())
 
 	ctx := context.Background()
 	user1Cfg := alertspb.AlertConfigDesc{User: "user-1", RawConfig: "content-1"}
@@ -86,7 +87,7 @@ func TestAlertStore_SetAndGetAlertConfig(t *testing.T) {
 
 func TestStore_GetAlertConfigs(t *testing.T) {
 	bucket := objstore.NewInMemBucket()
-	store := bucketclient.NewBucketAlertStore(bucket, nil, log.NewNopLogger())
+	store := bucketclient.NewBucketAlertStore(bucket, nil, 0, log.NewNopLogger())
 
 	ctx := context.Background()
 	user1Cfg := alertspb.AlertConfigDesc{User: "user-1", RawConfig: "content-1"}
@@ -123,7 +124,7 @@ func TestStore_GetAlertConfigs(t *testing.T) {
 
 func TestAlertStore_DeleteAlertConfig(t *testing.T) {
 	bucket := objstore.NewInMemBucket()
-	store := bucketclient.NewBucketAlertStore(bucket, nil, log.NewNopLogger())
+	store := bucketclient.NewBucketAlertStore(bucket, nil, 0, log.NewNopLogger())
 
 	ctx := context.Background()
 	user1Cfg := alertspb.AlertConfigDesc{User: "user-1", RawConfig: "content-1"}
@@ -157,6 +158,80 @@ func TestAlertStore_DeleteAlertConfig(t *testing.T) {
 	require.NoError(t, store.DeleteAlertConfig(ctx, "user-1"))
 }
 
+func TestAlertStore_AlertConfigVersions(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	store := bucketclient.NewBucketAlertStore(bucket, nil, 2, log.NewNopLogger())
+
+	ctx := context.Background()
+	user1Cfg := alertspb.AlertConfigDesc{User: "user-1", RawConfig: "content-1"}
+	user2Cfg := alertspb.AlertConfigDesc{User: "user-2", RawConfig: "content-2"}
+
+	// The user has no history.
+	{
+		versions, err := store.ListAlertConfigVersions(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+
+		_, _, err = store.GetAlertConfigVersion(ctx, "user-1", 1)
+		assert.ErrorIs(t, err, versioning.ErrVersionNotFound)
+	}
+
+	// The changes of the config are recorded in the history.
+	{
+		require.NoError(t, store.SetAlertConfig(versioning.ContextWithAuthor(ctx, "alice"), user1Cfg))
+		require.NoError(t, store.SetAlertConfig(ctx, user2Cfg))
+
+		versions, err := store.ListAlertConfigVersions(ctx, "user-1")
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, "alice", versions[0].Author)
+		assert.False(t, versions[0].Deleted)
+
+		v, config, err := store.GetAlertConfigVersion(ctx, "user-1", 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, v.ID)
+		assert.Equal(t, user1Cfg, config)
+
+		// The history doesn't interfere with the listing of users.
+		users, err := store.ListAllUsers(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user-1", "user-2"}, users)
+	}
+
+	// The oldest versions are deleted once the max number of versions is exceeded.
+	{
+		require.NoError(t, store.SetAlertConfig(ctx, user1Cfg))
+		require.NoError(t, store.SetAlertConfig(ctx, user1Cfg))
+
+		versions, err := store.ListAlertConfigVersions(ctx, "user-1")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, 2, versions[0].ID)
+		assert.Equal(t, 3, versions[1].ID)
+
+		exists, err := bucket.Exists(ctx, "alerts-history/user-1/00000000000000000003")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	}
+
+	// The history is deleted together with the config.
+	{
+		require.NoError(t, store.DeleteAlertConfig(ctx, "user-1"))
+
+		versions, err := store.ListAlertConfigVersions(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+
+		versions, err = store.ListAlertConfigVersions(ctx, "user-2")
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+
+		users, err := store.ListAllUsers(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user-2"}, users)
+	}
+}
+
 func makeTestFullState(content string) alertspb.FullStateDesc {
 	return alertspb.FullStateDesc{
 		State: &clusterpb.FullState{
@@ -172,7 +247,7 @@ func makeTestFullState(content string) alertspb.FullStateDesc {
 
 func TestBucketAlertStore_GetSetDeleteFullState(t *testing.T) {
 	bucket := objstore.NewInMemBucket()
-	store := bucketclient.NewBucketAlertStore(bucket, nil, log.NewNopLogger())
+	store := bucketclient.NewBucketAlertStore(bucket, nil, 0, log.NewNopLogger())
 
 	ctx := context.Background()
 	state1 := makeTestFullState("one")
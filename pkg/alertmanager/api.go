@@ -14,9 +14,11 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
 	"github.com/grafana/dskit/concurrency"
 	"github.com/pkg/errors"
 	"github.com/prometheus/alertmanager/config"
@@ -27,6 +29,7 @@ import (
 	"github.com/grafana/dskit/tenant"
 
 	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
 )
@@ -42,6 +45,10 @@ const (
 	errConfigurationTooBig   = "Alertmanager configuration is too big, limit: %d bytes"
 	errTooManyTemplates      = "too many templates in the configuration: %d (limit: %d)"
 	errTemplateTooBig        = "template %s is too big: %d bytes (limit: %d bytes)"
+	errListingVersions       = "unable to list the Alertmanager config versions"
+	errReadingVersion        = "unable to read the Alertmanager config version"
+	errInvalidVersion        = "invalid Alertmanager config version"
+	errDeletedVersion        = "the version records the deletion of the Alertmanager config"
 
 	fetchConcurrency = 16
 )
@@ -147,7 +154,7 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 		return
 	}
 
-	err = am.store.SetAlertConfig(r.Context(), cfgDesc)
+	err = am.store.SetAlertConfig(versioning.ContextWithAuthorFromRequest(r), cfgDesc)
 	if err != nil {
 		level.Error(logger).Log("msg", errStoringConfiguration, "err", err.Error())
 		http.Error(w, fmt.Sprintf("%s: %s", errStoringConfiguration, err.Error()), http.StatusInternalServerError)
@@ -168,7 +175,7 @@ func (am *MultitenantAlertmanager) DeleteUserConfig(w http.ResponseWriter, r *ht
 		return
 	}
 
-	err = am.store.DeleteAlertConfig(r.Context(), userID)
+	err = am.store.DeleteAlertConfig(versioning.ContextWithAuthorFromRequest(r), userID)
 	if err != nil {
 		level.Error(logger).Log("msg", errDeletingConfiguration, "err", err.Error())
 		http.Error(w, fmt.Sprintf("%s: %s", errDeletingConfiguration, err.Error()), http.StatusInternalServerError)
@@ -178,6 +185,177 @@ func (am *MultitenantAlertmanager) DeleteUserConfig(w http.ResponseWriter, r *ht
 	w.WriteHeader(http.StatusOK)
 }
 
+func (am *MultitenantAlertmanager) ListUserConfigVersions(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	versions, err := am.store.ListAlertConfigVersions(r.Context(), userID)
+	if err != nil {
+		level.Error(logger).Log("msg", errListingVersions, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errListingVersions, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []versioning.Version{}
+	}
+
+	writeYAML(w, logger, userID, versions)
+}
+
+func (am *MultitenantAlertmanager) GetUserConfigVersion(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	_, cfg, ok := am.getUserConfigVersion(w, r, logger, userID, mux.Vars(r)["version"])
+	if !ok {
+		return
+	}
+	if cfg == nil {
+		http.Error(w, errDeletedVersion, http.StatusNotFound)
+		return
+	}
+
+	writeYAML(w, logger, userID, cfg)
+}
+
+// DiffUserConfigVersions responds with the unified diff between the two versions of the
+// Alertmanager config passed as "from" and "to" query parameters.
+func (am *MultitenantAlertmanager) DiffUserConfigVersions(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		versions [2]versioning.Version
+		texts    [2]string
+	)
+	for i, param := range []string{"from", "to"} {
+		v, cfg, ok := am.getUserConfigVersion(w, r, logger, userID, r.FormValue(param))
+		if !ok {
+			return
+		}
+		versions[i] = v
+
+		// A version recording the deletion of the config is diffed as an empty config.
+		if cfg != nil {
+			d, err := yaml.Marshal(cfg)
+			if err != nil {
+				level.Error(logger).Log("msg", errMarshallingYAML, "err", err, "user", userID)
+				http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusInternalServerError)
+				return
+			}
+			texts[i] = string(d)
+		}
+	}
+
+	diff, err := versioning.Diff(versions[0], texts[0], versions[1], texts[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(diff)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// RollbackUserConfig stores a previous version of the Alertmanager config as its new version.
+func (am *MultitenantAlertmanager) RollbackUserConfig(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	_, cfg, ok := am.getUserConfigVersion(w, r, logger, userID, mux.Vars(r)["version"])
+	if !ok {
+		return
+	}
+	if cfg == nil {
+		http.Error(w, errDeletedVersion, http.StatusBadRequest)
+		return
+	}
+
+	// The limits may have changed since the version was stored.
+	cfgDesc := alertspb.ToProto(cfg.AlertmanagerConfig, cfg.TemplateFiles, userID)
+	if err := validateUserConfig(logger, cfgDesc, am.limits, userID); err != nil {
+		level.Warn(logger).Log("msg", errValidatingConfig, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errValidatingConfig, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	err = am.store.SetAlertConfig(versioning.ContextWithAuthorFromRequest(r), cfgDesc)
+	if err != nil {
+		level.Error(logger).Log("msg", errStoringConfiguration, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errStoringConfiguration, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// getUserConfigVersion returns a version of the Alertmanager config of the user, or responds with
+// an error and returns false. The returned config is nil if the version records the deletion of the config.
+func (am *MultitenantAlertmanager) getUserConfigVersion(w http.ResponseWriter, r *http.Request, logger log.Logger, userID, version string) (versioning.Version, *UserConfig, bool) {
+	id, err := strconv.Atoi(version)
+	if err != nil || id <= 0 {
+		http.Error(w, fmt.Sprintf("%s: %q", errInvalidVersion, version), http.StatusBadRequest)
+		return versioning.Version{}, nil, false
+	}
+
+	v, cfg, err := am.store.GetAlertConfigVersion(r.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, versioning.ErrVersionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			level.Error(logger).Log("msg", errReadingVersion, "err", err.Error())
+			http.Error(w, fmt.Sprintf("%s: %s", errReadingVersion, err.Error()), http.StatusInternalServerError)
+		}
+		return v, nil, false
+	}
+	if v.Deleted {
+		return v, nil, true
+	}
+
+	return v, &UserConfig{
+		TemplateFiles:      alertspb.ParseTemplates(cfg),
+		AlertmanagerConfig: cfg.RawConfig,
+	}, true
+}
+
+func writeYAML(w http.ResponseWriter, logger log.Logger, userID string, out interface{}) {
+	d, err := yaml.Marshal(out)
+	if err != nil {
+		level.Error(logger).Log("msg", errMarshallingYAML, "err", err, "user", userID)
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 // Partially copied from: https://github.com/prometheus/alertmanager/blob/8e861c646bf67599a1704fc843c6a94d519ce312/cli/check_config.go#L65-L96
 func validateUserConfig(logger log.Logger, cfg alertspb.AlertConfigDesc, limits Limits, user string) error {
 	// We don't have a valid use case for empty configurations. If a tenant does not have a
@@ -26,10 +26,12 @@ import (
 
 	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
 	"github.com/grafana/mimir/pkg/alertmanager/alertstore/bucketclient"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 	util_log "github.com/grafana/mimir/pkg/util/log"
 
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v2"
 )
 
 func TestAMConfigValidationAPI(t *testing.T) {
@@ -609,7 +611,7 @@ template_files:
 
 func TestMultitenantAlertmanager_DeleteUserConfig(t *testing.T) {
 	storage := objstore.NewInMemBucket()
-	alertStore := bucketclient.NewBucketAlertStore(storage, nil, log.NewNopLogger())
+	alertStore := bucketclient.NewBucketAlertStore(storage, nil, 0, log.NewNopLogger())
 
 	am := &MultitenantAlertmanager{
 		store:  alertStore,
@@ -652,6 +654,87 @@ func TestMultitenantAlertmanager_DeleteUserConfig(t *testing.T) {
 	}
 }
 
+func TestMultitenantAlertmanager_UserConfigVersions(t *testing.T) {
+	storage := objstore.NewInMemBucket()
+	alertStore := bucketclient.NewBucketAlertStore(storage, nil, 10, log.NewNopLogger())
+
+	am := &MultitenantAlertmanager{
+		store:  alertStore,
+		logger: util_log.Logger,
+		limits: &mockAlertManagerLimits{},
+	}
+
+	router := mux.NewRouter()
+	router.Path("/api/v1/alerts").Methods(http.MethodPost).HandlerFunc(am.SetUserConfig)
+	router.Path("/api/v1/alerts").Methods(http.MethodDelete).HandlerFunc(am.DeleteUserConfig)
+	router.Path("/api/v1/alerts/versions").Methods(http.MethodGet).HandlerFunc(am.ListUserConfigVersions)
+	router.Path("/api/v1/alerts/versions/{version}").Methods(http.MethodGet).HandlerFunc(am.GetUserConfigVersion)
+	router.Path("/api/v1/alerts/versions/{version}/rollback").Methods(http.MethodPost).HandlerFunc(am.RollbackUserConfig)
+	router.Path("/api/v1/alerts/diff").Methods(http.MethodGet).HandlerFunc(am.DiffUserConfigVersions)
+
+	do := func(method, path, author, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		if author != "" {
+			req.Header.Set(versioning.AuthorHeader, author)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req.WithContext(user.InjectOrgID(req.Context(), "user1")))
+		return rec
+	}
+
+	cfg := func(receiver string) string {
+		return fmt.Sprintf(`
+alertmanager_config: |
+  route:
+    receiver: %s
+  receivers:
+    - name: %s
+`, receiver, receiver)
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/v1/alerts", "alice", cfg("first")).Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/v1/alerts", "bob", cfg("second")).Code)
+
+	rec := do(http.MethodGet, "/api/v1/alerts/versions", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var versions []versioning.Version
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &versions))
+	require.Len(t, versions, 2)
+	assert.Equal(t, []string{"alice", "bob"}, []string{versions[0].Author, versions[1].Author})
+
+	rec = do(http.MethodGet, "/api/v1/alerts/versions/1", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "receiver: first")
+
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/alerts/versions/3", "", "").Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/alerts/versions/foo", "", "").Code)
+
+	rec = do(http.MethodGet, "/api/v1/alerts/diff?from=1&to=2", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "--- version 1 by alice\n+++ version 2 by bob\n")
+	assert.Contains(t, rec.Body.String(), "-    receiver: first\n")
+	assert.Contains(t, rec.Body.String(), "+    receiver: second\n")
+
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/alerts/versions/3/rollback", "", "").Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/v1/alerts/versions/1/rollback", "dave", "").Code)
+
+	current, err := alertStore.GetAlertConfig(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Contains(t, current.RawConfig, "receiver: first")
+
+	versions, err = alertStore.ListAlertConfigVersions(context.Background(), "user1")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, "dave", versions[2].Author)
+
+	// The history is deleted together with the config.
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/alerts", "carol", "").Code)
+
+	versions, err = alertStore.ListAlertConfigVersions(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
 func TestAMConfigListUserConfig(t *testing.T) {
 	testCases := map[string]*UserConfig{
 		"user1": {
@@ -693,7 +776,7 @@ receivers:
 	}
 
 	storage := objstore.NewInMemBucket()
-	alertStore := bucketclient.NewBucketAlertStore(storage, nil, log.NewNopLogger())
+	alertStore := bucketclient.NewBucketAlertStore(storage, nil, 0, log.NewNopLogger())
 
 	for u, cfg := range testCases {
 		err := alertStore.SetAlertConfig(context.Background(), alertspb.AlertConfigDesc{
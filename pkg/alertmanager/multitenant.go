@@ -267,6 +267,11 @@ type MultitenantAlertmanager struct {
 	tenantsDiscovered prometheus.Gauge
 	syncTotal         *prometheus.CounterVec
 	syncFailures      *prometheus.CounterVec
+
+	// Rate limiters of the notifications, per tenant, shared by the tenant's Alertmanager and the test notifications.
+	notificationLimitersMtx sync.Mutex
+	notificationLimiters    map[string]*tenantNotificationLimiters
+	receiverTestRateLimited *prometheus.CounterVec
 }
 
 // NewMultitenantAlertmanager creates a new MultitenantAlertmanager.
@@ -325,6 +330,11 @@ func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackC
 			Name: "cortex_alertmanager_sync_configs_failed_total",
 			Help: "Total number of times the alertmanager sync operation failed.",
 		}, []string{"reason"}),
+		notificationLimiters: map[string]*tenantNotificationLimiters{},
+		receiverTestRateLimited: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_alertmanager_receiver_test_notifications_rate_limited_total",
+			Help: "Number of rate-limited test notifications per integration.",
+		}, []string{"integration"}),
 		tenantsDiscovered: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
 			Name: "cortex_alertmanager_tenants_discovered",
 			Help: "Number of tenants with an Alertmanager configuration discovered.",
@@ -724,6 +734,16 @@ func (am *MultitenantAlertmanager) syncConfigs(cfgs map[string]alertspb.AlertCon
 			am.alertmanagerMetrics.removeUserRegistry(userID)
 		}
 	}
+
+	// Remove the notification limiters of the tenants without an Alertmanager on this instance,
+	// including the ones created only for test notifications.
+	am.notificationLimitersMtx.Lock()
+	for userID := range am.notificationLimiters {
+		if _, exists := am.alertmanagers[userID]; !exists {
+			delete(am.notificationLimiters, userID)
+		}
+	}
+	am.notificationLimitersMtx.Unlock()
 	am.alertmanagersMtx.Unlock()
 
 	// Now stop alertmanagers and wait until they are really stopped, without holding lock.
@@ -858,6 +878,7 @@ func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amco
 		Store:                             am.store,
 		PersisterConfig:                   am.cfg.Persister,
 		Limits:                            am.limits,
+		NotificationLimiters:              am.tenantNotificationLimiters(userID),
 	}, reg)
 	if err != nil {
 		return nil, fmt.Errorf("unable to start Alertmanager for user %v: %v", userID, err)
@@ -871,6 +892,21 @@ func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amco
 	return newAM, nil
 }
 
+// tenantNotificationLimiters returns the notification limiters of the tenant, creating them if they
+// don't exist yet.
+func (am *MultitenantAlertmanager) tenantNotificationLimiters(userID string) *tenantNotificationLimiters {
+	am.notificationLimitersMtx.Lock()
+	defer am.notificationLimitersMtx.Unlock()
+
+	if l, ok := am.notificationLimiters[userID]; ok {
+		return l
+	}
+
+	l := newTenantNotificationLimiters(userID, am.limits)
+	am.notificationLimiters[userID] = l
+	return l
+}
+
 // GetPositionForUser returns the position this Alertmanager instance holds in the ring related to its other replicas for an specific user.
 func (am *MultitenantAlertmanager) GetPositionForUser(userID string) int {
 	// If we have a replication factor of 1 or less we don't need to do any work and can immediately return.
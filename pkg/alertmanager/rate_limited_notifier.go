@@ -8,6 +8,7 @@ package alertmanager
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/prometheus/alertmanager/notify"
@@ -24,8 +25,32 @@ type rateLimits interface {
 
 type rateLimitedNotifier struct {
 	upstream notify.Notifier
+	limiter  *notificationLimiter
 	counter  prometheus.Counter
+}
+
+func newRateLimitedNotifier(upstream notify.Notifier, limits rateLimits, recheckInterval time.Duration, counter prometheus.Counter) *rateLimitedNotifier {
+	return &rateLimitedNotifier{
+		upstream: upstream,
+		limiter:  newNotificationLimiter(limits, recheckInterval),
+		counter:  counter,
+	}
+}
+
+var errRateLimited = errors.New("failed to notify due to rate limits")
+
+func (r *rateLimitedNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	if !r.limiter.allow() {
+		r.counter.Inc()
+		// Don't retry this notification later.
+		return false, errRateLimited
+	}
 
+	return r.upstream.Notify(ctx, alerts...)
+}
+
+// notificationLimiter rate-limits notifications. It can be shared by multiple notifiers.
+type notificationLimiter struct {
 	limiter *rate.Limiter
 	limits  rateLimits
 
@@ -33,19 +58,16 @@ type rateLimitedNotifier struct {
 	recheckAt       atomic.Int64 // unix nanoseconds timestamp
 }
 
-func newRateLimitedNotifier(upstream notify.Notifier, limits rateLimits, recheckInterval time.Duration, counter prometheus.Counter) *rateLimitedNotifier {
-	return &rateLimitedNotifier{
-		upstream:        upstream,
-		counter:         counter,
+func newNotificationLimiter(limits rateLimits, recheckInterval time.Duration) *notificationLimiter {
+	return &notificationLimiter{
 		limits:          limits,
 		limiter:         rate.NewLimiter(limits.RateLimit(), limits.Burst()),
 		recheckInterval: recheckInterval,
 	}
 }
 
-var errRateLimited = errors.New("failed to notify due to rate limits")
-
-func (r *rateLimitedNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+// allow returns whether a notification is allowed by the rate limits.
+func (r *notificationLimiter) allow() bool {
 	now := time.Now()
 	if now.UnixNano() >= r.recheckAt.Load() {
 		if limit := r.limits.RateLimit(); r.limiter.Limit() != limit {
@@ -60,11 +82,42 @@ func (r *rateLimitedNotifier) Notify(ctx context.Context, alerts ...*types.Alert
 	}
 
 	// This counts as single notification, no matter how many alerts there are in it.
-	if !r.limiter.AllowN(now, 1) {
-		r.counter.Inc()
-		// Don't retry this notification later.
-		return false, errRateLimited
+	return r.limiter.AllowN(now, 1)
+}
+
+// tenantNotificationLimiters holds the notification limiters of a tenant, one per integration,
+// shared by all the notifiers of the integration.
+type tenantNotificationLimiters struct {
+	tenant string
+	limits Limits
+
+	mtx      sync.Mutex
+	limiters map[string]*notificationLimiter
+}
+
+func newTenantNotificationLimiters(tenant string, limits Limits) *tenantNotificationLimiters {
+	return &tenantNotificationLimiters{
+		tenant:   tenant,
+		limits:   limits,
+		limiters: map[string]*notificationLimiter{},
 	}
+}
 
-	return r.upstream.Notify(ctx, alerts...)
+// get returns the limiter of the integration, creating it if it doesn't exist yet.
+func (l *tenantNotificationLimiters) get(integration string) *notificationLimiter {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if limiter, ok := l.limiters[integration]; ok {
+		return limiter
+	}
+
+	limiter := newNotificationLimiter(&tenantRateLimits{
+		tenant:      l.tenant,
+		limits:      l.limits,
+		integration: integration,
+	}, 10*time.Second)
+	l.limiters[integration] = limiter
+
+	return limiter
 }
@@ -44,7 +44,7 @@ func TestRateLimitedNotifier(t *testing.T) {
 }
 
 func runNotifications(t *testing.T, rateLimitedNotifier *rateLimitedNotifier, counter prometheus.Counter, count, expectedSuccess, expectedRateLimited, expectedCounter int) {
-	rateLimitedNotifier.recheckAt.Store(0) // Force recheck of limits.
+	rateLimitedNotifier.limiter.recheckAt.Store(0) // Force recheck of limits.
 
 	success := 0
 	rateLimited := 0
@@ -0,0 +1,316 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+	util_net "github.com/grafana/mimir/pkg/util/net"
+)
+
+const (
+	errReadingTestRequest  = "unable to read the test request"
+	errInvalidTestRequest  = "invalid test request"
+	errReceiverNotFound    = "receiver %q not found in the Alertmanager configuration"
+	errBuildingTestConfig  = "unable to build the configuration of the receiver"
+	errReadingTestTemplate = "unable to read the templates of the Alertmanager configuration"
+
+	// receiverTestTimeout is the maximum time spent sending the test notification of a receiver.
+	receiverTestTimeout = 30 * time.Second
+
+	receiverTestStatusSuccess = "success"
+	receiverTestStatusFailed  = "failed"
+)
+
+// TestReceiverRequest is the request to send a test notification through a receiver. Exactly
+// one of ReceiverName and Receiver must be set.
+type TestReceiverRequest struct {
+	// ReceiverName is the name of a receiver of the current Alertmanager configuration.
+	ReceiverName string `yaml:"receiver_name"`
+
+	// Receiver is a receiver definition, using the global configuration and the templates
+	// of the current Alertmanager configuration.
+	Receiver interface{} `yaml:"receiver"`
+}
+
+// TestReceiverResult is the outcome of the test notification sent through a receiver.
+type TestReceiverResult struct {
+	Receiver     string                  `yaml:"receiver"`
+	Integrations []TestIntegrationResult `yaml:"integrations"`
+}
+
+// TestIntegrationResult is the outcome of the test notification sent through an integration of a receiver.
+type TestIntegrationResult struct {
+	Name   string `yaml:"name"`
+	Index  int    `yaml:"index"`
+	Status string `yaml:"status"`
+	Error  string `yaml:"error,omitempty"`
+}
+
+// TestReceiver sends a test notification through each integration of a receiver of the tenant, and
+// responds with the outcome of each integration. The notifications are subject to the receivers
+// firewall and to the notification rate limits of the tenant.
+func (am *MultitenantAlertmanager) TestReceiver(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	var input io.Reader = r.Body
+	maxConfigSize := am.limits.AlertmanagerMaxConfigSize(userID)
+	if maxConfigSize > 0 {
+		// Allow one extra byte to check if the request is too big.
+		input = io.LimitReader(r.Body, int64(maxConfigSize)+1)
+	}
+
+	payload, err := ioutil.ReadAll(input)
+	if err != nil {
+		level.Error(logger).Log("msg", errReadingTestRequest, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingTestRequest, err.Error()), http.StatusBadRequest)
+		return
+	}
+	if maxConfigSize > 0 && len(payload) > maxConfigSize {
+		msg := fmt.Sprintf(errConfigurationTooBig, maxConfigSize)
+		level.Warn(logger).Log("msg", msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	req := TestReceiverRequest{}
+	if err := yaml.Unmarshal(payload, &req); err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusBadRequest)
+		return
+	}
+	if (req.ReceiverName == "") == (req.Receiver == nil) {
+		http.Error(w, fmt.Sprintf("%s: exactly one of receiver_name and receiver must be set", errInvalidTestRequest), http.StatusBadRequest)
+		return
+	}
+
+	cfgDesc, err := am.store.GetAlertConfig(r.Context(), userID)
+	if err != nil && !errors.Is(err, alertspb.ErrNotFound) {
+		level.Error(logger).Log("msg", errReadingConfiguration, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingConfiguration, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if cfgDesc.RawConfig == "" {
+		cfgDesc.RawConfig = am.fallbackConfig
+	}
+
+	rcv, templates, err := testReceiverConfig(cfgDesc.RawConfig, req)
+	if errors.Is(err, errTestReceiverNotFound) {
+		http.Error(w, fmt.Sprintf(errReceiverNotFound, req.ReceiverName), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errBuildingTestConfig, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	tmpl, err := am.testReceiverTemplate(userID, cfgDesc.Templates, templates)
+	if err != nil {
+		level.Warn(logger).Log("msg", errReadingTestTemplate, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingTestTemplate, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	results, err := am.testReceiver(r.Context(), userID, rcv, tmpl, logger)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", errBuildingTestConfig, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	writeYAML(w, logger, userID, TestReceiverResult{
+		Receiver:     rcv.Name,
+		Integrations: results,
+	})
+}
+
+var errTestReceiverNotFound = errors.New("receiver not found")
+
+// testReceiverConfig returns the receiver to test and the templates it may use, based on the raw
+// Alertmanager configuration of the tenant.
+func testReceiverConfig(rawCfg string, req TestReceiverRequest) (*config.Receiver, []string, error) {
+	if req.ReceiverName != "" {
+		cfg, err := config.Load(rawCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, rcv := range cfg.Receivers {
+			if rcv.Name == req.ReceiverName {
+				return rcv, cfg.Templates, nil
+			}
+		}
+		return nil, nil, errTestReceiverNotFound
+	}
+
+	// The receiver is loaded within the current configuration, so that the global configuration
+	// is applied to it. The routing tree and the inhibition rules are replaced, as they may
+	// reference the receivers of the current configuration.
+	base := yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(rawCfg), &base); err != nil {
+		return nil, nil, err
+	}
+
+	rcv := config.Receiver{}
+	buf, err := yaml.Marshal(req.Receiver)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := yaml.UnmarshalStrict(buf, &rcv); err != nil {
+		return nil, nil, err
+	}
+
+	testCfg := yaml.MapSlice{}
+	for _, item := range base {
+		switch item.Key {
+		case "route", "receivers", "inhibit_rules":
+			continue
+		}
+		testCfg = append(testCfg, item)
+	}
+	testCfg = append(testCfg,
+		yaml.MapItem{Key: "route", Value: map[string]string{"receiver": rcv.Name}},
+		yaml.MapItem{Key: "receivers", Value: []interface{}{req.Receiver}},
+	)
+
+	buf, err = yaml.Marshal(testCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := config.Load(string(buf))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateAlertmanagerConfig(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg.Receivers[0], cfg.Templates, nil
+}
+
+// testReceiverTemplate stores the template files of the tenant in a temporary directory,
+// and returns the template built from the templates referenced by the configuration.
+func (am *MultitenantAlertmanager) testReceiverTemplate(userID string, templateFiles []*alertspb.TemplateDesc, templates []string) (*template.Template, error) {
+	userTempDir, err := ioutil.TempDir("", "test-receiver-"+userID)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(userTempDir)
+
+	for _, t := range templateFiles {
+		templateFilepath, err := safeTemplateFilepath(userTempDir, t.Filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := storeTemplateFile(templateFilepath, t.Body); err != nil {
+			return nil, fmt.Errorf("unable to store template file '%s'", t.Filename)
+		}
+	}
+
+	paths := make([]string, len(templates))
+	for i, t := range templates {
+		if paths[i], err = safeTemplateFilepath(userTempDir, t); err != nil {
+			return nil, err
+		}
+	}
+
+	tmpl, err := template.FromGlobs(paths...)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.ExternalURL = am.cfg.ExternalURL.URL
+
+	return tmpl, nil
+}
+
+// testReceiver sends a test notification through each integration of the receiver concurrently,
+// and returns the outcome of each integration.
+func (am *MultitenantAlertmanager) testReceiver(ctx context.Context, userID string, rcv *config.Receiver, tmpl *template.Template, logger log.Logger) ([]TestIntegrationResult, error) {
+	firewallDialer := util_net.NewFirewallDialer(newFirewallDialerConfigProvider(userID, am.limits))
+
+	integrations, err := buildReceiverIntegrations(rcv, tmpl, firewallDialer, logger, func(integrationName string, notifier notify.Notifier) notify.Notifier {
+		// The test notifications share the rate limits of the notifications of the alerts.
+		return &rateLimitedNotifier{
+			upstream: notifier,
+			limiter:  am.tenantNotificationLimiters(userID).get(integrationName),
+			counter:  am.receiverTestRateLimited.WithLabelValues(integrationName),
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	groupLabels := model.LabelSet{model.AlertNameLabel: "TestAlert"}
+	alert := &types.Alert{
+		Alert: model.Alert{
+			Labels: model.LabelSet{
+				model.AlertNameLabel: "TestAlert",
+				"instance":           "Grafana Mimir",
+			},
+			Annotations: model.LabelSet{
+				"summary":     "Test notification",
+				"description": "This is a test notification sent by Grafana Mimir to check the receiver.",
+			},
+			StartsAt: now,
+		},
+		UpdatedAt: now,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, receiverTestTimeout)
+	defer cancel()
+
+	// Each test gets its own group key, so that the notification isn't deduplicated by the receiver.
+	ctx = notify.WithGroupKey(ctx, fmt.Sprintf("{}/test:%s:%d", groupLabels, now.UnixNano()))
+	ctx = notify.WithReceiverName(ctx, rcv.Name)
+	ctx = notify.WithGroupLabels(ctx, groupLabels)
+	ctx = notify.WithNow(ctx, now)
+
+	results := make([]TestIntegrationResult, len(integrations))
+	wg := sync.WaitGroup{}
+	for i := range integrations {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			integration := integrations[i]
+			results[i] = TestIntegrationResult{
+				Name:   integration.Name(),
+				Index:  integration.Index(),
+				Status: receiverTestStatusSuccess,
+			}
+
+			if _, err := integration.Notify(ctx, alert); err != nil {
+				level.Debug(logger).Log("msg", "test notification failed", "receiver", rcv.Name, "integration", integration.String(), "err", err)
+				results[i].Status = receiverTestStatusFailed
+				results[i].Error = err.Error()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return results, nil
+}
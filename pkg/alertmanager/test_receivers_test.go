@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestMultitenantAlertmanager_TestReceiver(t *testing.T) {
+	const userID = "user-1"
+
+	received := atomic.NewInt64(0)
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), `"alertname":"TestAlert"`) {
+			received.Inc()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failingServer.Close()
+
+	rawCfg := fmt.Sprintf(`
+route:
+  receiver: webhook
+  routes:
+    - receiver: other
+receivers:
+  - name: webhook
+    webhook_configs:
+      - url: %s
+  - name: other
+`, okServer.URL)
+
+	type integrationResult struct {
+		name, status string
+	}
+
+	tests := map[string]struct {
+		request         string
+		firewallEnabled bool
+		rateLimit       float64
+		expectedCode    int
+		expectedResults []integrationResult
+	}{
+		"receiver of the current configuration": {
+			request:         "receiver_name: webhook",
+			expectedCode:    http.StatusOK,
+			expectedResults: []integrationResult{{"webhook", receiverTestStatusSuccess}},
+		},
+		"receiver definition": {
+			request: fmt.Sprintf(`
+receiver:
+  name: new
+  webhook_configs:
+    - url: %s
+    - url: %s
+`, okServer.URL, failingServer.URL),
+			expectedCode:    http.StatusOK,
+			expectedResults: []integrationResult{{"webhook", receiverTestStatusSuccess}, {"webhook", receiverTestStatusFailed}},
+		},
+		"receiver blocked by the firewall": {
+			request:         "receiver_name: webhook",
+			firewallEnabled: true,
+			expectedCode:    http.StatusOK,
+			expectedResults: []integrationResult{{"webhook", receiverTestStatusFailed}},
+		},
+		"unknown receiver": {
+			request:      "receiver_name: unknown",
+			expectedCode: http.StatusNotFound,
+		},
+		"invalid receiver definition": {
+			request:      "receiver: {webhook_configs: [{url: http://localhost}]}",
+			expectedCode: http.StatusBadRequest,
+		},
+		"both receiver name and definition": {
+			request:      "{receiver_name: webhook, receiver: {name: new}}",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var limits validation.Limits
+			flagext.DefaultValues(&limits)
+			limits.AlertmanagerReceiversBlockPrivateAddresses = tc.firewallEnabled
+
+			overrides, err := validation.NewOverrides(limits, nil)
+			require.NoError(t, err)
+
+			store := prepareInMemoryAlertStore()
+			require.NoError(t, store.SetAlertConfig(context.Background(), alertspb.AlertConfigDesc{
+				User:      userID,
+				RawConfig: rawCfg,
+			}))
+
+			am := &MultitenantAlertmanager{
+				cfg:                     mockAlertmanagerConfig(t),
+				store:                   store,
+				logger:                  log.NewNopLogger(),
+				limits:                  overrides,
+				notificationLimiters:    map[string]*tenantNotificationLimiters{},
+				receiverTestRateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"integration"}),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts/receivers/test", bytes.NewReader([]byte(tc.request)))
+			rec := httptest.NewRecorder()
+			am.TestReceiver(rec, req.WithContext(user.InjectOrgID(req.Context(), userID)))
+			require.Equal(t, tc.expectedCode, rec.Code, rec.Body.String())
+
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			result := TestReceiverResult{}
+			require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &result))
+			require.Len(t, result.Integrations, len(tc.expectedResults))
+			for i, expected := range tc.expectedResults {
+				actual := result.Integrations[i]
+				assert.Equal(t, expected.name, actual.Name)
+				assert.Equal(t, i, actual.Index)
+				assert.Equal(t, expected.status, actual.Status)
+				assert.Equal(t, expected.status == receiverTestStatusFailed, actual.Error != "")
+			}
+		})
+	}
+
+	// Only the successful notifications reached the server.
+	assert.Equal(t, int64(2), received.Load())
+}
+
+func TestMultitenantAlertmanager_TestReceiver_RateLimits(t *testing.T) {
+	const userID = "user-1"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var limits validation.Limits
+	flagext.DefaultValues(&limits)
+	limits.NotificationRateLimitPerIntegration = map[string]float64{"webhook": 0.01}
+
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+
+	am := &MultitenantAlertmanager{
+		cfg:                     mockAlertmanagerConfig(t),
+		store:                   prepareInMemoryAlertStore(),
+		logger:                  log.NewNopLogger(),
+		limits:                  overrides,
+		notificationLimiters:    map[string]*tenantNotificationLimiters{},
+		receiverTestRateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"integration"}),
+	}
+
+	testReceiver := func() TestIntegrationResult {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts/receivers/test", bytes.NewReader([]byte(fmt.Sprintf(`
+receiver:
+  name: new
+  webhook_configs:
+    - url: %s
+`, server.URL))))
+		rec := httptest.NewRecorder()
+		am.TestReceiver(rec, req.WithContext(user.InjectOrgID(req.Context(), userID)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		result := TestReceiverResult{}
+		require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &result))
+		require.Len(t, result.Integrations, 1)
+		return result.Integrations[0]
+	}
+
+	// The burst allows a single notification.
+	assert.Equal(t, receiverTestStatusSuccess, testReceiver().Status)
+
+	result := testReceiver()
+	assert.Equal(t, receiverTestStatusFailed, result.Status)
+	assert.Equal(t, errRateLimited.Error(), result.Error)
+	assert.Equal(t, float64(1), testutil.ToFloat64(am.receiverTestRateLimited.WithLabelValues("webhook")))
+
+	// The limiters of the tenants without an Alertmanager on this instance are removed on sync.
+	am.syncConfigs(map[string]alertspb.AlertConfigDesc{})
+	assert.Empty(t, am.notificationLimiters)
+
+	// The test notifications share the rate limits of the notifications sent by the tenant's Alertmanager.
+	require.True(t, am.tenantNotificationLimiters(userID).get("webhook").allow())
+
+	result = testReceiver()
+	assert.Equal(t, receiverTestStatusFailed, result.Status)
+	assert.Equal(t, errRateLimited.Error(), result.Error)
+	assert.Equal(t, float64(2), testutil.ToFloat64(am.receiverTestRateLimited.WithLabelValues("webhook")))
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/grafana/mimir/pkg/frontend/v2/frontendv2pb"
 	"github.com/grafana/mimir/pkg/ingester/client"
 	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/overridesstore"
 	"github.com/grafana/mimir/pkg/purger"
 	"github.com/grafana/mimir/pkg/querier"
 	"github.com/grafana/mimir/pkg/ruler"
@@ -208,6 +209,11 @@ func (a *API) RegisterAlertmanager(am *alertmanager.MultitenantAlertmanager, api
 		a.RegisterRoute("/api/v1/alerts", http.HandlerFunc(am.GetUserConfig), true, true, "GET")
 		a.RegisterRoute("/api/v1/alerts", http.HandlerFunc(am.SetUserConfig), true, true, "POST")
 		a.RegisterRoute("/api/v1/alerts", http.HandlerFunc(am.DeleteUserConfig), true, true, "DELETE")
+		a.RegisterRoute("/api/v1/alerts/versions", http.HandlerFunc(am.ListUserConfigVersions), true, true, "GET")
+		a.RegisterRoute("/api/v1/alerts/versions/{version}", http.HandlerFunc(am.GetUserConfigVersion), true, true, "GET")
+		a.RegisterRoute("/api/v1/alerts/versions/{version}/rollback", http.HandlerFunc(am.RollbackUserConfig), true, true, "POST")
+		a.RegisterRoute("/api/v1/alerts/diff", http.HandlerFunc(am.DiffUserConfigVersions), true, true, "GET")
+		a.RegisterRoute("/api/v1/alerts/receivers/test", http.HandlerFunc(am.TestReceiver), true, true, "POST")
 	}
 }
 
@@ -225,6 +231,15 @@ func (a *API) RegisterAPI(httpPathPrefix string, actualCfg interface{}, defaultC
 	a.RegisterRoute("/api/v1/status/buildinfo", buildInfoHandler, false, true, "GET")
 }
 
+// RegisterConfigLint registers the endpoint reporting the configuration which doesn't follow the best practices.
+func (a *API) RegisterConfigLint(handler http.Handler) {
+	a.indexPage.AddLinks(configWeight, "Config lint", []IndexPageLink{
+		{Desc: "Findings of the configuration best practices checks", Path: "/config/lint"},
+	})
+
+	a.RegisterRoute("/config/lint", handler, false, true, "GET")
+}
+
 // RegisterRuntimeConfig registers the endpoints associates with the runtime configuration
 func (a *API) RegisterRuntimeConfig(runtimeConfigHandler http.HandlerFunc) {
 	a.indexPage.AddLinks(runtimeConfigWeight, "Current runtime config", []IndexPageLink{
@@ -235,11 +250,21 @@ func (a *API) RegisterRuntimeConfig(runtimeConfigHandler http.HandlerFunc) {
 	a.RegisterRoute("/runtime_config", runtimeConfigHandler, false, true, "GET")
 }
 
+// RegisterOverridesStorage registers the endpoints to read and write the per-tenant overrides stored in the object storage.
+func (a *API) RegisterOverridesStorage(api *overridesstore.API) {
+	a.RegisterRoute("/overrides/{tenant}", http.HandlerFunc(api.GetOverrides), false, true, "GET")
+	a.RegisterRoute("/overrides/{tenant}", http.HandlerFunc(api.SetOverrides), false, true, "PUT")
+	a.RegisterRoute("/overrides/{tenant}", http.HandlerFunc(api.PatchOverrides), false, true, "PATCH")
+	a.RegisterRoute("/overrides/{tenant}", http.HandlerFunc(api.DeleteOverrides), false, true, "DELETE")
+	a.RegisterRoute("/overrides/{tenant}/audit", http.HandlerFunc(api.GetAuditLog), false, true, "GET")
+}
+
 // RegisterDistributor registers the endpoints associated with the distributor.
 func (a *API) RegisterDistributor(d *distributor.Distributor, pushConfig distributor.Config) {
 	distributorpb.RegisterDistributorServer(a.server.GRPC, d)
 
 	a.RegisterRoute("/api/v1/push", push.Handler(pushConfig.MaxRecvMsgSize, a.sourceIPs, a.cfg.SkipLabelNameValidationHeader, a.cfg.wrapDistributorPush(d)), true, false, "POST")
+	a.RegisterRoute("/api/v1/push/influx/write", push.InfluxHandler(pushConfig.MaxRecvMsgSize, a.sourceIPs, pushConfig.Influx, a.cfg.wrapDistributorPush(d)), true, false, "POST")
 
 	a.indexPage.AddLinks(defaultWeight, "Distributor", []IndexPageLink{
 		{Desc: "Ring status", Path: "/distributor/ring"},
@@ -250,6 +275,7 @@ func (a *API) RegisterDistributor(d *distributor.Distributor, pushConfig distrib
 	a.RegisterRoute("/distributor/ring", d, false, true, "GET", "POST")
 	a.RegisterRoute("/distributor/all_user_stats", http.HandlerFunc(d.AllUserStatsHandler), false, true, "GET")
 	a.RegisterRoute("/distributor/ha_tracker", d.HATracker, false, true, "GET")
+	a.RegisterRoute("/distributor/ha_tracker/failover", http.HandlerFunc(d.HATracker.FailoverHandler), false, true, "POST")
 }
 
 // Ingester is defined as an interface to allow for alternative implementations
@@ -258,6 +284,9 @@ type Ingester interface {
 	client.IngesterServer
 	FlushHandler(http.ResponseWriter, *http.Request)
 	ShutdownHandler(http.ResponseWriter, *http.Request)
+	SeriesPerLabelValueHandler(http.ResponseWriter, *http.Request)
+	WALReplayStatusHandler(http.ResponseWriter, *http.Request)
+	PrepareDownscaleHandler(http.ResponseWriter, *http.Request)
 	PushWithCleanup(context.Context, *mimirpb.WriteRequest, func()) (*mimirpb.WriteResponse, error)
 }
 
@@ -265,13 +294,21 @@ type Ingester interface {
 func (a *API) RegisterIngester(i Ingester, pushConfig distributor.Config) {
 	client.RegisterIngesterServer(a.server.GRPC, i)
 
+	a.indexPage.AddLinks(defaultWeight, "Ingester TSDB", []IndexPageLink{
+		{Desc: "WAL replay status", Path: "/ingester/tsdb_wal_replay_status"},
+	})
+
 	a.indexPage.AddLinks(dangerousWeight, "Dangerous", []IndexPageLink{
 		{Dangerous: true, Desc: "Trigger a flush of data from ingester to storage", Path: "/ingester/flush"},
 		{Dangerous: true, Desc: "Trigger ingester shutdown", Path: "/ingester/shutdown"},
+		{Dangerous: true, Desc: "Prepare ingester for downscale", Path: "/ingester/prepare_downscale"},
 	})
 
 	a.RegisterRoute("/ingester/flush", http.HandlerFunc(i.FlushHandler), false, true, "GET", "POST")
 	a.RegisterRoute("/ingester/shutdown", http.HandlerFunc(i.ShutdownHandler), false, true, "GET", "POST")
+	a.RegisterRoute("/ingester/prepare_downscale", http.HandlerFunc(i.PrepareDownscaleHandler), false, true, "GET", "POST")
+	a.RegisterRoute("/ingester/series_per_label_value", http.HandlerFunc(i.SeriesPerLabelValueHandler), true, true, "GET")
+	a.RegisterRoute("/ingester/tsdb_wal_replay_status", http.HandlerFunc(i.WALReplayStatusHandler), false, true, "GET")
 	a.RegisterRoute("/ingester/push", push.Handler(pushConfig.MaxRecvMsgSize, a.sourceIPs, a.cfg.SkipLabelNameValidationHeader, i.PushWithCleanup), true, false, "POST") // For testing and debugging.
 }
 
@@ -330,6 +367,10 @@ func (a *API) RegisterRulerAPI(r *ruler.API, configAPIEnabled bool) {
 		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), http.HandlerFunc(r.CreateRuleGroup), true, true, "POST")
 		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}"), http.HandlerFunc(r.DeleteRuleGroup), true, true, "DELETE")
 		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}"), http.HandlerFunc(r.DeleteNamespace), true, true, "DELETE")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}/versions"), http.HandlerFunc(r.ListRuleGroupVersions), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}/versions/{version}"), http.HandlerFunc(r.GetRuleGroupVersion), true, true, "GET")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}/versions/{version}/rollback"), http.HandlerFunc(r.RollbackRuleGroup), true, true, "POST")
+		a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/config/v1/rules/{namespace}/{groupName}/diff"), http.HandlerFunc(r.DiffRuleGroupVersions), true, true, "GET")
 	}
 }
 
@@ -40,6 +40,7 @@ type BlocksCleanerConfig struct {
 	CleanupConcurrency      int
 	TenantCleanupDelay      time.Duration // Delay before removing tenant deletion mark and "debug".
 	DeleteBlocksConcurrency int
+	TenantDataCleaners      []TenantDataCleaner // Run before removing tenant deletion mark.
 }
 
 type BlocksCleaner struct {
@@ -294,6 +295,14 @@ func (c *BlocksCleaner) deleteUserMarkedForDeletion(ctx context.Context, userID
 		level.Info(userLogger).Log("msg", "deleted files under "+block.DebugMetas+" for tenant marked for deletion", "count", deleted)
 	}
 
+	// The tenant deletion mark is kept until the data stored outside of the blocks storage is deleted too,
+	// so that the cleanup is retried on failure.
+	for _, cleaner := range c.cfg.TenantDataCleaners {
+		if err := cleaner(ctx, userID); err != nil {
+			return errors.Wrap(err, "failed to delete tenant data")
+		}
+	}
+
 	// Tenant deletion mark file is inside Markers as well.
 	if deleted, err := bucket.DeletePrefix(ctx, userBucket, bucketindex.MarkersPathname, userLogger); err != nil {
 		return errors.Wrap(err, "failed to delete marker files")
@@ -451,6 +451,50 @@ func TestBlocksCleaner_ShouldNotCleanupUserThatDoesntBelongToShardAnymore(t *tes
 	require.ElementsMatch(t, []string{}, cleaner.lastOwnedUsers)
 }
 
+func TestBlocksCleaner_ShouldRunTenantDataCleanersBeforeRemovingTenantDeletionMark(t *testing.T) {
+	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	bucketClient = bucketindex.BucketWithGlobalMarkers(bucketClient)
+
+	// User-1 with no more blocks, whose final cleanup is due.
+	mark := tsdb.NewTenantDeletionMark(time.Now())
+	mark.FinishedTime = time.Now().Unix() - 60
+	require.NoError(t, tsdb.WriteTenantDeletionMark(context.Background(), bucketClient, "user-1", nil, mark))
+
+	var (
+		cleanedUsers []string
+		cleanerErr   = errors.New("failed to delete data")
+	)
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:           time.Hour,
+		CleanupInterval:         time.Minute,
+		CleanupConcurrency:      1,
+		DeleteBlocksConcurrency: 1,
+		TenantDataCleaners: []TenantDataCleaner{func(_ context.Context, userID string) error {
+			cleanedUsers = append(cleanedUsers, userID)
+			return cleanerErr
+		}},
+	}
+
+	ctx := context.Background()
+	cleaner := NewBlocksCleaner(cfg, bucketClient, tsdb.AllUsers, newMockConfigProvider(), log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	// The tenant deletion mark is kept if a cleaner fails, so that the cleanup is retried.
+	require.Error(t, cleaner.cleanUsers(ctx))
+	require.Equal(t, []string{"user-1"}, cleanedUsers)
+
+	exists, err := bucketClient.Exists(ctx, path.Join("user-1", tsdb.TenantDeletionMarkPath))
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	cleanerErr = nil
+	require.NoError(t, cleaner.cleanUsers(ctx))
+	require.Equal(t, []string{"user-1", "user-1"}, cleanedUsers)
+
+	exists, err = bucketClient.Exists(ctx, path.Join("user-1", tsdb.TenantDeletionMarkPath))
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
 func TestBlocksCleaner_ListBlocksOutsideRetentionPeriod(t *testing.T) {
 	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
 	bucketClient = bucketindex.BucketWithGlobalMarkers(bucketClient)
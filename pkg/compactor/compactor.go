@@ -70,6 +70,10 @@ type BlocksGrouperFactory func(
 	reg prometheus.Registerer,
 ) Grouper
 
+// TenantDataCleaner deletes data of a tenant stored outside of the blocks storage, once the tenant
+// marked for deletion is cleaned up.
+type TenantDataCleaner func(ctx context.Context, userID string) error
+
 // BlocksCompactorFactory builds and returns the compactor and planner to use to compact a tenant's blocks.
 type BlocksCompactorFactory func(
 	ctx context.Context,
@@ -107,6 +111,9 @@ type Config struct {
 
 	CompactionJobsOrder string `yaml:"compaction_jobs_order" category:"advanced"`
 
+	// Continuous replication of tenants to another bucket.
+	Replication ReplicationConfig `yaml:"replication"`
+
 	// No need to add options to customize the retry backoff,
 	// given the defaults should be fine, but allow to override
 	// it in tests.
@@ -116,11 +123,15 @@ type Config struct {
 	// Allow downstream projects to customise the blocks compactor.
 	BlocksGrouperFactory   BlocksGrouperFactory   `yaml:"-"`
 	BlocksCompactorFactory BlocksCompactorFactory `yaml:"-"`
+
+	// Cleaners of the tenant data stored outside of the blocks storage.
+	TenantDataCleaners []TenantDataCleaner `yaml:"-"`
 }
 
 // RegisterFlags registers the MultitenantCompactor flags.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.ShardingRing.RegisterFlags(f, logger)
+	cfg.Replication.RegisterFlags(f)
 
 	cfg.BlockRanges = mimir_tsdb.DurationList{2 * time.Hour, 12 * time.Hour, 24 * time.Hour}
 	cfg.retryMinBackoff = 10 * time.Second
@@ -173,7 +184,7 @@ func (cfg *Config) Validate() error {
 		return errInvalidCompactionOrder
 	}
 
-	return nil
+	return cfg.Replication.Validate()
 }
 
 // ConfigProvider defines the per-tenant config provider for the MultitenantCompactor.
@@ -214,6 +225,9 @@ type MultitenantCompactor struct {
 	// Blocks cleaner is responsible to hard delete blocks marked for deletion.
 	blocksCleaner *BlocksCleaner
 
+	// Tenants replicator is responsible to copy blocks to the replication storage, if enabled.
+	tenantsReplicator *TenantsReplicator
+
 	// Underlying compactor and planner used to compact TSDB blocks.
 	blocksCompactor Compactor
 	blocksPlanner   Planner
@@ -449,6 +463,7 @@ func (c *MultitenantCompactor) starting(ctx context.Context) error {
 		CleanupConcurrency:      c.compactorCfg.CleanupConcurrency,
 		TenantCleanupDelay:      c.compactorCfg.TenantCleanupDelay,
 		DeleteBlocksConcurrency: defaultDeleteBlocksConcurrency,
+		TenantDataCleaners:      c.compactorCfg.TenantDataCleaners,
 	}, c.bucketClient, c.shardingStrategy.blocksCleanerOwnUser, c.cfgProvider, c.parentLogger, c.registerer)
 
 	// Start blocks cleaner asynchronously, don't wait until initial cleanup is finished.
@@ -457,13 +472,37 @@ func (c *MultitenantCompactor) starting(ctx context.Context) error {
 		return errors.Wrap(err, "failed to start the blocks cleaner")
 	}
 
+	if c.compactorCfg.Replication.Enabled {
+		replicationBucket, err := bucket.NewClient(ctx, c.compactorCfg.Replication.Storage, "compactor-replication", c.parentLogger, c.registerer)
+		if err != nil {
+			c.stopBlocksCleanerAndRing()
+			return errors.Wrap(err, "failed to create replication bucket client")
+		}
+
+		c.tenantsReplicator = NewTenantsReplicator(c.compactorCfg.Replication, c.bucketClient, replicationBucket, c.shardingStrategy.blocksCleanerOwnUser, c.cfgProvider, c.parentLogger, c.registerer)
+		if err := c.tenantsReplicator.StartAsync(ctx); err != nil {
+			c.stopBlocksCleanerAndRing()
+			return errors.Wrap(err, "failed to start the tenants replicator")
+		}
+	}
+
 	return nil
 }
 
+// stopBlocksCleanerAndRing stops the services started by starting, since stopping isn't called
+// if starting fails.
+func (c *MultitenantCompactor) stopBlocksCleanerAndRing() {
+	services.StopAndAwaitTerminated(context.Background(), c.blocksCleaner) //nolint:errcheck
+	c.ringSubservices.StopAsync()
+}
+
 func (c *MultitenantCompactor) stopping(_ error) error {
 	ctx := context.Background()
 
 	services.StopAndAwaitTerminated(ctx, c.blocksCleaner) //nolint:errcheck
+	if c.tenantsReplicator != nil {
+		services.StopAndAwaitTerminated(ctx, c.tenantsReplicator) //nolint:errcheck
+	}
 	if c.ringSubservices != nil {
 		return services.StopManagerAndAwaitStopped(ctx, c.ringSubservices)
 	}
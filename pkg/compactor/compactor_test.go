@@ -1779,6 +1779,25 @@ func TestMultitenantCompactor_ShouldFailCompactionOnTimeout(t *testing.T) {
 	}, removeIgnoredLogs(strings.Split(strings.TrimSpace(logs.String()), "\n")))
 }
 
+func TestMultitenantCompactor_ShouldStopBlocksCleanerOnReplicationStartFailure(t *testing.T) {
+	t.Parallel()
+
+	bucketClient := &bucket.ClientMock{}
+	bucketClient.MockIter("", []string{}, nil)
+
+	cfg := prepareConfig(t)
+	cfg.Replication.Enabled = true
+	cfg.Replication.Storage.Backend = "unknown"
+
+	c, _, _, _, _ := prepare(t, cfg, bucketClient)
+
+	err := services.StartAndAwaitRunning(context.Background(), c)
+	require.ErrorIs(t, err, bucket.ErrUnsupportedStorageBackend)
+
+	require.NotNil(t, c.blocksCleaner)
+	assert.Equal(t, services.Terminated, c.blocksCleaner.State())
+}
+
 type ownUserReason int
 
 const (
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketcopy"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+var errInvalidReplicationConcurrency = errors.New("invalid replication concurrency, the value must be greater than 0")
+
+// ReplicationConfig holds the config for the continuous replication of tenants' blocks
+// to another object storage bucket.
+type ReplicationConfig struct {
+	Enabled         bool                   `yaml:"enabled" category:"experimental"`
+	Interval        time.Duration          `yaml:"interval" category:"experimental"`
+	Concurrency     int                    `yaml:"concurrency" category:"experimental"`
+	VerifyChecksums bool                   `yaml:"verify_checksums" category:"experimental"`
+	Tenants         flagext.StringSliceCSV `yaml:"tenants" category:"experimental"`
+	Storage         bucket.Config          `yaml:"storage"`
+}
+
+// RegisterFlags registers the ReplicationConfig flags.
+func (cfg *ReplicationConfig) RegisterFlags(f *flag.FlagSet) {
+	cfg.Storage.RegisterFlagsWithPrefix("compactor.replication.storage.", f)
+
+	f.BoolVar(&cfg.Enabled, "compactor.replication.enabled", false, "Enables the continuous replication of blocks, block markers and bucket index of the tenants owned by the compactor to the replication storage.")
+	f.DurationVar(&cfg.Interval, "compactor.replication.interval", 15*time.Minute, "How frequently compactor should replicate tenants to the replication storage.")
+	f.IntVar(&cfg.Concurrency, "compactor.replication.concurrency", 4, "Max number of tenants replicated concurrently.")
+	f.BoolVar(&cfg.VerifyChecksums, "compactor.replication.verify-checksums", false, "Read back each replicated object and verify its checksum against the source object.")
+	f.Var(&cfg.Tenants, "compactor.replication.tenants", "Comma separated list of tenants to replicate. If empty, all tenants owned by the compactor are replicated.")
+}
+
+func (cfg *ReplicationConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Concurrency < 1 {
+		return errInvalidReplicationConcurrency
+	}
+	return errors.Wrap(cfg.Storage.Validate(), "invalid replication storage config")
+}
+
+// TenantsReplicator periodically copies the blocks of the tenants owned by the compactor from the
+// blocks storage to the replication storage, using the same tenant ID.
+type TenantsReplicator struct {
+	services.Service
+
+	cfg          ReplicationConfig
+	cfgProvider  ConfigProvider
+	logger       log.Logger
+	srcBucket    objstore.Bucket
+	dstBucket    objstore.Bucket
+	usersScanner *mimir_tsdb.UsersScanner
+	ownUser      func(userID string) (bool, error)
+	tenants      map[string]bool
+
+	// Metrics.
+	runsStarted     prometheus.Counter
+	runsCompleted   prometheus.Counter
+	runsFailed      prometheus.Counter
+	runsLastSuccess prometheus.Gauge
+	blocksCopied    prometheus.Counter
+	markersCopied   prometheus.Counter
+	bytesCopied     prometheus.Counter
+}
+
+func NewTenantsReplicator(cfg ReplicationConfig, srcBucket, dstBucket objstore.Bucket, ownUser func(userID string) (bool, error), cfgProvider ConfigProvider, logger log.Logger, reg prometheus.Registerer) *TenantsReplicator {
+	r := &TenantsReplicator{
+		cfg:          cfg,
+		cfgProvider:  cfgProvider,
+		logger:       log.With(logger, "component", "replicator"),
+		srcBucket:    srcBucket,
+		dstBucket:    dstBucket,
+		usersScanner: mimir_tsdb.NewUsersScanner(srcBucket, ownUser, logger),
+		ownUser:      ownUser,
+		runsStarted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_replication_started_total",
+			Help: "Total number of tenants replication runs started.",
+		}),
+		runsCompleted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_replication_completed_total",
+			Help: "Total number of tenants replication runs successfully completed.",
+		}),
+		runsFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_replication_failed_total",
+			Help: "Total number of tenants replication runs failed.",
+		}),
+		runsLastSuccess: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_compactor_replication_last_successful_run_timestamp_seconds",
+			Help: "Unix timestamp of the last successful tenants replication run.",
+		}),
+		blocksCopied: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_replication_blocks_copied_total",
+			Help: "Total number of blocks copied to the replication storage.",
+		}),
+		markersCopied: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_replication_markers_copied_total",
+			Help: "Total number of block markers copied to the replication storage.",
+		}),
+		bytesCopied: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_replication_copied_bytes_total",
+			Help: "Total number of bytes copied to the replication storage.",
+		}),
+	}
+
+	if len(cfg.Tenants) > 0 {
+		r.tenants = util.StringsMap(cfg.Tenants)
+	}
+
+	r.Service = services.NewTimerService(cfg.Interval, nil, r.ticker, nil)
+
+	return r
+}
+
+func (r *TenantsReplicator) ticker(ctx context.Context) error {
+	level.Info(r.logger).Log("msg", "started tenants replication")
+	r.runsStarted.Inc()
+
+	if err := r.replicateUsers(ctx); err == nil {
+		level.Info(r.logger).Log("msg", "successfully completed tenants replication")
+		r.runsCompleted.Inc()
+		r.runsLastSuccess.SetToCurrentTime()
+	} else if errors.Is(err, context.Canceled) {
+		level.Info(r.logger).Log("msg", "canceled tenants replication", "err", err)
+	} else {
+		level.Error(r.logger).Log("msg", "failed to run tenants replication", "err", err.Error())
+		r.runsFailed.Inc()
+	}
+
+	// Never return an error, otherwise the service would stop.
+	return nil
+}
+
+func (r *TenantsReplicator) replicateUsers(ctx context.Context) error {
+	// Tenants marked for deletion are not replicated.
+	users, _, err := r.usersScanner.ScanUsers(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to discover users from bucket")
+	}
+
+	return concurrency.ForEachUser(ctx, users, r.cfg.Concurrency, func(ctx context.Context, userID string) error {
+		if r.tenants != nil && !r.tenants[userID] {
+			return nil
+		}
+
+		own, err := r.ownUser(userID)
+		if err != nil || !own {
+			// This returns error only if err != nil. ForEachUser keeps working for other users.
+			return errors.Wrap(err, "check own user")
+		}
+
+		return errors.Wrapf(r.replicateUser(ctx, userID), "failed to replicate user: %s", userID)
+	})
+}
+
+func (r *TenantsReplicator) replicateUser(ctx context.Context, userID string) error {
+	userLogger := util_log.WithUserID(userID, r.logger)
+	startTime := time.Now()
+
+	copier := bucketcopy.NewTenantCopier(bucketcopy.Config{
+		SourceTenant:    userID,
+		VerifyChecksums: r.cfg.VerifyChecksums,
+		CopyConcurrency: 1,
+	}, r.srcBucket, r.dstBucket, r.cfgProvider, userLogger)
+
+	stats, err := copier.CopyBlocks(ctx)
+	r.blocksCopied.Add(float64(stats.BlocksCopied))
+	r.markersCopied.Add(float64(stats.MarkersCopied))
+	r.bytesCopied.Add(float64(stats.BytesCopied))
+	if err != nil {
+		return err
+	}
+
+	level.Info(userLogger).Log("msg", "completed tenant replication", "blocks_copied", stats.BlocksCopied, "markers_copied", stats.MarkersCopied, "bytes_copied", stats.BytesCopied, "duration", time.Since(startTime))
+	return nil
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestTenantsReplicator(t *testing.T) {
+	ctx := context.Background()
+
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	srcBkt = bucketindex.BucketWithGlobalMarkers(srcBkt)
+
+	block1 := mimir_testutil.MockStorageBlock(t, srcBkt, "user-1", 10, 20)
+	block2 := mimir_testutil.MockStorageBlock(t, srcBkt, "user-2", 10, 20)
+	block3 := mimir_testutil.MockStorageBlock(t, srcBkt, "user-3", 10, 20)
+	block4 := mimir_testutil.MockStorageBlock(t, srcBkt, "user-4", 10, 20)
+	require.NoError(t, tsdb.WriteTenantDeletionMark(ctx, srcBkt, "user-4", nil, tsdb.NewTenantDeletionMark(time.Now())))
+
+	cfg := ReplicationConfig{Enabled: true, Concurrency: 2, VerifyChecksums: true, Tenants: []string{"user-1", "user-2", "user-4"}}
+	ownUser := func(userID string) (bool, error) { return userID != "user-2", nil }
+
+	reg := prometheus.NewPedanticRegistry()
+	r := NewTenantsReplicator(cfg, srcBkt, dstBkt, ownUser, newMockConfigProvider(), log.NewNopLogger(), reg)
+	require.NoError(t, r.replicateUsers(ctx))
+
+	for _, tc := range []struct {
+		userID   string
+		blockID  string
+		expected bool
+	}{
+		{userID: "user-1", blockID: block1.ULID.String(), expected: true},
+		{userID: "user-2", blockID: block2.ULID.String(), expected: false}, // Not owned.
+		{userID: "user-3", blockID: block3.ULID.String(), expected: false}, // Not in the tenants list.
+		{userID: "user-4", blockID: block4.ULID.String(), expected: false}, // Marked for deletion.
+	} {
+		exists, err := dstBkt.Exists(ctx, path.Join(tc.userID, tc.blockID, block.MetaFilename))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, exists, tc.userID)
+	}
+
+	_, err := bucketindex.ReadIndex(ctx, dstBkt, "user-1", nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_replication_blocks_copied_total Total number of blocks copied to the replication storage.
+		# TYPE cortex_compactor_replication_blocks_copied_total counter
+		cortex_compactor_replication_blocks_copied_total 1
+	`), "cortex_compactor_replication_blocks_copied_total"))
+}
+
+func TestReplicationConfig_Validate(t *testing.T) {
+	cfg := ReplicationConfig{}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Enabled = true
+	require.Equal(t, errInvalidReplicationConcurrency, cfg.Validate())
+
+	cfg.Concurrency = 1
+	require.Error(t, cfg.Validate())
+
+	cfg.Storage.Backend = "filesystem"
+	require.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+)
+
+const (
+	alertName       = "MimirContinuousTestAlwaysFiring"
+	alertLabelName  = "mimir_continuous_test"
+	alertLabelValue = "true"
+)
+
+type AlertingTestConfig struct {
+	Enabled            bool
+	EvaluationInterval time.Duration
+	MaxDeliveryDelay   time.Duration
+}
+
+func (cfg *AlertingTestConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tests.alerting-test.enabled", false, "Enable the test configuring an always firing alerting rule in the ruler and checking the alert is received by the Alertmanager. Requires -tests.ruler-endpoint and -tests.alertmanager-endpoint.")
+	f.DurationVar(&cfg.EvaluationInterval, "tests.alerting-test.evaluation-interval", time.Minute, "The evaluation interval of the alerting rule.")
+	f.DurationVar(&cfg.MaxDeliveryDelay, "tests.alerting-test.max-delivery-delay", 3*time.Minute, "The max delay between the time the alerting rule is configured and the time the alert is expected to be active in the Alertmanager.")
+}
+
+// AlertingTest configures an always firing alerting rule, and checks that the alert is active in the
+// Alertmanager, to verify the path from the ruler to the Alertmanager API.
+type AlertingTest struct {
+	name    string
+	cfg     AlertingTestConfig
+	client  MimirClient
+	logger  log.Logger
+	metrics *TestMetrics
+
+	// The time the rule group has been successfully configured, zero if not configured yet.
+	ruleGroupConfiguredAt time.Time
+}
+
+func NewAlertingTest(cfg AlertingTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) *AlertingTest {
+	const name = "alerting"
+
+	return &AlertingTest{
+		name:    name,
+		cfg:     cfg,
+		client:  client,
+		logger:  log.With(logger, "test", name),
+		metrics: NewTestMetrics(name, reg),
+	}
+}
+
+// Name implements Test.
+func (t *AlertingTest) Name() string {
+	return t.name
+}
+
+// Init implements Test.
+func (t *AlertingTest) Init() error {
+	return nil
+}
+
+// Run implements Test.
+func (t *AlertingTest) Run(ctx context.Context, now time.Time) {
+	if t.ruleGroupConfiguredAt.IsZero() {
+		group := rulefmt.RuleGroup{
+			Name:     t.name,
+			Interval: model.Duration(t.cfg.EvaluationInterval),
+			Rules: []rulefmt.RuleNode{
+				newRuleNode("", alertName, "vector(1)", map[string]string{alertLabelName: alertLabelValue}),
+			},
+		}
+
+		t.metrics.writesTotal.Inc()
+		if statusCode, err := t.client.SetRuleGroup(ctx, rulesNamespace, group); statusCode/100 != 2 {
+			t.metrics.writesFailedTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+			level.Warn(t.logger).Log("msg", "Failed to configure the rule group", "status_code", statusCode, "err", err)
+			return
+		}
+		t.ruleGroupConfiguredAt = now
+	}
+
+	// Give the ruler enough time to evaluate the rule and send the alert to the Alertmanager.
+	if now.Sub(t.ruleGroupConfiguredAt) < t.cfg.MaxDeliveryDelay {
+		level.Info(t.logger).Log("msg", "Skipped alerts check because the alert may have not been sent to the Alertmanager yet")
+		return
+	}
+
+	t.metrics.queriesTotal.Inc()
+	alerts, err := t.client.Alerts(ctx, fmt.Sprintf("alertname=%q", alertName), fmt.Sprintf("%s=%q", alertLabelName, alertLabelValue))
+	if err != nil {
+		t.metrics.queriesFailedTotal.Inc()
+		level.Warn(t.logger).Log("msg", "Failed to query alerts", "err", err)
+		return
+	}
+
+	t.metrics.queryResultChecksTotal.Inc()
+	if err := verifyAlertIsActive(alerts, alertName); err != nil {
+		t.metrics.queryResultChecksFailedTotal.Inc()
+		level.Warn(t.logger).Log("msg", "Alerts check failed", "err", err)
+	}
+}
+
+// verifyAlertIsActive checks that an active alert with the given name is among the alerts.
+func verifyAlertIsActive(alerts models.GettableAlerts, name string) error {
+	for _, a := range alerts {
+		if a.Labels["alertname"] != name || a.Status == nil || a.Status.State == nil {
+			continue
+		}
+		if *a.Status.State == models.AlertStatusStateActive {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no active alert %s found among %d alerts", name, len(alerts))
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAlertingTest_Run(t *testing.T) {
+	cfg := AlertingTestConfig{}
+	flagext.DefaultValues(&cfg)
+	start := time.Unix(1000, 0)
+
+	t.Run("should check the alert is active after the max delivery delay", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("SetRuleGroup", mock.Anything, mock.Anything, mock.Anything).Return(202, nil)
+		client.On("Alerts", mock.Anything, mock.Anything).Return(models.GettableAlerts{activeAlert(alertName)}, nil)
+
+		test := NewAlertingTest(cfg, client, log.NewNopLogger(), nil)
+		test.Run(context.Background(), start)
+		client.AssertNotCalled(t, "Alerts", mock.Anything, mock.Anything)
+
+		test.Run(context.Background(), start.Add(cfg.MaxDeliveryDelay))
+		client.AssertNumberOfCalls(t, "SetRuleGroup", 1)
+		client.AssertCalled(t, "Alerts", mock.Anything, []string{`alertname="MimirContinuousTestAlwaysFiring"`, `mimir_continuous_test="true"`})
+		assert.Equal(t, 1.0, testutil.ToFloat64(test.metrics.queryResultChecksTotal))
+		assert.Equal(t, 0.0, testutil.ToFloat64(test.metrics.queryResultChecksFailedTotal))
+	})
+
+	t.Run("should retry configuring the rule group on failure", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("SetRuleGroup", mock.Anything, mock.Anything, mock.Anything).Return(500, assert.AnError).Once()
+		client.On("SetRuleGroup", mock.Anything, mock.Anything, mock.Anything).Return(202, nil)
+
+		test := NewAlertingTest(cfg, client, log.NewNopLogger(), nil)
+		test.Run(context.Background(), start)
+		test.Run(context.Background(), start.Add(time.Minute))
+
+		client.AssertNumberOfCalls(t, "SetRuleGroup", 2)
+		assert.Equal(t, 1.0, testutil.ToFloat64(test.metrics.writesFailedTotal.WithLabelValues("500")))
+		assert.Equal(t, start.Add(time.Minute), test.ruleGroupConfiguredAt)
+	})
+}
+
+func TestVerifyAlertIsActive(t *testing.T) {
+	suppressed := activeAlert(alertName)
+	state := models.AlertStatusStateSuppressed
+	suppressed.Status.State = &state
+
+	assert.NoError(t, verifyAlertIsActive(models.GettableAlerts{suppressed, activeAlert(alertName)}, alertName))
+	assert.Error(t, verifyAlertIsActive(models.GettableAlerts{suppressed}, alertName))
+	assert.Error(t, verifyAlertIsActive(models.GettableAlerts{activeAlert("other")}, alertName))
+	assert.Error(t, verifyAlertIsActive(nil, alertName))
+}
+
+func activeAlert(name string) *models.GettableAlert {
+	state := models.AlertStatusStateActive
+	return &models.GettableAlert{
+		Alert:  models.Alert{Labels: models.LabelSet{"alertname": name}},
+		Status: &models.AlertStatus{State: &state},
+	}
+}
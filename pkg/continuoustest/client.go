@@ -5,10 +5,12 @@ package continuoustest
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/go-kit/log"
@@ -16,26 +18,94 @@ import (
 	"github.com/golang/snappy"
 	"github.com/grafana/dskit/flagext"
 	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/api/v2/models"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/prompb"
+	"gopkg.in/yaml.v3"
 
 	util_math "github.com/grafana/mimir/pkg/util/math"
 )
 
 const (
 	maxErrMsgLen = 256
+
+	// The headers used by the query-frontend to disable the results cache and query sharding for a request.
+	cacheControlHeader    = "Cache-Control"
+	noStoreValue          = "no-store"
+	shardingControlHeader = "Sharding-Control"
+	shardingDisabledValue = "0"
 )
 
+// RequestOption configures a query request.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	resultsCacheDisabled  bool
+	queryShardingDisabled bool
+}
+
+// WithResultsCacheDisabled disables the query-frontend results cache for the request.
+func WithResultsCacheDisabled() RequestOption {
+	return func(o *requestOptions) {
+		o.resultsCacheDisabled = true
+	}
+}
+
+// WithQueryShardingDisabled disables the query-frontend query sharding for the request.
+func WithQueryShardingDisabled() RequestOption {
+	return func(o *requestOptions) {
+		o.queryShardingDisabled = true
+	}
+}
+
+func newRequestOptions(options []RequestOption) requestOptions {
+	var o requestOptions
+	for _, opt := range options {
+		opt(&o)
+	}
+	return o
+}
+
+type requestOptionsKey struct{}
+
+func contextWithRequestOptions(ctx context.Context, options []RequestOption) context.Context {
+	if len(options) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requestOptionsKey{}, newRequestOptions(options))
+}
+
 // MimirClient is the interface implemented by a client used to interact with Mimir.
 type MimirClient interface {
 	// WriteSeries writes input series to Mimir. Returns the response status code and optionally
 	// an error. The error is always returned if request was not successful (eg. received a 4xx or 5xx error).
 	WriteSeries(ctx context.Context, series []prompb.TimeSeries) (statusCode int, err error)
 
+	// WriteMetadata writes input metric metadata to Mimir. Returns the response status code and optionally
+	// an error. The error is always returned if request was not successful (eg. received a 4xx or 5xx error).
+	WriteMetadata(ctx context.Context, metadata []prompb.MetricMetadata) (statusCode int, err error)
+
+	// Query performs an instant query at the given time.
+	Query(ctx context.Context, query string, ts time.Time, options ...RequestOption) (model.Vector, error)
+
 	// QueryRange performs a query for the given range.
-	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error)
+	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration, options ...RequestOption) (model.Matrix, error)
+
+	// QueryExemplars queries the exemplars of the series matching the query in the given range.
+	QueryExemplars(ctx context.Context, query string, start, end time.Time) ([]v1.ExemplarQueryResult, error)
+
+	// Metadata returns the metadata of the given metric.
+	Metadata(ctx context.Context, metric string) (map[string][]v1.Metadata, error)
+
+	// SetRuleGroup creates or replaces the rule group in the given namespace. Returns the response status
+	// code and optionally an error. The error is always returned if request was not successful.
+	SetRuleGroup(ctx context.Context, namespace string, group rulefmt.RuleGroup) (statusCode int, err error)
+
+	// Alerts returns the alerts in the Alertmanager matching the input filter, in the Alertmanager matchers format.
+	Alerts(ctx context.Context, filter ...string) (models.GettableAlerts, error)
 }
 
 type ClientConfig struct {
@@ -47,6 +117,9 @@ type ClientConfig struct {
 
 	ReadBaseEndpoint flagext.URLValue
 	ReadTimeout      time.Duration
+
+	RulerBaseEndpoint        flagext.URLValue
+	AlertmanagerBaseEndpoint flagext.URLValue
 }
 
 func (cfg *ClientConfig) RegisterFlags(f *flag.FlagSet) {
@@ -58,10 +131,14 @@ func (cfg *ClientConfig) RegisterFlags(f *flag.FlagSet) {
 
 	f.Var(&cfg.ReadBaseEndpoint, "tests.read-endpoint", "The base endpoint on the read path. The URL should have no trailing slash. The specific API path is appended by the tool to the URL, for example /api/v1/query_range for range query API, so the configured URL must not include it.")
 	f.DurationVar(&cfg.ReadTimeout, "tests.read-timeout", 30*time.Second, "The timeout for a single read request.")
+
+	f.Var(&cfg.RulerBaseEndpoint, "tests.ruler-endpoint", "The base endpoint of the ruler configuration API, including the Prometheus HTTP prefix. The URL should have no trailing slash. The specific API path is appended by the tool to the URL, for example /config/v1/rules, so the configured URL must not include it. Required by the tests using the ruler.")
+	f.Var(&cfg.AlertmanagerBaseEndpoint, "tests.alertmanager-endpoint", "The base endpoint of the Alertmanager API, including the Alertmanager HTTP prefix. The URL should have no trailing slash. The specific API path is appended by the tool to the URL, for example /api/v2/alerts, so the configured URL must not include it. Required by the tests using the Alertmanager.")
 }
 
 type Client struct {
 	writeClient *http.Client
+	httpClient  *http.Client
 	readClient  v1.API
 	cfg         ClientConfig
 	logger      log.Logger
@@ -91,15 +168,38 @@ func NewClient(cfg ClientConfig, logger log.Logger) (*Client, error) {
 
 	return &Client{
 		writeClient: &http.Client{Transport: rt},
+		httpClient:  &http.Client{Transport: rt},
 		readClient:  v1.NewAPI(readClient),
 		cfg:         cfg,
 		logger:      logger,
 	}, nil
 }
 
+// Query implements MimirClient.
+func (c *Client) Query(ctx context.Context, query string, ts time.Time, options ...RequestOption) (model.Vector, error) {
+	ctx, cancel := context.WithTimeout(contextWithRequestOptions(ctx, options), c.cfg.ReadTimeout)
+	defer cancel()
+
+	value, _, err := c.readClient.Query(ctx, query, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	if value.Type() != model.ValVector {
+		return nil, errors.New("was expecting to get a Vector")
+	}
+
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return nil, errors.New("failed to cast type to Vector")
+	}
+
+	return vector, nil
+}
+
 // QueryRange implements MimirClient.
-func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
-	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration, options ...RequestOption) (model.Matrix, error) {
+	ctx, cancel := context.WithTimeout(contextWithRequestOptions(ctx, options), c.cfg.ReadTimeout)
 	defer cancel()
 
 	value, _, err := c.readClient.QueryRange(ctx, query, v1.Range{
@@ -123,6 +223,85 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 	return matrix, nil
 }
 
+// QueryExemplars implements MimirClient.
+func (c *Client) QueryExemplars(ctx context.Context, query string, start, end time.Time) ([]v1.ExemplarQueryResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	return c.readClient.QueryExemplars(ctx, query, start, end)
+}
+
+// Metadata implements MimirClient.
+func (c *Client) Metadata(ctx context.Context, metric string) (map[string][]v1.Metadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	return c.readClient.Metadata(ctx, metric, "")
+}
+
+// SetRuleGroup implements MimirClient.
+func (c *Client) SetRuleGroup(ctx context.Context, namespace string, group rulefmt.RuleGroup) (int, error) {
+	if c.cfg.RulerBaseEndpoint.URL == nil {
+		return 0, errors.New("the ruler endpoint has not been set")
+	}
+
+	data, err := yaml.Marshal(group)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.WriteTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.RulerBaseEndpoint.String()+"/config/v1/rules/"+url.PathEscape(namespace), bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/yaml")
+	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer httpResp.Body.Close()
+
+	return httpResp.StatusCode, checkResponseStatus(httpResp)
+}
+
+// Alerts implements MimirClient.
+func (c *Client) Alerts(ctx context.Context, filter ...string) (models.GettableAlerts, error) {
+	if c.cfg.AlertmanagerBaseEndpoint.URL == nil {
+		return nil, errors.New("the Alertmanager endpoint has not been set")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	params := url.Values{"filter": filter}
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.AlertmanagerBaseEndpoint.String()+"/api/v2/alerts?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if err := checkResponseStatus(httpResp); err != nil {
+		return nil, err
+	}
+
+	var alerts models.GettableAlerts
+	if err := json.NewDecoder(httpResp.Body).Decode(&alerts); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the alerts")
+	}
+	return alerts, nil
+}
+
 // WriteSeries implements MimirClient.
 func (c *Client) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
 	lastStatusCode := 0
@@ -143,6 +322,11 @@ func (c *Client) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (i
 	return lastStatusCode, nil
 }
 
+// WriteMetadata implements MimirClient.
+func (c *Client) WriteMetadata(ctx context.Context, metadata []prompb.MetricMetadata) (int, error) {
+	return c.sendWriteRequest(ctx, &prompb.WriteRequest{Metadata: metadata})
+}
+
 func (c *Client) sendWriteRequest(ctx context.Context, req *prompb.WriteRequest) (int, error) {
 	data, err := proto.Marshal(req)
 	if err != nil {
@@ -170,16 +354,21 @@ func (c *Client) sendWriteRequest(ctx context.Context, req *prompb.WriteRequest)
 	}
 	defer httpResp.Body.Close()
 
-	if httpResp.StatusCode/100 != 2 {
-		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
-		if err != nil {
-			return httpResp.StatusCode, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
-		}
+	return httpResp.StatusCode, checkResponseStatus(httpResp)
+}
 
-		return httpResp.StatusCode, fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+// checkResponseStatus returns an error including the truncated response body if the response status is not 2xx.
+func checkResponseStatus(httpResp *http.Response) error {
+	if httpResp.StatusCode/100 == 2 {
+		return nil
 	}
 
-	return httpResp.StatusCode, nil
+	truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+	if err != nil {
+		return errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+	}
+
+	return fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
 }
 
 type clientRoundTripper struct {
@@ -187,8 +376,18 @@ type clientRoundTripper struct {
 	rt       http.RoundTripper
 }
 
-// RoundTrip add the tenant ID header required by Mimir.
+// RoundTrip add the tenant ID header required by Mimir, and the headers honoring the request options.
 func (rt *clientRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	req.Header.Set("X-Scope-OrgID", rt.tenantID)
+
+	if options, ok := req.Context().Value(requestOptionsKey{}).(requestOptions); ok {
+		if options.resultsCacheDisabled {
+			req.Header.Set(cacheControlHeader, noStoreValue)
+		}
+		if options.queryShardingDisabled {
+			req.Header.Set(shardingControlHeader, shardingDisabledValue)
+		}
+	}
+
 	return rt.rt.RoundTrip(req)
 }
@@ -14,7 +14,10 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/alertmanager/api/v2/models"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -106,6 +109,115 @@ func TestClient_WriteSeries(t *testing.T) {
 	})
 }
 
+func TestClient_SetRuleGroup(t *testing.T) {
+	var receivedPath, receivedBody string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		receivedPath = request.URL.Path
+		receivedBody = string(body)
+		assert.Equal(t, "anonymous", request.Header.Get("X-Scope-OrgID"))
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	group := rulefmt.RuleGroup{Name: "group", Rules: []rulefmt.RuleNode{newRuleNode("", "Alert", "vector(1)", nil)}}
+
+	// The ruler endpoint is required.
+	_, err = c.SetRuleGroup(context.Background(), "namespace", group)
+	require.Error(t, err)
+
+	require.NoError(t, cfg.RulerBaseEndpoint.Set(server.URL+"/prometheus"))
+	c, err = NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	statusCode, err := c.SetRuleGroup(context.Background(), "namespace", group)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, statusCode)
+	assert.Equal(t, "/prometheus/config/v1/rules/namespace", receivedPath)
+	assert.Equal(t, "name: group\nrules:\n    - alert: Alert\n      expr: vector(1)\n", receivedBody)
+}
+
+func TestClient_Query_RequestOptions(t *testing.T) {
+	tests := map[string]struct {
+		options                 []RequestOption
+		expectedCacheControl    string
+		expectedShardingControl string
+	}{
+		"no options": {},
+		"results cache disabled": {
+			options:              []RequestOption{WithResultsCacheDisabled()},
+			expectedCacheControl: "no-store",
+		},
+		"query sharding disabled": {
+			options:                 []RequestOption{WithQueryShardingDisabled()},
+			expectedShardingControl: "0",
+		},
+		"results cache and query sharding disabled": {
+			options:                 []RequestOption{WithResultsCacheDisabled(), WithQueryShardingDisabled()},
+			expectedCacheControl:    "no-store",
+			expectedShardingControl: "0",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				assert.Equal(t, "anonymous", request.Header.Get("X-Scope-OrgID"))
+				assert.Equal(t, testData.expectedCacheControl, request.Header.Get("Cache-Control"))
+				assert.Equal(t, testData.expectedShardingControl, request.Header.Get("Sharding-Control"))
+				_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger())
+			require.NoError(t, err)
+
+			_, err = c.Query(context.Background(), "sum(up)", time.Unix(1000, 0), testData.options...)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestClient_Alerts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/alertmanager/api/v2/alerts", request.URL.Path)
+		assert.Equal(t, []string{`alertname="test"`}, request.URL.Query()["filter"])
+		_, _ = writer.Write([]byte(`[{"labels":{"alertname":"test"},"status":{"state":"active","inhibitedBy":[],"silencedBy":[]}}]`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.AlertmanagerBaseEndpoint.Set(server.URL+"/alertmanager"))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	alerts, err := c.Alerts(context.Background(), `alertname="test"`)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "test", alerts[0].Labels["alertname"])
+	assert.Equal(t, models.AlertStatusStateActive, *alerts[0].Status.State)
+}
+
 // ClientMock mocks MimirClient.
 type ClientMock struct {
 	mock.Mock
@@ -116,7 +228,37 @@ func (m *ClientMock) WriteSeries(ctx context.Context, series []prompb.TimeSeries
 	return args.Int(0), args.Error(1)
 }
 
-func (m *ClientMock) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
-	args := m.Called(ctx, query, start, end, step)
+func (m *ClientMock) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration, options ...RequestOption) (model.Matrix, error) {
+	args := m.Called(ctx, query, start, end, step, newRequestOptions(options))
 	return args.Get(0).(model.Matrix), args.Error(1)
 }
+
+func (m *ClientMock) WriteMetadata(ctx context.Context, metadata []prompb.MetricMetadata) (int, error) {
+	args := m.Called(ctx, metadata)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *ClientMock) Query(ctx context.Context, query string, ts time.Time, options ...RequestOption) (model.Vector, error) {
+	args := m.Called(ctx, query, ts, newRequestOptions(options))
+	return args.Get(0).(model.Vector), args.Error(1)
+}
+
+func (m *ClientMock) QueryExemplars(ctx context.Context, query string, start, end time.Time) ([]v1.ExemplarQueryResult, error) {
+	args := m.Called(ctx, query, start, end)
+	return args.Get(0).([]v1.ExemplarQueryResult), args.Error(1)
+}
+
+func (m *ClientMock) Metadata(ctx context.Context, metric string) (map[string][]v1.Metadata, error) {
+	args := m.Called(ctx, metric)
+	return args.Get(0).(map[string][]v1.Metadata), args.Error(1)
+}
+
+func (m *ClientMock) SetRuleGroup(ctx context.Context, namespace string, group rulefmt.RuleGroup) (int, error) {
+	args := m.Called(ctx, namespace, group)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *ClientMock) Alerts(ctx context.Context, filter ...string) (models.GettableAlerts, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(models.GettableAlerts), args.Error(1)
+}
@@ -10,12 +10,14 @@ import (
 // TestMetrics holds generic metrics tracked by tests. The common metrics are used to enforce the same
 // metric names and labels to track the same information across different tests.
 type TestMetrics struct {
-	writesTotal                  prometheus.Counter
-	writesFailedTotal            *prometheus.CounterVec
-	queriesTotal                 prometheus.Counter
-	queriesFailedTotal           prometheus.Counter
-	queryResultChecksTotal       prometheus.Counter
-	queryResultChecksFailedTotal prometheus.Counter
+	writesTotal                       prometheus.Counter
+	writesFailedTotal                 *prometheus.CounterVec
+	queriesTotal                      prometheus.Counter
+	queriesFailedTotal                prometheus.Counter
+	queryResultChecksTotal            prometheus.Counter
+	queryResultChecksFailedTotal      prometheus.Counter
+	queryResultComparisonsTotal       prometheus.Counter
+	queryResultComparisonsFailedTotal prometheus.Counter
 }
 
 func NewTestMetrics(testName string, reg prometheus.Registerer) *TestMetrics {
@@ -50,5 +52,15 @@ func NewTestMetrics(testName string, reg prometheus.Registerer) *TestMetrics {
 			Help:        "Total number of query results failed when checking for correctness.",
 			ConstLabels: map[string]string{"test": testName},
 		}),
+		queryResultComparisonsTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_query_result_comparisons_total",
+			Help:        "Total number of query results compared with the results of the same query run with query sharding and results cache disabled.",
+			ConstLabels: map[string]string{"test": testName},
+		}),
+		queryResultComparisonsFailedTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_query_result_comparisons_failed_total",
+			Help:        "Total number of query results differing from the results of the same query run with query sharding and results cache disabled.",
+			ConstLabels: map[string]string{"test": testName},
+		}),
 	}
 }
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const (
+	recordingRuleInputMetricName  = "mimir_continuous_test_rule_input"
+	recordingRuleOutputMetricName = "mimir_continuous_test:rule_input:sum"
+)
+
+type RecordingRuleTestConfig struct {
+	Enabled            bool
+	NumSeries          int
+	EvaluationInterval time.Duration
+	MaxEvaluationDelay time.Duration
+}
+
+func (cfg *RecordingRuleTestConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tests.recording-rule-test.enabled", false, "Enable the test configuring a recording rule in the ruler and checking its output. Requires -tests.ruler-endpoint.")
+	f.IntVar(&cfg.NumSeries, "tests.recording-rule-test.num-series", 10, "Number of series used as input of the recording rule.")
+	f.DurationVar(&cfg.EvaluationInterval, "tests.recording-rule-test.evaluation-interval", time.Minute, "The evaluation interval of the recording rule.")
+	f.DurationVar(&cfg.MaxEvaluationDelay, "tests.recording-rule-test.max-evaluation-delay", 3*time.Minute, "The max delay between the time the input series are written and the time their value is reflected by the recording rule output.")
+}
+
+// RecordingRuleTest writes series whose value is the write timestamp, configures a recording rule summing
+// them, and checks that the recording rule output reflects recently written samples.
+type RecordingRuleTest struct {
+	name    string
+	cfg     RecordingRuleTestConfig
+	client  MimirClient
+	logger  log.Logger
+	metrics *TestMetrics
+
+	history writeHistory
+
+	// The time the rule group has been successfully configured, zero if not configured yet.
+	ruleGroupConfiguredAt time.Time
+}
+
+func NewRecordingRuleTest(cfg RecordingRuleTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) *RecordingRuleTest {
+	const name = "recording-rule"
+
+	return &RecordingRuleTest{
+		name:    name,
+		cfg:     cfg,
+		client:  client,
+		logger:  log.With(logger, "test", name),
+		metrics: NewTestMetrics(name, reg),
+	}
+}
+
+// Name implements Test.
+func (t *RecordingRuleTest) Name() string {
+	return t.name
+}
+
+// Init implements Test.
+func (t *RecordingRuleTest) Init() error {
+	return nil
+}
+
+// Run implements Test.
+func (t *RecordingRuleTest) Run(ctx context.Context, now time.Time) {
+	writeSamples(ctx, now, &t.history, t.client, t.metrics, t.logger, func(timestamp time.Time) []prompb.TimeSeries {
+		return generateTimestampSeries(recordingRuleInputMetricName, timestamp, t.cfg.NumSeries)
+	})
+
+	if t.ruleGroupConfiguredAt.IsZero() {
+		group := rulefmt.RuleGroup{
+			Name:     t.name,
+			Interval: model.Duration(t.cfg.EvaluationInterval),
+			Rules: []rulefmt.RuleNode{
+				newRuleNode(recordingRuleOutputMetricName, "", fmt.Sprintf("sum(%s)", recordingRuleInputMetricName), nil),
+			},
+		}
+
+		t.metrics.writesTotal.Inc()
+		if statusCode, err := t.client.SetRuleGroup(ctx, rulesNamespace, group); statusCode/100 != 2 {
+			t.metrics.writesFailedTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+			level.Warn(t.logger).Log("msg", "Failed to configure the rule group", "status_code", statusCode, "err", err)
+			return
+		}
+		t.ruleGroupConfiguredAt = now
+	}
+
+	// Give the ruler enough time to evaluate the rule on the written samples before checking its output.
+	if t.history.queryMinTime.IsZero() || now.Sub(maxTime(t.history.queryMinTime, t.ruleGroupConfiguredAt)) < t.cfg.MaxEvaluationDelay {
+		level.Info(t.logger).Log("msg", "Skipped recording rule output check because the rule may have not been evaluated yet on the written samples")
+		return
+	}
+
+	logger := log.With(t.logger, "query", recordingRuleOutputMetricName, "time", now.UnixMilli())
+	level.Debug(logger).Log("msg", "Running instant query")
+
+	t.metrics.queriesTotal.Inc()
+	vector, err := t.client.Query(ctx, recordingRuleOutputMetricName, now)
+	if err != nil {
+		t.metrics.queriesFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
+		return
+	}
+
+	t.metrics.queryResultChecksTotal.Inc()
+	if err := verifyRecordingRuleOutput(vector, t.cfg.NumSeries, now, t.cfg.MaxEvaluationDelay); err != nil {
+		t.metrics.queryResultChecksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Recording rule output check failed", "err", err)
+	}
+}
+
+// generateTimestampSeries generates series whose sample value is the sample timestamp, in seconds.
+func generateTimestampSeries(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+	out := make([]prompb.TimeSeries, 0, numSeries)
+
+	for i := 0; i < numSeries; i++ {
+		out = append(out, prompb.TimeSeries{
+			Labels: []prompb.Label{{
+				Name:  "__name__",
+				Value: name,
+			}, {
+				Name:  "series_id",
+				Value: strconv.Itoa(i),
+			}},
+			Samples: []prompb.Sample{{
+				Value:     float64(t.Unix()),
+				Timestamp: t.UnixMilli(),
+			}},
+		})
+	}
+
+	return out
+}
+
+// verifyRecordingRuleOutput checks that the recording rule output is the sum of expectedSeries series written
+// by generateTimestampSeries, at a timestamp not older than maxDelay.
+func verifyRecordingRuleOutput(vector model.Vector, expectedSeries int, now time.Time, maxDelay time.Duration) error {
+	if len(vector) != 1 {
+		return fmt.Errorf("expected 1 series in the result but got %d", len(vector))
+	}
+
+	// Since all series have the same value, the sum divided by the number of series is the write timestamp.
+	value := float64(vector[0].Value) / float64(expectedSeries)
+	writtenAt := math.Round(value)
+	if math.Abs(value-writtenAt) > maxComparisonDelta || int64(writtenAt)%int64(writeInterval.Seconds()) != 0 {
+		return fmt.Errorf("recording rule output has value %f which is not the sum of %d series written at the same timestamp", vector[0].Value, expectedSeries)
+	}
+
+	delay := now.Sub(time.Unix(int64(writtenAt), 0))
+	if delay < 0 || delay > maxDelay {
+		return fmt.Errorf("recording rule output reflects samples written at %s, which is %s before the query time while the max allowed delay is %s", time.Unix(int64(writtenAt), 0).UTC().String(), delay, maxDelay)
+	}
+
+	return nil
+}
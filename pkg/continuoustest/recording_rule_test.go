@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingRuleTest_Run(t *testing.T) {
+	cfg := RecordingRuleTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	start := time.Unix(1000, 0)
+
+	client := &ClientMock{}
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	client.On("SetRuleGroup", mock.Anything, mock.Anything, mock.Anything).Return(202, nil)
+
+	test := NewRecordingRuleTest(cfg, client, log.NewNopLogger(), nil)
+
+	// The rule output is not checked until the rule has had the time to be evaluated.
+	test.Run(context.Background(), start)
+	client.AssertCalled(t, "WriteSeries", mock.Anything, generateTimestampSeries(recordingRuleInputMetricName, start, 2))
+	client.AssertNumberOfCalls(t, "SetRuleGroup", 1)
+	client.AssertNotCalled(t, "Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	group := client.Calls[1].Arguments.Get(2).(rulefmt.RuleGroup)
+	assert.Equal(t, rulesNamespace, client.Calls[1].Arguments.Get(1))
+	assert.Equal(t, "recording-rule", group.Name)
+	require.Len(t, group.Rules, 1)
+	assert.Equal(t, recordingRuleOutputMetricName, group.Rules[0].Record.Value)
+	assert.Equal(t, "sum(mimir_continuous_test_rule_input)", group.Rules[0].Expr.Value)
+
+	// The rule output reflects samples written 1 minute before the query time.
+	now := start.Add(cfg.MaxEvaluationDelay)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{
+		{Metric: model.Metric{"__name__": recordingRuleOutputMetricName}, Value: model.SampleValue(2 * now.Add(-time.Minute).Unix())},
+	}, nil).Once()
+	test.Run(context.Background(), now)
+
+	client.AssertNumberOfCalls(t, "SetRuleGroup", 1)
+	client.AssertCalled(t, "Query", mock.Anything, recordingRuleOutputMetricName, now, requestOptions{})
+	assert.Equal(t, 1.0, testutil.ToFloat64(test.metrics.queryResultChecksTotal))
+	assert.Equal(t, 0.0, testutil.ToFloat64(test.metrics.queryResultChecksFailedTotal))
+}
+
+func TestVerifyRecordingRuleOutput(t *testing.T) {
+	now := time.Unix(10000, 0)
+	output := func(v float64) model.Vector {
+		return model.Vector{{Metric: model.Metric{"__name__": recordingRuleOutputMetricName}, Value: model.SampleValue(v)}}
+	}
+
+	assert.NoError(t, verifyRecordingRuleOutput(output(3*9980), 3, now, time.Minute))
+	assert.Error(t, verifyRecordingRuleOutput(model.Vector{}, 3, now, time.Minute))
+	assert.Error(t, verifyRecordingRuleOutput(output(2*9980), 3, now, time.Minute), "sum of a different number of series")
+	assert.Error(t, verifyRecordingRuleOutput(output(3*9990), 3, now, time.Minute), "timestamp not aligned to the write interval")
+	assert.Error(t, verifyRecordingRuleOutput(output(3*9900), 3, now, time.Minute), "too old")
+	assert.Error(t, verifyRecordingRuleOutput(output(3*10020), 3, now, time.Minute), "in the future")
+}
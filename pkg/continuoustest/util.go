@@ -10,11 +10,15 @@ import (
 	"time"
 
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/prompb"
 )
 
 const (
 	maxComparisonDelta = 0.001
+
+	// rulesNamespace is the namespace of the rule groups configured by the tests.
+	rulesNamespace = "mimir-continuous-test"
 )
 
 func alignTimestampToInterval(ts time.Time, interval time.Duration) time.Time {
@@ -103,6 +107,79 @@ func verifySineWaveSamplesSum(matrix model.Matrix, expectedSeries int, expectedS
 	return nil
 }
 
+// verifySineWaveSampleSum assumes the input vector is the result of an instant query summing the values
+// of expectedSeries sine wave series at ts and checks whether the actual value matches the expected one.
+// Returns error if values don't match.
+func verifySineWaveSampleSum(vector model.Vector, expectedSeries int, ts time.Time) error {
+	if len(vector) != 1 {
+		return fmt.Errorf("expected 1 series in the result but got %d", len(vector))
+	}
+
+	sample := vector[0]
+	if sample.Timestamp != model.TimeFromUnixNano(ts.UnixNano()) {
+		return fmt.Errorf("sample has timestamp %d while was expecting %d", sample.Timestamp, ts.UnixMilli())
+	}
+
+	expectedValue := generateSineWaveValue(ts) * float64(expectedSeries)
+	if !compareSampleValues(float64(sample.Value), expectedValue) {
+		return fmt.Errorf("sample at timestamp %d (%s) has value %f while was expecting %f", sample.Timestamp, ts.UTC().String(), sample.Value, expectedValue)
+	}
+
+	return nil
+}
+
+// compareMatrices checks whether the actual range query result matches the expected one,
+// allowing for floating point differences in the sample values.
+func compareMatrices(expected, actual model.Matrix) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("expected %d series in the result but got %d", len(expected), len(actual))
+	}
+
+	for i := range expected {
+		if !expected[i].Metric.Equal(actual[i].Metric) {
+			return fmt.Errorf("expected series %s but got %s", expected[i].Metric, actual[i].Metric)
+		}
+		if len(expected[i].Values) != len(actual[i].Values) {
+			return fmt.Errorf("expected %d samples for series %s but got %d", len(expected[i].Values), expected[i].Metric, len(actual[i].Values))
+		}
+
+		for j, e := range expected[i].Values {
+			a := actual[i].Values[j]
+			if e.Timestamp != a.Timestamp {
+				return fmt.Errorf("sample %d of series %s has timestamp %d while was expecting %d", j, expected[i].Metric, a.Timestamp, e.Timestamp)
+			}
+			if !compareSampleValues(float64(a.Value), float64(e.Value)) {
+				return fmt.Errorf("sample at timestamp %d of series %s has value %f while was expecting %f", a.Timestamp, expected[i].Metric, a.Value, e.Value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// compareVectors checks whether the actual instant query result matches the expected one,
+// allowing for floating point differences in the sample values.
+func compareVectors(expected, actual model.Vector) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("expected %d series in the result but got %d", len(expected), len(actual))
+	}
+
+	for i, e := range expected {
+		a := actual[i]
+		if !e.Metric.Equal(a.Metric) {
+			return fmt.Errorf("expected series %s but got %s", e.Metric, a.Metric)
+		}
+		if e.Timestamp != a.Timestamp {
+			return fmt.Errorf("sample of series %s has timestamp %d while was expecting %d", e.Metric, a.Timestamp, e.Timestamp)
+		}
+		if !compareSampleValues(float64(a.Value), float64(e.Value)) {
+			return fmt.Errorf("sample of series %s has value %f while was expecting %f", e.Metric, a.Value, e.Value)
+		}
+	}
+
+	return nil
+}
+
 func compareSampleValues(actual, expected float64) bool {
 	delta := math.Abs((actual - expected) / maxComparisonDelta)
 	return delta < maxComparisonDelta
@@ -131,3 +208,15 @@ func randTime(min, max time.Time) time.Time {
 	sec := rand.Int63n(delta) + min.Unix()
 	return time.Unix(sec, 0)
 }
+
+// newRuleNode returns a rule to configure in a rule group. If record is empty, an alerting rule is returned.
+func newRuleNode(record, alert, expr string, labels map[string]string) rulefmt.RuleNode {
+	node := rulefmt.RuleNode{Labels: labels}
+	node.Expr.SetString(expr)
+	if record != "" {
+		node.Record.SetString(record)
+	} else {
+		node.Alert.SetString(alert)
+	}
+	return node
+}
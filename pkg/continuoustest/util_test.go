@@ -112,6 +112,128 @@ func TestVerifySineWaveSamplesSums(t *testing.T) {
 	}
 }
 
+func TestVerifySineWaveSampleSum(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli()).UTC()
+
+	tests := map[string]struct {
+		vector         model.Vector
+		expectedSeries int
+		expectedErr    string
+	}{
+		"should return no error if the sample value and timestamp match the expected one": {
+			vector:         model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(5 * generateSineWaveValue(now))}},
+			expectedSeries: 5,
+		},
+		"should return error if there's a missing series": {
+			vector:         model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(4 * generateSineWaveValue(now))}},
+			expectedSeries: 5,
+			expectedErr:    "sample at timestamp .* has value .* while was expecting .*",
+		},
+		"should return error if the sample timestamp doesn't match": {
+			vector:         model.Vector{{Timestamp: model.Time(now.Add(time.Second).UnixMilli()), Value: model.SampleValue(5 * generateSineWaveValue(now))}},
+			expectedSeries: 5,
+			expectedErr:    "sample has timestamp .* while was expecting .*",
+		},
+		"should return error if the result is empty": {
+			vector:         model.Vector{},
+			expectedSeries: 5,
+			expectedErr:    "expected 1 series in the result but got 0",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual := verifySineWaveSampleSum(testData.vector, testData.expectedSeries, now)
+			if testData.expectedErr == "" {
+				assert.NoError(t, actual)
+			} else {
+				assert.Error(t, actual)
+				assert.Regexp(t, testData.expectedErr, actual.Error())
+			}
+		})
+	}
+}
+
+func TestCompareMatrices(t *testing.T) {
+	metric := model.Metric{"series_id": "1"}
+	expected := model.Matrix{{Metric: metric, Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}}}}
+
+	tests := map[string]struct {
+		actual      model.Matrix
+		expectedErr string
+	}{
+		"should return no error if results match": {
+			actual: model.Matrix{{Metric: metric, Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2.0000000001}}}},
+		},
+		"should return error if a series is missing": {
+			actual:      model.Matrix{},
+			expectedErr: "expected 1 series in the result but got 0",
+		},
+		"should return error if a series has different labels": {
+			actual:      model.Matrix{{Metric: model.Metric{"series_id": "2"}, Values: expected[0].Values}},
+			expectedErr: "expected series .* but got .*",
+		},
+		"should return error if a sample is missing": {
+			actual:      model.Matrix{{Metric: metric, Values: []model.SamplePair{{Timestamp: 1000, Value: 1}}}},
+			expectedErr: "expected 2 samples for series .* but got 1",
+		},
+		"should return error if a sample has a different value": {
+			actual:      model.Matrix{{Metric: metric, Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 3}}}},
+			expectedErr: "sample at timestamp 2000 of series .* has value .* while was expecting .*",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual := compareMatrices(expected, testData.actual)
+			if testData.expectedErr == "" {
+				assert.NoError(t, actual)
+			} else {
+				assert.Error(t, actual)
+				assert.Regexp(t, testData.expectedErr, actual.Error())
+			}
+		})
+	}
+}
+
+func TestCompareVectors(t *testing.T) {
+	metric := model.Metric{"series_id": "1"}
+	expected := model.Vector{{Metric: metric, Timestamp: 1000, Value: 1}}
+
+	tests := map[string]struct {
+		actual      model.Vector
+		expectedErr string
+	}{
+		"should return no error if results match": {
+			actual: model.Vector{{Metric: metric, Timestamp: 1000, Value: 1.0000000001}},
+		},
+		"should return error if a series is missing": {
+			actual:      model.Vector{},
+			expectedErr: "expected 1 series in the result but got 0",
+		},
+		"should return error if a sample has a different timestamp": {
+			actual:      model.Vector{{Metric: metric, Timestamp: 2000, Value: 1}},
+			expectedErr: "sample of series .* has timestamp 2000 while was expecting 1000",
+		},
+		"should return error if a sample has a different value": {
+			actual:      model.Vector{{Metric: metric, Timestamp: 1000, Value: 2}},
+			expectedErr: "sample of series .* has value .* while was expecting .*",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual := compareVectors(expected, testData.actual)
+			if testData.expectedErr == "" {
+				assert.NoError(t, actual)
+			} else {
+				assert.Error(t, actual)
+				assert.Regexp(t, testData.expectedErr, actual.Error())
+			}
+		})
+	}
+}
+
 func TestMinTime(t *testing.T) {
 	first := time.Now()
 	second := first.Add(time.Second)
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const (
+	exemplarsMetricName = "mimir_continuous_test_exemplars"
+	exemplarTraceIDName = "trace_id"
+)
+
+type WriteReadExemplarsTestConfig struct {
+	Enabled     bool
+	NumSeries   int
+	MaxQueryAge time.Duration
+}
+
+func (cfg *WriteReadExemplarsTestConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tests.write-read-exemplars-test.enabled", false, "Enable the test writing exemplars and querying them back. The tenant must be allowed to store at least num-series * max-query-age / 20s exemplars.")
+	f.IntVar(&cfg.NumSeries, "tests.write-read-exemplars-test.num-series", 10, "Number of series with exemplars used for the test.")
+	f.DurationVar(&cfg.MaxQueryAge, "tests.write-read-exemplars-test.max-query-age", 5*time.Minute, "How back in the past exemplars can be queried at most. Exemplars are not stored in the long-term storage, so this should be shorter than the time exemplars are kept in the ingesters.")
+}
+
+// WriteReadExemplarsTest writes series with an exemplar for each sample, and checks that
+// the exemplars are returned by the exemplars query API.
+type WriteReadExemplarsTest struct {
+	name    string
+	cfg     WriteReadExemplarsTestConfig
+	client  MimirClient
+	logger  log.Logger
+	metrics *TestMetrics
+
+	history writeHistory
+}
+
+func NewWriteReadExemplarsTest(cfg WriteReadExemplarsTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) *WriteReadExemplarsTest {
+	const name = "write-read-exemplars"
+
+	return &WriteReadExemplarsTest{
+		name:    name,
+		cfg:     cfg,
+		client:  client,
+		logger:  log.With(logger, "test", name),
+		metrics: NewTestMetrics(name, reg),
+	}
+}
+
+// Name implements Test.
+func (t *WriteReadExemplarsTest) Name() string {
+	return t.name
+}
+
+// Init implements Test.
+func (t *WriteReadExemplarsTest) Init() error {
+	return nil
+}
+
+// Run implements Test.
+func (t *WriteReadExemplarsTest) Run(ctx context.Context, now time.Time) {
+	writeSamples(ctx, now, &t.history, t.client, t.metrics, t.logger, func(timestamp time.Time) []prompb.TimeSeries {
+		return generateSineWaveSeriesWithExemplars(exemplarsMetricName, timestamp, t.cfg.NumSeries)
+	})
+
+	// The min and max allowed query timestamps are zero if there's no successfully written data yet.
+	if t.history.queryMinTime.IsZero() || t.history.queryMaxTime.IsZero() {
+		level.Info(t.logger).Log("msg", "Skipped exemplars query because there's no valid time range to query")
+		return
+	}
+
+	start := maxTime(t.history.queryMinTime, alignTimestampToInterval(now.Add(-t.cfg.MaxQueryAge), writeInterval).Add(writeInterval))
+	end := t.history.queryMaxTime
+	if end.Before(start) {
+		level.Info(t.logger).Log("msg", "Skipped exemplars query because there's no valid time range to query after honoring configured max query age")
+		return
+	}
+
+	logger := log.With(t.logger, "query", exemplarsMetricName, "start", start.UnixMilli(), "end", end.UnixMilli())
+	level.Debug(logger).Log("msg", "Running exemplars query")
+
+	t.metrics.queriesTotal.Inc()
+	results, err := t.client.QueryExemplars(ctx, exemplarsMetricName, start, end)
+	if err != nil {
+		t.metrics.queriesFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to execute exemplars query", "err", err)
+		return
+	}
+
+	t.metrics.queryResultChecksTotal.Inc()
+	if err := verifySineWaveExemplars(results, t.cfg.NumSeries, start, end); err != nil {
+		t.metrics.queryResultChecksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Exemplars query result check failed", "err", err)
+	}
+}
+
+// generateSineWaveSeriesWithExemplars generates sine wave series, each sample having an exemplar with the same
+// value and a trace ID set to the sample timestamp.
+func generateSineWaveSeriesWithExemplars(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+	series := generateSineWaveSeries(name, t, numSeries)
+	for i := range series {
+		series[i].Exemplars = []prompb.Exemplar{{
+			Labels:    []prompb.Label{{Name: exemplarTraceIDName, Value: strconv.FormatInt(t.UnixMilli(), 10)}},
+			Value:     series[i].Samples[0].Value,
+			Timestamp: t.UnixMilli(),
+		}}
+	}
+	return series
+}
+
+// verifySineWaveExemplars checks that the results contain the expected number of series, each one having
+// an exemplar for each write interval between start and end, both included.
+func verifySineWaveExemplars(results []v1.ExemplarQueryResult, expectedSeries int, start, end time.Time) error {
+	if len(results) != expectedSeries {
+		return fmt.Errorf("expected %d series in the result but got %d", expectedSeries, len(results))
+	}
+
+	expectedExemplars := int(end.Sub(start)/writeInterval) + 1
+
+	for _, res := range results {
+		if len(res.Exemplars) != expectedExemplars {
+			return fmt.Errorf("expected %d exemplars for series %s but got %d", expectedExemplars, res.SeriesLabels, len(res.Exemplars))
+		}
+
+		for idx, e := range res.Exemplars {
+			ts := start.Add(time.Duration(idx) * writeInterval)
+			if e.Timestamp != model.TimeFromUnixNano(ts.UnixNano()) {
+				return fmt.Errorf("exemplar %d of series %s has timestamp %d while was expecting %d", idx, res.SeriesLabels, e.Timestamp, ts.UnixMilli())
+			}
+
+			expectedTraceID := strconv.FormatInt(ts.UnixMilli(), 10)
+			if traceID := e.Labels[exemplarTraceIDName]; string(traceID) != expectedTraceID {
+				return fmt.Errorf("exemplar at timestamp %d of series %s has trace ID %q while was expecting %q", e.Timestamp, res.SeriesLabels, traceID, expectedTraceID)
+			}
+
+			if expectedValue := generateSineWaveValue(ts); !compareSampleValues(float64(e.Value), expectedValue) {
+				return fmt.Errorf("exemplar at timestamp %d of series %s has value %f while was expecting %f", e.Timestamp, res.SeriesLabels, e.Value, expectedValue)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteReadExemplarsTest_Run(t *testing.T) {
+	cfg := WriteReadExemplarsTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	t.Run("should write series with exemplars and query them back", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryExemplars", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(exemplarsResult(2, now, now), nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test := NewWriteReadExemplarsTest(cfg, client, log.NewNopLogger(), reg)
+		test.Run(context.Background(), now)
+
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeriesWithExemplars(exemplarsMetricName, now, 2))
+		client.AssertCalled(t, "QueryExemplars", mock.Anything, exemplarsMetricName, now, now)
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_total counter
+			mimir_continuous_test_query_result_checks_total{test="write-read-exemplars"} 1
+
+			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+			mimir_continuous_test_query_result_checks_failed_total{test="write-read-exemplars"} 0
+		`), "mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total"))
+	})
+
+	t.Run("should honor the max query age", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryExemplars", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]v1.ExemplarQueryResult{}, nil)
+
+		test := NewWriteReadExemplarsTest(cfg, client, log.NewNopLogger(), nil)
+		test.Run(context.Background(), time.Unix(1000, 0))
+		test.Run(context.Background(), time.Unix(1000, 0).Add(time.Hour))
+
+		client.AssertCalled(t, "QueryExemplars", mock.Anything, exemplarsMetricName, time.Unix(1000, 0).Add(time.Hour-cfg.MaxQueryAge+writeInterval), time.Unix(1000, 0).Add(time.Hour))
+	})
+}
+
+func TestVerifySineWaveExemplars(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := start.Add(2 * writeInterval)
+
+	assert.NoError(t, verifySineWaveExemplars(exemplarsResult(2, start, end), 2, start, end))
+	assert.EqualError(t, verifySineWaveExemplars(exemplarsResult(1, start, end), 2, start, end), "expected 2 series in the result but got 1")
+	assert.EqualError(t, verifySineWaveExemplars(exemplarsResult(2, start.Add(writeInterval), end), 2, start, end), `expected 3 exemplars for series {__name__="mimir_continuous_test_exemplars", series_id="0"} but got 2`)
+
+	results := exemplarsResult(2, start, end)
+	results[1].Exemplars[1].Value = 100
+	require.Error(t, verifySineWaveExemplars(results, 2, start, end))
+}
+
+func exemplarsResult(numSeries int, start, end time.Time) []v1.ExemplarQueryResult {
+	var results []v1.ExemplarQueryResult
+	for i := 0; i < numSeries; i++ {
+		results = append(results, v1.ExemplarQueryResult{SeriesLabels: model.LabelSet{"__name__": exemplarsMetricName, "series_id": model.LabelValue(fmt.Sprint(i))}})
+	}
+
+	for ts := start; !ts.After(end); ts = ts.Add(writeInterval) {
+		for i, s := range generateSineWaveSeriesWithExemplars(exemplarsMetricName, ts, numSeries) {
+			e := s.Exemplars[0]
+			results[i].Exemplars = append(results[i].Exemplars, v1.Exemplar{
+				Labels:    model.LabelSet{model.LabelName(e.Labels[0].Name): model.LabelValue(e.Labels[0].Value)},
+				Value:     model.SampleValue(e.Value),
+				Timestamp: model.Time(e.Timestamp),
+			})
+		}
+	}
+	return results
+}
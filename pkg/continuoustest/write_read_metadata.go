@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const metadataMetricName = "mimir_continuous_test_metadata"
+
+type WriteReadMetadataTestConfig struct {
+	Enabled bool
+}
+
+func (cfg *WriteReadMetadataTestConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tests.write-read-metadata-test.enabled", false, "Enable the test writing metric metadata and querying it back.")
+}
+
+// WriteReadMetadataTest writes the metadata of a metric and checks that it's returned by the metadata API.
+// The help of the metadata includes the write timestamp, so that each run checks freshly written metadata.
+type WriteReadMetadataTest struct {
+	name    string
+	cfg     WriteReadMetadataTestConfig
+	client  MimirClient
+	logger  log.Logger
+	metrics *TestMetrics
+}
+
+func NewWriteReadMetadataTest(cfg WriteReadMetadataTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) *WriteReadMetadataTest {
+	const name = "write-read-metadata"
+
+	return &WriteReadMetadataTest{
+		name:    name,
+		cfg:     cfg,
+		client:  client,
+		logger:  log.With(logger, "test", name),
+		metrics: NewTestMetrics(name, reg),
+	}
+}
+
+// Name implements Test.
+func (t *WriteReadMetadataTest) Name() string {
+	return t.name
+}
+
+// Init implements Test.
+func (t *WriteReadMetadataTest) Init() error {
+	return nil
+}
+
+// Run implements Test.
+func (t *WriteReadMetadataTest) Run(ctx context.Context, now time.Time) {
+	metadata := generateMetadata(metadataMetricName, now)
+
+	t.metrics.writesTotal.Inc()
+	statusCode, err := t.client.WriteMetadata(ctx, []prompb.MetricMetadata{metadata})
+	if statusCode/100 != 2 {
+		t.metrics.writesFailedTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+		level.Warn(t.logger).Log("msg", "Failed to remote write metadata", "status_code", statusCode, "err", err)
+		return
+	}
+
+	t.metrics.queriesTotal.Inc()
+	result, err := t.client.Metadata(ctx, metadataMetricName)
+	if err != nil {
+		t.metrics.queriesFailedTotal.Inc()
+		level.Warn(t.logger).Log("msg", "Failed to query metadata", "err", err)
+		return
+	}
+
+	t.metrics.queryResultChecksTotal.Inc()
+	if err := verifyMetadata(result, metadata); err != nil {
+		t.metrics.queryResultChecksFailedTotal.Inc()
+		level.Warn(t.logger).Log("msg", "Metadata query result check failed", "err", err)
+	}
+}
+
+func generateMetadata(name string, t time.Time) prompb.MetricMetadata {
+	return prompb.MetricMetadata{
+		MetricFamilyName: name,
+		Type:             prompb.MetricMetadata_GAUGE,
+		Help:             fmt.Sprintf("Metric written by the Mimir continuous test at %d.", t.UnixMilli()),
+	}
+}
+
+// verifyMetadata checks that the expected metadata is among the metadata returned for its metric.
+func verifyMetadata(result map[string][]v1.Metadata, expected prompb.MetricMetadata) error {
+	actual, ok := result[expected.MetricFamilyName]
+	if !ok {
+		return fmt.Errorf("metadata of metric %s is missing from the result", expected.MetricFamilyName)
+	}
+
+	for _, m := range actual {
+		if m.Help != expected.Help {
+			continue
+		}
+		if m.Type != v1.MetricTypeGauge {
+			return fmt.Errorf("metadata of metric %s has type %q while was expecting %q", expected.MetricFamilyName, m.Type, v1.MetricTypeGauge)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("metadata of metric %s with help %q is missing from the result", expected.MetricFamilyName, expected.Help)
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWriteReadMetadataTest_Run(t *testing.T) {
+	now := time.Unix(1000, 0)
+	expected := generateMetadata(metadataMetricName, now)
+
+	t.Run("should write metadata and query it back", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteMetadata", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Metadata", mock.Anything, mock.Anything).Return(map[string][]v1.Metadata{
+			metadataMetricName: {
+				{Type: v1.MetricTypeGauge, Help: "previous"},
+				{Type: v1.MetricTypeGauge, Help: expected.Help},
+			},
+		}, nil)
+
+		test := NewWriteReadMetadataTest(WriteReadMetadataTestConfig{}, client, log.NewNopLogger(), nil)
+		test.Run(context.Background(), now)
+
+		client.AssertCalled(t, "WriteMetadata", mock.Anything, []prompb.MetricMetadata{expected})
+		client.AssertCalled(t, "Metadata", mock.Anything, metadataMetricName)
+		assert.Equal(t, 1.0, testutil.ToFloat64(test.metrics.queryResultChecksTotal))
+		assert.Equal(t, 0.0, testutil.ToFloat64(test.metrics.queryResultChecksFailedTotal))
+	})
+
+	t.Run("should not query metadata if the write failed", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteMetadata", mock.Anything, mock.Anything).Return(500, errors.New("failed"))
+
+		test := NewWriteReadMetadataTest(WriteReadMetadataTestConfig{}, client, log.NewNopLogger(), nil)
+		test.Run(context.Background(), now)
+
+		client.AssertNotCalled(t, "Metadata", mock.Anything, mock.Anything)
+		assert.Equal(t, 1.0, testutil.ToFloat64(test.metrics.writesFailedTotal.WithLabelValues("500")))
+	})
+
+	t.Run("should fail the check if the written metadata is missing", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteMetadata", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Metadata", mock.Anything, mock.Anything).Return(map[string][]v1.Metadata{
+			metadataMetricName: {{Type: v1.MetricTypeGauge, Help: "previous"}},
+		}, nil)
+
+		test := NewWriteReadMetadataTest(WriteReadMetadataTestConfig{}, client, log.NewNopLogger(), nil)
+		test.Run(context.Background(), now)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(test.metrics.queryResultChecksFailedTotal))
+	})
+}
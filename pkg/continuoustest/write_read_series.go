@@ -19,14 +19,29 @@ const (
 	metricName    = "mimir_continuous_test_sine_wave"
 )
 
+// queryShapes are the queries run by the WriteReadSeriesTest. Since all the sine wave series have the same value,
+// each query is expected to return the sum of the series. The list mixes shardable and non-shardable queries.
+var queryShapes = []string{
+	fmt.Sprintf("sum(%s)", metricName),
+	fmt.Sprintf("sum(max by (series_id) (%s))", metricName),
+	fmt.Sprintf("avg(%[1]s) * count(%[1]s)", metricName),
+	fmt.Sprintf("quantile(0.5, %[1]s) * count(%[1]s)", metricName),
+}
+
 type WriteReadSeriesTestConfig struct {
-	NumSeries   int
-	MaxQueryAge time.Duration
+	NumSeries                int
+	MaxQueryAge              time.Duration
+	AdditionalQueriesEnabled bool
+	InstantQueriesEnabled    bool
+	QueryComparisonEnabled   bool
 }
 
 func (cfg *WriteReadSeriesTestConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.NumSeries, "tests.write-read-series-test.num-series", 10000, "Number of series used for the test.")
 	f.DurationVar(&cfg.MaxQueryAge, "tests.write-read-series-test.max-query-age", 7*24*time.Hour, "How back in the past metrics can be queried at most.")
+	f.BoolVar(&cfg.AdditionalQueriesEnabled, "tests.write-read-series-test.additional-queries-enabled", true, "Run shardable and non-shardable queries other than sum() on the written series, and check their results.")
+	f.BoolVar(&cfg.InstantQueriesEnabled, "tests.write-read-series-test.instant-queries-enabled", true, "Run instant queries, in addition to range queries, on the written series and check their results.")
+	f.BoolVar(&cfg.QueryComparisonEnabled, "tests.write-read-series-test.query-comparison-enabled", true, "Run each query a second time with query sharding and results cache disabled, and check both runs return the same results.")
 }
 
 type WriteReadSeriesTest struct {
@@ -103,24 +118,50 @@ func (t *WriteReadSeriesTest) Run(ctx context.Context, now time.Time) {
 		}
 	}
 
+	queries := queryShapes[:1]
+	if t.cfg.AdditionalQueriesEnabled {
+		queries = queryShapes
+	}
+
 	for _, timeRange := range t.getRangeQueryTimeRanges(now) {
-		t.runRangeQueryAndVerifyResult(ctx, timeRange[0], timeRange[1])
+		for _, query := range queries {
+			t.runRangeQueryAndVerifyResult(ctx, query, timeRange[0], timeRange[1])
+		}
+	}
+
+	if t.cfg.InstantQueriesEnabled {
+		for _, ts := range t.getInstantQueryTimes(now) {
+			for _, query := range queries {
+				t.runInstantQueryAndVerifyResult(ctx, query, ts)
+			}
+		}
 	}
 }
 
-// getRangeQueryTimeRanges returns the start/end time ranges to use to run test range queries.
-func (t *WriteReadSeriesTest) getRangeQueryTimeRanges(now time.Time) (ranges [][2]time.Time) {
+// getAdjustedQueryMinTime returns the min time which can be queried after honoring the configured max age,
+// or zero if there's no valid time range to query.
+func (t *WriteReadSeriesTest) getAdjustedQueryMinTime(now time.Time) time.Time {
 	// The min and max allowed query timestamps are zero if there's no successfully written data yet.
 	if t.queryMinTime.IsZero() || t.queryMaxTime.IsZero() {
-		level.Info(t.logger).Log("msg", "Skipped range queries because there's no valid time range to query")
-		return nil
+		level.Info(t.logger).Log("msg", "Skipped queries because there's no valid time range to query")
+		return time.Time{}
 	}
 
 	// Honor the configured max age.
 	adjustedQueryMinTime := maxTime(t.queryMinTime, now.Add(-t.cfg.MaxQueryAge))
 	if t.queryMaxTime.Before(adjustedQueryMinTime) {
-		level.Info(t.logger).Log("msg", "Skipped range queries because there's no valid time range to query after honoring configured max query age", "min_valid_time", t.queryMinTime, "max_valid_time", t.queryMaxTime, "max_query_age", t.cfg.MaxQueryAge)
-		return
+		level.Info(t.logger).Log("msg", "Skipped queries because there's no valid time range to query after honoring configured max query age", "min_valid_time", t.queryMinTime, "max_valid_time", t.queryMaxTime, "max_query_age", t.cfg.MaxQueryAge)
+		return time.Time{}
+	}
+
+	return adjustedQueryMinTime
+}
+
+// getRangeQueryTimeRanges returns the start/end time ranges to use to run test range queries.
+func (t *WriteReadSeriesTest) getRangeQueryTimeRanges(now time.Time) (ranges [][2]time.Time) {
+	adjustedQueryMinTime := t.getAdjustedQueryMinTime(now)
+	if adjustedQueryMinTime.IsZero() {
+		return nil
 	}
 
 	// Last 1h.
@@ -154,7 +195,27 @@ func (t *WriteReadSeriesTest) getRangeQueryTimeRanges(now time.Time) (ranges [][
 	return ranges
 }
 
-func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context, start, end time.Time) {
+// getInstantQueryTimes returns the timestamps to use to run test instant queries. The timestamps
+// are aligned to the write interval, in order to query the exact written samples.
+func (t *WriteReadSeriesTest) getInstantQueryTimes(now time.Time) []time.Time {
+	adjustedQueryMinTime := t.getAdjustedQueryMinTime(now)
+	if adjustedQueryMinTime.IsZero() {
+		return nil
+	}
+
+	// The last written timestamp and a random one. The max query time is always aligned, so the random
+	// timestamp can't go after it once moved to the next interval.
+	randTs := alignTimestampToInterval(randTime(adjustedQueryMinTime, t.queryMaxTime), writeInterval)
+	if randTs.Before(adjustedQueryMinTime) {
+		randTs = randTs.Add(writeInterval)
+	}
+	if randTs.Equal(t.queryMaxTime) {
+		return []time.Time{t.queryMaxTime}
+	}
+	return []time.Time{t.queryMaxTime, randTs}
+}
+
+func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context, query string, start, end time.Time) {
 	// We align start, end and step to write interval in order to avoid any false positives
 	// when checking results correctness. The min/max query time is always aligned.
 	start = maxTime(t.queryMinTime, alignTimestampToInterval(start, writeInterval))
@@ -164,7 +225,6 @@ func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context,
 	}
 
 	step := getQueryStep(start, end, writeInterval)
-	query := fmt.Sprintf("sum(%s)", metricName)
 
 	logger := log.With(t.logger, "query", query, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step)
 	level.Debug(logger).Log("msg", "Running range query")
@@ -182,8 +242,67 @@ func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context,
 	if err != nil {
 		t.metrics.queryResultChecksFailedTotal.Inc()
 		level.Warn(logger).Log("msg", "Range query result check failed", "err", err)
+	}
+
+	if !t.cfg.QueryComparisonEnabled {
+		return
+	}
+
+	level.Debug(logger).Log("msg", "Running range query with query sharding and results cache disabled")
+
+	t.metrics.queriesTotal.Inc()
+	expected, err := t.client.QueryRange(ctx, query, start, end, step, WithQueryShardingDisabled(), WithResultsCacheDisabled())
+	if err != nil {
+		t.metrics.queriesFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to execute range query with query sharding and results cache disabled", "err", err)
+		return
+	}
+
+	t.metrics.queryResultComparisonsTotal.Inc()
+	if err := compareMatrices(expected, matrix); err != nil {
+		t.metrics.queryResultComparisonsFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Range query result differs from the result with query sharding and results cache disabled", "err", err)
+	}
+}
+
+func (t *WriteReadSeriesTest) runInstantQueryAndVerifyResult(ctx context.Context, query string, ts time.Time) {
+	logger := log.With(t.logger, "query", query, "time", ts.UnixMilli())
+	level.Debug(logger).Log("msg", "Running instant query")
+
+	t.metrics.queriesTotal.Inc()
+	vector, err := t.client.Query(ctx, query, ts)
+	if err != nil {
+		t.metrics.queriesFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
 		return
 	}
+
+	t.metrics.queryResultChecksTotal.Inc()
+	err = verifySineWaveSampleSum(vector, t.cfg.NumSeries, ts)
+	if err != nil {
+		t.metrics.queryResultChecksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Instant query result check failed", "err", err)
+	}
+
+	if !t.cfg.QueryComparisonEnabled {
+		return
+	}
+
+	level.Debug(logger).Log("msg", "Running instant query with query sharding and results cache disabled")
+
+	t.metrics.queriesTotal.Inc()
+	expected, err := t.client.Query(ctx, query, ts, WithQueryShardingDisabled(), WithResultsCacheDisabled())
+	if err != nil {
+		t.metrics.queriesFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query with query sharding and results cache disabled", "err", err)
+		return
+	}
+
+	t.metrics.queryResultComparisonsTotal.Inc()
+	if err := compareVectors(expected, vector); err != nil {
+		t.metrics.queryResultComparisonsFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Instant query result differs from the result with query sharding and results cache disabled", "err", err)
+	}
 }
 
 func (t *WriteReadSeriesTest) nextWriteTimestamp(now time.Time) time.Time {
@@ -25,10 +25,15 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 	flagext.DefaultValues(&cfg)
 	cfg.NumSeries = 2
 
+	// Only run sum() range queries. Other queries are covered by TestWriteReadSeriesTest_Run_AdditionalQueries.
+	cfg.AdditionalQueriesEnabled = false
+	cfg.InstantQueriesEnabled = false
+	cfg.QueryComparisonEnabled = false
+
 	t.Run("should write series with current timestamp if it's already aligned to write interval", func(t *testing.T) {
 		client := &ClientMock{}
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
-		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
 
 		reg := prometheus.NewPedanticRegistry()
 		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
@@ -41,7 +46,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval, requestOptions{})
 
 		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
 			# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
@@ -63,7 +68,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 	t.Run("should write series with timestamp aligned to write interval", func(t *testing.T) {
 		client := &ClientMock{}
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
-		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
 
 		reg := prometheus.NewPedanticRegistry()
 		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
@@ -76,7 +81,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(980), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(980, 0), time.Unix(980, 0), writeInterval)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(980, 0), time.Unix(980, 0), writeInterval, requestOptions{})
 
 		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
 			# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
@@ -98,7 +103,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 	t.Run("should write series from last written timestamp until now", func(t *testing.T) {
 		client := &ClientMock{}
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
-		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
 
 		reg := prometheus.NewPedanticRegistry()
 		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
@@ -114,7 +119,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(960, 0), time.Unix(1000, 0), writeInterval)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(960, 0), time.Unix(1000, 0), writeInterval, requestOptions{})
 
 		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
 			# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
@@ -230,7 +235,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 
 		client := &ClientMock{}
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
-		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
 			{Values: []model.SamplePair{newSamplePair(now, generateSineWaveValue(now)*float64(cfg.NumSeries))}},
 		}, nil)
 
@@ -244,7 +249,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval, requestOptions{})
 
 		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
 			# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
@@ -277,7 +282,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 
 		client := &ClientMock{}
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
-		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
 			{Values: []model.SamplePair{{Timestamp: model.Time(now.UnixMilli()), Value: 12345}}},
 		}, nil)
 
@@ -291,7 +296,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval, requestOptions{})
 
 		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
 			# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
@@ -320,6 +325,164 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 	})
 }
 
+func TestWriteReadSeriesTest_Run_AdditionalQueries(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	now := time.Unix(1000, 0)
+	bypassOptions := requestOptions{resultsCacheDisabled: true, queryShardingDisabled: true}
+	expectedMatrix := model.Matrix{{Values: []model.SamplePair{newSamplePair(now, generateSineWaveValue(now)*float64(cfg.NumSeries))}}}
+	expectedVector := model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(generateSineWaveValue(now) * float64(cfg.NumSeries))}}
+
+	t.Run("should run range and instant queries with and without query sharding and results cache, and track no failure if results match", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(expectedMatrix, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(expectedVector, nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
+
+		test.Run(context.Background(), now)
+
+		// 2 time ranges and 1 timestamp, each query run with and without query sharding and results cache.
+		client.AssertNumberOfCalls(t, "QueryRange", 2*len(queryShapes)*2)
+		client.AssertNumberOfCalls(t, "Query", len(queryShapes)*2)
+
+		for _, query := range queryShapes {
+			client.AssertCalled(t, "QueryRange", mock.Anything, query, now, now, writeInterval, requestOptions{})
+			client.AssertCalled(t, "QueryRange", mock.Anything, query, now, now, writeInterval, bypassOptions)
+			client.AssertCalled(t, "Query", mock.Anything, query, now, requestOptions{})
+			client.AssertCalled(t, "Query", mock.Anything, query, now, bypassOptions)
+		}
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
+			# TYPE mimir_continuous_test_queries_total counter
+			mimir_continuous_test_queries_total{test="write-read-series"} 24
+
+			# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_total counter
+			mimir_continuous_test_query_result_checks_total{test="write-read-series"} 12
+
+			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+			mimir_continuous_test_query_result_checks_failed_total{test="write-read-series"} 0
+
+			# HELP mimir_continuous_test_query_result_comparisons_total Total number of query results compared with the results of the same query run with query sharding and results cache disabled.
+			# TYPE mimir_continuous_test_query_result_comparisons_total counter
+			mimir_continuous_test_query_result_comparisons_total{test="write-read-series"} 12
+
+			# HELP mimir_continuous_test_query_result_comparisons_failed_total Total number of query results differing from the results of the same query run with query sharding and results cache disabled.
+			# TYPE mimir_continuous_test_query_result_comparisons_failed_total counter
+			mimir_continuous_test_query_result_comparisons_failed_total{test="write-read-series"} 0
+		`),
+			"mimir_continuous_test_queries_total",
+			"mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total",
+			"mimir_continuous_test_query_result_comparisons_total", "mimir_continuous_test_query_result_comparisons_failed_total"))
+	})
+
+	t.Run("should track failure if results with and without query sharding and results cache differ", func(t *testing.T) {
+		divergingMatrix := model.Matrix{{Values: []model.SamplePair{{Timestamp: model.Time(now.UnixMilli()), Value: 12345}}}}
+		divergingVector := model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 12345}}
+
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, requestOptions{}).Return(expectedMatrix, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, bypassOptions).Return(divergingMatrix, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, requestOptions{}).Return(expectedVector, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, bypassOptions).Return(divergingVector, nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
+
+		test.Run(context.Background(), now)
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+			mimir_continuous_test_query_result_checks_failed_total{test="write-read-series"} 0
+
+			# HELP mimir_continuous_test_query_result_comparisons_total Total number of query results compared with the results of the same query run with query sharding and results cache disabled.
+			# TYPE mimir_continuous_test_query_result_comparisons_total counter
+			mimir_continuous_test_query_result_comparisons_total{test="write-read-series"} 12
+
+			# HELP mimir_continuous_test_query_result_comparisons_failed_total Total number of query results differing from the results of the same query run with query sharding and results cache disabled.
+			# TYPE mimir_continuous_test_query_result_comparisons_failed_total counter
+			mimir_continuous_test_query_result_comparisons_failed_total{test="write-read-series"} 12
+		`),
+			"mimir_continuous_test_query_result_checks_failed_total",
+			"mimir_continuous_test_query_result_comparisons_total", "mimir_continuous_test_query_result_comparisons_failed_total"))
+	})
+
+	t.Run("should not compare results if query comparison is disabled", func(t *testing.T) {
+		cfg := cfg
+		cfg.QueryComparisonEnabled = false
+
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(expectedMatrix, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(expectedVector, nil)
+
+		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
+		test.Run(context.Background(), now)
+
+		client.AssertNumberOfCalls(t, "QueryRange", 2*len(queryShapes))
+		client.AssertNumberOfCalls(t, "Query", len(queryShapes))
+		client.AssertNotCalled(t, "QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, bypassOptions)
+		client.AssertNotCalled(t, "Query", mock.Anything, mock.Anything, mock.Anything, bypassOptions)
+	})
+}
+
+func TestWriteReadSeriesTest_getInstantQueryTimes(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.MaxQueryAge = 2 * 24 * time.Hour
+
+	now := time.Unix(int64((10*24*time.Hour)+(2*time.Second)), 0)
+
+	t.Run("min/max query time has not been set yet", func(t *testing.T) {
+		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+
+		assert.Empty(t, test.getInstantQueryTimes(now))
+	})
+
+	t.Run("min/max query time is older than max age", func(t *testing.T) {
+		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test.queryMinTime = now.Add(-cfg.MaxQueryAge).Add(-time.Minute)
+		test.queryMaxTime = now.Add(-cfg.MaxQueryAge).Add(-time.Minute)
+
+		assert.Empty(t, test.getInstantQueryTimes(now))
+	})
+
+	t.Run("min query time = max query time", func(t *testing.T) {
+		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test.queryMinTime = alignTimestampToInterval(now.Add(-time.Minute), writeInterval)
+		test.queryMaxTime = test.queryMinTime
+
+		assert.Equal(t, []time.Time{test.queryMaxTime}, test.getInstantQueryTimes(now))
+	})
+
+	t.Run("min query time is before max query time", func(t *testing.T) {
+		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test.queryMinTime = alignTimestampToInterval(now.Add(-30*time.Hour), writeInterval)
+		test.queryMaxTime = alignTimestampToInterval(now.Add(-time.Minute), writeInterval)
+
+		actual := test.getInstantQueryTimes(now)
+		require.NotEmpty(t, actual)
+		require.Equal(t, test.queryMaxTime, actual[0])
+
+		// Random timestamp, aligned to the write interval.
+		for _, ts := range actual[1:] {
+			require.GreaterOrEqual(t, ts.Unix(), now.Add(-cfg.MaxQueryAge).Unix())
+			require.Less(t, ts.Unix(), test.queryMaxTime.Unix())
+			require.Equal(t, alignTimestampToInterval(ts, writeInterval), ts)
+		}
+	})
+}
+
 func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
 	cfg := WriteReadSeriesTestConfig{}
 	flagext.DefaultValues(&cfg)
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// writeHistory tracks the time range of the samples successfully written by a test.
+type writeHistory struct {
+	lastWrittenTimestamp time.Time
+	queryMinTime         time.Time
+	queryMaxTime         time.Time
+}
+
+func (h *writeHistory) nextWriteTimestamp(now time.Time) time.Time {
+	if h.lastWrittenTimestamp.IsZero() {
+		return alignTimestampToInterval(now, writeInterval)
+	}
+
+	return h.lastWrittenTimestamp.Add(writeInterval)
+}
+
+// writeSamples writes the series returned by generate for each write interval until now, and keeps
+// track of the time range which can be reliably queried in the history.
+func writeSamples(ctx context.Context, now time.Time, h *writeHistory, client MimirClient, metrics *TestMetrics, logger log.Logger, generate func(timestamp time.Time) []prompb.TimeSeries) {
+	for timestamp := h.nextWriteTimestamp(now); !timestamp.After(now); timestamp = h.nextWriteTimestamp(now) {
+		series := generate(timestamp)
+		statusCode, err := client.WriteSeries(ctx, series)
+
+		metrics.writesTotal.Inc()
+		if statusCode/100 != 2 {
+			metrics.writesFailedTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+			level.Warn(logger).Log("msg", "Failed to remote write series", "num_series", len(series), "timestamp", timestamp.String(), "status_code", statusCode, "err", err)
+		} else {
+			level.Debug(logger).Log("msg", "Remote write series succeeded", "num_series", len(series), "timestamp", timestamp.String())
+		}
+
+		// If the write request failed because of a 4xx error, retrying the request isn't expected to succeed.
+		// We keep writing the next interval, but we reset the query timestamp because we can't reliably
+		// assert on query results due to possible gaps.
+		if statusCode/100 == 4 {
+			h.lastWrittenTimestamp = timestamp
+			h.queryMinTime = time.Time{}
+			h.queryMaxTime = time.Time{}
+			continue
+		}
+
+		// If the write request failed because of a network or 5xx error, we'll retry to write series
+		// in the next test run.
+		if statusCode/100 != 2 || err != nil {
+			break
+		}
+
+		// The write request succeeded.
+		h.lastWrittenTimestamp = timestamp
+		h.queryMaxTime = timestamp
+		if h.queryMinTime.IsZero() {
+			h.queryMinTime = timestamp
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/ring"
+	ring_client "github.com/grafana/dskit/ring/client"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grafana/mimir/pkg/distributor/aggregation"
+	"github.com/grafana/mimir/pkg/distributor/distributorpb"
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// aggregationForwardedHeader is the gRPC metadata set on the push requests forwarding series
+// to the distributor owning their aggregation group.
+const aggregationForwardedHeader = "x-mimir-aggregation-forwarded"
+
+type aggregationOutputContextKey struct{}
+
+// aggregationRing implements aggregation.Ring on top of the distributors ring.
+type aggregationRing struct {
+	ring ring.ReadRing
+	addr string
+}
+
+func (r *aggregationRing) Owner(token uint32) (string, error) {
+	set, err := r.ring.Get(token, ring.WriteNoExtend, nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(set.Instances) == 0 {
+		return "", errors.New("no distributor owns the aggregation group")
+	}
+
+	if owner := set.Instances[0].Addr; owner != r.addr {
+		return owner, nil
+	}
+	return "", nil
+}
+
+type closableHealthAndDistributorClient struct {
+	distributorpb.DistributorClient
+	grpc_health_v1.HealthClient
+	conn *grpc.ClientConn
+}
+
+func (c *closableHealthAndDistributorClient) Close() error {
+	return c.conn.Close()
+}
+
+// newDistributorPool returns a pool of clients to the distributors in the ring, used to forward
+// the series to the distributor owning their aggregation group.
+func newDistributorPool(cfg PoolConfig, clientCfg grpcclient.Config, distributorsRing ring.ReadRing, reg prometheus.Registerer, logger log.Logger) *ring_client.Pool {
+	requestDuration := promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cortex",
+		Name:      "distributor_client_request_duration_seconds",
+		Help:      "Time spent doing distributor requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 6),
+	}, []string{"operation", "status_code"})
+	clientsCount := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Namespace: "cortex",
+		Name:      "distributor_distributor_clients",
+		Help:      "The current number of distributor clients.",
+	})
+
+	factory := func(addr string) (ring_client.PoolClient, error) {
+		opts, err := clientCfg.DialOption(grpcclient.Instrument(requestDuration))
+		if err != nil {
+			return nil, err
+		}
+		conn, err := grpc.Dial(addr, opts...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to dial distributor %s", addr)
+		}
+		return &closableHealthAndDistributorClient{
+			DistributorClient: distributorpb.NewDistributorClient(conn),
+			HealthClient:      grpc_health_v1.NewHealthClient(conn),
+			conn:              conn,
+		}, nil
+	}
+
+	poolCfg := ring_client.PoolConfig{
+		CheckInterval:      cfg.ClientCleanupPeriod,
+		HealthCheckEnabled: true,
+		HealthCheckTimeout: cfg.RemoteTimeout,
+	}
+	return ring_client.NewPool("distributor", poolCfg, ring_client.NewRingServiceDiscovery(distributorsRing), factory, clientsCount, logger)
+}
+
+// aggregationReq returns a new aggregation request for the tenant, or nil if the tenant has no aggregation
+// rules or the request pushes the aggregated series, which must not be aggregated again.
+func (d *Distributor) aggregationReq(ctx context.Context, userID string) *aggregation.Request {
+	if d.aggregator == nil || ctx.Value(aggregationOutputContextKey{}) != nil {
+		return nil
+	}
+
+	rules := d.limits.AggregationRules(userID)
+	if len(rules) == 0 {
+		return nil
+	}
+	return d.aggregator.NewRequest(userID, rules)
+}
+
+// pushAggregatedSeries pushes the series aggregated by this distributor.
+func (d *Distributor) pushAggregatedSeries(ctx context.Context, _ string, series []mimirpb.PreallocTimeseries) error {
+	ctx = context.WithValue(ctx, aggregationOutputContextKey{}, struct{}{})
+	_, err := d.PushWithCleanup(ctx, &mimirpb.WriteRequest{Timeseries: series, Source: mimirpb.API}, func() {})
+	return err
+}
+
+// forwardAggregationSeries forwards the series to the distributor owning their aggregation group.
+func (d *Distributor) forwardAggregationSeries(ctx context.Context, addr, userID string, series []mimirpb.PreallocTimeseries) error {
+	client, err := d.distributorPool.GetClientFor(addr)
+	if err != nil {
+		return err
+	}
+
+	ctx = user.InjectOrgID(ctx, userID)
+	ctx = metadata.AppendToOutgoingContext(ctx, aggregationForwardedHeader, "true")
+	_, err = client.(distributorpb.DistributorClient).Push(ctx, &mimirpb.WriteRequest{Timeseries: series, Source: mimirpb.API})
+	return err
+}
+
+// isAggregationForwardedRequest returns whether the push request has been forwarded by another
+// distributor to aggregate its series.
+func isAggregationForwardedRequest(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(aggregationForwardedHeader)) > 0
+}
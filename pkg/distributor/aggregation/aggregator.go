@@ -0,0 +1,582 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package aggregation
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/weaveworks/common/user"
+
+	ingester_client "github.com/grafana/mimir/pkg/ingester/client"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	// Labels added to the series forwarded to the distributor owning their aggregation group,
+	// so that it can aggregate them without looking up the rules.
+	inputLabel    = "__aggregation_input__"
+	typeLabel     = "__aggregation_type__"
+	intervalLabel = "__aggregation_interval__"
+
+	flushCheckPeriod = time.Second
+)
+
+var histogramSuffixes = []string{"_bucket", "_sum", "_count"}
+
+// ErrNotOwner is returned by AddForwarded when some of the forwarded series belong to aggregation
+// groups not owned by this distributor, e.g. while the distributors ring is changing.
+var ErrNotOwner = errors.New("the distributor doesn't own the aggregation group of some forwarded series")
+
+// Ring returns the owner of the aggregation groups.
+type Ring interface {
+	// Owner returns the address of the distributor owning the given token, or an empty string
+	// if the token is owned by this distributor.
+	Owner(token uint32) (string, error)
+}
+
+// PushFunc pushes the aggregated series of a tenant.
+type PushFunc func(ctx context.Context, userID string, series []mimirpb.PreallocTimeseries) error
+
+// ForwardFunc forwards the series of a tenant to the distributor owning their aggregation groups.
+type ForwardFunc func(ctx context.Context, addr, userID string, series []mimirpb.PreallocTimeseries) error
+
+// Aggregator pre-aggregates the incoming series matching the tenants' aggregation rules, and
+// periodically pushes the aggregated series at the end of the interval of each rule.
+type Aggregator struct {
+	services.Service
+
+	cfg     Config
+	ring    Ring
+	push    PushFunc
+	forward ForwardFunc
+	logger  log.Logger
+
+	mtx     sync.Mutex
+	tenants map[string]map[string]*group
+
+	inputSamples    *prometheus.CounterVec
+	forwardedSeries *prometheus.CounterVec
+	forwardFailures *prometheus.CounterVec
+	outputSamples   *prometheus.CounterVec
+	pushFailures    *prometheus.CounterVec
+	droppedGroups   *prometheus.CounterVec
+}
+
+// group is the state of an aggregated series.
+type group struct {
+	labels    []mimirpb.LabelAdapter
+	token     uint32
+	typ       validation.AggregationType
+	interval  time.Duration
+	nextFlush time.Time
+
+	// total is the sum of the increases of the input counters.
+	total  float64
+	inputs map[uint64]*input
+}
+
+// input is the state of a series aggregated into a group.
+type input struct {
+	last   float64
+	lastTs int64
+	seenAt time.Time
+}
+
+// update are the samples of an input series to aggregate into a group.
+type update struct {
+	// labels of the aggregated series. They may reference the request buffer, so they must be copied if retained.
+	labels   labels.Labels
+	token    uint32
+	input    uint64
+	typ      validation.AggregationType
+	interval time.Duration
+	samples  []mimirpb.Sample
+}
+
+// NewAggregator returns a new Aggregator. If ring is nil, all the aggregation groups are owned by this distributor.
+func NewAggregator(cfg Config, ring Ring, push PushFunc, forward ForwardFunc, reg prometheus.Registerer, logger log.Logger) *Aggregator {
+	a := &Aggregator{
+		cfg:     cfg,
+		ring:    ring,
+		push:    push,
+		forward: forward,
+		logger:  logger,
+		tenants: map[string]map[string]*group{},
+
+		inputSamples: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_aggregation_input_samples_total",
+			Help:      "The total number of samples aggregated by aggregation groups owned by the distributor.",
+		}, []string{"user"}),
+		forwardedSeries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_aggregation_forwarded_series_total",
+			Help:      "The total number of series forwarded to the distributor owning their aggregation group.",
+		}, []string{"user"}),
+		forwardFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_aggregation_forward_failures_total",
+			Help:      "The total number of failed requests forwarding series to the distributor owning their aggregation group.",
+		}, []string{"user"}),
+		outputSamples: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_aggregation_output_samples_total",
+			Help:      "The total number of aggregated samples pushed by the distributor.",
+		}, []string{"user"}),
+		pushFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_aggregation_output_push_failures_total",
+			Help:      "The total number of failed pushes of aggregated samples.",
+		}, []string{"user"}),
+		droppedGroups: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_aggregation_dropped_groups_total",
+			Help:      "The total number of aggregation groups dropped without being pushed because they're not owned by the distributor anymore.",
+		}, []string{"user"}),
+	}
+
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cortex_distributor_aggregation_groups",
+		Help: "The current number of aggregation groups owned by the distributor.",
+	}, func() float64 {
+		a.mtx.Lock()
+		defer a.mtx.Unlock()
+
+		count := 0
+		for _, groups := range a.tenants {
+			count += len(groups)
+		}
+		return float64(count)
+	})
+
+	a.Service = services.NewTimerService(flushCheckPeriod, nil, a.iteration, nil)
+	return a
+}
+
+func (a *Aggregator) iteration(_ context.Context) error {
+	a.Flush(time.Now())
+	return nil
+}
+
+// RemoveUserMetrics removes the metrics of a tenant which is not active anymore.
+func (a *Aggregator) RemoveUserMetrics(userID string) {
+	a.inputSamples.DeleteLabelValues(userID)
+	a.forwardedSeries.DeleteLabelValues(userID)
+	a.forwardFailures.DeleteLabelValues(userID)
+	a.outputSamples.DeleteLabelValues(userID)
+	a.pushFailures.DeleteLabelValues(userID)
+	a.droppedGroups.DeleteLabelValues(userID)
+}
+
+// Request collects the series of a push request to aggregate.
+type Request struct {
+	aggregator *Aggregator
+	userID     string
+	rules      validation.AggregationRules
+
+	local  []update
+	remote map[string][]mimirpb.PreallocTimeseries
+}
+
+// NewRequest returns a new Request aggregating the series of the tenant according to the given rules.
+func (a *Aggregator) NewRequest(userID string, rules validation.AggregationRules) *Request {
+	return &Request{
+		aggregator: a,
+		userID:     userID,
+		rules:      rules,
+		remote:     map[string][]mimirpb.PreallocTimeseries{},
+	}
+}
+
+// Add adds the series to the aggregation groups of the matching rules. It returns whether the
+// series should be sent to the ingesters, which is true unless a matching rule drops the raw series.
+// The series is retained until Send is called.
+func (r *Request) Add(ts mimirpb.PreallocTimeseries) bool {
+	lbls := mimirpb.FromLabelAdaptersToLabels(ts.Labels)
+	keep := true
+
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if !matches(rule.Matchers(), lbls) {
+			continue
+		}
+		keep = keep && rule.KeepRawSeries
+
+		out, ok := outputLabels(rule, lbls)
+		if !ok {
+			continue
+		}
+
+		u := update{
+			labels:   out,
+			token:    tokenForLabels(r.userID, out),
+			input:    lbls.Hash(),
+			typ:      rule.Type,
+			interval: time.Duration(rule.Interval),
+			samples:  ts.Samples,
+		}
+
+		if owner := r.aggregator.owner(r.userID, u.token); owner != "" {
+			r.remote[owner] = append(r.remote[owner], u.forwardedSeries())
+		} else {
+			r.local = append(r.local, u)
+		}
+	}
+
+	return keep
+}
+
+// Send aggregates the series owned by this distributor, and forwards the other ones to their owners.
+// It blocks until all the series have been forwarded, and returns the first forwarding error, if any.
+func (r *Request) Send(ctx context.Context) error {
+	r.aggregator.apply(r.userID, r.local, time.Now())
+	if len(r.remote) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.aggregator.cfg.ForwardTimeout)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, len(r.remote))
+	for addr, series := range r.remote {
+		wg.Add(1)
+		go func(addr string, series []mimirpb.PreallocTimeseries) {
+			defer wg.Done()
+
+			r.aggregator.forwardedSeries.WithLabelValues(r.userID).Add(float64(len(series)))
+			if err := r.aggregator.forward(ctx, addr, r.userID, series); err != nil {
+				r.aggregator.forwardFailures.WithLabelValues(r.userID).Inc()
+				errs <- errors.Wrapf(err, "failed to forward series to the aggregation owner %s", addr)
+			}
+		}(addr, series)
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// AddForwarded aggregates the series forwarded by another distributor whose aggregation groups are
+// owned by this distributor. The other series are not forwarded again, to not bounce them between
+// distributors while the ring is changing: ErrNotOwner is returned instead, so that the client retries
+// the request once the distributors agree on the owner. The retried samples already aggregated are ignored.
+func (a *Aggregator) AddForwarded(userID string, series []mimirpb.PreallocTimeseries) error {
+	updates := make([]update, 0, len(series))
+	notOwned := 0
+	for _, ts := range series {
+		u, err := parseForwardedSeries(ts)
+		if err != nil {
+			return err
+		}
+		u.token = tokenForLabels(userID, u.labels)
+		if a.owner(userID, u.token) != "" {
+			notOwned++
+			continue
+		}
+		updates = append(updates, u)
+	}
+
+	a.apply(userID, updates, time.Now())
+	if notOwned > 0 {
+		return errors.Wrapf(ErrNotOwner, "%d series", notOwned)
+	}
+	return nil
+}
+
+func (a *Aggregator) owner(userID string, token uint32) string {
+	if a.ring == nil {
+		return ""
+	}
+
+	owner, err := a.ring.Owner(token)
+	if err != nil {
+		// Aggregate locally rather than losing the samples.
+		level.Debug(a.logger).Log("msg", "failed to lookup the owner of the aggregation group, aggregating locally", "user", userID, "err", err)
+		return ""
+	}
+	return owner
+}
+
+func (a *Aggregator) apply(userID string, updates []update, now time.Time) {
+	if len(updates) == 0 {
+		return
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	groups := a.tenants[userID]
+	if groups == nil {
+		groups = map[string]*group{}
+		a.tenants[userID] = groups
+	}
+
+	samples := 0
+	for _, u := range updates {
+		key := string(u.typ) + u.labels.String()
+		g := groups[key]
+		if g == nil {
+			g = &group{
+				labels:    mimirpb.FromLabelsToLabelAdapters(copyLabels(u.labels)),
+				token:     u.token,
+				typ:       u.typ,
+				interval:  u.interval,
+				nextFlush: nextFlush(now, u.interval),
+				inputs:    map[uint64]*input{},
+			}
+			groups[key] = g
+		}
+
+		g.add(u.input, u.samples, now)
+		samples += len(u.samples)
+	}
+
+	a.inputSamples.WithLabelValues(userID).Add(float64(samples))
+}
+
+func (g *group) add(id uint64, samples []mimirpb.Sample, now time.Time) {
+	in := g.inputs[id]
+
+	for _, s := range samples {
+		if value.IsStaleNaN(s.Value) {
+			// The input series ended. Counters keep the increase computed so far.
+			delete(g.inputs, id)
+			in = nil
+			continue
+		}
+		if math.IsNaN(s.Value) {
+			continue
+		}
+
+		if in == nil {
+			// The first sample of a counter is its baseline, because we can't know whether
+			// it was already aggregated before, e.g. by another distributor.
+			in = &input{last: s.Value, lastTs: s.TimestampMs}
+			g.inputs[id] = in
+			continue
+		}
+
+		// Samples are ignored if older than the latest one, so that retried requests are not counted twice.
+		if s.TimestampMs <= in.lastTs {
+			continue
+		}
+
+		if g.typ != validation.AggregationTypeGauge {
+			if s.Value >= in.last {
+				g.total += s.Value - in.last
+			} else {
+				// Counter reset.
+				g.total += s.Value
+			}
+		}
+		in.last = s.Value
+		in.lastTs = s.TimestampMs
+	}
+
+	if in != nil {
+		in.seenAt = now
+	}
+}
+
+// Flush pushes the aggregated samples of the groups whose interval ended, and removes the state
+// of the input series which didn't receive samples within the idle timeout. The groups whose interval
+// ended but which are now owned by another distributor are dropped instead of being pushed, because
+// the new owner pushes the same series, starting from the samples it receives.
+func (a *Aggregator) Flush(now time.Time) {
+	output := map[string][]mimirpb.PreallocTimeseries{}
+	dropped := map[string]int{}
+
+	a.mtx.Lock()
+	for userID, groups := range a.tenants {
+		for key, g := range groups {
+			for id, in := range g.inputs {
+				if now.Sub(in.seenAt) > a.cfg.IdleTimeout {
+					delete(g.inputs, id)
+				}
+			}
+			if len(g.inputs) == 0 {
+				delete(groups, key)
+				continue
+			}
+
+			if now.Before(g.nextFlush) {
+				continue
+			}
+
+			if a.owner(userID, g.token) != "" {
+				delete(groups, key)
+				dropped[userID]++
+				continue
+			}
+
+			ts := g.nextFlush
+			g.nextFlush = nextFlush(now, g.interval)
+
+			v, ok := g.value(ts)
+			if !ok {
+				continue
+			}
+
+			output[userID] = append(output[userID], mimirpb.PreallocTimeseries{
+				TimeSeries: &mimirpb.TimeSeries{
+					// Labels are copied because the push may modify them.
+					Labels:  append([]mimirpb.LabelAdapter(nil), g.labels...),
+					Samples: []mimirpb.Sample{{TimestampMs: ts.UnixMilli(), Value: v}},
+				},
+			})
+		}
+
+		if len(groups) == 0 {
+			delete(a.tenants, userID)
+		}
+	}
+	a.mtx.Unlock()
+
+	for userID, count := range dropped {
+		a.droppedGroups.WithLabelValues(userID).Add(float64(count))
+		level.Debug(a.logger).Log("msg", "dropped aggregation groups owned by another distributor", "user", userID, "groups", count)
+	}
+
+	for userID, series := range output {
+		ctx := user.InjectOrgID(context.Background(), userID)
+		if err := a.push(ctx, userID, series); err != nil {
+			a.pushFailures.WithLabelValues(userID).Inc()
+			level.Warn(a.logger).Log("msg", "failed to push aggregated series", "user", userID, "series", len(series), "err", err)
+			continue
+		}
+		a.outputSamples.WithLabelValues(userID).Add(float64(len(series)))
+	}
+}
+
+// value returns the value of the aggregated series at the end of the interval ending at ts.
+// Gauges are the sum of the latest value of the input series which received samples within the interval.
+func (g *group) value(ts time.Time) (float64, bool) {
+	if g.typ != validation.AggregationTypeGauge {
+		return g.total, true
+	}
+
+	sum, found := 0.0, false
+	for _, in := range g.inputs {
+		if !in.seenAt.Before(ts.Add(-g.interval)) {
+			sum += in.last
+			found = true
+		}
+	}
+	return sum, found
+}
+
+func (u update) forwardedSeries() mimirpb.PreallocTimeseries {
+	lbls := make([]mimirpb.LabelAdapter, 0, len(u.labels)+3)
+	lbls = append(lbls, mimirpb.FromLabelsToLabelAdapters(u.labels)...)
+	lbls = append(lbls,
+		mimirpb.LabelAdapter{Name: inputLabel, Value: strconv.FormatUint(u.input, 16)},
+		mimirpb.LabelAdapter{Name: typeLabel, Value: string(u.typ)},
+		mimirpb.LabelAdapter{Name: intervalLabel, Value: u.interval.String()},
+	)
+	sort.Slice(lbls, func(i, j int) bool { return lbls[i].Name < lbls[j].Name })
+
+	return mimirpb.PreallocTimeseries{
+		TimeSeries: &mimirpb.TimeSeries{Labels: lbls, Samples: u.samples},
+	}
+}
+
+func parseForwardedSeries(ts mimirpb.PreallocTimeseries) (update, error) {
+	u := update{samples: ts.Samples}
+	out := make(labels.Labels, 0, len(ts.Labels))
+
+	var err error
+	for _, l := range ts.Labels {
+		switch l.Name {
+		case inputLabel:
+			u.input, err = strconv.ParseUint(l.Value, 16, 64)
+		case typeLabel:
+			u.typ = validation.AggregationType(l.Value)
+		case intervalLabel:
+			u.interval, err = time.ParseDuration(l.Value)
+		default:
+			out = append(out, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		if err != nil {
+			return update{}, errors.Wrapf(err, "invalid forwarded aggregation series label %s=%q", l.Name, l.Value)
+		}
+	}
+
+	if u.typ == "" || u.interval <= 0 {
+		return update{}, errors.Errorf("forwarded aggregation series %s misses the aggregation type or interval", out)
+	}
+
+	u.labels = out
+	return u, nil
+}
+
+// outputLabels returns the labels of the aggregated series of the given input series. It returns false
+// if the series can't be aggregated, e.g. if it's not part of a classic histogram for histogram rules.
+func outputLabels(rule *validation.AggregationRule, lbls labels.Labels) (labels.Labels, bool) {
+	name := rule.OutputMetricName
+	if rule.Type == validation.AggregationTypeHistogram {
+		metric := lbls.Get(labels.MetricName)
+
+		suffix := ""
+		for _, s := range histogramSuffixes {
+			if len(metric) > len(s) && metric[len(metric)-len(s):] == s {
+				suffix = s
+				break
+			}
+		}
+		if suffix == "" {
+			return nil, false
+		}
+		name += suffix
+	}
+
+	b := labels.NewBuilder(lbls)
+	b.Del(rule.DropLabels...)
+	b.Set(labels.MetricName, name)
+	return b.Labels(), true
+}
+
+func matches(matchers []*labels.Matcher, lbls labels.Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func tokenForLabels(userID string, lbls labels.Labels) uint32 {
+	h := ingester_client.HashNew32()
+	h = ingester_client.HashAdd32(h, userID)
+	for _, l := range lbls {
+		h = ingester_client.HashAdd32(h, l.Name)
+		h = ingester_client.HashAdd32(h, l.Value)
+	}
+	return h
+}
+
+func nextFlush(now time.Time, interval time.Duration) time.Time {
+	return now.Truncate(interval).Add(interval)
+}
+
+func copyLabels(lbls labels.Labels) labels.Labels {
+	copied := make(labels.Labels, 0, len(lbls))
+	for _, l := range lbls {
+		copied = append(copied, labels.Label{Name: string([]byte(l.Name)), Value: string([]byte(l.Value))})
+	}
+	return copied
+}
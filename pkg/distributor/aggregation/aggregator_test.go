@@ -0,0 +1,300 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package aggregation
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+type pushedSeries struct {
+	mtx    sync.Mutex
+	series map[string][]mimirpb.PreallocTimeseries
+}
+
+func (p *pushedSeries) push(ctx context.Context, userID string, series []mimirpb.PreallocTimeseries) error {
+	orgID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return err
+	}
+	if orgID != userID {
+		return errors.New("unexpected tenant")
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.series[userID] = append(p.series[userID], series...)
+	return nil
+}
+
+// values returns the pushed values by series, checking that they are timestamped at the end of an interval.
+func (p *pushedSeries) values(t *testing.T, userID string, interval time.Duration) map[string][]float64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	out := map[string][]float64{}
+	for _, ts := range p.series[userID] {
+		key := mimirpb.FromLabelAdaptersToLabels(ts.Labels).String()
+		for _, s := range ts.Samples {
+			assert.Zero(t, s.TimestampMs%interval.Milliseconds())
+			out[key] = append(out[key], s.Value)
+		}
+	}
+	return out
+}
+
+type staticRing struct {
+	owner string
+}
+
+func (r staticRing) Owner(uint32) (string, error) {
+	return r.owner, nil
+}
+
+// ringView is the view of a distributor on the owner of all the aggregation groups, which can change.
+type ringView struct {
+	self  string
+	mtx   sync.Mutex
+	owner string
+}
+
+func (r *ringView) Owner(uint32) (string, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.owner == r.self {
+		return "", nil
+	}
+	return r.owner, nil
+}
+
+func (r *ringView) setOwner(owner string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.owner = owner
+}
+
+func parseRules(t *testing.T, cfg string) validation.AggregationRules {
+	var rules validation.AggregationRules
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfg), &rules))
+	return rules
+}
+
+func series(lbls labels.Labels, samples ...mimirpb.Sample) mimirpb.PreallocTimeseries {
+	return mimirpb.PreallocTimeseries{
+		TimeSeries: &mimirpb.TimeSeries{Labels: mimirpb.FromLabelsToLabelAdapters(lbls), Samples: samples},
+	}
+}
+
+func newTestAggregator(ring Ring, forward ForwardFunc) (*Aggregator, *pushedSeries) {
+	pushed := &pushedSeries{series: map[string][]mimirpb.PreallocTimeseries{}}
+	cfg := Config{Enabled: true, IdleTimeout: time.Hour, ForwardTimeout: time.Second}
+	return NewAggregator(cfg, ring, pushed.push, forward, nil, log.NewNopLogger()), pushed
+}
+
+func TestAggregator_Counter(t *testing.T) {
+	rules := parseRules(t, `
+- match: http_requests_total{job="api"}
+  drop_labels: [pod]
+  type: counter
+  output_metric_name: job:http_requests_total:sum
+`)
+	a, pushed := newTestAggregator(nil, nil)
+
+	req := a.NewRequest("user-1", rules)
+	assert.False(t, req.Add(series(labels.FromStrings("__name__", "http_requests_total", "job", "api", "pod", "a"), mimirpb.Sample{TimestampMs: 1000, Value: 10}, mimirpb.Sample{TimestampMs: 2000, Value: 15})))
+	assert.False(t, req.Add(series(labels.FromStrings("__name__", "http_requests_total", "job", "api", "pod", "b"), mimirpb.Sample{TimestampMs: 1000, Value: 100}, mimirpb.Sample{TimestampMs: 2000, Value: 120})))
+	assert.True(t, req.Add(series(labels.FromStrings("__name__", "http_requests_total", "job", "other", "pod", "a"), mimirpb.Sample{TimestampMs: 1000, Value: 1})))
+	require.NoError(t, req.Send(context.Background()))
+
+	// Pod a is reset, and the retried sample of pod b is ignored.
+	req = a.NewRequest("user-1", rules)
+	req.Add(series(labels.FromStrings("__name__", "http_requests_total", "job", "api", "pod", "a"), mimirpb.Sample{TimestampMs: 3000, Value: 3}))
+	req.Add(series(labels.FromStrings("__name__", "http_requests_total", "job", "api", "pod", "b"), mimirpb.Sample{TimestampMs: 2000, Value: 120}))
+	require.NoError(t, req.Send(context.Background()))
+
+	// Nothing is pushed before the end of the interval.
+	a.Flush(time.Now())
+	assert.Empty(t, pushed.values(t, "user-1", time.Minute))
+
+	a.Flush(time.Now().Add(time.Minute))
+	assert.Equal(t, map[string][]float64{
+		`{__name__="job:http_requests_total:sum", job="api"}`: {28},
+	}, pushed.values(t, "user-1", time.Minute))
+}
+
+func TestAggregator_Gauge(t *testing.T) {
+	rules := parseRules(t, `
+- match: queue_length
+  drop_labels: [pod]
+  type: gauge
+  interval: 30s
+  output_metric_name: queue_length:sum
+  keep_raw_series: true
+`)
+	a, pushed := newTestAggregator(nil, nil)
+
+	req := a.NewRequest("user-1", rules)
+	assert.True(t, req.Add(series(labels.FromStrings("__name__", "queue_length", "pod", "a"), mimirpb.Sample{TimestampMs: 1000, Value: 10}, mimirpb.Sample{TimestampMs: 2000, Value: 5})))
+	assert.True(t, req.Add(series(labels.FromStrings("__name__", "queue_length", "pod", "b"), mimirpb.Sample{TimestampMs: 1000, Value: 7})))
+	assert.True(t, req.Add(series(labels.FromStrings("__name__", "queue_length", "pod", "c"), mimirpb.Sample{TimestampMs: 1000, Value: 1}, mimirpb.Sample{TimestampMs: 2000, Value: math.Float64frombits(value.StaleNaN)})))
+	require.NoError(t, req.Send(context.Background()))
+
+	a.Flush(time.Now().Add(30 * time.Second))
+	assert.Equal(t, map[string][]float64{
+		`{__name__="queue_length:sum"}`: {12},
+	}, pushed.values(t, "user-1", 30*time.Second))
+
+	// Once the inputs are idle, the group is removed and nothing is pushed anymore.
+	a.Flush(time.Now().Add(2 * time.Hour))
+	assert.Len(t, pushed.values(t, "user-1", 30*time.Second)[`{__name__="queue_length:sum"}`], 1)
+	assert.Empty(t, a.tenants)
+}
+
+func TestAggregator_Histogram(t *testing.T) {
+	rules := parseRules(t, `
+- match: '{__name__=~"latency_seconds_.*"}'
+  drop_labels: [pod]
+  type: histogram
+  output_metric_name: latency_seconds:sum
+`)
+	a, pushed := newTestAggregator(nil, nil)
+
+	req := a.NewRequest("user-1", rules)
+	for _, pod := range []string{"a", "b"} {
+		req.Add(series(labels.FromStrings("__name__", "latency_seconds_bucket", "le", "1", "pod", pod), mimirpb.Sample{TimestampMs: 1000, Value: 1}, mimirpb.Sample{TimestampMs: 2000, Value: 3}))
+		req.Add(series(labels.FromStrings("__name__", "latency_seconds_count", "pod", pod), mimirpb.Sample{TimestampMs: 1000, Value: 1}, mimirpb.Sample{TimestampMs: 2000, Value: 4}))
+		req.Add(series(labels.FromStrings("__name__", "latency_seconds_created", "pod", pod), mimirpb.Sample{TimestampMs: 1000, Value: 1}))
+	}
+	require.NoError(t, req.Send(context.Background()))
+
+	a.Flush(time.Now().Add(time.Minute))
+	assert.Equal(t, map[string][]float64{
+		`{__name__="latency_seconds:sum_bucket", le="1"}`: {4},
+		`{__name__="latency_seconds:sum_count"}`:          {6},
+	}, pushed.values(t, "user-1", time.Minute))
+}
+
+func TestAggregator_ForwardToOwner(t *testing.T) {
+	rules := parseRules(t, `
+- match: http_requests_total
+  drop_labels: [pod]
+  type: counter
+  output_metric_name: http_requests_total:sum
+`)
+
+	owner, pushed := newTestAggregator(nil, nil)
+
+	var forwardedTo []string
+	forward := func(_ context.Context, addr, userID string, series []mimirpb.PreallocTimeseries) error {
+		forwardedTo = append(forwardedTo, addr)
+		return owner.AddForwarded(userID, series)
+	}
+	a, localPushed := newTestAggregator(staticRing{owner: "distributor-2"}, forward)
+
+	for i, pod := range []string{"a", "b"} {
+		req := a.NewRequest("user-1", rules)
+		req.Add(series(labels.FromStrings("__name__", "http_requests_total", "pod", pod), mimirpb.Sample{TimestampMs: 1000, Value: 10}, mimirpb.Sample{TimestampMs: 2000, Value: float64(20 + i)}))
+		require.NoError(t, req.Send(context.Background()))
+	}
+	assert.Equal(t, []string{"distributor-2", "distributor-2"}, forwardedTo)
+
+	flushTs := time.Now().Add(time.Minute)
+	a.Flush(flushTs)
+	owner.Flush(flushTs)
+	assert.Empty(t, localPushed.values(t, "user-1", time.Minute))
+	assert.Equal(t, map[string][]float64{
+		`{__name__="http_requests_total:sum"}`: {21},
+	}, pushed.values(t, "user-1", time.Minute))
+
+	// Forwarding errors are returned.
+	a, _ = newTestAggregator(staticRing{owner: "distributor-2"}, func(context.Context, string, string, []mimirpb.PreallocTimeseries) error {
+		return errors.New("unavailable")
+	})
+	req := a.NewRequest("user-1", rules)
+	req.Add(series(labels.FromStrings("__name__", "http_requests_total", "pod", "a"), mimirpb.Sample{TimestampMs: 1000, Value: 10}))
+	require.Error(t, req.Send(context.Background()))
+}
+
+func TestAggregator_OwnerChange(t *testing.T) {
+	rules := parseRules(t, `
+- match: http_requests_total
+  drop_labels: [pod]
+  type: counter
+  output_metric_name: http_requests_total:sum
+`)
+
+	aggregators := map[string]*Aggregator{}
+	forward := func(_ context.Context, addr, userID string, series []mimirpb.PreallocTimeseries) error {
+		return aggregators[addr].AddForwarded(userID, series)
+	}
+	ring1 := &ringView{self: "distributor-1", owner: "distributor-1"}
+	ring2 := &ringView{self: "distributor-2", owner: "distributor-1"}
+	a1, pushed1 := newTestAggregator(ring1, forward)
+	a2, pushed2 := newTestAggregator(ring2, forward)
+	aggregators["distributor-1"] = a1
+	aggregators["distributor-2"] = a2
+
+	send := func(a *Aggregator, pod string, samples ...mimirpb.Sample) error {
+		req := a.NewRequest("user-1", rules)
+		req.Add(series(labels.FromStrings("__name__", "http_requests_total", "pod", pod), samples...))
+		return req.Send(context.Background())
+	}
+
+	// Both distributors agree that distributor-1 owns the group.
+	require.NoError(t, send(a1, "a", mimirpb.Sample{TimestampMs: 1000, Value: 10}, mimirpb.Sample{TimestampMs: 2000, Value: 15}))
+	require.NoError(t, send(a2, "b", mimirpb.Sample{TimestampMs: 1000, Value: 10}, mimirpb.Sample{TimestampMs: 2000, Value: 12}))
+
+	// distributor-1 sees the new owner first: the series forwarded by distributor-2 with a stale view
+	// of the ring are rejected with a retryable error rather than aggregated by the previous owner.
+	ring1.setOwner("distributor-2")
+	err := send(a2, "b", mimirpb.Sample{TimestampMs: 3000, Value: 14})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotOwner)
+
+	// Once distributor-2 sees the new owner too, the retried request is aggregated by distributor-2.
+	ring2.setOwner("distributor-2")
+	require.NoError(t, send(a2, "b", mimirpb.Sample{TimestampMs: 3000, Value: 14}, mimirpb.Sample{TimestampMs: 4000, Value: 20}))
+	require.NoError(t, send(a1, "a", mimirpb.Sample{TimestampMs: 3000, Value: 16}, mimirpb.Sample{TimestampMs: 4000, Value: 18}))
+
+	// The previous owner drops its group rather than pushing the same series as the new owner.
+	flushTs := time.Now().Add(time.Minute)
+	a1.Flush(flushTs)
+	a2.Flush(flushTs)
+	assert.Empty(t, pushed1.values(t, "user-1", time.Minute))
+	assert.Empty(t, a1.tenants)
+	assert.Equal(t, map[string][]float64{
+		`{__name__="http_requests_total:sum"}`: {8},
+	}, pushed2.values(t, "user-1", time.Minute))
+}
+
+func TestParseForwardedSeries(t *testing.T) {
+	u := update{
+		labels:   labels.FromStrings("__name__", "metric:sum", "job", "api"),
+		input:    12345,
+		typ:      validation.AggregationTypeGauge,
+		interval: 30 * time.Second,
+		samples:  []mimirpb.Sample{{TimestampMs: 1000, Value: 1}},
+	}
+
+	parsed, err := parseForwardedSeries(u.forwardedSeries())
+	require.NoError(t, err)
+	assert.Equal(t, u, parsed)
+
+	_, err = parseForwardedSeries(series(labels.FromStrings("__name__", "metric:sum")))
+	require.Error(t, err)
+}
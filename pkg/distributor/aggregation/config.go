@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package aggregation
+
+import (
+	"flag"
+	"time"
+)
+
+// RingNumTokens is the number of tokens each distributor registers in the distributors ring
+// when the aggregation is enabled, to evenly spread the ownership of the aggregation groups.
+const RingNumTokens = 128
+
+type Config struct {
+	Enabled        bool          `yaml:"enabled" category:"experimental"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout" category:"experimental"`
+	ForwardTimeout time.Duration `yaml:"forward_timeout" category:"experimental"`
+}
+
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&c.Enabled, "distributor.aggregation.enabled", false, "Enables the pre-aggregation of the incoming series matching the per-tenant aggregation rules. Each aggregation group is owned by a single distributor, selected through the distributors ring.")
+	f.DurationVar(&c.IdleTimeout, "distributor.aggregation.idle-timeout", 15*time.Minute, "How long the state of an aggregated series is kept after it stopped receiving samples. Aggregation groups without series are not written anymore.")
+	f.DurationVar(&c.ForwardTimeout, "distributor.aggregation.forward-timeout", 10*time.Second, "Timeout for requests forwarding the series to the distributor owning their aggregation group.")
+}
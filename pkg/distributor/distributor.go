@@ -39,12 +39,14 @@ import (
 
 	"github.com/grafana/dskit/tenant"
 
+	"github.com/grafana/mimir/pkg/distributor/aggregation"
 	"github.com/grafana/mimir/pkg/distributor/forwarding"
 	ingester_client "github.com/grafana/mimir/pkg/ingester/client"
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
 	util_math "github.com/grafana/mimir/pkg/util/math"
+	"github.com/grafana/mimir/pkg/util/push"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
@@ -81,6 +83,11 @@ type Distributor struct {
 	limits        *validation.Overrides
 	forwarder     forwarding.Forwarder
 
+	// Pre-aggregation of the incoming series, and the pool of clients used to forward
+	// the series to the distributors owning their aggregation group.
+	aggregator      *aggregation.Aggregator
+	distributorPool *ring_client.Pool
+
 	// The global rate limiter requires a distributors ring to count
 	// the number of healthy instances
 	distributorsLifeCycler *ring.Lifecycler
@@ -151,6 +158,12 @@ type Config struct {
 
 	// Configuration for forwarding of metrics to alternative ingestion endpoint.
 	Forwarding forwarding.Config
+
+	// Configuration for the pre-aggregation of the incoming series.
+	Aggregation aggregation.Config `yaml:"aggregation"`
+
+	// Configuration for the Influx line protocol push endpoint.
+	Influx push.InfluxConfig `yaml:"influx"`
 }
 
 type InstanceLimits struct {
@@ -164,6 +177,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.HATrackerConfig.RegisterFlags(f)
 	cfg.DistributorRing.RegisterFlags(f, logger)
 	cfg.Forwarding.RegisterFlags(f)
+	cfg.Aggregation.RegisterFlags(f)
+	cfg.Influx.RegisterFlags(f)
 
 	f.IntVar(&cfg.MaxRecvMsgSize, "distributor.max-recv-msg-size", 100<<20, "remote_write API max receive message size (bytes).")
 	f.DurationVar(&cfg.RemoteTimeout, "distributor.remote-timeout", 20*time.Second, "Timeout for downstream ingesters.")
@@ -178,6 +193,10 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 		return errInvalidTenantShardSize
 	}
 
+	if err := cfg.Forwarding.Validate(); err != nil {
+		return err
+	}
+
 	return cfg.HATrackerConfig.Validate()
 }
 
@@ -215,7 +234,13 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 	if !canJoinDistributorsRing {
 		ingestionRateStrategy = newInfiniteIngestionRateStrategy()
 	} else {
-		distributorsLifeCycler, err = ring.NewLifecycler(cfg.DistributorRing.ToLifecyclerConfig(), nil, "distributor", DistributorRingKey, true, log, prometheus.WrapRegistererWithPrefix("cortex_", reg))
+		lifecyclerCfg := cfg.DistributorRing.ToLifecyclerConfig()
+		if cfg.Aggregation.Enabled {
+			// The tokens are used to spread the ownership of the aggregation groups.
+			lifecyclerCfg.NumTokens = aggregation.RingNumTokens
+		}
+
+		distributorsLifeCycler, err = ring.NewLifecycler(lifecyclerCfg, nil, "distributor", DistributorRingKey, true, log, prometheus.WrapRegistererWithPrefix("cortex_", reg))
 		if err != nil {
 			return nil, err
 		}
@@ -367,7 +392,23 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 		return d.ingestionRate.Rate()
 	})
 
-	d.forwarder = forwarding.NewForwarder(reg, d.cfg.Forwarding)
+	d.forwarder = forwarding.NewForwarder(reg, d.cfg.Forwarding, d.limits, log)
+	if d.forwarder != nil {
+		subservices = append(subservices, d.forwarder)
+	}
+
+	if cfg.Aggregation.Enabled {
+		// When the distributor can't join the distributors ring, all the aggregation groups are owned locally.
+		var ownersRing aggregation.Ring
+		if distributorsRing != nil {
+			ownersRing = &aggregationRing{ring: distributorsRing, addr: distributorsLifeCycler.Addr}
+			d.distributorPool = newDistributorPool(cfg.PoolConfig, clientConfig.GRPCClientConfig, distributorsRing, reg, log)
+			subservices = append(subservices, d.distributorPool)
+		}
+
+		d.aggregator = aggregation.NewAggregator(cfg.Aggregation, ownersRing, d.pushAggregatedSeries, d.forwardAggregationSeries, reg, log)
+		subservices = append(subservices, d.aggregator)
+	}
 
 	d.replicationFactor.Set(float64(ingestersRing.ReplicationFactor()))
 	d.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(d.cleanupInactiveUser)
@@ -426,6 +467,10 @@ func (d *Distributor) cleanupInactiveUser(userID string) {
 	}
 
 	validation.DeletePerUserValidationMetrics(userID, d.log)
+
+	if d.aggregator != nil {
+		d.aggregator.RemoveUserMetrics(userID)
+	}
 }
 
 // Called after distributor is asked to stop via StopAsync.
@@ -610,6 +655,22 @@ func (d *Distributor) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReq
 	now := mtime.Now()
 	d.activeUsers.UpdateUserTimestamp(userID, now)
 
+	// The series forwarded by another distributor are only aggregated by this distributor, which owns their
+	// aggregation groups, because the raw series have already been handled by the distributor forwarding them.
+	if isAggregationForwardedRequest(ctx) {
+		if d.aggregator == nil {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, "received series to aggregate but the aggregation is disabled")
+		}
+		if err := d.aggregator.AddForwarded(userID, req.Timeseries); err != nil {
+			if errors.Is(err, aggregation.ErrNotOwner) {
+				// The forwarding distributor retries the request once the distributors ring has settled.
+				return nil, httpgrpc.Errorf(http.StatusServiceUnavailable, err.Error())
+			}
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+		}
+		return &mimirpb.WriteResponse{}, nil
+	}
+
 	source := util.GetSourceIPsFromOutgoingCtx(ctx)
 
 	var firstPartialErr error
@@ -687,6 +748,7 @@ func (d *Distributor) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReq
 	}
 
 	forwardingReq := d.forwardingReq(ctx, userID)
+	aggregationReq := d.aggregationReq(ctx, userID)
 
 	// For each timeseries, compute a hash to distribute across ingesters;
 	// check each sample and discard if outside limits.
@@ -728,6 +790,11 @@ func (d *Distributor) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReq
 
 		d.labelsHistogram.Observe(float64(len(ts.Labels)))
 
+		if aggregationReq != nil && !aggregationReq.Add(ts) {
+			// The raw series is dropped in favour of the aggregated one.
+			continue
+		}
+
 		if forwardingReq != nil {
 			// If this tenant has any forwarding rules then we should add all samples to the forwarding request,
 			// those that don't match a forwarding rule will be discarded by the forwarding request.
@@ -763,6 +830,12 @@ func (d *Distributor) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReq
 		forwardingErrCh = forwardingReq.Send(ctx)
 	}
 
+	// Errors forwarding the series to aggregate are recoverable, so the client retries the whole request.
+	var aggregationErr error
+	if aggregationReq != nil {
+		aggregationErr = aggregationReq.Send(ctx)
+	}
+
 	for _, m := range req.Metadata {
 		err := validation.ValidateMetadata(d.limits, userID, m)
 		if err != nil {
@@ -785,9 +858,9 @@ func (d *Distributor) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReq
 		if forwardingErrCh != nil {
 			// Blocks until the forwarding requests have completed and the final status has been pushed through this chan.
 			err = httpgrpcutil.PrioritizeRecoverableErr(err, <-forwardingErrCh, firstPartialErr)
-			if err != nil {
-				return nil, err
-			}
+		}
+		if err = httpgrpcutil.PrioritizeRecoverableErr(err, aggregationErr); err != nil {
+			return nil, err
 		}
 
 		return &mimirpb.WriteResponse{}, firstPartialErr
@@ -851,6 +924,7 @@ func (d *Distributor) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReq
 		forwardingErr := <-forwardingErrCh
 		err = httpgrpcutil.PrioritizeRecoverableErr(err, forwardingErr, firstPartialErr)
 	}
+	err = httpgrpcutil.PrioritizeRecoverableErr(err, aggregationErr)
 
 	if err != nil {
 		return nil, err
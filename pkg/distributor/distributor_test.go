@@ -41,7 +41,9 @@ import (
 	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/dskit/tenant"
 
@@ -2303,7 +2305,7 @@ func TestDistributor_IngestionIsControlledByForwarder(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := user.InjectOrgID(context.Background(), "user")
 			limits := &validation.Limits{
-				ForwardingRules: validation.ForwardingRules{metric: validation.ForwardingRule{}},
+				ForwardingRules: validation.ForwardingRules{{Match: metric}},
 			}
 			flagext.DefaultValues(limits)
 			limits.IngestionRate = 20
@@ -2342,6 +2344,72 @@ func TestDistributor_IngestionIsControlledByForwarder(t *testing.T) {
 	}
 }
 
+func TestDistributor_Aggregation(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	require.NoError(t, yaml.Unmarshal([]byte(`
+aggregation_rules:
+  - match: http_requests_total
+    drop_labels: [pod]
+    type: counter
+    output_metric_name: http_requests_total:sum
+`), limits))
+
+	distributors, ingesters, regs := prepare(t, prepConfig{
+		numIngesters:      1,
+		happyIngesters:    1,
+		replicationFactor: 1,
+		numDistributors:   1,
+		limits:            limits,
+		aggregation:       true,
+	})
+
+	for i, value := range []float64{10, 15} {
+		req := &mimirpb.WriteRequest{}
+		for _, pod := range []string{"a", "b"} {
+			req.Timeseries = append(req.Timeseries, makeWriteRequestTimeseries(
+				[]mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "http_requests_total"}, {Name: "pod", Value: pod}},
+				int64(i+1)*1000, value,
+			))
+		}
+		req.Timeseries = append(req.Timeseries, makeWriteRequestTimeseries(
+			[]mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "other_metric"}}, int64(i+1)*1000, value,
+		))
+
+		_, err := distributors[0].Push(ctx, req)
+		require.NoError(t, err)
+	}
+
+	// The raw series matching the rule are not ingested.
+	assert.Equal(t, []string{"other_metric"}, getIngestedMetrics(ctx, t, &ingesters[0]))
+
+	// The series forwarded by other distributors are only aggregated.
+	forwardedCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(aggregationForwardedHeader, "true"))
+	_, err := distributors[0].Push(forwardedCtx, &mimirpb.WriteRequest{Timeseries: []mimirpb.PreallocTimeseries{
+		makeWriteRequestTimeseries([]mimirpb.LabelAdapter{
+			{Name: "__aggregation_input__", Value: "1"},
+			{Name: "__aggregation_interval__", Value: "1m0s"},
+			{Name: "__aggregation_type__", Value: "counter"},
+			{Name: labels.MetricName, Value: "http_requests_total:sum"},
+		}, 1000, 5),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other_metric"}, getIngestedMetrics(ctx, t, &ingesters[0]))
+
+	distributors[0].aggregator.Flush(time.Now().Add(time.Minute))
+	assert.ElementsMatch(t, []string{"other_metric", "http_requests_total:sum"}, getIngestedMetrics(ctx, t, &ingesters[0]))
+
+	require.NoError(t, testutil.GatherAndCompare(regs[0], strings.NewReader(`
+		# HELP cortex_distributor_aggregation_input_samples_total The total number of samples aggregated by aggregation groups owned by the distributor.
+		# TYPE cortex_distributor_aggregation_input_samples_total counter
+		cortex_distributor_aggregation_input_samples_total{user="user"} 5
+		# HELP cortex_distributor_aggregation_output_samples_total The total number of aggregated samples pushed by the distributor.
+		# TYPE cortex_distributor_aggregation_output_samples_total counter
+		cortex_distributor_aggregation_output_samples_total{user="user"} 1
+	`), "cortex_distributor_aggregation_input_samples_total", "cortex_distributor_aggregation_output_samples_total"))
+}
+
 // getIngestedMetrics takes a mock ingester and returns all the metric names which it has ingested.
 func getIngestedMetrics(ctx context.Context, t *testing.T, ingester *mockIngester) []string {
 	labelsClient, err := ingester.LabelNamesAndValues(ctx, nil)
@@ -2639,6 +2707,7 @@ type prepConfig struct {
 	ingesterZones                []string
 	zonesResponseDelay           map[string]time.Duration
 	forwarding                   bool
+	aggregation                  bool
 }
 
 func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, []*prometheus.Registry) {
@@ -2747,6 +2816,10 @@ func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, []*p
 			distributorCfg.Forwarding.RequestTimeout = 10 * time.Second
 		}
 
+		if cfg.aggregation {
+			distributorCfg.Aggregation.Enabled = true
+		}
+
 		cfg.limits.IngestionTenantShardSize = cfg.shuffleShardSize
 
 		if cfg.enableTracker {
@@ -3382,6 +3455,8 @@ func setMockForwarder(distributor *Distributor, ingest bool) *mockForwarder {
 }
 
 type mockForwarder struct {
+	services.Service
+
 	ingest    bool
 	sendCount atomic.Uint32
 }
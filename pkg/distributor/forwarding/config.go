@@ -5,14 +5,51 @@ package forwarding
 import (
 	"flag"
 	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errInvalidQueueCapacity = errors.New("the forwarding queue capacity must be greater than 0")
+	errInvalidBatchSize     = errors.New("the forwarding batch size must be greater than 0")
 )
 
 type Config struct {
 	Enabled        bool          `yaml:"enabled" category:"experimental"`
 	RequestTimeout time.Duration `yaml:"request_timeout" category:"experimental"`
+
+	QueueCapacity      int           `yaml:"queue_capacity" category:"experimental"`
+	BatchSize          int           `yaml:"batch_size" category:"experimental"`
+	BatchSendDeadline  time.Duration `yaml:"batch_send_deadline" category:"experimental"`
+	MinBackoff         time.Duration `yaml:"min_backoff" category:"experimental"`
+	MaxBackoff         time.Duration `yaml:"max_backoff" category:"experimental"`
+	MaxRetries         int           `yaml:"max_retries" category:"experimental"`
+	DiskBufferDir      string        `yaml:"disk_buffer_dir" category:"experimental"`
+	DiskBufferMaxBytes int64         `yaml:"disk_buffer_max_bytes" category:"experimental"`
 }
 
 func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.Enabled, "distributor.forwarding.enabled", false, "Enables the feature to forward certain metrics in remote_write requests, depending on defined rules.")
 	f.DurationVar(&c.RequestTimeout, "distributor.forwarding.request-timeout", 10*time.Second, "Timeout for requests to ingestion endpoints to which we forward metrics.")
+	f.IntVar(&c.QueueCapacity, "distributor.forwarding.queue-capacity", 10000, "Maximum number of series buffered in memory for each tenant and endpoint, waiting to be forwarded. Series exceeding the capacity are written to the disk buffer if enabled, otherwise they are dropped.")
+	f.IntVar(&c.BatchSize, "distributor.forwarding.batch-size", 1000, "Maximum number of series forwarded in a single request.")
+	f.DurationVar(&c.BatchSendDeadline, "distributor.forwarding.batch-send-deadline", time.Second, "Maximum time the series wait in the queue before being forwarded, even if the batch is not full.")
+	f.DurationVar(&c.MinBackoff, "distributor.forwarding.min-backoff", 100*time.Millisecond, "Minimum delay before retrying a request which failed with a recoverable error.")
+	f.DurationVar(&c.MaxBackoff, "distributor.forwarding.max-backoff", 10*time.Second, "Maximum delay before retrying a request which failed with a recoverable error.")
+	f.IntVar(&c.MaxRetries, "distributor.forwarding.max-retries", 10, "Maximum number of retries of a request which failed with a recoverable error. Once the retries are exhausted, the series are written to the disk buffer if enabled, otherwise they are dropped. 0 to retry forever.")
+	f.StringVar(&c.DiskBufferDir, "distributor.forwarding.disk-buffer-dir", "", "Directory where the series which couldn't be kept in memory or forwarded are buffered, and forwarded later, including after a restart. Empty to disable the disk buffer.")
+	f.Int64Var(&c.DiskBufferMaxBytes, "distributor.forwarding.disk-buffer-max-bytes", 1<<30, "Maximum size of the disk buffer of each tenant and endpoint, in bytes. Series exceeding the size are dropped.")
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.QueueCapacity <= 0 {
+		return errInvalidQueueCapacity
+	}
+	if c.BatchSize <= 0 {
+		return errInvalidBatchSize
+	}
+	return nil
 }
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package forwarding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+const (
+	// endpointFilename is the file storing the URL of the endpoint in the directory of its disk buffer.
+	endpointFilename = "endpoint"
+	segmentExt       = ".segment"
+	tmpExt           = ".tmp"
+)
+
+var errDiskBufferFull = errors.New("the forwarding disk buffer is full")
+
+// diskBuffer stores the series of a tenant to forward to an endpoint on disk, in segment files each containing
+// a snappy-compressed remote write request. Segments are forwarded in the order they have been written.
+type diskBuffer struct {
+	dir      string
+	maxBytes int64
+
+	mtx      sync.Mutex
+	segments []segment
+	bytes    int64
+	nextSeq  uint64
+}
+
+type segment struct {
+	path string
+	seq  uint64
+	size int64
+}
+
+// diskBufferDir returns the directory of the disk buffer of the tenant and endpoint. The endpoint is hashed because
+// URLs can't be safely used as directory names.
+func diskBufferDir(root, tenant, endpoint string) string {
+	hash := sha256.Sum256([]byte(endpoint))
+	return filepath.Join(root, tenant, hex.EncodeToString(hash[:8]))
+}
+
+// openDiskBuffer opens the disk buffer of the tenant and endpoint, creating it if it doesn't exist yet.
+func openDiskBuffer(root, tenant, endpoint string, maxBytes int64) (*diskBuffer, error) {
+	dir := diskBufferDir(root, tenant, endpoint)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, endpointFilename), []byte(endpoint), 0o640); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &diskBuffer{dir: dir, maxBytes: maxBytes}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		// Leftovers of segments which were being written when the process stopped.
+		if strings.HasSuffix(e.Name(), tmpExt) {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !strings.HasSuffix(e.Name(), segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		d.segments = append(d.segments, segment{path: path, seq: seq, size: info.Size()})
+		d.bytes += info.Size()
+		if seq >= d.nextSeq {
+			d.nextSeq = seq + 1
+		}
+	}
+
+	sort.Slice(d.segments, func(i, j int) bool { return d.segments[i].seq < d.segments[j].seq })
+	return d, nil
+}
+
+// listDiskBuffers returns the tenants and endpoints which have a disk buffer in the root directory.
+func listDiskBuffers(root string) ([]queueKey, error) {
+	tenants, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []queueKey
+	for _, tenant := range tenants {
+		if !tenant.IsDir() {
+			continue
+		}
+
+		endpoints, err := os.ReadDir(filepath.Join(root, tenant.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range endpoints {
+			endpoint, err := os.ReadFile(filepath.Join(root, tenant.Name(), e.Name(), endpointFilename))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, queueKey{tenant: tenant.Name(), endpoint: string(endpoint)})
+		}
+	}
+	return keys, nil
+}
+
+// write writes the series to a new segment. It returns errDiskBufferFull if the segment would exceed the max size.
+func (d *diskBuffer) write(series []mimirpb.PreallocTimeseries) error {
+	data, err := (&mimirpb.WriteRequest{Timeseries: series}).Marshal()
+	if err != nil {
+		return err
+	}
+	data = snappy.Encode(nil, data)
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.maxBytes > 0 && d.bytes+int64(len(data)) > d.maxBytes {
+		return errDiskBufferFull
+	}
+
+	seg := segment{
+		path: filepath.Join(d.dir, fmt.Sprintf("%020d%s", d.nextSeq, segmentExt)),
+		seq:  d.nextSeq,
+		size: int64(len(data)),
+	}
+	if err := writeFileAtomic(seg.path, data); err != nil {
+		return err
+	}
+
+	d.nextSeq++
+	d.segments = append(d.segments, seg)
+	d.bytes += seg.size
+	return nil
+}
+
+// oldest returns the oldest segment, if any.
+func (d *diskBuffer) oldest() (segment, bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if len(d.segments) == 0 {
+		return segment{}, false
+	}
+	return d.segments[0], true
+}
+
+// read returns the series stored in the segment.
+func (d *diskBuffer) read(seg segment) ([]mimirpb.PreallocTimeseries, error) {
+	data, err := os.ReadFile(seg.path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = snappy.Decode(nil, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress the segment")
+	}
+
+	var req mimirpb.WriteRequest
+	if err := req.Unmarshal(data); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the segment")
+	}
+	return req.Timeseries, nil
+}
+
+// remove deletes the segment.
+func (d *diskBuffer) remove(seg segment) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for i, s := range d.segments {
+		if s.seq == seg.seq {
+			d.segments = append(d.segments[:i], d.segments[i+1:]...)
+			d.bytes -= s.size
+			break
+		}
+	}
+
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeAll deletes the disk buffer directory, including all its segments.
+func (d *diskBuffer) removeAll() error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.segments = nil
+	d.bytes = 0
+	return os.RemoveAll(d.dir)
+}
+
+// size returns the size of the segments, in bytes.
+func (d *diskBuffer) size() int64 {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.bytes
+}
+
+// writeFileAtomic writes the data to a temporary file which is then renamed, so that
+// a partially written file is never read as a segment.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + tmpExt
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -12,36 +12,49 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-kit/log"
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
+	"github.com/grafana/dskit/services"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
-	"github.com/weaveworks/common/httpgrpc"
+	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
-	"github.com/grafana/mimir/pkg/util/extract"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
+// queuesCleanupPeriod is how often the queues of the endpoints which are not in the tenants' rules anymore are removed.
+const queuesCleanupPeriod = time.Minute
+
 var errBadEndpointConfiguration = errors.New("bad endpoint configuration")
 
+// Limits are the per-tenant limits used by the forwarder.
+type Limits interface {
+	ForwardingRules(userID string) validation.ForwardingRules
+}
+
 type Forwarder interface {
+	services.Service
+
 	NewRequest(ctx context.Context, tenant string, rules validation.ForwardingRules) Request
 }
 
 type Request interface {
 	// Add adds a timeseries to the forwarding request.
 	// Samples which don't match any forwarding rule won't be added to the request.
+	// The timeseries is forwarded to the endpoint of each matching rule, once per endpoint.
 	// It returns a bool which indicates whether this timeseries should be sent to the Ingesters.
 	// A timeseries should be sent to the Ingester if any of the following conditions are true:
-	// - It has a labelset without a metric name, hence it can't match a forwarding rule.
-	// - There is no matching forwarding rule for the metric name of the timeseries.
-	// - There is a forwarding rule which defines that this metric should be forwarded and also pushed to the Ingesters.
+	// - There is no forwarding rule matching the labels of the timeseries.
+	// - There is a matching forwarding rule which defines that the timeseries should be forwarded and also pushed to the Ingesters.
+	// The timeseries is copied, so the caller can reuse it once Add returns.
 	Add(sample mimirpb.PreallocTimeseries) bool
 
-	// Send sends the timeseries which have been added to this forwarding request to the according endpoints.
-	// All errors returned via the returned error chan are http grpc errors.
+	// Send enqueues the timeseries which have been added to this forwarding request in the queues of the according
+	// endpoints, from which they are asynchronously forwarded. Failures to forward are handled by the queues, so the
+	// returned error chan is closed without any error once the timeseries have been enqueued.
 	// Send should only be called once, after it has been called this forwardingRequest must not be used anymore.
 	Send(ctx context.Context) <-chan error
 }
@@ -55,29 +68,54 @@ type pools struct {
 	snappy     sync.Pool
 }
 
+type queueKey struct {
+	tenant   string
+	endpoint string
+}
+
 type forwarder struct {
+	services.Service
+
 	cfg    Config
+	limits Limits
+	log    log.Logger
 	pools  pools
 	client http.Client
 
+	// ctx is the context of the queues, which is canceled when the forwarder stops.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	queuesMtx sync.Mutex
+	queues    map[queueKey]*queue
+	stopped   bool
+	wg        sync.WaitGroup
+
 	requestsTotal           *prometheus.CounterVec
 	requestLatencyHistogram *prometheus.HistogramVec
 	samplesTotal            *prometheus.CounterVec
+	droppedSamplesTotal     *prometheus.CounterVec
+	queueSeries             *prometheus.GaugeVec
+	queueLag                *prometheus.GaugeVec
+	diskBufferBytes         *prometheus.GaugeVec
 }
 
 // NewForwarder returns a new forwarder, if forwarding is disabled it returns nil.
-func NewForwarder(reg prometheus.Registerer, cfg Config) Forwarder {
+func NewForwarder(reg prometheus.Registerer, cfg Config, limits Limits, logger log.Logger) Forwarder {
 	if !cfg.Enabled {
 		return nil
 	}
 
-	return &forwarder{
-		cfg: cfg,
+	f := &forwarder{
+		cfg:    cfg,
+		limits: limits,
+		log:    logger,
 		pools: pools{
 			timeseries: sync.Pool{New: func() interface{} { return &[]mimirpb.PreallocTimeseries{} }},
 			protobuf:   sync.Pool{New: func() interface{} { return &[]byte{} }},
 			snappy:     sync.Pool{New: func() interface{} { return &[]byte{} }},
 		},
+		queues: map[queueKey]*queue{},
 
 		requestsTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Namespace: "cortex",
@@ -95,134 +133,221 @@ func NewForwarder(reg prometheus.Registerer, cfg Config) Forwarder {
 			Help:      "The client-side latency of requests to forward metrics made by the Distributor.",
 			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30},
 		}, []string{"user"}),
+		droppedSamplesTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_forward_dropped_samples_total",
+			Help:      "The total number of samples the Distributor dropped instead of forwarding them.",
+		}, []string{"user", "endpoint", "reason"}),
+		queueSeries: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "cortex",
+			Name:      "distributor_forward_queue_series",
+			Help:      "The number of series buffered in memory, waiting to be forwarded.",
+		}, []string{"user", "endpoint"}),
+		queueLag: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "cortex",
+			Name:      "distributor_forward_queue_lag_seconds",
+			Help:      "How long the oldest series buffered in memory has been waiting to be forwarded.",
+		}, []string{"user", "endpoint"}),
+		diskBufferBytes: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "cortex",
+			Name:      "distributor_forward_disk_buffer_bytes",
+			Help:      "The size of the series buffered on disk, waiting to be forwarded.",
+		}, []string{"user", "endpoint"}),
 	}
+
+	f.Service = services.NewTimerService(queuesCleanupPeriod, f.starting, f.iteration, f.stopping)
+	return f
 }
 
-func (r *forwarder) NewRequest(ctx context.Context, tenant string, rules validation.ForwardingRules) Request {
-	return &request{
-		ctx:    ctx,
-		client: &r.client, // http client should be re-used so open connections get re-used.
-		pools:  &r.pools,
+func (r *forwarder) starting(_ context.Context) error {
+	r.ctx, r.cancel = context.WithCancel(context.Background())
 
-		tsByEndpoint: make(map[string]*[]mimirpb.PreallocTimeseries),
-
-		rules:   rules,
-		timeout: r.cfg.RequestTimeout,
+	if r.cfg.DiskBufferDir == "" {
+		return nil
+	}
 
-		requests: r.requestsTotal.WithLabelValues(tenant),
-		samples:  r.samplesTotal.WithLabelValues(tenant),
-		latency:  r.requestLatencyHistogram.WithLabelValues(tenant),
+	// Resume forwarding the series buffered on disk before the restart.
+	buffered, err := listDiskBuffers(r.cfg.DiskBufferDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to list the forwarding disk buffers")
+	}
+	for _, key := range buffered {
+		r.getQueue(key.tenant, key.endpoint)
 	}
+	return nil
 }
 
-type request struct {
-	ctx    context.Context
-	client *http.Client
-	pools  *pools
+func (r *forwarder) iteration(_ context.Context) error {
+	r.removeStaleQueues()
+	return nil
+}
 
-	tsByEndpoint map[string]*[]mimirpb.PreallocTimeseries
+// removeStaleQueues stops and removes the queues of the endpoints which are not in the rules of their tenant anymore.
+// The series still buffered by these queues are dropped, because they're not meant to be forwarded anymore.
+func (r *forwarder) removeStaleQueues() {
+	var stale []*queue
 
-	// The rules which define:
-	// - which metrics get forwarded
-	// - where the metrics get forwarded to
-	// - whether the forwarded metrics should also be ingested (sent to ingesters)
-	rules   validation.ForwardingRules
-	timeout time.Duration
+	r.queuesMtx.Lock()
+	for key, q := range r.queues {
+		if !hasEndpoint(r.limits.ForwardingRules(key.tenant), key.endpoint) {
+			delete(r.queues, key)
+			stale = append(stale, q)
+		}
+	}
+	r.queuesMtx.Unlock()
 
-	requests prometheus.Counter
-	samples  prometheus.Counter
-	latency  prometheus.Observer
+	for _, q := range stale {
+		q.remove()
+	}
 }
 
-func (r *request) Add(sample mimirpb.PreallocTimeseries) bool {
-	metric, err := extract.UnsafeMetricNameFromLabelAdapters(sample.Labels)
-	if err != nil {
-		// The only possible error is due to no metric name being defined, in which case we won't forward the sample
-		// to anywhere but we should still send it to the Ingesters.
-		return true
+func hasEndpoint(rules validation.ForwardingRules, endpoint string) bool {
+	for _, rule := range rules {
+		if rule.Endpoint == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *forwarder) stopping(_ error) error {
+	r.queuesMtx.Lock()
+	r.stopped = true
+	r.queuesMtx.Unlock()
+
+	r.cancel()
+	r.wg.Wait()
+
+	// No more series can be enqueued, and the queues are not running anymore.
+	for _, q := range r.queues {
+		q.shutdown()
 	}
+	return nil
+}
+
+// getQueue returns the queue of the tenant and endpoint, creating it if it doesn't exist yet.
+// It returns nil if the forwarder is not running.
+func (r *forwarder) getQueue(tenant, endpoint string) *queue {
+	r.queuesMtx.Lock()
+	defer r.queuesMtx.Unlock()
 
-	rule, ok := r.rules[metric]
-	if !ok {
-		// There is no forwarding rule for this metric, send it to the Ingesters.
-		return true
+	if r.stopped || r.ctx == nil {
+		return nil
 	}
-	r.samples.Add(float64(len(sample.Samples)))
 
-	ts, ok := r.tsByEndpoint[rule.Endpoint]
-	if !ok {
-		ts = r.pools.timeseries.Get().(*[]mimirpb.PreallocTimeseries)
-		r.requests.Inc()
+	key := queueKey{tenant: tenant, endpoint: endpoint}
+	if q, ok := r.queues[key]; ok {
+		return q
 	}
 
-	*ts = append(*ts, sample)
-	r.tsByEndpoint[rule.Endpoint] = ts
+	ctx, cancel := context.WithCancel(r.ctx)
+	q := newQueue(r, tenant, endpoint, cancel)
+	r.queues[key] = q
 
-	return rule.Ingest
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(q.done)
+		q.run(ctx)
+	}()
+	return q
 }
 
-type recoverableError struct {
-	error
-}
+func (r *forwarder) NewRequest(ctx context.Context, tenant string, rules validation.ForwardingRules) Request {
+	return &request{
+		forwarder: r,
+		tenant:    tenant,
+		pools:     &r.pools,
+
+		tsByEndpoint: make(map[string]*[]mimirpb.PreallocTimeseries),
 
-func (r *request) Send(ctx context.Context) <-chan error {
-	errCh := make(chan error, 1)
+		rules: rules,
 
-	// Early return if there's no data to send.
-	if len(r.tsByEndpoint) == 0 {
-		close(errCh)
-		return errCh
+		samples: r.samplesTotal.WithLabelValues(tenant),
 	}
+}
 
-	var wg sync.WaitGroup
-	wg.Add(len(r.tsByEndpoint))
+type request struct {
+	forwarder *forwarder
+	tenant    string
+	pools     *pools
 
-	// Can't use concurrency.ForEachJob because we don't want to cancel the other jobs if one errors.
-	errorsByEndpoint := make(map[string]error, len(r.tsByEndpoint))
-	var errorsMtx sync.Mutex
-	for endpoint, ts := range r.tsByEndpoint {
-		go func(endpoint string, ts []mimirpb.PreallocTimeseries) {
-			defer wg.Done()
+	tsByEndpoint map[string]*[]mimirpb.PreallocTimeseries
 
-			errorsMtx.Lock()
-			defer errorsMtx.Unlock()
-			errorsByEndpoint[endpoint] = r.sendToEndpoint(ctx, endpoint, ts)
-		}(endpoint, *ts)
-	}
+	// The rules which define:
+	// - which metrics get forwarded
+	// - where the metrics get forwarded to
+	// - whether the forwarded metrics should also be ingested (sent to ingesters)
+	rules validation.ForwardingRules
 
-	go func() {
-		defer r.cleanup()
-		defer close(errCh)
+	samples prometheus.Counter
+}
 
-		wg.Wait()
+func (r *request) Add(sample mimirpb.PreallocTimeseries) bool {
+	var (
+		// The series is copied because it's forwarded after the push request has completed.
+		// The copy is shared by the endpoints, which only read it.
+		copied    mimirpb.PreallocTimeseries
+		endpoints []string
+		matched   bool
+		ingest    bool
+	)
+
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if !matches(rule.Matchers(), sample.Labels) {
+			continue
+		}
+		matched = true
+		ingest = ingest || rule.Ingest
 
-		var nonRecoverable error
-		// No need to get errorsMtx because we already waited for all routines which might modify it to end.
-		for endpoint, err := range errorsByEndpoint {
-			if err == nil {
-				continue
-			}
+		if containsString(endpoints, rule.Endpoint) {
+			continue
+		}
+		endpoints = append(endpoints, rule.Endpoint)
 
-			if errors.As(err, &recoverableError{}) {
-				// If there is at least one recoverable error we want to return the recoverable error.
-				errCh <- httpgrpc.Errorf(http.StatusInternalServerError, "endpoint %s: %s", endpoint, err.Error())
-				return
-			}
+		if copied.TimeSeries == nil {
+			copied = copyTimeseries(sample)
+		}
+		r.samples.Add(float64(len(sample.Samples)))
 
-			nonRecoverable = httpgrpc.Errorf(http.StatusBadRequest, "endpoint %s: %s", endpoint, err.Error())
+		ts, ok := r.tsByEndpoint[rule.Endpoint]
+		if !ok {
+			ts = r.pools.timeseries.Get().(*[]mimirpb.PreallocTimeseries)
 		}
+		*ts = append(*ts, copied)
+		r.tsByEndpoint[rule.Endpoint] = ts
+	}
+
+	// The series is sent to the Ingesters if there's no forwarding rule for it.
+	return !matched || ingest
+}
 
-		if nonRecoverable != nil {
-			errCh <- nonRecoverable
+func (r *request) Send(_ context.Context) <-chan error {
+	errCh := make(chan error)
+	defer close(errCh)
+	defer r.cleanup()
+
+	now := time.Now()
+	for endpoint, ts := range r.tsByEndpoint {
+		q := r.forwarder.getQueue(r.tenant, endpoint)
+		if q == nil {
+			r.forwarder.droppedSamplesTotal.WithLabelValues(r.tenant, endpoint, reasonShutdown).Add(float64(countSamples(*ts)))
+			continue
 		}
-	}()
+		q.push(*ts, now)
+	}
 
 	return errCh
 }
 
-// sendToEndpoint sends the given timeseries to the given endpoint.
+type recoverableError struct {
+	error
+}
+
+// sendToEndpoint sends the given timeseries of the tenant to the given endpoint.
 // All returned errors which are recoverable are of the type recoverableError.
-func (r *request) sendToEndpoint(ctx context.Context, endpoint string, ts []mimirpb.PreallocTimeseries) error {
+func (r *forwarder) sendToEndpoint(ctx context.Context, tenant, endpoint string, ts []mimirpb.PreallocTimeseries) error {
 	protoBufBytes := (*r.pools.protobuf.Get().(*[]byte))[:0]
 	protoBuf := proto.NewBuffer(protoBufBytes)
 	err := protoBuf.Marshal(&mimirpb.WriteRequest{Timeseries: ts})
@@ -236,7 +361,7 @@ func (r *request) sendToEndpoint(ctx context.Context, endpoint string, ts []mimi
 	snappyBuf = snappy.Encode(snappyBuf[:cap(snappyBuf)], protoBufBytes)
 	defer r.pools.snappy.Put(&snappyBuf)
 
-	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.RequestTimeout)
 	defer cancel()
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(snappyBuf))
@@ -251,9 +376,10 @@ func (r *request) sendToEndpoint(ctx context.Context, endpoint string, ts []mimi
 	httpReq.Header.Add("Content-Encoding", "snappy")
 	httpReq.Header.Set("Content-Type", "application/x-protobuf")
 
+	r.requestsTotal.WithLabelValues(tenant).Inc()
 	beforeTs := time.Now()
 	httpResp, err := r.client.Do(httpReq)
-	r.latency.Observe(time.Since(beforeTs).Seconds())
+	r.requestLatencyHistogram.WithLabelValues(tenant).Observe(time.Since(beforeTs).Seconds())
 	if err != nil {
 		// Errors from Client.Do are from (for example) network errors, so are recoverable.
 		return recoverableError{err}
@@ -288,3 +414,64 @@ func (r *request) cleanup() {
 
 	r.tsByEndpoint = nil
 }
+
+// matches returns whether the labels match all the matchers.
+func matches(matchers []*labels.Matcher, lbls []mimirpb.LabelAdapter) bool {
+	for _, m := range matchers {
+		value := ""
+		for _, l := range lbls {
+			if l.Name == m.Name {
+				value = l.Value
+				break
+			}
+		}
+		if !m.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// copyTimeseries returns a copy of the timeseries which doesn't reference the buffers of the push request.
+func copyTimeseries(ts mimirpb.PreallocTimeseries) mimirpb.PreallocTimeseries {
+	out := &mimirpb.TimeSeries{
+		Labels:  copyLabels(ts.Labels),
+		Samples: append([]mimirpb.Sample(nil), ts.Samples...),
+	}
+	if len(ts.Exemplars) > 0 {
+		out.Exemplars = make([]mimirpb.Exemplar, 0, len(ts.Exemplars))
+		for _, e := range ts.Exemplars {
+			out.Exemplars = append(out.Exemplars, mimirpb.Exemplar{
+				Labels:      copyLabels(e.Labels),
+				Value:       e.Value,
+				TimestampMs: e.TimestampMs,
+			})
+		}
+	}
+	return mimirpb.PreallocTimeseries{TimeSeries: out}
+}
+
+func copyLabels(lbls []mimirpb.LabelAdapter) []mimirpb.LabelAdapter {
+	out := make([]mimirpb.LabelAdapter, 0, len(lbls))
+	for _, l := range lbls {
+		out = append(out, mimirpb.LabelAdapter{Name: string([]byte(l.Name)), Value: string([]byte(l.Value))})
+	}
+	return out
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func countSamples(ts []mimirpb.PreallocTimeseries) int {
+	count := 0
+	for _, s := range ts {
+		count += len(s.Samples)
+	}
+	return count
+}
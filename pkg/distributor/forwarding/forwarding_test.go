@@ -8,43 +8,98 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-kit/log"
 	"github.com/golang/snappy"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
-	"github.com/weaveworks/common/httpgrpc"
+	"go.uber.org/atomic"
+	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 var testConfig = Config{
-	Enabled:        true,
-	RequestTimeout: time.Second,
+	Enabled:           true,
+	RequestTimeout:    time.Second,
+	QueueCapacity:     1000,
+	BatchSize:         100,
+	BatchSendDeadline: 10 * time.Millisecond,
+	MinBackoff:        time.Millisecond,
+	MaxBackoff:        5 * time.Millisecond,
+	MaxRetries:        3,
+}
+
+// staticLimits are the forwarding rules of the tenants, which can be changed.
+type staticLimits struct {
+	mtx   sync.Mutex
+	rules map[string]validation.ForwardingRules
+}
+
+func (l *staticLimits) ForwardingRules(userID string) validation.ForwardingRules {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.rules[userID]
+}
+
+func (l *staticLimits) setForwardingRules(userID string, rules validation.ForwardingRules) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.rules == nil {
+		l.rules = map[string]validation.ForwardingRules{}
+	}
+	l.rules[userID] = rules
+}
+
+func parseRules(tb testing.TB, cfg string, args ...interface{}) validation.ForwardingRules {
+	var rules validation.ForwardingRules
+	require.NoError(tb, yaml.UnmarshalStrict([]byte(fmt.Sprintf(cfg, args...)), &rules))
+	return rules
+}
+
+func newTestForwarder(t *testing.T, reg prometheus.Registerer, cfg Config) *forwarder {
+	return newTestForwarderWithLimits(t, reg, cfg, &staticLimits{})
+}
+
+func newTestForwarderWithLimits(t *testing.T, reg prometheus.Registerer, cfg Config, limits Limits) *forwarder {
+	f := NewForwarder(reg, cfg, limits, log.NewNopLogger()).(*forwarder)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), f))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), f)
+	})
+	return f
 }
 
 func TestForwardingSamplesSuccessfully(t *testing.T) {
 	const tenant = "tenant"
 	now := time.Now().UnixMilli()
 
-	url1, reqs1, bodies1, close1 := newTestServer(t, 200, true)
-	defer close1()
+	srv1 := newTestServer(t, 200)
+	defer srv1.Close()
 
-	url2, reqs2, bodies2, close2 := newTestServer(t, 200, true)
-	defer close2()
+	srv2 := newTestServer(t, 200)
+	defer srv2.Close()
 
 	reg := prometheus.NewPedanticRegistry()
-	forwarder := NewForwarder(reg, testConfig)
+	forwarder := newTestForwarder(t, reg, testConfig)
 
-	rules := validation.ForwardingRules{
-		"metric1": validation.ForwardingRule{Endpoint: url1, Ingest: false},
-		"metric2": validation.ForwardingRule{Endpoint: url2, Ingest: true},
-	}
+	rules := parseRules(t, `
+- match: metric1
+  endpoint: %s
+  ingest: false
+- match: metric2
+  endpoint: %s
+  ingest: true
+`, srv1.URL, srv2.URL)
 
 	forwardingReq := forwarder.NewRequest(context.Background(), tenant, rules)
 
@@ -63,21 +118,27 @@ func TestForwardingSamplesSuccessfully(t *testing.T) {
 	errCh := forwardingReq.Send(context.Background())
 	require.NoError(t, <-errCh)
 
-	for _, req := range append(*reqs1, *reqs2...) {
+	// The samples are forwarded asynchronously.
+	srv1.waitForRequests(t, 1)
+	srv2.waitForRequests(t, 1)
+
+	for _, req := range append(srv1.receivedRequests(), srv2.receivedRequests()...) {
 		require.Equal(t, req.Header.Get("Content-Encoding"), "snappy")
 		require.Equal(t, req.Header.Get("Content-Type"), "application/x-protobuf")
 	}
 
-	require.Len(t, (*bodies1), 1)
-	receivedReq := decodeBody(t, (*bodies1)[0])
+	bodies1 := srv1.receivedBodies()
+	require.Len(t, bodies1, 1)
+	receivedReq := decodeBody(t, bodies1[0])
 	require.Len(t, receivedReq.Timeseries, 2)
 	requireLabelsEqual(t, receivedReq.Timeseries[0].Labels, "__name__", "metric1", "some_label", "foo")
 	requireSamplesEqual(t, receivedReq.Timeseries[0].Samples, now, 1)
 	requireLabelsEqual(t, receivedReq.Timeseries[1].Labels, "__name__", "metric1", "some_label", "bar")
 	requireSamplesEqual(t, receivedReq.Timeseries[1].Samples, now, 2)
 
-	require.Len(t, (*bodies2), 1)
-	receivedReq = decodeBody(t, (*bodies2)[0])
+	bodies2 := srv2.receivedBodies()
+	require.Len(t, bodies2, 1)
+	receivedReq = decodeBody(t, bodies2[0])
 	require.Len(t, receivedReq.Timeseries, 2)
 	requireLabelsEqual(t, receivedReq.Timeseries[0].Labels, "__name__", "metric2", "some_label", "foo")
 	requireSamplesEqual(t, receivedReq.Timeseries[0].Samples, now, 3)
@@ -101,91 +162,332 @@ func TestForwardingSamplesSuccessfully(t *testing.T) {
 	))
 }
 
-func TestForwardingSamplesWithDifferentErrors(t *testing.T) {
-	type errorType uint16
-	const (
-		errNone           errorType = 0
-		errNonRecoverable errorType = 400
-		errRecoverable    errorType = 500
-	)
+func TestForwardingWithMatchers(t *testing.T) {
+	srv := newTestServer(t, 200)
+	defer srv.Close()
 
-	type testcase struct {
-		name              string
-		remoteStatusCodes []int
-		expectedError     errorType
-	}
+	var limits validation.Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(fmt.Sprintf(`
+forwarding_rules:
+  - match: 'metric1{env="prod", job=~"api|db"}'
+    endpoint: %s
+`, srv.URL)), &limits))
 
-	tcs := []testcase{
-		{
-			name:              "non-recoverable and successful codes should result in non-recoverable",
-			remoteStatusCodes: []int{200, 400, 200},
-			expectedError:     errNonRecoverable,
-		}, {
-			name:              "recoverable, non-recoverable and successful codes should result in recoverable (1)",
-			remoteStatusCodes: []int{200, 400, 500},
-			expectedError:     errRecoverable,
-		}, {
-			name:              "recoverable, non-recoverable and successful codes should result in recoverable (2)",
-			remoteStatusCodes: []int{500, 400, 200},
-			expectedError:     errRecoverable,
-		}, {
-			name:              "successful codes should result in no error",
-			remoteStatusCodes: []int{200, 200},
-			expectedError:     errNone,
-		}, {
-			name:              "codes which are not divisible by 100 (1)",
-			remoteStatusCodes: []int{204, 401, 200},
-			expectedError:     errNonRecoverable,
-		}, {
-			name:              "codes which are not divisible by 100 (2)",
-			remoteStatusCodes: []int{202, 403, 502},
-			expectedError:     errRecoverable,
-		}, {
-			name:              "codes which are not divisible by 100 (3)",
-			remoteStatusCodes: []int{504, 404, 201},
-			expectedError:     errRecoverable,
+	forwarder := newTestForwarder(t, nil, testConfig)
+	now := time.Now().UnixMilli()
+
+	req := forwarder.NewRequest(context.Background(), "tenant", limits.ForwardingRules)
+	require.False(t, req.Add(newSample(t, now, 1, "__name__", "metric1", "env", "prod", "job", "api")))
+	require.True(t, req.Add(newSample(t, now, 2, "__name__", "metric1", "env", "dev", "job", "api")))
+	require.True(t, req.Add(newSample(t, now, 3, "__name__", "metric1", "job", "api")))
+	require.True(t, req.Add(newSample(t, now, 4, "__name__", "metric2", "env", "prod", "job", "api")))
+	require.NoError(t, <-req.Send(context.Background()))
+
+	srv.waitForRequests(t, 1)
+	receivedReq := decodeBody(t, srv.receivedBodies()[0])
+	require.Len(t, receivedReq.Timeseries, 1)
+	requireLabelsEqual(t, receivedReq.Timeseries[0].Labels, "__name__", "metric1", "env", "prod", "job", "api")
+
+	err := yaml.UnmarshalStrict([]byte(`
+forwarding_rules:
+  - match: 'metric1{env="prod"'
+    endpoint: http://localhost
+`), &limits)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid forwarding rule match")
+}
+
+func TestForwardingWithSeveralMatchingRules(t *testing.T) {
+	srv1 := newTestServer(t, 200)
+	defer srv1.Close()
+
+	srv2 := newTestServer(t, 200)
+	defer srv2.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	forwarder := newTestForwarder(t, reg, testConfig)
+	now := time.Now().UnixMilli()
+
+	rules := parseRules(t, `
+- match: '{env="prod"}'
+  endpoint: %[1]s
+- match: '{job="api"}'
+  endpoint: %[1]s
+- match: metric1
+  endpoint: %[2]s
+  ingest: true
+`, srv1.URL, srv2.URL)
+
+	req := forwarder.NewRequest(context.Background(), "tenant", rules)
+	// Matches the rules of both endpoints, and one of them ingests it.
+	require.True(t, req.Add(newSample(t, now, 1, "__name__", "metric1", "env", "prod", "job", "api")))
+	// Matches only rules which don't ingest it, and is forwarded once to their endpoint.
+	require.False(t, req.Add(newSample(t, now, 2, "__name__", "metric2", "env", "prod", "job", "api")))
+	// Matches no rule.
+	require.True(t, req.Add(newSample(t, now, 3, "__name__", "metric2", "env", "dev")))
+	require.NoError(t, <-req.Send(context.Background()))
+
+	srv1.waitForRequests(t, 1)
+	srv2.waitForRequests(t, 1)
+
+	receivedReq := decodeBody(t, srv1.receivedBodies()[0])
+	require.Len(t, receivedReq.Timeseries, 2)
+	requireLabelsEqual(t, receivedReq.Timeseries[0].Labels, "__name__", "metric1", "env", "prod", "job", "api")
+	requireLabelsEqual(t, receivedReq.Timeseries[1].Labels, "__name__", "metric2", "env", "prod", "job", "api")
+
+	receivedReq = decodeBody(t, srv2.receivedBodies()[0])
+	require.Len(t, receivedReq.Timeseries, 1)
+	requireLabelsEqual(t, receivedReq.Timeseries[0].Labels, "__name__", "metric1", "env", "prod", "job", "api")
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_distributor_forward_samples_total The total number of samples the Distributor forwarded.
+		# TYPE cortex_distributor_forward_samples_total counter
+		cortex_distributor_forward_samples_total{user="tenant"} 3
+	`), "cortex_distributor_forward_samples_total"))
+}
+
+func TestForwardingSamplesWithDifferentErrors(t *testing.T) {
+	const tenant = "tenant"
+
+	tcs := map[string]struct {
+		// failures is the number of requests failing with status before the endpoint succeeds.
+		status           int
+		failures         int
+		expectedRequests int
+		expectedDropped  string
+	}{
+		"non-recoverable errors are not retried and the samples are dropped": {
+			status:           400,
+			failures:         100,
+			expectedRequests: 1,
+			expectedDropped:  reasonNonRecoverable,
+		},
+		"recoverable errors are retried until the retries are exhausted": {
+			status:           500,
+			failures:         100,
+			expectedRequests: testConfig.MaxRetries,
+			expectedDropped:  reasonRetriesExhausted,
+		},
+		"too many requests errors are retried": {
+			status:           429,
+			failures:         100,
+			expectedRequests: testConfig.MaxRetries,
+			expectedDropped:  reasonRetriesExhausted,
+		},
+		"recoverable errors are retried until the request succeeds": {
+			status:           503,
+			failures:         2,
+			expectedRequests: 3,
 		},
 	}
 
-	const tenant = "tenant"
-	forwarder := NewForwarder(nil, testConfig)
-	for _, tc := range tcs {
-		t.Run(tc.name, func(t *testing.T) {
-			urls := make([]string, len(tc.remoteStatusCodes))
-			closers := make([]func(), len(tc.remoteStatusCodes))
-			for i, code := range tc.remoteStatusCodes {
-				urls[i], _, _, closers[i] = newTestServer(t, code, false)
-				defer closers[i]()
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var requests atomic.Int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if requests.Inc() <= int64(tc.failures) {
+					http.Error(w, "failure", tc.status)
+				}
+			}))
+			defer srv.Close()
+
+			reg := prometheus.NewPedanticRegistry()
+			forwarder := newTestForwarder(t, reg, testConfig)
+
+			rules := parseRules(t, `[{match: metric, endpoint: %s}]`, srv.URL)
+			req := forwarder.NewRequest(context.Background(), tenant, rules)
+			req.Add(newSample(t, time.Now().UnixMilli(), 1, "__name__", "metric"))
+
+			// Errors are handled asynchronously, so they're never returned to the client.
+			require.NoError(t, <-req.Send(context.Background()))
+
+			test.Poll(t, time.Second, int64(tc.expectedRequests), func() interface{} {
+				return requests.Load()
+			})
+
+			expectedMetrics := ""
+			if tc.expectedDropped != "" {
+				expectedMetrics = fmt.Sprintf(`
+				# HELP cortex_distributor_forward_dropped_samples_total The total number of samples the Distributor dropped instead of forwarding them.
+				# TYPE cortex_distributor_forward_dropped_samples_total counter
+				cortex_distributor_forward_dropped_samples_total{endpoint="%s",reason="%s",user="tenant"} 1
+				`, srv.URL, tc.expectedDropped)
 			}
+			test.Poll(t, time.Second, nil, func() interface{} {
+				return testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "cortex_distributor_forward_dropped_samples_total")
+			})
+		})
+	}
+}
 
-			rules := make(validation.ForwardingRules)
-			metrics := make([]string, len(tc.remoteStatusCodes))
-			for i, url := range urls {
-				metric := fmt.Sprintf("metric%d", i)
-				metrics = append(metrics, metric)
-				rules[metric] = validation.ForwardingRule{Endpoint: url}
-			}
+func TestForwardingQueueFull(t *testing.T) {
+	srv := newTestServer(t, 200)
+	defer srv.Close()
 
-			now := time.Now().UnixMilli()
-			forwardingReq := forwarder.NewRequest(context.Background(), tenant, rules)
+	cfg := testConfig
+	cfg.QueueCapacity = 2
+	cfg.BatchSendDeadline = time.Hour
 
-			for _, metric := range metrics {
-				forwardingReq.Add(newSample(t, now, 1, "__name__", metric))
-			}
+	reg := prometheus.NewPedanticRegistry()
+	forwarder := newTestForwarder(t, reg, cfg)
 
-			errCh := forwardingReq.Send(context.Background())
-			switch tc.expectedError {
-			case errNone:
-				require.Nil(t, <-errCh)
-			case errRecoverable, errNonRecoverable:
-				err := <-errCh
-				require.NotNil(t, err)
-				resp, ok := httpgrpc.HTTPResponseFromError(err)
-				require.True(t, ok)
-				require.Equal(t, tc.expectedError, errorType(resp.Code))
-			}
-		})
+	rules := parseRules(t, `[{match: metric, endpoint: %s}]`, srv.URL)
+	req := forwarder.NewRequest(context.Background(), "tenant", rules)
+	for i := 0; i < 5; i++ {
+		req.Add(newSample(t, time.Now().UnixMilli(), float64(i), "__name__", "metric", "series", strconv.Itoa(i)))
+	}
+	require.NoError(t, <-req.Send(context.Background()))
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+		# HELP cortex_distributor_forward_dropped_samples_total The total number of samples the Distributor dropped instead of forwarding them.
+		# TYPE cortex_distributor_forward_dropped_samples_total counter
+		cortex_distributor_forward_dropped_samples_total{endpoint="%[1]s",reason="queue_full",user="tenant"} 3
+		# HELP cortex_distributor_forward_queue_series The number of series buffered in memory, waiting to be forwarded.
+		# TYPE cortex_distributor_forward_queue_series gauge
+		cortex_distributor_forward_queue_series{endpoint="%[1]s",user="tenant"} 2
+	`, srv.URL)), "cortex_distributor_forward_dropped_samples_total", "cortex_distributor_forward_queue_series"))
+
+	// The series buffered in memory are forwarded at shutdown.
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), forwarder))
+	require.Len(t, srv.receivedRequests(), 1)
+}
+
+func TestForwardingDiskBuffer(t *testing.T) {
+	var healthy atomic.Bool
+	var received atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		received.Add(int64(len(decodeBody(t, body).Timeseries)))
+	}))
+	defer srv.Close()
+
+	cfg := testConfig
+	cfg.QueueCapacity = 2
+	cfg.MaxRetries = 1
+	cfg.DiskBufferDir = t.TempDir()
+
+	reg := prometheus.NewPedanticRegistry()
+	forwarder := newTestForwarder(t, reg, cfg)
+
+	rules := parseRules(t, `[{match: metric, endpoint: %s}]`, srv.URL)
+	req := forwarder.NewRequest(context.Background(), "tenant", rules)
+	for i := 0; i < 5; i++ {
+		req.Add(newSample(t, time.Now().UnixMilli(), float64(i), "__name__", "metric", "series", strconv.Itoa(i)))
+	}
+	require.NoError(t, <-req.Send(context.Background()))
+
+	// The series exceeding the queue capacity are spilled to disk, and the ones buffered in memory are
+	// spilled to disk at shutdown.
+	queue := forwarder.getQueue("tenant", srv.URL)
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), forwarder))
+	require.Empty(t, queue.entries)
+	require.Len(t, queue.disk.segments, 2)
+	require.Zero(t, received.Load())
+
+	// The series buffered on disk are forwarded after a restart.
+	healthy.Store(true)
+	forwarder = newTestForwarder(t, prometheus.NewPedanticRegistry(), cfg)
+	test.Poll(t, time.Second, int64(5), func() interface{} {
+		return received.Load()
+	})
+	test.Poll(t, time.Second, int64(0), func() interface{} {
+		return forwarder.getQueue("tenant", srv.URL).disk.size()
+	})
+}
+
+func TestForwardingQueueExceedingCapacityAfterRequeue(t *testing.T) {
+	cfg := testConfig
+	cfg.QueueCapacity = 2
+
+	reg := prometheus.NewPedanticRegistry()
+	f := NewForwarder(reg, cfg, &staticLimits{}, log.NewNopLogger()).(*forwarder)
+	q := newQueue(f, "tenant", "http://localhost", func() {})
+
+	// A failed batch put back at shutdown can make the queue exceed its capacity.
+	q.requeue([]mimirpb.PreallocTimeseries{
+		newSample(t, 1, 1, "__name__", "metric", "series", "1"),
+		newSample(t, 1, 2, "__name__", "metric", "series", "2"),
+		newSample(t, 1, 3, "__name__", "metric", "series", "3"),
+	})
+	q.push([]mimirpb.PreallocTimeseries{newSample(t, 1, 4, "__name__", "metric", "series", "4")}, time.Now())
+
+	require.Len(t, q.entries, 3)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_distributor_forward_dropped_samples_total The total number of samples the Distributor dropped instead of forwarding them.
+		# TYPE cortex_distributor_forward_dropped_samples_total counter
+		cortex_distributor_forward_dropped_samples_total{endpoint="http://localhost",reason="queue_full",user="tenant"} 1
+	`), "cortex_distributor_forward_dropped_samples_total"))
+}
+
+func TestForwardingRemovesQueuesOfRemovedEndpoints(t *testing.T) {
+	srv1 := newTestServer(t, 200)
+	defer srv1.Close()
+
+	srv2 := newTestServer(t, 200)
+	defer srv2.Close()
+
+	cfg := testConfig
+	cfg.BatchSendDeadline = time.Hour
+	cfg.DiskBufferDir = t.TempDir()
+
+	limits := &staticLimits{}
+	limits.setForwardingRules("tenant", parseRules(t, `
+- match: metric1
+  endpoint: %s
+- match: metric2
+  endpoint: %s
+`, srv1.URL, srv2.URL))
+
+	reg := prometheus.NewPedanticRegistry()
+	forwarder := newTestForwarderWithLimits(t, reg, cfg, limits)
+
+	req := forwarder.NewRequest(context.Background(), "tenant", limits.ForwardingRules("tenant"))
+	req.Add(newSample(t, time.Now().UnixMilli(), 1, "__name__", "metric1"))
+	req.Add(newSample(t, time.Now().UnixMilli(), 2, "__name__", "metric2"))
+	require.NoError(t, <-req.Send(context.Background()))
+
+	removed := forwarder.getQueue("tenant", srv1.URL)
+	require.NoError(t, removed.disk.write([]mimirpb.PreallocTimeseries{newSample(t, time.Now().UnixMilli(), 3, "__name__", "metric1")}))
+
+	// The queue of the endpoint which has been removed from the rules is stopped, and its series are dropped.
+	limits.setForwardingRules("tenant", parseRules(t, `[{match: metric2, endpoint: %s}]`, srv2.URL))
+	forwarder.removeStaleQueues()
+
+	select {
+	case <-removed.done:
+	default:
+		require.Fail(t, "the queue of the removed endpoint is still running")
 	}
+	require.NoDirExists(t, diskBufferDir(cfg.DiskBufferDir, "tenant", srv1.URL))
+	require.DirExists(t, diskBufferDir(cfg.DiskBufferDir, "tenant", srv2.URL))
+
+	forwarder.queuesMtx.Lock()
+	require.Len(t, forwarder.queues, 1)
+	require.Contains(t, forwarder.queues, queueKey{tenant: "tenant", endpoint: srv2.URL})
+	forwarder.queuesMtx.Unlock()
+
+	// The series pushed with the previous rules to the removed queue are dropped.
+	removed.push([]mimirpb.PreallocTimeseries{newSample(t, time.Now().UnixMilli(), 4, "__name__", "metric1")}, time.Now())
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+		# HELP cortex_distributor_forward_dropped_samples_total The total number of samples the Distributor dropped instead of forwarding them.
+		# TYPE cortex_distributor_forward_dropped_samples_total counter
+		cortex_distributor_forward_dropped_samples_total{endpoint="%[1]s",reason="endpoint_removed",user="tenant"} 2
+		# HELP cortex_distributor_forward_queue_series The number of series buffered in memory, waiting to be forwarded.
+		# TYPE cortex_distributor_forward_queue_series gauge
+		cortex_distributor_forward_queue_series{endpoint="%[2]s",user="tenant"} 1
+	`, srv1.URL, srv2.URL)), "cortex_distributor_forward_dropped_samples_total", "cortex_distributor_forward_queue_series"))
+
+	// The series of the remaining queue are spilled to disk at shutdown, and nothing is forwarded to the removed endpoint.
+	remaining := forwarder.getQueue("tenant", srv2.URL)
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), forwarder))
+	require.Len(t, remaining.disk.segments, 1)
+	require.Empty(t, srv1.receivedRequests())
 }
 
 func newSample(tb testing.TB, time int64, value float64, labelValuePairs ...string) mimirpb.PreallocTimeseries {
@@ -229,28 +531,53 @@ func requireSamplesEqual(t *testing.T, gotSamples []mimirpb.Sample, wantTimeValu
 	}
 }
 
-func newTestServer(tb testing.TB, status int, record bool) (string, *[]*http.Request, *[][]byte, func()) {
-	var requests []*http.Request
-	var bodies [][]byte
-	var mtx sync.Mutex
+// recordingServer is a remote write endpoint recording the requests it receives.
+type recordingServer struct {
+	URL   string
+	Close func()
+
+	mtx      sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
 
+func newTestServer(tb testing.TB, status int) *recordingServer {
+	s := &recordingServer{}
 	srv := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			if record {
-				reqBody, err := ioutil.ReadAll(req.Body)
-				require.NoError(tb, err)
-
-				mtx.Lock()
-				defer mtx.Unlock()
-				requests = append(requests, req)
-				bodies = append(bodies, reqBody)
-			}
+			reqBody, err := ioutil.ReadAll(req.Body)
+			require.NoError(tb, err)
+
+			s.mtx.Lock()
+			s.requests = append(s.requests, req)
+			s.bodies = append(s.bodies, reqBody)
+			s.mtx.Unlock()
 
 			http.Error(w, "", status)
 		}),
 	)
 
-	return srv.URL, &requests, &bodies, srv.Close
+	s.URL, s.Close = srv.URL, srv.Close
+	return s
+}
+
+func (s *recordingServer) receivedRequests() []*http.Request {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+func (s *recordingServer) receivedBodies() [][]byte {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([][]byte(nil), s.bodies...)
+}
+
+// waitForRequests waits until the server received the expected number of requests.
+func (s *recordingServer) waitForRequests(t *testing.T, expected int) {
+	test.Poll(t, time.Second, expected, func() interface{} {
+		return len(s.receivedRequests())
+	})
 }
 
 func decodeBody(t *testing.T, body []byte) mimirpb.WriteRequest {
@@ -271,17 +598,25 @@ func BenchmarkRemoteWriteForwarding(b *testing.B) {
 	ctx := context.Background()
 
 	samples := make([]mimirpb.PreallocTimeseries, samplesPerReq)
-	rules := make(validation.ForwardingRules)
 	for i := 0; i < samplesPerReq; i++ {
-		metric := fmt.Sprintf("metric%03d", i)
-		samples[i] = newSample(b, now, 1, "__name__", metric)
-		rules[metric] = validation.ForwardingRule{Endpoint: "http://localhost/"}
+		samples[i] = newSample(b, now, 1, "__name__", fmt.Sprintf("metric%03d", i))
+	}
+
+	// Each rule matches the series of 100 metrics.
+	cfg := ""
+	for i := 0; i < 10; i++ {
+		cfg += fmt.Sprintf("- {match: '{__name__=~\"metric%d.*\"}', endpoint: 'http://localhost/'}\n", i)
 	}
+	rules := parseRules(b, cfg)
 
-	forwarder := NewForwarder(nil, testConfig).(*forwarder)
+	forwarder := NewForwarder(nil, testConfig, &staticLimits{}, log.NewNopLogger()).(*forwarder)
 
 	// No-op client, we don't want the benchmark to be skewed by TCP performance
 	forwarder.client = http.Client{Transport: &noopRoundTripper{}}
+	require.NoError(b, services.StartAndAwaitRunning(ctx, forwarder))
+	b.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(ctx, forwarder)
+	})
 
 	b.ResetTimer()
 	b.ReportAllocs()
@@ -0,0 +1,336 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package forwarding
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// Reasons why forwarded samples are dropped.
+const (
+	reasonQueueFull        = "queue_full"
+	reasonNonRecoverable   = "non_recoverable_error"
+	reasonRetriesExhausted = "retries_exhausted"
+	reasonShutdown         = "shutdown"
+	reasonDiskBuffer       = "disk_buffer_error"
+	reasonEndpointRemoved  = "endpoint_removed"
+)
+
+// queue buffers the series of a tenant to forward to an endpoint, and forwards them in batches.
+// The series which don't fit in memory or can't be forwarded are spilled to the disk buffer, if enabled,
+// which is forwarded before the series buffered in memory because it usually contains the oldest series.
+type queue struct {
+	forwarder *forwarder
+	tenant    string
+	endpoint  string
+	log       log.Logger
+
+	// disk is nil if the disk buffer is disabled.
+	disk *diskBuffer
+
+	mtx     sync.Mutex
+	entries []entry
+	// removed is set once the endpoint has been removed from the tenant's rules, after which no series are enqueued.
+	removed bool
+
+	// notify is signaled when series are enqueued.
+	notify chan struct{}
+
+	// cancel stops the queue, and done is closed once it has stopped running.
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	series          prometheus.Gauge
+	lag             prometheus.Gauge
+	diskBufferBytes prometheus.Gauge
+}
+
+type entry struct {
+	series     mimirpb.PreallocTimeseries
+	enqueuedAt time.Time
+}
+
+func newQueue(f *forwarder, tenant, endpoint string, cancel context.CancelFunc) *queue {
+	q := &queue{
+		forwarder:       f,
+		tenant:          tenant,
+		endpoint:        endpoint,
+		log:             log.With(f.log, "user", tenant, "endpoint", endpoint),
+		notify:          make(chan struct{}, 1),
+		cancel:          cancel,
+		done:            make(chan struct{}),
+		series:          f.queueSeries.WithLabelValues(tenant, endpoint),
+		lag:             f.queueLag.WithLabelValues(tenant, endpoint),
+		diskBufferBytes: f.diskBufferBytes.WithLabelValues(tenant, endpoint),
+	}
+
+	if f.cfg.DiskBufferDir != "" {
+		disk, err := openDiskBuffer(f.cfg.DiskBufferDir, tenant, endpoint, f.cfg.DiskBufferMaxBytes)
+		if err != nil {
+			level.Error(q.log).Log("msg", "failed to open the forwarding disk buffer, buffering only in memory", "err", err)
+		} else {
+			q.disk = disk
+			q.diskBufferBytes.Set(float64(disk.size()))
+		}
+	}
+
+	return q
+}
+
+// push enqueues the series. The series exceeding the queue capacity are written to the disk buffer, or dropped.
+func (q *queue) push(series []mimirpb.PreallocTimeseries, now time.Time) {
+	q.mtx.Lock()
+	if q.removed {
+		q.mtx.Unlock()
+		q.drop(series, reasonEndpointRemoved)
+		return
+	}
+
+	accepted := len(series)
+	// The queue can exceed its capacity once the series of a failed batch have been requeued.
+	free := q.forwarder.cfg.QueueCapacity - len(q.entries)
+	if free < 0 {
+		free = 0
+	}
+	if accepted > free {
+		accepted = free
+	}
+	for _, s := range series[:accepted] {
+		q.entries = append(q.entries, entry{series: s, enqueuedAt: now})
+	}
+	q.series.Set(float64(len(q.entries)))
+	q.mtx.Unlock()
+
+	if overflow := series[accepted:]; len(overflow) > 0 {
+		q.spill(overflow, reasonQueueFull)
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// spill writes the series to the disk buffer if enabled, otherwise they're dropped with the given reason.
+func (q *queue) spill(series []mimirpb.PreallocTimeseries, reason string) {
+	if q.disk != nil {
+		err := q.disk.write(series)
+		if err == nil {
+			q.diskBufferBytes.Set(float64(q.disk.size()))
+			return
+		}
+		level.Warn(q.log).Log("msg", "failed to write series to the forwarding disk buffer", "err", err)
+		if !errors.Is(err, errDiskBufferFull) {
+			reason = reasonDiskBuffer
+		}
+	}
+
+	q.drop(series, reason)
+}
+
+func (q *queue) drop(series []mimirpb.PreallocTimeseries, reason string) {
+	q.forwarder.droppedSamplesTotal.WithLabelValues(q.tenant, q.endpoint, reason).Add(float64(countSamples(series)))
+}
+
+// run forwards the buffered series until the context is canceled.
+func (q *queue) run(ctx context.Context) {
+	ticker := time.NewTicker(q.forwarder.cfg.BatchSendDeadline)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		if q.forwardDiskBuffer(ctx) {
+			continue
+		}
+
+		if batch := q.nextBatch(time.Now()); len(batch) > 0 {
+			if err := q.forward(ctx, batch); err != nil {
+				if ctx.Err() != nil {
+					// The queue is shutting down, put the batch back so that it's handled by shutdown().
+					q.requeue(batch)
+				} else {
+					q.spill(batch, reasonRetriesExhausted)
+				}
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// forwardDiskBuffer forwards the oldest segment of the disk buffer. It returns false if the disk buffer is empty.
+func (q *queue) forwardDiskBuffer(ctx context.Context) bool {
+	if q.disk == nil {
+		return false
+	}
+	seg, ok := q.disk.oldest()
+	if !ok {
+		return false
+	}
+
+	series, err := q.disk.read(seg)
+	if err != nil {
+		level.Error(q.log).Log("msg", "failed to read the forwarding disk buffer segment, removing it", "segment", seg.path, "err", err)
+	} else if err := q.forward(ctx, series); err != nil {
+		// The segment is kept, and retried at the next iteration.
+		return true
+	}
+
+	if err := q.disk.remove(seg); err != nil {
+		level.Warn(q.log).Log("msg", "failed to remove the forwarding disk buffer segment", "segment", seg.path, "err", err)
+	}
+	q.diskBufferBytes.Set(float64(q.disk.size()))
+	return true
+}
+
+// nextBatch dequeues the next batch of series if it's full or its oldest series has waited at least
+// the batch send deadline.
+func (q *queue) nextBatch(now time.Time) []mimirpb.PreallocTimeseries {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if len(q.entries) == 0 {
+		q.lag.Set(0)
+		return nil
+	}
+
+	age := now.Sub(q.entries[0].enqueuedAt)
+	q.lag.Set(age.Seconds())
+	if len(q.entries) < q.forwarder.cfg.BatchSize && age < q.forwarder.cfg.BatchSendDeadline {
+		return nil
+	}
+
+	n := len(q.entries)
+	if n > q.forwarder.cfg.BatchSize {
+		n = q.forwarder.cfg.BatchSize
+	}
+	return q.dequeue(n)
+}
+
+// dequeue must be called with mtx held.
+func (q *queue) dequeue(n int) []mimirpb.PreallocTimeseries {
+	batch := make([]mimirpb.PreallocTimeseries, 0, n)
+	for _, e := range q.entries[:n] {
+		batch = append(batch, e.series)
+	}
+
+	// Copy the remaining entries to not retain the dequeued series in the underlying array.
+	q.entries = append([]entry(nil), q.entries[n:]...)
+	q.series.Set(float64(len(q.entries)))
+	return batch
+}
+
+// requeue puts the series back at the front of the queue.
+func (q *queue) requeue(series []mimirpb.PreallocTimeseries) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	entries := make([]entry, 0, len(series)+len(q.entries))
+	for _, s := range series {
+		entries = append(entries, entry{series: s, enqueuedAt: time.Now()})
+	}
+	q.entries = append(entries, q.entries...)
+	q.series.Set(float64(len(q.entries)))
+}
+
+// forward sends the series to the endpoint, retrying on recoverable errors. The series which fail with a
+// non-recoverable error are dropped, because retrying them wouldn't succeed. It returns an error if the series
+// haven't been forwarded because the retries have been exhausted or the context has been canceled.
+func (q *queue) forward(ctx context.Context, series []mimirpb.PreallocTimeseries) error {
+	retries := backoff.New(ctx, backoff.Config{
+		MinBackoff: q.forwarder.cfg.MinBackoff,
+		MaxBackoff: q.forwarder.cfg.MaxBackoff,
+		MaxRetries: q.forwarder.cfg.MaxRetries,
+	})
+
+	var lastErr error
+	for retries.Ongoing() {
+		lastErr = q.forwarder.sendToEndpoint(ctx, q.tenant, q.endpoint, series)
+		if lastErr == nil {
+			return nil
+		}
+
+		if !errors.As(lastErr, &recoverableError{}) {
+			level.Warn(q.log).Log("msg", "dropping series which failed to be forwarded with a non-recoverable error", "series", len(series), "err", lastErr)
+			q.drop(series, reasonNonRecoverable)
+			return nil
+		}
+
+		level.Debug(q.log).Log("msg", "failed to forward series, retrying", "series", len(series), "err", lastErr)
+		retries.Wait()
+	}
+
+	if lastErr == nil {
+		lastErr = retries.Err()
+	}
+	level.Warn(q.log).Log("msg", "failed to forward series", "series", len(series), "err", lastErr)
+	return lastErr
+}
+
+// remove stops the queue once its endpoint has been removed from the tenant's rules, and drops the series
+// buffered in memory and on disk.
+func (q *queue) remove() {
+	q.cancel()
+	<-q.done
+
+	q.mtx.Lock()
+	q.removed = true
+	series := q.dequeue(len(q.entries))
+	q.mtx.Unlock()
+
+	q.drop(series, reasonEndpointRemoved)
+
+	if q.disk != nil {
+		level.Warn(q.log).Log("msg", "removing the forwarding disk buffer of the endpoint which is not in the forwarding rules anymore", "bytes", q.disk.size())
+		if err := q.disk.removeAll(); err != nil {
+			level.Warn(q.log).Log("msg", "failed to remove the forwarding disk buffer", "err", err)
+		}
+	}
+
+	q.forwarder.queueSeries.DeleteLabelValues(q.tenant, q.endpoint)
+	q.forwarder.queueLag.DeleteLabelValues(q.tenant, q.endpoint)
+	q.forwarder.diskBufferBytes.DeleteLabelValues(q.tenant, q.endpoint)
+}
+
+// shutdown handles the series still buffered in memory once the queue has stopped running.
+// They're written to the disk buffer if enabled, otherwise a last attempt to forward them is done.
+func (q *queue) shutdown() {
+	q.mtx.Lock()
+	series := q.dequeue(len(q.entries))
+	q.mtx.Unlock()
+
+	if len(series) == 0 {
+		return
+	}
+
+	if q.disk != nil {
+		q.spill(series, reasonShutdown)
+		return
+	}
+
+	for len(series) > 0 {
+		n := len(series)
+		if n > q.forwarder.cfg.BatchSize {
+			n = q.forwarder.cfg.BatchSize
+		}
+		if err := q.forwarder.sendToEndpoint(context.Background(), q.tenant, q.endpoint, series[:n]); err != nil {
+			level.Warn(q.log).Log("msg", "failed to forward series at shutdown", "series", n, "err", err)
+			q.drop(series[:n], reasonShutdown)
+		}
+		series = series[n:]
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/kv/codec"
+	"github.com/grafana/dskit/kv/memberlist"
 	"github.com/grafana/dskit/services"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -32,13 +33,17 @@ import (
 var (
 	errNegativeUpdateTimeoutJitterMax = errors.New("HA tracker max update timeout jitter shouldn't be negative")
 	errInvalidFailoverTimeout         = "HA Tracker failover timeout (%v) must be at least 1s greater than update timeout - max jitter (%v)"
-	errMemberlistUnsupported          = errors.New("memberlist is not supported by the HA tracker since gossip propagation is too slow for HA purposes")
+	errHATrackerDisabled              = errors.New("the HA tracker is disabled")
 )
 
 type haTrackerLimits interface {
 	// MaxHAClusters returns max number of clusters that HA tracker should track for a user.
 	// Samples from additional clusters are rejected.
 	MaxHAClusters(user string) int
+
+	// HATrackerFailoverTimeout returns the failover timeout of the HA tracker for a user,
+	// or 0 to use the failover timeout of the HA tracker configuration.
+	HATrackerFailoverTimeout(user string) time.Duration
 }
 
 // ProtoReplicaDescFactory makes new InstanceDescs
@@ -51,6 +56,99 @@ func NewReplicaDesc() *ReplicaDesc {
 	return &ReplicaDesc{}
 }
 
+// Merge implements memberlist.Mergeable. The ReplicaDesc is a last-write-wins register: any election
+// wins over a cleared descriptor, then the descriptor with the most recent term wins, and within
+// a term the descriptor with the most recent ReceivedAt wins. On equal ReceivedAt, the descriptor marked for deletion wins, so that
+// marking a replica for deletion is never lost, and the replica name breaks any remaining tie.
+// This makes the merge commutative, associative and idempotent.
+func (d *ReplicaDesc) Merge(mergeable memberlist.Mergeable, _ bool) (memberlist.Mergeable, error) {
+	if mergeable == nil {
+		return nil, nil
+	}
+
+	other, ok := mergeable.(*ReplicaDesc)
+	if !ok {
+		return nil, fmt.Errorf("expected *distributor.ReplicaDesc, got %T", mergeable)
+	}
+	if other == nil || !other.supersedes(d) {
+		return nil, nil
+	}
+
+	d.Replica = other.Replica
+	d.ReceivedAt = other.ReceivedAt
+	d.DeletedAt = other.DeletedAt
+	d.Term = other.Term
+	return other.Clone(), nil
+}
+
+// supersedes returns whether d wins over other when merging them.
+func (d *ReplicaDesc) supersedes(other *ReplicaDesc) bool {
+	if (d.Replica == "") != (other.Replica == "") {
+		return other.Replica == ""
+	}
+	if d.Term != other.Term {
+		return d.Term > other.Term
+	}
+	if d.ReceivedAt != other.ReceivedAt {
+		return d.ReceivedAt > other.ReceivedAt
+	}
+	if d.DeletedAt != other.DeletedAt {
+		return d.DeletedAt > other.DeletedAt
+	}
+	return d.Replica > other.Replica
+}
+
+// MergeContent implements memberlist.Mergeable.
+func (d *ReplicaDesc) MergeContent() []string {
+	if d.Replica == "" {
+		return nil
+	}
+	return []string{d.Replica}
+}
+
+// RemoveTombstones implements memberlist.Mergeable. Since memberlist doesn't support deleting keys,
+// a descriptor marked for deletion before the limit is cleared, like the LEFT instances of the ring.
+// If the limit is zero, any descriptor marked for deletion is cleared.
+//
+// This assumes that the updates of the replica older than the limit have been propagated to all the
+// members: an outdated update received after the descriptor has been cleared elects its replica again.
+func (d *ReplicaDesc) RemoveTombstones(limit time.Time) (total, removed int) {
+	if d.DeletedAt == 0 {
+		return 0, 0
+	}
+	if !limit.IsZero() && !timestamp.Time(d.DeletedAt).Before(limit) {
+		return 1, 0
+	}
+
+	// The term is kept, so that the next election started from the cleared descriptor wins over the
+	// deletion mark still stored by the members which haven't cleared it yet.
+	*d = ReplicaDesc{Term: d.Term}
+	return 0, 1
+}
+
+// nextTerm returns the term of the election of the replica following d, which is only incremented
+// on a failover to another replica. d may be nil if no replica has been elected yet.
+func (d *ReplicaDesc) nextTerm(replica string) uint64 {
+	if d == nil {
+		return 0
+	}
+	if d.Replica != "" && d.Replica != replica {
+		return d.Term + 1
+	}
+	return d.Term
+}
+
+// isDeleted returns whether the replica is marked for deletion, or has been cleared once its
+// deletion mark has been removed as a tombstone.
+func (d *ReplicaDesc) isDeleted() bool {
+	return d.DeletedAt > 0 || d.Replica == ""
+}
+
+// Clone implements memberlist.Mergeable.
+func (d *ReplicaDesc) Clone() memberlist.Mergeable {
+	return proto.Clone(d).(*ReplicaDesc)
+}
+
 // HATrackerConfig contains the configuration require to
 // create a HA Tracker.
 type HATrackerConfig struct {
@@ -66,7 +164,7 @@ type HATrackerConfig struct {
 	// more than this duration
 	FailoverTimeout time.Duration `yaml:"ha_tracker_failover_timeout" category:"advanced"`
 
-	KVStore kv.Config `yaml:"kvstore" doc:"description=Backend storage to use for the ring. Please be aware that memberlist is not supported by the HA tracker since gossip propagation is too slow for HA purposes."`
+	KVStore kv.Config `yaml:"kvstore" doc:"description=Backend storage to use for the ring. When using memberlist, the elected replicas are only guaranteed to be consistent across distributors if the gossip propagation delay is lower than the failover timeout minus the update timeout and its maximum jitter."`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -89,18 +187,18 @@ func (cfg *HATrackerConfig) Validate() error {
 		return errNegativeUpdateTimeoutJitterMax
 	}
 
-	minFailureTimeout := cfg.UpdateTimeout + cfg.UpdateTimeoutJitterMax + time.Second
-	if cfg.FailoverTimeout < minFailureTimeout {
+	if minFailureTimeout := cfg.minFailoverTimeout(); cfg.FailoverTimeout < minFailureTimeout {
 		return fmt.Errorf(errInvalidFailoverTimeout, cfg.FailoverTimeout, minFailureTimeout)
 	}
 
-	if cfg.KVStore.Store == "memberlist" {
-		return errMemberlistUnsupported
-	}
-
 	return nil
 }
 
+// minFailoverTimeout returns the minimum failover timeout allowed by the update timeout and its jitter.
+func (cfg *HATrackerConfig) minFailoverTimeout() time.Duration {
+	return cfg.UpdateTimeout + cfg.UpdateTimeoutJitterMax + time.Second
+}
+
 func GetReplicaDescCodec() codec.Proto {
 	return codec.NewProtoCodec("replicaDesc", ProtoReplicaDescFactory)
 }
@@ -136,6 +234,9 @@ type haClusterInfo struct {
 	electedLastSeenTimestamp    int64
 	nonElectedLastSeenReplica   string
 	nonElectedLastSeenTimestamp int64
+
+	// Whether a distributor request is failing over to a non-elected replica in-band.
+	failoverInProgress bool
 }
 
 // NewClusterTracker returns a new HA cluster tracker using either Consul
@@ -237,7 +338,7 @@ func (c *haTracker) loop(ctx context.Context) error {
 		user := segments[0]
 		cluster := segments[1]
 
-		if replica.DeletedAt > 0 {
+		if replica.isDeleted() {
 			c.electedReplicaChanges.DeleteLabelValues(user, cluster)
 			c.electedReplicaTimestamp.DeleteLabelValues(user, cluster)
 
@@ -353,6 +454,12 @@ func (c *haTracker) cleanupOldReplicas(ctx context.Context, deadline time.Time)
 			continue
 		}
 
+		if c.isMemberlist() && desc.isDeleted() {
+			// Memberlist doesn't support deleting keys: the replica marked for deletion is cleared
+			// once the mark is older than the memberlist tombstones timeout.
+			continue
+		}
+
 		if desc.DeletedAt > 0 {
 			if timestamp.Time(desc.DeletedAt).After(deadline) {
 				continue
@@ -415,18 +522,26 @@ func (c *haTracker) checkReplica(ctx context.Context, userID, cluster, replica s
 
 	c.electedLock.Lock()
 	if entry := c.clusters[userID][cluster]; entry != nil {
-		var err error
 		if entry.elected.Replica == replica {
 			// Sample received is from elected replica: update timestamp and carry on.
 			entry.electedLastSeenTimestamp = timestamp.FromTime(now)
-		} else {
-			// Sample received is from non-elected replica: record details and reject.
-			entry.nonElectedLastSeenReplica = replica
-			entry.nonElectedLastSeenTimestamp = timestamp.FromTime(now)
-			err = replicasNotMatchError{replica: replica, elected: entry.elected.Replica}
+			c.electedLock.Unlock()
+			return nil
+		}
+
+		// Sample received is from non-elected replica: record details and reject.
+		entry.nonElectedLastSeenReplica = replica
+		entry.nonElectedLastSeenTimestamp = timestamp.FromTime(now)
+		elected := entry.elected
+
+		// A single request per cluster fails over in-band at a time, while the others are rejected.
+		if c.isMemberlist() && !entry.failoverInProgress && now.Sub(timestamp.Time(elected.ReceivedAt)) >= c.failoverTimeout(userID) {
+			entry.failoverInProgress = true
+			c.electedLock.Unlock()
+			return c.failoverInBand(ctx, userID, cluster, replica, entry, now)
 		}
 		c.electedLock.Unlock()
-		return err
+		return replicasNotMatchError{replica: replica, elected: elected.Replica}
 	}
 
 	// We don't know about this cluster yet.
@@ -446,6 +561,52 @@ func (c *haTracker) checkReplica(ctx context.Context, userID, cluster, replica s
 	return c.checkReplica(ctx, userID, cluster, replica, now)
 }
 
+// failoverInBand attempts to fail over to the replica when the elected replica hasn't been
+// refreshed for the failover timeout, without waiting for the periodic update of the KV store.
+// Since the KV store updates are gossiped, this bounds the staleness of the elected replica
+// cached by the distributor to the failover timeout, regardless of the gossip propagation delay.
+// The caller must have set the failoverInProgress flag of the cluster entry.
+func (c *haTracker) failoverInBand(ctx context.Context, userID, cluster, replica string, entry *haClusterInfo, now time.Time) error {
+	err := c.updateKVStore(ctx, userID, cluster, replica, now)
+
+	c.electedLock.Lock()
+	defer c.electedLock.Unlock()
+
+	entry.failoverInProgress = false
+	if err != nil {
+		level.Error(c.logger).Log("msg", "failed to update KVStore - rejecting sample", "err", err)
+		return err
+	}
+
+	entry = c.clusters[userID][cluster]
+	if entry == nil {
+		// The cluster has been deleted in the meantime.
+		return replicasNotMatchError{replica: replica}
+	}
+	if entry.elected.Replica != replica {
+		return replicasNotMatchError{replica: replica, elected: entry.elected.Replica}
+	}
+	entry.electedLastSeenTimestamp = timestamp.FromTime(now)
+	return nil
+}
+
+// failoverTimeout returns the failover timeout of the user. The per-tenant override is raised
+// to the minimum failover timeout allowed by the configuration.
+func (c *haTracker) failoverTimeout(userID string) time.Duration {
+	timeout := c.limits.HATrackerFailoverTimeout(userID)
+	if timeout <= 0 {
+		return c.cfg.FailoverTimeout
+	}
+	if minTimeout := c.cfg.minFailoverTimeout(); timeout < minTimeout {
+		return minTimeout
+	}
+	return timeout
+}
+
+func (c *haTracker) isMemberlist() bool {
+	return c.cfg.KVStore.Store == "memberlist"
+}
+
 func (c *haTracker) withinUpdateTimeout(now time.Time, receivedAt int64) bool {
 	return now.Sub(timestamp.Time(receivedAt)) < c.cfg.UpdateTimeout+c.updateTimeoutJitter
 }
@@ -474,28 +635,30 @@ func (c *haTracker) updateKVStore(ctx context.Context, userID, cluster, replica
 	var desc *ReplicaDesc
 	err := c.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
 		var ok bool
-		if desc, ok = in.(*ReplicaDesc); ok && desc.DeletedAt == 0 {
+		if desc, ok = in.(*ReplicaDesc); ok && !desc.isDeleted() {
 			// If the entry in KVStore is up-to-date, just stop the loop.
 			if c.withinUpdateTimeout(now, desc.ReceivedAt) ||
 				// If our replica is different, wait until the failover time.
-				desc.Replica != replica && now.Sub(timestamp.Time(desc.ReceivedAt)) < c.cfg.FailoverTimeout {
+				desc.Replica != replica && now.Sub(timestamp.Time(desc.ReceivedAt)) < c.failoverTimeout(userID) {
 				return nil, false, nil
 			}
 		}
 
 		// Attempt to update KVStore to our timestamp and replica.
+		term := desc.nextTerm(replica)
 		desc = &ReplicaDesc{
 			Replica:    replica,
 			ReceivedAt: timestamp.FromTime(now),
 			DeletedAt:  0,
+			Term:       term,
 		}
 		return desc, true, nil
 	})
 	c.kvCASCalls.WithLabelValues(userID, cluster).Inc()
-	// If cache is currently empty, add the data we either stored or received from KVStore
+	// If cache is currently empty or outdated, add the data we either stored or received from KVStore
 	if err == nil && desc != nil {
 		c.electedLock.Lock()
-		if c.clusters[userID][cluster] == nil {
+		if entry := c.clusters[userID][cluster]; entry == nil || desc.supersedes(&entry.elected) {
 			c.updateCache(userID, cluster, desc)
 		}
 		c.electedLock.Unlock()
@@ -503,6 +666,50 @@ func (c *haTracker) updateKVStore(ctx context.Context, userID, cluster, replica
 	return err
 }
 
+// forceFailover elects the replica of the cluster, regardless of the failover timeout.
+func (c *haTracker) forceFailover(ctx context.Context, userID, cluster, replica string, now time.Time) error {
+	if !c.cfg.EnableHATracker {
+		return errHATrackerDisabled
+	}
+
+	// Like checkReplica, a new cluster can't be tracked if the limit of clusters is reached.
+	c.electedLock.RLock()
+	_, known := c.clusters[userID][cluster]
+	nClusters := len(c.clusters[userID])
+	c.electedLock.RUnlock()
+	if limit := c.limits.MaxHAClusters(userID); !known && limit > 0 && nClusters+1 > limit {
+		return tooManyClustersError{limit: limit}
+	}
+
+	key := fmt.Sprintf("%s/%s", userID, cluster)
+	var desc *ReplicaDesc
+	err := c.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
+		prev, _ := in.(*ReplicaDesc)
+		desc = &ReplicaDesc{
+			Replica:    replica,
+			ReceivedAt: timestamp.FromTime(now),
+			Term:       prev.nextTerm(replica),
+		}
+		if prev != nil && !desc.supersedes(prev) {
+			// Only happens if the replica has been refreshed by another distributor with a later timestamp.
+			desc = prev
+			return nil, false, nil
+		}
+		return desc, true, nil
+	})
+	c.kvCASCalls.WithLabelValues(userID, cluster).Inc()
+	if err != nil {
+		return err
+	}
+
+	c.electedLock.Lock()
+	if entry := c.clusters[userID][cluster]; entry == nil || desc.supersedes(&entry.elected) {
+		c.updateCache(userID, cluster, desc)
+	}
+	c.electedLock.Unlock()
+	return nil
+}
+
 type replicasNotMatchError struct {
 	replica, elected string
 }
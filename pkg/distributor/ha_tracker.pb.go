@@ -34,6 +34,10 @@ type ReplicaDesc struct {
 	// already remove entry from memory. Actual deletion from KV store does *not* trigger
 	// "watch" notification with a key for all KV stores.
 	DeletedAt int64 `protobuf:"varint,3,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+	// Election term of the replica, incremented on each failover to another replica.
+	// When merging, the descriptor of the most recent term wins regardless of ReceivedAt,
+	// so that a failover is never lost because of the clock skew between the distributors.
+	Term uint64 `protobuf:"varint,4,opt,name=term,proto3" json:"term,omitempty"`
 }
 
 func (m *ReplicaDesc) Reset()      { *m = ReplicaDesc{} }
@@ -89,6 +93,13 @@ func (m *ReplicaDesc) GetDeletedAt() int64 {
 	return 0
 }
 
+func (m *ReplicaDesc) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*ReplicaDesc)(nil), "distributor.ReplicaDesc")
 }
@@ -96,21 +107,22 @@ func init() {
 func init() { proto.RegisterFile("ha_tracker.proto", fileDescriptor_86f0e7bcf71d860b) }
 
 var fileDescriptor_86f0e7bcf71d860b = []byte{
-	// 224 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0xc8, 0x48, 0x8c, 0x2f,
-	0x29, 0x4a, 0x4c, 0xce, 0x4e, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x4e, 0xc9,
-	0x2c, 0x2e, 0x29, 0xca, 0x4c, 0x2a, 0x2d, 0xc9, 0x2f, 0x92, 0xd2, 0x4d, 0xcf, 0x2c, 0xc9, 0x28,
-	0x4d, 0xd2, 0x4b, 0xce, 0xcf, 0xd5, 0x4f, 0xcf, 0x4f, 0xcf, 0xd7, 0x07, 0xab, 0x49, 0x2a, 0x4d,
-	0x03, 0xf3, 0xc0, 0x1c, 0x30, 0x0b, 0xa2, 0x57, 0x29, 0x9d, 0x8b, 0x3b, 0x28, 0xb5, 0x20, 0x27,
-	0x33, 0x39, 0xd1, 0x25, 0xb5, 0x38, 0x59, 0x48, 0x82, 0x8b, 0xbd, 0x08, 0xc2, 0x95, 0x60, 0x54,
-	0x60, 0xd4, 0xe0, 0x0c, 0x82, 0x71, 0x85, 0xe4, 0xb9, 0xb8, 0x8b, 0x52, 0x93, 0x53, 0x33, 0xcb,
-	0x52, 0x53, 0xe2, 0x13, 0x4b, 0x24, 0x98, 0x14, 0x18, 0x35, 0x98, 0x83, 0xb8, 0x60, 0x42, 0x8e,
-	0x25, 0x42, 0xb2, 0x5c, 0x5c, 0x29, 0xa9, 0x39, 0xa9, 0x25, 0x10, 0x79, 0x66, 0xb0, 0x3c, 0x27,
-	0x54, 0xc4, 0xb1, 0xc4, 0xc9, 0xe4, 0xc2, 0x43, 0x39, 0x86, 0x1b, 0x0f, 0xe5, 0x18, 0x3e, 0x3c,
-	0x94, 0x63, 0x6c, 0x78, 0x24, 0xc7, 0xb8, 0xe2, 0x91, 0x1c, 0xe3, 0x89, 0x47, 0x72, 0x8c, 0x17,
-	0x1e, 0xc9, 0x31, 0x3e, 0x78, 0x24, 0xc7, 0xf8, 0xe2, 0x91, 0x1c, 0xc3, 0x87, 0x47, 0x72, 0x8c,
-	0x13, 0x1e, 0xcb, 0x31, 0x5c, 0x78, 0x2c, 0xc7, 0x70, 0xe3, 0xb1, 0x1c, 0x43, 0x12, 0x1b, 0xd8,
-	0x95, 0xc6, 0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0xb3, 0xd1, 0xdd, 0x8d, 0xf5, 0x00, 0x00, 0x00,
+	// 233 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x34, 0x8e, 0x31, 0x4e, 0xc3, 0x40,
+	0x10, 0x45, 0x77, 0x88, 0x05, 0xca, 0xba, 0x41, 0x5b, 0x59, 0x48, 0x0c, 0x16, 0x95, 0x1b, 0x92,
+	0x02, 0x2e, 0x10, 0xc4, 0x09, 0x7c, 0x81, 0xc8, 0xbb, 0x1e, 0x9c, 0x15, 0x89, 0x36, 0xda, 0x8c,
+	0x69, 0x68, 0x38, 0x02, 0xc7, 0xe0, 0x28, 0x94, 0x2e, 0x53, 0xe2, 0x75, 0x43, 0x99, 0x23, 0x20,
+	0xad, 0xe3, 0xee, 0xbf, 0xff, 0x66, 0xa4, 0x2f, 0xaf, 0x37, 0xd5, 0x9a, 0x7d, 0x65, 0xde, 0xc8,
+	0x2f, 0xf6, 0xde, 0xb1, 0x53, 0x69, 0x6d, 0x0f, 0xec, 0xad, 0x6e, 0xd9, 0xf9, 0x9b, 0x87, 0xc6,
+	0xf2, 0xa6, 0xd5, 0x0b, 0xe3, 0x76, 0xcb, 0xc6, 0x35, 0x6e, 0x19, 0x6f, 0x74, 0xfb, 0x1a, 0x29,
+	0x42, 0x4c, 0xe3, 0xef, 0xfd, 0x87, 0x4c, 0x4b, 0xda, 0x6f, 0xad, 0xa9, 0x5e, 0xe8, 0x60, 0x54,
+	0x26, 0xaf, 0xfc, 0x88, 0x19, 0xe4, 0x50, 0xcc, 0xcb, 0x09, 0xd5, 0x9d, 0x4c, 0x3d, 0x19, 0xb2,
+	0xef, 0x54, 0xaf, 0x2b, 0xce, 0x2e, 0x72, 0x28, 0x66, 0xa5, 0x9c, 0xaa, 0x15, 0xab, 0x5b, 0x29,
+	0x6b, 0xda, 0x12, 0x8f, 0x7e, 0x16, 0xfd, 0xfc, 0xdc, 0xac, 0x58, 0x29, 0x99, 0x30, 0xf9, 0x5d,
+	0x96, 0xe4, 0x50, 0x24, 0x65, 0xcc, 0xcf, 0x4f, 0x5d, 0x8f, 0xe2, 0xd8, 0xa3, 0x38, 0xf5, 0x08,
+	0x9f, 0x01, 0xe1, 0x3b, 0x20, 0xfc, 0x04, 0x84, 0x2e, 0x20, 0xfc, 0x06, 0x84, 0xbf, 0x80, 0xe2,
+	0x14, 0x10, 0xbe, 0x06, 0x14, 0xdd, 0x80, 0xe2, 0x38, 0xa0, 0xd0, 0x97, 0x71, 0xf9, 0xe3, 0xff,
+	0x00, 0x4b, 0x04, 0xa7, 0x96, 0x09, 0x01, 0x00, 0x00,
 }
 
 func (this *ReplicaDesc) Equal(that interface{}) bool {
@@ -141,17 +153,21 @@ func (this *ReplicaDesc) Equal(that interface{}) bool {
 	if this.DeletedAt != that1.DeletedAt {
 		return false
 	}
+	if this.Term != that1.Term {
+		return false
+	}
 	return true
 }
 func (this *ReplicaDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 7)
+	s := make([]string, 0, 8)
 	s = append(s, "&distributor.ReplicaDesc{")
 	s = append(s, "Replica: "+fmt.Sprintf("%#v", this.Replica)+",\n")
 	s = append(s, "ReceivedAt: "+fmt.Sprintf("%#v", this.ReceivedAt)+",\n")
 	s = append(s, "DeletedAt: "+fmt.Sprintf("%#v", this.DeletedAt)+",\n")
+	s = append(s, "Term: "+fmt.Sprintf("%#v", this.Term)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -183,6 +199,11 @@ func (m *ReplicaDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Term != 0 {
+		i = encodeVarintHaTracker(dAtA, i, uint64(m.Term))
+		i--
+		dAtA[i] = 0x20
+	}
 	if m.DeletedAt != 0 {
 		i = encodeVarintHaTracker(dAtA, i, uint64(m.DeletedAt))
 		i--
@@ -230,6 +251,9 @@ func (m *ReplicaDesc) Size() (n int) {
 	if m.DeletedAt != 0 {
 		n += 1 + sovHaTracker(uint64(m.DeletedAt))
 	}
+	if m.Term != 0 {
+		n += 1 + sovHaTracker(uint64(m.Term))
+	}
 	return n
 }
 
@@ -247,6 +271,7 @@ func (this *ReplicaDesc) String() string {
 		`Replica:` + fmt.Sprintf("%v", this.Replica) + `,`,
 		`ReceivedAt:` + fmt.Sprintf("%v", this.ReceivedAt) + `,`,
 		`DeletedAt:` + fmt.Sprintf("%v", this.DeletedAt) + `,`,
+		`Term:` + fmt.Sprintf("%v", this.Term) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -358,6 +383,25 @@ func (m *ReplicaDesc) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Term", wireType)
+			}
+			m.Term = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHaTracker
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Term |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipHaTracker(dAtA[iNdEx:])
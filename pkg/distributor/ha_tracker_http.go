@@ -7,6 +7,7 @@ package distributor
 
 import (
 	_ "embed" // Used to embed html template
+	"errors"
 	"html/template"
 	"net/http"
 	"sort"
@@ -30,6 +31,7 @@ type haTrackerReplica struct {
 	UserID       string        `json:"userID"`
 	Cluster      string        `json:"cluster"`
 	Replica      string        `json:"replica"`
+	Term         uint64        `json:"term"`
 	ElectedAt    time.Time     `json:"electedAt"`
 	UpdateTime   time.Duration `json:"updateDuration"`
 	FailoverTime time.Duration `json:"failoverDuration"`
@@ -46,9 +48,10 @@ func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 				UserID:       userID,
 				Cluster:      cluster,
 				Replica:      desc.Replica,
+				Term:         desc.Term,
 				ElectedAt:    timestamp.Time(desc.ReceivedAt),
 				UpdateTime:   time.Until(timestamp.Time(desc.ReceivedAt).Add(h.cfg.UpdateTimeout)),
-				FailoverTime: time.Until(timestamp.Time(desc.ReceivedAt).Add(h.cfg.FailoverTimeout)),
+				FailoverTime: time.Until(timestamp.Time(desc.ReceivedAt).Add(h.failoverTimeout(userID))),
 			})
 		}
 	}
@@ -69,3 +72,25 @@ func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		Now:     time.Now(),
 	}, haTrackerStatusPageTemplate, req)
 }
+
+// FailoverHandler forces the failover of an HA cluster to the given replica.
+func (h *haTracker) FailoverHandler(w http.ResponseWriter, req *http.Request) {
+	userID := req.FormValue("tenant")
+	cluster := req.FormValue("cluster")
+	replica := req.FormValue("replica")
+	if userID == "" || cluster == "" || replica == "" {
+		http.Error(w, "tenant, cluster and replica parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.forceFailover(req.Context(), userID, cluster, replica, time.Now()); err != nil {
+		if errors.Is(err, errHATrackerDisabled) || errors.Is(err, tooManyClustersError{}) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -8,6 +8,8 @@ package distributor
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -15,7 +17,9 @@ import (
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/kv/codec"
 	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/kv/memberlist"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/test"
@@ -116,7 +120,7 @@ func TestHATrackerConfig_Validate(t *testing.T) {
 			}(),
 			expectedErr: nil,
 		},
-		"should fail if KV backend is set to memberlist": {
+		"should pass if KV backend is set to memberlist": {
 			cfg: func() HATrackerConfig {
 				cfg := HATrackerConfig{}
 				flagext.DefaultValues(&cfg)
@@ -124,7 +128,7 @@ func TestHATrackerConfig_Validate(t *testing.T) {
 
 				return cfg
 			}(),
-			expectedErr: errMemberlistUnsupported,
+			expectedErr: nil,
 		},
 	}
 
@@ -679,13 +683,18 @@ func TestReplicasNotMatchError(t *testing.T) {
 }
 
 type trackerLimits struct {
-	maxClusters int
+	maxClusters     int
+	failoverTimeout time.Duration
 }
 
 func (l trackerLimits) MaxHAClusters(_ string) int {
 	return l.maxClusters
 }
 
+func (l trackerLimits) HATrackerFailoverTimeout(_ string) time.Duration {
+	return l.failoverTimeout
+}
+
 func TestHATracker_MetricsCleanup(t *testing.T) {
 	reg := prometheus.NewPedanticRegistry()
 	tr, err := newHATracker(HATrackerConfig{EnableHATracker: false}, nil, reg, log.NewNopLogger())
@@ -860,3 +869,399 @@ func checkReplicaDeletionState(t *testing.T, duration time.Duration, c *haTracke
 		require.Equal(t, expectedMarkedForDeletion, markedForDeletion, "KV entry marked for deletion")
 	}
 }
+
+func TestReplicaDesc_Merge(t *testing.T) {
+	const now = int64(1000)
+
+	tests := map[string]struct {
+		local, incoming *ReplicaDesc
+		expected        *ReplicaDesc
+		expectedChange  bool
+	}{
+		"newer election wins": {
+			local:          &ReplicaDesc{Replica: "a", ReceivedAt: now},
+			incoming:       &ReplicaDesc{Replica: "b", ReceivedAt: now + 1},
+			expected:       &ReplicaDesc{Replica: "b", ReceivedAt: now + 1},
+			expectedChange: true,
+		},
+		"older election loses": {
+			local:    &ReplicaDesc{Replica: "a", ReceivedAt: now},
+			incoming: &ReplicaDesc{Replica: "b", ReceivedAt: now - 1},
+			expected: &ReplicaDesc{Replica: "a", ReceivedAt: now},
+		},
+		"same election is a no-op": {
+			local:    &ReplicaDesc{Replica: "a", ReceivedAt: now},
+			incoming: &ReplicaDesc{Replica: "a", ReceivedAt: now},
+			expected: &ReplicaDesc{Replica: "a", ReceivedAt: now},
+		},
+		"deletion mark wins over the same election": {
+			local:          &ReplicaDesc{Replica: "a", ReceivedAt: now},
+			incoming:       &ReplicaDesc{Replica: "a", ReceivedAt: now, DeletedAt: now + 10},
+			expected:       &ReplicaDesc{Replica: "a", ReceivedAt: now, DeletedAt: now + 10},
+			expectedChange: true,
+		},
+		"newer election wins over deletion mark": {
+			local:          &ReplicaDesc{Replica: "a", ReceivedAt: now, DeletedAt: now + 10},
+			incoming:       &ReplicaDesc{Replica: "b", ReceivedAt: now + 20},
+			expected:       &ReplicaDesc{Replica: "b", ReceivedAt: now + 20},
+			expectedChange: true,
+		},
+		"newer term wins over a later timestamp": {
+			local:          &ReplicaDesc{Replica: "a", ReceivedAt: now + 10, Term: 1},
+			incoming:       &ReplicaDesc{Replica: "b", ReceivedAt: now, Term: 2},
+			expected:       &ReplicaDesc{Replica: "b", ReceivedAt: now, Term: 2},
+			expectedChange: true,
+		},
+		"older term loses to an earlier timestamp": {
+			local:    &ReplicaDesc{Replica: "b", ReceivedAt: now, Term: 2},
+			incoming: &ReplicaDesc{Replica: "a", ReceivedAt: now + 10, Term: 1},
+			expected: &ReplicaDesc{Replica: "b", ReceivedAt: now, Term: 2},
+		},
+		"election wins over a cleared descriptor of a newer term": {
+			local:          &ReplicaDesc{Term: 2},
+			incoming:       &ReplicaDesc{Replica: "a", ReceivedAt: now, Term: 1},
+			expected:       &ReplicaDesc{Replica: "a", ReceivedAt: now, Term: 1},
+			expectedChange: true,
+		},
+		"concurrent elections are resolved by replica name": {
+			local:          &ReplicaDesc{Replica: "a", ReceivedAt: now},
+			incoming:       &ReplicaDesc{Replica: "b", ReceivedAt: now},
+			expected:       &ReplicaDesc{Replica: "b", ReceivedAt: now},
+			expectedChange: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Merging in both orders converges to the same descriptor.
+			reversed := tc.incoming.Clone().(*ReplicaDesc)
+			_, err := reversed.Merge(tc.local.Clone(), false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, reversed)
+
+			change, err := tc.local.Merge(tc.incoming, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, tc.local)
+			if tc.expectedChange {
+				assert.Equal(t, tc.expected, change)
+			} else {
+				assert.Nil(t, change)
+			}
+		})
+	}
+}
+
+func TestReplicaDesc_RemoveTombstones(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		desc            *ReplicaDesc
+		limit           time.Time
+		expected        *ReplicaDesc
+		expectedTotal   int
+		expectedRemoved int
+	}{
+		"elected replica is kept": {
+			desc:     &ReplicaDesc{Replica: "a", ReceivedAt: timestamp.FromTime(now.Add(-time.Hour))},
+			limit:    now,
+			expected: &ReplicaDesc{Replica: "a", ReceivedAt: timestamp.FromTime(now.Add(-time.Hour))},
+		},
+		"deletion mark newer than the limit is kept": {
+			desc:          &ReplicaDesc{Replica: "a", ReceivedAt: 1, DeletedAt: timestamp.FromTime(now)},
+			limit:         now.Add(-time.Minute),
+			expected:      &ReplicaDesc{Replica: "a", ReceivedAt: 1, DeletedAt: timestamp.FromTime(now)},
+			expectedTotal: 1,
+		},
+		"deletion mark older than the limit is removed": {
+			desc:            &ReplicaDesc{Replica: "a", ReceivedAt: 1, DeletedAt: timestamp.FromTime(now.Add(-time.Hour))},
+			limit:           now.Add(-time.Minute),
+			expected:        &ReplicaDesc{},
+			expectedRemoved: 1,
+		},
+		"deletion mark is removed with a zero limit": {
+			desc:            &ReplicaDesc{Replica: "a", ReceivedAt: 1, DeletedAt: timestamp.FromTime(now)},
+			expected:        &ReplicaDesc{},
+			expectedRemoved: 1,
+		},
+		"term of the removed deletion mark is kept": {
+			desc:            &ReplicaDesc{Replica: "a", ReceivedAt: 1, DeletedAt: timestamp.FromTime(now), Term: 3},
+			expected:        &ReplicaDesc{Term: 3},
+			expectedRemoved: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			total, removed := tc.desc.RemoveTombstones(tc.limit)
+			assert.Equal(t, tc.expected, tc.desc)
+			assert.Equal(t, tc.expectedTotal, total)
+			assert.Equal(t, tc.expectedRemoved, removed)
+
+			// A removed deletion mark isn't gossiped, and loses to any election.
+			if tc.expectedRemoved > 0 {
+				assert.Empty(t, tc.desc.MergeContent())
+				assert.True(t, tc.desc.isDeleted())
+
+				change, err := tc.desc.Merge(&ReplicaDesc{Replica: "b", ReceivedAt: 1}, false)
+				require.NoError(t, err)
+				assert.Equal(t, &ReplicaDesc{Replica: "b", ReceivedAt: 1}, change)
+			}
+		})
+	}
+}
+
+func TestHATracker_FailoverTimeoutOverride(t *testing.T) {
+	replica1 := "replica1"
+	replica2 := "replica2"
+
+	kvStore, closer := consul.NewInMemoryClient(GetReplicaDescCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	c, err := newHATracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Mock: kvStore},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Second,
+	}, trackerLimits{maxClusters: 100, failoverTimeout: 2 * time.Second}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	now := time.Now()
+	require.NoError(t, c.checkReplica(context.Background(), "user", "test", replica1, now))
+
+	// Wait more than the global failover timeout, but less than the tenant one.
+	now = now.Add(1100 * time.Millisecond)
+	assert.Error(t, c.checkReplica(context.Background(), "user", "test", replica2, now))
+	c.updateKVStoreAll(context.Background(), now)
+	checkReplicaTimestamp(t, time.Second, c, "user", "test", replica1, now.Add(-1100*time.Millisecond))
+
+	// Wait more than the tenant failover timeout.
+	now = now.Add(time.Second)
+	assert.Error(t, c.checkReplica(context.Background(), "user", "test", replica2, now))
+	c.updateKVStoreAll(context.Background(), now)
+	checkReplicaTimestamp(t, time.Second, c, "user", "test", replica2, now)
+}
+
+func TestHATracker_FailoverTimeout(t *testing.T) {
+	cfg := HATrackerConfig{
+		UpdateTimeout:          10 * time.Second,
+		UpdateTimeoutJitterMax: 5 * time.Second,
+		FailoverTimeout:        30 * time.Second,
+	}
+
+	tests := map[string]struct {
+		override time.Duration
+		expected time.Duration
+	}{
+		"no override": {
+			expected: 30 * time.Second,
+		},
+		"override": {
+			override: time.Minute,
+			expected: time.Minute,
+		},
+		"override lower than the minimum failover timeout": {
+			override: 5 * time.Second,
+			expected: 16 * time.Second,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &haTracker{cfg: cfg, limits: trackerLimits{failoverTimeout: tc.override}}
+			assert.Equal(t, tc.expected, c.failoverTimeout("user"))
+		})
+	}
+}
+
+func TestHATracker_ForceFailover(t *testing.T) {
+	replica1 := "replica1"
+	replica2 := "replica2"
+
+	kvStore, closer := consul.NewInMemoryClient(GetReplicaDescCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	c, err := newHATracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Mock: kvStore},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Second,
+	}, trackerLimits{maxClusters: 100}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	now := time.Now().Add(-time.Second)
+	require.NoError(t, c.checkReplica(context.Background(), "user", "test", replica1, now))
+
+	// Fail over to replica2 before the failover timeout.
+	now = now.Add(10 * time.Millisecond)
+	require.NoError(t, c.forceFailover(context.Background(), "user", "test", replica2, now))
+	checkReplicaTimestamp(t, time.Second, c, "user", "test", replica2, now)
+	assert.Equal(t, uint64(1), electedTerm(t, kvStore, "user", "test"))
+
+	// Refreshing the elected replica keeps the term.
+	require.NoError(t, c.forceFailover(context.Background(), "user", "test", replica2, now.Add(time.Millisecond)))
+	assert.Equal(t, uint64(1), electedTerm(t, kvStore, "user", "test"))
+	now = now.Add(time.Millisecond)
+
+	// A failover wins even if the clock of the distributor is behind the elected replica's timestamp.
+	require.NoError(t, c.forceFailover(context.Background(), "user", "test", replica1, now.Add(-time.Minute)))
+	assert.NoError(t, c.checkReplica(context.Background(), "user", "test", replica1, now))
+	assert.Equal(t, uint64(2), electedTerm(t, kvStore, "user", "test"))
+	require.NoError(t, c.forceFailover(context.Background(), "user", "test", replica2, now))
+	assert.Equal(t, uint64(3), electedTerm(t, kvStore, "user", "test"))
+
+	assert.NoError(t, c.checkReplica(context.Background(), "user", "test", replica2, now))
+	assert.Error(t, c.checkReplica(context.Background(), "user", "test", replica1, now))
+
+	// The forced election must be kept by the periodic update.
+	c.updateKVStoreAll(context.Background(), now)
+	checkReplicaTimestamp(t, time.Second, c, "user", "test", replica2, now)
+
+	// Fail over to a replica via the HTTP API.
+	req := httptest.NewRequest(http.MethodPost, "/distributor/ha_tracker/failover", strings.NewReader("tenant=user&cluster=test&replica=replica1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c.FailoverHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NoError(t, c.checkReplica(context.Background(), "user", "test", replica1, time.Now()))
+
+	req = httptest.NewRequest(http.MethodPost, "/distributor/ha_tracker/failover", strings.NewReader("tenant=user&cluster=test"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	c.FailoverHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHATracker_ForceFailover_TooManyClusters(t *testing.T) {
+	kvStore, closer := consul.NewInMemoryClient(GetReplicaDescCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	c, err := newHATracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Mock: kvStore},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Second,
+	}, trackerLimits{maxClusters: 1}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	now := time.Now()
+	require.NoError(t, c.checkReplica(context.Background(), "user", "a", "replica1", now))
+
+	// The tracked cluster can be failed over, but a new cluster can't be tracked.
+	require.NoError(t, c.forceFailover(context.Background(), "user", "a", "replica2", now))
+	assert.ErrorIs(t, c.forceFailover(context.Background(), "user", "b", "replica1", now), tooManyClustersError{limit: 1})
+
+	req := httptest.NewRequest(http.MethodPost, "/distributor/ha_tracker/failover", strings.NewReader("tenant=user&cluster=b&replica=replica1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c.FailoverHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	val, err := kvStore.Get(context.Background(), "user/b")
+	require.NoError(t, err)
+	assert.Nil(t, val)
+}
+
+func electedTerm(t *testing.T, kvStore kv.Client, userID, cluster string) uint64 {
+	val, err := kvStore.Get(context.Background(), fmt.Sprintf("%s/%s", userID, cluster))
+	require.NoError(t, err)
+	require.NotNil(t, val)
+	return val.(*ReplicaDesc).Term
+}
+
+func TestHATracker_ForceFailover_Disabled(t *testing.T) {
+	c, err := newHATracker(HATrackerConfig{EnableHATracker: false}, trackerLimits{}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	assert.Equal(t, errHATrackerDisabled, c.forceFailover(context.Background(), "user", "test", "replica1", time.Now()))
+}
+
+func TestHATracker_Memberlist(t *testing.T) {
+	replica1 := "replica1"
+	replica2 := "replica2"
+
+	var kvCfg memberlist.KVConfig
+	flagext.DefaultValues(&kvCfg)
+	kvCfg.TCPTransport.BindAddrs = []string{"127.0.0.1"}
+	kvCfg.TCPTransport.BindPort = 0
+	kvCfg.Codecs = []codec.Codec{GetReplicaDescCodec()}
+
+	mkv := memberlist.NewKV(kvCfg, log.NewNopLogger(), &dnsProviderMock{}, prometheus.NewPedanticRegistry())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv) //nolint:errcheck
+
+	c, err := newHATracker(HATrackerConfig{
+		EnableHATracker: true,
+		KVStore: kv.Config{
+			Store: "memberlist",
+			StoreConfig: kv.StoreConfig{
+				MemberlistKV: func() (*memberlist.KV, error) { return mkv, nil },
+			},
+		},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Second,
+	}, trackerLimits{maxClusters: 100}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	now := time.Now()
+	require.NoError(t, c.checkReplica(context.Background(), "user", "test", replica1, now))
+	assert.Error(t, c.checkReplica(context.Background(), "user", "test", replica2, now))
+
+	// After the failover timeout, a single request at a time fails over in-band, while the others are rejected.
+	now = now.Add(1100 * time.Millisecond)
+	c.electedLock.Lock()
+	c.clusters["user"]["test"].failoverInProgress = true
+	c.electedLock.Unlock()
+	err = c.checkReplica(context.Background(), "user", "test", replica2, now)
+	assert.Equal(t, replicasNotMatchError{replica: replica2, elected: replica1}, err)
+	checkReplicaTimestamp(t, time.Second, c, "user", "test", replica1, now.Add(-1100*time.Millisecond))
+
+	c.electedLock.Lock()
+	c.clusters["user"]["test"].failoverInProgress = false
+	c.electedLock.Unlock()
+
+	// replica2 is elected without waiting for the periodic update.
+	require.NoError(t, c.checkReplica(context.Background(), "user", "test", replica2, now))
+	c.electedLock.RLock()
+	assert.False(t, c.clusters["user"]["test"].failoverInProgress)
+	c.electedLock.RUnlock()
+	assert.Error(t, c.checkReplica(context.Background(), "user", "test", replica1, now))
+	checkReplicaTimestamp(t, time.Second, c, "user", "test", replica2, now)
+
+	// The cleanup marks the replica for deletion, and memberlist clears it as a tombstone.
+	c.cleanupOldReplicas(context.Background(), now.Add(time.Hour))
+	c.cleanupOldReplicas(context.Background(), now.Add(2*time.Hour))
+	val, err := c.client.Get(context.Background(), "user/test")
+	require.NoError(t, err)
+	require.NotNil(t, val)
+	assert.True(t, val.(*ReplicaDesc).isDeleted())
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.replicasMarkedForDeletion))
+
+	test.Poll(t, time.Second, true, func() interface{} {
+		c.electedLock.RLock()
+		defer c.electedLock.RUnlock()
+		return c.clusters["user"]["test"] == nil
+	})
+
+	// A new election wins over the deletion mark.
+	now = now.Add(3 * time.Hour)
+	require.NoError(t, c.checkReplica(context.Background(), "user", "test", replica1, now))
+	checkReplicaTimestamp(t, time.Second, c, "user", "test", replica1, now)
+}
+
+type dnsProviderMock struct{}
+
+func (p *dnsProviderMock) Resolve(_ context.Context, _ []string) error { return nil }
+
+func (p *dnsProviderMock) Addresses() []string { return nil }
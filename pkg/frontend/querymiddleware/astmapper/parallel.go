@@ -13,12 +13,22 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 )
 
+// summableAggregates is the list of aggregations which can be computed from the
+// per-shard results of one or more aggregations. STDDEV and STDVAR are not
+// included: computing them from the per-shard sum of squares, sum and count is
+// numerically unstable for large values, while the stable parallel formula over
+// the per-shard count, avg and stdvar requires more sharded queries than running
+// the aggregation unsharded.
 var summableAggregates = map[parser.ItemType]struct{}{
-	parser.SUM:   {},
-	parser.MIN:   {},
-	parser.MAX:   {},
-	parser.COUNT: {},
-	parser.AVG:   {},
+	parser.SUM:          {},
+	parser.MIN:          {},
+	parser.MAX:          {},
+	parser.COUNT:        {},
+	parser.AVG:          {},
+	parser.TOPK:         {},
+	parser.BOTTOMK:      {},
+	parser.GROUP:        {},
+	parser.COUNT_VALUES: {},
 }
 
 // NonParallelFuncs is the list of functions that shouldn't be parallelized.
@@ -53,6 +63,12 @@ func CanParallelize(node parser.Node, logger log.Logger) bool {
 			return false
 		}
 
+		// The parameter is evaluated both by the sharded and the re-aggregation queries,
+		// so it must not depend on the queried series.
+		if !isConstantParam(n.Param) {
+			return false
+		}
+
 		// Ensure there are no nested aggregations
 		nestedAggrs, err := anyNode(n.Expr, isAggregateExpr)
 
@@ -71,6 +87,7 @@ func CanParallelize(node parser.Node, logger log.Logger) bool {
 		}
 		// If n.VectorMatching is not nil, then both hands are vector operators, so none of them is a constant scalar, so we can't shard it.
 		// It is just a shortcut, but the other two operations should imply the same.
+		// Sharding binary expressions with vector matching is not supported.
 		return n.VectorMatching == nil && (parallelisable(n.LHS, n.RHS) || parallelisable(n.RHS, n.LHS))
 
 	case *parser.Call:
@@ -143,6 +160,17 @@ func isConstantScalar(n parser.Node) bool {
 	return !isNot
 }
 
+// isConstantParam returns true if the given aggregation parameter is either not set,
+// a string literal or a constant scalar.
+func isConstantParam(n parser.Expr) bool {
+	switch n.(type) {
+	case nil, *parser.StringLiteral:
+		return true
+	default:
+		return isConstantScalar(n)
+	}
+}
+
 func isNotConstantNumber(n parser.Node) (bool, error) {
 	switch n := n.(type) {
 	case nil,
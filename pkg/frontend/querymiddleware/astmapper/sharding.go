@@ -14,6 +14,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 
 	"github.com/grafana/mimir/pkg/storage/sharding"
+	"github.com/grafana/mimir/pkg/util"
 )
 
 // NewSharding creates a new query sharding mapper.
@@ -210,7 +211,7 @@ func (summer *shardSummer) shardAggregate(expr *parser.AggregateExpr, stats *Map
 			return nil, false, err
 		}
 		return mapped, true, nil
-	case parser.MAX, parser.MIN:
+	case parser.MAX, parser.MIN, parser.TOPK, parser.BOTTOMK, parser.GROUP:
 		mapped, err = summer.shardMinMax(expr, stats)
 		if err != nil {
 			return nil, false, err
@@ -222,6 +223,12 @@ func (summer *shardSummer) shardAggregate(expr *parser.AggregateExpr, stats *Map
 			return nil, false, err
 		}
 		return mapped, true, nil
+	case parser.COUNT_VALUES:
+		mapped, err = summer.shardCountValues(expr, stats)
+		if err != nil {
+			return nil, false, err
+		}
+		return mapped, true, nil
 	}
 
 	// If the aggregation operation is not shardable, we have to return the input
@@ -285,15 +292,25 @@ func (summer *shardSummer) shardCount(expr *parser.AggregateExpr, stats *MapperS
 	}, nil
 }
 
-// shardMinMax attempts to shard the given MIN/MAX aggregation expression.
+// shardMinMax attempts to shard the given MIN/MAX/TOPK/BOTTOMK/GROUP aggregation expression.
 func (summer *shardSummer) shardMinMax(expr *parser.AggregateExpr, stats *MapperStats) (result parser.Node, err error) {
-	// We expect the given aggregation is either a MIN or MAX.
-	if expr.Op != parser.MIN && expr.Op != parser.MAX {
-		return nil, errors.Errorf("expected MIN or MAX aggregation while got %s", expr.Op.String())
+	// We expect the given aggregation is either a MIN, MAX, TOPK, BOTTOMK or GROUP.
+	switch expr.Op {
+	case parser.MIN, parser.MAX, parser.TOPK, parser.BOTTOMK, parser.GROUP:
+	default:
+		return nil, errors.Errorf("expected MIN, MAX, TOPK, BOTTOMK or GROUP aggregation while got %s", expr.Op.String())
 	}
 
-	// The MIN/MAX aggregation can be parallelized as the MIN/MAX of per-shard MIN/MAX.
-	// Create a MIN/MAX sub-query for each shard and squash it into a CONCAT expression.
+	/*
+		These aggregations can be parallelized as the same aggregation of the per-shard ones,
+		because each series belongs to exactly one shard. For example, topk is representable as
+		topk by(foo) (10,
+		  topk by(foo) (10, rate(bar1{__query_shard__="0_of_2",baz="blip"}[1m])) or
+		  topk by(foo) (10, rate(bar1{__query_shard__="1_of_2",baz="blip"}[1m]))
+		)
+	*/
+
+	// Create a sub-query with the same aggregation for each shard and squash it into a CONCAT expression.
 	sharded, err := summer.shardAndSquashAggregateExpr(expr, expr.Op, stats)
 	if err != nil {
 		return nil, err
@@ -330,12 +347,52 @@ func (summer *shardSummer) shardAvg(expr *parser.AggregateExpr, stats *MapperSta
 	}, nil
 }
 
+// shardCountValues attempts to shard the given COUNT_VALUES aggregation expression.
+func (summer *shardSummer) shardCountValues(expr *parser.AggregateExpr, stats *MapperStats) (result parser.Node, err error) {
+	param, ok := expr.Param.(*parser.StringLiteral)
+	if !ok {
+		return nil, errors.Errorf("expected string literal parameter for COUNT_VALUES aggregation while got %T", expr.Param)
+	}
+
+	/*
+		The COUNT_VALUES aggregation can be parallelized as the SUM of per-shard COUNT_VALUES,
+		grouping by the output label too:
+		sum by(foo, value) (
+		  count_values by(foo) ("value", bar1{__query_shard__="0_of_2",baz="blip"}) or
+		  count_values by(foo) ("value", bar1{__query_shard__="1_of_2",baz="blip"})
+		)
+	*/
+	sharded, err := summer.shardAndSquashAggregateExpr(expr, parser.COUNT_VALUES, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	// The output label is added to the "by" grouping, like the PromQL engine does.
+	grouping := expr.Grouping
+	if !expr.Without && !util.StringsContain(grouping, param.Val) {
+		grouping = append(append(make([]string, 0, len(grouping)+1), grouping...), param.Val)
+	}
+
+	return &parser.AggregateExpr{
+		Op:       parser.SUM,
+		Expr:     sharded,
+		Grouping: grouping,
+		Without:  expr.Without,
+	}, nil
+}
+
 // shardAndSquashAggregateExpr returns a squashed CONCAT expression including N embedded
 // queries, where N is the number of shards and each sub-query queries a different shard
 // with the given "op" aggregation operation.
 func (summer *shardSummer) shardAndSquashAggregateExpr(expr *parser.AggregateExpr, op parser.ItemType, stats *MapperStats) (parser.Expr, error) {
 	children := make([]parser.Node, 0, summer.shards)
 
+	// The parameter is only preserved when the sub-queries run the original aggregation (e.g. topk).
+	var childParam parser.Expr
+	if op == expr.Op {
+		childParam = expr.Param
+	}
+
 	// Create sub-query for each shard.
 	for i := 0; i < summer.shards; i++ {
 		sharded, err := cloneAndMap(NewASTNodeMapper(summer.CopyWithCurShard(i)), expr.Expr, stats)
@@ -349,6 +406,7 @@ func (summer *shardSummer) shardAndSquashAggregateExpr(expr *parser.AggregateExp
 		children = append(children, &parser.AggregateExpr{
 			Op:       op,
 			Expr:     sharded.(parser.Expr),
+			Param:    childParam,
 			Grouping: expr.Grouping,
 			Without:  expr.Without,
 		})
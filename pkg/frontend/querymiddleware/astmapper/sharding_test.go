@@ -175,6 +175,51 @@ func TestShardSummer(t *testing.T) {
 				`)`,
 			6,
 		},
+		{
+			`topk(10, rate(foo[1m]))`,
+			`topk(10, ` + concatShards(3, `topk(10, rate(foo{__query_shard__="x_of_y"}[1m]))`) + `)`,
+			3,
+		},
+		{
+			`bottomk by (foo) (5, rate(foo[1m]))`,
+			`bottomk by (foo) (5, ` + concatShards(3, `bottomk by (foo) (5, rate(foo{__query_shard__="x_of_y"}[1m]))`) + `)`,
+			3,
+		},
+		{
+			`topk(scalar(foo), rate(foo[1m]))`,
+			concat(`topk(scalar(foo), rate(foo[1m]))`),
+			0,
+		},
+		{
+			`group without (foo) (rate(foo[1m]))`,
+			`group without (foo) (` + concatShards(3, `group without (foo) (rate(foo{__query_shard__="x_of_y"}[1m]))`) + `)`,
+			3,
+		},
+		{
+			`count_values by (foo) ("value", foo)`,
+			`sum by (foo, value) (` + concatShards(3, `count_values by (foo) ("value", foo{__query_shard__="x_of_y"})`) + `)`,
+			3,
+		},
+		{
+			`count_values without (foo) ("value", foo)`,
+			`sum without (foo) (` + concatShards(3, `count_values without (foo) ("value", foo{__query_shard__="x_of_y"})`) + `)`,
+			3,
+		},
+		{
+			`stdvar by (foo) (rate(foo[1m]))`,
+			concat(`stdvar by (foo) (rate(foo[1m]))`),
+			0,
+		},
+		{
+			`stddev(rate(foo[1m]))`,
+			concat(`stddev(rate(foo[1m]))`),
+			0,
+		},
+		{
+			`histogram_quantile(0.99, sum by (le) (rate(foo_bucket[1m])))`,
+			`histogram_quantile(0.99, sum by (le) (` + concatShards(3, `sum by (le) (rate(foo_bucket{__query_shard__="x_of_y"}[1m]))`) + `))`,
+			3,
+		},
 		{
 			`min_over_time(metric_counter[5m])`,
 			concat(`min_over_time(metric_counter[5m])`),
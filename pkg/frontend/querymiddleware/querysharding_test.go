@@ -218,6 +218,66 @@ func TestQueryShardingCorrectness(t *testing.T) {
 			query:                  `avg without(unique) (metric_counter)`,
 			expectedShardedQueries: 2, // avg() is parallelized as sum()/count().
 		},
+		"stddev() no grouping": {
+			query:                  `stddev(metric_counter{const="fixed"})`,
+			expectedShardedQueries: 0, // stddev() is not parallelized because it's numerically unstable.
+		},
+		"stddev() grouping 'by'": {
+			query:                  `stddev by(group_2) (rate(metric_counter[1m]))`,
+			expectedShardedQueries: 0,
+		},
+		"stdvar() no grouping": {
+			query:                  `stdvar(metric_counter{const="fixed"})`,
+			expectedShardedQueries: 0, // stdvar() is not parallelized because it's numerically unstable.
+		},
+		"stdvar() grouping 'without'": {
+			query:                  `stdvar without(unique) (rate(metric_counter[1m]))`,
+			expectedShardedQueries: 0,
+		},
+		"topk() no grouping": {
+			query:                  `topk(2, metric_counter{const="fixed"})`,
+			expectedShardedQueries: 1,
+		},
+		"topk() grouping 'by'": {
+			query:                  `topk by(group_1) (3, rate(metric_counter[1m]))`,
+			expectedShardedQueries: 1,
+		},
+		"bottomk() no grouping": {
+			query:                  `bottomk(2, metric_counter{const="fixed"})`,
+			expectedShardedQueries: 1,
+		},
+		"bottomk() grouping 'without'": {
+			query:                  `bottomk without(unique, group_2) (1, metric_counter{const="fixed"})`,
+			expectedShardedQueries: 1,
+		},
+		"topk() of topk()": {
+			query:                  `topk(5, topk by(group_1) (1, rate(metric_counter[1m])))`,
+			expectedShardedQueries: 1, // only the inner topk() is sharded because of the nested aggregation.
+		},
+		"group() no grouping": {
+			query:                  `group(metric_counter)`,
+			expectedShardedQueries: 1,
+		},
+		"group() grouping 'by'": {
+			query:                  `group by(group_1, group_2) (metric_counter)`,
+			expectedShardedQueries: 1,
+		},
+		"count_values() no grouping": {
+			query:                  `count_values("value", metric_histogram_bucket{le="1.000000"})`,
+			expectedShardedQueries: 1,
+		},
+		"count_values() grouping 'by'": {
+			query:                  `count_values by(group_2) ("value", metric_histogram_bucket{le="1.000000"})`,
+			expectedShardedQueries: 1,
+		},
+		"count_values() grouping 'without'": {
+			query:                  `count_values without(unique, group_1) ("value", metric_histogram_bucket{le="1.000000"})`,
+			expectedShardedQueries: 1,
+		},
+		"histogram_quantile() of sum() grouping 'by' le with a high quantile": {
+			query:                  `histogram_quantile(0.99, sum by(le) (rate(metric_histogram_bucket[1m])))`,
+			expectedShardedQueries: 1,
+		},
 		"sum(min_over_time())": {
 			query:                  `sum by (group_1, group_2) (min_over_time(metric_counter{const="fixed"}[2m]))`,
 			expectedShardedQueries: 1,
@@ -395,20 +455,8 @@ func TestQueryShardingCorrectness(t *testing.T) {
 			expectedShardedQueries: 0,
 			noRangeQuery:           true,
 		},
-		"stddev()": {
-			query:                  `stddev(metric_counter{const="fixed"})`,
-			expectedShardedQueries: 0,
-		},
-		"stdvar()": {
-			query:                  `stdvar(metric_counter{const="fixed"})`,
-			expectedShardedQueries: 0,
-		},
-		"topk()": {
-			query:                  `topk(2, metric_counter{const="fixed"})`,
-			expectedShardedQueries: 0,
-		},
-		"bottomk()": {
-			query:                  `bottomk(2, metric_counter{const="fixed"})`,
+		"topk() with non constant parameter": {
+			query:                  `topk(scalar(count(metric_counter{group_1="0"})), metric_counter{const="fixed"})`,
 			expectedShardedQueries: 0,
 		},
 		"vector()": {
@@ -594,6 +642,84 @@ func TestQueryShardingCorrectness(t *testing.T) {
 	}
 }
 
+func TestQuerySharding_ShouldReturnAccurateStddevAndStdvarWithLargeOffsetValues(t *testing.T) {
+	const numSeries = 100
+
+	// The values have a large offset and a small variance: the variance of the
+	// values 0, 1, 2 and 3 repeated evenly is 1.25.
+	series := make([]*promql.StorageSeries, 0, numSeries)
+	for i := 0; i < numSeries; i++ {
+		series = append(series, newSeries(newTestCounterLabels(i), start.Add(-lookbackDelta), end, step, constant(1e9+float64(i%4))))
+	}
+	queryable := storageSeriesQueryable(series)
+
+	tests := map[string]struct {
+		query         string
+		expectedValue float64
+	}{
+		"stddev() no grouping": {
+			query:         `stddev(metric_counter)`,
+			expectedValue: math.Sqrt(1.25),
+		},
+		"stdvar() no grouping": {
+			query:         `stdvar(metric_counter)`,
+			expectedValue: 1.25,
+		},
+		"stddev() grouping 'by'": {
+			query:         `stddev by(const) (metric_counter)`,
+			expectedValue: math.Sqrt(1.25),
+		},
+		"stdvar() grouping 'without'": {
+			query:         `stdvar without(unique, group_1, group_2) (metric_counter)`,
+			expectedValue: 1.25,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := &PrometheusInstantQueryRequest{
+				Path:  "/query",
+				Time:  util.TimeToMillis(end),
+				Query: testData.query,
+			}
+
+			engine := newEngine()
+			downstream := &downstreamHandler{
+				engine:    engine,
+				queryable: queryable,
+			}
+
+			// Run the query without sharding.
+			expectedRes, err := downstream.Do(context.Background(), req)
+			require.NoError(t, err)
+			expectedPrometheusRes := expectedRes.(*PrometheusResponse)
+
+			for _, numShards := range []int{2, 4, 8, 16} {
+				t.Run(fmt.Sprintf("shards=%d", numShards), func(t *testing.T) {
+					shardingware := newQueryShardingMiddleware(
+						log.NewNopLogger(),
+						engine,
+						mockLimits{totalShards: numShards},
+						prometheus.NewPedanticRegistry(),
+					)
+
+					// Run the query with sharding.
+					shardedRes, err := shardingware.Wrap(downstream).Do(user.InjectOrgID(context.Background(), "test"), req)
+					require.NoError(t, err)
+					shardedPrometheusRes := shardedRes.(*PrometheusResponse)
+					approximatelyEquals(t, expectedPrometheusRes, shardedPrometheusRes)
+
+					samples, err := responseToSamples(shardedPrometheusRes)
+					require.NoError(t, err)
+					require.Len(t, samples, 1)
+					require.Len(t, samples[0].Samples, 1)
+					assert.InEpsilon(t, testData.expectedValue, samples[0].Samples[0].Value, 1e-6)
+				})
+			}
+		})
+	}
+}
+
 // requireValidSamples ensures the query produces some results which are not NaN.
 func requireValidSamples(t *testing.T, result []SampleStream) {
 	t.Helper()
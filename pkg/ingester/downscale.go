@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ingester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/ring"
+	"github.com/pkg/errors"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// Number of times the blocks flushing is attempted while preparing the downscale,
+// before giving up because some blocks haven't been flushed and shipped.
+const prepareDownscaleFlushAttempts = 3
+
+var errPreparingDownscale = errors.New("the ingester is preparing for downscale and doesn't accept new series")
+
+// PrepareDownscaleState is the state of the preparation of the ingester downscale.
+type PrepareDownscaleState string
+
+const (
+	PrepareDownscaleNotRequested PrepareDownscaleState = "not_requested"
+	PrepareDownscaleInProgress   PrepareDownscaleState = "in_progress"
+	PrepareDownscaleCompleted    PrepareDownscaleState = "completed"
+	PrepareDownscaleFailed       PrepareDownscaleState = "failed"
+)
+
+// PrepareDownscaleStatus is the response of the PrepareDownscaleHandler.
+type PrepareDownscaleStatus struct {
+	State       PrepareDownscaleState `json:"state"`
+	RequestedAt *time.Time            `json:"requested_at,omitempty"`
+	CompletedAt *time.Time            `json:"completed_at,omitempty"`
+	Error       string                `json:"error,omitempty"`
+}
+
+type prepareDownscale struct {
+	// Set once the preparation has been requested. Checked when creating new series.
+	requested atomic.Bool
+
+	mtx    sync.Mutex
+	status PrepareDownscaleStatus
+}
+
+func (p *prepareDownscale) getStatus() PrepareDownscaleStatus {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.status.State == "" {
+		return PrepareDownscaleStatus{State: PrepareDownscaleNotRequested}
+	}
+	return p.status
+}
+
+// start moves the preparation to the in-progress state and returns true, unless
+// the preparation is already in progress or completed.
+func (p *prepareDownscale) start() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.status.State == PrepareDownscaleInProgress || p.status.State == PrepareDownscaleCompleted {
+		return false
+	}
+
+	now := time.Now()
+	p.status = PrepareDownscaleStatus{State: PrepareDownscaleInProgress, RequestedAt: &now}
+	p.requested.Store(true)
+	return true
+}
+
+func (p *prepareDownscale) complete(err error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	now := time.Now()
+	p.status.CompletedAt = &now
+	if err != nil {
+		p.status.State = PrepareDownscaleFailed
+		p.status.Error = err.Error()
+	} else {
+		p.status.State = PrepareDownscaleCompleted
+	}
+}
+
+// PrepareDownscaleHandler prepares the ingester to be scaled down. On POST, the preparation is
+// triggered in background: the ingester switches to the LEAVING state in the ring, so that distributors
+// write the series to other ingesters, stops creating new series, while samples are still appended
+// to the existing ones, and flushes and ships all the in-memory series to the storage. The ingester
+// is unregistered from the ring once it's shut down. The preparation can't be reverted, except by
+// restarting the ingester. Both GET and POST return the status of the preparation, which can be
+// polled to wait until it's completed.
+func (i *Ingester) PrepareDownscaleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if i.prepareDownscale.start() {
+			level.Info(i.logger).Log("msg", "preparing the ingester for downscale")
+			go func() {
+				err := i.prepareForDownscale()
+				if err != nil {
+					level.Error(i.logger).Log("msg", "failed to prepare the ingester for downscale", "err", err)
+				} else {
+					level.Info(i.logger).Log("msg", "the ingester is ready to be scaled down")
+				}
+				i.prepareDownscale.complete(err)
+			}()
+		}
+	}
+
+	util.WriteJSONResponse(w, i.prepareDownscale.getStatus())
+}
+
+func (i *Ingester) prepareForDownscale() error {
+	// Leave the ring on shutdown, so that the ingester doesn't need to be manually forgotten.
+	i.lifecycler.SetFlushOnShutdown(true)
+	i.lifecycler.SetUnregisterOnShutdown(true)
+
+	// Distributors don't write to LEAVING ingesters, and extend the replication set to another ingester
+	// when -distributor.extend-writes is enabled, while LEAVING ingesters are still queried. The state
+	// is already LEAVING if a previous preparation failed.
+	if state := i.lifecycler.GetState(); state != ring.LEAVING {
+		if err := i.lifecycler.ChangeState(context.Background(), ring.LEAVING); err != nil {
+			return errors.Wrapf(err, "failed to switch the ingester from the %s to the LEAVING state", state)
+		}
+	}
+
+	// New series have been rejected since the preparation has been requested, until distributors
+	// have seen the LEAVING state. Once the head has been compacted, series are removed from it and
+	// the samples of their next writes are rejected too, so the head is eventually empty.
+	var unflushed []string
+	for attempt := 1; attempt <= prepareDownscaleFlushAttempts; attempt++ {
+		if err := i.flushAndShipBlocks(nil); err != nil {
+			return err
+		}
+
+		if unflushed = i.getUnflushedTenants(); len(unflushed) == 0 {
+			return nil
+		}
+		level.Warn(i.logger).Log("msg", "some tenants have not been fully flushed and shipped while preparing for downscale", "attempt", attempt, "tenants", strings.Join(unflushed, ","))
+	}
+
+	return fmt.Errorf("the following tenants have not been fully flushed and shipped: %s", strings.Join(unflushed, ", "))
+}
+
+// getUnflushedTenants returns the tenants with series in the TSDB head or blocks not shipped to the storage yet.
+// Tenants marked for deletion are skipped because their blocks are never shipped.
+func (i *Ingester) getUnflushedTenants() []string {
+	var unflushed []string
+	for _, userID := range i.getTSDBUsers() {
+		db := i.getTSDB(userID)
+		if db == nil || db.deletionMarkFound.Load() {
+			continue
+		}
+
+		if db.Head().NumSeries() > 0 {
+			unflushed = append(unflushed, userID)
+			continue
+		}
+		if db.shipper != nil && db.getOldestUnshippedBlockTime() > 0 {
+			unflushed = append(unflushed, userID)
+		}
+	}
+
+	sort.Strings(unflushed)
+	return unflushed
+}
+
+// checkCanCreateSeries returns an error if new series can't be created because
+// the preparation of the downscale has been requested.
+func (p *prepareDownscale) checkCanCreateSeries() error {
+	if p.requested.Load() {
+		return errPreparingDownscale
+	}
+	return nil
+}
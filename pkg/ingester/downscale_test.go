@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ingester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+)
+
+func TestIngester_PrepareDownscaleHandler(t *testing.T) {
+	cfg := defaultIngesterTestConfig(t)
+	cfg.IngesterRing.JoinAfter = 0
+
+	i, err := prepareIngesterWithBlocksStorage(t, cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	test.Poll(t, 1*time.Second, 1, func() interface{} {
+		return i.lifecycler.HealthyInstancesCount()
+	})
+
+	getStatus := func(method string) PrepareDownscaleStatus {
+		rec := httptest.NewRecorder()
+		i.PrepareDownscaleHandler(rec, httptest.NewRequest(method, "/ingester/prepare_downscale", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var status PrepareDownscaleStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		return status
+	}
+
+	assert.Equal(t, PrepareDownscaleNotRequested, getStatus(http.MethodGet).State)
+
+	// Push some series for a couple of tenants.
+	now := time.Now()
+	for _, userID := range []string{"user-1", "user-2"} {
+		ctx := user.InjectOrgID(context.Background(), userID)
+		for n := 0; n < 10; n++ {
+			req, _, _, _ := mockWriteRequest(t, labels.Labels{{Name: labels.MetricName, Value: "test"}, {Name: "series", Value: fmt.Sprint(n)}}, 1, now.UnixMilli())
+			_, err := i.Push(ctx, req)
+			require.NoError(t, err)
+		}
+	}
+
+	// Trigger the downscale preparation and wait until completed.
+	assert.Equal(t, PrepareDownscaleInProgress, getStatus(http.MethodPost).State)
+	test.Poll(t, 5*time.Second, PrepareDownscaleCompleted, func() interface{} {
+		return getStatus(http.MethodGet).State
+	})
+
+	status := getStatus(http.MethodGet)
+	assert.Empty(t, status.Error)
+	assert.NotNil(t, status.RequestedAt)
+	assert.NotNil(t, status.CompletedAt)
+
+	// Requesting the preparation again is a no-op.
+	assert.Equal(t, PrepareDownscaleCompleted, getStatus(http.MethodPost).State)
+
+	// The ingester is LEAVING in the ring, and all series have been flushed and shipped.
+	assert.Equal(t, ring.LEAVING, i.lifecycler.GetState())
+	assert.True(t, i.lifecycler.ShouldUnregisterOnShutdown())
+	assert.Empty(t, i.getUnflushedTenants())
+	for _, userID := range []string{"user-1", "user-2"} {
+		db := i.getTSDB(userID)
+		require.NotNil(t, db)
+		assert.Zero(t, db.Head().NumSeries())
+		assert.NotEmpty(t, db.getCachedShippedBlocks())
+	}
+
+	// The flushed series have been removed from the head, so writes to them are rejected.
+	req, _, _, _ := mockWriteRequest(t, labels.Labels{{Name: labels.MetricName, Value: "test"}, {Name: "series", Value: "0"}}, 1, now.UnixMilli()+1)
+	_, err = i.Push(user.InjectOrgID(context.Background(), "user-1"), req)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusServiceUnavailable), resp.Code)
+	assert.Contains(t, string(resp.Body), errPreparingDownscale.Error())
+}
+
+func TestIngester_PrepareDownscale_ShouldRejectOnlyNewSeries(t *testing.T) {
+	i, err := prepareIngesterWithBlocksStorage(t, defaultIngesterTestConfig(t), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	test.Poll(t, 1*time.Second, 1, func() interface{} {
+		return i.lifecycler.HealthyInstancesCount()
+	})
+
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+	existing := labels.Labels{{Name: labels.MetricName, Value: "test"}, {Name: "series", Value: "existing"}}
+	now := time.Now()
+
+	req, _, _, _ := mockWriteRequest(t, existing, 1, now.UnixMilli())
+	_, err = i.Push(ctx, req)
+	require.NoError(t, err)
+
+	// Simulate the preparation has been requested but the head hasn't been flushed yet.
+	i.prepareDownscale.requested.Store(true)
+
+	// Samples are still appended to existing series.
+	req, _, _, _ = mockWriteRequest(t, existing, 2, now.UnixMilli()+1)
+	_, err = i.Push(ctx, req)
+	require.NoError(t, err)
+
+	// New series are rejected with a server error, so that the distributor doesn't count them as rejected
+	// because of a client error, even if other series in the same request have been rejected because of one.
+	req, _, _, _ = mockWriteRequest(t, labels.Labels{{Name: labels.MetricName, Value: "test"}, {Name: "series", Value: "new"}}, 1, now.UnixMilli())
+	outOfOrder, _, _, _ := mockWriteRequest(t, existing, 0, now.UnixMilli()-1)
+	req.Timeseries = append(outOfOrder.Timeseries, req.Timeseries...)
+	_, err = i.Push(ctx, req)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusServiceUnavailable), resp.Code)
+	assert.Contains(t, string(resp.Body), errPreparingDownscale.Error())
+
+	assert.Equal(t, uint64(1), i.getTSDB("user-1").Head().NumSeries())
+	assert.Equal(t, ring.ACTIVE, i.lifecycler.GetState())
+}
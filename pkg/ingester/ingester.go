@@ -73,6 +73,12 @@ const (
 	// Period at which to attempt purging metadata from memory.
 	metadataPurgePeriod = 5 * time.Minute
 
+	// Period at which to update the WAL replay progress metrics while opening a TSDB.
+	walReplayMetricsUpdatePeriod = 5 * time.Second
+
+	// Period at which to check whether the label name of the max series per label value limit has changed.
+	seriesPerLabelValueUpdatePeriod = 15 * time.Second
+
 	// IngesterRingKey is the key under which we store the ingesters ring in the KVStore.
 	IngesterRingKey = "ring"
 
@@ -234,6 +240,13 @@ type Ingester struct {
 	usersMetadataMtx sync.RWMutex
 	usersMetadata    map[string]*userMetricsMetadata
 
+	// Progress of the WAL replay of the tenants TSDBs.
+	walReplayMtx      sync.RWMutex
+	walReplayStatuses map[string]*walReplayStatus
+
+	// Preparation of the ingester downscale.
+	prepareDownscale prepareDownscale
+
 	// Rate of pushed samples. Used to limit global samples push rate.
 	ingestionRate        *util_math.EwmaRate
 	inflightPushRequests atomic.Int64
@@ -257,6 +270,7 @@ func newIngester(cfg Config, limits *validation.Overrides, registerer prometheus
 
 		tsdbs:               make(map[string]*userTSDB),
 		usersMetadata:       make(map[string]*userMetricsMetadata),
+		walReplayStatuses:   make(map[string]*walReplayStatus),
 		bucket:              bucketClient,
 		tsdbMetrics:         newTSDBMetrics(registerer),
 		forceCompactTrigger: make(chan requestWithUsersAndCallback),
@@ -447,6 +461,9 @@ func (i *Ingester) updateLoop(ctx context.Context) error {
 	metadataPurgeTicker := time.NewTicker(metadataPurgePeriod)
 	defer metadataPurgeTicker.Stop()
 
+	seriesPerLabelValueUpdateTicker := time.NewTicker(seriesPerLabelValueUpdatePeriod)
+	defer seriesPerLabelValueUpdateTicker.Stop()
+
 	for {
 		select {
 		case <-metadataPurgeTicker.C:
@@ -464,6 +481,9 @@ func (i *Ingester) updateLoop(ctx context.Context) error {
 		case <-exemplarUpdateTicker.C:
 			i.applyExemplarsSettings()
 
+		case <-seriesPerLabelValueUpdateTicker.C:
+			i.applySeriesPerLabelValueSettings()
+
 		case <-activeSeriesTickerChan:
 			i.updateActiveSeries(time.Now())
 
@@ -501,6 +521,22 @@ func (i *Ingester) updateActiveSeries(now time.Time) {
 	}
 }
 
+// Go through all tenants and rebuild the series per label value counters of the ones whose
+// configured label name has changed.
+func (i *Ingester) applySeriesPerLabelValueSettings() {
+	for _, userID := range i.getTSDBUsers() {
+		userDB := i.getTSDB(userID)
+		if userDB == nil {
+			continue
+		}
+
+		labelName := i.limits.MaxGlobalSeriesPerLabelValueLabelName(userID)
+		if err := userDB.seriesInLabelValue.updateLabelName(labelName, userDB.Head()); err != nil {
+			level.Warn(i.logger).Log("msg", "failed to rebuild the series per label value counters", "user", userID, "label", labelName, "err", err)
+		}
+	}
+}
+
 // Go through all tenants and apply the current max-exemplars setting.
 // If it changed, tsdb will resize the buffer; if it didn't change tsdb will return quickly.
 func (i *Ingester) applyExemplarsSettings() {
@@ -540,12 +576,11 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReques
 	// retain anything from `req` past the exit from this function.
 	defer cleanup()
 
-	var firstPartialErr error
+	var firstPartialErr, preparingDownscaleErr error
 
 	if err := i.checkRunning(); err != nil {
 		return nil, err
 	}
-
 	// We will report *this* request in the error too.
 	inflight := i.inflightPushRequests.Inc()
 	defer i.inflightPushRequests.Dec()
@@ -598,16 +633,17 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReques
 	// Keep track of some stats which are tracked only if the samples will be
 	// successfully committed
 	var (
-		succeededSamplesCount     = 0
-		failedSamplesCount        = 0
-		succeededExemplarsCount   = 0
-		failedExemplarsCount      = 0
-		startAppend               = time.Now()
-		sampleOutOfBoundsCount    = 0
-		sampleOutOfOrderCount     = 0
-		newValueForTimestampCount = 0
-		perUserSeriesLimitCount   = 0
-		perMetricSeriesLimitCount = 0
+		succeededSamplesCount         = 0
+		failedSamplesCount            = 0
+		succeededExemplarsCount       = 0
+		failedExemplarsCount          = 0
+		startAppend                   = time.Now()
+		sampleOutOfBoundsCount        = 0
+		sampleOutOfOrderCount         = 0
+		newValueForTimestampCount     = 0
+		perUserSeriesLimitCount       = 0
+		perMetricSeriesLimitCount     = 0
+		perLabelValueSeriesLimitCount = 0
 
 		minAppendTime, minAppendTimeAvailable = db.Head().AppendableMinValidTime()
 
@@ -700,6 +736,20 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReques
 					return makeMetricLimitError(perMetricSeriesLimit, copiedLabels, i.limiter.FormatError(userID, cause))
 				})
 				continue
+
+			case errMaxSeriesPerLabelValueLimitExceeded:
+				perLabelValueSeriesLimitCount++
+				updateFirstPartial(func() error {
+					return makeMetricLimitError(perLabelValueSeriesLimit, copiedLabels, i.limiter.FormatMaxSeriesPerLabelValueError(userID, copiedLabels))
+				})
+				continue
+
+			case errPreparingDownscale:
+				// Not returned as a partial error, because the series haven't been rejected for a client error.
+				if preparingDownscaleErr == nil {
+					preparingDownscaleErr = wrappedTSDBIngestErr(err, model.Time(s.TimestampMs), ts.Labels)
+				}
+				continue
 			}
 
 			// The error looks an issue on our side, so we should rollback
@@ -795,6 +845,9 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReques
 	if perMetricSeriesLimitCount > 0 {
 		validation.DiscardedSamples.WithLabelValues(perMetricSeriesLimit, userID).Add(float64(perMetricSeriesLimitCount))
 	}
+	if perLabelValueSeriesLimitCount > 0 {
+		validation.DiscardedSamples.WithLabelValues(perLabelValueSeriesLimit, userID).Add(float64(perLabelValueSeriesLimitCount))
+	}
 	if succeededSamplesCount > 0 {
 		i.ingestionRate.Add(int64(succeededSamplesCount))
 
@@ -808,6 +861,13 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, req *mimirpb.WriteReques
 		}
 	}
 
+	if preparingDownscaleErr != nil {
+		// The distributor doesn't know yet the ingester is LEAVING, so we return a server error to count
+		// the ingester as failed: the series are still written to the other ingesters of the replication
+		// set, and the client retries if the quorum hasn't been reached.
+		return &mimirpb.WriteResponse{}, httpgrpc.Errorf(http.StatusServiceUnavailable, wrapWithUser(preparingDownscaleErr, userID).Error())
+	}
+
 	if firstPartialErr != nil {
 		code := http.StatusBadRequest
 		var ve *validationError
@@ -1449,13 +1509,23 @@ func (i *Ingester) createTSDB(userID string) (*userTSDB, error) {
 		userID:              userID,
 		activeSeries:        NewActiveSeries(i.activeSeriesMatcher),
 		seriesInMetric:      newMetricCounter(i.limiter, i.cfg.getIgnoreSeriesLimitForMetricNamesMap()),
+		seriesInLabelValue:  newLabelValueCounter(i.limiter, i.limits.MaxGlobalSeriesPerLabelValueLabelName(userID)),
 		ingestedAPISamples:  util_math.NewEWMARate(0.2, i.cfg.RateUpdatePeriod),
 		ingestedRuleSamples: util_math.NewEWMARate(0.2, i.cfg.RateUpdatePeriod),
 
 		instanceLimitsFn:    i.getInstanceLimits,
 		instanceSeriesCount: &i.seriesCount,
+		prepareDownscale:    &i.prepareDownscale,
 	}
 
+	// Track the WAL replay progress through the TSDB head stats.
+	dbStats := tsdb.NewDBStats()
+	walReplay := i.startWALReplay(userID, dbStats.Head.WALReplayStatus)
+	userDB.walReplay = walReplay
+
+	walReplayDone := make(chan struct{})
+	go walReplay.updateMetricsUntilDone(walReplayDone)
+
 	maxExemplars := i.limiter.convertGlobalToLocalLimit(userID, i.limits.MaxGlobalExemplarsPerUser(userID))
 	// Create a new user database
 	db, err := tsdb.Open(udir, userLogger, tsdbPromReg, &tsdb.Options{
@@ -1476,10 +1546,28 @@ func (i *Ingester) createTSDB(userID string) (*userTSDB, error) {
 		EnableMemorySnapshotOnShutdown: i.cfg.BlocksStorageConfig.TSDB.MemorySnapshotOnShutdown,
 		IsolationDisabled:              !i.cfg.BlocksStorageConfig.TSDB.IsolationEnabled,
 		HeadChunksWriteQueueSize:       i.cfg.BlocksStorageConfig.TSDB.HeadChunksWriteQueueSize,
-	}, nil)
+	}, dbStats)
+	close(walReplayDone)
+	userDB.walReplay = nil
 	if err != nil {
+		walReplay.complete(err)
 		return nil, errors.Wrapf(err, "failed to open TSDB: %s", udir)
 	}
+
+	if i.cfg.BlocksStorageConfig.TSDB.MemorySnapshotOnShutdown {
+		failed, err := chunkSnapshotReplayFailed(tsdbPromReg)
+		if err != nil {
+			level.Warn(userLogger).Log("msg", "failed to check whether the chunk snapshot has been replayed", "err", err)
+		}
+		if failed {
+			if err := i.discardChunkSnapshot(db, udir, walReplay, userLogger); err != nil {
+				walReplay.complete(err)
+				return nil, errors.Wrapf(err, "failed to discard the chunk snapshot of TSDB: %s", udir)
+			}
+			return i.createTSDB(userID)
+		}
+	}
+	walReplay.complete(nil)
 	db.DisableCompactions() // we will compact on our own schedule
 
 	// Run compaction before using this TSDB. If there is data in head that needs to be put into blocks,
@@ -1968,6 +2056,7 @@ func (i *Ingester) closeAndDeleteUserTSDBIfIdle(userID string) tsdbCloseCheckRes
 	i.tsdbMetrics.removeRegistryForUser(userID)
 
 	i.deleteUserMetadata(userID)
+	i.deleteWALReplayStatus(userID)
 	i.metrics.deletePerUserMetrics(userID)
 
 	validation.DeletePerUserValidationMetrics(userID, i.logger)
@@ -2016,69 +2105,73 @@ const (
 	waitParam   = "wait"
 )
 
-// Blocks version of Flush handler. It force-compacts blocks, and triggers shipping.
-func (i *Ingester) FlushHandler(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
-	if err != nil {
-		level.Warn(i.logger).Log("msg", "failed to parse HTTP request in flush handler", "err", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+// flushAndShipBlocks force-compacts the TSDB heads of the allowed users and ships the blocks
+// to the storage, waiting until both have been completed.
+func (i *Ingester) flushAndShipBlocks(allowedUsers *util.AllowedTenants) error {
+	ingCtx := i.BasicService.ServiceContext()
+	if ingCtx == nil || ingCtx.Err() != nil {
+		return errors.New("ingester not running")
 	}
 
-	tenants := r.Form[tenantParam]
+	compactionCallbackCh := make(chan struct{})
 
-	allowedUsers := util.NewAllowedTenants(tenants, nil)
-	run := func() {
-		ingCtx := i.BasicService.ServiceContext()
-		if ingCtx == nil || ingCtx.Err() != nil {
-			level.Info(i.logger).Log("msg", "flushing TSDB blocks: ingester not running, ignoring flush request")
-			return
-		}
+	level.Info(i.logger).Log("msg", "flushing TSDB blocks: triggering compaction")
+	select {
+	case i.forceCompactTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: compactionCallbackCh}:
+		// Compacting now.
+	case <-ingCtx.Done():
+		return errors.New("failed to compact TSDB blocks, ingester not running anymore")
+	}
+
+	// Wait until notified about compaction being finished.
+	select {
+	case <-compactionCallbackCh:
+		level.Info(i.logger).Log("msg", "finished compacting TSDB blocks")
+	case <-ingCtx.Done():
+		return errors.New("failed to compact TSDB blocks, ingester not running anymore")
+	}
+
+	if i.cfg.BlocksStorageConfig.TSDB.IsBlocksShippingEnabled() {
+		shippingCallbackCh := make(chan struct{}) // must be new channel, as compactionCallbackCh is closed now.
 
-		compactionCallbackCh := make(chan struct{})
+		level.Info(i.logger).Log("msg", "flushing TSDB blocks: triggering shipping")
 
-		level.Info(i.logger).Log("msg", "flushing TSDB blocks: triggering compaction")
 		select {
-		case i.forceCompactTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: compactionCallbackCh}:
-			// Compacting now.
+		case i.shipTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: shippingCallbackCh}:
+			// shipping now
 		case <-ingCtx.Done():
-			level.Warn(i.logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
-			return
+			return errors.New("failed to ship TSDB blocks, ingester not running anymore")
 		}
 
-		// Wait until notified about compaction being finished.
+		// Wait until shipping finished.
 		select {
-		case <-compactionCallbackCh:
-			level.Info(i.logger).Log("msg", "finished compacting TSDB blocks")
+		case <-shippingCallbackCh:
+			level.Info(i.logger).Log("msg", "shipping of TSDB blocks finished")
 		case <-ingCtx.Done():
-			level.Warn(i.logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
-			return
+			return errors.New("failed to ship TSDB blocks, ingester not running anymore")
 		}
+	}
 
-		if i.cfg.BlocksStorageConfig.TSDB.IsBlocksShippingEnabled() {
-			shippingCallbackCh := make(chan struct{}) // must be new channel, as compactionCallbackCh is closed now.
+	level.Info(i.logger).Log("msg", "flushing TSDB blocks: finished")
+	return nil
+}
 
-			level.Info(i.logger).Log("msg", "flushing TSDB blocks: triggering shipping")
+// Blocks version of Flush handler. It force-compacts blocks, and triggers shipping.
+func (i *Ingester) FlushHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		level.Warn(i.logger).Log("msg", "failed to parse HTTP request in flush handler", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-			select {
-			case i.shipTrigger <- requestWithUsersAndCallback{users: allowedUsers, callback: shippingCallbackCh}:
-				// shipping now
-			case <-ingCtx.Done():
-				level.Warn(i.logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
-				return
-			}
+	tenants := r.Form[tenantParam]
 
-			// Wait until shipping finished.
-			select {
-			case <-shippingCallbackCh:
-				level.Info(i.logger).Log("msg", "shipping of TSDB blocks finished")
-			case <-ingCtx.Done():
-				level.Warn(i.logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
-				return
-			}
+	allowedUsers := util.NewAllowedTenants(tenants, nil)
+	run := func() {
+		if err := i.flushAndShipBlocks(allowedUsers); err != nil {
+			level.Warn(i.logger).Log("msg", "flushing TSDB blocks failed", "err", err)
 		}
-
-		level.Info(i.logger).Log("msg", "flushing TSDB blocks: finished")
 	}
 
 	if len(r.Form[waitParam]) > 0 && r.Form[waitParam][0] == "true" {
@@ -2148,6 +2241,50 @@ func (i *Ingester) ShutdownHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// SeriesPerLabelValueResponse is the response of the SeriesPerLabelValueHandler.
+type SeriesPerLabelValueResponse struct {
+	LabelName string                     `json:"label_name"`
+	Values    []SeriesPerLabelValueUsage `json:"values"`
+}
+
+// SeriesPerLabelValueUsage holds the current usage of the series per label value limit for a label value.
+type SeriesPerLabelValueUsage struct {
+	LabelValue string `json:"label_value"`
+	Series     int    `json:"series"`
+	LocalLimit int    `json:"local_limit"`
+}
+
+// SeriesPerLabelValueHandler returns the number of in-memory series of the tenant per value
+// of the label configured in the max series per label value limit, along with the per-ingester limit.
+func (i *Ingester) SeriesPerLabelValueHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := SeriesPerLabelValueResponse{
+		LabelName: i.limits.MaxGlobalSeriesPerLabelValueLabelName(userID),
+		Values:    []SeriesPerLabelValueUsage{},
+	}
+
+	// The counters are rebuilt periodically when the configured label name changes, so they're
+	// not reported if they don't reflect the currently configured label name yet.
+	if db := i.getTSDB(userID); db != nil && resp.LabelName != "" {
+		if labelName, values := db.seriesInLabelValue.seriesPerLabelValue(); labelName == resp.LabelName {
+			for _, v := range values {
+				resp.Values = append(resp.Values, SeriesPerLabelValueUsage{
+					LabelValue: v.LabelValue,
+					Series:     v.Series,
+					LocalLimit: i.limiter.convertGlobalToLocalLimit(userID, i.limits.MaxGlobalSeriesPerLabelValue(userID, v.LabelValue)),
+				})
+			}
+		}
+	}
+
+	util.WriteJSONResponse(w, resp)
+}
+
 // Using block store, the ingester is only available when it is in a Running state. The ingester is not available
 // when stopping to prevent any read or writes to the TSDB after the ingester has closed them.
 func (i *Ingester) checkRunning() error {
@@ -147,6 +147,33 @@ func (i *ActivityTrackerWrapper) ShutdownHandler(w http.ResponseWriter, r *http.
 	i.ing.ShutdownHandler(w, r)
 }
 
+func (i *ActivityTrackerWrapper) SeriesPerLabelValueHandler(w http.ResponseWriter, r *http.Request) {
+	ix := i.tracker.Insert(func() string {
+		return requestActivity(r.Context(), "Ingester/SeriesPerLabelValueHandler", nil)
+	})
+	defer i.tracker.Delete(ix)
+
+	i.ing.SeriesPerLabelValueHandler(w, r)
+}
+
+func (i *ActivityTrackerWrapper) WALReplayStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ix := i.tracker.Insert(func() string {
+		return requestActivity(r.Context(), "Ingester/WALReplayStatusHandler", nil)
+	})
+	defer i.tracker.Delete(ix)
+
+	i.ing.WALReplayStatusHandler(w, r)
+}
+
+func (i *ActivityTrackerWrapper) PrepareDownscaleHandler(w http.ResponseWriter, r *http.Request) {
+	ix := i.tracker.Insert(func() string {
+		return requestActivity(r.Context(), "Ingester/PrepareDownscaleHandler", nil)
+	})
+	defer i.tracker.Delete(ix)
+
+	i.ing.PrepareDownscaleHandler(w, r)
+}
+
 func requestActivity(ctx context.Context, name string, req interface{}) string {
 	userID, _ := tenant.TenantID(ctx)
 	traceID, _ := tracing.ExtractSampledTraceID(ctx)
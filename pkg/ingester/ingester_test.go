@@ -11,6 +11,7 @@ package ingester
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -2844,6 +2845,15 @@ func prepareIngesterWithBlocksStorage(t testing.TB, ingesterCfg Config, register
 }
 
 func prepareIngesterWithBlocksStorageAndLimits(t testing.TB, ingesterCfg Config, limits validation.Limits, dataDir string, registerer prometheus.Registerer) (*Ingester, error) {
+	overrides, err := validation.NewOverrides(limits, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return prepareIngesterWithBlocksStorageAndOverrides(t, ingesterCfg, overrides, dataDir, registerer)
+}
+
+func prepareIngesterWithBlocksStorageAndOverrides(t testing.TB, ingesterCfg Config, overrides *validation.Overrides, dataDir string, registerer prometheus.Registerer) (*Ingester, error) {
 	// Create a data dir if none has been provided.
 	if dataDir == "" {
 		var err error
@@ -2867,11 +2877,6 @@ func prepareIngesterWithBlocksStorageAndLimits(t testing.TB, ingesterCfg Config,
 
 	clientCfg := defaultClientTestConfig()
 
-	overrides, err := validation.NewOverrides(limits, nil)
-	if err != nil {
-		return nil, err
-	}
-
 	ingesterCfg.BlocksStorageConfig.TSDB.Dir = dataDir
 	ingesterCfg.BlocksStorageConfig.Bucket.Backend = "filesystem"
 	ingesterCfg.BlocksStorageConfig.Bucket.Filesystem.Directory = bucketDir
@@ -5302,3 +5307,201 @@ func TestGetIgnoreSeriesLimitForMetricNamesMap(t *testing.T) {
 	cfg.IgnoreSeriesLimitForMetricNames = "foo, bar, ,"
 	require.Equal(t, map[string]struct{}{"foo": {}, "bar": {}}, cfg.getIgnoreSeriesLimitForMetricNamesMap())
 }
+
+func TestIngesterSeriesPerLabelValueLimitExceeded(t *testing.T) {
+	limits := defaultLimitsTestConfig()
+	limits.MaxGlobalSeriesPerLabelValueLabelName = "team"
+	limits.MaxGlobalSeriesPerLabelValue = 1
+	limits.MaxGlobalSeriesPerLabelValueOverrides = map[string]int{"b": 2}
+
+	// create a data dir that survives an ingester restart
+	dataDir := t.TempDir()
+
+	newIngester := func() *Ingester {
+		cfg := defaultIngesterTestConfig(t)
+		cfg.IngesterRing.ReplicationFactor = 1
+		ing, err := prepareIngesterWithBlocksStorageAndLimits(t, cfg, limits, dataDir, nil)
+		require.NoError(t, err)
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), ing))
+
+		// Wait until it's healthy
+		test.Poll(t, time.Second, 1, func() interface{} {
+			return ing.lifecycler.HealthyInstancesCount()
+		})
+
+		return ing
+	}
+
+	ing := newIngester()
+	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+
+	userID := "1"
+	ctx := user.InjectOrgID(context.Background(), userID)
+	series := func(name, team string) labels.Labels {
+		if team == "" {
+			return labels.Labels{{Name: labels.MetricName, Value: name}}
+		}
+		return labels.Labels{{Name: labels.MetricName, Value: name}, {Name: "team", Value: team}}
+	}
+	push := func(ts int64, lbls ...labels.Labels) error {
+		samples := make([]mimirpb.Sample, 0, len(lbls))
+		for range lbls {
+			samples = append(samples, mimirpb.Sample{TimestampMs: ts, Value: 1})
+		}
+		_, err := ing.Push(ctx, mimirpb.ToWriteRequest(lbls, samples, nil, nil, mimirpb.API))
+		return err
+	}
+
+	require.NoError(t, push(0, series("m1", "a"), series("m1", "b"), series("m2", "b"), series("m1", ""), series("m2", "")))
+
+	testLimits := func(ts int64) {
+		for _, lbls := range []labels.Labels{series("m2", "a"), series("m3", "b")} {
+			err := push(ts, lbls)
+			httpResp, ok := httpgrpc.HTTPResponseFromError(err)
+			require.True(t, ok, "returned error is not an httpgrpc response")
+			assert.Equal(t, http.StatusBadRequest, int(httpResp.Code))
+			assert.Equal(t, wrapWithUser(makeMetricLimitError(perLabelValueSeriesLimit, lbls, ing.limiter.FormatMaxSeriesPerLabelValueError(userID, lbls)), userID).Error(), string(httpResp.Body))
+		}
+
+		// Series without the label and existing series are not limited.
+		require.NoError(t, push(ts, series("m1", "a"), series("m3", "")))
+
+		req := httptest.NewRequest("GET", "/ingester/series_per_label_value", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		ing.SeriesPerLabelValueHandler(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp SeriesPerLabelValueResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, SeriesPerLabelValueResponse{
+			LabelName: "team",
+			Values: []SeriesPerLabelValueUsage{
+				{LabelValue: "a", Series: 1, LocalLimit: 1},
+				{LabelValue: "b", Series: 2, LocalLimit: 2},
+			},
+		}, resp)
+	}
+
+	testLimits(1)
+
+	// Limits should hold after restart.
+	services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+	ing = newIngester()
+	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+
+	testLimits(2)
+}
+
+func TestIngesterSeriesPerLabelValueLimitLabelNameChanged(t *testing.T) {
+	limits := defaultLimitsTestConfig()
+	tenantLimits := &seriesPerLabelValueTenantLimits{}
+	overrides, err := validation.NewOverrides(limits, tenantLimits)
+	require.NoError(t, err)
+
+	cfg := defaultIngesterTestConfig(t)
+	cfg.IngesterRing.ReplicationFactor = 1
+	ing, err := prepareIngesterWithBlocksStorageAndOverrides(t, cfg, overrides, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), ing))
+	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+
+	// Wait until it's healthy
+	test.Poll(t, time.Second, 1, func() interface{} {
+		return ing.lifecycler.HealthyInstancesCount()
+	})
+
+	userID := "1"
+	ctx := user.InjectOrgID(context.Background(), userID)
+	push := func(name, team string) error {
+		lbls := labels.Labels{{Name: labels.MetricName, Value: name}, {Name: "team", Value: team}}
+		_, err := ing.Push(ctx, mimirpb.ToWriteRequest([]labels.Labels{lbls}, []mimirpb.Sample{{TimestampMs: 1, Value: 1}}, nil, nil, mimirpb.API))
+		return err
+	}
+	seriesPerLabelValue := func() SeriesPerLabelValueResponse {
+		rec := httptest.NewRecorder()
+		ing.SeriesPerLabelValueHandler(rec, httptest.NewRequest("GET", "/ingester/series_per_label_value", nil).WithContext(ctx))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp SeriesPerLabelValueResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	require.NoError(t, push("m1", "a"))
+	require.NoError(t, push("m2", "a"))
+
+	// Enable the limit at runtime: it's not enforced until the counters have been rebuilt.
+	tenantLimits.setLabelName(limits, "team", 2)
+	require.NoError(t, push("m3", "a"))
+	assert.Equal(t, SeriesPerLabelValueResponse{LabelName: "team", Values: []SeriesPerLabelValueUsage{}}, seriesPerLabelValue())
+
+	ing.applySeriesPerLabelValueSettings()
+	assert.Equal(t, SeriesPerLabelValueResponse{
+		LabelName: "team",
+		Values:    []SeriesPerLabelValueUsage{{LabelValue: "a", Series: 3, LocalLimit: 2}},
+	}, seriesPerLabelValue())
+
+	// The counters are now updated on series creation.
+	require.Error(t, push("m4", "a"))
+	require.NoError(t, push("m1", "b"))
+	assert.Equal(t, SeriesPerLabelValueResponse{
+		LabelName: "team",
+		Values: []SeriesPerLabelValueUsage{
+			{LabelValue: "a", Series: 3, LocalLimit: 2},
+			{LabelValue: "b", Series: 1, LocalLimit: 2},
+		},
+	}, seriesPerLabelValue())
+}
+
+func TestLabelValueCounter_ShouldBlockUpdatesWhileRebuilding(t *testing.T) {
+	c := newLabelValueCounter(nil, "")
+	series := labels.Labels{{Name: labels.MetricName, Value: "m1"}, {Name: "team", Value: "a"}}
+
+	// Simulate a rebuild in progress: the series created meanwhile is counted once the counters are rebuilt.
+	c.resetMtx.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.increaseSeries(series)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("the counters have been updated while rebuilding")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.labelName.Store("team")
+	c.resetMtx.Unlock()
+	<-done
+
+	labelName, values := c.seriesPerLabelValue()
+	assert.Equal(t, "team", labelName)
+	assert.Equal(t, []labelValueSeries{{LabelValue: "a", Series: 1}}, values)
+}
+
+// seriesPerLabelValueTenantLimits is a validation.TenantLimits whose max series per label value
+// limit can be changed while the ingester is running.
+type seriesPerLabelValueTenantLimits struct {
+	mtx    sync.Mutex
+	limits *validation.Limits
+}
+
+func (l *seriesPerLabelValueTenantLimits) setLabelName(defaults validation.Limits, labelName string, limit int) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	defaults.MaxGlobalSeriesPerLabelValueLabelName = labelName
+	defaults.MaxGlobalSeriesPerLabelValue = limit
+	l.limits = &defaults
+}
+
+func (l *seriesPerLabelValueTenantLimits) ByUserID(string) *validation.Limits {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.limits
+}
+
+func (l *seriesPerLabelValueTenantLimits) AllByUserID() map[string]*validation.Limits {
+	return nil
+}
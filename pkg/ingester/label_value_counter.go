@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ingester
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/segmentio/fasthash/fnv1a"
+	"go.uber.org/atomic"
+)
+
+// DiscardedSamples metric labels
+const (
+	perLabelValueSeriesLimit = "per_label_value_series_limit"
+)
+
+// labelValueCounter tracks the number of in-memory series per value of the label configured
+// for the tenant in the max series per label value limit. Series without the label are not tracked.
+// The counters are updated on series creation and deletion, and only rebuilt from the head when the
+// configured label name changes.
+type labelValueCounter struct {
+	limiter *Limiter
+	shards  []metricCounterShard
+
+	// Name of the label whose values are tracked. Empty if the limit is disabled.
+	labelName atomic.String
+
+	// Held for reading while the counters are updated, and for writing while they're rebuilt when the
+	// configured label name changes, so that no series is created or deleted during the rebuild.
+	resetMtx sync.RWMutex
+}
+
+// newLabelValueCounter makes a new labelValueCounter tracking the input label name. It should be created
+// before the TSDB head is replayed, so that the counters are built while series are created.
+func newLabelValueCounter(limiter *Limiter, labelName string) *labelValueCounter {
+	shards := make([]metricCounterShard, 0, numMetricCounterShards)
+	for i := 0; i < numMetricCounterShards; i++ {
+		shards = append(shards, metricCounterShard{
+			m: map[string]int{},
+		})
+	}
+	c := &labelValueCounter{
+		limiter: limiter,
+		shards:  shards,
+	}
+	c.labelName.Store(labelName)
+	return c
+}
+
+func (c *labelValueCounter) getShard(labelValue string) *metricCounterShard {
+	return &c.shards[hashFP(model.Fingerprint(fnv1a.HashString64(labelValue)))%numMetricCounterShards]
+}
+
+// canAddSeries returns an error if the series can't be created because its label value has reached the limit.
+// If the label name configured for the tenant has changed, the limit is not enforced until the counters
+// have been rebuilt by updateLabelName.
+func (c *labelValueCounter) canAddSeries(userID string, series labels.Labels) error {
+	labelName := c.limiter.limits.MaxGlobalSeriesPerLabelValueLabelName(userID)
+	if labelName == "" || labelName != c.labelName.Load() {
+		return nil
+	}
+
+	labelValue := series.Get(labelName)
+	if labelValue == "" {
+		return nil
+	}
+
+	shard := c.getShard(labelValue)
+	shard.mtx.Lock()
+	defer shard.mtx.Unlock()
+
+	return c.limiter.AssertMaxSeriesPerLabelValue(userID, labelValue, shard.m[labelValue])
+}
+
+func (c *labelValueCounter) increaseSeries(series labels.Labels) {
+	c.resetMtx.RLock()
+	defer c.resetMtx.RUnlock()
+
+	labelValue := c.labelValue(series)
+	if labelValue == "" {
+		return
+	}
+
+	shard := c.getShard(labelValue)
+	shard.mtx.Lock()
+	shard.m[labelValue]++
+	shard.mtx.Unlock()
+}
+
+func (c *labelValueCounter) decreaseSeries(series labels.Labels) {
+	c.resetMtx.RLock()
+	defer c.resetMtx.RUnlock()
+
+	labelValue := c.labelValue(series)
+	if labelValue == "" {
+		return
+	}
+
+	shard := c.getShard(labelValue)
+	shard.mtx.Lock()
+	defer shard.mtx.Unlock()
+
+	if shard.m[labelValue] <= 1 {
+		delete(shard.m, labelValue)
+		return
+	}
+	shard.m[labelValue]--
+}
+
+func (c *labelValueCounter) labelValue(series labels.Labels) string {
+	labelName := c.labelName.Load()
+	if labelName == "" {
+		return ""
+	}
+	return series.Get(labelName)
+}
+
+// updateLabelName rebuilds the counters from the series in the head if the input label name differs
+// from the tracked one. It's O(series with the label), so it must not be called in the write path.
+// The updates of the counters are blocked during the rebuild: since the head calls the series lifecycle
+// callbacks before updating its postings, a series whose creation or deletion is blocked is counted by its
+// callback once the counters are rebuilt, and not by the scan of the postings.
+func (c *labelValueCounter) updateLabelName(labelName string, head *tsdb.Head) error {
+	c.resetMtx.Lock()
+	defer c.resetMtx.Unlock()
+
+	// Nothing to do if the label name hasn't changed.
+	if labelName == c.labelName.Load() {
+		return nil
+	}
+
+	counts := map[string]int{}
+	if labelName != "" {
+		idx, err := head.Index()
+		if err != nil {
+			return err
+		}
+		defer idx.Close()
+
+		values, err := idx.LabelValues(labelName)
+		if err != nil {
+			return err
+		}
+		for _, value := range values {
+			p, err := idx.Postings(labelName, value)
+			if err != nil {
+				return err
+			}
+			for p.Next() {
+				counts[value]++
+			}
+			if err := p.Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range c.shards {
+		c.shards[i].mtx.Lock()
+		c.shards[i].m = map[string]int{}
+		c.shards[i].mtx.Unlock()
+	}
+	for value, count := range counts {
+		shard := c.getShard(value)
+		shard.mtx.Lock()
+		shard.m[value] = count
+		shard.mtx.Unlock()
+	}
+	c.labelName.Store(labelName)
+
+	return nil
+}
+
+// labelValueSeries holds the number of in-memory series for a label value.
+type labelValueSeries struct {
+	LabelValue string `json:"label_value"`
+	Series     int    `json:"series"`
+}
+
+// seriesPerLabelValue returns the number of in-memory series per label value, sorted by label value.
+func (c *labelValueCounter) seriesPerLabelValue() (string, []labelValueSeries) {
+	var result []labelValueSeries
+	for i := range c.shards {
+		c.shards[i].mtx.Lock()
+		for value, count := range c.shards[i].m {
+			result = append(result, labelValueSeries{LabelValue: value, Series: count})
+		}
+		c.shards[i].mtx.Unlock()
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LabelValue < result[j].LabelValue
+	})
+	return c.labelName.Load(), result
+}
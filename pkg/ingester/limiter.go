@@ -10,6 +10,7 @@ import (
 	"math"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/grafana/mimir/pkg/util"
 	util_math "github.com/grafana/mimir/pkg/util/math"
@@ -21,6 +22,8 @@ var (
 	errMaxMetadataPerMetricLimitExceeded = errors.New("per-metric metadata limit exceeded")
 	errMaxSeriesPerUserLimitExceeded     = errors.New("per-user series limit exceeded")
 	errMaxMetadataPerUserLimitExceeded   = errors.New("per-user metric metadata limit exceeded")
+
+	errMaxSeriesPerLabelValueLimitExceeded = errors.New("per-label-value series limit exceeded")
 )
 
 // RingCount is the interface exposed by a ring implementation which allows
@@ -64,6 +67,16 @@ func (l *Limiter) AssertMaxSeriesPerMetric(userID string, series int) error {
 	return errMaxSeriesPerMetricLimitExceeded
 }
 
+// AssertMaxSeriesPerLabelValue limit has not been reached compared to the current
+// number of series with the given label value in input and returns an error if so.
+func (l *Limiter) AssertMaxSeriesPerLabelValue(userID, labelValue string, series int) error {
+	if actualLimit := l.maxSeriesPerLabelValue(userID, labelValue); series < actualLimit {
+		return nil
+	}
+
+	return errMaxSeriesPerLabelValueLimitExceeded
+}
+
 // AssertMaxMetadataPerMetric limit has not been reached compared to the current
 // number of metadata per metric in input and returns an error if so.
 func (l *Limiter) AssertMaxMetadataPerMetric(userID string, metadata int) error {
@@ -127,6 +140,18 @@ func (l *Limiter) formatMaxSeriesPerMetricError(userID string) error {
 		globalLimit, actualLimit)
 }
 
+// FormatMaxSeriesPerLabelValueError returns the per-label-value series limit error enriched with the
+// actual limits for the given user and the label value of the input series.
+func (l *Limiter) FormatMaxSeriesPerLabelValueError(userID string, series labels.Labels) error {
+	labelName := l.limits.MaxGlobalSeriesPerLabelValueLabelName(userID)
+	labelValue := series.Get(labelName)
+	actualLimit := l.maxSeriesPerLabelValue(userID, labelValue)
+	globalLimit := l.limits.MaxGlobalSeriesPerLabelValue(userID, labelValue)
+
+	return fmt.Errorf("per-label-value series limit of %d for %s=%q exceeded, please contact administrator to raise it (per-ingester local limit: %d)",
+		globalLimit, labelName, labelValue, actualLimit)
+}
+
 func (l *Limiter) formatMaxMetadataPerUserError(userID string) error {
 	actualLimit := l.maxMetadataPerUser(userID)
 	globalLimit := l.limits.MaxGlobalMetricsWithMetadataPerUser(userID)
@@ -147,6 +172,12 @@ func (l *Limiter) maxSeriesPerMetric(userID string) int {
 	return l.convertGlobalToLocalLimitOrUnlimited(userID, l.limits.MaxGlobalSeriesPerMetric)
 }
 
+func (l *Limiter) maxSeriesPerLabelValue(userID, labelValue string) int {
+	return l.convertGlobalToLocalLimitOrUnlimited(userID, func(userID string) int {
+		return l.limits.MaxGlobalSeriesPerLabelValue(userID, labelValue)
+	})
+}
+
 func (l *Limiter) maxMetadataPerMetric(userID string) int {
 	return l.convertGlobalToLocalLimitOrUnlimited(userID, l.limits.MaxGlobalMetadataPerMetric)
 }
@@ -49,6 +49,13 @@ type ingesterMetrics struct {
 	appenderAddDuration    prometheus.Histogram
 	appenderCommitDuration prometheus.Histogram
 	idleTsdbChecks         *prometheus.CounterVec
+
+	// WAL replay progress metrics.
+	walReplaySegments                 *prometheus.GaugeVec
+	walReplaySegmentsReplayed         *prometheus.GaugeVec
+	walReplaySeriesLoaded             *prometheus.GaugeVec
+	walReplayChunkSnapshotDiscarded   *prometheus.GaugeVec
+	chunkSnapshotVerificationFailures prometheus.Counter
 }
 
 func newIngesterMetrics(
@@ -250,6 +257,27 @@ func newIngesterMetrics(
 		}),
 
 		idleTsdbChecks: idleTsdbChecks,
+
+		walReplaySegments: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ingester_tsdb_wal_replay_segments",
+			Help: "The number of WAL segments to replay when opening the TSDB.",
+		}, []string{"user"}),
+		walReplaySegmentsReplayed: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ingester_tsdb_wal_replay_segments_replayed",
+			Help: "The number of WAL segments replayed so far when opening the TSDB.",
+		}, []string{"user"}),
+		walReplaySeriesLoaded: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ingester_tsdb_wal_replay_series_loaded",
+			Help: "The number of series loaded so far from the chunk snapshot and the WAL when opening the TSDB.",
+		}, []string{"user"}),
+		walReplayChunkSnapshotDiscarded: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ingester_tsdb_wal_replay_chunk_snapshot_discarded",
+			Help: "Whether the chunk snapshot failed to replay when opening the TSDB, and the TSDB has been reopened to replay the WAL from the start (1) or not (0).",
+		}, []string{"user"}),
+		chunkSnapshotVerificationFailures: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ingester_tsdb_chunk_snapshot_verification_failures_total",
+			Help: "Total number of TSDB chunk snapshots discarded because corrupted. The TSDB is reopened to replay the WAL from the start.",
+		}),
 	}
 
 	if activeSeriesEnabled && r != nil {
@@ -264,6 +292,10 @@ func (m *ingesterMetrics) deletePerUserMetrics(userID string) {
 	m.memMetadataCreatedTotal.DeleteLabelValues(userID)
 	m.memMetadataRemovedTotal.DeleteLabelValues(userID)
 	m.activeSeriesPerUser.DeleteLabelValues(userID)
+	m.walReplaySegments.DeleteLabelValues(userID)
+	m.walReplaySegmentsReplayed.DeleteLabelValues(userID)
+	m.walReplaySeriesLoaded.DeleteLabelValues(userID)
+	m.walReplayChunkSnapshotDiscarded.DeleteLabelValues(userID)
 	for _, name := range m.activeSeriesCustomTrackerNames {
 		m.activeSeriesCustomTrackersPerUser.DeleteLabelValues(userID, name)
 	}
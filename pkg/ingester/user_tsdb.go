@@ -55,14 +55,19 @@ func (r tsdbCloseCheckResult) shouldClose() bool {
 }
 
 type userTSDB struct {
-	db             *tsdb.DB
-	userID         string
-	activeSeries   *ActiveSeries
-	seriesInMetric *metricCounter
-	limiter        *Limiter
+	db                 *tsdb.DB
+	userID             string
+	activeSeries       *ActiveSeries
+	seriesInMetric     *metricCounter
+	seriesInLabelValue *labelValueCounter
+	limiter            *Limiter
+
+	// Progress of the WAL replay, only set while the TSDB is being opened.
+	walReplay *walReplayStatus
 
 	instanceSeriesCount *atomic.Int64 // Shared across all userTSDB instances created by ingester.
 	instanceLimitsFn    func() *InstanceLimits
+	prepareDownscale    *prepareDownscale // Shared across all userTSDB instances created by ingester.
 
 	stateMtx       sync.RWMutex
 	state          tsdbState
@@ -185,6 +190,13 @@ func (u *userTSDB) PreCreation(metric labels.Labels) error {
 		}
 	}
 
+	// New series are rejected while the ingester is preparing for downscale.
+	if u.prepareDownscale != nil {
+		if err := u.prepareDownscale.checkCanCreateSeries(); err != nil {
+			return err
+		}
+	}
+
 	// Total series limit.
 	if err := u.limiter.AssertMaxSeriesPerUser(u.userID, int(u.Head().NumSeries())); err != nil {
 		return err
@@ -199,12 +211,21 @@ func (u *userTSDB) PreCreation(metric labels.Labels) error {
 		return err
 	}
 
+	// Series per label value limit.
+	if err := u.seriesInLabelValue.canAddSeries(u.userID, metric); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // PostCreation implements SeriesLifecycleCallback interface.
 func (u *userTSDB) PostCreation(metric labels.Labels) {
 	u.instanceSeriesCount.Inc()
+	u.seriesInLabelValue.increaseSeries(metric)
+	if u.walReplay != nil {
+		u.walReplay.seriesCreated()
+	}
 
 	metricName, err := extract.MetricNameFromLabels(metric)
 	if err != nil {
@@ -219,6 +240,8 @@ func (u *userTSDB) PostDeletion(metrics ...labels.Labels) {
 	u.instanceSeriesCount.Sub(int64(len(metrics)))
 
 	for _, metric := range metrics {
+		u.seriesInLabelValue.decreaseSeries(metric)
+
 		metricName, err := extract.MetricNameFromLabels(metric)
 		if err != nil {
 			// This should never happen because it has already been checked in PreCreation().
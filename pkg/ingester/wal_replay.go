@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ingester
+
+import (
+	_ "embed" // Used to embed html template
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/tsdb"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// walReplayStatus tracks the progress of the WAL replay of a tenant TSDB. The WAL segments replay
+// progress is read from the TSDB head stats, while the series loaded are counted by the userTSDB.
+type walReplayStatus struct {
+	userID    string
+	startedAt time.Time
+
+	// Updated by the TSDB head while replaying the WAL.
+	head *tsdb.WALReplayStatus
+
+	seriesLoaded atomic.Int64
+
+	segmentsGauge          prometheus.Gauge
+	segmentsReplayedGauge  prometheus.Gauge
+	seriesLoadedGauge      prometheus.Gauge
+	snapshotDiscardedGauge prometheus.Gauge
+
+	mtx         sync.Mutex
+	completedAt time.Time
+	err         error
+
+	// Whether the TSDB failed to replay the chunk snapshot, and has been reopened replaying the WAL only.
+	snapshotDiscarded bool
+}
+
+// newWALReplayStatus makes a new walReplayStatus. If the previous replay of the TSDB has been interrupted
+// because the chunk snapshot has been discarded, the new replay is reported as its continuation.
+func newWALReplayStatus(userID string, head *tsdb.WALReplayStatus, m *ingesterMetrics, prev *walReplayStatus) *walReplayStatus {
+	s := &walReplayStatus{
+		userID:                 userID,
+		startedAt:              time.Now(),
+		head:                   head,
+		segmentsGauge:          m.walReplaySegments.WithLabelValues(userID),
+		segmentsReplayedGauge:  m.walReplaySegmentsReplayed.WithLabelValues(userID),
+		seriesLoadedGauge:      m.walReplaySeriesLoaded.WithLabelValues(userID),
+		snapshotDiscardedGauge: m.walReplayChunkSnapshotDiscarded.WithLabelValues(userID),
+	}
+	if prev != nil {
+		if completedAt, discarded, _ := prev.completion(); completedAt.IsZero() && discarded {
+			s.startedAt = prev.startedAt
+			s.snapshotDiscarded = true
+		}
+	}
+
+	// The TSDB may be reopened, so we reset the metrics of the previous replay.
+	s.updateMetrics()
+	s.seriesLoadedGauge.Set(0)
+	if s.snapshotDiscarded {
+		s.snapshotDiscardedGauge.Set(1)
+	} else {
+		s.snapshotDiscardedGauge.Set(0)
+	}
+	return s
+}
+
+// segments returns the number of WAL segments to replay, and the ones replayed so far. The head
+// replays the segments (and the checkpoint, if any) in the range [Min, Max], and Current is the
+// last one replayed. The progress is computed the same way the Prometheus WAL replay status is.
+func (s *walReplayStatus) segments() (total, replayed int64) {
+	st := s.head.GetWALReplayStatus()
+	if st.Max <= st.Min {
+		return 0, 0
+	}
+	return int64(st.Max - st.Min), int64(st.Current - st.Min)
+}
+
+// updateMetrics updates the progress metrics from the TSDB head stats.
+func (s *walReplayStatus) updateMetrics() {
+	total, replayed := s.segments()
+	s.segmentsGauge.Set(float64(total))
+	s.segmentsReplayedGauge.Set(float64(replayed))
+}
+
+func (s *walReplayStatus) seriesCreated() {
+	s.seriesLoaded.Inc()
+	s.seriesLoadedGauge.Inc()
+}
+
+// complete marks the replay as completed.
+func (s *walReplayStatus) complete(err error) {
+	s.updateMetrics()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.completedAt = time.Now()
+	s.err = err
+}
+
+// discardedChunkSnapshot records that the chunk snapshot failed to replay, and the TSDB is going to be
+// reopened replaying the WAL only.
+func (s *walReplayStatus) discardedChunkSnapshot() {
+	s.snapshotDiscardedGauge.Set(1)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.snapshotDiscarded = true
+}
+
+func (s *walReplayStatus) completion() (time.Time, bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.completedAt, s.snapshotDiscarded, s.err
+}
+
+// updateMetricsUntilDone periodically updates the progress metrics until done is closed.
+func (s *walReplayStatus) updateMetricsUntilDone(done <-chan struct{}) {
+	ticker := time.NewTicker(walReplayMetricsUpdatePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.updateMetrics()
+		case <-done:
+			return
+		}
+	}
+}
+
+// chunkSnapshotReplayFailed returns whether the TSDB head failed to replay the chunk snapshot, according
+// to the head metrics registered to reg.
+func chunkSnapshotReplayFailed(reg prometheus.Gatherer) (bool, error) {
+	families, err := reg.Gather()
+	if err != nil {
+		return false, err
+	}
+
+	for _, family := range families {
+		if family.GetName() != "prometheus_tsdb_snapshot_replay_error_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if m.GetCounter().GetValue() > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// discardChunkSnapshot closes the TSDB whose head failed to replay the chunk snapshot and deletes the
+// snapshots, so that the TSDB can be reopened replaying the WAL only. The head falls back to the WAL
+// on its own, but the series loaded from the snapshot before the failure are still accounted in the
+// number of head series and have been notified to the series lifecycle callbacks, so the TSDB can't be
+// used as is. The WAL replay status reports the fallback, and the replay of the reopened TSDB as its
+// continuation.
+func (i *Ingester) discardChunkSnapshot(db *tsdb.DB, dir string, walReplay *walReplayStatus, logger log.Logger) error {
+	i.metrics.chunkSnapshotVerificationFailures.Inc()
+	walReplay.discardedChunkSnapshot()
+	level.Warn(logger).Log("msg", "TSDB head failed to replay the chunk snapshot, deleting chunk snapshots and reopening the TSDB to replay the WAL again")
+
+	// Remove the series created while opening the TSDB from the ingester series count.
+	i.seriesCount.Sub(walReplay.seriesLoaded.Load())
+
+	if err := db.Close(); err != nil {
+		return errors.Wrap(err, "close TSDB")
+	}
+	return errors.Wrap(tsdb.DeleteChunkSnapshots(dir, math.MaxInt, math.MaxInt), "delete chunk snapshots")
+}
+
+func (i *Ingester) startWALReplay(userID string, head *tsdb.WALReplayStatus) *walReplayStatus {
+	i.walReplayMtx.Lock()
+	defer i.walReplayMtx.Unlock()
+
+	status := newWALReplayStatus(userID, head, i.metrics, i.walReplayStatuses[userID])
+	i.walReplayStatuses[userID] = status
+
+	return status
+}
+
+func (i *Ingester) deleteWALReplayStatus(userID string) {
+	i.walReplayMtx.Lock()
+	delete(i.walReplayStatuses, userID)
+	i.walReplayMtx.Unlock()
+}
+
+//go:embed wal_replay_status.gohtml
+var walReplayStatusPageHTML string
+var walReplayStatusTemplate = template.Must(template.New("webpage").Parse(walReplayStatusPageHTML))
+
+type walReplayStatusPageContents struct {
+	Now     time.Time                 `json:"now"`
+	Tenants []walReplayTenantContents `json:"tenants"`
+}
+
+type walReplayTenantContents struct {
+	Tenant            string    `json:"tenant"`
+	Completed         bool      `json:"completed"`
+	SnapshotDiscarded bool      `json:"chunk_snapshot_discarded"`
+	Error             string    `json:"error,omitempty"`
+	StartedAt         time.Time `json:"started_at"`
+	Duration          string    `json:"duration"`
+	Segments          int64     `json:"segments"`
+	SegmentsReplayed  int64     `json:"segments_replayed"`
+	SeriesLoaded      int64     `json:"series_loaded"`
+}
+
+// Progress returns the percentage of replayed WAL segments.
+func (c walReplayTenantContents) Progress() string {
+	if c.Segments == 0 {
+		if c.Completed {
+			return "100%"
+		}
+		return "0%"
+	}
+	return fmt.Sprintf("%.0f%%", float64(c.SegmentsReplayed)*100/float64(c.Segments))
+}
+
+// WALReplayStatusHandler shows the progress of the WAL replay of the tenants TSDBs opened by this ingester.
+// Tenants still replaying the WAL are listed first.
+func (i *Ingester) WALReplayStatusHandler(w http.ResponseWriter, req *http.Request) {
+	i.walReplayMtx.RLock()
+	statuses := make([]*walReplayStatus, 0, len(i.walReplayStatuses))
+	for _, s := range i.walReplayStatuses {
+		statuses = append(statuses, s)
+	}
+	i.walReplayMtx.RUnlock()
+
+	now := time.Now()
+	tenants := make([]walReplayTenantContents, 0, len(statuses))
+	for _, s := range statuses {
+		completedAt, snapshotDiscarded, err := s.completion()
+		segments, segmentsReplayed := s.segments()
+
+		c := walReplayTenantContents{
+			Tenant:            s.userID,
+			Completed:         !completedAt.IsZero(),
+			SnapshotDiscarded: snapshotDiscarded,
+			StartedAt:         s.startedAt,
+			Segments:          segments,
+			SegmentsReplayed:  segmentsReplayed,
+			SeriesLoaded:      s.seriesLoaded.Load(),
+		}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		if c.Completed {
+			c.Duration = completedAt.Sub(s.startedAt).Round(time.Millisecond).String()
+		} else {
+			c.Duration = now.Sub(s.startedAt).Round(time.Millisecond).String()
+		}
+		tenants = append(tenants, c)
+	}
+
+	sort.Slice(tenants, func(i, j int) bool {
+		if tenants[i].Completed != tenants[j].Completed {
+			return !tenants[i].Completed
+		}
+		return tenants[i].Tenant < tenants[j].Tenant
+	})
+
+	util.RenderHTTPResponse(w, walReplayStatusPageContents{
+		Now:     now,
+		Tenants: tenants,
+	}, walReplayStatusTemplate, req)
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ingester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestIngester_WALReplayWithChunkSnapshot(t *testing.T) {
+	const (
+		userID     = "user-1"
+		numSeries  = 100
+		tsdbSubdir = userID
+	)
+
+	tests := map[string]struct {
+		corruptSnapshot          bool
+		expectedSnapshotFailures int
+	}{
+		"should load the TSDB from a valid chunk snapshot": {
+			corruptSnapshot:          false,
+			expectedSnapshotFailures: 0,
+		},
+		"should fall back to the WAL replay if the chunk snapshot is corrupted": {
+			corruptSnapshot:          true,
+			expectedSnapshotFailures: 1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			dataDir := t.TempDir()
+
+			cfg := defaultIngesterTestConfig(t)
+			cfg.IngesterRing.JoinAfter = 0
+			cfg.BlocksStorageConfig.TSDB.MemorySnapshotOnShutdown = true
+
+			// Start the ingester, push some series and stop it, so that the chunk snapshot is taken.
+			i, err := prepareIngesterWithBlocksStorageAndLimits(t, cfg, defaultLimitsTestConfig(), dataDir, nil)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+
+			test.Poll(t, 1*time.Second, 1, func() interface{} {
+				return i.lifecycler.HealthyInstancesCount()
+			})
+
+			ctx := user.InjectOrgID(context.Background(), userID)
+			for n := 0; n < numSeries; n++ {
+				req, _, _, _ := mockWriteRequest(t, labels.Labels{{Name: labels.MetricName, Value: "test"}, {Name: "series", Value: fmt.Sprint(n)}}, 1, int64(n))
+				_, err := i.Push(ctx, req)
+				require.NoError(t, err)
+			}
+			require.NoError(t, services.StopAndAwaitTerminated(context.Background(), i))
+
+			snapshotDir, _, _, err := tsdb.LastChunkSnapshot(filepath.Join(dataDir, tsdbSubdir))
+			require.NoError(t, err)
+
+			if testData.corruptSnapshot {
+				corruptFile(t, filepath.Join(snapshotDir, "00000000"))
+			}
+
+			// Restart the ingester.
+			reg := prometheus.NewPedanticRegistry()
+			i, err = prepareIngesterWithBlocksStorageAndLimits(t, cfg, defaultLimitsTestConfig(), dataDir, reg)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+			defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+			db := i.getTSDB(userID)
+			require.NotNil(t, db)
+			assert.Equal(t, uint64(numSeries), db.Head().NumSeries())
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP cortex_ingester_tsdb_chunk_snapshot_verification_failures_total Total number of TSDB chunk snapshots discarded because corrupted. The TSDB is reopened to replay the WAL from the start.
+				# TYPE cortex_ingester_tsdb_chunk_snapshot_verification_failures_total counter
+				cortex_ingester_tsdb_chunk_snapshot_verification_failures_total %d
+
+				# HELP cortex_ingester_tsdb_wal_replay_series_loaded The number of series loaded so far from the chunk snapshot and the WAL when opening the TSDB.
+				# TYPE cortex_ingester_tsdb_wal_replay_series_loaded gauge
+				cortex_ingester_tsdb_wal_replay_series_loaded{user="user-1"} %d
+
+				# HELP cortex_ingester_tsdb_wal_replay_chunk_snapshot_discarded Whether the chunk snapshot failed to replay when opening the TSDB, and the TSDB has been reopened to replay the WAL from the start (1) or not (0).
+				# TYPE cortex_ingester_tsdb_wal_replay_chunk_snapshot_discarded gauge
+				cortex_ingester_tsdb_wal_replay_chunk_snapshot_discarded{user="user-1"} %d
+			`, testData.expectedSnapshotFailures, numSeries, testData.expectedSnapshotFailures)),
+				"cortex_ingester_tsdb_chunk_snapshot_verification_failures_total",
+				"cortex_ingester_tsdb_wal_replay_series_loaded",
+				"cortex_ingester_tsdb_wal_replay_chunk_snapshot_discarded",
+			))
+
+			// Check the WAL replay status page.
+			req := httptest.NewRequest("GET", "/ingester/tsdb_wal_replay_status", nil)
+			req.Header.Set("Accept", "application/json")
+			rec := httptest.NewRecorder()
+			i.WALReplayStatusHandler(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var page walReplayStatusPageContents
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+			require.Len(t, page.Tenants, 1)
+			assert.Equal(t, userID, page.Tenants[0].Tenant)
+			assert.True(t, page.Tenants[0].Completed)
+			assert.Empty(t, page.Tenants[0].Error)
+			assert.Equal(t, testData.corruptSnapshot, page.Tenants[0].SnapshotDiscarded)
+			assert.Equal(t, page.Tenants[0].Segments, page.Tenants[0].SegmentsReplayed)
+			assert.Equal(t, int64(numSeries), page.Tenants[0].SeriesLoaded)
+			assert.Equal(t, "100%", page.Tenants[0].Progress())
+		})
+	}
+}
+
+func TestWALReplayStatus(t *testing.T) {
+	head := &tsdb.WALReplayStatus{}
+	status := newWALReplayStatus("user-1", head, newIngesterMetrics(nil, false, nil, nil, nil, nil), nil)
+
+	// The replay has not started yet.
+	total, replayed := status.segments()
+	assert.Equal(t, int64(0), total)
+	assert.Equal(t, int64(0), replayed)
+
+	// The head is replaying the checkpoint 3 and the segments up to 7.
+	head.Min, head.Max, head.Current = 3, 7, 5
+	status.seriesCreated()
+	status.updateMetrics()
+
+	total, replayed = status.segments()
+	assert.Equal(t, int64(4), total)
+	assert.Equal(t, int64(2), replayed)
+
+	assert.Equal(t, 4.0, testutil.ToFloat64(status.segmentsGauge))
+	assert.Equal(t, 2.0, testutil.ToFloat64(status.segmentsReplayedGauge))
+	assert.Equal(t, 1.0, testutil.ToFloat64(status.seriesLoadedGauge))
+
+	// The metrics are updated on completion.
+	head.Current = 7
+	status.complete(nil)
+
+	completedAt, _, err := status.completion()
+	assert.False(t, completedAt.IsZero())
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, testutil.ToFloat64(status.segmentsReplayedGauge))
+}
+
+func TestWALReplayStatus_ShouldReportTheReplayAfterDiscardingTheChunkSnapshotAsContinuation(t *testing.T) {
+	metrics := newIngesterMetrics(nil, false, nil, nil, nil, nil)
+
+	first := newWALReplayStatus("user-1", &tsdb.WALReplayStatus{}, metrics, nil)
+	first.seriesCreated()
+	first.discardedChunkSnapshot()
+	assert.Equal(t, 1.0, testutil.ToFloat64(first.snapshotDiscardedGauge))
+
+	// The TSDB is reopened, and the new replay is reported as the continuation of the previous one.
+	second := newWALReplayStatus("user-1", &tsdb.WALReplayStatus{}, metrics, first)
+	assert.Equal(t, first.startedAt, second.startedAt)
+	assert.Equal(t, 0.0, testutil.ToFloat64(second.seriesLoadedGauge))
+	assert.Equal(t, 1.0, testutil.ToFloat64(second.snapshotDiscardedGauge))
+
+	second.complete(nil)
+	completedAt, snapshotDiscarded, err := second.completion()
+	assert.False(t, completedAt.IsZero())
+	assert.True(t, snapshotDiscarded)
+	assert.NoError(t, err)
+
+	// A later replay of the tenant TSDB is not related to the completed one.
+	third := newWALReplayStatus("user-1", &tsdb.WALReplayStatus{}, metrics, second)
+	assert.Equal(t, 0.0, testutil.ToFloat64(third.snapshotDiscardedGauge))
+	_, snapshotDiscarded, _ = third.completion()
+	assert.False(t, snapshotDiscarded)
+}
+
+// corruptFile flips a byte in the middle of the file.
+func corruptFile(t *testing.T, path string) {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotEmpty(t, data)
+
+	data[len(data)/2] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+}
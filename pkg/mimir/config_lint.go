@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimir
+
+import (
+	"net/http"
+
+	"github.com/grafana/mimir/pkg/mimirtool/config/lint"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// lintConfig returns the part of the configuration checked by the lint rules.
+func lintConfig(cfg *Config) lint.Config {
+	return lint.Config{
+		Ingester:      &cfg.Ingester,
+		Querier:       &cfg.Querier,
+		BlocksStorage: &cfg.BlocksStorage,
+		Limits:        &cfg.LimitsConfig,
+	}
+}
+
+// configLintHandler returns the findings of lint.Check for the running configuration and the
+// currently loaded runtime overrides.
+func configLintHandler(cfg *Config, tenantLimits func() map[string]*validation.Limits) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var overrides map[string]*validation.Limits
+		if tenantLimits != nil {
+			overrides = tenantLimits()
+		}
+
+		findings := lint.Check(lintConfig(cfg), overrides)
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		util.WriteYAMLResponse(w, findings)
+	}
+}
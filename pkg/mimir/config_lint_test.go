@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimir
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/mimirtool/config/lint"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestConfigLintHandler(t *testing.T) {
+	cfg := newDefaultConfig()
+	cfg.Ingester.IngesterRing.ReplicationFactor = 1
+
+	tenantLimits := func() map[string]*validation.Limits {
+		return map[string]*validation.Limits{"user-1": {IngestionRate: 1000, IngestionBurstSize: 500}}
+	}
+
+	resp := httptest.NewRecorder()
+	configLintHandler(cfg, tenantLimits).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/config/lint", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var findings []lint.Finding
+	require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &findings))
+	require.Len(t, findings, 2)
+	assert.Equal(t, "ingester-replication-factor", findings[0].Rule)
+	assert.Equal(t, "user-1", findings[1].Tenant)
+
+	// No findings are reported as an empty list.
+	resp = httptest.NewRecorder()
+	configLintHandler(newDefaultConfig(), nil).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/config/lint", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "[]\n", resp.Body.String())
+}
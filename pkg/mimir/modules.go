@@ -40,6 +40,7 @@ import (
 	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
 	"github.com/grafana/mimir/pkg/frontend/transport"
 	"github.com/grafana/mimir/pkg/ingester"
+	"github.com/grafana/mimir/pkg/overridesstore"
 	"github.com/grafana/mimir/pkg/purger"
 	"github.com/grafana/mimir/pkg/querier"
 	"github.com/grafana/mimir/pkg/querier/engine"
@@ -47,6 +48,7 @@ import (
 	querier_worker "github.com/grafana/mimir/pkg/querier/worker"
 	"github.com/grafana/mimir/pkg/ruler"
 	"github.com/grafana/mimir/pkg/scheduler"
+	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storegateway"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/activitytracker"
@@ -63,6 +65,7 @@ const (
 	Ring                     string = "ring"
 	RuntimeConfig            string = "runtime-config"
 	Overrides                string = "overrides"
+	OverridesStorage         string = "overrides-storage"
 	OverridesExporter        string = "overrides-exporter"
 	Server                   string = "server"
 	Distributor              string = "distributor"
@@ -114,6 +117,13 @@ func (t *Mimir) initAPI() (services.Service, error) {
 
 	t.API = a
 	t.API.RegisterAPI(t.Cfg.Server.PathPrefix, t.Cfg, newDefaultConfig(), t.BuildInfoHandler)
+	t.API.RegisterConfigLint(configLintHandler(&t.Cfg, func() map[string]*validation.Limits {
+		// The tenant limits are set once the runtime config module is initialised, if enabled.
+		if t.TenantLimits == nil {
+			return nil
+		}
+		return t.TenantLimits.AllByUserID()
+	}))
 
 	return nil, nil
 }
@@ -231,6 +241,28 @@ func (t *Mimir) initRuntimeConfig() (services.Service, error) {
 	return serv, err
 }
 
+func (t *Mimir) initOverridesStorage() (services.Service, error) {
+	if !t.Cfg.OverridesStorage.Enabled {
+		return nil, nil
+	}
+
+	// make sure to set default limits before we start loading the stored overrides into memory
+	validation.SetDefaultLimitsForYAMLUnmarshalling(t.Cfg.LimitsConfig)
+
+	bucketClient, err := bucket.NewClient(context.Background(), t.Cfg.OverridesStorage.Config, "overrides-storage", util_log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
+	store := overridesstore.NewBucketStore(bucketClient, t.Cfg.OverridesStorage.AuditLogMaxEntries)
+	loader := overridesstore.NewLoader(store, t.Cfg.OverridesStorage.PollInterval, util_log.Logger, prometheus.DefaultRegisterer)
+
+	// The stored overrides are combined with the ones of the runtime config file, if any.
+	t.TenantLimits = newStoredTenantLimits(t.RuntimeConfig, loader, util_log.Logger)
+	t.API.RegisterOverridesStorage(overridesstore.NewAPI(store, loader, t.Cfg.OverridesStorage.WriterURL.URL, util_log.Logger))
+	return loader, nil
+}
+
 func (t *Mimir) initOverrides() (serv services.Service, err error) {
 	t.Overrides, err = validation.NewOverrides(t.Cfg.LimitsConfig, t.TenantLimits)
 	// overrides don't have operational state, nor do they need to do anything more in starting/stopping phase,
@@ -571,7 +603,7 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 	)
 
 	dnsResolver := dns.NewProvider(util_log.Logger, dnsProviderReg, dns.GolangResolverType)
-	manager, err := ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, prometheus.DefaultRegisterer, util_log.Logger, dnsResolver)
+	manager, err := ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, t.Overrides, managerFactory, prometheus.DefaultRegisterer, util_log.Logger, dnsResolver)
 	if err != nil {
 		return nil, err
 	}
@@ -620,7 +652,29 @@ func (t *Mimir) initCompactor() (serv services.Service, err error) {
 	t.Cfg.Compactor.ShardingRing.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
 	t.Cfg.Compactor.ShardingRing.ListenPort = t.Cfg.Server.GRPCListenPort
 
-	t.Compactor, err = compactor.NewMultitenantCompactor(t.Cfg.Compactor, t.Cfg.BlocksStorage, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
+	compactorCfg := t.Cfg.Compactor
+
+	// Delete the rules and Alertmanager configuration history of the tenants marked for deletion.
+	// The stores don't register their metrics, which are already registered by the ruler and
+	// Alertmanager when running in the same process.
+	if t.Cfg.RulerStorage.MaxVersions > 0 {
+		ruleStore, err := ruler.NewRuleStore(context.Background(), t.Cfg.RulerStorage, t.Overrides, rules.FileLoader{}, util_log.Logger, nil)
+		if err != nil {
+			return nil, err
+		}
+		compactorCfg.TenantDataCleaners = append(compactorCfg.TenantDataCleaners, func(ctx context.Context, userID string) error {
+			return ruleStore.DeleteHistory(ctx, userID, "")
+		})
+	}
+	if t.Cfg.AlertmanagerStorage.MaxVersions > 0 {
+		alertStore, err := alertstore.NewAlertStore(context.Background(), t.Cfg.AlertmanagerStorage, t.Overrides, util_log.Logger, nil)
+		if err != nil {
+			return nil, err
+		}
+		compactorCfg.TenantDataCleaners = append(compactorCfg.TenantDataCleaners, alertStore.DeleteAlertConfigHistory)
+	}
+
+	t.Compactor, err = compactor.NewMultitenantCompactor(compactorCfg, t.Cfg.BlocksStorage, t.Overrides, util_log.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return
 	}
@@ -650,6 +704,7 @@ func (t *Mimir) initMemberlistKV() (services.Service, error) {
 	t.Cfg.MemberlistKV.MetricsRegisterer = reg
 	t.Cfg.MemberlistKV.Codecs = []codec.Codec{
 		ring.GetCodec(),
+		distributor.GetReplicaDescCodec(),
 	}
 	dnsProviderReg := prometheus.WrapRegistererWithPrefix(
 		"cortex_",
@@ -664,6 +719,7 @@ func (t *Mimir) initMemberlistKV() (services.Service, error) {
 
 	// Update the config.
 	t.Cfg.Distributor.DistributorRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
+	t.Cfg.Distributor.HATrackerConfig.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	t.Cfg.Ingester.IngesterRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	t.Cfg.StoreGateway.ShardingRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	t.Cfg.Compactor.ShardingRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
@@ -706,6 +762,7 @@ func (t *Mimir) setupModuleManager() error {
 	mm.RegisterModule(RuntimeConfig, t.initRuntimeConfig, modules.UserInvisibleModule)
 	mm.RegisterModule(MemberlistKV, t.initMemberlistKV, modules.UserInvisibleModule)
 	mm.RegisterModule(Ring, t.initRing, modules.UserInvisibleModule)
+	mm.RegisterModule(OverridesStorage, t.initOverridesStorage, modules.UserInvisibleModule)
 	mm.RegisterModule(Overrides, t.initOverrides, modules.UserInvisibleModule)
 	mm.RegisterModule(OverridesExporter, t.initOverridesExporter)
 	mm.RegisterModule(Distributor, t.initDistributor)
@@ -736,7 +793,8 @@ func (t *Mimir) setupModuleManager() error {
 		MemberlistKV:             {API},
 		RuntimeConfig:            {API},
 		Ring:                     {API, RuntimeConfig, MemberlistKV},
-		Overrides:                {RuntimeConfig},
+		OverridesStorage:         {API, RuntimeConfig},
+		Overrides:                {RuntimeConfig, OverridesStorage},
 		OverridesExporter:        {Overrides},
 		Distributor:              {DistributorService, API},
 		DistributorService:       {Ring, Overrides},
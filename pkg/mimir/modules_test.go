@@ -200,6 +200,8 @@ func TestMultiKVSetup(t *testing.T) {
 
 			// Must be set, otherwise MultiKV config provider will not be set.
 			cfg.RuntimeConfig.LoadPath = filepath.Join(dir, "config.yaml")
+			// Disable the activity tracker, otherwise it writes its file to the working directory.
+			cfg.ActivityTracker.Filepath = ""
 
 			c, err := New(cfg)
 			require.NoError(t, err)
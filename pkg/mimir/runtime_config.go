@@ -6,15 +6,20 @@
 package mimir
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"net/http"
+	"sync"
 
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/runtimeconfig"
 	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/mimir/pkg/ingester"
+	"github.com/grafana/mimir/pkg/overridesstore"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
@@ -34,6 +39,10 @@ type runtimeConfigValues struct {
 	IngesterChunkStreaming *bool `yaml:"ingester_stream_chunks_when_using_blocks"`
 
 	IngesterLimits *ingester.InstanceLimits `yaml:"ingester_limits"`
+
+	// rawTenantLimits are the per-tenant overrides as defined in the file, used to know which limits
+	// the file sets when combining them with the overrides stored in the object storage.
+	rawTenantLimits map[string]yaml.MapSlice
 }
 
 // runtimeConfigTenantLimits provides per-tenant limit overrides based on a runtimeconfig.Manager
@@ -63,10 +72,79 @@ func (l *runtimeConfigTenantLimits) AllByUserID() map[string]*validation.Limits
 	return nil
 }
 
+// storedTenantLimits provides per-tenant limit overrides combining the ones of the runtime configuration file,
+// if any, with the ones stored in the object storage. The file takes precedence for the limits it defines.
+type storedTenantLimits struct {
+	manager *runtimeconfig.Manager
+	loader  *overridesstore.Loader
+	logger  log.Logger
+
+	mtx              sync.Mutex
+	cachedConfig     *runtimeConfigValues
+	cachedGeneration uint64
+	cached           map[string]*validation.Limits
+}
+
+// newStoredTenantLimits creates a new validation.TenantLimits that loads per-tenant limit overrides from
+// both the runtimeconfig.Manager, which may be nil, and the overridesstore.Loader.
+func newStoredTenantLimits(manager *runtimeconfig.Manager, loader *overridesstore.Loader, logger log.Logger) validation.TenantLimits {
+	return &storedTenantLimits{
+		manager: manager,
+		loader:  loader,
+		logger:  logger,
+	}
+}
+
+func (l *storedTenantLimits) ByUserID(userID string) *validation.Limits {
+	return l.AllByUserID()[userID]
+}
+
+func (l *storedTenantLimits) AllByUserID() map[string]*validation.Limits {
+	var cfg *runtimeConfigValues
+	if l.manager != nil {
+		cfg, _ = l.manager.GetConfig().(*runtimeConfigValues)
+	}
+	stored, generation := l.loader.Overrides()
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	// The combined limits are only computed again when either the runtime config or the stored overrides are reloaded.
+	if l.cached != nil && l.cachedConfig == cfg && l.cachedGeneration == generation {
+		return l.cached
+	}
+
+	combined := map[string]*validation.Limits{}
+	var fileOverrides map[string]yaml.MapSlice
+	if cfg != nil {
+		for userID, limits := range cfg.TenantLimits {
+			combined[userID] = limits
+		}
+		fileOverrides = cfg.rawTenantLimits
+	}
+
+	for userID, overrides := range stored {
+		limits, err := overridesstore.ToLimits(overridesstore.MergeOverrides(overrides, fileOverrides[userID]))
+		if err != nil {
+			level.Warn(l.logger).Log("msg", "ignoring the invalid stored overrides of the tenant", "user", userID, "err", err)
+			continue
+		}
+		combined[userID] = limits
+	}
+
+	l.cached, l.cachedConfig, l.cachedGeneration = combined, cfg, generation
+	return combined
+}
+
 func loadRuntimeConfig(r io.Reader) (interface{}, error) {
 	var overrides = &runtimeConfigValues{}
 
-	decoder := yaml.NewDecoder(r)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
 	decoder.SetStrict(true)
 
 	// Decode the first document. An empty document (EOF) is OK.
@@ -79,6 +157,16 @@ func loadRuntimeConfig(r io.Reader) (interface{}, error) {
 		return nil, errMultipleDocuments
 	}
 
+	if len(overrides.TenantLimits) > 0 {
+		var raw struct {
+			TenantLimits map[string]yaml.MapSlice `yaml:"overrides"`
+		}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		overrides.rawTenantLimits = raw.TenantLimits
+	}
+
 	return overrides, nil
 }
 
@@ -6,12 +6,23 @@
 package mimir
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/runtimeconfig"
+	"github.com/grafana/dskit/services"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v2"
 
+	"github.com/grafana/mimir/pkg/overridesstore"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
@@ -113,3 +124,61 @@ overrides:
 		assert.Nil(t, actual)
 	}
 }
+
+func TestStoredTenantLimits(t *testing.T) {
+	var defaults validation.Limits
+	flagext.DefaultValues(&defaults)
+	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
+
+	ctx := context.Background()
+
+	runtimeConfigFile := filepath.Join(t.TempDir(), "runtime.yaml")
+	require.NoError(t, os.WriteFile(runtimeConfigFile, []byte(`
+overrides:
+  user-1:
+    ingestion_rate: 100
+  user-2:
+    ingestion_rate: 200
+`), 0644))
+
+	manager, err := runtimeconfig.New(runtimeconfig.Config{LoadPath: runtimeConfigFile, ReloadPeriod: time.Hour, Loader: loadRuntimeConfig}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, manager))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, manager))
+	})
+
+	store := overridesstore.NewBucketStore(objstore.NewInMemBucket(), 0)
+	loader := overridesstore.NewLoader(store, time.Hour, log.NewNopLogger(), nil)
+	tenantLimits := newStoredTenantLimits(manager, loader, log.NewNopLogger())
+
+	// Only the runtime config file overrides are loaded.
+	require.NoError(t, loader.Load(ctx))
+	assert.Equal(t, 100.0, tenantLimits.ByUserID("user-1").IngestionRate)
+	assert.Equal(t, 200.0, tenantLimits.ByUserID("user-2").IngestionRate)
+	assert.Nil(t, tenantLimits.ByUserID("user-3"))
+
+	for userID, overrides := range map[string]yaml.MapSlice{
+		"user-1": {{Key: "ingestion_rate", Value: 1000}, {Key: "ingestion_burst_size", Value: 5000}},
+		"user-3": {{Key: "ingestion_rate", Value: 3000}},
+		// Invalid overrides are ignored.
+		"user-4": {{Key: "ingestion_rate", Value: -1}},
+	} {
+		_, err := store.Set(ctx, userID, overrides, overridesstore.AnyVersion, time.Now())
+		require.NoError(t, err)
+	}
+	require.NoError(t, loader.Load(ctx))
+
+	// The runtime config file takes precedence for the limits it defines.
+	assert.Equal(t, 100.0, tenantLimits.ByUserID("user-1").IngestionRate)
+	assert.Equal(t, 5000, tenantLimits.ByUserID("user-1").IngestionBurstSize)
+	assert.Equal(t, 200.0, tenantLimits.ByUserID("user-2").IngestionRate)
+	assert.Equal(t, defaults.IngestionBurstSize, tenantLimits.ByUserID("user-2").IngestionBurstSize)
+	assert.Equal(t, 3000.0, tenantLimits.ByUserID("user-3").IngestionRate)
+	assert.Nil(t, tenantLimits.ByUserID("user-4"))
+
+	// The stored overrides are used without the runtime config file too.
+	tenantLimits = newStoredTenantLimits(nil, loader, log.NewNopLogger())
+	assert.Equal(t, 1000.0, tenantLimits.ByUserID("user-1").IngestionRate)
+	assert.Nil(t, tenantLimits.ByUserID("user-2"))
+}
@@ -12,12 +12,18 @@ type MetricsInPrometheus struct {
 
 	InUseMetricCounts      []MetricCount `json:"in_use_metric_counts"`
 	AdditionalMetricCounts []MetricCount `json:"additional_metric_counts"`
+
+	Recommendations *Recommendations `json:"recommendations,omitempty"`
 }
 
 type MetricCount struct {
 	Metric    string     `json:"metric"`
 	Count     int        `json:"count"`
 	JobCounts []JobCount `json:"job_counts"`
+
+	// CardinalitySeriesCount is the number of series in the ingesters, as reported by the
+	// Grafana Mimir cardinality API. It's 0 if the API has not been used.
+	CardinalitySeriesCount int `json:"cardinality_series_count,omitempty"`
 }
 
 type JobCount struct {
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package analyze
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// Recommendations summarises the artifacts suggested by the analysis, with an estimate of
+// the series they save.
+type Recommendations struct {
+	DroppedMetrics           int `json:"dropped_metrics"`
+	DroppedMetricsSeries     int `json:"dropped_metrics_series"`
+	MaxGlobalSeriesPerMetric int `json:"max_global_series_per_metric"`
+	SeriesLimitedPerMetric   int `json:"series_limited_per_metric"`
+}
+
+// DropRelabelConfig is a relabel config dropping the series whose metric name matches the regex.
+// It can be used both as a Prometheus write_relabel_configs and a Grafana Mimir metric_relabel_configs entry.
+type DropRelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,flow"`
+	Regex        string   `yaml:"regex"`
+	Action       string   `yaml:"action"`
+}
+
+// Series returns the number of series of the metric, preferring the series count from the
+// cardinality API, if any, to the count of active series.
+func (m MetricCount) Series() int {
+	if m.CardinalitySeriesCount > 0 {
+		return m.CardinalitySeriesCount
+	}
+	return m.Count
+}
+
+// UnusedMetricsToDrop returns the metrics not used in Grafana dashboards or rules, which have
+// at least one series, sorted by number of series.
+func UnusedMetricsToDrop(output MetricsInPrometheus) []MetricCount {
+	var metrics []MetricCount
+	for _, m := range output.AdditionalMetricCounts {
+		if m.Series() > 0 {
+			metrics = append(metrics, m)
+		}
+	}
+
+	sort.SliceStable(metrics, func(i, j int) bool {
+		if metrics[i].Series() != metrics[j].Series() {
+			return metrics[i].Series() > metrics[j].Series()
+		}
+		return metrics[i].Metric < metrics[j].Metric
+	})
+	return metrics
+}
+
+// DropRelabelConfigs returns the relabel configs dropping the input metrics, each one matching
+// at most metricsPerConfig metric names to keep the regular expressions readable.
+func DropRelabelConfigs(metrics []MetricCount, metricsPerConfig int) []DropRelabelConfig {
+	if metricsPerConfig <= 0 {
+		metricsPerConfig = len(metrics)
+	}
+
+	var configs []DropRelabelConfig
+	for start := 0; start < len(metrics); start += metricsPerConfig {
+		end := start + metricsPerConfig
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+
+		names := make([]string, 0, end-start)
+		for _, m := range metrics[start:end] {
+			names = append(names, regexp.QuoteMeta(m.Metric))
+		}
+
+		configs = append(configs, DropRelabelConfig{
+			SourceLabels: []string{labels.MetricName},
+			Regex:        strings.Join(names, "|"),
+			Action:       "drop",
+		})
+	}
+	return configs
+}
+
+// SuggestMaxGlobalSeriesPerMetric returns the max_global_series_per_metric limit computed as the series of
+// the largest used metric multiplied by factor, rounded up to 1, 2 or 5 times a power of 10 (e.g. 20000).
+// Returns 0 if no used metric has series.
+func SuggestMaxGlobalSeriesPerMetric(output MetricsInPrometheus, factor float64) int {
+	largest := 0
+	for _, m := range output.InUseMetricCounts {
+		if m.Series() > largest {
+			largest = m.Series()
+		}
+	}
+	if largest == 0 {
+		return 0
+	}
+
+	target := float64(largest) * math.Max(factor, 1)
+	magnitude := math.Pow(10, math.Floor(math.Log10(target)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if limit := step * magnitude; limit >= target {
+			return int(limit)
+		}
+	}
+	return int(10 * magnitude)
+}
+
+// SeriesLimitedPerMetric returns the number of series of the input metrics exceeding the per-metric limit.
+func SeriesLimitedPerMetric(metrics []MetricCount, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+
+	limited := 0
+	for _, m := range metrics {
+		if m.Series() > limit {
+			limited += m.Series() - limit
+		}
+	}
+	return limited
+}
+
+// BuildRecommendations computes the recommendations for the analysis output. The used metrics are never
+// limited by the suggested max_global_series_per_metric, so the series it saves are the ones of the unused
+// metrics exceeding it, in case they are not dropped.
+func BuildRecommendations(output MetricsInPrometheus, factor float64) Recommendations {
+	rec := Recommendations{MaxGlobalSeriesPerMetric: SuggestMaxGlobalSeriesPerMetric(output, factor)}
+
+	for _, m := range UnusedMetricsToDrop(output) {
+		rec.DroppedMetrics++
+		rec.DroppedMetricsSeries += m.Series()
+	}
+	rec.SeriesLimitedPerMetric = SeriesLimitedPerMetric(output.AdditionalMetricCounts, rec.MaxGlobalSeriesPerMetric)
+
+	return rec
+}
@@ -24,6 +24,9 @@ import (
 const (
 	rulerAPIPath  = "/api/v1/rules"
 	legacyAPIPath = "/api/prom/rules"
+
+	// authorHeader is the header used by Grafana Mimir to record the author of a change.
+	authorHeader = "X-Mimir-Author"
 )
 
 var (
@@ -38,7 +41,8 @@ type Config struct {
 	Address         string `yaml:"address"`
 	ID              string `yaml:"id"`
 	TLS             tls.ClientConfig
-	UseLegacyRoutes bool `yaml:"use_legacy_routes"`
+	UseLegacyRoutes bool   `yaml:"use_legacy_routes"`
+	Author          string `yaml:"author"`
 }
 
 // CortexClient is used to get and load rules into a cortex ruler
@@ -46,6 +50,7 @@ type CortexClient struct {
 	user     string
 	key      string
 	id       string
+	author   string
 	endpoint *url.URL
 	Client   http.Client
 	apiPath  string
@@ -93,6 +98,7 @@ func New(cfg Config) (*CortexClient, error) {
 		user:     cfg.User,
 		key:      cfg.Key,
 		id:       cfg.ID,
+		author:   cfg.Author,
 		endpoint: endpoint,
 		Client:   client,
 		apiPath:  path,
@@ -126,6 +132,9 @@ func (r *CortexClient) doRequest(path, method string, payload []byte) (*http.Res
 	}
 
 	req.Header.Add("X-Scope-OrgID", r.id)
+	if r.author != "" {
+		req.Header.Add(authorHeader, r.author)
+	}
 
 	log.WithFields(log.Fields{
 		"url":    req.URL.String(),
@@ -205,5 +214,6 @@ func buildRequest(p, m string, endpoint url.URL, payload []byte) (*http.Request,
 		endpoint.RawPath = joinPath(endpoint.EscapedPath(), pURL.EscapedPath())
 	}
 	endpoint.Path = joinPath(endpoint.Path, pURL.Path)
+	endpoint.RawQuery = pURL.RawQuery
 	return http.NewRequest(m, endpoint.String(), bytes.NewBuffer(payload))
 }
@@ -84,6 +84,13 @@ func TestBuildURL(t *testing.T) {
 			url:       "http://mimirurl.com/apathto",
 			resultURL: "http://mimirurl.com/apathto/api/v1/rules/last-char-slash%2F",
 		},
+		{
+			name:      "builds the correct URL when the target path has a query string",
+			path:      "/api/v1/alerts/diff?from=1&to=2",
+			method:    http.MethodGet,
+			url:       "http://mimirurl.com/apathto",
+			resultURL: "http://mimirurl.com/apathto/api/v1/alerts/diff?from=1&to=2",
+		},
 	}
 
 	for _, tt := range tc {
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// rulerConfigAPIPath is the path of the ruler configuration API exposing the history of the rule groups.
+const rulerConfigAPIPath = "/prometheus/config/v1/rules"
+
+// Version describes a version in the history of a rule group or of an Alertmanager configuration.
+type Version struct {
+	ID        int       `yaml:"id"`
+	Timestamp time.Time `yaml:"timestamp"`
+	Author    string    `yaml:"author,omitempty"`
+	Deleted   bool      `yaml:"deleted,omitempty"`
+}
+
+// ListRuleGroupVersions retrieves the versions in the history of a rule group
+func (r *CortexClient) ListRuleGroupVersions(ctx context.Context, namespace, groupName string) ([]Version, error) {
+	return r.listVersions(ruleGroupConfigPath(namespace, groupName) + "/versions")
+}
+
+// DiffRuleGroupVersions retrieves the diff between two versions of a rule group
+func (r *CortexClient) DiffRuleGroupVersions(ctx context.Context, namespace, groupName string, from, to int) (string, error) {
+	return r.diffVersions(ruleGroupConfigPath(namespace, groupName)+"/diff", from, to)
+}
+
+// RollbackRuleGroup restores a previous version of a rule group
+func (r *CortexClient) RollbackRuleGroup(ctx context.Context, namespace, groupName string, version int) error {
+	path := fmt.Sprintf("%s/versions/%d/rollback", ruleGroupConfigPath(namespace, groupName), version)
+
+	res, err := r.doRequest(path, "POST", nil)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	return nil
+}
+
+// ListAlertmanagerConfigVersions retrieves the versions in the history of the users alertmanager config
+func (r *CortexClient) ListAlertmanagerConfigVersions(ctx context.Context) ([]Version, error) {
+	return r.listVersions(alertmanagerAPIPath + "/versions")
+}
+
+// DiffAlertmanagerConfigVersions retrieves the diff between two versions of the users alertmanager config
+func (r *CortexClient) DiffAlertmanagerConfigVersions(ctx context.Context, from, to int) (string, error) {
+	return r.diffVersions(alertmanagerAPIPath+"/diff", from, to)
+}
+
+// RollbackAlertmanagerConfig restores a previous version of the users alertmanager config
+func (r *CortexClient) RollbackAlertmanagerConfig(ctx context.Context, version int) error {
+	path := fmt.Sprintf("%s/versions/%d/rollback", alertmanagerAPIPath, version)
+
+	res, err := r.doRequest(path, "POST", nil)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	return nil
+}
+
+func (r *CortexClient) listVersions(path string) ([]Version, error) {
+	res, err := r.doRequest(path, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	err = yaml.Unmarshal(body, &versions)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal versions from response")
+
+		return nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	return versions, nil
+}
+
+func (r *CortexClient) diffVersions(path string, from, to int) (string, error) {
+	res, err := r.doRequest(fmt.Sprintf("%s?from=%d&to=%d", path, from, to), "GET", nil)
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+func ruleGroupConfigPath(namespace, groupName string) string {
+	return rulerConfigAPIPath + "/" + url.PathEscape(namespace) + "/" + url.PathEscape(groupName)
+}
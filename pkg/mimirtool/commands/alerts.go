@@ -46,6 +46,10 @@ type AlertmanagerCommand struct {
 	TemplateFiles          []string
 	DisableColor           bool
 
+	Version     int
+	FromVersion int
+	ToVersion   int
+
 	cli *client.CortexClient
 }
 
@@ -72,6 +76,7 @@ func (a *AlertmanagerCommand) Register(app *kingpin.Application, envVars EnvVarN
 	alertCmd.Flag("tls-ca-path", "TLS CA certificate to verify Grafana Mimir API as part of mTLS; alternatively, set "+envVars.TLSCAPath+".").Default("").Envar(envVars.TLSCAPath).StringVar(&a.ClientConfig.TLS.CAPath)
 	alertCmd.Flag("tls-cert-path", "TLS client certificate to authenticate with the Grafana Mimir API as part of mTLS; alternatively, set "+envVars.TLSCertPath+".").Default("").Envar(envVars.TLSCertPath).StringVar(&a.ClientConfig.TLS.CertPath)
 	alertCmd.Flag("tls-key-path", "TLS client certificate private key to authenticate with the Grafana Mimir API as part of mTLS; alternatively, set "+envVars.TLSKeyPath+".").Default("").Envar(envVars.TLSKeyPath).StringVar(&a.ClientConfig.TLS.KeyPath)
+	alertCmd.Flag("author", "Author of the changes, recorded in the history of the Alertmanager configuration; alternatively, set "+envVars.Author+".").Default("").Envar(envVars.Author).StringVar(&a.ClientConfig.Author)
 
 	// Get Alertmanager Configs Command
 	getAlertsCmd := alertCmd.Command("get", "Get the Alertmanager configuration that is currently in the Grafana Mimir Alertmanager.").Action(a.getConfig)
@@ -79,6 +84,16 @@ func (a *AlertmanagerCommand) Register(app *kingpin.Application, envVars EnvVarN
 
 	alertCmd.Command("delete", "Delete the Alertmanager configuration that is currently in the Grafana Mimir Alertmanager.").Action(a.deleteConfig)
 
+	alertCmd.Command("versions", "List the versions in the history of the Alertmanager configuration.").Action(a.listConfigVersions)
+
+	diffVersionsCmd := alertCmd.Command("diff-versions", "Diff two versions in the history of the Alertmanager configuration.").Action(a.diffConfigVersions)
+	diffVersionsCmd.Arg("from", "Version to diff from.").Required().IntVar(&a.FromVersion)
+	diffVersionsCmd.Arg("to", "Version to diff to.").Required().IntVar(&a.ToVersion)
+	diffVersionsCmd.Flag("disable-color", "disable colored output").BoolVar(&a.DisableColor)
+
+	rollbackCmd := alertCmd.Command("rollback", "Restore a previous version of the Alertmanager configuration.").Action(a.rollbackConfig)
+	rollbackCmd.Arg("version", "Version to restore.").Required().IntVar(&a.Version)
+
 	loadalertCmd := alertCmd.Command("load", "Load a set of rules to a designated Grafana Mimir endpoint").Action(a.loadConfig)
 	loadalertCmd.Arg("config", "alertmanager configuration to load").Required().StringVar(&a.AlertmanagerConfigFile)
 	loadalertCmd.Arg("template-files", "The template files to load").ExistingFilesVar(&a.TemplateFiles)
@@ -141,6 +156,43 @@ func (a *AlertmanagerCommand) deleteConfig(k *kingpin.ParseContext) error {
 	return nil
 }
 
+func (a *AlertmanagerCommand) listConfigVersions(k *kingpin.ParseContext) error {
+	versions, err := a.cli.ListAlertmanagerConfigVersions(context.Background())
+	if err != nil {
+		return err
+	}
+
+	p := printer.New(a.DisableColor)
+	p.PrintVersions(versions, os.Stdout)
+	return nil
+}
+
+func (a *AlertmanagerCommand) diffConfigVersions(k *kingpin.ParseContext) error {
+	diff, err := a.cli.DiffAlertmanagerConfigVersions(context.Background(), a.FromVersion, a.ToVersion)
+	if err != nil {
+		if err == client.ErrResourceNotFound {
+			log.Infof("this alertmanager config version does not exist")
+			return nil
+		}
+		return err
+	}
+
+	p := printer.New(a.DisableColor)
+	return p.PrintVersionsDiff(diff, os.Stdout)
+}
+
+func (a *AlertmanagerCommand) rollbackConfig(k *kingpin.ParseContext) error {
+	err := a.cli.RollbackAlertmanagerConfig(context.Background(), a.Version)
+	if err != nil {
+		if err == client.ErrResourceNotFound {
+			log.Infof("this alertmanager config version does not exist")
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
 func (a *AlertCommand) Register(app *kingpin.Application, envVars EnvVarNames) {
 	alertCmd := app.Command("alerts", "View active alerts in alertmanager.").PreAction(a.setup)
 	alertCmd.Flag("address", "Address of the Grafana Mimir cluster, alternatively set "+envVars.Address+".").Envar(envVars.Address).Required().StringVar(&a.ClientConfig.Address)
@@ -41,6 +41,24 @@ func (cmd *AnalyzeCommand) Register(app *kingpin.Application, envVars EnvVarName
 	prometheusAnalyzeCmd.Flag("output", "The path for the output file").
 		Default("prometheus-metrics.json").
 		StringVar(&paCmd.outputFile)
+	prometheusAnalyzeCmd.Flag("cardinality-api", "Use the Grafana Mimir cardinality API to get the number of series in the ingesters for each metric, used to estimate the series saved by the recommendations.").
+		Default("false").
+		BoolVar(&paCmd.useCardinalityAPI)
+	prometheusAnalyzeCmd.Flag("write-relabel-configs-output", "The path for the output file containing the Prometheus write_relabel_configs to drop the unused metrics. If empty, the file is not written.").
+		Default("").
+		StringVar(&paCmd.writeRelabelConfigsFile)
+	prometheusAnalyzeCmd.Flag("metric-relabel-configs-output", "The path for the output file containing the Grafana Mimir metric_relabel_configs overrides to drop the unused metrics. If empty, the file is not written.").
+		Default("").
+		StringVar(&paCmd.metricRelabelConfigsFile)
+	prometheusAnalyzeCmd.Flag("series-limits-output", "The path for the output file containing the suggested Grafana Mimir max_global_series_per_metric overrides. If empty, the file is not written.").
+		Default("").
+		StringVar(&paCmd.seriesLimitsFile)
+	prometheusAnalyzeCmd.Flag("metrics-per-relabel-config", "The max number of metric names matched by each generated relabel config.").
+		Default("50").
+		IntVar(&paCmd.metricsPerRelabelConfig)
+	prometheusAnalyzeCmd.Flag("series-per-metric-limit-factor", "The suggested max_global_series_per_metric is the series of the largest used metric multiplied by this factor.").
+		Default("2").
+		Float64Var(&paCmd.seriesPerMetricLimitFactor)
 
 	gaCmd := &GrafanaAnalyzeCommand{}
 	grafanaAnalyzeCmd := analyzeCmd.Command("grafana", "Analyze and output the metrics used in Grafana Dashboards.").Action(gaCmd.run)
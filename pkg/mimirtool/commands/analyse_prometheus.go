@@ -6,11 +6,17 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -21,10 +27,14 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/mimir/pkg/mimirtool/analyze"
 )
 
+// cardinalityAPIMaxLimit is the max number of label values returned by the Grafana Mimir cardinality API.
+const cardinalityAPIMaxLimit = 500
+
 type PrometheusAnalyzeCommand struct {
 	address     string
 	username    string
@@ -34,6 +44,13 @@ type PrometheusAnalyzeCommand struct {
 	grafanaMetricsFile string
 	rulerMetricsFile   string
 	outputFile         string
+
+	useCardinalityAPI          bool
+	writeRelabelConfigsFile    string
+	metricRelabelConfigsFile   string
+	seriesLimitsFile           string
+	metricsPerRelabelConfig    int
+	seriesPerMetricLimitFactor float64
 }
 
 func (cmd *PrometheusAnalyzeCommand) run(k *kingpin.ParseContext) error {
@@ -228,6 +245,27 @@ func (cmd *PrometheusAnalyzeCommand) run(k *kingpin.ParseContext) error {
 		return output.AdditionalMetricCounts[i].Count > output.AdditionalMetricCounts[j].Count
 	})
 
+	if cmd.useCardinalityAPI {
+		ctx, cancel := context.WithTimeout(context.Background(), cmd.readTimeout)
+		defer cancel()
+
+		seriesCounts, err := fetchMetricNamesSeriesCounts(ctx, promClient)
+		if err != nil {
+			return errors.Wrap(err, "error querying the cardinality API")
+		}
+		log.Infof("Found the series count of %d metric names in the cardinality API\n", len(seriesCounts))
+
+		for _, counts := range [][]analyze.MetricCount{output.InUseMetricCounts, output.AdditionalMetricCounts} {
+			for i := range counts {
+				counts[i].CardinalitySeriesCount = seriesCounts[counts[i].Metric]
+			}
+		}
+	}
+
+	recommendations := analyze.BuildRecommendations(output, cmd.seriesPerMetricLimitFactor)
+	output.Recommendations = &recommendations
+	log.Infof("%d unused metrics with %d series can be dropped", recommendations.DroppedMetrics, recommendations.DroppedMetricsSeries)
+
 	out, err := json.MarshalIndent(output, "", "  ")
 	if err != nil {
 		return err
@@ -237,5 +275,113 @@ func (cmd *PrometheusAnalyzeCommand) run(k *kingpin.ParseContext) error {
 		return err
 	}
 
+	return cmd.writeArtifacts(output)
+}
+
+// fetchMetricNamesSeriesCounts returns the number of series of each metric name from the Grafana Mimir
+// label values cardinality API. The API only returns the metric names with the most series.
+func fetchMetricNamesSeriesCounts(ctx context.Context, client api.Client) (map[string]int, error) {
+	u := client.URL("/api/v1/cardinality/label_values", nil)
+	u.RawQuery = url.Values{"label_names[]": []string{labels.MetricName}, "limit": []string{strconv.Itoa(cardinalityAPIMaxLimit)}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, body, err := client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("unexpected response status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var parsed struct {
+		Labels []struct {
+			LabelName   string `json:"label_name"`
+			Cardinality []struct {
+				LabelValue  string `json:"label_value"`
+				SeriesCount int    `json:"series_count"`
+			} `json:"cardinality"`
+		} `json:"labels"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, errors.Wrap(err, "error decoding the cardinality API response")
+	}
+
+	counts := map[string]int{}
+	for _, l := range parsed.Labels {
+		if l.LabelName != labels.MetricName {
+			continue
+		}
+		for _, c := range l.Cardinality {
+			counts[c.LabelValue] = c.SeriesCount
+		}
+	}
+	return counts, nil
+}
+
+// writeArtifacts writes the configuration snippets suggested by the analysis to the requested files.
+func (cmd *PrometheusAnalyzeCommand) writeArtifacts(output analyze.MetricsInPrometheus) error {
+	tenantID := cmd.username
+	if tenantID == "" {
+		tenantID = "anonymous"
+	}
+
+	rec := output.Recommendations
+	toDrop := analyze.UnusedMetricsToDrop(output)
+	relabelConfigs := analyze.DropRelabelConfigs(toDrop, cmd.metricsPerRelabelConfig)
+	dropComment := fmt.Sprintf("Drops %d metrics not used in Grafana dashboards and rules, saving an estimated %d series.", rec.DroppedMetrics, rec.DroppedMetricsSeries)
+
+	if cmd.writeRelabelConfigsFile != "" {
+		err := writeYAMLArtifact(cmd.writeRelabelConfigsFile, map[string]interface{}{
+			"write_relabel_configs": relabelConfigs,
+		}, dropComment, "Add these rules to the write_relabel_configs of the Prometheus remote_write configuration.")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.metricRelabelConfigsFile != "" {
+		err := writeYAMLArtifact(cmd.metricRelabelConfigsFile, map[string]interface{}{
+			"overrides": map[string]interface{}{
+				tenantID: map[string]interface{}{"metric_relabel_configs": relabelConfigs},
+			},
+		}, dropComment, "Merge these overrides into the Grafana Mimir runtime configuration.")
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.seriesLimitsFile != "" {
+		err := writeYAMLArtifact(cmd.seriesLimitsFile, map[string]interface{}{
+			"overrides": map[string]interface{}{
+				tenantID: map[string]interface{}{"max_global_series_per_metric": rec.MaxGlobalSeriesPerMetric},
+			},
+		}, fmt.Sprintf("Limits the series of each metric to %.1fx the series of the largest metric used in Grafana dashboards and rules.", cmd.seriesPerMetricLimitFactor),
+			fmt.Sprintf("If the unused metrics are not dropped, the limit rejects an estimated %d of their series. It also protects from cardinality explosions.", rec.SeriesLimitedPerMetric),
+			"Merge these overrides into the Grafana Mimir runtime configuration.")
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// writeYAMLArtifact writes v as YAML to the file, preceded by the comments.
+func writeYAMLArtifact(file string, v interface{}, comments ...string) error {
+	out := bytes.Buffer{}
+	for _, c := range comments {
+		out.WriteString("# " + c + "\n")
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	out.Write(data)
+
+	return errors.Wrapf(ioutil.WriteFile(file, out.Bytes(), os.FileMode(int(0666))), "error writing %s", file)
+}
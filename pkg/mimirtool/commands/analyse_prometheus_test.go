@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/api"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/mimirtool/analyze"
+)
+
+func testMetricsInPrometheus() analyze.MetricsInPrometheus {
+	return analyze.MetricsInPrometheus{
+		InUseMetricCounts: []analyze.MetricCount{
+			{Metric: "up", Count: 100},
+			{Metric: "http_requests_total", Count: 4000, CardinalitySeriesCount: 6000},
+		},
+		AdditionalMetricCounts: []analyze.MetricCount{
+			{Metric: "unused_small", Count: 10},
+			{Metric: "unused_gone", Count: 0},
+			{Metric: "unused_large", Count: 20000},
+			{Metric: "unused:recorded", Count: 10},
+		},
+	}
+}
+
+func TestBuildRecommendations(t *testing.T) {
+	output := testMetricsInPrometheus()
+
+	toDrop := analyze.UnusedMetricsToDrop(output)
+	names := make([]string, 0, len(toDrop))
+	for _, m := range toDrop {
+		names = append(names, m.Metric)
+	}
+	assert.Equal(t, []string{"unused_large", "unused:recorded", "unused_small"}, names)
+
+	assert.Equal(t, []analyze.DropRelabelConfig{
+		{SourceLabels: []string{labels.MetricName}, Regex: "unused_large|unused:recorded", Action: "drop"},
+		{SourceLabels: []string{labels.MetricName}, Regex: "unused_small", Action: "drop"},
+	}, analyze.DropRelabelConfigs(toDrop, 2))
+
+	// The largest used metric has 6000 series according to the cardinality API.
+	assert.Equal(t, 10000, analyze.SuggestMaxGlobalSeriesPerMetric(output, 1.5))
+	assert.Equal(t, 20000, analyze.SuggestMaxGlobalSeriesPerMetric(output, 2))
+	assert.Equal(t, 0, analyze.SuggestMaxGlobalSeriesPerMetric(analyze.MetricsInPrometheus{}, 2))
+
+	assert.Equal(t, analyze.Recommendations{
+		DroppedMetrics:           3,
+		DroppedMetricsSeries:     20020,
+		MaxGlobalSeriesPerMetric: 10000,
+		SeriesLimitedPerMetric:   10000,
+	}, analyze.BuildRecommendations(output, 1.5))
+}
+
+func TestFetchMetricNamesSeriesCounts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/prometheus/api/v1/cardinality/label_values", r.URL.Path)
+		assert.Equal(t, []string{labels.MetricName}, r.URL.Query()["label_names[]"])
+		assert.Equal(t, "500", r.URL.Query().Get("limit"))
+
+		_, _ = w.Write([]byte(`{"series_count_total": 30, "labels": [{"label_name": "__name__", "label_values_count": 2, "series_count": 30, "cardinality": [
+			{"label_value": "metric_1", "series_count": 20},
+			{"label_value": "metric_2", "series_count": 10}
+		]}]}`))
+	}))
+	defer server.Close()
+
+	client, err := api.NewClient(api.Config{Address: server.URL + "/prometheus"})
+	require.NoError(t, err)
+
+	counts, err := fetchMetricNamesSeriesCounts(context.Background(), client)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"metric_1": 20, "metric_2": 10}, counts)
+}
+
+func TestPrometheusAnalyzeCommand_writeArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	cmd := &PrometheusAnalyzeCommand{
+		username:                   "tenant-1",
+		writeRelabelConfigsFile:    filepath.Join(dir, "write-relabel-configs.yaml"),
+		metricRelabelConfigsFile:   filepath.Join(dir, "metric-relabel-configs.yaml"),
+		seriesLimitsFile:           filepath.Join(dir, "series-limits.yaml"),
+		metricsPerRelabelConfig:    50,
+		seriesPerMetricLimitFactor: 2,
+	}
+
+	output := testMetricsInPrometheus()
+	rec := analyze.BuildRecommendations(output, cmd.seriesPerMetricLimitFactor)
+	output.Recommendations = &rec
+	require.NoError(t, cmd.writeArtifacts(output))
+
+	writeRelabelConfigs, err := os.ReadFile(cmd.writeRelabelConfigsFile)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(writeRelabelConfigs), "# Drops 3 metrics not used in Grafana dashboards and rules, saving an estimated 20020 series.\n"))
+
+	var prometheusCfg struct {
+		WriteRelabelConfigs []*relabel.Config `yaml:"write_relabel_configs"`
+	}
+	require.NoError(t, yaml.UnmarshalStrict(writeRelabelConfigs, &prometheusCfg))
+	require.Len(t, prometheusCfg.WriteRelabelConfigs, 1)
+	assert.Equal(t, relabel.Drop, prometheusCfg.WriteRelabelConfigs[0].Action)
+	assert.True(t, prometheusCfg.WriteRelabelConfigs[0].Regex.MatchString("unused_large"))
+	assert.False(t, prometheusCfg.WriteRelabelConfigs[0].Regex.MatchString("up"))
+
+	metricRelabelConfigs, err := os.ReadFile(cmd.metricRelabelConfigsFile)
+	require.NoError(t, err)
+	var overrides struct {
+		Overrides map[string]struct {
+			MetricRelabelConfigs []*relabel.Config `yaml:"metric_relabel_configs"`
+		} `yaml:"overrides"`
+	}
+	require.NoError(t, yaml.UnmarshalStrict(metricRelabelConfigs, &overrides))
+	assert.Equal(t, prometheusCfg.WriteRelabelConfigs, overrides.Overrides["tenant-1"].MetricRelabelConfigs)
+
+	seriesLimits, err := os.ReadFile(cmd.seriesLimitsFile)
+	require.NoError(t, err)
+	var limits struct {
+		Overrides map[string]map[string]int `yaml:"overrides"`
+	}
+	require.NoError(t, yaml.UnmarshalStrict(seriesLimits, &limits))
+	assert.Equal(t, map[string]map[string]int{"tenant-1": {"max_global_series_per_metric": 20000}}, limits.Overrides)
+}
@@ -0,0 +1,505 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/listblocks"
+)
+
+const (
+	markDeletion  = "deletion"
+	markNoCompact = "no-compact"
+)
+
+// BlocksCommand is the kingpin command to inspect and repair the TSDB blocks stored in a blocks storage bucket.
+type BlocksCommand struct {
+	bucketConfig string
+	tenants      []string
+	tenant       string
+	blockIDs     []string
+
+	minTime     string
+	maxTime     string
+	showDeleted bool
+
+	checkChunks bool
+	topN        int
+
+	mark    string
+	details string
+	dryRun  bool
+
+	bkt    objstore.Bucket
+	out    io.Writer
+	logger log.Logger
+}
+
+// Register is used to register the command to a parent command.
+func (b *BlocksCommand) Register(app *kingpin.Application, _ EnvVarNames) {
+	blocksCmd := app.Command("blocks", "Inspect and repair the TSDB blocks stored in a Grafana Mimir blocks storage bucket. All commands print their results as JSON.")
+	blocksCmd.Flag("bucket-config", "The CLI args to configure the blocks storage bucket, e.g. '-backend=s3 -s3.bucket-name=blocks'.").Required().StringVar(&b.bucketConfig)
+
+	listCmd := blocksCmd.Command("list", "List the blocks of the tenants.").Action(b.list)
+	listCmd.Flag("tenant", "Tenant ID whose blocks are listed. Can be repeated. If empty, the blocks of all tenants are listed.").StringsVar(&b.tenants)
+	listCmd.Flag("min-time", "If set, only blocks with a min time after or equal to this RFC3339 timestamp are listed.").StringVar(&b.minTime)
+	listCmd.Flag("max-time", "If set, only blocks with a max time before or equal to this RFC3339 timestamp are listed.").StringVar(&b.maxTime)
+	listCmd.Flag("show-deleted", "Also list the blocks marked for deletion.").BoolVar(&b.showDeleted)
+
+	verifyCmd := blocksCmd.Command("verify", "Verify the index, and optionally the chunks, of the blocks.").Action(b.verify)
+	verifyCmd.Flag("tenant", "Tenant ID owning the blocks.").Required().StringVar(&b.tenant)
+	verifyCmd.Flag("check-chunks", "Also download the chunks of the blocks and verify the samples in each chunk.").BoolVar(&b.checkChunks)
+	verifyCmd.Arg("block-id", "IDs of the blocks to verify.").Required().StringsVar(&b.blockIDs)
+
+	statsCmd := blocksCmd.Command("stats", "Show series and label statistics of the blocks.").Action(b.stats)
+	statsCmd.Flag("tenant", "Tenant ID owning the blocks.").Required().StringVar(&b.tenant)
+	statsCmd.Flag("top", "Number of metric names and label names with the highest cardinality to show.").Default("10").IntVar(&b.topN)
+	statsCmd.Arg("block-id", "IDs of the blocks to show statistics for.").Required().StringsVar(&b.blockIDs)
+
+	repairCmd := blocksCmd.Command("repair", "Repair out-of-order and duplicated chunks of the blocks. Each repaired block is rewritten to a new block, and the original one is marked for deletion.").Action(b.repair)
+	repairCmd.Flag("tenant", "Tenant ID owning the blocks.").Required().StringVar(&b.tenant)
+	repairCmd.Flag("dry-run", "Only report the issues which would be repaired, without uploading the repaired blocks.").BoolVar(&b.dryRun)
+	repairCmd.Arg("block-id", "IDs of the blocks to repair.").Required().StringsVar(&b.blockIDs)
+
+	markCmd := blocksCmd.Command("mark", "Mark the blocks for deletion or to not be compacted.").Action(b.markBlocks)
+	markCmd.Flag("tenant", "Tenant ID owning the blocks.").Required().StringVar(&b.tenant)
+	markCmd.Flag("mark", "Mark type to create, valid options: deletion, no-compact.").Required().EnumVar(&b.mark, markDeletion, markNoCompact)
+	markCmd.Flag("details", "Details field of the uploaded marks.").StringVar(&b.details)
+	markCmd.Flag("dry-run", "Only report the marks which would be uploaded.").BoolVar(&b.dryRun)
+	markCmd.Arg("block-id", "IDs of the blocks to mark.").Required().StringsVar(&b.blockIDs)
+}
+
+func (b *BlocksCommand) setup(ctx context.Context) error {
+	b.logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	b.out = os.Stdout
+
+	cfg, err := parseBucketConfig(b.bucketConfig)
+	if err != nil {
+		return errors.Wrap(err, "error when parsing bucket config")
+	}
+
+	b.bkt, err = bucket.NewClient(ctx, cfg, "blocks", b.logger, prometheus.DefaultRegisterer)
+	return errors.Wrap(err, "failed to create the bucket client")
+}
+
+// blockEntry is the JSON representation of a block returned by the list command.
+type blockEntry struct {
+	Tenant          string            `json:"tenant"`
+	BlockID         string            `json:"block_id"`
+	MinTime         time.Time         `json:"min_time"`
+	MaxTime         time.Time         `json:"max_time"`
+	Duration        string            `json:"duration"`
+	CompactionLevel int               `json:"compaction_level"`
+	NumSeries       uint64            `json:"num_series"`
+	NumSamples      uint64            `json:"num_samples"`
+	NumChunks       uint64            `json:"num_chunks"`
+	SizeBytes       uint64            `json:"size_bytes"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	DeletionTime    *time.Time        `json:"deletion_time,omitempty"`
+}
+
+func (b *BlocksCommand) list(_ *kingpin.ParseContext) error {
+	ctx := context.Background()
+	if err := b.setup(ctx); err != nil {
+		return err
+	}
+
+	entries, err := b.listBlocks(ctx)
+	if err != nil {
+		return err
+	}
+	return b.printJSON(entries)
+}
+
+func (b *BlocksCommand) listBlocks(ctx context.Context) ([]blockEntry, error) {
+	minTime, err := parseOptionalTime(b.minTime)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid min time")
+	}
+	maxTime, err := parseOptionalTime(b.maxTime)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid max time")
+	}
+
+	tenants := b.tenants
+	if len(tenants) == 0 {
+		tenants, _, err = tsdb.NewUsersScanner(b.bkt, tsdb.AllUsers, b.logger).ScanUsers(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tenants")
+		}
+	}
+
+	entries := []blockEntry{}
+	for _, tenant := range tenants {
+		metas, deletionTimes, err := listblocks.LoadMetaFilesAndDeletionMarkers(ctx, b.bkt, tenant, b.showDeleted, time.Time{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the blocks metadata of tenant %s", tenant)
+		}
+
+		for _, m := range listblocks.SortBlocks(metas) {
+			if !minTime.IsZero() && util.TimeFromMillis(m.MinTime).Before(minTime) {
+				continue
+			}
+			if !maxTime.IsZero() && util.TimeFromMillis(m.MaxTime).After(maxTime) {
+				continue
+			}
+
+			entry := blockEntry{
+				Tenant:          tenant,
+				BlockID:         m.ULID.String(),
+				MinTime:         util.TimeFromMillis(m.MinTime).UTC(),
+				MaxTime:         util.TimeFromMillis(m.MaxTime).UTC(),
+				Duration:        util.TimeFromMillis(m.MaxTime).Sub(util.TimeFromMillis(m.MinTime)).String(),
+				CompactionLevel: m.Compaction.Level,
+				NumSeries:       m.Stats.NumSeries,
+				NumSamples:      m.Stats.NumSamples,
+				NumChunks:       m.Stats.NumChunks,
+				SizeBytes:       listblocks.GetBlockSizeBytes(m),
+				Labels:          m.Thanos.Labels,
+			}
+			if t, ok := deletionTimes[m.ULID]; ok && !t.IsZero() {
+				t = t.UTC()
+				entry.DeletionTime = &t
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// blockVerifyResult is the JSON representation of the result of the verify command for a block.
+type blockVerifyResult struct {
+	BlockID     string            `json:"block_id"`
+	Healthy     bool              `json:"healthy"`
+	Issues      []string          `json:"issues,omitempty"`
+	IndexStats  block.HealthStats `json:"index_stats"`
+	ChunksStats *chunksStats      `json:"chunks_stats,omitempty"`
+}
+
+func (b *BlocksCommand) verify(_ *kingpin.ParseContext) error {
+	ctx := context.Background()
+	if err := b.setup(ctx); err != nil {
+		return err
+	}
+
+	results, err := b.verifyBlocks(ctx)
+	if err != nil {
+		return err
+	}
+	return b.printJSON(results)
+}
+
+func (b *BlocksCommand) verifyBlocks(ctx context.Context) ([]blockVerifyResult, error) {
+	ids, err := parseBlockIDs(b.blockIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	userBkt := b.userBucket()
+	results := make([]blockVerifyResult, 0, len(ids))
+
+	for _, id := range ids {
+		var res blockVerifyResult
+		err := b.withDownloadedBlock(ctx, userBkt, id, b.checkChunks, func(dir string, meta *metadata.Meta) error {
+			var err error
+			res, err = verifyBlockDir(b.logger, dir, meta, b.checkChunks)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to verify block %s", id)
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+func (b *BlocksCommand) stats(_ *kingpin.ParseContext) error {
+	ctx := context.Background()
+	if err := b.setup(ctx); err != nil {
+		return err
+	}
+
+	results, err := b.blocksStats(ctx)
+	if err != nil {
+		return err
+	}
+	return b.printJSON(results)
+}
+
+func (b *BlocksCommand) blocksStats(ctx context.Context) ([]blockIndexStats, error) {
+	ids, err := parseBlockIDs(b.blockIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	userBkt := b.userBucket()
+	results := make([]blockIndexStats, 0, len(ids))
+
+	for _, id := range ids {
+		var res blockIndexStats
+		err := b.withDownloadedBlock(ctx, userBkt, id, false, func(dir string, _ *metadata.Meta) error {
+			var err error
+			res, err = gatherBlockIndexStats(filepath.Join(dir, block.IndexFilename), b.topN)
+			res.BlockID = id.String()
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to gather statistics of block %s", id)
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// blockRepairResult is the JSON representation of the result of the repair command for a block.
+type blockRepairResult struct {
+	BlockID         string   `json:"block_id"`
+	Issues          []string `json:"issues,omitempty"`
+	Repaired        bool     `json:"repaired"`
+	RepairedBlockID string   `json:"repaired_block_id,omitempty"`
+}
+
+func (b *BlocksCommand) repair(_ *kingpin.ParseContext) error {
+	ctx := context.Background()
+	if err := b.setup(ctx); err != nil {
+		return err
+	}
+
+	results, err := b.repairBlocks(ctx)
+	if err != nil {
+		return err
+	}
+	return b.printJSON(results)
+}
+
+func (b *BlocksCommand) repairBlocks(ctx context.Context) ([]blockRepairResult, error) {
+	ids, err := parseBlockIDs(b.blockIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	userBkt := b.userBucket()
+	results := make([]blockRepairResult, 0, len(ids))
+
+	for _, id := range ids {
+		res := blockRepairResult{BlockID: id.String()}
+
+		err := b.withDownloadedBlock(ctx, userBkt, id, true, func(dir string, meta *metadata.Meta) error {
+			stats, err := block.GatherIndexHealthStats(b.logger, filepath.Join(dir, block.IndexFilename), meta.MinTime, meta.MaxTime)
+			if err != nil {
+				return errors.Wrap(err, "gather index health stats")
+			}
+			if err := stats.AnyErr(); err != nil {
+				res.Issues = append(res.Issues, err.Error())
+			}
+			if stats.OutOfOrderChunks == 0 && stats.DuplicatedChunks == 0 && stats.CompleteOutsideChunks == 0 && stats.Issue347OutsideChunks == 0 {
+				level.Info(b.logger).Log("msg", "block has no repairable issues", "block", id)
+				return nil
+			}
+			if b.dryRun {
+				level.Info(b.logger).Log("msg", "dry-run, not repairing block", "block", id)
+				return nil
+			}
+
+			// The block is downloaded into a directory named after its ID, the repaired block is written beside it.
+			resID, err := block.Repair(b.logger, filepath.Dir(dir), id, metadata.BucketRepairSource,
+				block.IgnoreCompleteOutsideChunk, block.IgnoreIssue347OutsideChunk, block.IgnoreDuplicateOutsideChunk)
+			if err != nil {
+				return errors.Wrap(err, "repair block")
+			}
+
+			resDir := filepath.Join(filepath.Dir(dir), resID.String())
+			if err := block.VerifyIndex(b.logger, filepath.Join(resDir, block.IndexFilename), meta.MinTime, meta.MaxTime); err != nil {
+				return errors.Wrapf(err, "repaired block %s is invalid", resID)
+			}
+
+			level.Info(b.logger).Log("msg", "uploading repaired block", "block", id, "repaired_block", resID)
+			if err := block.Upload(ctx, b.logger, userBkt, resDir, metadata.NoneFunc); err != nil {
+				return errors.Wrapf(err, "upload repaired block %s", resID)
+			}
+
+			if err := block.MarkForDeletion(ctx, b.logger, userBkt, id, "source of repaired block "+resID.String(), prometheus.NewCounter(prometheus.CounterOpts{})); err != nil {
+				return errors.Wrap(err, "mark block for deletion")
+			}
+
+			res.Repaired = true
+			res.RepairedBlockID = resID.String()
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to repair block %s", id)
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// blockMarkResult is the JSON representation of the result of the mark command for a block.
+type blockMarkResult struct {
+	BlockID string `json:"block_id"`
+	Marked  bool   `json:"marked"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (b *BlocksCommand) markBlocks(_ *kingpin.ParseContext) error {
+	ctx := context.Background()
+	if err := b.setup(ctx); err != nil {
+		return err
+	}
+
+	results, err := b.uploadMarks(ctx)
+	if err != nil {
+		return err
+	}
+	return b.printJSON(results)
+}
+
+func (b *BlocksCommand) uploadMarks(ctx context.Context) ([]blockMarkResult, error) {
+	ids, err := parseBlockIDs(b.blockIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	markFilename := metadata.DeletionMarkFilename
+	if b.mark == markNoCompact {
+		markFilename = metadata.NoCompactMarkFilename
+	}
+
+	userBkt := b.userBucket()
+	results := make([]blockMarkResult, 0, len(ids))
+
+	for _, id := range ids {
+		res := blockMarkResult{BlockID: id.String()}
+
+		if exists, err := userBkt.Exists(ctx, path.Join(id.String(), block.MetaFilename)); err != nil {
+			return nil, errors.Wrapf(err, "failed to check the existence of block %s", id)
+		} else if !exists {
+			res.Reason = "block does not exist"
+			results = append(results, res)
+			continue
+		}
+
+		if exists, err := userBkt.Exists(ctx, path.Join(id.String(), markFilename)); err != nil {
+			return nil, errors.Wrapf(err, "failed to check the existence of the mark of block %s", id)
+		} else if exists {
+			res.Reason = "mark already exists"
+			results = append(results, res)
+			continue
+		}
+
+		if b.dryRun {
+			res.Reason = "dry-run"
+			results = append(results, res)
+			continue
+		}
+
+		counter := prometheus.NewCounter(prometheus.CounterOpts{})
+		if b.mark == markNoCompact {
+			err = block.MarkForNoCompact(ctx, b.logger, userBkt, id, metadata.ManualNoCompactReason, b.details, counter)
+		} else {
+			err = block.MarkForDeletion(ctx, b.logger, userBkt, id, b.details, counter)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to mark block %s", id)
+		}
+
+		res.Marked = true
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// userBucket returns the bucket client of the tenant passed to the command, which also
+// writes the block markers to the global markers location.
+func (b *BlocksCommand) userBucket() objstore.Bucket {
+	return bucketindex.BucketWithGlobalMarkers(bucket.NewUserBucketClient(b.tenant, b.bkt, nil))
+}
+
+// withDownloadedBlock downloads the block index, and optionally its chunks, to a temporary directory
+// named after the block ID, and calls f with it. The directory is removed once f returns.
+func (b *BlocksCommand) withDownloadedBlock(ctx context.Context, userBkt objstore.Bucket, id ulid.ULID, withChunks bool, f func(dir string, meta *metadata.Meta) error) error {
+	tmpDir, err := ioutil.TempDir("", "mimirtool-blocks")
+	if err != nil {
+		return errors.Wrap(err, "create temporary directory")
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove temporary directory", "dir", tmpDir, "err", err)
+		}
+	}()
+
+	dir := filepath.Join(tmpDir, id.String())
+	if withChunks {
+		if err := block.Download(ctx, b.logger, userBkt, id, dir); err != nil {
+			return errors.Wrap(err, "download block")
+		}
+	} else {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return errors.Wrap(err, "create block directory")
+		}
+		for _, name := range []string{block.MetaFilename, block.IndexFilename} {
+			if err := objstore.DownloadFile(ctx, b.logger, userBkt, path.Join(id.String(), name), filepath.Join(dir, name)); err != nil {
+				return errors.Wrapf(err, "download %s", name)
+			}
+		}
+	}
+
+	meta, err := metadata.ReadFromDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "read block meta")
+	}
+
+	return f(dir, meta)
+}
+
+func (b *BlocksCommand) printJSON(v interface{}) error {
+	enc := json.NewEncoder(b.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func parseBlockIDs(blockIDs []string) ([]ulid.ULID, error) {
+	ids := make([]ulid.ULID, 0, len(blockIDs))
+	for _, s := range blockIDs {
+		id, err := ulid.Parse(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid block ID %q", s)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseOptionalTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+// chunksStats holds the result of the verification of the samples in the chunks of a block.
+type chunksStats struct {
+	TotalChunks           int64 `json:"total_chunks"`
+	TotalSamples          int64 `json:"total_samples"`
+	UnreadableChunks      int64 `json:"unreadable_chunks"`
+	EmptyChunks           int64 `json:"empty_chunks"`
+	OutOfOrderSamples     int64 `json:"out_of_order_samples"`
+	TimeRangeMismatches   int64 `json:"time_range_mismatches"`
+	SampleIterationErrors int64 `json:"sample_iteration_errors"`
+}
+
+func (s chunksStats) issues() []string {
+	var issues []string
+	if s.UnreadableChunks > 0 {
+		issues = append(issues, fmt.Sprintf("found %d chunks which can't be read", s.UnreadableChunks))
+	}
+	if s.SampleIterationErrors > 0 {
+		issues = append(issues, fmt.Sprintf("found %d chunks failing while iterating samples", s.SampleIterationErrors))
+	}
+	if s.EmptyChunks > 0 {
+		issues = append(issues, fmt.Sprintf("found %d chunks without samples", s.EmptyChunks))
+	}
+	if s.OutOfOrderSamples > 0 {
+		issues = append(issues, fmt.Sprintf("found %d samples with a timestamp not strictly higher than the previous sample", s.OutOfOrderSamples))
+	}
+	if s.TimeRangeMismatches > 0 {
+		issues = append(issues, fmt.Sprintf("found %d chunks whose samples don't match the chunk time range in the index", s.TimeRangeMismatches))
+	}
+	return issues
+}
+
+// verifyBlockDir verifies the index of the block in dir and, if checkChunks is true, the samples in its chunks.
+func verifyBlockDir(logger log.Logger, dir string, meta *metadata.Meta, checkChunks bool) (blockVerifyResult, error) {
+	res := blockVerifyResult{BlockID: meta.ULID.String()}
+
+	stats, err := block.GatherIndexHealthStats(logger, filepath.Join(dir, block.IndexFilename), meta.MinTime, meta.MaxTime)
+	if err != nil {
+		// The index can't be walked at all, which is an issue of the block rather than a failure of the command.
+		res.Issues = append(res.Issues, err.Error())
+		return res, nil
+	}
+	res.IndexStats = stats
+	if err := stats.AnyErr(); err != nil {
+		res.Issues = append(res.Issues, err.Error())
+	}
+
+	if checkChunks {
+		chkStats, err := verifyBlockChunks(dir)
+		if err != nil {
+			return res, err
+		}
+		res.ChunksStats = &chkStats
+		res.Issues = append(res.Issues, chkStats.issues()...)
+	}
+
+	res.Healthy = len(res.Issues) == 0
+	return res, nil
+}
+
+// verifyBlockChunks reads every chunk referenced by the index of the block in dir, and checks that its
+// samples are in order and match the chunk time range.
+func verifyBlockChunks(dir string) (stats chunksStats, err error) {
+	cr, err := chunks.NewDirReader(filepath.Join(dir, block.ChunksDirname), nil)
+	if err != nil {
+		return stats, errors.Wrap(err, "open chunks dir")
+	}
+	defer runutil.CloseWithErrCapture(&err, cr, "close chunks reader")
+
+	r, err := index.NewFileReader(filepath.Join(dir, block.IndexFilename))
+	if err != nil {
+		return stats, errors.Wrap(err, "open index file")
+	}
+	defer runutil.CloseWithErrCapture(&err, r, "close index reader")
+
+	p, err := r.Postings(index.AllPostingsKey())
+	if err != nil {
+		return stats, errors.Wrap(err, "get all postings")
+	}
+
+	var (
+		lset labels.Labels
+		chks []chunks.Meta
+	)
+
+	for p.Next() {
+		if err := r.Series(p.At(), &lset, &chks); err != nil {
+			return stats, errors.Wrap(err, "read series")
+		}
+
+		for _, cm := range chks {
+			stats.TotalChunks++
+
+			ch, err := cr.Chunk(cm.Ref)
+			if err != nil {
+				stats.UnreadableChunks++
+				continue
+			}
+
+			samples := int64(0)
+			firstTs, prevTs := int64(0), int64(0)
+
+			it := ch.Iterator(nil)
+			for it.Next() {
+				ts, _ := it.At()
+				if samples == 0 {
+					firstTs = ts
+				} else if ts <= prevTs {
+					stats.OutOfOrderSamples++
+				}
+				prevTs = ts
+				samples++
+			}
+			stats.TotalSamples += samples
+
+			switch {
+			case it.Err() != nil:
+				stats.SampleIterationErrors++
+			case samples == 0:
+				stats.EmptyChunks++
+			case firstTs != cm.MinTime || prevTs != cm.MaxTime:
+				stats.TimeRangeMismatches++
+			}
+		}
+	}
+	if p.Err() != nil {
+		return stats, errors.Wrap(p.Err(), "walk postings")
+	}
+
+	return stats, nil
+}
+
+// nameCardinality is the number of series, and optionally of label values, of a metric or label name.
+type nameCardinality struct {
+	Name   string `json:"name"`
+	Series int64  `json:"series"`
+	Values int64  `json:"values,omitempty"`
+}
+
+// blockIndexStats holds the series and label statistics of a block.
+type blockIndexStats struct {
+	BlockID          string            `json:"block_id"`
+	TotalSeries      int64             `json:"total_series"`
+	TotalChunks      int64             `json:"total_chunks"`
+	MetricNamesCount int64             `json:"metric_names_count"`
+	LabelNamesCount  int64             `json:"label_names_count"`
+	TopMetrics       []nameCardinality `json:"top_metrics_by_series"`
+	TopLabelNames    []nameCardinality `json:"top_label_names_by_values"`
+}
+
+// gatherBlockIndexStats computes the series and label statistics of the index at indexPath, showing the
+// topN metric names with the most series and the topN label names with the most values.
+func gatherBlockIndexStats(indexPath string, topN int) (stats blockIndexStats, err error) {
+	r, err := index.NewFileReader(indexPath)
+	if err != nil {
+		return stats, errors.Wrap(err, "open index file")
+	}
+	defer runutil.CloseWithErrCapture(&err, r, "close index reader")
+
+	p, err := r.Postings(index.AllPostingsKey())
+	if err != nil {
+		return stats, errors.Wrap(err, "get all postings")
+	}
+
+	var (
+		lset         labels.Labels
+		chks         []chunks.Meta
+		metricSeries = map[string]int64{}
+		labelSeries  = map[string]int64{}
+	)
+
+	for p.Next() {
+		if err := r.Series(p.At(), &lset, &chks); err != nil {
+			return stats, errors.Wrap(err, "read series")
+		}
+
+		stats.TotalSeries++
+		stats.TotalChunks += int64(len(chks))
+		metricSeries[lset.Get(labels.MetricName)]++
+		for _, l := range lset {
+			labelSeries[l.Name]++
+		}
+	}
+	if p.Err() != nil {
+		return stats, errors.Wrap(p.Err(), "walk postings")
+	}
+
+	stats.MetricNamesCount = int64(len(metricSeries))
+	stats.LabelNamesCount = int64(len(labelSeries))
+
+	for name, series := range metricSeries {
+		stats.TopMetrics = append(stats.TopMetrics, nameCardinality{Name: name, Series: series})
+	}
+	sortNameCardinalities(stats.TopMetrics, func(c nameCardinality) int64 { return c.Series })
+	stats.TopMetrics = truncateNameCardinalities(stats.TopMetrics, topN)
+
+	for name, series := range labelSeries {
+		values, err := r.LabelValues(name)
+		if err != nil {
+			return stats, errors.Wrapf(err, "label values of %s", name)
+		}
+		stats.TopLabelNames = append(stats.TopLabelNames, nameCardinality{Name: name, Series: series, Values: int64(len(values))})
+	}
+	sortNameCardinalities(stats.TopLabelNames, func(c nameCardinality) int64 { return c.Values })
+	stats.TopLabelNames = truncateNameCardinalities(stats.TopLabelNames, topN)
+
+	return stats, nil
+}
+
+// sortNameCardinalities sorts the input by the value returned by key in descending order, and then by name.
+func sortNameCardinalities(s []nameCardinality, key func(nameCardinality) int64) {
+	sort.Slice(s, func(i, j int) bool {
+		if ki, kj := key(s[i]), key(s[j]); ki != kj {
+			return ki > kj
+		}
+		return s[i].Name < s[j].Name
+	})
+}
+
+func truncateNameCardinalities(s []nameCardinality, n int) []nameCardinality {
+	if n > 0 && len(s) > n {
+		return s[:n]
+	}
+	return s
+}
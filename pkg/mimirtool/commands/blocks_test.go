@@ -0,0 +1,285 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/tsdbutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/bucket/filesystem"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+type blockSample struct {
+	t int64
+	v float64
+}
+
+func (s blockSample) T() int64   { return s.t }
+func (s blockSample) V() float64 { return s.v }
+
+func chunkFromSamples(ts ...int64) chunks.Meta {
+	samples := make([]tsdbutil.Sample, 0, len(ts))
+	for _, t := range ts {
+		samples = append(samples, blockSample{t: t, v: float64(t)})
+	}
+	return tsdbutil.ChunkFromSamples(samples)
+}
+
+// prepareBlocksBucket returns a filesystem bucket containing a healthy block and a block with
+// out-of-order and duplicated chunks for the given tenant.
+func prepareBlocksBucket(t *testing.T, tenant string) (objstore.Bucket, *metadata.Meta, *metadata.Meta) {
+	storageDir := t.TempDir()
+
+	healthy, err := mimir_testutil.GenerateBlockFromSpec(tenant, filepath.Join(storageDir, tenant), mimir_testutil.BlockSeriesSpecs{
+		{Labels: labels.FromStrings(labels.MetricName, "metric_1", "series", "1"), Chunks: []chunks.Meta{chunkFromSamples(10, 11), chunkFromSamples(20, 21)}},
+		{Labels: labels.FromStrings(labels.MetricName, "metric_1", "series", "2"), Chunks: []chunks.Meta{chunkFromSamples(10, 11)}},
+		{Labels: labels.FromStrings(labels.MetricName, "metric_2", "series", "1"), Chunks: []chunks.Meta{chunkFromSamples(10, 21)}},
+	})
+	require.NoError(t, err)
+
+	broken, err := mimir_testutil.GenerateBlockFromSpec(tenant, filepath.Join(storageDir, tenant), mimir_testutil.BlockSeriesSpecs{
+		{Labels: labels.FromStrings(labels.MetricName, "metric_1", "series", "1"), Chunks: []chunks.Meta{chunkFromSamples(20, 21), chunkFromSamples(10, 11), chunkFromSamples(10, 11)}},
+	})
+	require.NoError(t, err)
+
+	bkt, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	return bkt, healthy, broken
+}
+
+func newTestBlocksCommand(bkt objstore.Bucket, tenants ...string) (*BlocksCommand, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	cmd := &BlocksCommand{bkt: bkt, tenants: tenants, out: out, logger: log.NewNopLogger()}
+	if len(tenants) > 0 {
+		cmd.tenant = tenants[0]
+	}
+	return cmd, out
+}
+
+func TestBlocksCommand_listBlocks(t *testing.T) {
+	ctx := context.Background()
+	bkt, healthy, broken := prepareBlocksBucket(t, "user-1")
+
+	// Listing only reads the meta.json of the blocks.
+	require.NoError(t, bkt.Upload(ctx, path.Join("user-2", healthy.ULID.String(), block.MetaFilename), bytes.NewReader(mustMarshalMeta(t, healthy))))
+
+	userBkt := bucketindex.BucketWithGlobalMarkers(bucket.NewUserBucketClient("user-1", bkt, nil))
+	require.NoError(t, block.MarkForDeletion(ctx, log.NewNopLogger(), userBkt, broken.ULID, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	t.Run("should list the blocks not marked for deletion of the tenant", func(t *testing.T) {
+		cmd, _ := newTestBlocksCommand(bkt, "user-1")
+
+		entries, err := cmd.listBlocks(ctx)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "user-1", entries[0].Tenant)
+		assert.Equal(t, healthy.ULID.String(), entries[0].BlockID)
+		assert.Nil(t, entries[0].DeletionTime)
+	})
+
+	t.Run("should list the blocks marked for deletion if requested", func(t *testing.T) {
+		cmd, _ := newTestBlocksCommand(bkt, "user-1")
+		cmd.showDeleted = true
+
+		entries, err := cmd.listBlocks(ctx)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		for _, e := range entries {
+			assert.Equal(t, e.BlockID == broken.ULID.String(), e.DeletionTime != nil)
+		}
+	})
+
+	t.Run("should list the blocks of all tenants if no tenant is set", func(t *testing.T) {
+		cmd, _ := newTestBlocksCommand(bkt)
+
+		entries, err := cmd.listBlocks(ctx)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "user-1", entries[0].Tenant)
+		assert.Equal(t, "user-2", entries[1].Tenant)
+	})
+
+	t.Run("should filter blocks by time range", func(t *testing.T) {
+		cmd, _ := newTestBlocksCommand(bkt, "user-1")
+		cmd.minTime = time.UnixMilli(15).UTC().Format(time.RFC3339Nano)
+
+		entries, err := cmd.listBlocks(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestBlocksCommand_verifyBlocks(t *testing.T) {
+	ctx := context.Background()
+	bkt, healthy, broken := prepareBlocksBucket(t, "user-1")
+
+	for _, checkChunks := range []bool{false, true} {
+		cmd, out := newTestBlocksCommand(bkt, "user-1")
+		cmd.checkChunks = checkChunks
+		cmd.blockIDs = []string{healthy.ULID.String(), broken.ULID.String()}
+
+		results, err := cmd.verifyBlocks(ctx)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+
+		assert.True(t, results[0].Healthy)
+		assert.Empty(t, results[0].Issues)
+		assert.Equal(t, int64(3), results[0].IndexStats.TotalSeries)
+
+		assert.False(t, results[1].Healthy)
+		assert.Equal(t, 1, results[1].IndexStats.OutOfOrderChunks)
+		assert.Equal(t, 1, results[1].IndexStats.DuplicatedChunks)
+
+		if checkChunks {
+			require.NotNil(t, results[0].ChunksStats)
+			assert.Equal(t, int64(4), results[0].ChunksStats.TotalChunks)
+			assert.Equal(t, int64(8), results[0].ChunksStats.TotalSamples)
+			assert.Empty(t, results[0].ChunksStats.issues())
+		} else {
+			assert.Nil(t, results[0].ChunksStats)
+		}
+
+		// The results are printed as JSON.
+		require.NoError(t, cmd.printJSON(results))
+		var decoded []map[string]interface{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		assert.Len(t, decoded, 2)
+	}
+}
+
+func TestBlocksCommand_blocksStats(t *testing.T) {
+	ctx := context.Background()
+	bkt, healthy, _ := prepareBlocksBucket(t, "user-1")
+
+	cmd, _ := newTestBlocksCommand(bkt, "user-1")
+	cmd.topN = 1
+	cmd.blockIDs = []string{healthy.ULID.String()}
+
+	results, err := cmd.blocksStats(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	assert.Equal(t, blockIndexStats{
+		BlockID:          healthy.ULID.String(),
+		TotalSeries:      3,
+		TotalChunks:      4,
+		MetricNamesCount: 2,
+		LabelNamesCount:  2,
+		TopMetrics:       []nameCardinality{{Name: "metric_1", Series: 2}},
+		TopLabelNames:    []nameCardinality{{Name: "__name__", Series: 3, Values: 2}},
+	}, results[0])
+}
+
+func TestBlocksCommand_repairBlocks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should only report issues on dry-run", func(t *testing.T) {
+		bkt, healthy, broken := prepareBlocksBucket(t, "user-1")
+
+		cmd, _ := newTestBlocksCommand(bkt, "user-1")
+		cmd.dryRun = true
+		cmd.blockIDs = []string{healthy.ULID.String(), broken.ULID.String()}
+
+		results, err := cmd.repairBlocks(ctx)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, blockRepairResult{BlockID: healthy.ULID.String()}, results[0])
+		assert.False(t, results[1].Repaired)
+		assert.NotEmpty(t, results[1].Issues)
+
+		exists, err := bkt.Exists(ctx, path.Join("user-1", broken.ULID.String(), metadata.DeletionMarkFilename))
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("should rewrite the broken block and mark it for deletion", func(t *testing.T) {
+		bkt, healthy, broken := prepareBlocksBucket(t, "user-1")
+
+		cmd, _ := newTestBlocksCommand(bkt, "user-1")
+		cmd.blockIDs = []string{healthy.ULID.String(), broken.ULID.String()}
+
+		results, err := cmd.repairBlocks(ctx)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.False(t, results[0].Repaired)
+		require.True(t, results[1].Repaired)
+
+		for _, name := range []string{
+			path.Join("user-1", broken.ULID.String(), metadata.DeletionMarkFilename),
+			path.Join("user-1", bucketindex.BlockDeletionMarkFilepath(broken.ULID)),
+		} {
+			exists, err := bkt.Exists(ctx, name)
+			require.NoError(t, err)
+			assert.True(t, exists, name)
+		}
+
+		// The repaired block is healthy.
+		cmd, _ = newTestBlocksCommand(bkt, "user-1")
+		cmd.checkChunks = true
+		cmd.blockIDs = []string{results[1].RepairedBlockID}
+
+		verified, err := cmd.verifyBlocks(ctx)
+		require.NoError(t, err)
+		require.Len(t, verified, 1)
+		assert.True(t, verified[0].Healthy, verified[0].Issues)
+		assert.Equal(t, int64(2), verified[0].IndexStats.TotalChunks)
+	})
+}
+
+func TestBlocksCommand_uploadMarks(t *testing.T) {
+	ctx := context.Background()
+	bkt, healthy, _ := prepareBlocksBucket(t, "user-1")
+	missing := "01FSCTA0A3C0NZ4KQXB8D8AZTQ"
+
+	cmd, _ := newTestBlocksCommand(bkt, "user-1")
+	cmd.mark = markNoCompact
+	cmd.details = "manually marked"
+	cmd.blockIDs = []string{healthy.ULID.String(), missing}
+
+	results, err := cmd.uploadMarks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []blockMarkResult{
+		{BlockID: healthy.ULID.String(), Marked: true},
+		{BlockID: missing, Reason: "block does not exist"},
+	}, results)
+
+	for _, name := range []string{
+		path.Join("user-1", healthy.ULID.String(), metadata.NoCompactMarkFilename),
+		path.Join("user-1", bucketindex.NoCompactMarkFilepath(healthy.ULID)),
+	} {
+		exists, err := bkt.Exists(ctx, name)
+		require.NoError(t, err)
+		assert.True(t, exists, name)
+	}
+
+	// Marking again is a no-op.
+	results, err = cmd.uploadMarks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, blockMarkResult{BlockID: healthy.ULID.String(), Reason: "mark already exists"}, results[0])
+}
+
+func mustMarshalMeta(t *testing.T, meta *metadata.Meta) []byte {
+	data, err := json.Marshal(meta)
+	require.NoError(t, err)
+	return data
+}
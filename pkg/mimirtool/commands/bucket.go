@@ -0,0 +1,306 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	"github.com/grafana/mimir/pkg/alertmanager/alertstore/bucketclient"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	rulestore_bucketclient "github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketcopy"
+)
+
+// BucketCommand is the kingpin command for operations working directly on object storage buckets.
+type BucketCommand struct {
+	srcBlocksBucketConfig       string
+	dstBlocksBucketConfig       string
+	srcRulerBucketConfig        string
+	dstRulerBucketConfig        string
+	srcAlertmanagerBucketConfig string
+	dstAlertmanagerBucketConfig string
+
+	srcTenant       string
+	dstTenant       string
+	verifyChecksums bool
+	concurrency     int
+	dryRun          bool
+
+	tenantMappings   []string
+	tenantLabel      string
+	defaultTenant    string
+	keepLabels       []string
+	progressInterval int
+
+	logger log.Logger
+}
+
+// Register is used to register the command to a parent command.
+func (b *BucketCommand) Register(app *kingpin.Application, _ EnvVarNames) {
+	bucketCmd := app.Command("bucket", "Operate on object storage buckets used by Grafana Mimir.")
+
+	copyCmd := bucketCmd.Command("copy-tenant", "Copy blocks, block markers, bucket index, ruler rule groups and alertmanager configuration and state of a tenant from a bucket to another. "+
+		"The copy can be safely interrupted and resumed: blocks which have already been fully copied are skipped.").Action(b.copyTenant)
+	copyCmd.Flag("source-tenant", "Tenant ID to copy from the source buckets.").Required().StringVar(&b.srcTenant)
+	copyCmd.Flag("destination-tenant", "Tenant ID to write to the destination buckets. If empty, the source tenant ID is used.").StringVar(&b.dstTenant)
+	copyCmd.Flag("source.blocks-bucket-config", "The CLI args to configure the source blocks storage bucket, e.g. '-backend=s3 -s3.bucket-name=blocks'. If empty, blocks are not copied.").StringVar(&b.srcBlocksBucketConfig)
+	copyCmd.Flag("destination.blocks-bucket-config", "The CLI args to configure the destination blocks storage bucket.").StringVar(&b.dstBlocksBucketConfig)
+	copyCmd.Flag("source.ruler-bucket-config", "The CLI args to configure the source ruler storage bucket. If empty, rule groups are not copied.").StringVar(&b.srcRulerBucketConfig)
+	copyCmd.Flag("destination.ruler-bucket-config", "The CLI args to configure the destination ruler storage bucket.").StringVar(&b.dstRulerBucketConfig)
+	copyCmd.Flag("source.alertmanager-bucket-config", "The CLI args to configure the source alertmanager storage bucket. If empty, alertmanager configuration and state are not copied.").StringVar(&b.srcAlertmanagerBucketConfig)
+	copyCmd.Flag("destination.alertmanager-bucket-config", "The CLI args to configure the destination alertmanager storage bucket.").StringVar(&b.dstAlertmanagerBucketConfig)
+	copyCmd.Flag("verify-checksums", "Read back each copied block file from the destination bucket and verify its checksum.").Default("true").BoolVar(&b.verifyChecksums)
+	copyCmd.Flag("concurrency", "Number of blocks copied concurrently.").Default("4").IntVar(&b.concurrency)
+	copyCmd.Flag("dry-run", "Only report what would be copied, without writing to the destination buckets.").BoolVar(&b.dryRun)
+
+	migrateCmd := bucketCmd.Command("migrate-thanos", "Migrate the raw blocks of a Thanos bucket to a Grafana Mimir blocks bucket, mapping the external labels of each block to a tenant. "+
+		"Downsampled blocks and blocks marked for deletion are not migrated, and the bucket index of each tenant is generated at the end of the migration. "+
+		"The migration can be safely interrupted and resumed: blocks which have already been fully migrated are skipped.").Action(b.migrateThanos)
+	migrateCmd.Flag("source.bucket-config", "The CLI args to configure the source Thanos bucket, e.g. '-backend=s3 -s3.bucket-name=thanos'.").Required().StringVar(&b.srcBlocksBucketConfig)
+	migrateCmd.Flag("destination.bucket-config", "The CLI args to configure the destination blocks storage bucket.").Required().StringVar(&b.dstBlocksBucketConfig)
+	migrateCmd.Flag("tenant-mapping", "Map the blocks whose external labels match a selector to a tenant, in the form '<selector>=<tenant>', e.g. '{cluster=\"prod\"}=prod'. "+
+		"Can be repeated: mappings are evaluated in order and the first matching one is used.").StringsVar(&b.tenantMappings)
+	migrateCmd.Flag("tenant-label", "External label whose value is used as tenant for the blocks not matching any tenant mapping.").StringVar(&b.tenantLabel)
+	migrateCmd.Flag("default-tenant", "Tenant of the blocks not mapped by a tenant mapping or the tenant label. If empty, such blocks are not migrated.").StringVar(&b.defaultTenant)
+	migrateCmd.Flag("keep-label", "External label to keep in the migrated blocks. Can be repeated. All other external labels are replaced with the tenant ID label.").StringsVar(&b.keepLabels)
+	migrateCmd.Flag("concurrency", "Number of blocks migrated concurrently.").Default("4").IntVar(&b.concurrency)
+	migrateCmd.Flag("progress-interval", "Log the migration progress every this number of processed blocks.").Default("100").IntVar(&b.progressInterval)
+	migrateCmd.Flag("dry-run", "Only report what would be migrated, without writing to the destination bucket.").BoolVar(&b.dryRun)
+}
+
+func (b *BucketCommand) copyTenant(_ *kingpin.ParseContext) error {
+	b.logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	ctx := context.Background()
+
+	if b.dstTenant == "" {
+		b.dstTenant = b.srcTenant
+	}
+	if b.srcBlocksBucketConfig == "" && b.srcRulerBucketConfig == "" && b.srcAlertmanagerBucketConfig == "" {
+		return errors.New("at least one of the source blocks, ruler or alertmanager bucket configs must be set")
+	}
+
+	if b.srcBlocksBucketConfig != "" {
+		srcBkt, dstBkt, err := b.newBucketClients(ctx, "blocks", b.srcBlocksBucketConfig, b.dstBlocksBucketConfig)
+		if err != nil {
+			return err
+		}
+
+		copier := bucketcopy.NewTenantCopier(bucketcopy.Config{
+			SourceTenant:      b.srcTenant,
+			DestinationTenant: b.dstTenant,
+			VerifyChecksums:   b.verifyChecksums,
+			CopyConcurrency:   b.concurrency,
+			DryRun:            b.dryRun,
+		}, srcBkt, dstBkt, nil, b.logger)
+
+		stats, err := copier.CopyBlocks(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to copy blocks")
+		}
+
+		level.Info(b.logger).Log("msg", "copied blocks", "blocks_copied", stats.BlocksCopied, "blocks_skipped", stats.BlocksSkipped, "partial_blocks", stats.PartialsFound,
+			"markers_copied", stats.MarkersCopied, "objects_copied", stats.ObjectsCopied, "bytes_copied", stats.BytesCopied, "bucket_index_generated", stats.IndexGenerated)
+	}
+
+	if b.srcRulerBucketConfig != "" {
+		srcBkt, dstBkt, err := b.newBucketClients(ctx, "ruler", b.srcRulerBucketConfig, b.dstRulerBucketConfig)
+		if err != nil {
+			return err
+		}
+
+		if err := b.copyRuleGroups(ctx, srcBkt, dstBkt); err != nil {
+			return errors.Wrap(err, "failed to copy rule groups")
+		}
+	}
+
+	if b.srcAlertmanagerBucketConfig != "" {
+		srcBkt, dstBkt, err := b.newBucketClients(ctx, "alertmanager", b.srcAlertmanagerBucketConfig, b.dstAlertmanagerBucketConfig)
+		if err != nil {
+			return err
+		}
+
+		if err := b.copyAlertmanager(ctx, srcBkt, dstBkt); err != nil {
+			return errors.Wrap(err, "failed to copy alertmanager")
+		}
+	}
+
+	return nil
+}
+
+func (b *BucketCommand) migrateThanos(_ *kingpin.ParseContext) error {
+	b.logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	ctx := context.Background()
+
+	cfg := bucketcopy.MigratorConfig{
+		TenantLabel:      b.tenantLabel,
+		DefaultTenant:    b.defaultTenant,
+		KeepLabels:       b.keepLabels,
+		CopyConcurrency:  b.concurrency,
+		ProgressInterval: b.progressInterval,
+		DryRun:           b.dryRun,
+	}
+	for _, m := range b.tenantMappings {
+		mapping, err := bucketcopy.ParseTenantMapping(m)
+		if err != nil {
+			return err
+		}
+		cfg.TenantMappings = append(cfg.TenantMappings, mapping)
+	}
+	if len(cfg.TenantMappings) == 0 && cfg.TenantLabel == "" && cfg.DefaultTenant == "" {
+		return errors.New("at least one of tenant mapping, tenant label or default tenant must be set")
+	}
+
+	srcBkt, dstBkt, err := b.newBucketClients(ctx, "blocks", b.srcBlocksBucketConfig, b.dstBlocksBucketConfig)
+	if err != nil {
+		return err
+	}
+
+	stats, err := bucketcopy.NewThanosMigrator(cfg, srcBkt, dstBkt, b.logger).Migrate(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to migrate blocks")
+	}
+
+	userIDs := make([]string, 0, len(stats.Tenants))
+	for userID := range stats.Tenants {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+	for _, userID := range userIDs {
+		level.Info(b.logger).Log("msg", "migrated tenant", "user", userID, "blocks", stats.Tenants[userID])
+	}
+	level.Info(b.logger).Log("msg", "migrated blocks", "blocks_migrated", stats.BlocksMigrated, "blocks_already_migrated", stats.BlocksAlreadyMigrated,
+		"unmapped_blocks", stats.BlocksUnmapped, "downsampled_blocks", stats.BlocksDownsampled, "blocks_marked_for_deletion", stats.BlocksMarkedForDeletion,
+		"partial_blocks", stats.PartialsFound, "markers_copied", stats.MarkersCopied, "objects_copied", stats.ObjectsCopied, "bytes_copied", stats.BytesCopied,
+		"bucket_indexes_generated", stats.IndexesGenerated)
+	return nil
+}
+
+func (b *BucketCommand) newBucketClients(ctx context.Context, name, srcConfig, dstConfig string) (objstore.Bucket, objstore.Bucket, error) {
+	if dstConfig == "" {
+		return nil, nil, fmt.Errorf("the destination %s bucket config must be set when the source one is", name)
+	}
+
+	srcCfg, err := parseBucketConfig(srcConfig)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error when parsing source %s bucket config", name)
+	}
+	dstCfg, err := parseBucketConfig(dstConfig)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error when parsing destination %s bucket config", name)
+	}
+
+	srcBkt, err := bucket.NewClient(ctx, srcCfg, "source-"+name, b.logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create the source %s bucket client", name)
+	}
+	dstBkt, err := bucket.NewClient(ctx, dstCfg, "destination-"+name, b.logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create the destination %s bucket client", name)
+	}
+
+	return srcBkt, dstBkt, nil
+}
+
+func (b *BucketCommand) copyRuleGroups(ctx context.Context, srcBkt, dstBkt objstore.Bucket) error {
+	srcStore := rulestore_bucketclient.NewBucketRuleStore(srcBkt, nil, 0, b.logger)
+	dstStore := rulestore_bucketclient.NewBucketRuleStore(dstBkt, nil, 0, b.logger)
+
+	groups, err := srcStore.ListRuleGroupsForUserAndNamespace(ctx, b.srcTenant, "")
+	if err != nil {
+		return errors.Wrap(err, "list source rule groups")
+	}
+
+	if err := srcStore.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{b.srcTenant: groups}); err != nil {
+		return errors.Wrap(err, "load source rule groups")
+	}
+
+	for _, rg := range groups {
+		rg.User = b.dstTenant
+
+		if !b.dryRun {
+			if err := dstStore.SetRuleGroup(ctx, b.dstTenant, rg.Namespace, rg); err != nil {
+				return errors.Wrapf(err, "write rule group %s/%s", rg.Namespace, rg.Name)
+			}
+		}
+	}
+
+	level.Info(b.logger).Log("msg", "copied rule groups", "rule_groups_copied", len(groups))
+	return nil
+}
+
+func (b *BucketCommand) copyAlertmanager(ctx context.Context, srcBkt, dstBkt objstore.Bucket) error {
+	srcStore := bucketclient.NewBucketAlertStore(srcBkt, nil, 0, b.logger)
+	dstStore := bucketclient.NewBucketAlertStore(dstBkt, nil, 0, b.logger)
+
+	cfg, err := srcStore.GetAlertConfig(ctx, b.srcTenant)
+	if errors.Is(err, alertspb.ErrNotFound) {
+		level.Info(b.logger).Log("msg", "no alertmanager configuration found for the source tenant")
+	} else if err != nil {
+		return errors.Wrap(err, "read source alertmanager configuration")
+	} else {
+		cfg.User = b.dstTenant
+		if !b.dryRun {
+			if err := dstStore.SetAlertConfig(ctx, cfg); err != nil {
+				return errors.Wrap(err, "write alertmanager configuration")
+			}
+		}
+		level.Info(b.logger).Log("msg", "copied alertmanager configuration")
+	}
+
+	fs, err := srcStore.GetFullState(ctx, b.srcTenant)
+	if errors.Is(err, alertspb.ErrNotFound) {
+		level.Info(b.logger).Log("msg", "no alertmanager state found for the source tenant")
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "read source alertmanager state")
+	}
+
+	if fs.State != nil {
+		renameAlertmanagerStateParts(fs.State, b.srcTenant, b.dstTenant)
+	}
+	if !b.dryRun {
+		if err := dstStore.SetFullState(ctx, b.dstTenant, fs); err != nil {
+			return errors.Wrap(err, "write alertmanager state")
+		}
+	}
+	level.Info(b.logger).Log("msg", "copied alertmanager state")
+	return nil
+}
+
+// renameAlertmanagerStateParts rewrites the keys of the state parts (notification log and silences),
+// which are suffixed with the tenant ID (e.g. "nfl:<user>").
+func renameAlertmanagerStateParts(state *clusterpb.FullState, srcTenant, dstTenant string) {
+	for i, p := range state.Parts {
+		if prefix := strings.TrimSuffix(p.Key, ":"+srcTenant); prefix != p.Key {
+			state.Parts[i].Key = prefix + ":" + dstTenant
+		}
+	}
+}
+
+// parseBucketConfig parses the CLI args to configure a storage bucket, as passed to -bucket-config flags.
+func parseBucketConfig(args string) (bucket.Config, error) {
+	cfg := bucket.Config{}
+
+	fs := flag.NewFlagSet("bucket-config", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	if err := fs.Parse(strings.Split(args, " ")); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	"github.com/grafana/mimir/pkg/alertmanager/alertstore/bucketclient"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	rulestore_bucketclient "github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestBucketCommand_copyRuleGroups(t *testing.T) {
+	ctx := context.Background()
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	srcStore := rulestore_bucketclient.NewBucketRuleStore(srcBkt, nil, 0, log.NewNopLogger())
+	require.NoError(t, srcStore.SetRuleGroup(ctx, "user-1", "ns-1", &rulespb.RuleGroupDesc{User: "user-1", Namespace: "ns-1", Name: "group-1"}))
+	require.NoError(t, srcStore.SetRuleGroup(ctx, "user-1", "ns-2", &rulespb.RuleGroupDesc{User: "user-1", Namespace: "ns-2", Name: "group-2"}))
+	require.NoError(t, srcStore.SetRuleGroup(ctx, "user-2", "ns-1", &rulespb.RuleGroupDesc{User: "user-2", Namespace: "ns-1", Name: "group-3"}))
+
+	cmd := &BucketCommand{srcTenant: "user-1", dstTenant: "user-1-copy", logger: log.NewNopLogger()}
+	require.NoError(t, cmd.copyRuleGroups(ctx, srcBkt, dstBkt))
+
+	dstStore := rulestore_bucketclient.NewBucketRuleStore(dstBkt, nil, 0, log.NewNopLogger())
+	users, err := dstStore.ListAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-1-copy"}, users)
+
+	for ns, group := range map[string]string{"ns-1": "group-1", "ns-2": "group-2"} {
+		rg, err := dstStore.GetRuleGroup(ctx, "user-1-copy", ns, group)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1-copy", rg.User)
+	}
+}
+
+func TestBucketCommand_copyAlertmanager(t *testing.T) {
+	ctx := context.Background()
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	srcStore := bucketclient.NewBucketAlertStore(srcBkt, nil, 0, log.NewNopLogger())
+	require.NoError(t, srcStore.SetAlertConfig(ctx, alertspb.AlertConfigDesc{User: "user-1", RawConfig: "config"}))
+	require.NoError(t, srcStore.SetFullState(ctx, "user-1", alertspb.FullStateDesc{State: &clusterpb.FullState{Parts: []clusterpb.Part{
+		{Key: "nfl:user-1", Data: []byte("nflog")},
+		{Key: "sil:user-1", Data: []byte("silences")},
+	}}}))
+
+	cmd := &BucketCommand{srcTenant: "user-1", dstTenant: "user-1-copy", logger: log.NewNopLogger()}
+	require.NoError(t, cmd.copyAlertmanager(ctx, srcBkt, dstBkt))
+
+	dstStore := bucketclient.NewBucketAlertStore(dstBkt, nil, 0, log.NewNopLogger())
+	cfg, err := dstStore.GetAlertConfig(ctx, "user-1-copy")
+	require.NoError(t, err)
+	assert.Equal(t, alertspb.AlertConfigDesc{User: "user-1-copy", RawConfig: "config"}, cfg)
+
+	fs, err := dstStore.GetFullState(ctx, "user-1-copy")
+	require.NoError(t, err)
+	assert.Equal(t, []clusterpb.Part{
+		{Key: "nfl:user-1-copy", Data: []byte("nflog")},
+		{Key: "sil:user-1-copy", Data: []byte("silences")},
+	}, fs.State.Parts)
+}
+
+func TestBucketCommand_copyAlertmanager_ShouldSucceedIfNothingToCopy(t *testing.T) {
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	cmd := &BucketCommand{srcTenant: "user-1", dstTenant: "user-1", logger: log.NewNopLogger()}
+	require.NoError(t, cmd.copyAlertmanager(context.Background(), srcBkt, dstBkt))
+}
@@ -171,14 +171,9 @@ mimirtool bucket-validation --bucket-config='-backend=s3 -s3.endpoint=localhost:
 }
 
 func (b *BucketValidationCommand) parseBucketConfig() error {
-	fs := flag.NewFlagSet("bucket-config", flag.ContinueOnError)
-	b.cfg.RegisterFlags(fs)
-	err := fs.Parse(strings.Split(b.bucketConfig, " "))
-	if err != nil {
-		return err
-	}
-
-	return b.cfg.Validate()
+	var err error
+	b.cfg, err = parseBucketConfig(b.bucketConfig)
+	return err
 }
 
 func (b *BucketValidationCommand) report(phase string, completed int) {
@@ -4,6 +4,8 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"io/fs"
@@ -11,11 +13,18 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/go-kit/log"
 	"github.com/grafana/dskit/multierror"
 	"github.com/pkg/errors"
 	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
 
+	"github.com/grafana/mimir/pkg/ingester"
 	"github.com/grafana/mimir/pkg/mimirtool/config"
+	"github.com/grafana/mimir/pkg/mimirtool/config/lint"
+	"github.com/grafana/mimir/pkg/querier"
+	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 // ConfigCommand works with the mimir configuration parameters (YAML files and CLI flags)
@@ -33,6 +42,9 @@ type ConfigCommand struct {
 	verbose bool
 
 	gem bool
+
+	runtimeConfigFile string
+	outputFormat      string
 }
 
 // Register rule related commands and flags with the kingpin application
@@ -51,6 +63,15 @@ func (c *ConfigCommand) Register(app *kingpin.Application, _ EnvVarNames) {
 	convertCmd.Flag("include-defaults", "If you set this flag, all default values are included in the output YAML, regardless of whether you explicitly set the values in the input files.").BoolVar(&c.includeDefaults)
 	convertCmd.Flag("verbose", "If you set this flag, the CLI flags and YAML paths from the old configuration that do not exist in the new configuration are printed to stderr. This flag also prints default values that have changed between the old and the new configuration.").Short('v').BoolVar(&c.verbose)
 	convertCmd.Flag("gem", "If you set this flag, the tool will convert from Grafana Metrics Enterprise (GEM) v1.7.x to v2.0.0.").BoolVar(&c.gem)
+
+	checkCmd := configCmd.
+		Command("check", "Check a Grafana Mimir configuration (YAML and CLI flags) and its runtime overrides against best practices, and report the findings. Fails if any finding has the error severity.").
+		Action(c.checkConfig)
+
+	checkCmd.Flag("yaml-file", "The YAML configuration file to check.").StringVar(&c.yamlFile)
+	checkCmd.Flag("flags-file", "Newline-delimited list of CLI flags to check.").StringVar(&c.flagsFile)
+	checkCmd.Flag("runtime-config-file", "The runtime configuration file, whose per-tenant overrides are checked too.").StringVar(&c.runtimeConfigFile)
+	checkCmd.Flag("output", "The output format of the findings. Supported values: text, json.").Default("text").EnumVar(&c.outputFormat, "text", "json")
 }
 
 func (c *ConfigCommand) convertConfig(_ *kingpin.ParseContext) error {
@@ -72,6 +93,136 @@ func (c *ConfigCommand) convertConfig(_ *kingpin.ParseContext) error {
 	return c.output(convertedYAML, flagsFlags, notices)
 }
 
+func (c *ConfigCommand) checkConfig(_ *kingpin.ParseContext) error {
+	yamlContents, flags, err := c.prepareInputs()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := parseLintedConfig(yamlContents, flags)
+	if err != nil {
+		return err
+	}
+
+	var tenantLimits map[string]*validation.Limits
+	if c.runtimeConfigFile != "" {
+		runtimeConfig, err := os.Open(c.runtimeConfigFile)
+		if err != nil {
+			return errors.Wrap(err, "could not read runtime-config-file")
+		}
+		defer runtimeConfig.Close()
+
+		// Unset limits in the overrides take the value of the default limits.
+		validation.SetDefaultLimitsForYAMLUnmarshalling(*cfg.Limits)
+		tenantLimits, err = parseRuntimeConfigTenantLimits(runtimeConfig)
+		if err != nil {
+			return errors.Wrap(err, "could not parse runtime-config-file")
+		}
+	}
+
+	findings := lint.Check(cfg, tenantLimits)
+	if err := writeConfigLintFindings(os.Stdout, findings, c.outputFormat); err != nil {
+		return err
+	}
+
+	errorsCount := 0
+	for _, f := range findings {
+		if f.Severity == lint.SeverityError {
+			errorsCount++
+		}
+	}
+	if errorsCount > 0 {
+		return fmt.Errorf("found %d configuration issues with error severity", errorsCount)
+	}
+	return nil
+}
+
+// lintedConfig is the part of the Grafana Mimir configuration checked by the lint rules. The other
+// top-level YAML blocks are ignored, so that mimirtool doesn't depend on the Grafana Mimir server.
+type lintedConfig struct {
+	Ingester      ingester.Config          `yaml:"ingester"`
+	Querier       querier.Config           `yaml:"querier"`
+	BlocksStorage tsdb.BlocksStorageConfig `yaml:"blocks_storage"`
+	LimitsConfig  validation.Limits        `yaml:"limits"`
+
+	Other map[string]interface{} `yaml:",inline"`
+}
+
+// parseLintedConfig parses the YAML configuration and the CLI flags as done by Grafana Mimir:
+// the defaults are overridden by the YAML configuration, which is in turn overridden by the CLI flags.
+// The CLI flags of the configuration blocks not checked by the lint rules are ignored.
+func parseLintedConfig(yamlContents []byte, flags []string) (lint.Config, error) {
+	cfg := &lintedConfig{}
+	fs := flag.NewFlagSet("mimir", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg.Ingester.RegisterFlags(fs, log.NewNopLogger())
+	cfg.Querier.RegisterFlags(fs)
+	cfg.BlocksStorage.RegisterFlags(fs)
+	cfg.LimitsConfig.RegisterFlags(fs)
+
+	if err := yaml.UnmarshalStrict(yamlContents, cfg); err != nil {
+		return lint.Config{}, errors.Wrap(err, "could not parse yaml-file")
+	}
+
+	var linted []string
+	for _, f := range flags {
+		name := strings.SplitN(strings.TrimLeft(f, "-"), "=", 2)[0]
+		if fs.Lookup(name) != nil {
+			linted = append(linted, f)
+		}
+	}
+	if err := fs.Parse(linted); err != nil {
+		return lint.Config{}, errors.Wrap(err, "could not parse flags-file")
+	}
+
+	return lint.Config{
+		Ingester:      &cfg.Ingester,
+		Querier:       &cfg.Querier,
+		BlocksStorage: &cfg.BlocksStorage,
+		Limits:        &cfg.LimitsConfig,
+	}, nil
+}
+
+// parseRuntimeConfigTenantLimits reads the per-tenant limit overrides from a runtime configuration file.
+// The default limits used for unset values must be set with validation.SetDefaultLimitsForYAMLUnmarshalling.
+func parseRuntimeConfigTenantLimits(r io.Reader) (map[string]*validation.Limits, error) {
+	var runtimeConfig struct {
+		TenantLimits map[string]*validation.Limits `yaml:"overrides"`
+	}
+	if err := yaml.NewDecoder(r).Decode(&runtimeConfig); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return runtimeConfig.TenantLimits, nil
+}
+
+func writeConfigLintFindings(w io.Writer, findings []lint.Finding, format string) error {
+	if format == "json" {
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	}
+
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No issues found.")
+		return err
+	}
+
+	out := bytes.Buffer{}
+	for _, f := range findings {
+		if f.Tenant != "" {
+			_, _ = fmt.Fprintf(&out, "[%s] %s (tenant %s): %s\n", f.Severity, f.Rule, f.Tenant, f.Message)
+		} else {
+			_, _ = fmt.Fprintf(&out, "[%s] %s: %s\n", f.Severity, f.Rule, f.Message)
+		}
+		_, _ = fmt.Fprintf(&out, "    parameters: %s\n", strings.Join(f.Parameters, ", "))
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
+
 func (c *ConfigCommand) prepareInputs() ([]byte, []string, error) {
 	var (
 		yamlContents []byte
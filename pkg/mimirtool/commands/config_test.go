@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirtool/config/lint"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestParseMimirConfig(t *testing.T) {
+	yamlContents := []byte(`
+ingester:
+  ring:
+    replication_factor: 1
+querier:
+  query_ingesters_within: 6h
+`)
+
+	cfg, err := parseLintedConfig(yamlContents, []string{"-querier.query-ingesters-within=8h", "-distributor.ha-tracker.enable-for-all-users"})
+	require.NoError(t, err)
+
+	// The CLI flags take precedence over the YAML config, which takes precedence over the defaults.
+	assert.Equal(t, 1, cfg.Ingester.IngesterRing.ReplicationFactor)
+	assert.Equal(t, 8*time.Hour, cfg.Querier.QueryIngestersWithin)
+	assert.Equal(t, 24*time.Hour, cfg.BlocksStorage.TSDB.Retention)
+
+	// The blocks not checked by the lint rules, and their CLI flags, are ignored.
+	_, err = parseLintedConfig([]byte("distributor:\n  remote_timeout: 5s"), []string{"-distributor.remote-timeout=5s"})
+	assert.NoError(t, err)
+
+	// Unknown fields in the checked blocks are reported.
+	_, err = parseLintedConfig([]byte("querier:\n  unknown_field: true"), nil)
+	assert.Error(t, err)
+}
+
+func TestParseRuntimeConfigTenantLimits(t *testing.T) {
+	cfg, err := parseLintedConfig([]byte("limits:\n  ingestion_rate: 100"), nil)
+	require.NoError(t, err)
+	validation.SetDefaultLimitsForYAMLUnmarshalling(*cfg.Limits)
+
+	tenantLimits, err := parseRuntimeConfigTenantLimits(strings.NewReader(`
+overrides:
+  user-1:
+    ingestion_burst_size: 50
+multi_kv_config:
+  primary: consul
+`))
+	require.NoError(t, err)
+	require.Contains(t, tenantLimits, "user-1")
+	assert.Equal(t, 50, tenantLimits["user-1"].IngestionBurstSize)
+	assert.Equal(t, 100.0, tenantLimits["user-1"].IngestionRate)
+
+	tenantLimits, err = parseRuntimeConfigTenantLimits(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Empty(t, tenantLimits)
+}
+
+func TestWriteConfigLintFindings(t *testing.T) {
+	findings := []lint.Finding{
+		{Rule: "rule-1", Severity: lint.SeverityError, Message: "first issue", Parameters: []string{"a.b", "c.d"}},
+		{Rule: "rule-2", Severity: lint.SeverityWarning, Message: "second issue", Parameters: []string{"overrides.user-1.e"}, Tenant: "user-1"},
+	}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, writeConfigLintFindings(out, findings, "text"))
+	assert.Equal(t, `[error] rule-1: first issue
+    parameters: a.b, c.d
+[warning] rule-2 (tenant user-1): second issue
+    parameters: overrides.user-1.e
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeConfigLintFindings(out, findings, "json"))
+	var decoded []lint.Finding
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, findings, decoded)
+
+	out.Reset()
+	require.NoError(t, writeConfigLintFindings(out, nil, "text"))
+	assert.Equal(t, "No issues found.\n", out.String())
+}
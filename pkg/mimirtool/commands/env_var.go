@@ -6,6 +6,7 @@ type EnvVarNames struct {
 	Address         string
 	APIKey          string
 	APIUser         string
+	Author          string
 	TLSCAPath       string
 	TLSCertPath     string
 	TLSKeyPath      string
@@ -18,6 +19,7 @@ func NewEnvVarsWithPrefix(prefix string) EnvVarNames {
 		address         = "ADDRESS"
 		apiKey          = "API_KEY"
 		apiUser         = "API_USER"
+		author          = "AUTHOR"
 		tenantID        = "TENANT_ID"
 		tlsCAPath       = "TLS_CA_PATH"
 		tlsCertPath     = "TLS_CERT_PATH"
@@ -33,6 +35,7 @@ func NewEnvVarsWithPrefix(prefix string) EnvVarNames {
 		Address:         prefix + address,
 		APIKey:          prefix + apiKey,
 		APIUser:         prefix + apiUser,
+		Author:          prefix + author,
 		TLSCAPath:       prefix + tlsCAPath,
 		TLSCertPath:     prefix + tlsCertPath,
 		TLSKeyPath:      prefix + tlsKeyPath,
@@ -7,12 +7,17 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
@@ -29,6 +34,9 @@ import (
 	"github.com/prometheus/prometheus/storage/remote"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/grafana/mimir/pkg/mimirtool/analyze"
+	"github.com/grafana/mimir/pkg/mimirtool/loadgen"
 )
 
 var (
@@ -49,6 +57,18 @@ var queryRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Buckets:   defBuckets,
 }, []string{"success"})
 
+var queryClassRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "loadgen",
+	Name:      "query_class_request_duration_seconds",
+	Help:      "Duration of the queries run by the workload profile, by query class.",
+	Buckets:   defBuckets,
+}, []string{"class", "success"})
+
+const (
+	writeClass = "write"
+	queryClass = "query"
+)
+
 type LoadgenCommand struct {
 	writeURL       string
 	activeSeries   int
@@ -64,12 +84,24 @@ type LoadgenCommand struct {
 	queryTimeout     time.Duration
 	queryDuration    time.Duration
 
+	profileFile         string
+	profileMetricsFile  string
+	profileQueryLogFile string
+	profileOutput       string
+	profileSeriesScale  float64
+
+	duration     time.Duration
+	reportOutput string
+
 	metricsListenAddress string
 
 	// Runtime stuff.
-	wg          sync.WaitGroup
-	writeClient remote.WriteClient
-	queryClient v1.API
+	wg             sync.WaitGroup
+	writeClient    remote.WriteClient
+	queryClient    v1.API
+	seriesLabels   func(idx int, now time.Time) []prompb.Label
+	queryGenerator *loadgen.QueryGenerator
+	recorder       *loadgen.LatencyRecorder
 }
 
 func (c *LoadgenCommand) Register(app *kingpin.Application, _ EnvVarNames) {
@@ -101,15 +133,115 @@ func (c *LoadgenCommand) Register(app *kingpin.Application, _ EnvVarNames) {
 	cmd.Flag("query-duration", "length of query").
 		Default("1h").DurationVar(&loadgenCommand.queryDuration)
 
+	cmd.Flag("profile", "workload profile file describing the metrics, churn and query mix to replay. When set, the series-name, active-series and query flags are ignored for the parts of the workload described by the profile.").
+		Default("").StringVar(&loadgenCommand.profileFile)
+	cmd.Flag("profile-metrics-file", "build the metrics of the workload profile from the output file of the analyze prometheus command").
+		Default("").StringVar(&loadgenCommand.profileMetricsFile)
+	cmd.Flag("profile-query-log-file", "build the query mix of the workload profile from a log file containing the query-frontend query stats log lines").
+		Default("").StringVar(&loadgenCommand.profileQueryLogFile)
+	cmd.Flag("profile-output", "write the workload profile to this file and exit, without generating any load").
+		Default("").StringVar(&loadgenCommand.profileOutput)
+	cmd.Flag("profile-series-scale", "multiplier applied to the number of series of each metric of the workload profile").
+		Default("1").Float64Var(&loadgenCommand.profileSeriesScale)
+
+	cmd.Flag("duration", "how long to generate load for, after which a summary report is printed; 0 to run until interrupted").
+		Default("0s").DurationVar(&loadgenCommand.duration)
+	cmd.Flag("report-output", "write the summary report to this file in JSON format").
+		Default("").StringVar(&loadgenCommand.reportOutput)
+
 	cmd.Flag("metrics-listen-address", "address to serve metrics on").
 		Default(":8080").StringVar(&loadgenCommand.metricsListenAddress)
 }
 
+// loadProfile builds the workload profile from the profile flags.
+func (c *LoadgenCommand) loadProfile() (loadgen.Profile, bool, error) {
+	if c.profileFile == "" && c.profileMetricsFile == "" && c.profileQueryLogFile == "" {
+		return loadgen.Profile{}, false, nil
+	}
+
+	var profile loadgen.Profile
+	if c.profileFile != "" {
+		var err error
+		if profile, err = loadgen.LoadProfile(c.profileFile); err != nil {
+			return loadgen.Profile{}, false, err
+		}
+	}
+
+	if c.profileMetricsFile != "" {
+		data, err := ioutil.ReadFile(c.profileMetricsFile)
+		if err != nil {
+			return loadgen.Profile{}, false, errors.Wrap(err, "failed to read the analyze prometheus output file")
+		}
+
+		var metrics analyze.MetricsInPrometheus
+		if err := json.Unmarshal(data, &metrics); err != nil {
+			return loadgen.Profile{}, false, errors.Wrap(err, "failed to decode the analyze prometheus output file")
+		}
+		profile.Metrics = loadgen.MetricsFromAnalyze(metrics)
+	}
+
+	if c.profileQueryLogFile != "" {
+		f, err := os.Open(c.profileQueryLogFile)
+		if err != nil {
+			return loadgen.Profile{}, false, errors.Wrap(err, "failed to open the query log file")
+		}
+		defer f.Close()
+
+		if profile.Queries, err = loadgen.QueriesFromQueryLog(f); err != nil {
+			return loadgen.Profile{}, false, err
+		}
+	}
+
+	if err := profile.Validate(); err != nil {
+		return loadgen.Profile{}, false, errors.Wrap(err, "invalid workload profile")
+	}
+	return profile, true, nil
+}
+
 func (c *LoadgenCommand) run(k *kingpin.ParseContext) error {
+	profile, useProfile, err := c.loadProfile()
+	if err != nil {
+		return err
+	}
+	if c.profileOutput != "" {
+		if !useProfile {
+			return errors.New("the -profile-output flag requires a workload profile")
+		}
+		return loadgen.WriteProfile(c.profileOutput, profile)
+	}
+
 	if c.writeURL == "" && c.queryURL == "" {
 		return errors.New("either a -write-url or -query-url flag must be provided to run the loadgen command")
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if c.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.duration)
+		defer cancel()
+	}
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	c.recorder = loadgen.NewLatencyRecorder()
+	c.seriesLabels = c.defaultSeriesLabels
+	c.queryGenerator = loadgen.NewQueryGenerator(nil)
+	if useProfile && len(profile.Metrics) > 0 {
+		series := loadgen.NewSeriesGenerator(profile, c.profileSeriesScale, time.Now())
+		c.activeSeries = series.ActiveSeries()
+		c.seriesLabels = series.Series
+	}
+	if useProfile && len(profile.Queries) > 0 {
+		c.queryGenerator = loadgen.NewQueryGenerator(profile.Queries)
+	}
+
 	http.Handle("/metrics", promhttp.Handler())
 	go func() {
 		err := http.ListenAndServe(c.metricsListenAddress, nil)
@@ -136,9 +268,9 @@ func (c *LoadgenCommand) run(k *kingpin.ParseContext) error {
 
 		c.wg.Add(c.parallelism)
 
-		metricsPerShard := c.activeSeries / c.parallelism
-		for i := 0; i < c.activeSeries; i += metricsPerShard {
-			go c.runWriteShard(i, i+metricsPerShard)
+		metricsPerShard := (c.activeSeries + c.parallelism - 1) / c.parallelism
+		for shard := 0; shard < c.parallelism; shard++ {
+			go c.runWriteShard(ctx, shard*metricsPerShard, (shard+1)*metricsPerShard)
 		}
 	} else {
 		log.Println("write load generation is disabled, -write-url flag has not been set")
@@ -157,28 +289,61 @@ func (c *LoadgenCommand) run(k *kingpin.ParseContext) error {
 		c.wg.Add(c.queryParallelism)
 
 		for i := 0; i < c.queryParallelism; i++ {
-			go c.runQueryShard()
+			go c.runQueryShard(ctx)
 		}
 	} else {
 		log.Println("query load generation is disabled, -query-url flag has not been set")
 	}
 
+	start := time.Now()
 	c.wg.Wait()
+
+	report := c.recorder.Report(time.Since(start))
+	if err := report.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	if c.reportOutput != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(c.reportOutput, data, 0644); err != nil {
+			return errors.Wrap(err, "failed to write the summary report")
+		}
+	}
 	return nil
 }
 
-func (c *LoadgenCommand) runWriteShard(from, to int) {
+func (c *LoadgenCommand) runWriteShard(ctx context.Context, from, to int) {
 	defer c.wg.Done()
 	ticker := time.NewTicker(c.scrapeInterval)
+	defer ticker.Stop()
+
 	c.runScrape(from, to)
-	for range ticker.C {
-		c.runScrape(from, to)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.runScrape(from, to)
+		}
 	}
 }
 
 func (c *LoadgenCommand) runScrape(from, to int) {
+	if to > c.activeSeries {
+		to = c.activeSeries
+	}
+	if from >= to {
+		return
+	}
+
 	for i := from; i < to; i += c.batchSize {
-		if err := c.runBatch(i, i+c.batchSize); err != nil {
+		batchTo := i + c.batchSize
+		if batchTo > to {
+			batchTo = to
+		}
+		if err := c.runBatch(i, batchTo); err != nil {
 			log.Printf("error sending batch: %v", err)
 		}
 	}
@@ -195,13 +360,7 @@ func (c *LoadgenCommand) runBatch(from, to int) error {
 
 	for i := from; i < to; i++ {
 		timeseries := prompb.TimeSeries{
-			Labels: []prompb.Label{
-				{Name: "__name__", Value: c.metricName},
-				{Name: "job", Value: "node_exporter"},
-				{Name: "instance", Value: fmt.Sprintf("instance%000d", i)},
-				{Name: "cpu", Value: "0"},
-				{Name: "mode", Value: "idle"},
-			},
+			Labels: c.seriesLabels(i, time.Now()),
 			Samples: []prompb.Sample{{
 				Timestamp: now,
 				Value:     rand.Float64(),
@@ -218,7 +377,9 @@ func (c *LoadgenCommand) runBatch(from, to int) error {
 	compressed := snappy.Encode(nil, data)
 
 	start := time.Now()
-	if err := c.writeClient.Store(context.Background(), compressed); err != nil {
+	err = c.writeClient.Store(context.Background(), compressed)
+	c.recorder.Observe(writeClass, time.Since(start), err)
+	if err != nil {
 		writeRequestDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
 		return err
 	}
@@ -227,15 +388,29 @@ func (c *LoadgenCommand) runBatch(from, to int) error {
 	return nil
 }
 
-func (c *LoadgenCommand) runQueryShard() {
+func (c *LoadgenCommand) defaultSeriesLabels(i int, _ time.Time) []prompb.Label {
+	return []prompb.Label{
+		{Name: "__name__", Value: c.metricName},
+		{Name: "job", Value: "node_exporter"},
+		{Name: "instance", Value: fmt.Sprintf("instance%000d", i)},
+		{Name: "cpu", Value: "0"},
+		{Name: "mode", Value: "idle"},
+	}
+}
+
+func (c *LoadgenCommand) runQueryShard(ctx context.Context) {
 	defer c.wg.Done()
-	for {
-		c.runQuery()
+	for ctx.Err() == nil {
+		if c.queryGenerator.Empty() {
+			c.runQuery(ctx)
+		} else {
+			c.runProfileQuery(ctx)
+		}
 	}
 }
 
-func (c *LoadgenCommand) runQuery() {
-	ctx, cancel := context.WithTimeout(context.Background(), c.queryTimeout)
+func (c *LoadgenCommand) runQuery(ctx context.Context) {
+	queryCtx, cancel := context.WithTimeout(ctx, c.queryTimeout)
 	defer cancel()
 	r := v1.Range{
 		Start: time.Now().Add(-c.queryDuration),
@@ -243,7 +418,8 @@ func (c *LoadgenCommand) runQuery() {
 		Step:  time.Minute,
 	}
 	start := time.Now()
-	_, _, err := c.queryClient.QueryRange(ctx, c.query, r)
+	_, _, err := c.queryClient.QueryRange(queryCtx, c.query, r)
+	c.recordQuery(ctx, queryClass, start, err)
 	if err != nil {
 		queryRequestDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
 		log.Printf("error doing query: %v", err)
@@ -251,3 +427,36 @@ func (c *LoadgenCommand) runQuery() {
 	}
 	queryRequestDuration.WithLabelValues("success").Observe(time.Since(start).Seconds())
 }
+
+func (c *LoadgenCommand) runProfileQuery(ctx context.Context) {
+	queryCtx, cancel := context.WithTimeout(ctx, c.queryTimeout)
+	defer cancel()
+
+	q := c.queryGenerator.Next(time.Now())
+	start := time.Now()
+
+	var err error
+	if q.Type == loadgen.QueryTypeRange {
+		_, _, err = c.queryClient.QueryRange(queryCtx, q.Expr, v1.Range{Start: q.Start, End: q.End, Step: q.Step})
+	} else {
+		_, _, err = c.queryClient.Query(queryCtx, q.Expr, q.Time)
+	}
+
+	c.recordQuery(ctx, q.Class, start, err)
+	success := "success"
+	if err != nil {
+		success = "error"
+		log.Printf("error doing query: class=%s query=%s err=%v", q.Class, q.Expr, err)
+	}
+	queryRequestDuration.WithLabelValues(success).Observe(time.Since(start).Seconds())
+	queryClassRequestDuration.WithLabelValues(q.Class, success).Observe(time.Since(start).Seconds())
+}
+
+// recordQuery records the query latency in the summary report. Queries canceled
+// because the load generation is ending are not recorded.
+func (c *LoadgenCommand) recordQuery(ctx context.Context, class string, start time.Time, err error) {
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	c.recorder.Observe(class, time.Since(start), err)
+}
@@ -12,8 +12,11 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 
+	gokitlog "github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/model/rulefmt"
@@ -25,6 +28,8 @@ import (
 	"github.com/grafana/mimir/pkg/mimirtool/printer"
 	"github.com/grafana/mimir/pkg/mimirtool/rules"
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+	"github.com/grafana/mimir/pkg/mimirtool/rules/unittest"
+	"github.com/grafana/mimir/pkg/querier/engine"
 )
 
 const (
@@ -60,6 +65,11 @@ type RuleCommand struct {
 	Namespace string
 	RuleGroup string
 
+	// Rule Group Versions Configs
+	Version     int
+	FromVersion int
+	ToVersion   int
+
 	// Load Rules Config
 	RuleFilesList []string
 	RuleFiles     string
@@ -83,6 +93,11 @@ type RuleCommand struct {
 	// Rules check flags
 	Strict bool
 
+	// Rules test flags
+	TestFiles        []string
+	TestJUnitOutput  string
+	TestEngineConfig engine.Config
+
 	// List Rules Config
 	Format string
 
@@ -97,6 +112,7 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames) {
 	rulesCmd := app.Command("rules", "View and edit rules stored in Grafan Mimir.").PreAction(r.setup)
 	rulesCmd.Flag("user", fmt.Sprintf("API user to use when contacting Grafana Mimir; alternatively, set %s. If empty, %s is used instead.", envVars.APIUser, envVars.TenantID)).Default("").Envar(envVars.APIUser).StringVar(&r.ClientConfig.User)
 	rulesCmd.Flag("key", "API key to use when contacting Grafana Mimir; alternatively, set "+envVars.APIKey+".").Default("").Envar(envVars.APIKey).StringVar(&r.ClientConfig.Key)
+	rulesCmd.Flag("author", "Author of the changes, recorded in the history of the rule groups; alternatively, set "+envVars.Author+".").Default("").Envar(envVars.Author).StringVar(&r.ClientConfig.Author)
 	rulesCmd.Flag("backend", "Backend type to interact with (deprecated)").Default(rules.MimirBackend).EnumVar(&r.Backend, backends...)
 
 	// Register rule commands
@@ -112,6 +128,15 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames) {
 	deleteRuleGroupCmd := rulesCmd.
 		Command("delete", "Delete a rulegroup from the ruler.").
 		Action(r.deleteRuleGroup)
+	listVersionsCmd := rulesCmd.
+		Command("versions", "List the versions in the history of a rulegroup.").
+		Action(r.listRuleGroupVersions)
+	diffVersionsCmd := rulesCmd.
+		Command("diff-versions", "Diff two versions in the history of a rulegroup.").
+		Action(r.diffRuleGroupVersions)
+	rollbackCmd := rulesCmd.
+		Command("rollback", "Restore a previous version of a rulegroup.").
+		Action(r.rollbackRuleGroup)
 	loadRulesCmd := rulesCmd.
 		Command("load", "Load a set of rules to a designated Grafana Mimir endpoint.").
 		Action(r.loadRules)
@@ -130,9 +155,12 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames) {
 	checkCmd := rulesCmd.
 		Command("check", "Run various best practice checks against rules.").
 		Action(r.checkRecordingRuleNames)
+	testCmd := rulesCmd.
+		Command("test", "Run unit tests for rules, using the same test file format as promtool. Rules are evaluated with the Grafana Mimir PromQL engine and ruler semantics.").
+		Action(r.testRules)
 
 	// Require Mimir cluster address and tentant ID on all these commands
-	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd} {
+	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, listVersionsCmd, diffVersionsCmd, rollbackCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd} {
 		c.Flag("address", "Address of the Grafana Mimir cluster; alternatively, set "+envVars.Address+".").
 			Envar(envVars.Address).
 			Required().
@@ -177,6 +205,22 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames) {
 	deleteRuleGroupCmd.Arg("namespace", "Namespace of the rulegroup to delete.").Required().StringVar(&r.Namespace)
 	deleteRuleGroupCmd.Arg("group", "Name of the rulegroup ot delete.").Required().StringVar(&r.RuleGroup)
 
+	// Versions Command
+	listVersionsCmd.Arg("namespace", "Namespace of the rulegroup.").Required().StringVar(&r.Namespace)
+	listVersionsCmd.Arg("group", "Name of the rulegroup.").Required().StringVar(&r.RuleGroup)
+
+	// Diff Versions Command
+	diffVersionsCmd.Arg("namespace", "Namespace of the rulegroup.").Required().StringVar(&r.Namespace)
+	diffVersionsCmd.Arg("group", "Name of the rulegroup.").Required().StringVar(&r.RuleGroup)
+	diffVersionsCmd.Arg("from", "Version to diff from.").Required().IntVar(&r.FromVersion)
+	diffVersionsCmd.Arg("to", "Version to diff to.").Required().IntVar(&r.ToVersion)
+	diffVersionsCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
+
+	// Rollback Command
+	rollbackCmd.Arg("namespace", "Namespace of the rulegroup to roll back.").Required().StringVar(&r.Namespace)
+	rollbackCmd.Arg("group", "Name of the rulegroup to roll back.").Required().StringVar(&r.RuleGroup)
+	rollbackCmd.Arg("version", "Version to restore.").Required().IntVar(&r.Version)
+
 	// Load Rules Command
 	loadRulesCmd.Arg("rule-files", "The rule files to check.").Required().ExistingFilesVar(&r.RuleFilesList)
 
@@ -234,6 +278,13 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames) {
 	).StringVar(&r.RuleFilesPath)
 	checkCmd.Flag("strict", "fails rules checks that do not match best practices exactly").BoolVar(&r.Strict)
 
+	// Test Command
+	flagext.DefaultValues(&r.TestEngineConfig)
+	testCmd.Arg("test-files", "The unit test files to run.").Required().ExistingFilesVar(&r.TestFiles)
+	testCmd.Flag("junit-output", "File to write the test results to, in JUnit XML format.").StringVar(&r.TestJUnitOutput)
+	testCmd.Flag("lookback-delta", "Time since the last sample after which a time series is considered stale and ignored by expression evaluations.").Default(r.TestEngineConfig.LookbackDelta.String()).DurationVar(&r.TestEngineConfig.LookbackDelta)
+	testCmd.Flag("max-samples", "Maximum number of samples a single query can load into memory.").Default(strconv.Itoa(r.TestEngineConfig.MaxSamples)).IntVar(&r.TestEngineConfig.MaxSamples)
+
 	// List Command
 	listCmd.Flag("format", "Backend type to interact with: <json|yaml|table>").Default("table").EnumVar(&r.Format, formats...)
 	listCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
@@ -376,6 +427,43 @@ func (r *RuleCommand) deleteRuleGroup(k *kingpin.ParseContext) error {
 	return nil
 }
 
+func (r *RuleCommand) listRuleGroupVersions(k *kingpin.ParseContext) error {
+	versions, err := r.cli.ListRuleGroupVersions(context.Background(), r.Namespace, r.RuleGroup)
+	if err != nil {
+		log.Fatalf("Unable to read rule group versions from Grafana Mimir, %v", err)
+	}
+
+	p := printer.New(r.DisableColor)
+	p.PrintVersions(versions, os.Stdout)
+	return nil
+}
+
+func (r *RuleCommand) diffRuleGroupVersions(k *kingpin.ParseContext) error {
+	diff, err := r.cli.DiffRuleGroupVersions(context.Background(), r.Namespace, r.RuleGroup, r.FromVersion, r.ToVersion)
+	if err != nil {
+		if err == client.ErrResourceNotFound {
+			log.Infof("this rule group version does not exist")
+			return nil
+		}
+		log.Fatalf("Unable to diff rule group versions from Grafana Mimir, %v", err)
+	}
+
+	p := printer.New(r.DisableColor)
+	return p.PrintVersionsDiff(diff, os.Stdout)
+}
+
+func (r *RuleCommand) rollbackRuleGroup(k *kingpin.ParseContext) error {
+	err := r.cli.RollbackRuleGroup(context.Background(), r.Namespace, r.RuleGroup, r.Version)
+	if err != nil {
+		if err == client.ErrResourceNotFound {
+			log.Infof("this rule group version does not exist")
+			return nil
+		}
+		log.Fatalf("Unable to roll back rule group in Grafana Mimir, %v", err)
+	}
+	return nil
+}
+
 func (r *RuleCommand) loadRules(k *kingpin.ParseContext) error {
 	nss, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
 	if err != nil {
@@ -722,6 +810,56 @@ func (r *RuleCommand) checkRecordingRuleNames(k *kingpin.ParseContext) error {
 	return nil
 }
 
+func (r *RuleCommand) testRules(k *kingpin.ParseContext) error {
+	runner := unittest.NewRunner(r.TestEngineConfig, gokitlog.NewNopLogger())
+
+	var results []unittest.FileResult
+	failed := false
+	for _, f := range r.TestFiles {
+		fmt.Println("Unit Testing: ", f)
+
+		res := runner.RunFile(f)
+		results = append(results, res)
+
+		if res.Err != nil {
+			fmt.Printf("  FAILED:\n    %s\n\n", res.Err)
+			failed = true
+			continue
+		}
+		if !res.Failed() {
+			fmt.Println("  SUCCESS")
+			fmt.Println()
+			continue
+		}
+
+		failed = true
+		fmt.Println("  FAILED:")
+		for _, g := range res.Groups {
+			for _, err := range g.Errs {
+				fmt.Printf("  %s:\n%s\n", g.Name, err)
+			}
+		}
+		fmt.Println()
+	}
+
+	if r.TestJUnitOutput != "" {
+		f, err := os.Create(r.TestJUnitOutput)
+		if err != nil {
+			return errors.Wrap(err, "unable to create the JUnit output file")
+		}
+		defer f.Close()
+
+		if err := unittest.WriteJUnit(f, results); err != nil {
+			return errors.Wrap(err, "unable to write the JUnit output file")
+		}
+	}
+
+	if failed {
+		return errors.New("rules unit tests failed")
+	}
+	return nil
+}
+
 // Taken from https://github.com/prometheus/prometheus/blob/8c8de46003d1800c9d40121b4a5e5de8582ef6e1/cmd/promtool/main.go#L403
 type compareRuleType struct {
 	metric string
@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+// Package lint checks a Grafana Mimir configuration against the best practices. It's used both by
+// mimirtool and by Grafana Mimir, so it must not depend on the Grafana Mimir server package.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/ingester"
+	"github.com/grafana/mimir/pkg/querier"
+	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// Config is the part of the Grafana Mimir configuration checked by the rules.
+type Config struct {
+	Ingester      *ingester.Config
+	Querier       *querier.Config
+	BlocksStorage *tsdb.BlocksStorageConfig
+	Limits        *validation.Limits
+}
+
+// Severity is the severity of a Finding.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Finding is a configuration which doesn't follow the best practices, as reported by Check.
+type Finding struct {
+	Rule       string   `yaml:"rule" json:"rule"`
+	Severity   Severity `yaml:"severity" json:"severity"`
+	Message    string   `yaml:"message" json:"message"`
+	Parameters []string `yaml:"parameters" json:"parameters"`
+
+	// Tenant is set when the finding is about the runtime overrides of a tenant.
+	Tenant string `yaml:"tenant,omitempty" json:"tenant,omitempty"`
+}
+
+// configRule checks the configuration. The check returns the severity and description
+// of the issue, or an empty description if the configuration follows the best practices.
+type configRule struct {
+	name       string
+	parameters []string
+	check      func(cfg Config) (Severity, string)
+}
+
+// limitsRule checks the default limits and the limits of each tenant with runtime overrides.
+// The parameters are relative to the limits block.
+type limitsRule struct {
+	name       string
+	parameters []string
+	check      func(cfg Config, limits *validation.Limits) (Severity, string)
+}
+
+var configRules = []configRule{
+	{
+		name:       "ingester-replication-factor",
+		parameters: []string{"ingester.ring.replication_factor"},
+		check: func(cfg Config) (Severity, string) {
+			switch rf := cfg.Ingester.IngesterRing.ReplicationFactor; {
+			case rf == 1:
+				return SeverityError, "series are not replicated: a single ingester restart or failure causes write failures and the loss of the samples not yet shipped to the storage"
+			case rf == 2:
+				return SeverityWarning, fmt.Sprintf("with a replication factor of %d, writes fail as soon as a single ingester is unavailable, because the quorum requires all replicas", rf)
+			}
+			return "", ""
+		},
+	}, {
+		name:       "query-ingesters-within-retention",
+		parameters: []string{"querier.query_ingesters_within", "blocks_storage.tsdb.retention_period"},
+		check: func(cfg Config) (Severity, string) {
+			within, retention := cfg.Querier.QueryIngestersWithin, cfg.BlocksStorage.TSDB.Retention
+			if within > 0 && retention < within {
+				return SeverityError, fmt.Sprintf("ingesters keep blocks for %s, less than the %s queriers expect them to have: queries may miss samples not yet available in the store-gateways", retention, within)
+			}
+			return "", ""
+		},
+	}, {
+		name:       "query-ingesters-within-block-range",
+		parameters: []string{"querier.query_ingesters_within", "blocks_storage.tsdb.block_ranges_period", "blocks_storage.bucket_store.sync_interval"},
+		check: func(cfg Config) (Severity, string) {
+			within, available := cfg.Querier.QueryIngestersWithin, timeToStoreGateways(cfg)
+			if within > 0 && within < available {
+				return SeverityError, fmt.Sprintf("samples may take up to %s (block range plus store-gateway sync interval) to be queryable from the store-gateways, but queriers only query ingesters for the last %s", available, within)
+			}
+			return "", ""
+		},
+	}, {
+		name:       "query-store-after-block-range",
+		parameters: []string{"querier.query_store_after", "blocks_storage.tsdb.block_ranges_period"},
+		check: func(cfg Config) (Severity, string) {
+			if len(cfg.BlocksStorage.TSDB.BlockRanges) == 0 {
+				return "", ""
+			}
+			after, blockRange := cfg.Querier.QueryStoreAfter, cfg.BlocksStorage.TSDB.BlockRanges[0]
+			if after > 0 && after < blockRange {
+				return SeverityInfo, fmt.Sprintf("queriers query the store-gateways for samples more recent than the %s block range, which have not been shipped by ingesters yet", blockRange)
+			}
+			return "", ""
+		},
+	}, {
+		name:       "ignore-blocks-within-query-ingesters-within",
+		parameters: []string{"blocks_storage.bucket_store.ignore_blocks_within", "querier.query_ingesters_within"},
+		check: func(cfg Config) (Severity, string) {
+			ignore, within := cfg.BlocksStorage.BucketStore.IgnoreBlocksWithin, cfg.Querier.QueryIngestersWithin
+			if ignore > 0 && within > 0 && ignore >= within {
+				return SeverityError, fmt.Sprintf("store-gateways don't load blocks of the last %s, but queriers only query ingesters for the last %s: queries may miss samples in between", ignore, within)
+			}
+			return "", ""
+		},
+	}, {
+		name:       "chunks-cache-max-item-size",
+		parameters: []string{"blocks_storage.bucket_store.chunks_cache.memcached.max_item_size", "blocks_storage.bucket_store.chunks_cache.subrange_size"},
+		check: func(cfg Config) (Severity, string) {
+			chunksCache := cfg.BlocksStorage.BucketStore.ChunksCache
+			if chunksCache.Backend != cache.BackendMemcached || chunksCache.Memcached.MaxItemSize <= 0 {
+				return "", ""
+			}
+			if int64(chunksCache.Memcached.MaxItemSize) < chunksCache.SubrangeSize {
+				return SeverityError, fmt.Sprintf("the memcached max item size (%d bytes) is smaller than the chunks subrange size (%d bytes), so chunks are never cached", chunksCache.Memcached.MaxItemSize, chunksCache.SubrangeSize)
+			}
+			return "", ""
+		},
+	},
+}
+
+var limitsRules = []limitsRule{
+	{
+		name:       "ingestion-burst-size",
+		parameters: []string{"ingestion_burst_size", "ingestion_rate"},
+		check: func(_ Config, limits *validation.Limits) (Severity, string) {
+			if float64(limits.IngestionBurstSize) < limits.IngestionRate {
+				return SeverityWarning, fmt.Sprintf("the ingestion burst size (%d samples) is lower than the ingestion rate (%g samples/s), so the rate limit can't be reached by a single push", limits.IngestionBurstSize, limits.IngestionRate)
+			}
+			return "", ""
+		},
+	}, {
+		name:       "compactor-blocks-retention-query-lookback",
+		parameters: []string{"compactor_blocks_retention_period", "max_query_lookback"},
+		check: func(_ Config, limits *validation.Limits) (Severity, string) {
+			retention, lookback := time.Duration(limits.CompactorBlocksRetentionPeriod), time.Duration(limits.MaxQueryLookback)
+			if retention > 0 && lookback > retention {
+				return SeverityWarning, fmt.Sprintf("blocks are deleted after %s, but queries can look back up to %s and return partial results", retention, lookback)
+			}
+			return "", ""
+		},
+	},
+}
+
+// timeToStoreGateways returns the maximum time it takes for a sample to be queryable from the store-gateways.
+func timeToStoreGateways(cfg Config) time.Duration {
+	if len(cfg.BlocksStorage.TSDB.BlockRanges) == 0 {
+		return 0
+	}
+	return cfg.BlocksStorage.TSDB.BlockRanges[0] + cfg.BlocksStorage.BucketStore.SyncInterval
+}
+
+// Check checks the configuration, and the runtime overrides of each tenant in tenantLimits,
+// against the best practices and returns the findings.
+func Check(cfg Config, tenantLimits map[string]*validation.Limits) []Finding {
+	var findings []Finding
+
+	for _, rule := range configRules {
+		if severity, message := rule.check(cfg); message != "" {
+			findings = append(findings, Finding{Rule: rule.name, Severity: severity, Message: message, Parameters: rule.parameters})
+		}
+	}
+
+	findings = append(findings, checkLimits(cfg, cfg.Limits, "")...)
+
+	tenants := make([]string, 0, len(tenantLimits))
+	for tenant, limits := range tenantLimits {
+		if limits != nil {
+			tenants = append(tenants, tenant)
+		}
+	}
+	sort.Strings(tenants)
+
+	for _, tenant := range tenants {
+		findings = append(findings, checkLimits(cfg, tenantLimits[tenant], tenant)...)
+	}
+
+	return findings
+}
+
+func checkLimits(cfg Config, limits *validation.Limits, tenant string) []Finding {
+	prefix := "limits."
+	if tenant != "" {
+		prefix = "overrides." + tenant + "."
+	}
+
+	var findings []Finding
+	for _, rule := range limitsRules {
+		severity, message := rule.check(cfg, limits)
+		if message == "" {
+			continue
+		}
+
+		parameters := make([]string, 0, len(rule.parameters))
+		for _, p := range rule.parameters {
+			parameters = append(parameters, prefix+p)
+		}
+		findings = append(findings, Finding{Rule: rule.name, Severity: severity, Message: message, Parameters: parameters, Tenant: tenant})
+	}
+	return findings
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package lint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/ingester"
+	"github.com/grafana/mimir/pkg/querier"
+	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestCheck(t *testing.T) {
+	tests := map[string]struct {
+		setup        func(cfg Config)
+		tenantLimits map[string]*validation.Limits
+		expected     []Finding
+	}{
+		"default config": {
+			setup: func(cfg Config) {},
+		},
+		"ingester replication factor 1": {
+			setup: func(cfg Config) { cfg.Ingester.IngesterRing.ReplicationFactor = 1 },
+			expected: []Finding{
+				{Rule: "ingester-replication-factor", Severity: SeverityError, Parameters: []string{"ingester.ring.replication_factor"}},
+			},
+		},
+		"ingester replication factor 2": {
+			setup: func(cfg Config) { cfg.Ingester.IngesterRing.ReplicationFactor = 2 },
+			expected: []Finding{
+				{Rule: "ingester-replication-factor", Severity: SeverityWarning, Parameters: []string{"ingester.ring.replication_factor"}},
+			},
+		},
+		"ingesters retention shorter than query ingesters within": {
+			setup: func(cfg Config) { cfg.BlocksStorage.TSDB.Retention = 6 * time.Hour },
+			expected: []Finding{
+				{Rule: "query-ingesters-within-retention", Severity: SeverityError, Parameters: []string{"querier.query_ingesters_within", "blocks_storage.tsdb.retention_period"}},
+			},
+		},
+		"query ingesters within shorter than the time blocks take to be queryable from store-gateways": {
+			setup: func(cfg Config) { cfg.Querier.QueryIngestersWithin = 2 * time.Hour },
+			expected: []Finding{
+				{Rule: "query-ingesters-within-block-range", Severity: SeverityError, Parameters: []string{"querier.query_ingesters_within", "blocks_storage.tsdb.block_ranges_period", "blocks_storage.bucket_store.sync_interval"}},
+			},
+		},
+		"query store after shorter than the block range": {
+			setup: func(cfg Config) { cfg.Querier.QueryStoreAfter = time.Hour },
+			expected: []Finding{
+				{Rule: "query-store-after-block-range", Severity: SeverityInfo, Parameters: []string{"querier.query_store_after", "blocks_storage.tsdb.block_ranges_period"}},
+			},
+		},
+		"store-gateways ignoring blocks queried only from them": {
+			setup: func(cfg Config) { cfg.BlocksStorage.BucketStore.IgnoreBlocksWithin = 24 * time.Hour },
+			expected: []Finding{
+				{Rule: "ignore-blocks-within-query-ingesters-within", Severity: SeverityError, Parameters: []string{"blocks_storage.bucket_store.ignore_blocks_within", "querier.query_ingesters_within"}},
+			},
+		},
+		"chunks cache max item size smaller than the subrange size": {
+			setup: func(cfg Config) {
+				cfg.BlocksStorage.BucketStore.ChunksCache.Backend = cache.BackendMemcached
+				cfg.BlocksStorage.BucketStore.ChunksCache.Memcached.MaxItemSize = 8000
+			},
+			expected: []Finding{
+				{Rule: "chunks-cache-max-item-size", Severity: SeverityError, Parameters: []string{"blocks_storage.bucket_store.chunks_cache.memcached.max_item_size", "blocks_storage.bucket_store.chunks_cache.subrange_size"}},
+			},
+		},
+		"risky default limits and tenant overrides": {
+			setup: func(cfg Config) {
+				cfg.Limits.CompactorBlocksRetentionPeriod = model.Duration(7 * 24 * time.Hour)
+				cfg.Limits.MaxQueryLookback = model.Duration(30 * 24 * time.Hour)
+			},
+			tenantLimits: map[string]*validation.Limits{
+				"user-1": {IngestionRate: 1000, IngestionBurstSize: 500},
+				"user-2": {IngestionRate: 1000, IngestionBurstSize: 10000},
+				"user-3": nil,
+			},
+			expected: []Finding{
+				{Rule: "compactor-blocks-retention-query-lookback", Severity: SeverityWarning, Parameters: []string{"limits.compactor_blocks_retention_period", "limits.max_query_lookback"}},
+				{Rule: "ingestion-burst-size", Severity: SeverityWarning, Parameters: []string{"overrides.user-1.ingestion_burst_size", "overrides.user-1.ingestion_rate"}, Tenant: "user-1"},
+			},
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultConfig()
+			testData.setup(cfg)
+
+			findings := Check(cfg, testData.tenantLimits)
+			for i := range findings {
+				assert.NotEmpty(t, findings[i].Message)
+				findings[i].Message = ""
+			}
+			assert.Equal(t, testData.expected, findings)
+		})
+	}
+}
+
+func defaultConfig() Config {
+	cfg := Config{
+		Ingester:      &ingester.Config{},
+		Querier:       &querier.Config{},
+		BlocksStorage: &tsdb.BlocksStorageConfig{},
+		Limits:        &validation.Limits{},
+	}
+	flagext.DefaultValues(cfg.Ingester, cfg.Querier, cfg.BlocksStorage, cfg.Limits)
+	return cfg
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package loadgen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	QueryTypeInstant = "instant"
+	QueryTypeRange   = "range"
+)
+
+// Profile describes a workload to replay: the series to write and the queries to run.
+type Profile struct {
+	Metrics []MetricProfile `yaml:"metrics"`
+	Churn   ChurnProfile    `yaml:"churn,omitempty"`
+	Queries []QueryClass    `yaml:"queries,omitempty"`
+}
+
+// MetricProfile describes the series of a metric. Each series gets a unique series_id label,
+// in addition to the constant labels and the labels with the configured cardinality.
+type MetricProfile struct {
+	Name        string            `yaml:"name"`
+	Series      int               `yaml:"series"`
+	ConstLabels map[string]string `yaml:"const_labels,omitempty"`
+	Labels      []LabelProfile    `yaml:"labels,omitempty"`
+}
+
+// LabelProfile describes the values of a label. If values are not set, the label
+// gets cardinality values in the form <name>-<n>.
+type LabelProfile struct {
+	Name        string   `yaml:"name"`
+	Cardinality int      `yaml:"cardinality,omitempty"`
+	Values      []string `yaml:"values,omitempty"`
+}
+
+// ChurnProfile describes how the active series change over time.
+type ChurnProfile struct {
+	// Ratio of the active series of each metric replaced by new series at each interval.
+	Ratio    float64        `yaml:"ratio"`
+	Interval model.Duration `yaml:"interval"`
+}
+
+// QueryClass is a class of similar queries. Each query run picks a class, according to
+// the classes weight, and then a random query of the class.
+type QueryClass struct {
+	Name    string   `yaml:"name"`
+	Weight  float64  `yaml:"weight"`
+	Type    string   `yaml:"type"`
+	Queries []string `yaml:"queries"`
+	// Time range and step of range queries. Range queries end at the current time.
+	TimeRange model.Duration `yaml:"time_range,omitempty"`
+	Step      model.Duration `yaml:"step,omitempty"`
+}
+
+// LoadProfile reads and validates a workload profile from a YAML file.
+func LoadProfile(filename string) (Profile, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var p Profile
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return Profile{}, errors.Wrapf(err, "failed to decode the workload profile %s", filename)
+	}
+	if err := p.Validate(); err != nil {
+		return Profile{}, errors.Wrapf(err, "invalid workload profile %s", filename)
+	}
+	return p, nil
+}
+
+// WriteProfile writes the workload profile to a YAML file.
+func WriteProfile(filename string, p Profile) error {
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+// Validate returns an error if the profile is invalid.
+func (p Profile) Validate() error {
+	for _, m := range p.Metrics {
+		if !model.IsValidMetricName(model.LabelValue(m.Name)) {
+			return errors.Errorf("invalid metric name %q", m.Name)
+		}
+		if m.Series <= 0 {
+			return errors.Errorf("metric %s: the number of series must be positive", m.Name)
+		}
+		for _, l := range m.Labels {
+			if !model.LabelName(l.Name).IsValid() || l.Name == model.MetricNameLabel || l.Name == seriesIDLabel {
+				return errors.Errorf("metric %s: invalid label name %q", m.Name, l.Name)
+			}
+			if l.Cardinality <= 0 && len(l.Values) == 0 {
+				return errors.Errorf("metric %s: label %s must have either a positive cardinality or a list of values", m.Name, l.Name)
+			}
+		}
+	}
+
+	if p.Churn.Ratio < 0 || p.Churn.Ratio > 1 {
+		return errors.New("the churn ratio must be between 0 and 1")
+	}
+	if p.Churn.Ratio > 0 && p.Churn.Interval <= 0 {
+		return errors.New("the churn interval must be positive when the churn ratio is set")
+	}
+
+	for _, q := range p.Queries {
+		if q.Weight < 0 {
+			return errors.Errorf("query class %s: the weight can't be negative", q.Name)
+		}
+		if len(q.Queries) == 0 {
+			return errors.Errorf("query class %s: no queries", q.Name)
+		}
+		switch q.Type {
+		case QueryTypeInstant:
+		case QueryTypeRange:
+			if q.TimeRange <= 0 {
+				return errors.Errorf("query class %s: range queries require a positive time range", q.Name)
+			}
+		default:
+			return errors.Errorf("query class %s: unknown query type %q", q.Name, q.Type)
+		}
+	}
+	return nil
+}
+
+// cardinality returns the number of values of the label.
+func (l LabelProfile) cardinality() int {
+	if len(l.Values) > 0 {
+		return len(l.Values)
+	}
+	return l.Cardinality
+}
+
+// step returns the step of range queries of the class. If not set, the step is chosen
+// to have about 250 points per series, like Grafana does for a typical panel.
+func (q QueryClass) step() time.Duration {
+	if q.Step > 0 {
+		return time.Duration(q.Step)
+	}
+
+	step := (time.Duration(q.TimeRange) / 250).Truncate(time.Second)
+	if step < 15*time.Second {
+		step = 15 * time.Second
+	}
+	return step
+}
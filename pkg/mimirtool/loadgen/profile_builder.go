@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package loadgen
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/mimirtool/analyze"
+)
+
+// maxQueriesPerClass is the max number of distinct queries kept for each query class
+// built from a query log. The most frequent queries are kept.
+const maxQueriesPerClass = 100
+
+// queryRangeClasses are the classes of range queries built from a query log, by time range.
+var queryRangeClasses = []struct {
+	name     string
+	maxRange time.Duration
+}{
+	{name: "range_1h", maxRange: time.Hour},
+	{name: "range_6h", maxRange: 6 * time.Hour},
+	{name: "range_24h", maxRange: 24 * time.Hour},
+	{name: "range_7d", maxRange: 7 * 24 * time.Hour},
+	{name: "range_long", maxRange: math.MaxInt64},
+}
+
+// MetricsFromAnalyze returns the metrics profile matching the series found by
+// the "analyze prometheus" command. The series of each metric are split by job.
+func MetricsFromAnalyze(metrics analyze.MetricsInPrometheus) []MetricProfile {
+	var profiles []MetricProfile
+
+	all := append(append([]analyze.MetricCount{}, metrics.InUseMetricCounts...), metrics.AdditionalMetricCounts...)
+	for _, mc := range all {
+		if mc.Count <= 0 {
+			continue
+		}
+		if len(mc.JobCounts) == 0 {
+			profiles = append(profiles, MetricProfile{Name: mc.Metric, Series: mc.Count})
+			continue
+		}
+
+		for _, jc := range mc.JobCounts {
+			if jc.Count <= 0 {
+				continue
+			}
+			profiles = append(profiles, MetricProfile{
+				Name:        mc.Metric,
+				Series:      jc.Count,
+				ConstLabels: map[string]string{"job": jc.Job},
+			})
+		}
+	}
+	return profiles
+}
+
+type loggedQuery struct {
+	query     string
+	timeRange time.Duration
+	step      time.Duration
+}
+
+// QueriesFromQueryLog returns the query classes matching the queries logged by the query-frontend
+// "query stats" log lines. Instant queries are a single class, while range queries are classified
+// by time range.
+func QueriesFromQueryLog(r io.Reader) ([]QueryClass, error) {
+	var instant []loggedQuery
+	ranges := make([][]loggedQuery, len(queryRangeClasses))
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		fields, ok := parseQueryStatsLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		q := loggedQuery{query: fields["param_query"]}
+		if q.query == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(fields["path"], "/api/v1/query"):
+			instant = append(instant, q)
+
+		case strings.HasSuffix(fields["path"], "/api/v1/query_range"):
+			start, err := parseTime(fields["param_start"])
+			if err != nil {
+				continue
+			}
+			end, err := parseTime(fields["param_end"])
+			if err != nil || end.Before(start) {
+				continue
+			}
+			q.timeRange = end.Sub(start)
+			q.step, _ = parseDuration(fields["param_step"])
+
+			for i, c := range queryRangeClasses {
+				if q.timeRange <= c.maxRange {
+					ranges[i] = append(ranges[i], q)
+					break
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read the query log")
+	}
+
+	var classes []QueryClass
+	if len(instant) > 0 {
+		classes = append(classes, QueryClass{
+			Name:    QueryTypeInstant,
+			Weight:  float64(len(instant)),
+			Type:    QueryTypeInstant,
+			Queries: mostFrequentQueries(instant),
+		})
+	}
+	for i, queries := range ranges {
+		if len(queries) == 0 {
+			continue
+		}
+
+		// The time range and step of the class are the median ones of the logged queries.
+		sort.Slice(queries, func(a, b int) bool { return queries[a].timeRange < queries[b].timeRange })
+		timeRange := queries[len(queries)/2].timeRange.Round(time.Minute)
+		if timeRange <= 0 {
+			timeRange = time.Minute
+		}
+		sort.Slice(queries, func(a, b int) bool { return queries[a].step < queries[b].step })
+		step := queries[len(queries)/2].step
+
+		classes = append(classes, QueryClass{
+			Name:      queryRangeClasses[i].name,
+			Weight:    float64(len(queries)),
+			Type:      QueryTypeRange,
+			Queries:   mostFrequentQueries(queries),
+			TimeRange: model.Duration(timeRange),
+			Step:      model.Duration(step),
+		})
+	}
+	return classes, nil
+}
+
+// parseQueryStatsLine parses a query-frontend "query stats" logfmt line.
+func parseQueryStatsLine(line string) (map[string]string, bool) {
+	dec := logfmt.NewDecoder(strings.NewReader(line))
+	if !dec.ScanRecord() {
+		return nil, false
+	}
+
+	fields := map[string]string{}
+	for dec.ScanKeyval() {
+		fields[string(dec.Key())] = string(dec.Value())
+	}
+	if dec.Err() != nil || fields["msg"] != "query stats" {
+		return nil, false
+	}
+	return fields, true
+}
+
+func mostFrequentQueries(queries []loggedQuery) []string {
+	counts := map[string]int{}
+	for _, q := range queries {
+		counts[q.query]++
+	}
+
+	distinct := make([]string, 0, len(counts))
+	for q := range counts {
+		distinct = append(distinct, q)
+	}
+	sort.Slice(distinct, func(i, j int) bool {
+		if counts[distinct[i]] != counts[distinct[j]] {
+			return counts[distinct[i]] > counts[distinct[j]]
+		}
+		return distinct[i] < distinct[j]
+	})
+
+	if len(distinct) > maxQueriesPerClass {
+		distinct = distinct[:maxQueriesPerClass]
+	}
+	return distinct
+}
+
+// parseTime parses a time in the formats supported by the Prometheus API.
+func parseTime(s string) (time.Time, error) {
+	if t, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, ns := math.Modf(t)
+		return time.Unix(int64(sec), int64(ns*float64(time.Second))).UTC(), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// parseDuration parses a duration in the formats supported by the Prometheus API.
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(d * float64(time.Second)), nil
+	}
+	d, err := model.ParseDuration(s)
+	return time.Duration(d), err
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package loadgen
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirtool/analyze"
+)
+
+func TestMetricsFromAnalyze(t *testing.T) {
+	metrics := analyze.MetricsInPrometheus{
+		InUseMetricCounts: []analyze.MetricCount{
+			{Metric: "up", Count: 30, JobCounts: []analyze.JobCount{{Job: "api", Count: 20}, {Job: "db", Count: 10}}},
+		},
+		AdditionalMetricCounts: []analyze.MetricCount{
+			{Metric: "unused_total", Count: 5},
+			{Metric: "empty", Count: 0},
+		},
+	}
+
+	assert.Equal(t, []MetricProfile{
+		{Name: "up", Series: 20, ConstLabels: map[string]string{"job": "api"}},
+		{Name: "up", Series: 10, ConstLabels: map[string]string{"job": "db"}},
+		{Name: "unused_total", Series: 5},
+	}, MetricsFromAnalyze(metrics))
+}
+
+func TestQueriesFromQueryLog(t *testing.T) {
+	log := strings.Join([]string{
+		`level=info ts=2022-04-20T10:00:00Z caller=handler.go:220 org_id=user-1 msg="query stats" component=query-frontend method=GET path=/prometheus/api/v1/query response_time=10ms param_query=up param_time=1650448800`,
+		`level=info ts=2022-04-20T10:00:01Z caller=handler.go:220 org_id=user-1 msg="query stats" component=query-frontend method=GET path=/prometheus/api/v1/query response_time=10ms param_query=up`,
+		`level=info ts=2022-04-20T10:00:02Z caller=handler.go:220 org_id=user-1 msg="query stats" component=query-frontend method=POST path=/prometheus/api/v1/query response_time=10ms param_query="sum(up)"`,
+		`level=info ts=2022-04-20T10:00:03Z caller=handler.go:220 org_id=user-1 msg="query stats" component=query-frontend method=GET path=/prometheus/api/v1/query_range response_time=1s param_query="sum(rate(http_requests_total[5m]))" param_start=1650445200 param_end=1650448800 param_step=15`,
+		`level=info ts=2022-04-20T10:00:04Z caller=handler.go:220 org_id=user-1 msg="query stats" component=query-frontend method=GET path=/prometheus/api/v1/query_range response_time=1s param_query="sum(up)" param_start=2022-04-20T09:30:00Z param_end=2022-04-20T10:00:00Z param_step=30s`,
+		`level=info ts=2022-04-20T10:00:05Z caller=handler.go:220 org_id=user-1 msg="query stats" component=query-frontend method=GET path=/prometheus/api/v1/query_range response_time=5s param_query="sum(up)" param_start=1650362400 param_end=1650448800 param_step=300`,
+		`level=info ts=2022-04-20T10:00:06Z caller=handler.go:220 msg="another log line" param_query=up`,
+		`not a logfmt line "`,
+	}, "\n")
+
+	classes, err := QueriesFromQueryLog(strings.NewReader(log))
+	require.NoError(t, err)
+
+	assert.Equal(t, []QueryClass{
+		{Name: "instant", Weight: 3, Type: QueryTypeInstant, Queries: []string{"up", "sum(up)"}},
+		{
+			Name:      "range_1h",
+			Weight:    2,
+			Type:      QueryTypeRange,
+			Queries:   []string{"sum(rate(http_requests_total[5m]))", "sum(up)"},
+			TimeRange: model.Duration(time.Hour),
+			Step:      model.Duration(30 * time.Second),
+		},
+		{
+			Name:      "range_24h",
+			Weight:    1,
+			Type:      QueryTypeRange,
+			Queries:   []string{"sum(up)"},
+			TimeRange: model.Duration(24 * time.Hour),
+			Step:      model.Duration(5 * time.Minute),
+		},
+	}, classes)
+
+	require.NoError(t, Profile{Queries: classes}.Validate())
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package loadgen
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Query is a query to run.
+type Query struct {
+	Class string
+	Type  string
+	Expr  string
+	// Time of instant queries.
+	Time time.Time
+	// Time range of range queries.
+	Start, End time.Time
+	Step       time.Duration
+}
+
+// QueryGenerator picks the queries to run according to the query classes weight.
+type QueryGenerator struct {
+	classes []QueryClass
+	// cumWeights[i] is the sum of the weights of classes[0..i].
+	cumWeights []float64
+}
+
+// NewQueryGenerator returns a generator of the given query classes. If all the classes
+// have zero weight, they're picked with the same probability.
+func NewQueryGenerator(classes []QueryClass) *QueryGenerator {
+	g := &QueryGenerator{}
+
+	total := 0.0
+	for _, c := range classes {
+		total += c.Weight
+	}
+
+	cum := 0.0
+	for _, c := range classes {
+		if len(c.Queries) == 0 {
+			continue
+		}
+
+		weight := c.Weight
+		if total == 0 {
+			weight = 1
+		}
+		if weight == 0 {
+			continue
+		}
+
+		cum += weight
+		g.classes = append(g.classes, c)
+		g.cumWeights = append(g.cumWeights, cum)
+	}
+	return g
+}
+
+// Empty returns whether the generator has no query to run.
+func (g *QueryGenerator) Empty() bool {
+	return len(g.classes) == 0
+}
+
+// Next returns a random query to run at the given time.
+func (g *QueryGenerator) Next(now time.Time) Query {
+	r := rand.Float64() * g.cumWeights[len(g.cumWeights)-1]
+	idx := sort.SearchFloat64s(g.cumWeights, r)
+	if idx >= len(g.classes) {
+		idx = len(g.classes) - 1
+	}
+	class := g.classes[idx]
+
+	q := Query{
+		Class: class.Name,
+		Type:  class.Type,
+		Expr:  class.Queries[rand.Intn(len(class.Queries))],
+		Time:  now,
+	}
+	if class.Type == QueryTypeRange {
+		q.Start = now.Add(-time.Duration(class.TimeRange))
+		q.End = now
+		q.Step = class.step()
+	}
+	return q
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// latencyBuckets are the upper bounds of the buckets used to estimate the latency percentiles,
+// from 1ms to about 5 minutes, each bucket being 10% wider than the previous one.
+var latencyBuckets = prometheus.ExponentialBuckets(0.001, 1.1, 133)
+
+// LatencyRecorder records the requests latency by class, to build the summary report.
+type LatencyRecorder struct {
+	mtx     sync.Mutex
+	classes map[string]*classLatency
+}
+
+type classLatency struct {
+	buckets  []uint64
+	requests uint64
+	errors   uint64
+	sum      float64
+	max      float64
+}
+
+func NewLatencyRecorder() *LatencyRecorder {
+	return &LatencyRecorder{classes: map[string]*classLatency{}}
+}
+
+// Observe records the latency of a request of the given class.
+func (r *LatencyRecorder) Observe(class string, d time.Duration, err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	c, ok := r.classes[class]
+	if !ok {
+		c = &classLatency{buckets: make([]uint64, len(latencyBuckets)+1)}
+		r.classes[class] = c
+	}
+
+	c.requests++
+	if err != nil {
+		c.errors++
+	}
+
+	secs := d.Seconds()
+	c.sum += secs
+	if secs > c.max {
+		c.max = secs
+	}
+	c.buckets[sort.SearchFloat64s(latencyBuckets, secs)]++
+}
+
+// Report is the summary report of a load generation run.
+type Report struct {
+	Duration time.Duration `json:"duration"`
+	Classes  []ClassReport `json:"classes"`
+}
+
+// ClassReport is the summary of the requests of a class. Latencies are in seconds.
+type ClassReport struct {
+	Class    string  `json:"class"`
+	Requests uint64  `json:"requests"`
+	Errors   uint64  `json:"errors"`
+	Mean     float64 `json:"mean_seconds"`
+	P50      float64 `json:"p50_seconds"`
+	P90      float64 `json:"p90_seconds"`
+	P99      float64 `json:"p99_seconds"`
+	Max      float64 `json:"max_seconds"`
+}
+
+// Report returns the summary report of the requests recorded so far.
+func (r *LatencyRecorder) Report(duration time.Duration) Report {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	report := Report{Duration: duration}
+	for name, c := range r.classes {
+		report.Classes = append(report.Classes, ClassReport{
+			Class:    name,
+			Requests: c.requests,
+			Errors:   c.errors,
+			Mean:     c.sum / float64(c.requests),
+			P50:      c.quantile(0.5),
+			P90:      c.quantile(0.9),
+			P99:      c.quantile(0.99),
+			Max:      c.max,
+		})
+	}
+
+	sort.Slice(report.Classes, func(i, j int) bool { return report.Classes[i].Class < report.Classes[j].Class })
+	return report
+}
+
+// quantile returns the upper bound of the bucket containing the quantile, capped to the max latency.
+func (c *classLatency) quantile(q float64) float64 {
+	rank := uint64(q * float64(c.requests))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var count uint64
+	for i, n := range c.buckets {
+		count += n
+		if count >= rank {
+			if i < len(latencyBuckets) && latencyBuckets[i] < c.max {
+				return latencyBuckets[i]
+			}
+			return c.max
+		}
+	}
+	return c.max
+}
+
+// WriteText writes the report as a human readable table.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Load generation summary (duration: %s)\n\n", r.Duration.Round(time.Second))
+	fmt.Fprintln(tw, "CLASS\tREQUESTS\tERRORS\tMEAN\tP50\tP90\tP99\tMAX")
+	for _, c := range r.Classes {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n", c.Class, c.Requests, c.Errors,
+			formatSeconds(c.Mean), formatSeconds(c.P50), formatSeconds(c.P90), formatSeconds(c.P99), formatSeconds(c.Max))
+	}
+	return tw.Flush()
+}
+
+func formatSeconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond).String()
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package loadgen
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// seriesIDLabel is the label making each generated series unique.
+const seriesIDLabel = "series_id"
+
+// SeriesGenerator generates the active series of a workload profile at a given time.
+// The series are identified by an index in [0, ActiveSeries()), which is stable over
+// time, while the labels of the series at a given index change because of churn.
+type SeriesGenerator struct {
+	metrics []MetricProfile
+	churn   ChurnProfile
+	start   time.Time
+
+	// firstIndex[i] is the index of the first series of metrics[i].
+	firstIndex []int
+	total      int
+}
+
+// NewSeriesGenerator returns a generator of the profile series. The number of series
+// of each metric is multiplied by scale.
+func NewSeriesGenerator(p Profile, scale float64, start time.Time) *SeriesGenerator {
+	g := &SeriesGenerator{
+		churn: p.Churn,
+		start: start,
+	}
+
+	for _, m := range p.Metrics {
+		m.Series = int(math.Ceil(float64(m.Series) * scale))
+		if m.Series <= 0 {
+			continue
+		}
+
+		g.metrics = append(g.metrics, m)
+		g.firstIndex = append(g.firstIndex, g.total)
+		g.total += m.Series
+	}
+	return g
+}
+
+// ActiveSeries returns the number of active series at any time.
+func (g *SeriesGenerator) ActiveSeries() int {
+	return g.total
+}
+
+// Generation returns how many times the series have churned at the given time.
+func (g *SeriesGenerator) Generation(t time.Time) int {
+	if g.churn.Ratio <= 0 || g.churn.Interval <= 0 || t.Before(g.start) {
+		return 0
+	}
+	return int(t.Sub(g.start) / time.Duration(g.churn.Interval))
+}
+
+// Series returns the labels of the series at the given index and time.
+func (g *SeriesGenerator) Series(idx int, t time.Time) []prompb.Label {
+	m := sort.Search(len(g.firstIndex), func(i int) bool { return g.firstIndex[i] > idx }) - 1
+	metric := g.metrics[m]
+
+	// At each churn, the oldest series of the metric are replaced by new series.
+	churned := int(math.Round(g.churn.Ratio * float64(metric.Series)))
+	id := idx - g.firstIndex[m] + g.Generation(t)*churned
+
+	lbls := make([]prompb.Label, 0, 2+len(metric.ConstLabels)+len(metric.Labels))
+	lbls = append(lbls, prompb.Label{Name: model.MetricNameLabel, Value: metric.Name})
+	lbls = append(lbls, prompb.Label{Name: seriesIDLabel, Value: strconv.Itoa(id)})
+	for name, value := range metric.ConstLabels {
+		lbls = append(lbls, prompb.Label{Name: name, Value: value})
+	}
+
+	// The value of each label is picked treating the series ID as a mixed radix number, where
+	// each digit is the value index of a label, so that all the values combinations are used.
+	rest := id
+	for _, l := range metric.Labels {
+		card := l.cardinality()
+		valueIdx := rest % card
+		rest /= card
+
+		value := fmt.Sprintf("%s-%d", l.Name, valueIdx)
+		if len(l.Values) > 0 {
+			value = l.Values[valueIdx]
+		}
+		lbls = append(lbls, prompb.Label{Name: l.Name, Value: value})
+	}
+
+	sort.Slice(lbls, func(i, j int) bool { return lbls[i].Name < lbls[j].Name })
+	return lbls
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package loadgen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesGenerator(t *testing.T) {
+	start := time.Unix(0, 0)
+	profile := Profile{
+		Metrics: []MetricProfile{
+			{
+				Name:        "http_requests_total",
+				Series:      4,
+				ConstLabels: map[string]string{"job": "api"},
+				Labels: []LabelProfile{
+					{Name: "method", Values: []string{"GET", "POST"}},
+					{Name: "status", Cardinality: 3},
+				},
+			},
+			{Name: "up", Series: 2},
+		},
+		Churn: ChurnProfile{Ratio: 0.5, Interval: model.Duration(time.Minute)},
+	}
+	require.NoError(t, profile.Validate())
+
+	g := NewSeriesGenerator(profile, 1, start)
+	assert.Equal(t, 6, g.ActiveSeries())
+
+	assert.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "http_requests_total"},
+		{Name: "job", Value: "api"},
+		{Name: "method", Value: "POST"},
+		{Name: "series_id", Value: "3"},
+		{Name: "status", Value: "status-1"},
+	}, g.Series(3, start))
+	assert.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "up"},
+		{Name: "series_id", Value: "1"},
+	}, g.Series(5, start))
+
+	// After a churn interval, half of the series of each metric have been replaced.
+	assert.Equal(t, 1, g.Generation(start.Add(90*time.Second)))
+	seriesIDs := func(t time.Time) []string {
+		var ids []string
+		for i := 0; i < 4; i++ {
+			for _, l := range g.Series(i, t) {
+				if l.Name == seriesIDLabel {
+					ids = append(ids, l.Value)
+				}
+			}
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"0", "1", "2", "3"}, seriesIDs(start))
+	assert.Equal(t, []string{"2", "3", "4", "5"}, seriesIDs(start.Add(90*time.Second)))
+}
+
+func TestSeriesGenerator_Scale(t *testing.T) {
+	g := NewSeriesGenerator(Profile{Metrics: []MetricProfile{
+		{Name: "a", Series: 100},
+		{Name: "b", Series: 10},
+	}}, 0.05, time.Now())
+
+	// The number of series is rounded up, so that each metric has at least one series.
+	assert.Equal(t, 6, g.ActiveSeries())
+}
+
+func TestQueryGenerator(t *testing.T) {
+	now := time.Unix(10000, 0)
+	g := NewQueryGenerator([]QueryClass{
+		{Name: "instant", Weight: 1, Type: QueryTypeInstant, Queries: []string{"up"}},
+		{Name: "range_1h", Weight: 3, Type: QueryTypeRange, Queries: []string{"sum(up)"}, TimeRange: model.Duration(time.Hour)},
+		{Name: "disabled", Weight: 0, Type: QueryTypeInstant, Queries: []string{"down"}},
+	})
+	require.False(t, g.Empty())
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		q := g.Next(now)
+		counts[q.Class]++
+
+		if q.Class == "range_1h" {
+			assert.Equal(t, "sum(up)", q.Expr)
+			assert.Equal(t, now.Add(-time.Hour), q.Start)
+			assert.Equal(t, now, q.End)
+			assert.Equal(t, 15*time.Second, q.Step)
+		}
+	}
+
+	assert.Zero(t, counts["disabled"])
+	assert.InDelta(t, 1000, counts["instant"], 200)
+	assert.InDelta(t, 3000, counts["range_1h"], 200)
+
+	assert.True(t, NewQueryGenerator(nil).Empty())
+}
@@ -13,11 +13,13 @@ import (
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/alecthomas/chroma/quick"
 	"github.com/mitchellh/colorstring"
 	"gopkg.in/yaml.v3"
 
+	"github.com/grafana/mimir/pkg/mimirtool/client"
 	"github.com/grafana/mimir/pkg/mimirtool/rules"
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
 )
@@ -247,3 +249,31 @@ func (p *Printer) PrintRuleSet(rules map[string][]rwrulefmt.RuleGroup, format st
 
 	return nil
 }
+
+// PrintVersions prints the versions in the history of a rule group or of an Alertmanager configuration
+func (p *Printer) PrintVersions(versions []client.Version, writer io.Writer) {
+	w := tabwriter.NewWriter(writer, 0, 0, 1, ' ', tabwriter.Debug)
+
+	fmt.Fprintln(w, "Version\t Timestamp\t Author\t Deleted")
+	for _, v := range versions {
+		fmt.Fprintf(w, "%d\t %s\t %s\t %t\n", v.ID, v.Timestamp.Format(time.RFC3339), v.Author, v.Deleted)
+	}
+
+	w.Flush()
+}
+
+// PrintVersionsDiff prints the diff between two versions of a rule group or of an Alertmanager configuration
+func (p *Printer) PrintVersionsDiff(diff string, writer io.Writer) error {
+	if diff == "" {
+		fmt.Fprintln(writer, "no changes detected")
+		return nil
+	}
+
+	// go-text-template
+	if !p.disableColor {
+		return quick.Highlight(writer, diff, "diff", "terminal", "swapoff")
+	}
+
+	fmt.Fprint(writer, diff)
+	return nil
+}
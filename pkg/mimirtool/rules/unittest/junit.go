@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package unittest
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      float64         `xml:"time,attr"`
+	Error     *junitMessage   `xml:"error,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as a JUnit XML report. Each test file is a test suite,
+// and each test group of the file is a test case.
+func WriteJUnit(w io.Writer, results []FileResult) error {
+	report := junitTestSuites{}
+
+	for _, res := range results {
+		suite := junitTestSuite{Name: res.File}
+
+		if res.Err != nil {
+			suite.Errors = 1
+			suite.Error = &junitMessage{Message: "failed to load the test file", Text: res.Err.Error()}
+		}
+
+		for _, g := range res.Groups {
+			tc := junitTestCase{Name: g.Name, ClassName: res.File, Time: g.Duration.Seconds()}
+			if len(g.Errs) > 0 {
+				msgs := make([]string, 0, len(g.Errs))
+				for _, err := range g.Errs {
+					msgs = append(msgs, err.Error())
+				}
+				tc.Failure = &junitMessage{Message: "test group failed", Text: strings.Join(msgs, "\n")}
+				suite.Failures++
+			}
+
+			suite.Tests++
+			suite.Time += tc.Time
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package unittest
+
+import (
+	"context"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/weaveworks/common/user"
+)
+
+// tenantLabel is the internal label used to store the series of all the tenants in the same storage.
+// It's never exposed to the rules being tested.
+const tenantLabel = "__mimirtool_tenant__"
+
+// tenantQueryable is a storage.Queryable returning only the series of the tenant in the context,
+// like the querier does. It doesn't support querying multiple tenants at once: tenant federation
+// is implemented on top of it, the same way it's done in Mimir.
+type tenantQueryable struct {
+	storage.Queryable
+}
+
+func (q tenantQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	querier, err := q.Queryable.Querier(ctx, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantQuerier{Querier: querier, matcher: labels.MustNewMatcher(labels.MatchEqual, tenantLabel, userID)}, nil
+}
+
+type tenantQuerier struct {
+	storage.Querier
+	matcher *labels.Matcher
+}
+
+func (q *tenantQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	return &tenantSeriesSet{SeriesSet: q.Querier.Select(sortSeries, hints, append([]*labels.Matcher{q.matcher}, matchers...)...)}
+}
+
+func (q *tenantQuerier) LabelValues(name string, matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	if name == tenantLabel {
+		return nil, nil, nil
+	}
+	return q.Querier.LabelValues(name, append([]*labels.Matcher{q.matcher}, matchers...)...)
+}
+
+func (q *tenantQuerier) LabelNames(matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	names, warnings, err := q.Querier.LabelNames(append([]*labels.Matcher{q.matcher}, matchers...)...)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	filtered := names[:0]
+	for _, name := range names {
+		if name != tenantLabel {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered, warnings, nil
+}
+
+// tenantSeriesSet removes the internal tenant label from the series.
+type tenantSeriesSet struct {
+	storage.SeriesSet
+}
+
+func (s *tenantSeriesSet) At() storage.Series {
+	return tenantSeries{Series: s.SeriesSet.At()}
+}
+
+type tenantSeries struct {
+	storage.Series
+}
+
+func (s tenantSeries) Labels() labels.Labels {
+	return labels.NewBuilder(s.Series.Labels()).Del(tenantLabel).Labels()
+}
+
+// tenantAppendable is a storage.Appendable adding the tenant label to the appended series,
+// so that the series written by the rules are only visible to the tenant owning them.
+type tenantAppendable struct {
+	storage.Appendable
+	userID string
+}
+
+func (a tenantAppendable) Appender(ctx context.Context) storage.Appender {
+	return tenantAppender{Appender: a.Appendable.Appender(user.InjectOrgID(ctx, a.userID)), userID: a.userID}
+}
+
+type tenantAppender struct {
+	storage.Appender
+	userID string
+}
+
+func (a tenantAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	return a.Appender.Append(ref, withTenantLabel(l, a.userID), t, v)
+}
+
+func (a tenantAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	return a.Appender.AppendExemplar(ref, withTenantLabel(l, a.userID), e)
+}
+
+func withTenantLabel(l labels.Labels, userID string) labels.Labels {
+	return labels.NewBuilder(l).Set(tenantLabel, userID).Labels()
+}
@@ -0,0 +1,621 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/cmd/promtool/unittest.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors.
+
+package unittest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/weaveworks/common/user"
+	yaml "gopkg.in/yaml.v3"
+
+	mimirrules "github.com/grafana/mimir/pkg/mimirtool/rules"
+	"github.com/grafana/mimir/pkg/querier/engine"
+	"github.com/grafana/mimir/pkg/querier/tenantfederation"
+	"github.com/grafana/mimir/pkg/ruler"
+)
+
+// DefaultTenant is the tenant owning the rules under test, unless configured in the test file.
+const DefaultTenant = "anonymous"
+
+// TestFile is the content of a rules unit test file. The format is the same used by promtool,
+// extended with the tenant owning the rules and the default evaluation delay (RulerEvaluationDelay).
+type TestFile struct {
+	RuleFiles          []string       `yaml:"rule_files"`
+	EvaluationInterval model.Duration `yaml:"evaluation_interval,omitempty"`
+	GroupEvalOrder     []string       `yaml:"group_eval_order"`
+	Tests              []TestGroup    `yaml:"tests"`
+
+	// Tenant owning the rules. Input series belong to this tenant, unless otherwise specified.
+	Tenant string `yaml:"tenant,omitempty"`
+	// Default evaluation delay of the rule groups, like the ruler_evaluation_delay_duration limit.
+	EvaluationDelay model.Duration `yaml:"evaluation_delay,omitempty"`
+}
+
+// TestGroup is a group of input series and tests associated with it.
+type TestGroup struct {
+	Interval        model.Duration             `yaml:"interval"`
+	InputSeries     []Series                   `yaml:"input_series"`
+	AlertRuleTests  []AlertTestCase            `yaml:"alert_rule_test,omitempty"`
+	PromQLExprTests []PromQLTestCase           `yaml:"promql_expr_test,omitempty"`
+	ExternalLabels  labels.Labels              `yaml:"external_labels,omitempty"`
+	ExternalURL     string                     `yaml:"external_url,omitempty"`
+	TestGroupName   string                     `yaml:"name,omitempty"`
+	tenant          string                     `yaml:"-"`
+	evaluationDelay time.Duration              `yaml:"-"`
+	groupOrder      map[string]int             `yaml:"-"`
+	ruleNamespaces  []mimirrules.RuleNamespace `yaml:"-"`
+}
+
+// Series is an input series, in the promql load command format.
+type Series struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+	// Tenant the series belongs to. Defaults to the tenant owning the rules.
+	Tenant string `yaml:"tenant,omitempty"`
+}
+
+// AlertTestCase asserts on the alerts firing at a given time.
+type AlertTestCase struct {
+	EvalTime  model.Duration `yaml:"eval_time"`
+	Alertname string         `yaml:"alertname"`
+	ExpAlerts []Alert        `yaml:"exp_alerts"`
+}
+
+// Alert is an expected firing alert.
+type Alert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+// PromQLTestCase asserts on the result of a PromQL expression at a given time.
+type PromQLTestCase struct {
+	Expr       string         `yaml:"expr"`
+	EvalTime   model.Duration `yaml:"eval_time"`
+	ExpSamples []Sample       `yaml:"exp_samples"`
+}
+
+// Sample is an expected sample.
+type Sample struct {
+	Labels string  `yaml:"labels"`
+	Value  float64 `yaml:"value"`
+}
+
+// FileResult is the result of running the tests of a test file.
+type FileResult struct {
+	File string
+	// Error loading the test file or the rules. When set, no test has been run.
+	Err    error
+	Groups []GroupResult
+}
+
+// Failed returns whether the test file failed.
+func (r FileResult) Failed() bool {
+	if r.Err != nil {
+		return true
+	}
+	for _, g := range r.Groups {
+		if len(g.Errs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupResult is the result of a test group.
+type GroupResult struct {
+	Name     string
+	Duration time.Duration
+	Errs     []error
+}
+
+// Runner runs rules unit tests.
+type Runner struct {
+	engineOpts promql.EngineOpts
+	logger     log.Logger
+}
+
+// NewRunner returns a Runner evaluating the rules with the same PromQL engine settings used by Mimir.
+func NewRunner(cfg engine.Config, logger log.Logger) *Runner {
+	// Swap out the default resolver to support multiple tenant IDs separated by a '|', required by federated rule groups.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+
+	return &Runner{
+		engineOpts: engine.NewPromQLEngineOptions(cfg, nil, logger, nil),
+		logger:     logger,
+	}
+}
+
+// RunFile runs all the tests of a test file.
+func (r *Runner) RunFile(filename string) FileResult {
+	result := FileResult{File: filename}
+
+	groups, err := r.loadFile(filename)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for n, tg := range groups {
+		name := tg.TestGroupName
+		if name == "" {
+			name = fmt.Sprintf("test group #%d", n+1)
+		}
+
+		start := time.Now()
+		errs := r.runGroup(tg)
+		result.Groups = append(result.Groups, GroupResult{Name: name, Duration: time.Since(start), Errs: errs})
+	}
+	return result
+}
+
+func (r *Runner) loadFile(filename string) ([]TestGroup, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var tf TestFile
+	decoder := yaml.NewDecoder(strings.NewReader(string(b)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&tf); err != nil {
+		return nil, errors.Wrap(err, "parse test file")
+	}
+
+	if tf.EvaluationInterval == 0 {
+		tf.EvaluationInterval = model.Duration(time.Minute)
+	}
+	if tf.Tenant == "" {
+		tf.Tenant = DefaultTenant
+	}
+
+	ruleFiles, err := resolveRuleFiles(filepath.Dir(filename), tf.RuleFiles)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := mimirrules.ParseFiles(mimirrules.MimirBackend, ruleFiles)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse rule files")
+	}
+
+	groupOrder := make(map[string]int, len(tf.GroupEvalOrder))
+	for i, name := range tf.GroupEvalOrder {
+		if _, ok := groupOrder[name]; ok {
+			return nil, errors.Errorf("group name repeated in evaluation order: %s", name)
+		}
+		groupOrder[name] = i
+	}
+
+	// Namespaces are sorted to get a deterministic evaluation order of the groups not listed in the evaluation order.
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sortedNamespaces := make([]mimirrules.RuleNamespace, 0, len(names))
+	for _, name := range names {
+		sortedNamespaces = append(sortedNamespaces, namespaces[name])
+	}
+
+	for i := range tf.Tests {
+		if tf.Tests[i].Interval == 0 {
+			tf.Tests[i].Interval = tf.EvaluationInterval
+		}
+		tf.Tests[i].tenant = tf.Tenant
+		tf.Tests[i].evaluationDelay = time.Duration(tf.EvaluationDelay)
+		tf.Tests[i].groupOrder = groupOrder
+		tf.Tests[i].ruleNamespaces = sortedNamespaces
+	}
+	return tf.Tests, nil
+}
+
+// resolveRuleFiles resolves the rule files globs, relative to the directory of the test file.
+func resolveRuleFiles(baseDir string, patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, errors.Errorf("no rule files match %s", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// runGroup runs the tests of a test group, evaluating the rules at each evaluation interval.
+func (r *Runner) runGroup(tg TestGroup) []error {
+	input, err := tg.seriesLoadingString()
+	if err != nil {
+		return []error{err}
+	}
+
+	suite, err := promql.NewLazyLoader(nil, input, promql.LazyLoaderOpts{EnableAtModifier: r.engineOpts.EnableAtModifier, EnableNegativeOffset: r.engineOpts.EnableNegativeOffset})
+	if err != nil {
+		return []error{err}
+	}
+	defer suite.Close()
+
+	// The rules are evaluated the same way the ruler does: using Mimir PromQL engine settings, querying only
+	// the series of the tenant owning the rules, or the source tenants' series for federated rule groups.
+	eng := promql.NewEngine(r.engineOpts)
+	queryable := tenantQueryable{Queryable: suite.Storage()}
+	federatedQueryable := tenantfederation.NewQueryable(queryable, false, r.logger)
+
+	ctx := user.InjectOrgID(suite.Context(), tg.tenant)
+	opts := &rules.ManagerOptions{
+		QueryFunc:  ruler.TenantFederationQueryFunc(rules.EngineQueryFunc(eng, queryable), rules.EngineQueryFunc(eng, federatedQueryable)),
+		Appendable: tenantAppendable{Appendable: suite.Storage(), userID: tg.tenant},
+		Queryable:  queryable,
+		Context:    ctx,
+		NotifyFunc: func(ctx context.Context, expr string, alerts ...*rules.Alert) {},
+		Logger:     r.logger,
+		DefaultEvaluationDelay: func() time.Duration {
+			return tg.evaluationDelay
+		},
+	}
+
+	groups, err := tg.loadGroups(opts)
+	if err != nil {
+		return []error{err}
+	}
+
+	mint := time.Unix(0, 0).UTC()
+	maxt := mint.Add(tg.maxEvalTime())
+
+	// All the eval times at which alerts are tested, the alert names tested at each eval time and the test cases.
+	alertEvalTimesMap := map[model.Duration]struct{}{}
+	alertsInTest := map[model.Duration]map[string]struct{}{}
+	alertTests := map[model.Duration][]AlertTestCase{}
+	for _, alert := range tg.AlertRuleTests {
+		if alert.Alertname == "" {
+			return []error{errors.Errorf("an item under alert_rule_test misses required attribute alertname at eval_time %v", alert.EvalTime)}
+		}
+		alertEvalTimesMap[alert.EvalTime] = struct{}{}
+
+		if _, ok := alertsInTest[alert.EvalTime]; !ok {
+			alertsInTest[alert.EvalTime] = map[string]struct{}{}
+		}
+		alertsInTest[alert.EvalTime][alert.Alertname] = struct{}{}
+		alertTests[alert.EvalTime] = append(alertTests[alert.EvalTime], alert)
+	}
+	alertEvalTimes := make([]model.Duration, 0, len(alertEvalTimesMap))
+	for t := range alertEvalTimesMap {
+		alertEvalTimes = append(alertEvalTimes, t)
+	}
+	sort.Slice(alertEvalTimes, func(i, j int) bool { return alertEvalTimes[i] < alertEvalTimes[j] })
+
+	// Mark alerting rules as restored, to ensure the ALERTS series are created when they run.
+	for _, g := range groups {
+		for _, rule := range g.Rules() {
+			if alertRule, ok := rule.(*rules.AlertingRule); ok {
+				alertRule.SetRestored(true)
+			}
+		}
+	}
+
+	evalInterval := time.Duration(tg.Interval)
+	curr := 0
+
+	var errs []error
+	for ts := mint; !ts.After(maxt); ts = ts.Add(evalInterval) {
+		var evalErrs []error
+		suite.WithSamplesTill(ts, func(err error) {
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for _, g := range groups {
+				g.Eval(ruler.FederatedGroupContextFunc(ctx, g), ts)
+				for _, rule := range g.Rules() {
+					if rule.LastError() != nil {
+						evalErrs = append(evalErrs, errors.Errorf("    rule: %s, time: %s, err: %v", rule.Name(), ts.Sub(mint), rule.LastError()))
+					}
+				}
+			}
+		})
+		errs = append(errs, evalErrs...)
+		// Stop testing only if errors occurred evaluating the rules, rather than on test failures.
+		if len(evalErrs) > 0 {
+			return errs
+		}
+
+		// Alerts tested at eval times in the range [ts, ts+evalInterval) are compared with the evaluation at ts.
+		for curr < len(alertEvalTimes) && ts.Sub(mint) <= time.Duration(alertEvalTimes[curr]) && time.Duration(alertEvalTimes[curr]) < ts.Add(evalInterval).Sub(mint) {
+			t := alertEvalTimes[curr]
+			errs = append(errs, tg.checkAlerts(groups, alertsInTest[t], alertTests[t])...)
+			curr++
+		}
+	}
+
+	errs = append(errs, tg.checkPromQLExprs(suite.Context(), eng, queryable, mint)...)
+	return errs
+}
+
+// loadGroups builds the rule groups, ordered by the group evaluation order.
+func (tg *TestGroup) loadGroups(opts *rules.ManagerOptions) ([]*rules.Group, error) {
+	var groups []*rules.Group
+
+	for _, ns := range tg.ruleNamespaces {
+		for _, rg := range ns.Groups {
+			interval := time.Duration(tg.Interval)
+			if rg.Interval != 0 {
+				interval = time.Duration(rg.Interval)
+			}
+
+			groupRules := make([]rules.Rule, 0, len(rg.Rules))
+			for _, rule := range rg.Rules {
+				expr, err := parser.ParseExpr(rule.Expr.Value)
+				if err != nil {
+					return nil, errors.Wrapf(err, "namespace %s, group %s", ns.Namespace, rg.Name)
+				}
+
+				if rule.Alert.Value != "" {
+					groupRules = append(groupRules, rules.NewAlertingRule(
+						rule.Alert.Value,
+						expr,
+						time.Duration(rule.For),
+						labels.FromMap(rule.Labels),
+						labels.FromMap(rule.Annotations),
+						tg.ExternalLabels,
+						tg.ExternalURL,
+						true,
+						log.With(opts.Logger, "alert", rule.Alert.Value),
+					))
+					continue
+				}
+				groupRules = append(groupRules, rules.NewRecordingRule(rule.Record.Value, expr, labels.FromMap(rule.Labels)))
+			}
+
+			groups = append(groups, rules.NewGroup(rules.GroupOptions{
+				Name:            rg.Name,
+				File:            ns.Namespace,
+				Interval:        interval,
+				Limit:           rg.Limit,
+				Rules:           groupRules,
+				SourceTenants:   rg.SourceTenants,
+				Opts:            opts,
+				EvaluationDelay: (*time.Duration)(rg.EvaluationDelay),
+			}))
+		}
+	}
+
+	// Groups listed in the evaluation order come first, the other ones keep the namespace order.
+	sort.SliceStable(groups, func(i, j int) bool {
+		iOrder, iOk := tg.groupOrder[groups[i].Name()]
+		jOrder, jOk := tg.groupOrder[groups[j].Name()]
+		if iOk && jOk {
+			return iOrder < jOrder
+		}
+		return iOk && !jOk
+	})
+	return groups, nil
+}
+
+func (tg *TestGroup) checkAlerts(groups []*rules.Group, alertNames map[string]struct{}, testCases []AlertTestCase) []error {
+	// The same alert name can be used in multiple groups, so we collect all of them.
+	got := map[string]labelsAndAnnotations{}
+	for _, g := range groups {
+		for _, rule := range g.Rules() {
+			ar, ok := rule.(*rules.AlertingRule)
+			if !ok {
+				continue
+			}
+			if _, ok := alertNames[ar.Name()]; !ok {
+				continue
+			}
+
+			var alerts labelsAndAnnotations
+			for _, a := range ar.ActiveAlerts() {
+				if a.State == rules.StateFiring {
+					alerts = append(alerts, labelAndAnnotation{
+						Labels:      append(labels.Labels{}, a.Labels...),
+						Annotations: append(labels.Labels{}, a.Annotations...),
+					})
+				}
+			}
+			got[ar.Name()] = append(got[ar.Name()], alerts...)
+		}
+	}
+
+	var errs []error
+	for _, testCase := range testCases {
+		gotAlerts := got[testCase.Alertname]
+
+		var expAlerts labelsAndAnnotations
+		for _, a := range testCase.ExpAlerts {
+			// The alertname label is added by the rule evaluation, so it's not part of the expected labels.
+			expLabels := labels.NewBuilder(labels.FromMap(a.ExpLabels)).Set(labels.AlertName, testCase.Alertname).Labels()
+			expAlerts = append(expAlerts, labelAndAnnotation{
+				Labels:      expLabels,
+				Annotations: labels.FromMap(a.ExpAnnotations),
+			})
+		}
+
+		sort.Sort(gotAlerts)
+		sort.Sort(expAlerts)
+
+		if !reflect.DeepEqual(expAlerts, gotAlerts) {
+			errs = append(errs, errors.Errorf("    alertname: %s, time: %s, \n        exp:%v, \n        got:%v",
+				testCase.Alertname, testCase.EvalTime.String(), indentLines(expAlerts.String(), "            "), indentLines(gotAlerts.String(), "            ")))
+		}
+	}
+	return errs
+}
+
+func (tg *TestGroup) checkPromQLExprs(ctx context.Context, eng *promql.Engine, queryable tenantQueryable, mint time.Time) []error {
+	var errs []error
+	ctx = user.InjectOrgID(ctx, tg.tenant)
+
+outer:
+	for _, testCase := range tg.PromQLExprTests {
+		got, err := rules.EngineQueryFunc(eng, queryable)(ctx, testCase.Expr, mint.Add(time.Duration(testCase.EvalTime)))
+		if err != nil {
+			errs = append(errs, errors.Errorf("    expr: %q, time: %s, err: %s", testCase.Expr, testCase.EvalTime.String(), err.Error()))
+			continue
+		}
+
+		var gotSamples []parsedSample
+		for _, s := range got {
+			gotSamples = append(gotSamples, parsedSample{Labels: s.Metric.Copy(), Value: s.V})
+		}
+
+		var expSamples []parsedSample
+		for _, s := range testCase.ExpSamples {
+			lb, err := parser.ParseMetric(s.Labels)
+			if err != nil {
+				errs = append(errs, errors.Errorf("    expr: %q, time: %s, err: %s", testCase.Expr, testCase.EvalTime.String(), errors.Wrapf(err, "labels %q", s.Labels).Error()))
+				continue outer
+			}
+			expSamples = append(expSamples, parsedSample{Labels: lb, Value: s.Value})
+		}
+
+		sort.Slice(expSamples, func(i, j int) bool { return labels.Compare(expSamples[i].Labels, expSamples[j].Labels) <= 0 })
+		sort.Slice(gotSamples, func(i, j int) bool { return labels.Compare(gotSamples[i].Labels, gotSamples[j].Labels) <= 0 })
+		if !reflect.DeepEqual(expSamples, gotSamples) {
+			errs = append(errs, errors.Errorf("    expr: %q, time: %s,\n        exp: %v\n        got: %v", testCase.Expr,
+				testCase.EvalTime.String(), parsedSamplesString(expSamples), parsedSamplesString(gotSamples)))
+		}
+	}
+	return errs
+}
+
+// seriesLoadingString returns the input series in the promql load command format. The series of each
+// tenant are stored with the internal tenant label, so that each tenant can only query its own series.
+func (tg *TestGroup) seriesLoadingString() (string, error) {
+	result := fmt.Sprintf("load %v\n", shortDuration(tg.Interval))
+	for _, is := range tg.InputSeries {
+		lset, err := parser.ParseMetric(is.Series)
+		if err != nil {
+			return "", errors.Wrapf(err, "input series %q", is.Series)
+		}
+
+		userID := is.Tenant
+		if userID == "" {
+			userID = tg.tenant
+		}
+		result += fmt.Sprintf("  %v %v\n", withTenantLabel(lset, userID), is.Values)
+	}
+	return result, nil
+}
+
+// maxEvalTime returns the max eval time among all the alert and PromQL tests.
+func (tg *TestGroup) maxEvalTime() time.Duration {
+	var maxd model.Duration
+	for _, alert := range tg.AlertRuleTests {
+		if alert.EvalTime > maxd {
+			maxd = alert.EvalTime
+		}
+	}
+	for _, pet := range tg.PromQLExprTests {
+		if pet.EvalTime > maxd {
+			maxd = pet.EvalTime
+		}
+	}
+	return time.Duration(maxd)
+}
+
+func shortDuration(d model.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+type labelsAndAnnotations []labelAndAnnotation
+
+func (la labelsAndAnnotations) Len() int      { return len(la) }
+func (la labelsAndAnnotations) Swap(i, j int) { la[i], la[j] = la[j], la[i] }
+func (la labelsAndAnnotations) Less(i, j int) bool {
+	diff := labels.Compare(la[i].Labels, la[j].Labels)
+	if diff != 0 {
+		return diff < 0
+	}
+	return labels.Compare(la[i].Annotations, la[j].Annotations) < 0
+}
+
+func (la labelsAndAnnotations) String() string {
+	if len(la) == 0 {
+		return "[]"
+	}
+	s := "[\n0:" + indentLines("\n"+la[0].String(), "  ")
+	for i, l := range la[1:] {
+		s += ",\n" + strconv.Itoa(i+1) + ":" + indentLines("\n"+l.String(), "  ")
+	}
+	s += "\n]"
+
+	return s
+}
+
+type labelAndAnnotation struct {
+	Labels      labels.Labels
+	Annotations labels.Labels
+}
+
+func (la *labelAndAnnotation) String() string {
+	return "Labels:" + la.Labels.String() + "\nAnnotations:" + la.Annotations.String()
+}
+
+type parsedSample struct {
+	Labels labels.Labels
+	Value  float64
+}
+
+func parsedSamplesString(pss []parsedSample) string {
+	if len(pss) == 0 {
+		return "nil"
+	}
+	s := pss[0].String()
+	for _, ps := range pss[1:] {
+		s += ", " + ps.String()
+	}
+	return s
+}
+
+func (ps *parsedSample) String() string {
+	return ps.Labels.String() + " " + strconv.FormatFloat(ps.Value, 'E', -1, 64)
+}
+
+// indentLines prefixes each line in the supplied string with the given "indent" string.
+func indentLines(lines, indent string) string {
+	sb := strings.Builder{}
+	n := strings.Split(lines, "\n")
+	for i, l := range n {
+		if i > 0 {
+			sb.WriteString(indent)
+		}
+		sb.WriteString(l)
+		if i != len(n)-1 {
+			sb.WriteRune('\n')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package unittest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/querier/engine"
+)
+
+func newTestRunner() *Runner {
+	cfg := engine.Config{}
+	flagext.DefaultValues(&cfg)
+	return NewRunner(cfg, log.NewNopLogger())
+}
+
+func TestRunner_RunFile(t *testing.T) {
+	tests := map[string]struct {
+		file           string
+		expectedGroups map[string]int
+		expectedErr    string
+	}{
+		"recording, alerting and federated rules": {
+			file: "testdata/passing_test.yaml",
+			expectedGroups: map[string]int{
+				"recording and alerting rules": 0,
+				"federated rule group":         0,
+			},
+		},
+		"evaluation delay": {
+			file: "testdata/evaluation_delay_test.yaml",
+			expectedGroups: map[string]int{
+				"test group #1": 0,
+			},
+		},
+		"failing tests": {
+			file: "testdata/failing_test.yaml",
+			expectedGroups: map[string]int{
+				"wrong recording rule result": 1,
+				"missing alert":               1,
+			},
+		},
+		"missing test file": {
+			file:        "testdata/missing_test.yaml",
+			expectedErr: "open testdata/missing_test.yaml: no such file or directory",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			res := newTestRunner().RunFile(testData.file)
+			if testData.expectedErr != "" {
+				require.EqualError(t, res.Err, testData.expectedErr)
+				assert.True(t, res.Failed())
+				return
+			}
+			require.NoError(t, res.Err)
+
+			actualGroups := map[string]int{}
+			for _, g := range res.Groups {
+				actualGroups[g.Name] = len(g.Errs)
+			}
+			assert.Equal(t, testData.expectedGroups, actualGroups, "number of errors per test group: %v", res.Groups)
+		})
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	runner := newTestRunner()
+	results := []FileResult{
+		runner.RunFile("testdata/passing_test.yaml"),
+		runner.RunFile("testdata/failing_test.yaml"),
+		runner.RunFile("testdata/missing_test.yaml"),
+	}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, WriteJUnit(&buf, results))
+
+	var report junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &report))
+
+	assert.Equal(t, 4, report.Tests)
+	assert.Equal(t, 2, report.Failures)
+	assert.Equal(t, 1, report.Errors)
+	require.Len(t, report.Suites, 3)
+
+	assert.Equal(t, "testdata/passing_test.yaml", report.Suites[0].Name)
+	assert.Equal(t, 0, report.Suites[0].Failures)
+	require.Len(t, report.Suites[0].TestCases, 2)
+	assert.Nil(t, report.Suites[0].TestCases[0].Failure)
+
+	assert.Equal(t, "testdata/failing_test.yaml", report.Suites[1].Name)
+	assert.Equal(t, 2, report.Suites[1].Failures)
+	require.Len(t, report.Suites[1].TestCases, 2)
+	require.NotNil(t, report.Suites[1].TestCases[0].Failure)
+	assert.Contains(t, report.Suites[1].TestCases[0].Failure.Text, "job:up:sum")
+
+	require.NotNil(t, report.Suites[2].Error)
+	assert.Empty(t, report.Suites[2].TestCases)
+}
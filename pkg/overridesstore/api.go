@@ -0,0 +1,392 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package overridesstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	maxRequestBodySize   = 1 << 20
+	defaultAuditLogLimit = 100
+
+	actionSet    = "set"
+	actionPatch  = "patch"
+	actionDelete = "delete"
+
+	// forwardedHeader is set on the requests forwarded to the writer, to detect a writer which would forward them again.
+	forwardedHeader = "X-Mimir-Overrides-Forwarded"
+)
+
+// API exposes the endpoints to read and write the stored overrides of a tenant. Changes are conditional on the
+// version of the overrides given in the If-Match header, if any, and are recorded in the tenant's audit log.
+// Changes are forwarded to the single instance writing the overrides, if this instance isn't the writer.
+type API struct {
+	store  *BucketStore
+	loader *Loader
+	logger log.Logger
+	audit  log.Logger
+
+	// writer forwards the changes to the writer instance. It's nil if this instance is the writer.
+	writer *httputil.ReverseProxy
+}
+
+// NewAPI returns a new API. writerURL is the URL of the instance writing the overrides, or nil if this instance is the writer.
+func NewAPI(store *BucketStore, loader *Loader, writerURL *url.URL, logger log.Logger) *API {
+	a := &API{
+		store:  store,
+		loader: loader,
+		logger: logger,
+		audit:  log.With(logger, "component", "overrides-audit"),
+	}
+
+	if writerURL != nil {
+		a.writer = httputil.NewSingleHostReverseProxy(writerURL)
+		director := a.writer.Director
+		a.writer.Director = func(r *http.Request) {
+			director(r)
+			r.Header.Set(forwardedHeader, "true")
+		}
+		a.writer.ModifyResponse = func(resp *http.Response) error {
+			if resp.StatusCode/100 == 2 {
+				a.reload(resp.Request.Context())
+			}
+			return nil
+		}
+		a.writer.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, err error) {
+			level.Warn(a.logger).Log("msg", "failed to forward the overrides change to the writer", "writer", writerURL.String(), "err", err)
+			http.Error(w, fmt.Sprintf("failed to forward the overrides change to the writer: %s", err), http.StatusBadGateway)
+		}
+	}
+	return a
+}
+
+// GetOverrides returns the stored overrides of the tenant, with their version in the ETag header.
+func (a *API) GetOverrides(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tenantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	overrides, err := a.store.Get(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeOverrides(w, overrides)
+}
+
+// SetOverrides replaces the stored overrides of the tenant with the ones in the request body.
+func (a *API) SetOverrides(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tenantFromPath(w, r)
+	if !ok || a.forwardToWriter(w, r) {
+		return
+	}
+	expectedVersion, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	overrides, err := readOverrides(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.set(w, r, userID, actionSet, overrides, expectedVersion)
+}
+
+// PatchOverrides merges the overrides in the request body into the stored overrides of the tenant.
+// The limits set to null are removed from the stored overrides.
+func (a *API) PatchOverrides(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tenantFromPath(w, r)
+	if !ok || a.forwardToWriter(w, r) {
+		return
+	}
+	expectedVersion, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	patch, err := readOverrides(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, err := a.store.Get(r.Context(), userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	if expectedVersion != AnyVersion && expectedVersion != current.Version {
+		writeError(w, ErrVersionMismatch)
+		return
+	}
+
+	// The patched overrides are written only if they haven't been changed since they were read.
+	a.set(w, r, userID, actionPatch, MergeOverrides(current.Overrides, patch), current.Version)
+}
+
+// DeleteOverrides deletes the stored overrides of the tenant.
+func (a *API) DeleteOverrides(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tenantFromPath(w, r)
+	if !ok || a.forwardToWriter(w, r) {
+		return
+	}
+	expectedVersion, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := a.store.Delete(r.Context(), userID, expectedVersion)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// The audit log of the deleted overrides is deleted too, so only the audit log message keeps track of the deletion.
+	a.logChange(r, userID, AuditEntry{Action: actionDelete, Version: deleted.Version})
+	if err := a.store.DeleteAuditLog(r.Context(), userID); err != nil {
+		level.Error(a.logger).Log("msg", "failed to delete the overrides audit log", "user", userID, "err", err)
+	}
+	a.reload(r.Context())
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetAuditLog returns the changes of the overrides of the tenant, from the newest to the oldest.
+// The number of returned entries can be set with the limit parameter.
+func (a *API) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tenantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultAuditLogLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := a.store.AuditLog(r.Context(), userID, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeYAML(w, entries)
+}
+
+func (a *API) set(w http.ResponseWriter, r *http.Request, userID, action string, overrides yaml.MapSlice, expectedVersion int64) {
+	if hasRedactedSecrets(overrides) {
+		current, err := a.store.Get(r.Context(), userID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			writeError(w, err)
+			return
+		}
+		if overrides, err = restoreSecrets(overrides, current.Overrides); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := ToLimits(overrides); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := a.store.Set(r.Context(), userID, overrides, expectedVersion, time.Now())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	a.recordChange(r, userID, AuditEntry{Action: action, Version: updated.Version, Overrides: RedactSecrets(updated.Overrides)})
+	writeOverrides(w, updated)
+}
+
+// forwardToWriter forwards the request changing the overrides to the writer instance, unless this instance
+// is the writer. It returns false if the request must be handled by this instance.
+func (a *API) forwardToWriter(w http.ResponseWriter, r *http.Request) bool {
+	if a.writer == nil {
+		return false
+	}
+	if r.Header.Get(forwardedHeader) != "" {
+		http.Error(w, "the overrides change has been forwarded to an instance which is not the writer", http.StatusInternalServerError)
+		return true
+	}
+
+	a.writer.ServeHTTP(w, r)
+	return true
+}
+
+// recordChange logs the change in the audit log, and reloads the stored overrides so that the change
+// is applied immediately by this instance.
+func (a *API) recordChange(r *http.Request, userID string, entry AuditEntry) {
+	entry = a.logChange(r, userID, entry)
+	if err := a.store.AddAuditEntry(r.Context(), userID, entry); err != nil {
+		// The change has been done, so the request doesn't fail. The audit log message keeps track of it.
+		level.Error(a.logger).Log("msg", "failed to store the overrides audit log entry", "user", userID, "err", err)
+	}
+
+	a.reload(r.Context())
+}
+
+// logChange logs the change with the audit logger, and returns the entry completed with the details of the request.
+func (a *API) logChange(r *http.Request, userID string, entry AuditEntry) AuditEntry {
+	entry.Timestamp = time.Now().UTC()
+	entry.Source = r.RemoteAddr
+	entry.UserAgent = r.UserAgent()
+
+	level.Info(a.audit).Log("msg", "tenant overrides changed", "user", userID, "action", entry.Action, "version", entry.Version, "source", entry.Source, "user_agent", entry.UserAgent)
+	return entry
+}
+
+// reload reloads the stored overrides so that a change is applied immediately by this instance.
+func (a *API) reload(ctx context.Context) {
+	if a.loader == nil {
+		return
+	}
+	if err := a.loader.Load(ctx); err != nil {
+		level.Warn(a.logger).Log("msg", "failed to reload the stored overrides", "err", err)
+	}
+}
+
+// ToLimits returns the limits of a tenant with the overrides, validating them. The limits which aren't overridden
+// are set to the defaults set with validation.SetDefaultLimitsForYAMLUnmarshalling.
+func ToLimits(overrides yaml.MapSlice) (*validation.Limits, error) {
+	data, err := yaml.Marshal(overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	var limits validation.Limits
+	if err := yaml.UnmarshalStrict(data, &limits); err != nil {
+		return nil, errors.Wrap(err, "invalid overrides")
+	}
+	if err := limits.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid overrides")
+	}
+	return &limits, nil
+}
+
+// MergeOverrides returns the base overrides with the overrides of the patch, which take precedence.
+// The overrides set to null in the patch are removed.
+func MergeOverrides(base, patch yaml.MapSlice) yaml.MapSlice {
+	patched := make(map[string]bool, len(patch))
+	for _, item := range patch {
+		patched[fmt.Sprint(item.Key)] = true
+	}
+
+	out := make(yaml.MapSlice, 0, len(base)+len(patch))
+	for _, item := range base {
+		if !patched[fmt.Sprint(item.Key)] {
+			out = append(out, item)
+		}
+	}
+	for _, item := range patch {
+		if item.Value != nil {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func tenantFromPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := mux.Vars(r)["tenant"]
+	if userID == "" || userID == "." || userID == ".." {
+		http.Error(w, fmt.Sprintf("invalid tenant ID %q", userID), http.StatusBadRequest)
+		return "", false
+	}
+	if err := tenant.ValidTenantID(userID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return userID, true
+}
+
+// expectedVersion returns the version of the overrides expected by the request, which is set in the If-Match
+// header, or NoVersion if the If-None-Match header is "*".
+func expectedVersion(r *http.Request) (int64, error) {
+	if r.Header.Get("If-None-Match") == "*" {
+		return NoVersion, nil
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return AnyVersion, nil
+	}
+	version, err := strconv.ParseInt(strings.Trim(ifMatch, `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errors.Errorf("invalid If-Match header %q", ifMatch)
+	}
+	return version, nil
+}
+
+func readOverrides(w http.ResponseWriter, r *http.Request) (yaml.MapSlice, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the request body")
+	}
+
+	var overrides yaml.MapSlice
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, errors.Wrap(err, "the request body must be a map of limits")
+	}
+	return overrides, nil
+}
+
+// writeOverrides writes the overrides with the secrets redacted.
+func writeOverrides(w http.ResponseWriter, overrides TenantOverrides) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, overrides.Version))
+	w.Header().Set("Last-Modified", overrides.UpdatedAt.UTC().Format(http.TimeFormat))
+	if overrides.Overrides == nil {
+		overrides.Overrides = yaml.MapSlice{}
+	}
+	writeYAML(w, RedactSecrets(overrides.Overrides))
+}
+
+// writeYAML is like util.WriteYAMLResponse, but it marshals with yaml.v2 which the overrides are decoded with,
+// so that yaml.MapSlice is written as a map.
+func writeYAML(w http.ResponseWriter, v interface{}) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrVersionMismatch):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
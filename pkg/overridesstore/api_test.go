@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package overridesstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestAPI(t *testing.T) {
+	var defaults validation.Limits
+	flagext.DefaultValues(&defaults)
+	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
+
+	store := NewBucketStore(objstore.NewInMemBucket(), 0)
+	loader := NewLoader(store, time.Minute, log.NewNopLogger(), nil)
+	api := NewAPI(store, loader, nil, log.NewNopLogger())
+
+	t.Run("get missing overrides", func(t *testing.T) {
+		resp := call(api.GetOverrides, http.MethodGet, "user-1", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+
+	t.Run("invalid tenant", func(t *testing.T) {
+		resp := call(api.GetOverrides, http.MethodGet, "..", "", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
+	t.Run("set invalid overrides", func(t *testing.T) {
+		resp := call(api.SetOverrides, http.MethodPut, "user-1", "ingestion_rate: -1", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "invalid ingestion_rate")
+
+		resp = call(api.SetOverrides, http.MethodPut, "user-1", "unknown_limit: 1", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
+	t.Run("set, patch and delete overrides", func(t *testing.T) {
+		resp := call(api.SetOverrides, http.MethodPut, "user-1", "ingestion_rate: 100\ningestion_burst_size: 1000\n", map[string]string{"If-None-Match": "*"})
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		assert.Equal(t, `"1"`, resp.Header().Get("ETag"))
+
+		// The overrides are reloaded right after the change.
+		overrides, _ := loader.Overrides()
+		assert.Equal(t, yaml.MapSlice{{Key: "ingestion_rate", Value: 100}, {Key: "ingestion_burst_size", Value: 1000}}, overrides["user-1"])
+
+		// The overrides already exist.
+		resp = call(api.SetOverrides, http.MethodPut, "user-1", "ingestion_rate: 200", map[string]string{"If-None-Match": "*"})
+		assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+		resp = call(api.PatchOverrides, http.MethodPatch, "user-1", "ingestion_rate: 200\ningestion_burst_size: null\nmax_global_series_per_user: 10\n", map[string]string{"If-Match": `"1"`})
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+
+		resp = call(api.GetOverrides, http.MethodGet, "user-1", "", nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+		assert.Equal(t, "ingestion_rate: 200\nmax_global_series_per_user: 10\n", resp.Body.String())
+
+		// The version 1 is stale.
+		resp = call(api.PatchOverrides, http.MethodPatch, "user-1", "ingestion_rate: 300", map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+		resp = call(api.DeleteOverrides, http.MethodDelete, "user-1", "", map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+		resp = call(api.DeleteOverrides, http.MethodDelete, "user-1", "", map[string]string{"If-Match": `"2"`})
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		resp = call(api.GetOverrides, http.MethodGet, "user-1", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+
+		overrides, _ = loader.Overrides()
+		assert.NotContains(t, overrides, "user-1")
+	})
+
+	t.Run("audit log", func(t *testing.T) {
+		resp := call(api.SetOverrides, http.MethodPut, "user-2", "ingestion_rate: 100", nil)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		resp = call(api.PatchOverrides, http.MethodPatch, "user-2", "ingestion_rate: 200", nil)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+		resp = call(api.GetAuditLog, http.MethodGet, "user-2", "", nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var entries []AuditEntry
+		require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &entries))
+		require.Len(t, entries, 2)
+		assert.Equal(t, actionPatch, entries[0].Action)
+		assert.Equal(t, actionSet, entries[1].Action)
+
+		entries, err := store.AuditLog(context.Background(), "user-2", 1)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, int64(2), entries[0].Version)
+
+		// The audit log is deleted with the overrides.
+		resp = call(api.DeleteOverrides, http.MethodDelete, "user-2", "", nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		entries, err = store.AuditLog(context.Background(), "user-2", 0)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestAPI_ShouldForwardChangesToTheWriter(t *testing.T) {
+	var defaults validation.Limits
+	flagext.DefaultValues(&defaults)
+	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
+
+	bkt := objstore.NewInMemBucket()
+	writerStore := NewBucketStore(bkt, 0)
+	writer := NewAPI(writerStore, nil, nil, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/overrides/{tenant}").Methods(http.MethodPut).HandlerFunc(writer.SetOverrides)
+	router.Path("/overrides/{tenant}").Methods(http.MethodPatch).HandlerFunc(writer.PatchOverrides)
+	router.Path("/overrides/{tenant}").Methods(http.MethodDelete).HandlerFunc(writer.DeleteOverrides)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	writerURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	store := NewBucketStore(bkt, 0)
+	loader := NewLoader(store, time.Minute, log.NewNopLogger(), nil)
+	api := NewAPI(store, loader, writerURL, log.NewNopLogger())
+
+	resp := call(api.SetOverrides, http.MethodPut, "user-1", "ingestion_rate: 100", map[string]string{"If-None-Match": "*"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, `"1"`, resp.Header().Get("ETag"))
+
+	// The change has been written by the writer, and is reloaded right after the change by this instance too.
+	overrides, _ := loader.Overrides()
+	assert.Equal(t, yaml.MapSlice{{Key: "ingestion_rate", Value: 100}}, overrides["user-1"])
+
+	// The errors of the writer are returned.
+	resp = call(api.PatchOverrides, http.MethodPatch, "user-1", "ingestion_rate: 200", map[string]string{"If-Match": `"2"`})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	resp = call(api.DeleteOverrides, http.MethodDelete, "user-1", "", map[string]string{"If-Match": `"1"`})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	_, err = writerStore.Get(context.Background(), "user-1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// A change forwarded to an instance which isn't the writer isn't forwarded again.
+	resp = call(api.SetOverrides, http.MethodPut, "user-1", "ingestion_rate: 100", map[string]string{forwardedHeader: "true"})
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
+
+func call(handler http.HandlerFunc, method, tenant, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/overrides/"+tenant, strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"tenant": tenant})
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	return resp
+}
+
+func TestAPI_ShouldRedactSecrets(t *testing.T) {
+	var defaults validation.Limits
+	flagext.DefaultValues(&defaults)
+	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
+
+	assert.Equal(t, [][]string{
+		{"ruler_alertmanager_client", "basic_auth_password"},
+		{"ruler_alertmanager_client", "bearer_token"},
+	}, secretPaths)
+
+	store := NewBucketStore(objstore.NewInMemBucket(), 0)
+	api := NewAPI(store, nil, nil, log.NewNopLogger())
+
+	const overrides = `
+ruler_alertmanager_url: https://alertmanager.example.com
+ruler_alertmanager_client:
+  basic_auth_username: user
+  basic_auth_password: password
+`
+	const redacted = `ruler_alertmanager_url: https://alertmanager.example.com
+ruler_alertmanager_client:
+  basic_auth_username: user
+  basic_auth_password: '********'
+`
+	resp := call(api.SetOverrides, http.MethodPut, "user-1", overrides, nil)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, redacted, resp.Body.String())
+
+	resp = call(api.GetOverrides, http.MethodGet, "user-1", "", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, redacted, resp.Body.String())
+
+	entries, err := store.AuditLog(context.Background(), "user-1", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "********", entries[0].Overrides[1].Value.(yaml.MapSlice)[1].Value)
+
+	// The stored password isn't redacted.
+	stored, err := store.Get(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "password", stored.Overrides[1].Value.(yaml.MapSlice)[1].Value)
+
+	// The redacted overrides can be written back, keeping the stored password.
+	resp = call(api.SetOverrides, http.MethodPut, "user-1", redacted+"ingestion_rate: 100\n", nil)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	stored, err = store.Get(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "password", stored.Overrides[1].Value.(yaml.MapSlice)[1].Value)
+	assert.Equal(t, yaml.MapItem{Key: "ingestion_rate", Value: 100}, stored.Overrides[2])
+
+	// A redacted secret which isn't stored can't be restored.
+	resp = call(api.PatchOverrides, http.MethodPatch, "user-1", "ruler_alertmanager_client:\n  bearer_token: '********'\n", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestMergeOverrides(t *testing.T) {
+	base := yaml.MapSlice{{Key: "a", Value: 1}, {Key: "b", Value: 2}, {Key: "c", Value: 3}}
+	patch := yaml.MapSlice{{Key: "b", Value: nil}, {Key: "c", Value: 30}, {Key: "d", Value: 4}}
+
+	assert.Equal(t, yaml.MapSlice{{Key: "a", Value: 1}, {Key: "c", Value: 30}, {Key: "d", Value: 4}}, MergeOverrides(base, patch))
+	assert.Equal(t, base, MergeOverrides(base, nil))
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package overridesstore
+
+import (
+	"flag"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+var (
+	errInvalidPollInterval       = errors.New("the overrides storage poll interval must be greater than 0")
+	errInvalidAuditLogMaxEntries = errors.New("the overrides storage audit log max entries must be greater than 0")
+)
+
+// Config configures the storage of the per-tenant overrides written through the overrides API.
+type Config struct {
+	Enabled            bool             `yaml:"enabled" category:"experimental"`
+	PollInterval       time.Duration    `yaml:"poll_interval" category:"experimental"`
+	WriterURL          flagext.URLValue `yaml:"writer_url" category:"experimental"`
+	AuditLogMaxEntries int              `yaml:"audit_log_max_entries" category:"experimental"`
+	bucket.Config      `yaml:",inline"`
+}
+
+// RegisterFlags registers the overrides storage config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	prefix := "overrides-storage."
+
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "Enables the API to write per-tenant overrides, which are stored in the object storage and loaded by all components in addition to the runtime configuration file. The runtime configuration file takes precedence over the stored overrides for the limits it defines.")
+	f.DurationVar(&cfg.PollInterval, prefix+"poll-interval", 30*time.Second, "How frequently the stored overrides are reloaded from the object storage.")
+	f.Var(&cfg.WriterURL, prefix+"writer-url", "URL of the single instance which writes the stored overrides, to which the other instances forward the requests changing the overrides. The object storage doesn't support conditional writes, so concurrent changes are only safely detected when they're all written by the same instance. Leave empty on the writer instance only.")
+	f.IntVar(&cfg.AuditLogMaxEntries, prefix+"audit-log-max-entries", 1000, "Maximum number of entries kept in the audit log of each tenant. The oldest entries are removed.")
+	cfg.RegisterFlagsWithPrefixAndDefaultDirectory(prefix, "overrides", f)
+}
+
+// Validate the config.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.PollInterval <= 0 {
+		return errInvalidPollInterval
+	}
+	if cfg.AuditLogMaxEntries <= 0 {
+		return errInvalidAuditLogMaxEntries
+	}
+	return cfg.Config.Validate()
+}
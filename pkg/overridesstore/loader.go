@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package overridesstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/yaml.v2"
+)
+
+// Loader periodically loads the stored overrides of all the tenants, keeping them in memory.
+type Loader struct {
+	services.Service
+
+	store  *BucketStore
+	logger log.Logger
+
+	mtx        sync.RWMutex
+	overrides  map[string]yaml.MapSlice
+	generation uint64
+
+	lastLoadSuccess prometheus.Gauge
+	tenants         prometheus.Gauge
+}
+
+func NewLoader(store *BucketStore, pollInterval time.Duration, logger log.Logger, reg prometheus.Registerer) *Loader {
+	l := &Loader{
+		store:  store,
+		logger: logger,
+		lastLoadSuccess: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_overrides_storage_last_successful_load_timestamp_seconds",
+			Help: "Timestamp of the last successful load of the stored overrides.",
+		}),
+		tenants: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_overrides_storage_tenants",
+			Help: "Number of tenants with stored overrides.",
+		}),
+	}
+
+	l.Service = services.NewTimerService(pollInterval, l.starting, l.iteration, nil)
+	return l
+}
+
+func (l *Loader) starting(ctx context.Context) error {
+	// Fail the startup if the overrides can't be loaded, like the runtime config does,
+	// to not run with the default limits.
+	return l.Load(ctx)
+}
+
+func (l *Loader) iteration(ctx context.Context) error {
+	if err := l.Load(ctx); err != nil {
+		level.Warn(l.logger).Log("msg", "failed to load the stored overrides", "err", err)
+	}
+	return nil
+}
+
+// Load loads the stored overrides.
+func (l *Loader) Load(ctx context.Context) error {
+	stored, err := l.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	overrides := make(map[string]yaml.MapSlice, len(stored))
+	for tenant, o := range stored {
+		overrides[tenant] = o.Overrides
+	}
+
+	l.mtx.Lock()
+	l.overrides = overrides
+	l.generation++
+	l.mtx.Unlock()
+
+	l.lastLoadSuccess.SetToCurrentTime()
+	l.tenants.Set(float64(len(overrides)))
+	return nil
+}
+
+// Overrides returns the stored overrides by tenant, and a generation number which changes whenever they're reloaded.
+// The returned map must not be modified.
+func (l *Loader) Overrides() (map[string]yaml.MapSlice, uint64) {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	return l.overrides, l.generation
+}
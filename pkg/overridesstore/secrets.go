@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package overridesstore
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// redactedSecret replaces the secrets in the returned overrides, like flagext.Secret does when marshalled.
+const redactedSecret = "********"
+
+// secretPaths are the YAML paths of the secret limits, e.g. ruler_alertmanager_client.bearer_token.
+var secretPaths = findSecretPaths(reflect.TypeOf(validation.Limits{}), nil)
+
+func findSecretPaths(t reflect.Type, prefix []string) [][]string {
+	var paths [][]string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		path := append(append([]string(nil), prefix...), name)
+		switch {
+		case field.Type == reflect.TypeOf(flagext.Secret{}):
+			paths = append(paths, path)
+		case field.Type.Kind() == reflect.Struct:
+			paths = append(paths, findSecretPaths(field.Type, path)...)
+		}
+	}
+	return paths
+}
+
+// RedactSecrets returns a copy of the overrides with the secrets redacted.
+func RedactSecrets(overrides yaml.MapSlice) yaml.MapSlice {
+	for _, path := range secretPaths {
+		overrides = replaceValue(overrides, path, func(value interface{}) interface{} {
+			if value == nil || fmt.Sprint(value) == "" {
+				return value
+			}
+			return redactedSecret
+		})
+	}
+	return overrides
+}
+
+// hasRedactedSecrets returns whether any secret of the overrides is redacted.
+func hasRedactedSecrets(overrides yaml.MapSlice) bool {
+	for _, path := range secretPaths {
+		if value, ok := lookupValue(overrides, path); ok && value == redactedSecret {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreSecrets returns a copy of the overrides with the redacted secrets replaced by the secrets of the stored
+// overrides, so that the overrides previously returned by the API can be changed and written back.
+func restoreSecrets(overrides, stored yaml.MapSlice) (yaml.MapSlice, error) {
+	for _, path := range secretPaths {
+		if value, ok := lookupValue(overrides, path); !ok || value != redactedSecret {
+			continue
+		}
+
+		storedValue, ok := lookupValue(stored, path)
+		if !ok || storedValue == nil {
+			return nil, errors.Errorf("%s is redacted but it isn't set in the stored overrides", strings.Join(path, "."))
+		}
+		overrides = replaceValue(overrides, path, func(interface{}) interface{} {
+			return storedValue
+		})
+	}
+	return overrides, nil
+}
+
+// replaceValue returns a copy of the overrides with the value at path replaced by the output of replace.
+// The overrides are returned unchanged if they have no value at path.
+func replaceValue(overrides yaml.MapSlice, path []string, replace func(interface{}) interface{}) yaml.MapSlice {
+	for i, item := range overrides {
+		if fmt.Sprint(item.Key) != path[0] {
+			continue
+		}
+
+		value := item.Value
+		if len(path) == 1 {
+			value = replace(value)
+		} else if nested, ok := value.(yaml.MapSlice); ok {
+			value = replaceValue(nested, path[1:], replace)
+		} else {
+			return overrides
+		}
+
+		out := make(yaml.MapSlice, len(overrides))
+		copy(out, overrides)
+		out[i].Value = value
+		return out
+	}
+	return overrides
+}
+
+func lookupValue(overrides yaml.MapSlice, path []string) (interface{}, bool) {
+	for _, item := range overrides {
+		if fmt.Sprint(item.Key) != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return item.Value, true
+		}
+		if nested, ok := item.Value.(yaml.MapSlice); ok {
+			return lookupValue(nested, path[1:])
+		}
+		return nil, false
+	}
+	return nil, false
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package overridesstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// overridesPrefix is the prefix of the objects storing the overrides, named <tenant>.yaml.
+	overridesPrefix = "overrides/"
+	// auditPrefix is the prefix of the audit log entries, named <tenant>/<timestamp>.yaml.
+	auditPrefix = "audit/"
+	objectExt   = ".yaml"
+
+	// AnyVersion disables the version check when writing overrides.
+	AnyVersion int64 = -1
+	// NoVersion requires the overrides to not exist when writing them.
+	NoVersion int64 = 0
+)
+
+var (
+	ErrNotFound        = errors.New("the tenant has no stored overrides")
+	ErrVersionMismatch = errors.New("the stored overrides have been changed since the given version")
+)
+
+// TenantOverrides are the overrides of a tenant stored in the bucket. Overrides only contains the limits
+// set for the tenant, so that the other limits keep following the defaults.
+type TenantOverrides struct {
+	// Version is incremented at each change of the overrides, and is used to detect concurrent changes.
+	Version   int64         `yaml:"version"`
+	UpdatedAt time.Time     `yaml:"updated_at"`
+	Overrides yaml.MapSlice `yaml:"overrides"`
+}
+
+// AuditEntry records a change of the overrides of a tenant.
+type AuditEntry struct {
+	Timestamp time.Time `yaml:"timestamp"`
+	Action    string    `yaml:"action"`
+	// Version is the version of the overrides after the change, or the deleted version.
+	Version   int64  `yaml:"version"`
+	Source    string `yaml:"source"`
+	UserAgent string `yaml:"user_agent"`
+	// Overrides are the overrides after the change, empty if they've been deleted.
+	Overrides yaml.MapSlice `yaml:"overrides,omitempty"`
+}
+
+// BucketStore stores the per-tenant overrides in a bucket.
+type BucketStore struct {
+	bkt                objstore.Bucket
+	auditLogMaxEntries int
+
+	// mtx serializes the changes done through this store. The object storage doesn't support conditional writes,
+	// so a change done concurrently by another instance, between the version check and the write, could be
+	// overwritten: all the changes must be done by a single instance, to which the other ones forward them.
+	mtx sync.Mutex
+}
+
+// NewBucketStore returns a new BucketStore keeping up to auditLogMaxEntries entries in the audit log of each tenant,
+// or all of them if auditLogMaxEntries is 0.
+func NewBucketStore(bkt objstore.Bucket, auditLogMaxEntries int) *BucketStore {
+	return &BucketStore{bkt: bkt, auditLogMaxEntries: auditLogMaxEntries}
+}
+
+// Get returns the overrides of the tenant, or ErrNotFound.
+func (s *BucketStore) Get(ctx context.Context, tenant string) (TenantOverrides, error) {
+	var overrides TenantOverrides
+	if err := s.read(ctx, overridesPrefix+tenant+objectExt, &overrides); err != nil {
+		if s.bkt.IsObjNotFoundErr(errors.Cause(err)) {
+			return TenantOverrides{}, ErrNotFound
+		}
+		return TenantOverrides{}, errors.Wrapf(err, "failed to read the overrides of tenant %s", tenant)
+	}
+	return overrides, nil
+}
+
+// List returns the overrides of all the tenants.
+func (s *BucketStore) List(ctx context.Context) (map[string]TenantOverrides, error) {
+	var tenants []string
+	err := s.bkt.Iter(ctx, overridesPrefix, func(name string) error {
+		if strings.HasSuffix(name, objectExt) {
+			tenants = append(tenants, strings.TrimSuffix(strings.TrimPrefix(name, overridesPrefix), objectExt))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the stored overrides")
+	}
+
+	out := make(map[string]TenantOverrides, len(tenants))
+	for _, tenant := range tenants {
+		overrides, err := s.Get(ctx, tenant)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted in the meanwhile.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[tenant] = overrides
+	}
+	return out, nil
+}
+
+// Set writes the overrides of the tenant if the stored overrides have the expected version, which is NoVersion
+// if they must not exist, or AnyVersion to skip the check. It returns ErrVersionMismatch if the versions differ.
+func (s *BucketStore) Set(ctx context.Context, tenant string, overrides yaml.MapSlice, expectedVersion int64, now time.Time) (TenantOverrides, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	current, err := s.Get(ctx, tenant)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return TenantOverrides{}, err
+	}
+	if expectedVersion != AnyVersion && current.Version != expectedVersion {
+		return TenantOverrides{}, ErrVersionMismatch
+	}
+
+	updated := TenantOverrides{
+		Version:   current.Version + 1,
+		UpdatedAt: now.UTC(),
+		Overrides: overrides,
+	}
+	if err := s.write(ctx, overridesPrefix+tenant+objectExt, updated); err != nil {
+		return TenantOverrides{}, errors.Wrapf(err, "failed to write the overrides of tenant %s", tenant)
+	}
+	return updated, nil
+}
+
+// Delete deletes the overrides of the tenant, checking the version like Set. It returns the deleted overrides.
+func (s *BucketStore) Delete(ctx context.Context, tenant string, expectedVersion int64) (TenantOverrides, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	current, err := s.Get(ctx, tenant)
+	if err != nil {
+		return TenantOverrides{}, err
+	}
+	if expectedVersion != AnyVersion && current.Version != expectedVersion {
+		return TenantOverrides{}, ErrVersionMismatch
+	}
+
+	if err := s.bkt.Delete(ctx, overridesPrefix+tenant+objectExt); err != nil && !s.bkt.IsObjNotFoundErr(err) {
+		return TenantOverrides{}, errors.Wrapf(err, "failed to delete the overrides of tenant %s", tenant)
+	}
+	return current, nil
+}
+
+// AddAuditEntry appends the entry to the audit log of the tenant, and removes the oldest entries
+// exceeding the max number of entries.
+func (s *BucketStore) AddAuditEntry(ctx context.Context, tenant string, entry AuditEntry) error {
+	name := path.Join(auditPrefix, tenant, fmt.Sprintf("%020d%s", entry.Timestamp.UnixNano(), objectExt))
+	if err := s.write(ctx, name, entry); err != nil {
+		return errors.Wrapf(err, "failed to write the audit log entry of tenant %s", tenant)
+	}
+
+	if s.auditLogMaxEntries <= 0 {
+		return nil
+	}
+	names, err := s.auditLogEntries(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	if len(names) <= s.auditLogMaxEntries {
+		return nil
+	}
+	return errors.Wrapf(s.deleteObjects(ctx, names[s.auditLogMaxEntries:]), "failed to delete the oldest audit log entries of tenant %s", tenant)
+}
+
+// DeleteAuditLog deletes all the entries of the audit log of the tenant.
+func (s *BucketStore) DeleteAuditLog(ctx context.Context, tenant string) error {
+	names, err := s.auditLogEntries(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(s.deleteObjects(ctx, names), "failed to delete the audit log of tenant %s", tenant)
+}
+
+// AuditLog returns the audit log of the tenant, from the newest to the oldest entry, up to limit entries.
+func (s *BucketStore) AuditLog(ctx context.Context, tenant string, limit int) ([]AuditEntry, error) {
+	names, err := s.auditLogEntries(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+
+	entries := make([]AuditEntry, 0, len(names))
+	for _, name := range names {
+		var entry AuditEntry
+		if err := s.read(ctx, name, &entry); err != nil {
+			return nil, errors.Wrapf(err, "failed to read the audit log entry %s", name)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// auditLogEntries returns the object names of the audit log entries of the tenant, from the newest to the oldest.
+func (s *BucketStore) auditLogEntries(ctx context.Context, tenant string) ([]string, error) {
+	var names []string
+	err := s.bkt.Iter(ctx, path.Join(auditPrefix, tenant)+"/", func(name string) error {
+		if strings.HasSuffix(name, objectExt) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the audit log of tenant %s", tenant)
+	}
+
+	// Names are zero-padded timestamps, so the lexicographical order is the chronological one.
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+func (s *BucketStore) deleteObjects(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if err := s.bkt.Delete(ctx, name); err != nil && !s.bkt.IsObjNotFoundErr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BucketStore) read(ctx context.Context, name string, out interface{}) error {
+	reader, err := s.bkt.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+func (s *BucketStore) write(ctx context.Context, name string, in interface{}) error {
+	data, err := yaml.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return s.bkt.Upload(ctx, name, bytes.NewReader(data))
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package overridesstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v2"
+)
+
+func TestBucketStore_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	store := NewBucketStore(objstore.NewInMemBucket(), 0)
+	now := time.Now()
+
+	_, err := store.Get(ctx, "user-1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// The overrides can't be updated if they don't exist yet.
+	_, err = store.Set(ctx, "user-1", yaml.MapSlice{{Key: "ingestion_rate", Value: 10}}, 1, now)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	created, err := store.Set(ctx, "user-1", yaml.MapSlice{{Key: "ingestion_rate", Value: 10}}, NoVersion, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.Version)
+
+	// The overrides can't be created twice.
+	_, err = store.Set(ctx, "user-1", yaml.MapSlice{{Key: "ingestion_rate", Value: 20}}, NoVersion, now)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	updated, err := store.Set(ctx, "user-1", yaml.MapSlice{{Key: "ingestion_rate", Value: 20}}, created.Version, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	// The previous version is stale.
+	_, err = store.Set(ctx, "user-1", yaml.MapSlice{{Key: "ingestion_rate", Value: 30}}, created.Version, now)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	actual, err := store.Get(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), actual.Version)
+	assert.Equal(t, yaml.MapSlice{{Key: "ingestion_rate", Value: 20}}, actual.Overrides)
+
+	_, err = store.Set(ctx, "user-2", yaml.MapSlice{{Key: "max_global_series_per_user", Value: 1000}}, AnyVersion, now)
+	require.NoError(t, err)
+
+	all, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+	assert.Equal(t, int64(2), all["user-1"].Version)
+	assert.Equal(t, int64(1), all["user-2"].Version)
+
+	_, err = store.Delete(ctx, "user-1", created.Version)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	deleted, err := store.Delete(ctx, "user-1", updated.Version)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted.Version)
+
+	_, err = store.Get(ctx, "user-1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.Delete(ctx, "user-1", AnyVersion)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	all, err = store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
+
+func TestBucketStore_AuditLog(t *testing.T) {
+	ctx := context.Background()
+	store := NewBucketStore(objstore.NewInMemBucket(), 0)
+	now := time.Now().UTC()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, store.AddAuditEntry(ctx, "user-1", AuditEntry{
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Action:    actionSet,
+			Version:   int64(i),
+		}))
+	}
+	require.NoError(t, store.AddAuditEntry(ctx, "user-2", AuditEntry{Timestamp: now, Action: actionDelete, Version: 1}))
+
+	entries, err := store.AuditLog(ctx, "user-1", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, entry := range entries {
+		assert.Equal(t, int64(3-i), entry.Version)
+	}
+
+	entries, err = store.AuditLog(ctx, "user-1", 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(3), entries[0].Version)
+	assert.Equal(t, int64(2), entries[1].Version)
+
+	entries, err = store.AuditLog(ctx, "user-3", 0)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestBucketStore_AuditLogMaxEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewBucketStore(objstore.NewInMemBucket(), 2)
+	now := time.Now().UTC()
+
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, store.AddAuditEntry(ctx, "user-1", AuditEntry{
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Action:    actionSet,
+			Version:   int64(i),
+		}))
+	}
+	require.NoError(t, store.AddAuditEntry(ctx, "user-2", AuditEntry{Timestamp: now, Action: actionSet, Version: 1}))
+
+	// Only the newest entries are kept.
+	entries, err := store.AuditLog(ctx, "user-1", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(4), entries[0].Version)
+	assert.Equal(t, int64(3), entries[1].Version)
+
+	require.NoError(t, store.DeleteAuditLog(ctx, "user-1"))
+	entries, err = store.AuditLog(ctx, "user-1", 0)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// The audit log of the other tenants is kept.
+	entries, err = store.AuditLog(ctx, "user-2", 0)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
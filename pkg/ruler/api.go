@@ -7,6 +7,7 @@ package ruler
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -30,6 +31,7 @@ import (
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 	util_log "github.com/grafana/mimir/pkg/util/log"
 )
 
@@ -296,6 +298,10 @@ var (
 	ErrNoRuleGroups = errors.New("no rule groups found")
 	// ErrBadRuleGroup is returned when the provided rule group can not be unmarshalled
 	ErrBadRuleGroup = errors.New("unable to decoded rule group")
+	// ErrBadVersion is returned when the provided rule group version can not be parsed
+	ErrBadVersion = errors.New("invalid rule group version")
+	// ErrDeletedVersion is returned when the requested rule group version records the deletion of the rule group
+	ErrDeletedVersion = errors.New("the version records the deletion of the rule group")
 )
 
 func marshalAndSend(output interface{}, w http.ResponseWriter, logger log.Logger) {
@@ -507,7 +513,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 	rgProto := rulespb.ToProto(userID, namespace, rg)
 
 	level.Debug(logger).Log("msg", "attempting to store rulegroup", "userID", userID, "group", rgProto.String())
-	err = a.store.SetRuleGroup(req.Context(), userID, namespace, rgProto)
+	err = a.store.SetRuleGroup(versioning.ContextWithAuthorFromRequest(req), userID, namespace, rgProto)
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to store rule group", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -526,7 +532,7 @@ func (a *API) DeleteNamespace(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err = a.store.DeleteNamespace(req.Context(), userID, namespace)
+	err = a.store.DeleteNamespace(versioning.ContextWithAuthorFromRequest(req), userID, namespace)
 	if err != nil {
 		if err == rulestore.ErrGroupNamespaceNotFound {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -548,7 +554,7 @@ func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err = a.store.DeleteRuleGroup(req.Context(), userID, namespace, groupName)
+	err = a.store.DeleteRuleGroup(versioning.ContextWithAuthorFromRequest(req), userID, namespace, groupName)
 	if err != nil {
 		if err == rulestore.ErrGroupNotFound {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -560,3 +566,185 @@ func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
 
 	respondAccepted(w, logger)
 }
+
+func (a *API) ListRuleGroupVersions(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, groupName, err := parseRequest(req, true, true)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	versions, err := a.store.ListRuleGroupVersions(req.Context(), userID, namespace, groupName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if versions == nil {
+		versions = []versioning.Version{}
+	}
+	marshalAndSend(versions, w, logger)
+}
+
+func (a *API) GetRuleGroupVersion(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, groupName, err := parseRequest(req, true, true)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	id, err := parseVersion(mux.Vars(req)["version"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, rg, ok := a.getRuleGroupVersion(w, req, userID, namespace, groupName, id)
+	if !ok {
+		return
+	}
+	if rg == nil {
+		http.Error(w, ErrDeletedVersion.Error(), http.StatusNotFound)
+		return
+	}
+
+	formatted := rulespb.FromProto(rg)
+	marshalAndSend(formatted, w, logger)
+}
+
+// DiffRuleGroupVersions responds with the unified diff between the two versions of the rule group
+// passed as "from" and "to" query parameters.
+func (a *API) DiffRuleGroupVersions(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, groupName, err := parseRequest(req, true, true)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	var (
+		versions [2]versioning.Version
+		texts    [2]string
+	)
+	for i, param := range []string{"from", "to"} {
+		id, err := parseVersion(req.FormValue(param))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s: %s", param, err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		v, rg, ok := a.getRuleGroupVersion(w, req, userID, namespace, groupName, id)
+		if !ok {
+			return
+		}
+		versions[i] = v
+
+		// A version recording the deletion of the rule group is diffed as an empty rule group.
+		if rg != nil {
+			d, err := yaml.Marshal(rulespb.FromProto(rg))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			texts[i] = string(d)
+		}
+	}
+
+	diff, err := versioning.Diff(versions[0], texts[0], versions[1], texts[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(diff)); err != nil {
+		level.Error(logger).Log("msg", "error writing diff response", "err", err)
+	}
+}
+
+// RollbackRuleGroup stores a previous version of the rule group as its new version.
+func (a *API) RollbackRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, groupName, err := parseRequest(req, true, true)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	id, err := parseVersion(mux.Vars(req)["version"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, rg, ok := a.getRuleGroupVersion(w, req, userID, namespace, groupName, id)
+	if !ok {
+		return
+	}
+	if rg == nil {
+		http.Error(w, ErrDeletedVersion.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The limits may have changed since the version was stored.
+	if err := a.ruler.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to fetch current rule groups for validation", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	numRuleGroups := len(rgs) + 1
+	for _, g := range rgs {
+		if g.Namespace == namespace && g.Name == groupName {
+			numRuleGroups--
+			break
+		}
+	}
+	if err := a.ruler.AssertMaxRuleGroups(userID, numRuleGroups); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(logger).Log("msg", "rolling back rulegroup", "userID", userID, "namespace", namespace, "group", groupName, "version", id)
+	err = a.store.SetRuleGroup(versioning.ContextWithAuthorFromRequest(req), userID, namespace, rg)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to store rule group", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondAccepted(w, logger)
+}
+
+// getRuleGroupVersion returns a version of the rule group, or responds with an error and returns false.
+func (a *API) getRuleGroupVersion(w http.ResponseWriter, req *http.Request, userID, namespace, groupName string, id int) (versioning.Version, *rulespb.RuleGroupDesc, bool) {
+	v, rg, err := a.store.GetRuleGroupVersion(req.Context(), userID, namespace, groupName, id)
+	if err != nil {
+		if errors.Is(err, versioning.ErrVersionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return v, nil, false
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return v, nil, false
+	}
+	return v, rg, true
+}
+
+// parseVersion parses a rule group version ID.
+func parseVersion(value string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, ErrBadVersion
+	}
+	return id, nil
+}
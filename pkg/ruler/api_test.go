@@ -20,9 +20,13 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/grafana/dskit/services"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
 	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 func TestRuler(t *testing.T) {
@@ -514,6 +518,78 @@ rules:
 	}
 }
 
+func TestRuler_RuleGroupVersions(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, 10, log.NewNopLogger()))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules/{namespace}").Methods(http.MethodPost).HandlerFunc(a.CreateRuleGroup)
+	router.Path("/api/v1/rules/{namespace}/{groupName}").Methods(http.MethodGet).HandlerFunc(a.GetRuleGroup)
+	router.Path("/api/v1/rules/{namespace}/{groupName}/versions").Methods(http.MethodGet).HandlerFunc(a.ListRuleGroupVersions)
+	router.Path("/api/v1/rules/{namespace}/{groupName}/versions/{version}").Methods(http.MethodGet).HandlerFunc(a.GetRuleGroupVersion)
+	router.Path("/api/v1/rules/{namespace}/{groupName}/versions/{version}/rollback").Methods(http.MethodPost).HandlerFunc(a.RollbackRuleGroup)
+	router.Path("/api/v1/rules/{namespace}/{groupName}/diff").Methods(http.MethodGet).HandlerFunc(a.DiffRuleGroupVersions)
+
+	do := func(method, url, body, author string) *httptest.ResponseRecorder {
+		req := requestFor(t, method, "https://localhost:8080"+url, strings.NewReader(body), "user1")
+		if author != "" {
+			req.Header.Set(versioning.AuthorHeader, author)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Store two versions of the rule group.
+	w := do(http.MethodPost, "/api/v1/rules/namespace", "name: test\ninterval: 15s\nrules:\n- record: up_rule\n  expr: up\n", "alice")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	w = do(http.MethodPost, "/api/v1/rules/namespace", "name: test\ninterval: 30s\nrules:\n- record: up_rule\n  expr: up\n", "bob")
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = do(http.MethodGet, "/api/v1/rules/namespace/test/versions", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var versions []versioning.Version
+	require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &versions))
+	require.Len(t, versions, 2)
+	require.Equal(t, []string{"alice", "bob"}, []string{versions[0].Author, versions[1].Author})
+
+	w = do(http.MethodGet, "/api/v1/rules/namespace/test/versions/1", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "name: test\ninterval: 15s\nrules:\n    - record: up_rule\n      expr: up\n", w.Body.String())
+
+	w = do(http.MethodGet, "/api/v1/rules/namespace/test/diff?from=1&to=2", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "--- version 1 by alice\n+++ version 2 by bob\n@@ -1,5 +1,5 @@\n name: test\n-interval: 15s\n+interval: 30s\n rules:\n     - record: up_rule\n       expr: up\n", w.Body.String())
+
+	// Roll back to the first version.
+	w = do(http.MethodPost, "/api/v1/rules/namespace/test/versions/1/rollback", "", "carol")
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = do(http.MethodGet, "/api/v1/rules/namespace/test", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "name: test\ninterval: 15s\nrules:\n    - record: up_rule\n      expr: up\n", w.Body.String())
+
+	w = do(http.MethodGet, "/api/v1/rules/namespace/test/versions", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &versions))
+	require.Len(t, versions, 3)
+	require.Equal(t, "carol", versions[2].Author)
+
+	// Invalid and missing versions.
+	w = do(http.MethodGet, "/api/v1/rules/namespace/test/versions/invalid", "", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodGet, "/api/v1/rules/namespace/test/versions/10", "", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = do(http.MethodGet, "/api/v1/rules/namespace/test/diff?from=1", "", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPost, "/api/v1/rules/namespace/test/versions/10/rollback", "", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
 func requestFor(t *testing.T, method string, url string, body io.Reader, userID string) *http.Request {
 	t.Helper()
 
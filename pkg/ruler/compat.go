@@ -12,6 +12,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/prometheus/model/exemplar"
@@ -27,6 +28,7 @@ import (
 	"github.com/grafana/mimir/pkg/querier"
 	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 // Pusher is an ingester server that accepts pushes.
@@ -119,6 +121,12 @@ type RulesLimits interface {
 	RulerTenantShardSize(userID string) int
 	RulerMaxRuleGroupsPerTenant(userID string) int
 	RulerMaxRulesPerRuleGroup(userID string) int
+	RulerMaxIndependentRuleEvaluationConcurrencyPerTenant(userID string) int
+	RulerAlertmanagerURL(userID string) string
+	RulerAlertmanagerClientConfig(userID string) validation.RulerAlertmanagerClientConfig
+	RulerExternalLabels(userID string) labels.Labels
+	AlertmanagerReceiversBlockCIDRNetworks(userID string) []flagext.CIDR
+	AlertmanagerReceiversBlockPrivateAddresses(userID string) bool
 }
 
 func MetricsQueryFunc(qf rules.QueryFunc, queries, failedQueries prometheus.Counter) rules.QueryFunc {
@@ -232,6 +240,11 @@ func DefaultTenantManagerFactory(cfg Config, p Pusher, queryable, federatedQuery
 		Name: "cortex_ruler_queries_failed_total",
 		Help: "Number of failed queries by ruler.",
 	})
+	var concurrencyController *MultiTenantConcurrencyController
+	if cfg.MaxIndependentRuleEvaluationConcurrency > 0 {
+		concurrencyController = NewMultiTenantConcurrencyController(cfg.MaxIndependentRuleEvaluationConcurrency, overrides)
+	}
+
 	var rulerQuerySeconds *prometheus.CounterVec
 	if cfg.EnableQueryStats {
 		rulerQuerySeconds = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
@@ -261,12 +274,25 @@ func DefaultTenantManagerFactory(cfg Config, p Pusher, queryable, federatedQuery
 		regularQueryFunc := wrapQueryable(queryable)
 		federatedQueryFunc := wrapQueryable(federatedQueryable)
 
-		return rules.NewManager(&rules.ManagerOptions{
+		var tenantConcurrencyController *TenantConcurrencyController
+		if concurrencyController != nil {
+			tenantConcurrencyController = concurrencyController.NewTenantConcurrencyControllerFor(userID, reg)
+		}
+
+		// The rules are deliberately not labelled individually: a histogram per rule would multiply
+		// the series of each tenant by the number of its rules.
+		ruleEvalDuration := promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_ruler_rule_evaluation_duration_seconds",
+			Help:    "The duration of the evaluation of each rule of the rule group. The durations of all the rules of a group are observed in the same histogram, without a per-rule label.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"rule_group"})
+
+		manager := rules.NewManager(&rules.ManagerOptions{
 			Appendable:                 NewPusherAppendable(p, userID, overrides, totalWrites, failedWrites),
 			Queryable:                  queryable,
-			QueryFunc:                  TenantFederationQueryFunc(regularQueryFunc, federatedQueryFunc),
+			QueryFunc:                  ConcurrentQueryFunc(TenantFederationQueryFunc(regularQueryFunc, federatedQueryFunc)),
 			Context:                    user.InjectOrgID(ctx, userID),
-			GroupEvaluationContextFunc: FederatedGroupContextFunc,
+			GroupEvaluationContextFunc: GroupEvaluationContextFunc(tenantConcurrencyController, ruleEvalDuration),
 			ExternalURL:                cfg.ExternalURL.URL,
 			NotifyFunc:                 SendAlerts(notifier, cfg.ExternalURL.URL.String()),
 			Logger:                     log.With(logger, "user", userID),
@@ -280,7 +306,36 @@ func DefaultTenantManagerFactory(cfg Config, p Pusher, queryable, federatedQuery
 				return overrides.EvaluationDelay(userID)
 			},
 		})
+
+		return &groupMetricsCleaner{Manager: manager, ruleEvalDuration: ruleEvalDuration}
+	}
+}
+
+// groupMetricsCleaner deletes the per rule group metrics registered by the ruler
+// when the rule groups are removed from the manager.
+type groupMetricsCleaner struct {
+	*rules.Manager
+
+	ruleEvalDuration *prometheus.HistogramVec
+}
+
+// Update implements RulesManager.
+func (m *groupMetricsCleaner) Update(interval time.Duration, files []string, externalLabels labels.Labels, externalURL string) error {
+	oldGroups := map[string]struct{}{}
+	for _, g := range m.Manager.RuleGroups() {
+		oldGroups[rules.GroupKey(g.File(), g.Name())] = struct{}{}
 	}
+
+	err := m.Manager.Update(interval, files, externalLabels, externalURL)
+
+	for _, g := range m.Manager.RuleGroups() {
+		delete(oldGroups, rules.GroupKey(g.File(), g.Name()))
+	}
+	for key := range oldGroups {
+		m.ruleEvalDuration.DeleteLabelValues(key)
+	}
+
+	return err
 }
 
 type QueryableError struct {
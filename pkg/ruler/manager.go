@@ -17,7 +17,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
-	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
 	promRules "github.com/prometheus/prometheus/rules"
@@ -26,11 +25,13 @@ import (
 	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	util_net "github.com/grafana/mimir/pkg/util/net"
 )
 
 type DefaultMultiTenantManager struct {
 	cfg            Config
-	notifierCfg    *config.Config
+	limits         RulesLimits
+	dnsResolver    cacheutil.AddressProvider
 	managerFactory ManagerFactory
 
 	mapper *mapper
@@ -53,9 +54,9 @@ type DefaultMultiTenantManager struct {
 	logger                        log.Logger
 }
 
-func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg prometheus.Registerer, logger log.Logger, dnsResolver cacheutil.AddressProvider) (*DefaultMultiTenantManager, error) {
-	ncfg, err := buildNotifierConfig(&cfg, dnsResolver)
-	if err != nil {
+func NewDefaultMultiTenantManager(cfg Config, limits RulesLimits, managerFactory ManagerFactory, reg prometheus.Registerer, logger log.Logger, dnsResolver cacheutil.AddressProvider) (*DefaultMultiTenantManager, error) {
+	// Validate the global notifier config upfront. The config of each tenant is built when its notifier is created.
+	if _, err := buildNotifierConfig(&cfg, dnsResolver); err != nil {
 		return nil, err
 	}
 
@@ -66,7 +67,8 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg
 
 	return &DefaultMultiTenantManager{
 		cfg:                cfg,
-		notifierCfg:        ncfg,
+		limits:             limits,
+		dnsResolver:        dnsResolver,
 		managerFactory:     managerFactory,
 		notifiers:          map[string]*rulerNotifier{},
 		mapper:             newMapper(cfg.RulePath, logger),
@@ -109,6 +111,7 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 
 	for userID, ruleGroup := range ruleGroups {
 		r.syncRulesToManager(ctx, userID, ruleGroup)
+		r.syncNotifier(userID)
 	}
 
 	// Check for deleted users and remove them
@@ -213,14 +216,14 @@ func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifie
 			defer sp.Finish()
 			ctx = ot.ContextWithSpan(ctx, sp)
 			_ = ot.GlobalTracer().Inject(sp.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(req.Header))
-			return ctxhttp.Do(ctx, client, req)
+			return ctxhttp.Do(ctx, n.httpClient(client), req)
 		},
 	}, log.With(r.logger, "user", userID))
 
 	n.run()
 
-	// This should never fail, unless there's a programming mistake.
-	if err := n.applyConfig(r.notifierCfg); err != nil {
+	if err := r.applyNotifierConfig(userID, n, getTenantNotifierOverrides(userID, r.limits)); err != nil {
+		n.stop()
 		return nil, err
 	}
 
@@ -228,6 +231,45 @@ func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifie
 	return n.notifier, nil
 }
 
+// syncNotifier applies the notifier config of the tenant again if the tenant's notifier overrides changed
+// since it was applied.
+func (r *DefaultMultiTenantManager) syncNotifier(userID string) {
+	r.notifiersMtx.Lock()
+	defer r.notifiersMtx.Unlock()
+
+	n, ok := r.notifiers[userID]
+	if !ok {
+		return
+	}
+
+	overrides := getTenantNotifierOverrides(userID, r.limits)
+	if overrides.equal(n.overrides) {
+		return
+	}
+
+	if err := r.applyNotifierConfig(userID, n, overrides); err != nil {
+		// The notifier keeps running with the previous config.
+		level.Error(r.logger).Log("msg", "unable to update the notifier config", "user", userID, "err", err)
+		return
+	}
+	level.Info(r.logger).Log("msg", "updated the notifier config", "user", userID)
+}
+
+func (r *DefaultMultiTenantManager) applyNotifierConfig(userID string, n *rulerNotifier, overrides tenantNotifierOverrides) error {
+	firewallDialer := util_net.NewFirewallDialer(notifierFirewallConfigProvider{userID: userID, limits: r.limits})
+
+	ncfg, client, err := buildTenantNotifierConfig(&r.cfg, r.dnsResolver, overrides, firewallDialer)
+	if err != nil {
+		return err
+	}
+	if err := n.applyConfig(ncfg, client); err != nil {
+		return err
+	}
+
+	n.overrides = overrides
+	return nil
+}
+
 func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
 	var groups []*promRules.Group
 	r.userManagerMtx.Lock()
@@ -27,6 +27,12 @@ type ManagerMetrics struct {
 	GroupLastDuration    *prometheus.Desc
 	GroupRules           *prometheus.Desc
 	GroupLastEvalSamples *prometheus.Desc
+	RuleEvalDuration     *prometheus.Desc
+
+	IndependentRuleEvalConcurrencySlotsInUse         *prometheus.Desc
+	IndependentRuleEvalConcurrencyAttemptsStarted    *prometheus.Desc
+	IndependentRuleEvalConcurrencyAttemptsIncomplete *prometheus.Desc
+	IndependentRuleEvalConcurrencyAttemptsCompleted  *prometheus.Desc
 }
 
 // NewManagerMetrics returns a ManagerMetrics struct
@@ -100,6 +106,36 @@ func NewManagerMetrics() *ManagerMetrics {
 			[]string{"user", "rule_group"},
 			nil,
 		),
+		RuleEvalDuration: prometheus.NewDesc(
+			"cortex_ruler_rule_evaluation_duration_seconds",
+			"The duration of the evaluation of each rule of the rule group. The durations of all the rules of a group are observed in the same histogram, without a per-rule label.",
+			[]string{"user", "rule_group"},
+			nil,
+		),
+		IndependentRuleEvalConcurrencySlotsInUse: prometheus.NewDesc(
+			"cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use",
+			"Current number of concurrency slots used to evaluate independent rules concurrently.",
+			[]string{"user"},
+			nil,
+		),
+		IndependentRuleEvalConcurrencyAttemptsStarted: prometheus.NewDesc(
+			"cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total",
+			"Total number of independent rules which attempted to acquire a slot to be evaluated concurrently.",
+			[]string{"user"},
+			nil,
+		),
+		IndependentRuleEvalConcurrencyAttemptsIncomplete: prometheus.NewDesc(
+			"cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total",
+			"Total number of independent rules evaluated sequentially because no concurrency slot was available.",
+			[]string{"user"},
+			nil,
+		),
+		IndependentRuleEvalConcurrencyAttemptsCompleted: prometheus.NewDesc(
+			"cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total",
+			"Total number of independent rules evaluated concurrently.",
+			[]string{"user"},
+			nil,
+		),
 	}
 }
 
@@ -126,6 +162,11 @@ func (m *ManagerMetrics) Describe(out chan<- *prometheus.Desc) {
 	out <- m.GroupLastDuration
 	out <- m.GroupRules
 	out <- m.GroupLastEvalSamples
+	out <- m.RuleEvalDuration
+	out <- m.IndependentRuleEvalConcurrencySlotsInUse
+	out <- m.IndependentRuleEvalConcurrencyAttemptsStarted
+	out <- m.IndependentRuleEvalConcurrencyAttemptsIncomplete
+	out <- m.IndependentRuleEvalConcurrencyAttemptsCompleted
 }
 
 // Collect implements the Collector interface
@@ -148,4 +189,10 @@ func (m *ManagerMetrics) Collect(out chan<- prometheus.Metric) {
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupLastDuration, "prometheus_rule_group_last_duration_seconds", "rule_group")
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupRules, "prometheus_rule_group_rules", "rule_group")
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupLastEvalSamples, "prometheus_rule_group_last_evaluation_samples", "rule_group")
+	data.SendSumOfHistogramsPerUserWithLabels(out, m.RuleEvalDuration, "cortex_ruler_rule_evaluation_duration_seconds", "rule_group")
+
+	data.SendSumOfGaugesPerUser(out, m.IndependentRuleEvalConcurrencySlotsInUse, "cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use")
+	data.SendSumOfCountersPerUser(out, m.IndependentRuleEvalConcurrencyAttemptsStarted, "cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total")
+	data.SendSumOfCountersPerUser(out, m.IndependentRuleEvalConcurrencyAttemptsIncomplete, "cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total")
+	data.SendSumOfCountersPerUser(out, m.IndependentRuleEvalConcurrencyAttemptsCompleted, "cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total")
 }
@@ -135,6 +135,70 @@ cortex_prometheus_rule_group_rules{rule_group="group_one",user="user3"} 100000
 cortex_prometheus_rule_group_rules{rule_group="group_two",user="user1"} 1000
 cortex_prometheus_rule_group_rules{rule_group="group_two",user="user2"} 10000
 cortex_prometheus_rule_group_rules{rule_group="group_two",user="user3"} 100000
+# HELP cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total Total number of independent rules evaluated concurrently.
+# TYPE cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total counter
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total{user="user1"} 1
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total{user="user2"} 1
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total{user="user3"} 1
+# HELP cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total Total number of independent rules evaluated sequentially because no concurrency slot was available.
+# TYPE cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total counter
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total{user="user1"} 1
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total{user="user2"} 1
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total{user="user3"} 1
+# HELP cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total Total number of independent rules which attempted to acquire a slot to be evaluated concurrently.
+# TYPE cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total counter
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total{user="user1"} 2
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total{user="user2"} 2
+cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total{user="user3"} 2
+# HELP cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use Current number of concurrency slots used to evaluate independent rules concurrently.
+# TYPE cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use gauge
+cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use{user="user1"} 0
+cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use{user="user2"} 0
+cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use{user="user3"} 0
+# HELP cortex_ruler_rule_evaluation_duration_seconds The duration of the evaluation of each rule of the rule group. The durations of all the rules of a group are observed in the same histogram, without a per-rule label.
+# TYPE cortex_ruler_rule_evaluation_duration_seconds histogram
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="0.005"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="0.01"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="0.025"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="0.05"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="0.1"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="0.25"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="0.5"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="1"} 1
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="2.5"} 1
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="5"} 1
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="10"} 1
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user1",le="+Inf"} 1
+cortex_ruler_rule_evaluation_duration_seconds_sum{rule_group="group_one",user="user1"} 1
+cortex_ruler_rule_evaluation_duration_seconds_count{rule_group="group_one",user="user1"} 1
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="0.005"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="0.01"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="0.025"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="0.05"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="0.1"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="0.25"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="0.5"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="1"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="2.5"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="5"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="10"} 1
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user2",le="+Inf"} 1
+cortex_ruler_rule_evaluation_duration_seconds_sum{rule_group="group_one",user="user2"} 10
+cortex_ruler_rule_evaluation_duration_seconds_count{rule_group="group_one",user="user2"} 1
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="0.005"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="0.01"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="0.025"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="0.05"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="0.1"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="0.25"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="0.5"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="1"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="2.5"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="5"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="10"} 0
+cortex_ruler_rule_evaluation_duration_seconds_bucket{rule_group="group_one",user="user3",le="+Inf"} 1
+cortex_ruler_rule_evaluation_duration_seconds_sum{rule_group="group_one",user="user3"} 100
+cortex_ruler_rule_evaluation_duration_seconds_count{rule_group="group_one",user="user3"} 1
 `))
 	require.NoError(t, err)
 }
@@ -168,6 +232,20 @@ func populateManager(base float64) *prometheus.Registry {
 	metrics.groupLastEvalSamples.WithLabelValues("group_one").Add(base * 1000)
 	metrics.groupLastEvalSamples.WithLabelValues("group_two").Add(base * 1000)
 
+	// The ruler exports the rule evaluation duration and the concurrency metrics to the same registry.
+	ruleEvalDuration := promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cortex_ruler_rule_evaluation_duration_seconds",
+		Help:    "The duration of the evaluation of each rule of the rule group. The durations of all the rules of a group are observed in the same histogram, without a per-rule label.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"rule_group"})
+	ruleEvalDuration.WithLabelValues("group_one").Observe(base)
+
+	ctrl := NewMultiTenantConcurrencyController(10, ruleLimits{maxRuleConcurrency: 1}).NewTenantConcurrencyControllerFor("user", r)
+	if ctrl.tryAcquire() {
+		ctrl.tryAcquire()
+		ctrl.release()
+	}
+
 	return r
 }
 
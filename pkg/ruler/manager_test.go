@@ -31,7 +31,7 @@ func TestSyncRuleGroups(t *testing.T) {
 		_ = os.RemoveAll(dir)
 	})
 
-	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir}, factory, nil, log.NewNopLogger(), nil)
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir}, ruleLimits{}, factory, nil, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
 	const user = "testUser"
@@ -136,3 +136,52 @@ func (m *mockRulesManager) Update(_ time.Duration, _ []string, _ labels.Labels,
 func (m *mockRulesManager) RuleGroups() []*promRules.Group {
 	return nil
 }
+
+func TestSyncRuleGroups_ShouldUpdateNotifierOnOverridesChange(t *testing.T) {
+	const user = "testUser"
+
+	limits := &ruleLimits{externalLabels: labels.FromStrings("tenant", user)}
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: t.TempDir()}, limits, factory, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	t.Cleanup(m.Stop)
+
+	userRules := map[string]rulespb.RuleGroupList{
+		user: {
+			&rulespb.RuleGroupDesc{
+				Name:      "group1",
+				Namespace: "ns",
+				Interval:  1 * time.Minute,
+				User:      user,
+			},
+		},
+	}
+	m.SyncRuleGroups(context.Background(), userRules)
+
+	n := getNotifier(m, user)
+	require.NotNil(t, n)
+	require.Equal(t, labels.FromStrings("tenant", user), n.overrides.externalLabels)
+	require.Nil(t, n.httpClient(nil))
+
+	// The notifier config is updated when the overrides change.
+	limits.alertmanagerURL = "http://alertmanager.example.com"
+	limits.externalLabels = labels.FromStrings("tenant", user, "team", "a")
+	m.SyncRuleGroups(context.Background(), userRules)
+
+	require.True(t, n == getNotifier(m, user))
+	require.Equal(t, "http://alertmanager.example.com", n.overrides.alertmanagerURL)
+	require.Equal(t, labels.FromStrings("tenant", user, "team", "a"), n.overrides.externalLabels)
+	require.NotNil(t, n.httpClient(nil))
+
+	// The previous config is kept if the overrides are invalid.
+	limits.alertmanagerURL = "dnsserv+http://alertmanager.example.com"
+	m.SyncRuleGroups(context.Background(), userRules)
+
+	require.Equal(t, "http://alertmanager.example.com", n.overrides.alertmanagerURL)
+}
+
+func getNotifier(m *DefaultMultiTenantManager, user string) *rulerNotifier {
+	m.notifiersMtx.Lock()
+	defer m.notifiersMtx.Unlock()
+
+	return m.notifiers[user]
+}
@@ -8,31 +8,42 @@ package ruler
 import (
 	"context"
 	"flag"
+	"net/http"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	gklog "github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/crypto/tls"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/notifier"
 	"github.com/thanos-io/thanos/pkg/cacheutil"
 
 	"github.com/grafana/mimir/pkg/util"
+	util_net "github.com/grafana/mimir/pkg/util/net"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 type NotifierConfig struct {
 	TLS       tls.ClientConfig `yaml:",inline"`
 	BasicAuth util.BasicAuth   `yaml:",inline"`
+
+	// TenantTLSFilesDirectory is the directory of the TLS files which the tenants can set in their Alertmanager client config.
+	TenantTLSFilesDirectory string `yaml:"tenant_tls_files_directory" category:"experimental"`
 }
 
 func (cfg *NotifierConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.TLS.RegisterFlagsWithPrefix("ruler.alertmanager-client", f)
 	cfg.BasicAuth.RegisterFlagsWithPrefix("ruler.alertmanager-client.", f)
+	f.StringVar(&cfg.TenantTLSFilesDirectory, "ruler.alertmanager-client.tenant-tls-files-directory", "", "Directory of the CA, certificate and key files which the tenants can set in the per-tenant Alertmanager client config (ruler_alertmanager_client). The paths set by the tenants are relative to this directory, and can't point outside of it. If empty, the tenants can't set TLS files.")
 }
 
 // rulerNotifier bundles a notifier.Manager together with an associated
@@ -44,6 +55,13 @@ type rulerNotifier struct {
 	sdManager *discovery.Manager
 	wg        sync.WaitGroup
 	logger    gklog.Logger
+
+	// overrides are the tenant overrides the current config has been built from.
+	overrides tenantNotifierOverrides
+
+	// client sends the notifications to the Alertmanager overridden by the tenant, if any.
+	clientMtx sync.RWMutex
+	client    *http.Client
 }
 
 func newRulerNotifier(o *notifier.Options, l gklog.Logger) *rulerNotifier {
@@ -71,7 +89,13 @@ func (rn *rulerNotifier) run() {
 	}()
 }
 
-func (rn *rulerNotifier) applyConfig(cfg *config.Config) error {
+// applyConfig applies the notifier config. If client is not nil, it's used to send the notifications
+// instead of the client built by the notifier.Manager from the config.
+func (rn *rulerNotifier) applyConfig(cfg *config.Config, client *http.Client) error {
+	rn.clientMtx.Lock()
+	rn.client = client
+	rn.clientMtx.Unlock()
+
 	if err := rn.notifier.ApplyConfig(cfg); err != nil {
 		return err
 	}
@@ -83,6 +107,18 @@ func (rn *rulerNotifier) applyConfig(cfg *config.Config) error {
 	return rn.sdManager.ApplyConfig(sdCfgs)
 }
 
+// httpClient returns the client to send the notifications with, which is the given default client
+// unless the tenant overrides the Alertmanager.
+func (rn *rulerNotifier) httpClient(defaultClient *http.Client) *http.Client {
+	rn.clientMtx.RLock()
+	defer rn.clientMtx.RUnlock()
+
+	if rn.client != nil {
+		return rn.client
+	}
+	return defaultClient
+}
+
 func (rn *rulerNotifier) stop() {
 	rn.sdCancel()
 	rn.notifier.Stop()
@@ -125,6 +161,146 @@ func buildNotifierConfig(rulerConfig *Config, resolver cacheutil.AddressProvider
 	return promConfig, nil
 }
 
+// tenantNotifierOverrides are the per-tenant overrides of the notifier config.
+type tenantNotifierOverrides struct {
+	alertmanagerURL string
+	clientConfig    validation.RulerAlertmanagerClientConfig
+	externalLabels  labels.Labels
+}
+
+func getTenantNotifierOverrides(userID string, limits RulesLimits) tenantNotifierOverrides {
+	return tenantNotifierOverrides{
+		alertmanagerURL: limits.RulerAlertmanagerURL(userID),
+		clientConfig:    limits.RulerAlertmanagerClientConfig(userID),
+		externalLabels:  limits.RulerExternalLabels(userID),
+	}
+}
+
+func (o tenantNotifierOverrides) equal(other tenantNotifierOverrides) bool {
+	return o.alertmanagerURL == other.alertmanagerURL &&
+		o.clientConfig == other.clientConfig &&
+		labels.Equal(o.externalLabels, other.externalLabels)
+}
+
+// buildTenantNotifierConfig builds the notifier config of a tenant, with the tenant's external labels.
+// If the tenant overrides the Alertmanager, the config only uses the tenant's Alertmanager client config,
+// and the returned client sends the notifications through the firewall dialer.
+func buildTenantNotifierConfig(rulerConfig *Config, resolver cacheutil.AddressProvider, overrides tenantNotifierOverrides, firewallDialer *util_net.FirewallDialer) (*config.Config, *http.Client, error) {
+	if overrides.alertmanagerURL == "" {
+		ncfg, err := buildNotifierConfig(rulerConfig, resolver)
+		if err != nil {
+			return nil, nil, err
+		}
+		ncfg.GlobalConfig.ExternalLabels = overrides.externalLabels
+		return ncfg, nil, nil
+	}
+
+	clientConfig, err := resolveTenantTLSFiles(rulerConfig.Notifier.TenantTLSFilesDirectory, overrides.clientConfig)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid tenant Alertmanager client config")
+	}
+
+	// The global client config, including the credentials, isn't used for the tenant's Alertmanager.
+	tenantConfig := *rulerConfig
+	tenantConfig.AlertmanagerURL = overrides.alertmanagerURL
+	tenantConfig.Notifier = NotifierConfig{}
+
+	ncfg, err := buildNotifierConfig(&tenantConfig, resolver)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid tenant Alertmanager URL")
+	}
+	ncfg.GlobalConfig.ExternalLabels = overrides.externalLabels
+
+	for _, amConfig := range ncfg.AlertingConfig.AlertmanagerConfigs {
+		applyClientConfigOverrides(&amConfig.HTTPClientConfig, clientConfig)
+	}
+	if err := ncfg.AlertingConfig.AlertmanagerConfigs[0].HTTPClientConfig.Validate(); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid tenant Alertmanager client config")
+	}
+
+	// All the Alertmanagers of the tenant share the same client config.
+	client, err := config_util.NewClientFromConfig(ncfg.AlertingConfig.AlertmanagerConfigs[0].HTTPClientConfig, "alertmanager", config_util.WithDialContextFunc(firewallDialer.DialContext))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid tenant Alertmanager client config")
+	}
+	return ncfg, client, nil
+}
+
+// resolveTenantTLSFiles returns the client config with the paths of the TLS files resolved against the directory
+// of the tenants' TLS files. The tenant's paths can't point outside of this directory, which prevents a tenant from
+// reading other files of the ruler, e.g. the ruler's own client certificates.
+func resolveTenantTLSFiles(dir string, cfg validation.RulerAlertmanagerClientConfig) (validation.RulerAlertmanagerClientConfig, error) {
+	for _, file := range []struct {
+		name string
+		path *string
+	}{
+		{name: "tls_ca_path", path: &cfg.TLSCAPath},
+		{name: "tls_cert_path", path: &cfg.TLSCertPath},
+		{name: "tls_key_path", path: &cfg.TLSKeyPath},
+	} {
+		if *file.path == "" {
+			continue
+		}
+		if dir == "" {
+			return cfg, errors.Errorf("%s can't be set because the tenant TLS files directory isn't configured", file.name)
+		}
+
+		if !isLocalPath(*file.path) {
+			return cfg, errors.Errorf("%s %q must be a relative path within the tenant TLS files directory", file.name, *file.path)
+		}
+		*file.path = filepath.Join(dir, *file.path)
+	}
+	return cfg, nil
+}
+
+// isLocalPath returns whether the path is relative and stays within the directory it's relative to.
+func isLocalPath(path string) bool {
+	if filepath.IsAbs(path) || filepath.VolumeName(path) != "" {
+		return false
+	}
+	cleaned := filepath.Clean(path)
+	return cleaned != ".." && !strings.HasPrefix(cleaned, ".."+string(filepath.Separator))
+}
+
+func applyClientConfigOverrides(httpConfig *config_util.HTTPClientConfig, overrides validation.RulerAlertmanagerClientConfig) {
+	httpConfig.TLSConfig = config_util.TLSConfig{
+		CAFile:             overrides.TLSCAPath,
+		CertFile:           overrides.TLSCertPath,
+		KeyFile:            overrides.TLSKeyPath,
+		ServerName:         overrides.TLSServerName,
+		InsecureSkipVerify: overrides.TLSInsecureSkipVerify,
+	}
+
+	// Like the global config, the basic authentication config overrides the credentials in the URL.
+	if overrides.BasicAuthUsername != "" || overrides.BasicAuthPassword.String() != "" {
+		httpConfig.BasicAuth = &config_util.BasicAuth{
+			Username: overrides.BasicAuthUsername,
+			Password: config_util.Secret(overrides.BasicAuthPassword.String()),
+		}
+	}
+	if overrides.BearerToken.String() != "" {
+		httpConfig.Authorization = &config_util.Authorization{
+			Type:        "Bearer",
+			Credentials: config_util.Secret(overrides.BearerToken.String()),
+		}
+	}
+}
+
+// notifierFirewallConfigProvider applies the Alertmanager receivers firewall of a tenant
+// to the tenant's Alertmanager.
+type notifierFirewallConfigProvider struct {
+	userID string
+	limits RulesLimits
+}
+
+func (p notifierFirewallConfigProvider) BlockCIDRNetworks() []flagext.CIDR {
+	return p.limits.AlertmanagerReceiversBlockCIDRNetworks(p.userID)
+}
+
+func (p notifierFirewallConfigProvider) BlockPrivateAddresses() bool {
+	return p.limits.AlertmanagerReceiversBlockPrivateAddresses(p.userID)
+}
+
 func amConfigWithSD(rulerConfig *Config, url *url.URL, sdConfig discovery.Config) *config.AlertmanagerConfig {
 	amConfig := &config.AlertmanagerConfig{
 		APIVersion:              config.AlertmanagerAPIVersionV2,
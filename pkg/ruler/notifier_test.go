@@ -7,6 +7,9 @@ package ruler
 
 import (
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,10 +18,13 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/thanos/pkg/discovery/dns"
 
 	"github.com/grafana/mimir/pkg/util"
+	util_net "github.com/grafana/mimir/pkg/util/net"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 func TestBuildNotifierConfig(t *testing.T) {
@@ -299,3 +305,122 @@ func TestBuildNotifierConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildTenantNotifierConfig(t *testing.T) {
+	rulerConfig := &Config{
+		AlertmanagerURL:     "http://alertmanager.default.svc.cluster.local/alertmanager",
+		NotificationTimeout: 10 * time.Second,
+		Notifier: NotifierConfig{
+			BasicAuth: util.BasicAuth{Username: "global", Password: flagext.SecretWithValue("global-password")},
+		},
+	}
+	firewallDialer := util_net.NewFirewallDialer(notifierFirewallConfigProvider{userID: "user-1", limits: ruleLimits{}})
+
+	t.Run("without Alertmanager override", func(t *testing.T) {
+		ncfg, client, err := buildTenantNotifierConfig(rulerConfig, nil, tenantNotifierOverrides{externalLabels: labels.FromStrings("tenant", "user-1")}, firewallDialer)
+		require.NoError(t, err)
+		require.Nil(t, client)
+		require.Equal(t, labels.FromStrings("tenant", "user-1"), ncfg.GlobalConfig.ExternalLabels)
+		require.Len(t, ncfg.AlertingConfig.AlertmanagerConfigs, 1)
+		require.Equal(t, "global", ncfg.AlertingConfig.AlertmanagerConfigs[0].HTTPClientConfig.BasicAuth.Username)
+	})
+
+	t.Run("with Alertmanager override", func(t *testing.T) {
+		ncfg, client, err := buildTenantNotifierConfig(rulerConfig, nil, tenantNotifierOverrides{
+			alertmanagerURL: "https://am1.example.com/alertmanager,https://am2.example.com",
+			clientConfig: validation.RulerAlertmanagerClientConfig{
+				BearerToken:   flagext.SecretWithValue("token"),
+				TLSServerName: "example.com",
+			},
+		}, firewallDialer)
+		require.NoError(t, err)
+		require.NotNil(t, client)
+		require.Len(t, ncfg.AlertingConfig.AlertmanagerConfigs, 2)
+
+		for _, amConfig := range ncfg.AlertingConfig.AlertmanagerConfigs {
+			// The global credentials aren't sent to the tenant's Alertmanager.
+			require.Nil(t, amConfig.HTTPClientConfig.BasicAuth)
+			require.Equal(t, &config_util.Authorization{Type: "Bearer", Credentials: "token"}, amConfig.HTTPClientConfig.Authorization)
+			require.Equal(t, "example.com", amConfig.HTTPClientConfig.TLSConfig.ServerName)
+			require.Equal(t, "https", amConfig.Scheme)
+			require.Equal(t, model.Duration(10*time.Second), amConfig.Timeout)
+		}
+	})
+
+	t.Run("with invalid Alertmanager override", func(t *testing.T) {
+		_, _, err := buildTenantNotifierConfig(rulerConfig, nil, tenantNotifierOverrides{alertmanagerURL: "dnsserv+https://alertmanager"}, firewallDialer)
+		require.EqualError(t, err, `invalid tenant Alertmanager URL: invalid DNS service discovery prefix "dnsserv"`)
+	})
+}
+
+func TestResolveTenantTLSFiles(t *testing.T) {
+	tests := map[string]struct {
+		dir         string
+		cfg         validation.RulerAlertmanagerClientConfig
+		expected    validation.RulerAlertmanagerClientConfig
+		expectedErr string
+	}{
+		"no TLS files": {
+			cfg:      validation.RulerAlertmanagerClientConfig{TLSServerName: "example.com"},
+			expected: validation.RulerAlertmanagerClientConfig{TLSServerName: "example.com"},
+		},
+		"TLS files within the directory": {
+			dir:      "/etc/tenants-tls",
+			cfg:      validation.RulerAlertmanagerClientConfig{TLSCAPath: "ca.crt", TLSCertPath: "user-1/client.crt", TLSKeyPath: "./user-1/../user-1/client.key"},
+			expected: validation.RulerAlertmanagerClientConfig{TLSCAPath: "/etc/tenants-tls/ca.crt", TLSCertPath: "/etc/tenants-tls/user-1/client.crt", TLSKeyPath: "/etc/tenants-tls/user-1/client.key"},
+		},
+		"TLS files directory not configured": {
+			cfg:         validation.RulerAlertmanagerClientConfig{TLSCAPath: "ca.crt"},
+			expectedErr: "tls_ca_path can't be set because the tenant TLS files directory isn't configured",
+		},
+		"absolute path": {
+			dir:         "/etc/tenants-tls",
+			cfg:         validation.RulerAlertmanagerClientConfig{TLSCertPath: "/etc/ruler/client.crt"},
+			expectedErr: `tls_cert_path "/etc/ruler/client.crt" must be a relative path within the tenant TLS files directory`,
+		},
+		"path outside of the directory": {
+			dir:         "/etc/tenants-tls",
+			cfg:         validation.RulerAlertmanagerClientConfig{TLSKeyPath: "user-1/../../ruler/client.key"},
+			expectedErr: `tls_key_path "user-1/../../ruler/client.key" must be a relative path within the tenant TLS files directory`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := resolveTenantTLSFiles(tc.dir, tc.cfg)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestBuildTenantNotifierConfig_Firewall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	for name, blockPrivateAddresses := range map[string]bool{"firewall disabled": false, "firewall enabled": true} {
+		t.Run(name, func(t *testing.T) {
+			limits := ruleLimits{blockPrivateAddresses: blockPrivateAddresses}
+			firewallDialer := util_net.NewFirewallDialer(notifierFirewallConfigProvider{userID: "user-1", limits: limits})
+
+			_, client, err := buildTenantNotifierConfig(&Config{}, nil, tenantNotifierOverrides{alertmanagerURL: server.URL}, firewallDialer)
+			require.NoError(t, err)
+
+			resp, err := client.Post(server.URL+"/api/v2/alerts", "application/json", strings.NewReader("[]"))
+			if blockPrivateAddresses {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "blocked address")
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
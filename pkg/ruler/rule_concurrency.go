@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"go.uber.org/atomic"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	groupEvaluationStateKey contextKey = 2
+
+	// Names of the series written by alerting rules.
+	alertMetricName         = "ALERTS"
+	alertForStateMetricName = "ALERTS_FOR_STATE"
+)
+
+// MultiTenantConcurrencyController limits the number of queries of independent rules
+// run concurrently across all tenants.
+type MultiTenantConcurrencyController struct {
+	limits RulesLimits
+	slots  *semaphore.Weighted
+}
+
+// NewMultiTenantConcurrencyController returns a MultiTenantConcurrencyController allowing up to
+// maxConcurrency queries of independent rules to be run concurrently across all tenants.
+func NewMultiTenantConcurrencyController(maxConcurrency int64, limits RulesLimits) *MultiTenantConcurrencyController {
+	return &MultiTenantConcurrencyController{
+		limits: limits,
+		slots:  semaphore.NewWeighted(maxConcurrency),
+	}
+}
+
+// NewTenantConcurrencyControllerFor returns the TenantConcurrencyController of the tenant,
+// exporting its metrics to the tenant's registerer.
+func (c *MultiTenantConcurrencyController) NewTenantConcurrencyControllerFor(userID string, reg prometheus.Registerer) *TenantConcurrencyController {
+	return &TenantConcurrencyController{
+		userID:      userID,
+		limits:      c.limits,
+		globalSlots: c.slots,
+
+		slotsInUse: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use",
+			Help: "Current number of concurrency slots used to evaluate independent rules concurrently.",
+		}),
+		attemptsStarted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total",
+			Help: "Total number of independent rules which attempted to acquire a slot to be evaluated concurrently.",
+		}),
+		attemptsIncomplete: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total",
+			Help: "Total number of independent rules evaluated sequentially because no concurrency slot was available.",
+		}),
+		attemptsCompleted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total",
+			Help: "Total number of independent rules evaluated concurrently.",
+		}),
+	}
+}
+
+// TenantConcurrencyController limits the number of queries of independent rules of a tenant
+// run concurrently. A query is run concurrently if both a tenant and a global slot are available.
+type TenantConcurrencyController struct {
+	userID      string
+	limits      RulesLimits
+	globalSlots *semaphore.Weighted
+	inUse       atomic.Int64
+
+	slotsInUse         prometheus.Gauge
+	attemptsStarted    prometheus.Counter
+	attemptsIncomplete prometheus.Counter
+	attemptsCompleted  prometheus.Counter
+}
+
+// tryAcquire returns whether a concurrency slot has been acquired. If it returns true,
+// release must be called once the query completes.
+func (c *TenantConcurrencyController) tryAcquire() bool {
+	limit := int64(c.limits.RulerMaxIndependentRuleEvaluationConcurrencyPerTenant(c.userID))
+	if limit <= 0 {
+		return false
+	}
+
+	c.attemptsStarted.Inc()
+
+	if c.inUse.Inc() > limit {
+		c.inUse.Dec()
+		c.attemptsIncomplete.Inc()
+		return false
+	}
+	if !c.globalSlots.TryAcquire(1) {
+		c.inUse.Dec()
+		c.attemptsIncomplete.Inc()
+		return false
+	}
+
+	c.slotsInUse.Inc()
+	return true
+}
+
+// release releases the slot acquired by a successful call to tryAcquire.
+func (c *TenantConcurrencyController) release() {
+	c.globalSlots.Release(1)
+	c.inUse.Dec()
+	c.slotsInUse.Dec()
+	c.attemptsCompleted.Inc()
+}
+
+// GroupEvaluationContextFunc returns a rules.ContextWrapFunc which injects the evaluation state of
+// the group in its context, to be used by ConcurrentQueryFunc. The controller may be nil, in which
+// case the rules are evaluated sequentially.
+func GroupEvaluationContextFunc(controller *TenantConcurrencyController, ruleEvalDuration *prometheus.HistogramVec) rules.ContextWrapFunc {
+	return func(ctx context.Context, g *rules.Group) context.Context {
+		ctx = FederatedGroupContextFunc(ctx, g)
+		state := newGroupEvaluationState(g, controller, ruleEvalDuration)
+		ctx = context.WithValue(ctx, groupEvaluationStateKey, state)
+		state.ctx = ctx
+		return ctx
+	}
+}
+
+// ConcurrentQueryFunc returns a rules.QueryFunc which returns the results of the queries run
+// concurrently for the group being evaluated, if any, and otherwise runs the query with qf.
+func ConcurrentQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
+		if state, _ := ctx.Value(groupEvaluationStateKey).(*groupEvaluationState); state != nil {
+			return state.query(ctx, qf, q, t)
+		}
+		return qf(ctx, q, t)
+	}
+}
+
+// groupEvaluationState tracks the evaluations of a rule group. The rules of a group are evaluated
+// sequentially, and all of them are queried at the same timestamp. On the first query of each
+// evaluation, the queries of the independent rules of the group are started concurrently, and their
+// results are returned when the rules are evaluated.
+type groupEvaluationState struct {
+	// ctx is the context of the group, which the queries run concurrently are run with, so that they
+	// aren't bound to the context (and tracing span) of the rule whose query started the evaluation.
+	ctx context.Context
+
+	rules       []rules.Rule
+	independent []bool
+	controller  *TenantConcurrencyController
+
+	ruleEvalDuration prometheus.Observer
+
+	mtx sync.Mutex
+	// Timestamp the rules are queried at in the current evaluation.
+	queryTime time.Time
+	// Queries run concurrently in the current evaluation, by query. Each result is returned once.
+	prefetched map[string]*prefetchedQuery
+	// Timestamp of the last evaluation of each rule whose duration has been observed.
+	lastObservedEval []time.Time
+}
+
+type prefetchedQuery struct {
+	done   chan struct{}
+	vector promql.Vector
+	err    error
+}
+
+func newGroupEvaluationState(g *rules.Group, controller *TenantConcurrencyController, ruleEvalDuration *prometheus.HistogramVec) *groupEvaluationState {
+	return &groupEvaluationState{
+		rules:            g.Rules(),
+		independent:      findIndependentRules(g.Rules()),
+		controller:       controller,
+		ruleEvalDuration: ruleEvalDuration.WithLabelValues(rules.GroupKey(g.File(), g.Name())),
+		lastObservedEval: make([]time.Time, len(g.Rules())),
+	}
+}
+
+func (s *groupEvaluationState) query(ctx context.Context, qf rules.QueryFunc, q string, t time.Time) (promql.Vector, error) {
+	s.mtx.Lock()
+	if !t.Equal(s.queryTime) {
+		s.startEvaluation(qf, q, t)
+	}
+	p, ok := s.prefetched[q]
+	delete(s.prefetched, q)
+	s.mtx.Unlock()
+
+	if !ok {
+		return qf(ctx, q, t)
+	}
+
+	select {
+	case <-p.done:
+		return p.vector, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startEvaluation is called on the first query of a new evaluation of the group, which is
+// run by the caller. It must be called with the lock held.
+func (s *groupEvaluationState) startEvaluation(qf rules.QueryFunc, q string, t time.Time) {
+	s.queryTime = t
+	// The results not consumed by the previous evaluation, which may have been interrupted, are discarded.
+	s.prefetched = map[string]*prefetchedQuery{}
+
+	// The previous evaluation has completed, so the duration of its rules can be observed.
+	for i, rule := range s.rules {
+		if ts := rule.GetEvaluationTimestamp(); ts.After(s.lastObservedEval[i]) {
+			s.lastObservedEval[i] = ts
+			s.ruleEvalDuration.Observe(rule.GetEvaluationDuration().Seconds())
+		}
+	}
+
+	if s.controller == nil {
+		return
+	}
+
+	for i, rule := range s.rules {
+		rq := rule.Query().String()
+		if !s.independent[i] || rq == q {
+			continue
+		}
+		if _, ok := s.prefetched[rq]; ok {
+			continue
+		}
+		if !s.controller.tryAcquire() {
+			continue
+		}
+
+		p := &prefetchedQuery{done: make(chan struct{})}
+		s.prefetched[rq] = p
+
+		go func() {
+			defer s.controller.release()
+			defer close(p.done)
+
+			p.vector, p.err = qf(s.ctx, rq, t)
+		}()
+	}
+}
+
+// findIndependentRules returns, for each rule, whether the rule doesn't select the output of any
+// other rule of the group. The query of an independent rule can be run at any time during the
+// evaluation of the group, because its result doesn't depend on the rules evaluated before it.
+func findIndependentRules(groupRules []rules.Rule) []bool {
+	// Map each metric name to the rules writing series with that name.
+	outputs := map[string][]int{}
+	for i, rule := range groupRules {
+		switch r := rule.(type) {
+		case *rules.RecordingRule:
+			outputs[r.Name()] = append(outputs[r.Name()], i)
+		case *rules.AlertingRule:
+			outputs[alertMetricName] = append(outputs[alertMetricName], i)
+			outputs[alertForStateMetricName] = append(outputs[alertForStateMetricName], i)
+		}
+	}
+
+	independent := make([]bool, len(groupRules))
+	for i, rule := range groupRules {
+		names, ok := selectedMetricNames(rule.Query())
+		if !ok {
+			// The rule may select the output of any other rule.
+			continue
+		}
+
+		independent[i] = true
+		for _, name := range names {
+			for _, j := range outputs[name] {
+				// A rule selecting its own output only gets the series of its previous
+				// evaluation, regardless of when its query is run.
+				if j != i {
+					independent[i] = false
+				}
+			}
+		}
+	}
+	return independent
+}
+
+// selectedMetricNames returns the metric names of the series selected by the expression.
+// It returns false if any selector doesn't select a single metric name.
+func selectedMetricNames(expr parser.Expr) ([]string, bool) {
+	if expr == nil {
+		return nil, true
+	}
+
+	var (
+		names []string
+		ok    = true
+	)
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		vs, isSelector := node.(*parser.VectorSelector)
+		if !isSelector || !ok {
+			return nil
+		}
+
+		name := ""
+		for _, m := range vs.LabelMatchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				name = m.Value
+				break
+			}
+		}
+		if name == "" {
+			ok = false
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	})
+
+	return names, ok
+}
@@ -0,0 +1,263 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantConcurrencyController(t *testing.T) {
+	limits := ruleLimits{maxRuleConcurrency: 2}
+	global := NewMultiTenantConcurrencyController(3, limits)
+
+	user1 := global.NewTenantConcurrencyControllerFor("user-1", nil)
+	user2 := global.NewTenantConcurrencyControllerFor("user-2", nil)
+
+	// The tenant limit is reached.
+	require.True(t, user1.tryAcquire())
+	require.True(t, user1.tryAcquire())
+	require.False(t, user1.tryAcquire())
+
+	// The global limit is reached.
+	require.True(t, user2.tryAcquire())
+	require.False(t, user2.tryAcquire())
+
+	// The released slots can be used by any tenant.
+	user1.release()
+	require.True(t, user2.tryAcquire())
+	require.False(t, user1.tryAcquire())
+
+	// The rules of a tenant are evaluated sequentially if the tenant limit is 0.
+	user3 := NewMultiTenantConcurrencyController(3, ruleLimits{}).NewTenantConcurrencyControllerFor("user-3", nil)
+	require.False(t, user3.tryAcquire())
+}
+
+func TestFindIndependentRules(t *testing.T) {
+	for name, tc := range map[string]struct {
+		rules    []rules.Rule
+		expected []bool
+	}{
+		"no rules": {
+			expected: []bool{},
+		},
+		"independent recording rules": {
+			rules:    []rules.Rule{newRecordingRule(t, "a", "sum(up)"), newRecordingRule(t, "b", "rate(requests_total[5m])")},
+			expected: []bool{true, true},
+		},
+		"recording rule selecting the output of another rule": {
+			rules:    []rules.Rule{newRecordingRule(t, "a", "sum(up)"), newRecordingRule(t, "b", "up"), newRecordingRule(t, "c", "sum_over_time(a[5m])")},
+			expected: []bool{true, true, false},
+		},
+		"recording rule selecting its own output": {
+			rules:    []rules.Rule{newRecordingRule(t, "a", "a or up"), newRecordingRule(t, "b", "up")},
+			expected: []bool{true, true},
+		},
+		"recording rule selecting series without a metric name": {
+			rules:    []rules.Rule{newRecordingRule(t, "a", "sum(up)"), newRecordingRule(t, "b", `{job="test"}`)},
+			expected: []bool{true, false},
+		},
+		"recording rule selecting the alerts": {
+			rules:    []rules.Rule{newAlertingRule(t, "Alert", "up == 0"), newRecordingRule(t, "a", "count(ALERTS)"), newRecordingRule(t, "b", "up")},
+			expected: []bool{true, false, true},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, findIndependentRules(tc.rules))
+		})
+	}
+}
+
+func TestConcurrentQueryFunc(t *testing.T) {
+	const user = "user-1"
+
+	// The independent rules wait for each other, so that their evaluation only succeeds
+	// if they're evaluated concurrently.
+	var independent sync.WaitGroup
+	queryFunc := func(ctx context.Context, qs string, ts time.Time) (promql.Vector, error) {
+		if qs == "sum(b_input)" || qs == "sum(c_input)" {
+			independent.Done()
+
+			done := make(chan struct{})
+			go func() {
+				independent.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				return nil, fmt.Errorf("query %s not evaluated concurrently", qs)
+			}
+		}
+		return promql.Vector{{Point: promql.Point{T: ts.UnixMilli(), V: 1}, Metric: labels.FromStrings("job", "test")}}, nil
+	}
+
+	ctrl := NewMultiTenantConcurrencyController(10, ruleLimits{maxRuleConcurrency: 2}).NewTenantConcurrencyControllerFor(user, nil)
+	ruleEvalDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"rule_group"})
+	groupRules := []rules.Rule{
+		newRecordingRule(t, "a", "sum(a_input)"),
+		newRecordingRule(t, "b", "sum(b_input)"),
+		newRecordingRule(t, "c", "sum(c_input)"),
+		newRecordingRule(t, "d", "a * 2"),
+	}
+	g := rules.NewGroup(rules.GroupOptions{
+		Name:     "group",
+		File:     "file",
+		Interval: time.Minute,
+		Rules:    groupRules,
+		Opts: &rules.ManagerOptions{
+			QueryFunc:  ConcurrentQueryFunc(queryFunc),
+			Appendable: noopAppendable{},
+			Logger:     log.NewNopLogger(),
+			Context:    context.Background(),
+		},
+	})
+	require.Equal(t, []bool{true, true, true, false}, findIndependentRules(groupRules))
+
+	ctx := GroupEvaluationContextFunc(ctrl, ruleEvalDuration)(context.Background(), g)
+	now := time.Now()
+	for n := 0; n < 2; n++ {
+		independent.Add(2)
+		g.Eval(ctx, now.Add(time.Duration(n)*time.Minute))
+
+		for _, rule := range groupRules {
+			assert.Equal(t, rules.HealthGood, rule.Health(), rule.Name())
+			assert.NoError(t, rule.LastError(), rule.Name())
+			assert.NotZero(t, rule.GetEvaluationTimestamp(), rule.Name())
+		}
+		assert.Zero(t, ctrl.inUse.Load())
+		assert.Equal(t, float64(2*(n+1)), testutil.ToFloat64(ctrl.attemptsCompleted))
+	}
+
+	// The evaluation duration of the rules is observed when the next evaluation starts.
+	assert.Equal(t, 1, testutil.CollectAndCount(ruleEvalDuration))
+	assert.Equal(t, uint64(len(groupRules)), histogramSampleCount(t, ruleEvalDuration.WithLabelValues(rules.GroupKey("file", "group"))))
+}
+
+func TestConcurrentQueryFunc_ShouldRunConcurrentQueriesWithTheGroupContext(t *testing.T) {
+	type ruleKey struct{}
+
+	var (
+		mtx        sync.Mutex
+		queryRules = map[string]interface{}{}
+	)
+	queryFunc := func(ctx context.Context, qs string, ts time.Time) (promql.Vector, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		queryRules[qs] = ctx.Value(ruleKey{})
+		return nil, nil
+	}
+
+	ctrl := NewMultiTenantConcurrencyController(10, ruleLimits{maxRuleConcurrency: 2}).NewTenantConcurrencyControllerFor("user-1", nil)
+	ruleEvalDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"rule_group"})
+	g := rules.NewGroup(rules.GroupOptions{
+		Name:     "group",
+		File:     "file",
+		Interval: time.Minute,
+		Rules:    []rules.Rule{newRecordingRule(t, "a", "sum(a_input)"), newRecordingRule(t, "b", "sum(b_input)")},
+		Opts:     &rules.ManagerOptions{Logger: log.NewNopLogger(), Context: context.Background()},
+	})
+	groupCtx := GroupEvaluationContextFunc(ctrl, ruleEvalDuration)(context.Background(), g)
+
+	// The first rule's query starts the query of the second rule, whose result is then returned to the second rule.
+	qf := ConcurrentQueryFunc(queryFunc)
+	now := time.Now()
+	ruleCtx, cancel := context.WithCancel(context.WithValue(groupCtx, ruleKey{}, "a"))
+	_, err := qf(ruleCtx, "sum(a_input)", now)
+	require.NoError(t, err)
+	cancel()
+
+	_, err = qf(context.WithValue(groupCtx, ruleKey{}, "b"), "sum(b_input)", now)
+	require.NoError(t, err)
+
+	// The query of the second rule hasn't been run with the context of the first rule.
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, map[string]interface{}{"sum(a_input)": "a", "sum(b_input)": nil}, queryRules)
+	assert.Equal(t, float64(1), testutil.ToFloat64(ctrl.attemptsCompleted))
+}
+
+func TestConcurrentQueryFunc_ShouldEvaluateSequentiallyWithoutController(t *testing.T) {
+	var queries []string
+	queryFunc := func(ctx context.Context, qs string, ts time.Time) (promql.Vector, error) {
+		queries = append(queries, qs)
+		return nil, nil
+	}
+
+	groupRules := []rules.Rule{
+		newRecordingRule(t, "a", "sum(a_input)"),
+		newRecordingRule(t, "b", "sum(b_input)"),
+		newRecordingRule(t, "c", "sum(c_input)"),
+	}
+	g := rules.NewGroup(rules.GroupOptions{
+		Name:     "group",
+		File:     "file",
+		Interval: time.Minute,
+		Rules:    groupRules,
+		Opts: &rules.ManagerOptions{
+			QueryFunc:  ConcurrentQueryFunc(queryFunc),
+			Appendable: noopAppendable{},
+			Logger:     log.NewNopLogger(),
+			Context:    context.Background(),
+		},
+	})
+
+	ruleEvalDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"rule_group"})
+	g.Eval(GroupEvaluationContextFunc(nil, ruleEvalDuration)(context.Background(), g), time.Now())
+
+	assert.Equal(t, []string{"sum(a_input)", "sum(b_input)", "sum(c_input)"}, queries)
+}
+
+func histogramSampleCount(t *testing.T, o prometheus.Observer) uint64 {
+	m := &dto.Metric{}
+	require.NoError(t, o.(prometheus.Metric).Write(m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func newRecordingRule(t *testing.T, name, query string) rules.Rule {
+	expr, err := parser.ParseExpr(query)
+	require.NoError(t, err)
+	return rules.NewRecordingRule(name, expr, nil)
+}
+
+func newAlertingRule(t *testing.T, name, query string) rules.Rule {
+	expr, err := parser.ParseExpr(query)
+	require.NoError(t, err)
+	return rules.NewAlertingRule(name, expr, 0, nil, nil, nil, "", true, log.NewNopLogger())
+}
+
+type noopAppendable struct{}
+
+func (noopAppendable) Appender(_ context.Context) storage.Appender {
+	return noopAppender{}
+}
+
+type noopAppender struct{}
+
+func (noopAppender) Append(ref storage.SeriesRef, _ labels.Labels, _ int64, _ float64) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+func (noopAppender) AppendExemplar(ref storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+func (noopAppender) Commit() error { return nil }
+
+func (noopAppender) Rollback() error { return nil }
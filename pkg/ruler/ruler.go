@@ -46,7 +46,8 @@ import (
 )
 
 var (
-	errInvalidTenantShardSize = errors.New("invalid tenant shard size, the value must be greater or equal to 0")
+	errInvalidTenantShardSize                         = errors.New("invalid tenant shard size, the value must be greater or equal to 0")
+	errInvalidMaxIndependentRuleEvaluationConcurrency = errors.New("invalid max independent rule evaluation concurrency, the value must be greater or equal to 0")
 )
 
 const (
@@ -117,6 +118,8 @@ type Config struct {
 	EnableQueryStats bool `yaml:"query_stats_enabled" category:"advanced"`
 
 	TenantFederation TenantFederationConfig `yaml:"tenant_federation"`
+
+	MaxIndependentRuleEvaluationConcurrency int64 `yaml:"max_independent_rule_evaluation_concurrency" category:"experimental"`
 }
 
 // Validate config and returns error on failure
@@ -125,6 +128,10 @@ func (cfg *Config) Validate(limits validation.Limits, log log.Logger) error {
 		return errInvalidTenantShardSize
 	}
 
+	if cfg.MaxIndependentRuleEvaluationConcurrency < 0 {
+		return errInvalidMaxIndependentRuleEvaluationConcurrency
+	}
+
 	if err := cfg.ClientTLSConfig.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid ruler gRPC client config")
 	}
@@ -161,6 +168,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 
 	f.BoolVar(&cfg.EnableQueryStats, "ruler.query-stats-enabled", false, "Report the wall time for ruler queries to complete as a per-tenant metric and as an info level log message.")
 
+	f.Int64Var(&cfg.MaxIndependentRuleEvaluationConcurrency, "ruler.max-independent-rule-evaluation-concurrency", 0, "Maximum number of independent rules whose query can be run concurrently across all tenants, ahead of the sequential evaluation of their rule group. The concurrency of each tenant is limited by -ruler.max-independent-rule-evaluation-concurrency-per-tenant. 0 to evaluate the rules sequentially.")
+
 	cfg.RingCheckPeriod = 5 * time.Second
 }
 
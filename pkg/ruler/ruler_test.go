@@ -49,6 +49,7 @@ import (
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
 	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
 	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 func defaultRulerConfig(t testing.TB) Config {
@@ -76,10 +77,16 @@ func defaultRulerConfig(t testing.TB) Config {
 }
 
 type ruleLimits struct {
-	evalDelay            time.Duration
-	tenantShard          int
-	maxRulesPerRuleGroup int
-	maxRuleGroups        int
+	evalDelay                time.Duration
+	tenantShard              int
+	maxRulesPerRuleGroup     int
+	maxRuleGroups            int
+	maxRuleConcurrency       int
+	alertmanagerURL          string
+	alertmanagerClientConfig validation.RulerAlertmanagerClientConfig
+	externalLabels           labels.Labels
+	blockCIDRNetworks        []flagext.CIDR
+	blockPrivateAddresses    bool
 }
 
 func (r ruleLimits) EvaluationDelay(_ string) time.Duration {
@@ -98,6 +105,30 @@ func (r ruleLimits) RulerMaxRulesPerRuleGroup(_ string) int {
 	return r.maxRulesPerRuleGroup
 }
 
+func (r ruleLimits) RulerMaxIndependentRuleEvaluationConcurrencyPerTenant(_ string) int {
+	return r.maxRuleConcurrency
+}
+
+func (r ruleLimits) RulerAlertmanagerURL(_ string) string {
+	return r.alertmanagerURL
+}
+
+func (r ruleLimits) RulerAlertmanagerClientConfig(_ string) validation.RulerAlertmanagerClientConfig {
+	return r.alertmanagerClientConfig
+}
+
+func (r ruleLimits) RulerExternalLabels(_ string) labels.Labels {
+	return r.externalLabels
+}
+
+func (r ruleLimits) AlertmanagerReceiversBlockCIDRNetworks(_ string) []flagext.CIDR {
+	return r.blockCIDRNetworks
+}
+
+func (r ruleLimits) AlertmanagerReceiversBlockPrivateAddresses(_ string) bool {
+	return r.blockPrivateAddresses
+}
+
 func testSetup(t *testing.T) (*promql.Engine, storage.QueryableFunc, Pusher, log.Logger, RulesLimits) {
 	dir := t.TempDir()
 	tracker := promql.NewActiveQueryTracker(dir, 20, log.NewNopLogger())
@@ -124,7 +155,7 @@ func testSetup(t *testing.T) (*promql.Engine, storage.QueryableFunc, Pusher, log
 
 func newManager(t *testing.T, cfg Config) *DefaultMultiTenantManager {
 	engine, noopQueryable, pusher, logger, overrides := testSetup(t)
-	manager, err := NewDefaultMultiTenantManager(cfg, DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryable, engine, overrides, nil), prometheus.NewRegistry(), logger, nil)
+	manager, err := NewDefaultMultiTenantManager(cfg, overrides, DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryable, engine, overrides, nil), prometheus.NewRegistry(), logger, nil)
 	require.NoError(t, err)
 
 	return manager
@@ -173,7 +204,7 @@ func buildRuler(t *testing.T, cfg Config, storage rulestore.RuleStore, rulerAddr
 
 	reg := prometheus.NewRegistry()
 	managerFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryable, engine, overrides, reg)
-	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, reg, log.NewNopLogger(), nil)
+	manager, err := NewDefaultMultiTenantManager(cfg, overrides, managerFactory, reg, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
 	ruler, err := newRuler(cfg, manager, reg, logger, storage, overrides, newMockClientsPool(cfg, logger, reg, rulerAddrMap))
@@ -910,7 +941,7 @@ func TestDeleteTenantRuleGroups(t *testing.T) {
 	}
 
 	obj := objstore.NewInMemBucket()
-	rs := bucketclient.NewBucketRuleStore(obj, nil, log.NewNopLogger())
+	rs := bucketclient.NewBucketRuleStore(obj, nil, 0, log.NewNopLogger())
 
 	// "upload" rule groups
 	for _, key := range ruleGroups {
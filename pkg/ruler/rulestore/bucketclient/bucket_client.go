@@ -23,12 +23,18 @@ import (
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
 	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 const (
 	// The bucket prefix under which all tenants rule groups are stored.
 	rulesPrefix = "rules"
 
+	// The bucket prefix under which the history of all tenants rule groups is stored.
+	// Note that objects stored under this prefix follow the pattern:
+	//     rules-history/<user>/<namespace>/<rules group>/<version>
+	rulesHistoryPrefix = "rules-history"
+
 	loadConcurrency = 10
 )
 
@@ -42,16 +48,22 @@ var (
 // BucketRuleStore is used to support the RuleStore interface against an object storage backend. It is implemented
 // using the Thanos objstore.Bucket interface
 type BucketRuleStore struct {
-	bucket      objstore.Bucket
-	cfgProvider bucket.TenantConfigProvider
-	logger      log.Logger
+	bucket        objstore.Bucket
+	historyBucket objstore.Bucket
+	cfgProvider   bucket.TenantConfigProvider
+	maxVersions   int
+	logger        log.Logger
 }
 
-func NewBucketRuleStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *BucketRuleStore {
+// NewBucketRuleStore returns a BucketRuleStore keeping up to maxVersions versions in the history
+// of each rule group. The history is disabled if maxVersions is 0.
+func NewBucketRuleStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, maxVersions int, logger log.Logger) *BucketRuleStore {
 	return &BucketRuleStore{
-		bucket:      bucket.NewPrefixedBucketClient(bkt, rulesPrefix),
-		cfgProvider: cfgProvider,
-		logger:      logger,
+		bucket:        bucket.NewPrefixedBucketClient(bkt, rulesPrefix),
+		historyBucket: bucket.NewPrefixedBucketClient(bkt, rulesHistoryPrefix),
+		cfgProvider:   cfgProvider,
+		maxVersions:   maxVersions,
+		logger:        logger,
 	}
 }
 
@@ -202,7 +214,13 @@ func (b *BucketRuleStore) SetRuleGroup(ctx context.Context, userID string, names
 		return err
 	}
 
-	return userBucket.Upload(ctx, getRuleGroupObjectKey(namespace, group.Name), bytes.NewBuffer(data))
+	err = userBucket.Upload(ctx, getRuleGroupObjectKey(namespace, group.Name), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	b.addRuleGroupVersion(ctx, userID, namespace, group.Name, data)
+	return nil
 }
 
 // DeleteRuleGroup implements rules.RuleStore.
@@ -212,7 +230,12 @@ func (b *BucketRuleStore) DeleteRuleGroup(ctx context.Context, userID string, na
 	if b.bucket.IsObjNotFoundErr(err) {
 		return rulestore.ErrGroupNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	b.addRuleGroupVersion(ctx, userID, namespace, group, nil)
+	return nil
 }
 
 // DeleteNamespace implements rules.RuleStore.
@@ -223,6 +246,10 @@ func (b *BucketRuleStore) DeleteNamespace(ctx context.Context, userID string, na
 	}
 
 	if len(ruleGroupList) == 0 {
+		// The rule groups may have been deleted one by one, leaving their history behind.
+		if err := b.DeleteHistory(ctx, userID, namespace); err != nil {
+			return err
+		}
 		return rulestore.ErrGroupNamespaceNotFound
 	}
 
@@ -240,9 +267,65 @@ func (b *BucketRuleStore) DeleteNamespace(ctx context.Context, userID string, na
 		}
 	}
 
+	return b.DeleteHistory(ctx, userID, namespace)
+}
+
+// DeleteHistory implements rules.RuleStore.
+func (b *BucketRuleStore) DeleteHistory(ctx context.Context, userID, namespace string) error {
+	userBucket := bucket.NewUserBucketClient(userID, b.historyBucket, b.cfgProvider)
+
+	prefix := ""
+	if namespace != "" {
+		prefix = getNamespacePrefix(namespace)
+	}
+
+	deleted, err := bucket.DeletePrefix(ctx, userBucket, prefix, b.logger)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete rule groups history for user %s", userID)
+	}
+	if deleted > 0 {
+		level.Debug(b.logger).Log("msg", "deleted rule groups history", "user", userID, "namespace", namespace, "objects", deleted)
+	}
 	return nil
 }
 
+// ListRuleGroupVersions implements rules.RuleStore.
+func (b *BucketRuleStore) ListRuleGroupVersions(ctx context.Context, userID, namespace, group string) ([]versioning.Version, error) {
+	return b.getRuleGroupHistory(userID, namespace, group).List(ctx)
+}
+
+// GetRuleGroupVersion implements rules.RuleStore.
+func (b *BucketRuleStore) GetRuleGroupVersion(ctx context.Context, userID, namespace, group string, id int) (versioning.Version, *rulespb.RuleGroupDesc, error) {
+	v, data, err := b.getRuleGroupHistory(userID, namespace, group).Get(ctx, id)
+	if err != nil || v.Deleted {
+		return v, nil, err
+	}
+
+	rg := &rulespb.RuleGroupDesc{}
+	if err := proto.Unmarshal(data, rg); err != nil {
+		return v, nil, errors.Wrapf(err, "failed to unmarshal version %d of rule group %s", id, getRuleGroupObjectKey(namespace, group))
+	}
+	return v, rg, nil
+}
+
+// addRuleGroupVersion adds a version to the history of the rule group. The data is nil if the
+// version records the deletion of the rule group. A failure doesn't fail the change of the rule group.
+func (b *BucketRuleStore) addRuleGroupVersion(ctx context.Context, userID, namespace, group string, data []byte) {
+	if b.maxVersions <= 0 {
+		return
+	}
+
+	_, err := b.getRuleGroupHistory(userID, namespace, group).Add(ctx, data, data == nil)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to add version to rule group history", "user", userID, "namespace", namespace, "group", group, "err", err)
+	}
+}
+
+func (b *BucketRuleStore) getRuleGroupHistory(userID, namespace, group string) *versioning.History {
+	userBucket := bucket.NewUserBucketClient(userID, b.historyBucket, b.cfgProvider)
+	return versioning.NewHistory(bucket.NewPrefixedBucketClient(userBucket, getRuleGroupObjectKey(namespace, group)), b.maxVersions, b.logger)
+}
+
 func getNamespacePrefix(namespace string) string {
 	return base64.URLEncoding.EncodeToString([]byte(namespace)) + objstore.DirDelim
 }
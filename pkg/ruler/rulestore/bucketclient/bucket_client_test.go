@@ -24,6 +24,7 @@ import (
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 type testGroup struct {
@@ -32,7 +33,7 @@ type testGroup struct {
 }
 
 func TestListRules(t *testing.T) {
-	rs := NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	rs := NewBucketRuleStore(objstore.NewInMemBucket(), nil, 0, log.NewNopLogger())
 
 	groups := []testGroup{
 		{user: "user1", namespace: "hello", ruleGroup: rulefmt.RuleGroup{Name: "first testGroup"}},
@@ -93,7 +94,7 @@ func TestListRules(t *testing.T) {
 }
 
 func TestLoadRules(t *testing.T) {
-	rs := NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	rs := NewBucketRuleStore(objstore.NewInMemBucket(), nil, 0, log.NewNopLogger())
 	groups := []testGroup{
 		{user: "user1", namespace: "hello", ruleGroup: rulefmt.RuleGroup{Name: "first testGroup", Interval: model.Duration(time.Minute), Rules: []rulefmt.RuleNode{{
 			For:    model.Duration(5 * time.Minute),
@@ -169,7 +170,7 @@ func TestLoadRules(t *testing.T) {
 
 func TestDelete(t *testing.T) {
 	bucketClient := objstore.NewInMemBucket()
-	rs := NewBucketRuleStore(bucketClient, nil, log.NewNopLogger())
+	rs := NewBucketRuleStore(bucketClient, nil, 0, log.NewNopLogger())
 
 	groups := []testGroup{
 		{user: "user1", namespace: "A", ruleGroup: rulefmt.RuleGroup{Name: "1"}},
@@ -229,6 +230,79 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestRuleGroupVersions(t *testing.T) {
+	ctx := context.Background()
+	bucketClient := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(bucketClient, nil, 2, log.NewNopLogger())
+
+	setGroup := func(ctx context.Context, namespace, name string, interval time.Duration) {
+		desc := rulespb.ToProto("user1", namespace, rulefmt.RuleGroup{Name: name, Interval: model.Duration(interval)})
+		require.NoError(t, rs.SetRuleGroup(ctx, "user1", namespace, desc))
+	}
+
+	setGroup(versioning.ContextWithAuthor(ctx, "alice"), "A", "1", time.Minute)
+	setGroup(ctx, "A", "1", 2*time.Minute)
+	setGroup(ctx, "A", "2", time.Minute)
+
+	// The history doesn't interfere with the listing of rule groups.
+	users, err := rs.ListAllUsers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user1"}, users)
+
+	groups, err := rs.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	versions, err := rs.ListRuleGroupVersions(ctx, "user1", "A", "1")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "alice", versions[0].Author)
+	assert.Equal(t, "", versions[1].Author)
+
+	v, rg, err := rs.GetRuleGroupVersion(ctx, "user1", "A", "1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v.ID)
+	assert.Equal(t, time.Minute, rg.Interval)
+
+	// The rule group deletions are recorded in the history, up to the max number of versions.
+	require.NoError(t, rs.DeleteRuleGroup(versioning.ContextWithAuthor(ctx, "bob"), "user1", "A", "1"))
+
+	versions, err = rs.ListRuleGroupVersions(ctx, "user1", "A", "1")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].ID)
+	assert.Equal(t, 3, versions[1].ID)
+	assert.Equal(t, "bob", versions[1].Author)
+	assert.True(t, versions[1].Deleted)
+
+	v, rg, err = rs.GetRuleGroupVersion(ctx, "user1", "A", "1", 3)
+	require.NoError(t, err)
+	assert.True(t, v.Deleted)
+	assert.Nil(t, rg)
+
+	_, _, err = rs.GetRuleGroupVersion(ctx, "user1", "A", "1", 1)
+	assert.ErrorIs(t, err, versioning.ErrVersionNotFound)
+
+	// The history of a namespace is deleted together with the namespace, even if its rule groups
+	// have already been deleted one by one.
+	setGroup(ctx, "B", "1", time.Minute)
+	require.NoError(t, rs.DeleteRuleGroup(ctx, "user1", "A", "2"))
+	require.ErrorIs(t, rs.DeleteNamespace(ctx, "user1", "A"), rulestore.ErrGroupNamespaceNotFound)
+
+	versions, err = rs.ListRuleGroupVersions(ctx, "user1", "A", "2")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	require.Equal(t, []string{
+		"rules-history/user1/" + getRuleGroupObjectKey("B", "1") + "/00000000000000000001",
+		"rules/user1/" + getRuleGroupObjectKey("B", "1"),
+	}, getSortedObjectKeys(bucketClient))
+
+	// The whole history is deleted together with all the rule groups of the tenant.
+	require.NoError(t, rs.DeleteNamespace(ctx, "user1", ""))
+	require.Empty(t, getSortedObjectKeys(bucketClient))
+}
+
 func getSortedObjectKeys(bucketClient interface{}) []string {
 	if typed, ok := bucketClient.(*objstore.InMemBucket); ok {
 		var keys []string
@@ -377,7 +451,7 @@ func TestListAllRuleGroupsWithNoNamespaceOrGroup(t *testing.T) {
 		},
 	}
 
-	s := NewBucketRuleStore(obj, nil, log.NewNopLogger())
+	s := NewBucketRuleStore(obj, nil, 0, log.NewNopLogger())
 	out, err := s.ListRuleGroupsForUserAndNamespace(context.Background(), "user1", "")
 	require.NoError(t, err)
 	require.Equal(t, 0, len(out))
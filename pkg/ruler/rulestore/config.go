@@ -19,6 +19,8 @@ import (
 type Config struct {
 	bucket.Config `yaml:",inline"`
 	Local         local.Config `yaml:"local"`
+
+	MaxVersions int `yaml:"max_versions" category:"experimental"`
 }
 
 // RegisterFlags registers the backend storage config.
@@ -28,6 +30,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.ExtraBackends = []string{local.Name}
 	cfg.Local.RegisterFlagsWithPrefix(prefix, f)
 	cfg.RegisterFlagsWithPrefixAndDefaultDirectory(prefix, "ruler", f)
+	f.IntVar(&cfg.MaxVersions, prefix+"max-versions", 0, "Maximum number of versions to keep in the history of each rule group. The oldest versions are deleted once the limit is exceeded. 0 to disable the history.")
 }
 
 // IsDefaults returns true if the storage options have not been set.
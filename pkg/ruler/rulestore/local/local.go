@@ -16,6 +16,7 @@ import (
 	promRules "github.com/prometheus/prometheus/rules"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 const (
@@ -111,6 +112,22 @@ func (l *Client) DeleteNamespace(ctx context.Context, userID, namespace string)
 	return errors.New("DeleteNamespace unsupported in rule local store")
 }
 
+// ListRuleGroupVersions implements RulerStore
+func (l *Client) ListRuleGroupVersions(ctx context.Context, userID, namespace, group string) ([]versioning.Version, error) {
+	return nil, errors.New("ListRuleGroupVersions unsupported in rule local store")
+}
+
+// GetRuleGroupVersion implements RulerStore
+func (l *Client) GetRuleGroupVersion(ctx context.Context, userID, namespace, group string, id int) (versioning.Version, *rulespb.RuleGroupDesc, error) {
+	return versioning.Version{}, nil, errors.New("GetRuleGroupVersion unsupported in rule local store")
+}
+
+// DeleteHistory implements RulerStore
+func (l *Client) DeleteHistory(ctx context.Context, userID, namespace string) error {
+	// The local store is read-only, so it has no history.
+	return nil
+}
+
 func (l *Client) loadAllRulesGroupsForUser(ctx context.Context, userID string) (rulespb.RuleGroupList, error) {
 	var allLists rulespb.RuleGroupList
 
@@ -10,6 +10,7 @@ import (
 	"errors"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 var (
@@ -47,7 +48,18 @@ type RuleStore interface {
 	// DeleteRuleGroup deletes single rule group.
 	DeleteRuleGroup(ctx context.Context, userID, namespace string, group string) error
 
-	// DeleteNamespace lists rule groups for given user and namespace, and deletes all rule groups.
-	// If namespace is empty, deletes all rule groups for user.
+	// DeleteNamespace lists rule groups for given user and namespace, and deletes all rule groups
+	// and their history. If namespace is empty, deletes all rule groups for user.
 	DeleteNamespace(ctx context.Context, userID, namespace string) error
+
+	// ListRuleGroupVersions returns the versions in the history of the rule group, sorted by ID.
+	ListRuleGroupVersions(ctx context.Context, userID, namespace, group string) ([]versioning.Version, error)
+
+	// GetRuleGroupVersion returns a version in the history of the rule group.
+	// The returned rule group is nil if the version records the deletion of the rule group.
+	GetRuleGroupVersion(ctx context.Context, userID, namespace, group string, id int) (versioning.Version, *rulespb.RuleGroupDesc, error)
+
+	// DeleteHistory deletes the history of all rule groups for given user and namespace.
+	// If namespace is empty, deletes the history of all rule groups for user.
+	DeleteHistory(ctx context.Context, userID, namespace string) error
 }
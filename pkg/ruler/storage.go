@@ -34,7 +34,7 @@ func NewRuleStore(ctx context.Context, cfg rulestore.Config, cfgProvider bucket.
 		return nil, err
 	}
 
-	store := bucketclient.NewBucketRuleStore(bucketClient, cfgProvider, logger)
+	store := bucketclient.NewBucketRuleStore(bucketClient, cfgProvider, cfg.MaxVersions, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/storage/versioning"
 )
 
 type mockRuleStore struct {
@@ -245,3 +246,15 @@ func (m *mockRuleStore) DeleteNamespace(ctx context.Context, userID, namespace s
 
 	return nil
 }
+
+func (m *mockRuleStore) ListRuleGroupVersions(ctx context.Context, userID, namespace, group string) ([]versioning.Version, error) {
+	return nil, nil
+}
+
+func (m *mockRuleStore) DeleteHistory(ctx context.Context, userID, namespace string) error {
+	return nil
+}
+
+func (m *mockRuleStore) GetRuleGroupVersion(ctx context.Context, userID, namespace, group string, id int) (versioning.Version, *rulespb.RuleGroupDesc, error) {
+	return versioning.Version{}, nil, versioning.ErrVersionNotFound
+}
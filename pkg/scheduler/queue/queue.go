@@ -63,14 +63,23 @@ type RequestQueue struct {
 
 	queueLength       *prometheus.GaugeVec   // Per user and reason.
 	discardedRequests *prometheus.CounterVec // Per user.
+	throttledDuration *prometheus.CounterVec // Per user. Optional.
 }
 
-func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, queueLength *prometheus.GaugeVec, discardedRequests *prometheus.CounterVec) *RequestQueue {
+// MaxConcurrentQueriesFunc returns the max number of requests of the user which can be dispatched
+// to queriers at the same time (zero or negative = unlimited).
+type MaxConcurrentQueriesFunc func(userID string) int
+
+// NewRequestQueue makes a new RequestQueue. The throttledDuration metric and the maxConcurrentQueries function
+// are optional. The max concurrent queries limit is evaluated each time a request is dispatched to a querier,
+// so that changes to the limit apply to requests already in the queue.
+func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, queueLength *prometheus.GaugeVec, discardedRequests, throttledDuration *prometheus.CounterVec, maxConcurrentQueries MaxConcurrentQueriesFunc) *RequestQueue {
 	q := &RequestQueue{
-		queues:                  newUserQueues(maxOutstandingPerTenant, forgetDelay),
+		queues:                  newUserQueues(maxOutstandingPerTenant, forgetDelay, maxConcurrentQueries),
 		connectedQuerierWorkers: atomic.NewInt32(0),
 		queueLength:             queueLength,
 		discardedRequests:       discardedRequests,
+		throttledDuration:       throttledDuration,
 	}
 
 	q.cond = contextCond{Cond: sync.NewCond(&q.mtx)}
@@ -138,7 +147,8 @@ FindQueue:
 	}
 
 	for {
-		queue, userID, idx := q.queues.getNextQueueForQuerier(last.last, querierID)
+		now := time.Now()
+		queue, userID, idx := q.queues.getNextQueueForQuerier(last.last, querierID, now)
 		last.last = idx
 		if queue == nil {
 			break
@@ -147,6 +157,9 @@ FindQueue:
 		// Pick next request from the queue.
 		for {
 			request := <-queue
+			if throttled := q.queues.requestDispatched(userID, now); throttled > 0 && q.throttledDuration != nil {
+				q.throttledDuration.WithLabelValues(userID).Add(throttled.Seconds())
+			}
 			if len(queue) == 0 {
 				q.queues.deleteQueue(userID)
 			}
@@ -166,6 +179,19 @@ FindQueue:
 	goto FindQueue
 }
 
+// RequestCompleted must be called once a request returned by GetNextRequestForQuerier has been handled,
+// if the queue enforces the max concurrent queries limit. In-flight requests are tracked for all users,
+// whatever their current limit, so that the limit is correctly enforced even if it changes at runtime.
+func (q *RequestQueue) RequestCompleted(userID string) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	q.queues.requestCompleted(userID)
+
+	// Notify queriers waiting for a request, since this user's queue may have been unblocked.
+	q.cond.Broadcast()
+}
+
 func (q *RequestQueue) forgetDisconnectedQueriers(_ context.Context) error {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
@@ -15,6 +15,7 @@ import (
 
 	"github.com/grafana/dskit/services"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -30,6 +31,7 @@ func BenchmarkGetNextRequest(b *testing.B) {
 		queue := NewRequestQueue(maxOutstandingPerTenant, 0,
 			prometheus.NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 			prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+			nil, nil,
 		)
 		queues = append(queues, queue)
 
@@ -87,6 +89,7 @@ func BenchmarkQueueRequest(b *testing.B) {
 		q := NewRequestQueue(maxOutstandingPerTenant, 0,
 			prometheus.NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 			prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+			nil, nil,
 		)
 
 		for ix := 0; ix < queriers; ix++ {
@@ -119,7 +122,8 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 
 	queue := NewRequestQueue(1, forgetDelay,
 		prometheus.NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
-		prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"}))
+		prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+		nil, nil)
 
 	// Start the queue service.
 	ctx := context.Background()
@@ -156,6 +160,135 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 	assert.GreaterOrEqual(t, waitTime.Milliseconds(), forgetDelay.Milliseconds())
 }
 
+func TestRequestQueue_GetNextRequestForQuerier_ShouldHonorMaxConcurrentQueries(t *testing.T) {
+	throttledDuration := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"})
+	queue := NewRequestQueue(10, 0,
+		prometheus.NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
+		prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+		throttledDuration,
+		func(string) int { return 1 })
+
+	ctx := context.Background()
+	require.NoError(t, services.StartAndAwaitRunning(ctx, queue))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, queue))
+	})
+
+	queue.RegisterQuerierConnection("querier-1")
+
+	require.NoError(t, queue.EnqueueRequest("user-1", "request-1", 0, nil))
+	require.NoError(t, queue.EnqueueRequest("user-1", "request-2", 0, nil))
+	require.NoError(t, queue.EnqueueRequest("user-2", "request-3", 0, nil))
+
+	req, last, err := queue.GetNextRequestForQuerier(ctx, FirstUser(), "querier-1")
+	require.NoError(t, err)
+	assert.Equal(t, "request-1", req)
+
+	// user-1 has reached the limit, so the next request is picked from user-2.
+	req, last, err = queue.GetNextRequestForQuerier(ctx, last, "querier-1")
+	require.NoError(t, err)
+	assert.Equal(t, "request-3", req)
+
+	// No request can be dispatched until a user-1 request completes.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		req, _, err := queue.GetNextRequestForQuerier(ctx, last, "querier-1")
+		require.NoError(t, err)
+		assert.Equal(t, "request-2", req)
+	}()
+
+	assertChanNotReceived(t, done, 100*time.Millisecond, "request has been dispatched even if user-1 reached the max concurrent queries limit")
+
+	queue.RequestCompleted("user-1")
+	assertChanReceived(t, done, time.Second, "request has not been dispatched after a user-1 request completed")
+
+	assert.Greater(t, testutil.ToFloat64(throttledDuration.WithLabelValues("user-1")), 0.0)
+	assert.Equal(t, 0.0, testutil.ToFloat64(throttledDuration.WithLabelValues("user-2")))
+}
+
+func TestRequestQueue_GetNextRequestForQuerier_ShouldHonorMaxConcurrentQueriesChangedAtRuntime(t *testing.T) {
+	var (
+		limitMx sync.Mutex
+		limit   = 0
+	)
+	setLimit := func(l int) {
+		limitMx.Lock()
+		defer limitMx.Unlock()
+		limit = l
+	}
+
+	queue := NewRequestQueue(10, 0,
+		prometheus.NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
+		prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
+		nil,
+		func(string) int {
+			limitMx.Lock()
+			defer limitMx.Unlock()
+			return limit
+		})
+
+	ctx := context.Background()
+	require.NoError(t, services.StartAndAwaitRunning(ctx, queue))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, queue))
+	})
+
+	queue.RegisterQuerierConnection("querier-1")
+
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, queue.EnqueueRequest("user-1", fmt.Sprintf("request-%d", i), 0, nil))
+	}
+
+	// Dispatch two requests while the user has no limit.
+	last := FirstUser()
+	for i := 1; i <= 2; i++ {
+		req, idx, err := queue.GetNextRequestForQuerier(ctx, last, "querier-1")
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("request-%d", i), req)
+		last = idx
+	}
+
+	// The limit is lowered while the requests are in-flight (e.g. a time window opens), so queued
+	// requests must not be dispatched until enough in-flight requests complete.
+	setLimit(2)
+
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+
+		req, _, err := queue.GetNextRequestForQuerier(ctx, last, "querier-1")
+		require.NoError(t, err)
+		assert.Equal(t, "request-3", req)
+	}()
+
+	assertChanNotReceived(t, dispatched, 100*time.Millisecond, "request has been dispatched even if user-1 reached the max concurrent queries limit")
+
+	queue.RequestCompleted("user-1")
+	assertChanReceived(t, dispatched, time.Second, "request has not been dispatched after a user-1 request completed")
+
+	// The requests dispatched while the user had no limit are still accounted for.
+	queue.mtx.Lock()
+	assert.Equal(t, 2, queue.queues.inflight["user-1"])
+	queue.mtx.Unlock()
+
+	// Removing the limit (e.g. a time window closes) unblocks the queue at the next dispatch.
+	setLimit(0)
+
+	req, _, err := queue.GetNextRequestForQuerier(ctx, last, "querier-1")
+	require.NoError(t, err)
+	assert.Equal(t, "request-4", req)
+
+	for i := 0; i < 3; i++ {
+		queue.RequestCompleted("user-1")
+	}
+
+	queue.mtx.Lock()
+	assert.NotContains(t, queue.queues.inflight, "user-1")
+	queue.mtx.Unlock()
+}
+
 func TestContextCond(t *testing.T) {
 	t.Run("wait until broadcast", func(t *testing.T) {
 		t.Parallel()
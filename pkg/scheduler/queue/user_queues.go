@@ -46,6 +46,14 @@ type queues struct {
 
 	// Sorted list of querier names, used when creating per-user shard.
 	sortedQueriers []string
+
+	// Number of requests dispatched to queriers and not completed yet, per user. Tracked for
+	// all users, whatever their limit, if the max concurrent queries limit is enforced.
+	inflight map[string]int
+
+	// Optional max concurrent queries limit, evaluated when choosing the next queue to dispatch from.
+	// If nil, the limit is not enforced and in-flight requests are not tracked.
+	maxConcurrentQueries MaxConcurrentQueriesFunc
 }
 
 type userQueue struct {
@@ -56,6 +64,10 @@ type userQueue struct {
 	queriers    map[string]struct{}
 	maxQueriers int
 
+	// Since when this user is waiting for in-flight requests to complete because it hit
+	// the max concurrent queries limit.
+	throttledSince time.Time
+
 	// Seed for shuffle sharding of queriers. This seed is based on userID only and is therefore consistent
 	// between different frontends.
 	seed int64
@@ -64,14 +76,16 @@ type userQueue struct {
 	index int
 }
 
-func newUserQueues(maxUserQueueSize int, forgetDelay time.Duration) *queues {
+func newUserQueues(maxUserQueueSize int, forgetDelay time.Duration, maxConcurrentQueries MaxConcurrentQueriesFunc) *queues {
 	return &queues{
-		userQueues:       map[string]*userQueue{},
-		users:            nil,
-		maxUserQueueSize: maxUserQueueSize,
-		forgetDelay:      forgetDelay,
-		queriers:         map[string]*querier{},
-		sortedQueriers:   nil,
+		userQueues:           map[string]*userQueue{},
+		users:                nil,
+		maxUserQueueSize:     maxUserQueueSize,
+		forgetDelay:          forgetDelay,
+		queriers:             map[string]*querier{},
+		sortedQueriers:       nil,
+		inflight:             map[string]int{},
+		maxConcurrentQueries: maxConcurrentQueries,
 	}
 }
 
@@ -144,8 +158,8 @@ func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
 
 // Finds next queue for the querier. To support fair scheduling between users, client is expected
 // to pass last user index returned by this function as argument. Is there was no previous
-// last user index, use -1.
-func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (chan Request, string, int) {
+// last user index, use -1. Users which have reached their max concurrent queries limit are skipped.
+func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string, now time.Time) (chan Request, string, int) {
 	uid := lastUserIndex
 
 	for iters := 0; iters < len(q.users); iters++ {
@@ -162,20 +176,73 @@ func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (ch
 			continue
 		}
 
-		q := q.userQueues[u]
+		uq := q.userQueues[u]
 
-		if q.queriers != nil {
-			if _, ok := q.queriers[querierID]; !ok {
+		if uq.queriers != nil {
+			if _, ok := uq.queriers[querierID]; !ok {
 				// This querier is not handling the user.
 				continue
 			}
 		}
 
-		return q.ch, u, uid
+		if q.isThrottled(u) {
+			if uq.throttledSince.IsZero() {
+				uq.throttledSince = now
+			}
+			continue
+		}
+
+		return uq.ch, u, uid
 	}
 	return nil, "", uid
 }
 
+// isThrottled returns whether the user has reached its max concurrent queries limit. The limit is
+// evaluated at each call, so that changes (e.g. time windows opening or closing) apply immediately.
+func (q *queues) isThrottled(userID string) bool {
+	if q.maxConcurrentQueries == nil {
+		return false
+	}
+	limit := q.maxConcurrentQueries(userID)
+	return limit > 0 && q.inflight[userID] >= limit
+}
+
+// requestDispatched is called when a request of the user has been taken off its queue. It returns
+// for how long the user has been throttled because of the max concurrent queries limit, if it was.
+func (q *queues) requestDispatched(userID string, now time.Time) time.Duration {
+	if q.maxConcurrentQueries == nil {
+		return 0
+	}
+
+	q.inflight[userID]++
+
+	uq := q.userQueues[userID]
+	if uq == nil || uq.throttledSince.IsZero() {
+		return 0
+	}
+	throttled := now.Sub(uq.throttledSince)
+	uq.throttledSince = time.Time{}
+	return throttled
+}
+
+// requestCompleted is called when a request of the user dispatched to a querier has completed.
+func (q *queues) requestCompleted(userID string) {
+	if q.maxConcurrentQueries == nil {
+		return
+	}
+
+	n, ok := q.inflight[userID]
+	if !ok || n <= 0 {
+		panic("unexpected number of in-flight requests for user")
+	}
+
+	if n == 1 {
+		delete(q.inflight, userID)
+		return
+	}
+	q.inflight[userID] = n - 1
+}
+
 func (q *queues) addQuerierConnection(querierID string) {
 	info := q.queriers[querierID]
 	if info != nil {
@@ -18,11 +18,11 @@ import (
 )
 
 func TestQueues(t *testing.T) {
-	uq := newUserQueues(0, 0)
+	uq := newUserQueues(0, 0, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
-	q, u, lastUserIndex := uq.getNextQueueForQuerier(-1, "querier-1")
+	q, u, lastUserIndex := uq.getNextQueueForQuerier(-1, "querier-1", time.Now())
 	assert.Nil(t, q)
 	assert.Equal(t, "", u)
 
@@ -68,12 +68,12 @@ func TestQueues(t *testing.T) {
 	uq.deleteQueue("four")
 	assert.NoError(t, isConsistent(uq))
 
-	q, _, _ = uq.getNextQueueForQuerier(lastUserIndex, "querier-1")
+	q, _, _ = uq.getNextQueueForQuerier(lastUserIndex, "querier-1", time.Now())
 	assert.Nil(t, q)
 }
 
 func TestQueuesWithQueriers(t *testing.T) {
-	uq := newUserQueues(0, 0)
+	uq := newUserQueues(0, 0, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -87,7 +87,7 @@ func TestQueuesWithQueriers(t *testing.T) {
 		uq.addQuerierConnection(qid)
 
 		// No querier has any queues yet.
-		q, u, _ := uq.getNextQueueForQuerier(-1, qid)
+		q, u, _ := uq.getNextQueueForQuerier(-1, qid, time.Now())
 		assert.Nil(t, q)
 		assert.Equal(t, "", u)
 	}
@@ -113,7 +113,7 @@ func TestQueuesWithQueriers(t *testing.T) {
 
 		lastUserIndex := -1
 		for {
-			_, _, newIx := uq.getNextQueueForQuerier(lastUserIndex, qid)
+			_, _, newIx := uq.getNextQueueForQuerier(lastUserIndex, qid, time.Now())
 			if newIx < lastUserIndex {
 				break
 			}
@@ -150,7 +150,7 @@ func TestQueuesConsistency(t *testing.T) {
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
-			uq := newUserQueues(0, testData.forgetDelay)
+			uq := newUserQueues(0, testData.forgetDelay, nil)
 			assert.NotNil(t, uq)
 			assert.NoError(t, isConsistent(uq))
 
@@ -166,7 +166,7 @@ func TestQueuesConsistency(t *testing.T) {
 					assert.NotNil(t, uq.getOrAddQueue(generateTenant(r), 3))
 				case 1:
 					qid := generateQuerier(r)
-					_, _, luid := uq.getNextQueueForQuerier(lastUserIndexes[qid], qid)
+					_, _, luid := uq.getNextQueueForQuerier(lastUserIndexes[qid], qid, time.Now())
 					lastUserIndexes[qid] = luid
 				case 2:
 					uq.deleteQueue(generateTenant(r))
@@ -199,7 +199,7 @@ func TestQueues_ForgetDelay(t *testing.T) {
 	)
 
 	now := time.Now()
-	uq := newUserQueues(0, forgetDelay)
+	uq := newUserQueues(0, forgetDelay, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -291,7 +291,7 @@ func TestQueues_ForgetDelay_ShouldCorrectlyHandleQuerierReconnectingBeforeForget
 	)
 
 	now := time.Now()
-	uq := newUserQueues(0, forgetDelay)
+	uq := newUserQueues(0, forgetDelay, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -375,7 +375,7 @@ func getOrAdd(t *testing.T, uq *queues, tenant string, maxQueriers int) chan Req
 func confirmOrderForQuerier(t *testing.T, uq *queues, querier string, lastUserIndex int, qs ...chan Request) int {
 	var n chan Request
 	for _, q := range qs {
-		n, _, lastUserIndex = uq.getNextQueueForQuerier(lastUserIndex, querier)
+		n, _, lastUserIndex = uq.getNextQueueForQuerier(lastUserIndex, querier, time.Now())
 		assert.Equal(t, q, n)
 		assert.NoError(t, isConsistent(uq))
 	}
@@ -69,6 +69,7 @@ type Scheduler struct {
 	connectedQuerierClients  prometheus.GaugeFunc
 	connectedFrontendClients prometheus.GaugeFunc
 	queueDuration            prometheus.Histogram
+	throttledDuration        *prometheus.CounterVec
 	inflightRequests         prometheus.Summary
 }
 
@@ -118,7 +119,11 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		Name: "cortex_query_scheduler_discarded_requests_total",
 		Help: "Total number of query requests discarded.",
 	}, []string{"user"})
-	s.requestQueue = queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, s.queueLength, s.discardedRequests)
+	s.throttledDuration = promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_query_scheduler_throttled_duration_seconds_total",
+		Help: "Total time queued queries have waited because the tenant reached the max concurrent queries limit.",
+	}, []string{"user"})
+	s.requestQueue = queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, s.queueLength, s.discardedRequests, s.throttledDuration, s.maxConcurrentQueries)
 
 	s.queueDuration = promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
 		Name:    "cortex_query_scheduler_queue_duration_seconds",
@@ -158,6 +163,9 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 type Limits interface {
 	// MaxQueriersPerUser returns max queriers to use per tenant, or 0 if shuffle sharding is disabled.
 	MaxQueriersPerUser(user string) int
+
+	// MaxConcurrentQueries returns max number of requests per tenant dispatched to queriers at the same time, or 0 if unlimited.
+	MaxConcurrentQueries(user string) int
 }
 
 type schedulerRequest struct {
@@ -331,6 +339,17 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	})
 }
 
+// maxConcurrentQueries returns the max concurrent queries limit of the (possibly multi tenant) user.
+// It's called by the queue each time it looks for a request to dispatch, so that the limit in effect
+// at dispatch time is honored.
+func (s *Scheduler) maxConcurrentQueries(userID string) int {
+	tenantIDs, err := tenant.TenantIDsFromOrgID(userID)
+	if err != nil {
+		return 0
+	}
+	return validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, s.limits.MaxConcurrentQueries)
+}
+
 // This method doesn't do removal from the queue.
 func (s *Scheduler) cancelRequestAndRemoveFromPending(frontendAddr string, queryID uint64) {
 	s.pendingRequestsMu.Lock()
@@ -387,12 +406,15 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 		if r.ctx.Err() != nil {
 			// Remove from pending requests.
 			s.cancelRequestAndRemoveFromPending(r.frontendAddress, r.queryID)
+			s.requestQueue.RequestCompleted(r.userID)
 
 			lastUserIndex = lastUserIndex.ReuseLastUser()
 			continue
 		}
 
-		if err := s.forwardRequestToQuerier(querier, r); err != nil {
+		err = s.forwardRequestToQuerier(querier, r)
+		s.requestQueue.RequestCompleted(r.userID)
+		if err != nil {
 			return err
 		}
 	}
@@ -534,6 +556,7 @@ func (s *Scheduler) stopping(_ error) error {
 func (s *Scheduler) cleanupMetricsForInactiveUser(user string) {
 	s.queueLength.DeleteLabelValues(user)
 	s.discardedRequests.DeleteLabelValues(user)
+	s.throttledDuration.DeleteLabelValues(user)
 }
 
 func (s *Scheduler) getConnectedFrontendClientsMetric() float64 {
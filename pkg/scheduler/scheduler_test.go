@@ -499,13 +499,18 @@ func verifyNoPendingRequestsLeft(t *testing.T, scheduler *Scheduler) {
 }
 
 type limits struct {
-	queriers int
+	queriers          int
+	concurrentQueries int
 }
 
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
 
+func (l limits) MaxConcurrentQueries(_ string) int {
+	return l.concurrentQueries
+}
+
 type frontendMock struct {
 	mu   sync.Mutex
 	resp map[uint64]*httpgrpc.HTTPResponse
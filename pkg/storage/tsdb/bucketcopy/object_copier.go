@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketcopy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/runutil"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+)
+
+// objectCopier copies block files and markers from a source to a destination bucket.
+type objectCopier struct {
+	src objstore.Bucket
+	dst objstore.Bucket
+
+	// verifyChecksums enables reading back each copied object from the destination bucket
+	// and comparing its SHA256 checksum with the source object.
+	verifyChecksums bool
+
+	// dryRun reads the source objects without writing to the destination bucket.
+	dryRun bool
+
+	logger log.Logger
+}
+
+// copyBlock copies all the files of the block and uploads meta last, so that a block whose
+// meta.json exists in the destination is known to be complete. Markers are not copied.
+// It returns the number of objects and bytes copied, excluding the meta.json.
+func (c objectCopier) copyBlock(ctx context.Context, blockID ulid.ULID, meta *metadata.Meta) (objects int, size int64, _ error) {
+	expectedSizes := map[string]int64{}
+	for _, f := range meta.Thanos.Files {
+		if f.SizeBytes > 0 {
+			expectedSizes[f.RelPath] = f.SizeBytes
+		}
+	}
+
+	var names []string
+	err := c.src.Iter(ctx, blockID.String(), func(name string) error {
+		switch path.Base(name) {
+		case block.MetaFilename, metadata.DeletionMarkFilename, metadata.NoCompactMarkFilename:
+			// The meta.json is uploaded last, while markers are copied separately.
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	}, objstore.WithRecursiveIter)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "list block files")
+	}
+
+	for _, name := range names {
+		relPath := strings.TrimPrefix(name, blockID.String()+"/")
+
+		n, err := c.copyObject(ctx, name, expectedSizes[relPath])
+		if err != nil {
+			return objects, size, err
+		}
+
+		objects++
+		size += n
+	}
+
+	buf := bytes.Buffer{}
+	if err := meta.Write(&buf); err != nil {
+		return objects, size, errors.Wrap(err, "encode block meta")
+	}
+
+	if c.dryRun {
+		return objects, size, nil
+	}
+
+	return objects, size, errors.Wrap(c.dst.Upload(ctx, path.Join(blockID.String(), block.MetaFilename), &buf), "upload block meta")
+}
+
+// copyObject copies a single object and returns its size. If expectedSize is greater than zero,
+// the size of the source object is checked against it.
+func (c objectCopier) copyObject(ctx context.Context, name string, expectedSize int64) (int64, error) {
+	r, err := c.src.Get(ctx, name)
+	if err != nil {
+		return 0, errors.Wrapf(err, "read %s", name)
+	}
+	defer runutil.CloseWithLogOnErr(c.logger, r, "close source object %s", name)
+
+	srcHash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(r, srcHash)}
+
+	if c.dryRun {
+		_, err = io.Copy(io.Discard, counter)
+	} else {
+		err = c.dst.Upload(ctx, name, counter)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "copy %s", name)
+	}
+
+	if expectedSize > 0 && counter.n != expectedSize {
+		return 0, errors.Wrapf(errSizeMismatch, "%s: expected %d bytes, read %d bytes", name, expectedSize, counter.n)
+	}
+
+	if c.verifyChecksums && !c.dryRun {
+		if err := c.verifyChecksum(ctx, name, hex.EncodeToString(srcHash.Sum(nil))); err != nil {
+			return 0, err
+		}
+	}
+
+	return counter.n, nil
+}
+
+func (c objectCopier) verifyChecksum(ctx context.Context, name, expected string) error {
+	r, err := c.dst.Get(ctx, name)
+	if err != nil {
+		return errors.Wrapf(err, "read back %s", name)
+	}
+	defer runutil.CloseWithLogOnErr(c.logger, r, "close destination object %s", name)
+
+	dstHash := sha256.New()
+	if _, err := io.Copy(dstHash, r); err != nil {
+		return errors.Wrapf(err, "read back %s", name)
+	}
+
+	if actual := hex.EncodeToString(dstHash.Sum(nil)); actual != expected {
+		return errors.Wrapf(errChecksumMismatch, "%s: expected sha256 %s, got %s", name, expected, actual)
+	}
+	return nil
+}
+
+// copyMarkIfMissing copies a block marker if it exists in the source but not in the destination bucket.
+// If the destination is a global markers bucket client, uploading the marker writes the global marker too.
+func (c objectCopier) copyMarkIfMissing(ctx context.Context, name string) (bool, error) {
+	r, err := c.src.Get(ctx, name)
+	if c.src.IsObjNotFoundErr(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "read %s", name)
+	}
+	defer runutil.CloseWithLogOnErr(c.logger, r, "close source object %s", name)
+
+	exists, err := c.dst.Exists(ctx, name)
+	if err != nil || exists {
+		return false, errors.Wrapf(err, "check %s in the destination bucket", name)
+	}
+
+	if c.dryRun {
+		return true, nil
+	}
+	return true, errors.Wrapf(c.dst.Upload(ctx, name, r), "upload %s", name)
+}
+
+// readMeta reads the meta.json of the block from bkt.
+func readMeta(ctx context.Context, bkt objstore.Bucket, blockID ulid.ULID) (*metadata.Meta, error) {
+	r, err := bkt.Get(ctx, path.Join(blockID.String(), block.MetaFilename))
+	if bkt.IsObjNotFoundErr(err) {
+		return nil, bucketindex.ErrBlockMetaNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read block meta")
+	}
+
+	meta, err := metadata.Read(r)
+	if err != nil {
+		return nil, errors.Wrap(bucketindex.ErrBlockMetaCorrupted, err.Error())
+	}
+	return meta, nil
+}
+
+// updateBucketIndex regenerates the bucket index of the tenant in the destination bucket.
+func updateBucketIndex(ctx context.Context, dstRoot objstore.Bucket, userID string, cfgProvider bucket.TenantConfigProvider, logger log.Logger) error {
+	old, err := bucketindex.ReadIndex(ctx, dstRoot, userID, cfgProvider, logger)
+	if err != nil && !errors.Is(err, bucketindex.ErrIndexNotFound) && !errors.Is(err, bucketindex.ErrIndexCorrupted) {
+		return errors.Wrap(err, "read destination bucket index")
+	}
+
+	idx, _, err := bucketindex.NewUpdater(dstRoot, userID, cfgProvider, logger).UpdateIndex(ctx, old)
+	if err != nil {
+		return errors.Wrap(err, "update destination bucket index")
+	}
+
+	return errors.Wrap(bucketindex.WriteIndex(ctx, dstRoot, userID, cfgProvider, idx), "write destination bucket index")
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
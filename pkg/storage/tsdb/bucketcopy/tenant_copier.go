@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketcopy
+
+import (
+	"context"
+	"path"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+)
+
+var (
+	errSourceTenantMarkedForDeletion = errors.New("source tenant is marked for deletion")
+	errChecksumMismatch              = errors.New("checksum mismatch")
+	errSizeMismatch                  = errors.New("size mismatch")
+)
+
+// Config holds the configuration of a TenantCopier.
+type Config struct {
+	// SourceTenant is the tenant whose blocks are read from the source bucket.
+	SourceTenant string
+
+	// DestinationTenant is the tenant the blocks are written to in the destination bucket.
+	// If empty, SourceTenant is used.
+	DestinationTenant string
+
+	// VerifyChecksums enables reading back each copied object from the destination bucket
+	// and comparing its SHA256 checksum with the source object.
+	VerifyChecksums bool
+
+	// CopyConcurrency is the number of blocks copied concurrently.
+	CopyConcurrency int
+
+	// DryRun only reports what would be copied, without writing to the destination bucket.
+	DryRun bool
+}
+
+func (cfg Config) destinationTenant() string {
+	if cfg.DestinationTenant == "" {
+		return cfg.SourceTenant
+	}
+	return cfg.DestinationTenant
+}
+
+// Stats summarises the outcome of a copy run.
+type Stats struct {
+	BlocksCopied   int
+	BlocksSkipped  int
+	MarkersCopied  int
+	ObjectsCopied  int
+	BytesCopied    int64
+	PartialsFound  int
+	IndexGenerated bool
+}
+
+// TenantCopier copies a tenant's blocks, their markers and the bucket index from a
+// source bucket to a destination bucket.
+//
+// The copy is resumable: a block's meta.json is uploaded only after all other block
+// files have been successfully copied, so a block whose meta.json already exists in
+// the destination is considered complete and is skipped, while a block interrupted
+// halfway is copied again from scratch on the next run.
+type TenantCopier struct {
+	cfg         Config
+	srcRoot     objstore.Bucket
+	dstRoot     objstore.Bucket
+	srcBucket   objstore.Bucket
+	dstBucket   objstore.Bucket
+	copier      objectCopier
+	cfgProvider bucket.TenantConfigProvider
+	logger      log.Logger
+}
+
+// NewTenantCopier makes a new TenantCopier. srcBucket and dstBucket must not be prefixed
+// with the tenant ID.
+func NewTenantCopier(cfg Config, srcBucket, dstBucket objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *TenantCopier {
+	if cfg.CopyConcurrency <= 0 {
+		cfg.CopyConcurrency = 1
+	}
+
+	c := &TenantCopier{
+		cfg:         cfg,
+		srcRoot:     srcBucket,
+		dstRoot:     dstBucket,
+		srcBucket:   bucket.NewUserBucketClient(cfg.SourceTenant, srcBucket, cfgProvider),
+		dstBucket:   bucketindex.BucketWithGlobalMarkers(bucket.NewUserBucketClient(cfg.destinationTenant(), dstBucket, cfgProvider)),
+		cfgProvider: cfgProvider,
+		logger:      log.With(logger, "source_user", cfg.SourceTenant, "destination_user", cfg.destinationTenant()),
+	}
+	c.copier = objectCopier{
+		src:             c.srcBucket,
+		dst:             c.dstBucket,
+		verifyChecksums: cfg.VerifyChecksums,
+		dryRun:          cfg.DryRun,
+		logger:          c.logger,
+	}
+	return c
+}
+
+// CopyBlocks copies all blocks which are not yet in the destination bucket, propagates block
+// markers of already copied blocks, and finally regenerates the destination bucket index.
+func (c *TenantCopier) CopyBlocks(ctx context.Context) (Stats, error) {
+	var (
+		stats   Stats
+		statsMx sync.Mutex
+	)
+
+	deleted, err := mimir_tsdb.TenantDeletionMarkExists(ctx, c.srcRoot, c.cfg.SourceTenant)
+	if err != nil {
+		return stats, errors.Wrap(err, "check source tenant deletion mark")
+	}
+	if deleted {
+		return stats, errSourceTenantMarkedForDeletion
+	}
+
+	var blockIDs []interface{}
+	err = c.srcBucket.Iter(ctx, "", func(name string) error {
+		if id, ok := block.IsBlockDir(name); ok {
+			blockIDs = append(blockIDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, errors.Wrap(err, "list source blocks")
+	}
+
+	level.Info(c.logger).Log("msg", "discovered blocks in the source bucket", "blocks", len(blockIDs))
+
+	err = concurrency.ForEach(ctx, blockIDs, c.cfg.CopyConcurrency, func(ctx context.Context, job interface{}) error {
+		blockStats, err := c.copyBlock(ctx, job.(ulid.ULID))
+		if err != nil {
+			return errors.Wrapf(err, "copy block %s", job.(ulid.ULID).String())
+		}
+
+		statsMx.Lock()
+		stats.add(blockStats)
+		statsMx.Unlock()
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	if c.cfg.DryRun {
+		return stats, nil
+	}
+
+	if err := updateBucketIndex(ctx, c.dstRoot, c.cfg.destinationTenant(), c.cfgProvider, c.logger); err != nil {
+		return stats, err
+	}
+	stats.IndexGenerated = true
+
+	return stats, nil
+}
+
+func (s *Stats) add(o Stats) {
+	s.BlocksCopied += o.BlocksCopied
+	s.BlocksSkipped += o.BlocksSkipped
+	s.MarkersCopied += o.MarkersCopied
+	s.ObjectsCopied += o.ObjectsCopied
+	s.BytesCopied += o.BytesCopied
+	s.PartialsFound += o.PartialsFound
+}
+
+func (c *TenantCopier) copyBlock(ctx context.Context, blockID ulid.ULID) (Stats, error) {
+	var stats Stats
+	logger := log.With(c.logger, "block", blockID.String())
+
+	meta, err := readMeta(ctx, c.srcBucket, blockID)
+	if errors.Is(err, bucketindex.ErrBlockMetaNotFound) {
+		// The block may still be in the process of being uploaded: we'll pick it up on the next run.
+		level.Warn(logger).Log("msg", "skipped partial block in the source bucket")
+		stats.PartialsFound++
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	alreadyCopied, err := c.dstBucket.Exists(ctx, path.Join(blockID.String(), block.MetaFilename))
+	if err != nil {
+		return stats, errors.Wrap(err, "check block in the destination bucket")
+	}
+
+	markedForDeletion, err := c.srcBucket.Exists(ctx, path.Join(blockID.String(), metadata.DeletionMarkFilename))
+	if err != nil {
+		return stats, errors.Wrap(err, "check block deletion mark")
+	}
+
+	// A block marked for deletion has already been replaced by another block (eg. compacted),
+	// so it's not worth copying it. If it has been copied in a previous run, we just propagate
+	// the marker and let the destination compactor delete it.
+	if !alreadyCopied && markedForDeletion {
+		level.Debug(logger).Log("msg", "skipped block marked for deletion in the source bucket")
+		stats.BlocksSkipped++
+		return stats, nil
+	}
+
+	if alreadyCopied {
+		stats.BlocksSkipped++
+	} else {
+		// Rewrite the tenant ID external label, if any, so that the block is correctly
+		// attributed to the destination tenant.
+		if _, ok := meta.Thanos.Labels[mimir_tsdb.TenantIDExternalLabel]; ok {
+			meta.Thanos.Labels[mimir_tsdb.TenantIDExternalLabel] = c.cfg.destinationTenant()
+		}
+
+		objects, size, err := c.copier.copyBlock(ctx, blockID, meta)
+		stats.ObjectsCopied += objects
+		stats.BytesCopied += size
+		if err != nil {
+			return stats, err
+		}
+		stats.BlocksCopied++
+		level.Info(logger).Log("msg", "copied block", "objects", stats.ObjectsCopied, "bytes", stats.BytesCopied)
+	}
+
+	for _, markName := range []string{metadata.DeletionMarkFilename, metadata.NoCompactMarkFilename} {
+		copied, err := c.copier.copyMarkIfMissing(ctx, path.Join(blockID.String(), markName))
+		if err != nil {
+			return stats, err
+		}
+		if copied {
+			stats.MarkersCopied++
+		}
+	}
+
+	return stats, nil
+}
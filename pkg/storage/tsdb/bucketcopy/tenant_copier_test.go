@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketcopy
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestTenantCopier_CopyBlocks(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	extLabels := map[string]string{mimir_tsdb.TenantIDExternalLabel: "user-1"}
+	block1 := mimir_testutil.MockStorageBlockWithExtLabels(t, srcBkt, "user-1", 10, 20, extLabels)
+	block2 := mimir_testutil.MockStorageBlockWithExtLabels(t, srcBkt, "user-1", 20, 30, extLabels)
+	block3 := mimir_testutil.MockStorageBlockWithExtLabels(t, srcBkt, "user-1", 30, 40, extLabels)
+	mimir_testutil.MockStorageDeletionMark(t, srcBkt, "user-1", block3.BlockMeta)
+	mimir_testutil.MockNoCompactMark(t, srcBkt, "user-1", block2.BlockMeta)
+
+	// Partial block: no meta.json.
+	partial := mimir_testutil.MockStorageBlock(t, srcBkt, "user-1", 40, 50)
+	require.NoError(t, srcBkt.Delete(ctx, path.Join("user-1", partial.ULID.String(), block.MetaFilename)))
+
+	// Block of another tenant, which shouldn't be copied.
+	mimir_testutil.MockStorageBlock(t, srcBkt, "user-2", 10, 20)
+
+	cfg := Config{SourceTenant: "user-1", DestinationTenant: "user-1-copy", VerifyChecksums: true, CopyConcurrency: 2}
+	stats, err := NewTenantCopier(cfg, srcBkt, dstBkt, nil, logger).CopyBlocks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.BlocksCopied)
+	assert.Equal(t, 1, stats.BlocksSkipped)
+	assert.Equal(t, 1, stats.MarkersCopied)
+	assert.Equal(t, 1, stats.PartialsFound)
+	assert.True(t, stats.IndexGenerated)
+
+	// The tenant ID external label should have been rewritten.
+	for _, id := range []string{block1.ULID.String(), block2.ULID.String()} {
+		r, err := dstBkt.Get(ctx, path.Join("user-1-copy", id, block.MetaFilename))
+		require.NoError(t, err)
+		meta, err := metadata.Read(r)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1-copy", meta.Thanos.Labels[mimir_tsdb.TenantIDExternalLabel])
+
+		exists, err := dstBkt.Exists(ctx, path.Join("user-1-copy", id, block.IndexFilename))
+		require.NoError(t, err)
+		assert.True(t, exists)
+	}
+
+	// The no-compact mark should have been copied, in both the block and global location.
+	for _, name := range []string{
+		path.Join("user-1-copy", block2.ULID.String(), metadata.NoCompactMarkFilename),
+		path.Join("user-1-copy", bucketindex.NoCompactMarkFilepath(block2.ULID)),
+	} {
+		exists, err := dstBkt.Exists(ctx, name)
+		require.NoError(t, err)
+		assert.True(t, exists, name)
+	}
+
+	for _, id := range []string{block3.ULID.String(), partial.ULID.String()} {
+		exists, err := dstBkt.Exists(ctx, path.Join("user-1-copy", id, block.IndexFilename))
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+
+	idx, err := bucketindex.ReadIndex(ctx, dstBkt, "user-1-copy", nil, logger)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{block1.ULID.String(), block2.ULID.String()}, blockIDs(idx))
+
+	// Mark a copied block for deletion in the source, then run the copy again.
+	mimir_testutil.MockStorageDeletionMark(t, srcBkt, "user-1", block1.BlockMeta)
+
+	stats, err = NewTenantCopier(cfg, srcBkt, dstBkt, nil, logger).CopyBlocks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.BlocksCopied)
+	assert.Equal(t, 3, stats.BlocksSkipped)
+	assert.Equal(t, 1, stats.MarkersCopied)
+
+	idx, err = bucketindex.ReadIndex(ctx, dstBkt, "user-1-copy", nil, logger)
+	require.NoError(t, err)
+	require.Len(t, idx.BlockDeletionMarks, 1)
+	assert.Equal(t, block1.ULID, idx.BlockDeletionMarks[0].ID)
+}
+
+func TestTenantCopier_CopyBlocks_ShouldResumeInterruptedCopy(t *testing.T) {
+	ctx := context.Background()
+
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	block1 := mimir_testutil.MockStorageBlock(t, srcBkt, "user-1", 10, 20)
+	require.NoError(t, srcBkt.Upload(ctx, path.Join("user-1", block1.ULID.String(), "chunks", "000001"), strings.NewReader("chunks")))
+
+	// Simulate a copy interrupted before uploading the meta.json.
+	require.NoError(t, dstBkt.Upload(ctx, path.Join("user-1", block1.ULID.String(), block.IndexFilename), strings.NewReader("")))
+
+	stats, err := NewTenantCopier(Config{SourceTenant: "user-1"}, srcBkt, dstBkt, nil, log.NewNopLogger()).CopyBlocks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.BlocksCopied)
+	assert.Equal(t, 2, stats.ObjectsCopied)
+
+	for _, name := range []string{"chunks/000001", block.IndexFilename, block.MetaFilename} {
+		exists, err := dstBkt.Exists(ctx, path.Join("user-1", block1.ULID.String(), name))
+		require.NoError(t, err)
+		assert.True(t, exists, name)
+	}
+}
+
+func TestTenantCopier_CopyBlocks_ShouldFailIfSourceTenantIsMarkedForDeletion(t *testing.T) {
+	ctx := context.Background()
+
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	mimir_testutil.MockStorageBlock(t, srcBkt, "user-1", 10, 20)
+	require.NoError(t, mimir_tsdb.WriteTenantDeletionMark(ctx, srcBkt, "user-1", nil, mimir_tsdb.NewTenantDeletionMark(time.Now())))
+
+	_, err := NewTenantCopier(Config{SourceTenant: "user-1"}, srcBkt, dstBkt, nil, log.NewNopLogger()).CopyBlocks(ctx)
+	require.ErrorIs(t, err, errSourceTenantMarkedForDeletion)
+}
+
+func TestTenantCopier_CopyBlocks_DryRun(t *testing.T) {
+	ctx := context.Background()
+
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+	dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	mimir_testutil.MockStorageBlock(t, srcBkt, "user-1", 10, 20)
+
+	stats, err := NewTenantCopier(Config{SourceTenant: "user-1", DryRun: true}, srcBkt, dstBkt, nil, log.NewNopLogger()).CopyBlocks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.BlocksCopied)
+	assert.False(t, stats.IndexGenerated)
+
+	var objects []string
+	require.NoError(t, dstBkt.Iter(ctx, "", func(name string) error {
+		objects = append(objects, name)
+		return nil
+	}))
+	assert.Empty(t, objects)
+}
+
+func blockIDs(idx *bucketindex.Index) []string {
+	var ids []string
+	for _, b := range idx.Blocks {
+		ids = append(ids, b.ID.String())
+	}
+	return ids
+}
@@ -0,0 +1,358 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketcopy
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+)
+
+// TenantMapping maps the blocks whose external labels match all Matchers to Tenant.
+type TenantMapping struct {
+	Matchers []*labels.Matcher
+	Tenant   string
+}
+
+// ParseTenantMapping parses a tenant mapping in the form '<selector>=<tenant>',
+// e.g. '{cluster="prod",env=~"eu-.*"}=tenant-1'.
+func ParseTenantMapping(s string) (TenantMapping, error) {
+	end := strings.LastIndex(s, "}")
+	if !strings.HasPrefix(s, "{") || end < 0 || !strings.HasPrefix(s[end+1:], "=") {
+		return TenantMapping{}, fmt.Errorf("invalid tenant mapping %q: expected format is '<selector>=<tenant>'", s)
+	}
+
+	matchers, err := parser.ParseMetricSelector(s[:end+1])
+	if err != nil {
+		return TenantMapping{}, errors.Wrapf(err, "invalid selector in tenant mapping %q", s)
+	}
+
+	tenantID := s[end+2:]
+	if err := validTenantID(tenantID); err != nil {
+		return TenantMapping{}, errors.Wrapf(err, "invalid tenant in tenant mapping %q", s)
+	}
+
+	return TenantMapping{Matchers: matchers, Tenant: tenantID}, nil
+}
+
+// validTenantID returns an error if the input tenant ID can't be used as a prefix in the bucket.
+func validTenantID(userID string) error {
+	switch userID {
+	case "":
+		return errors.New("empty tenant ID")
+	case ".", "..":
+		return fmt.Errorf("tenant ID %q is not allowed", userID)
+	}
+	return tenant.ValidTenantID(userID)
+}
+
+func (m TenantMapping) matches(lset map[string]string) bool {
+	for _, matcher := range m.Matchers {
+		if !matcher.Matches(lset[matcher.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MigratorConfig holds the configuration of a ThanosMigrator.
+type MigratorConfig struct {
+	// TenantMappings are evaluated in order against the external labels of each block,
+	// and the first matching one selects the tenant of the block.
+	TenantMappings []TenantMapping
+
+	// TenantLabel is the external label whose value is used as tenant, for the blocks
+	// not matching any of the TenantMappings.
+	TenantLabel string
+
+	// DefaultTenant is the tenant of the blocks not mapped by TenantMappings or TenantLabel.
+	// If empty, such blocks are not migrated.
+	DefaultTenant string
+
+	// KeepLabels are the external labels kept in the migrated blocks' meta.json.
+	// All other external labels are removed.
+	KeepLabels []string
+
+	// CopyConcurrency is the number of blocks migrated concurrently.
+	CopyConcurrency int
+
+	// ProgressInterval is the number of processed blocks after which the progress is logged.
+	ProgressInterval int
+
+	// DryRun only reports what would be migrated, without writing to the destination bucket.
+	DryRun bool
+}
+
+// MigrationStats summarises the outcome of a migration run.
+type MigrationStats struct {
+	BlocksMigrated          int
+	BlocksAlreadyMigrated   int
+	BlocksUnmapped          int
+	BlocksDownsampled       int
+	BlocksMarkedForDeletion int
+	PartialsFound           int
+	MarkersCopied           int
+	ObjectsCopied           int
+	BytesCopied             int64
+
+	// Tenants is the number of blocks migrated, or already migrated, for each tenant.
+	Tenants map[string]int
+
+	// IndexesGenerated is the number of tenants whose bucket index has been generated.
+	IndexesGenerated int
+}
+
+func (s *MigrationStats) add(o MigrationStats) {
+	s.BlocksMigrated += o.BlocksMigrated
+	s.BlocksAlreadyMigrated += o.BlocksAlreadyMigrated
+	s.BlocksUnmapped += o.BlocksUnmapped
+	s.BlocksDownsampled += o.BlocksDownsampled
+	s.BlocksMarkedForDeletion += o.BlocksMarkedForDeletion
+	s.PartialsFound += o.PartialsFound
+	s.MarkersCopied += o.MarkersCopied
+	s.ObjectsCopied += o.ObjectsCopied
+	s.BytesCopied += o.BytesCopied
+
+	for userID, blocks := range o.Tenants {
+		if s.Tenants == nil {
+			s.Tenants = map[string]int{}
+		}
+		s.Tenants[userID] += blocks
+	}
+}
+
+// ThanosMigrator migrates the blocks of a Thanos bucket, where all blocks are stored at the
+// root of the bucket and identified by their external labels, to a Mimir bucket, where blocks
+// are stored under the tenant prefix.
+//
+// Only raw resolution blocks are migrated, because Mimir doesn't support downsampling, and
+// the external labels of each migrated block are replaced with the Mimir tenant ID label.
+// As for the TenantCopier, a block's meta.json is uploaded last, so that an interrupted
+// migration can be safely resumed.
+type ThanosMigrator struct {
+	cfg       MigratorConfig
+	srcBucket objstore.Bucket
+	dstRoot   objstore.Bucket
+	logger    log.Logger
+
+	processedMx sync.Mutex
+	processed   int
+}
+
+// NewThanosMigrator makes a new ThanosMigrator. dstBucket must not be prefixed with the tenant ID.
+func NewThanosMigrator(cfg MigratorConfig, srcBucket, dstBucket objstore.Bucket, logger log.Logger) *ThanosMigrator {
+	if cfg.CopyConcurrency <= 0 {
+		cfg.CopyConcurrency = 1
+	}
+
+	return &ThanosMigrator{
+		cfg:       cfg,
+		srcBucket: srcBucket,
+		dstRoot:   dstBucket,
+		logger:    logger,
+	}
+}
+
+// Migrate migrates all blocks which are not yet in the destination bucket, and finally
+// regenerates the bucket index of each tenant blocks have been migrated to.
+func (m *ThanosMigrator) Migrate(ctx context.Context) (MigrationStats, error) {
+	var (
+		stats   MigrationStats
+		statsMx sync.Mutex
+	)
+
+	var blockIDs []interface{}
+	err := m.srcBucket.Iter(ctx, "", func(name string) error {
+		if id, ok := block.IsBlockDir(name); ok {
+			blockIDs = append(blockIDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, errors.Wrap(err, "list source blocks")
+	}
+
+	level.Info(m.logger).Log("msg", "discovered blocks in the source bucket", "blocks", len(blockIDs))
+
+	err = concurrency.ForEach(ctx, blockIDs, m.cfg.CopyConcurrency, func(ctx context.Context, job interface{}) error {
+		blockStats, err := m.migrateBlock(ctx, job.(ulid.ULID))
+		if err != nil {
+			return errors.Wrapf(err, "migrate block %s", job.(ulid.ULID).String())
+		}
+
+		statsMx.Lock()
+		stats.add(blockStats)
+		statsMx.Unlock()
+
+		m.reportProgress(len(blockIDs))
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	if m.cfg.DryRun {
+		return stats, nil
+	}
+
+	tenants := make([]string, 0, len(stats.Tenants))
+	for userID := range stats.Tenants {
+		tenants = append(tenants, userID)
+	}
+	sort.Strings(tenants)
+
+	for _, userID := range tenants {
+		if err := updateBucketIndex(ctx, m.dstRoot, userID, nil, log.With(m.logger, "user", userID)); err != nil {
+			return stats, errors.Wrapf(err, "tenant %s", userID)
+		}
+		stats.IndexesGenerated++
+	}
+
+	return stats, nil
+}
+
+func (m *ThanosMigrator) reportProgress(total int) {
+	m.processedMx.Lock()
+	m.processed++
+	processed := m.processed
+	m.processedMx.Unlock()
+
+	if m.cfg.ProgressInterval > 0 && (processed%m.cfg.ProgressInterval == 0 || processed == total) {
+		level.Info(m.logger).Log("msg", "migration progress", "processed_blocks", processed, "total_blocks", total)
+	}
+}
+
+// tenantForBlock returns the tenant the block with the input external labels is migrated to,
+// or an empty string if the block is not mapped to any tenant.
+func (m *ThanosMigrator) tenantForBlock(lset map[string]string) string {
+	for _, mapping := range m.cfg.TenantMappings {
+		if mapping.matches(lset) {
+			return mapping.Tenant
+		}
+	}
+
+	if m.cfg.TenantLabel != "" {
+		if userID := lset[m.cfg.TenantLabel]; userID != "" {
+			return userID
+		}
+	}
+
+	return m.cfg.DefaultTenant
+}
+
+// convertExternalLabels returns the external labels of a block migrated to userID.
+func (m *ThanosMigrator) convertExternalLabels(lset map[string]string, userID string) map[string]string {
+	out := map[string]string{mimir_tsdb.TenantIDExternalLabel: userID}
+	for _, name := range m.cfg.KeepLabels {
+		if value, ok := lset[name]; ok && name != mimir_tsdb.TenantIDExternalLabel {
+			out[name] = value
+		}
+	}
+	return out
+}
+
+func (m *ThanosMigrator) migrateBlock(ctx context.Context, blockID ulid.ULID) (MigrationStats, error) {
+	var stats MigrationStats
+	logger := log.With(m.logger, "block", blockID.String())
+
+	meta, err := readMeta(ctx, m.srcBucket, blockID)
+	if errors.Is(err, bucketindex.ErrBlockMetaNotFound) {
+		// The block may still be in the process of being uploaded: we'll pick it up on the next run.
+		level.Warn(logger).Log("msg", "skipped partial block in the source bucket")
+		stats.PartialsFound++
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	if meta.Thanos.Downsample.Resolution > 0 {
+		level.Debug(logger).Log("msg", "skipped downsampled block", "resolution", meta.Thanos.Downsample.Resolution)
+		stats.BlocksDownsampled++
+		return stats, nil
+	}
+
+	userID := m.tenantForBlock(meta.Thanos.Labels)
+	if userID == "" {
+		level.Warn(logger).Log("msg", "skipped block not mapped to any tenant", "labels", labels.FromMap(meta.Thanos.Labels).String())
+		stats.BlocksUnmapped++
+		return stats, nil
+	}
+	if err := validTenantID(userID); err != nil {
+		return stats, errors.Wrapf(err, "invalid tenant for external labels %s", labels.FromMap(meta.Thanos.Labels).String())
+	}
+
+	logger = log.With(logger, "user", userID)
+
+	markedForDeletion, err := m.srcBucket.Exists(ctx, path.Join(blockID.String(), metadata.DeletionMarkFilename))
+	if err != nil {
+		return stats, errors.Wrap(err, "check block deletion mark")
+	}
+
+	// A block marked for deletion has already been replaced by another block (eg. compacted),
+	// so it's not worth migrating it.
+	if markedForDeletion {
+		level.Debug(logger).Log("msg", "skipped block marked for deletion in the source bucket")
+		stats.BlocksMarkedForDeletion++
+		return stats, nil
+	}
+
+	dstBucket := bucketindex.BucketWithGlobalMarkers(bucket.NewUserBucketClient(userID, m.dstRoot, nil))
+	copier := objectCopier{
+		src:    m.srcBucket,
+		dst:    dstBucket,
+		dryRun: m.cfg.DryRun,
+		logger: m.logger,
+	}
+
+	alreadyMigrated, err := dstBucket.Exists(ctx, path.Join(blockID.String(), block.MetaFilename))
+	if err != nil {
+		return stats, errors.Wrap(err, "check block in the destination bucket")
+	}
+
+	if alreadyMigrated {
+		stats.BlocksAlreadyMigrated++
+	} else {
+		meta.Thanos.Labels = m.convertExternalLabels(meta.Thanos.Labels, userID)
+		meta.Thanos.Version = metadata.ThanosVersion1
+
+		objects, size, err := copier.copyBlock(ctx, blockID, meta)
+		stats.ObjectsCopied += objects
+		stats.BytesCopied += size
+		if err != nil {
+			return stats, err
+		}
+		stats.BlocksMigrated++
+		level.Info(logger).Log("msg", "migrated block", "objects", stats.ObjectsCopied, "bytes", stats.BytesCopied)
+	}
+	stats.Tenants = map[string]int{userID: 1}
+
+	copied, err := copier.copyMarkIfMissing(ctx, path.Join(blockID.String(), metadata.NoCompactMarkFilename))
+	if err != nil {
+		return stats, err
+	}
+	if copied {
+		stats.MarkersCopied++
+	}
+
+	return stats, nil
+}
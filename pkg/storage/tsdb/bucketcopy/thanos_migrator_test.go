@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketcopy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+// mockThanosBlock uploads a block with the input external labels and resolution at the root of the bucket,
+// as done by Thanos.
+func mockThanosBlock(t *testing.T, bkt objstore.Bucket, minT, maxT int64, extLabels map[string]string, resolution int64) metadata.Meta {
+	id := ulid.MustNew(uint64(maxT), rand.Reader)
+	meta := metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			Version:    1,
+			ULID:       id,
+			MinTime:    minT,
+			MaxTime:    maxT,
+			Compaction: tsdb.BlockMetaCompaction{Level: 1, Sources: []ulid.ULID{id}},
+		},
+		Thanos: metadata.Thanos{
+			Labels:     extLabels,
+			Downsample: metadata.ThanosDownsample{Resolution: resolution},
+			Source:     metadata.SidecarSource,
+		},
+	}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, meta.Write(&buf))
+	require.NoError(t, bkt.Upload(context.Background(), path.Join(id.String(), block.MetaFilename), &buf))
+	require.NoError(t, bkt.Upload(context.Background(), path.Join(id.String(), block.IndexFilename), strings.NewReader("index")))
+	require.NoError(t, bkt.Upload(context.Background(), path.Join(id.String(), block.ChunksDirname, "000001"), strings.NewReader("chunks")))
+	return meta
+}
+
+func TestParseTenantMapping(t *testing.T) {
+	mapping, err := ParseTenantMapping(`{cluster="prod",env=~"eu-.*"}=tenant-1`)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", mapping.Tenant)
+	assert.Equal(t, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, "cluster", "prod"),
+		labels.MustNewMatcher(labels.MatchRegexp, "env", "eu-.*"),
+	}, mapping.Matchers)
+
+	assert.True(t, mapping.matches(map[string]string{"cluster": "prod", "env": "eu-west", "replica": "a"}))
+	assert.False(t, mapping.matches(map[string]string{"cluster": "prod", "env": "us-east"}))
+
+	for _, invalid := range []string{"", "tenant-1", `{cluster="prod"}`, `{cluster="prod"}=`, `{cluster=}=tenant-1`, `{cluster="prod"}=..`} {
+		_, err := ParseTenantMapping(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestThanosMigrator_Migrate(t *testing.T) {
+	ctx := context.Background()
+
+	srcBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	prodA := mockThanosBlock(t, srcBkt, 10, 20, map[string]string{"cluster": "prod", "replica": "a"}, 0)
+	prodB := mockThanosBlock(t, srcBkt, 10, 20, map[string]string{"cluster": "prod", "replica": "b"}, 0)
+	dev := mockThanosBlock(t, srcBkt, 20, 30, map[string]string{"cluster": "dev", "team": "team-1"}, 0)
+	unmapped := mockThanosBlock(t, srcBkt, 30, 40, map[string]string{"cluster": "staging"}, 0)
+	downsampled := mockThanosBlock(t, srcBkt, 10, 20, map[string]string{"cluster": "prod", "replica": "a"}, 300000)
+	deleted := mockThanosBlock(t, srcBkt, 40, 50, map[string]string{"cluster": "prod", "replica": "a"}, 0)
+	mimir_testutil.MockStorageDeletionMark(t, srcBkt, "", deleted.BlockMeta)
+	mimir_testutil.MockNoCompactMark(t, srcBkt, "", prodB.BlockMeta)
+
+	// Partial block: no meta.json.
+	partial := mockThanosBlock(t, srcBkt, 50, 60, map[string]string{"cluster": "prod"}, 0)
+	require.NoError(t, srcBkt.Delete(ctx, path.Join(partial.ULID.String(), block.MetaFilename)))
+
+	prodMapping, err := ParseTenantMapping(`{cluster="prod"}=prod`)
+	require.NoError(t, err)
+
+	cfg := MigratorConfig{
+		TenantMappings:   []TenantMapping{prodMapping},
+		TenantLabel:      "team",
+		KeepLabels:       []string{"cluster"},
+		CopyConcurrency:  2,
+		ProgressInterval: 2,
+	}
+
+	t.Run("dry-run", func(t *testing.T) {
+		dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+		dryRunCfg := cfg
+		dryRunCfg.DryRun = true
+		stats, err := NewThanosMigrator(dryRunCfg, srcBkt, dstBkt, log.NewNopLogger()).Migrate(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 3, stats.BlocksMigrated)
+		assert.Equal(t, map[string]int{"prod": 2, "team-1": 1}, stats.Tenants)
+		assert.Equal(t, 0, stats.IndexesGenerated)
+
+		var objects []string
+		require.NoError(t, dstBkt.Iter(ctx, "", func(name string) error {
+			objects = append(objects, name)
+			return nil
+		}))
+		assert.Empty(t, objects)
+	})
+
+	t.Run("migration", func(t *testing.T) {
+		dstBkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+		stats, err := NewThanosMigrator(cfg, srcBkt, dstBkt, log.NewNopLogger()).Migrate(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, MigrationStats{
+			BlocksMigrated:          3,
+			BlocksUnmapped:          1,
+			BlocksDownsampled:       1,
+			BlocksMarkedForDeletion: 1,
+			PartialsFound:           1,
+			MarkersCopied:           1,
+			ObjectsCopied:           6,
+			BytesCopied:             33,
+			Tenants:                 map[string]int{"prod": 2, "team-1": 1},
+			IndexesGenerated:        2,
+		}, stats)
+
+		expected := map[string]map[string]metadata.Meta{
+			"prod":   {prodA.ULID.String(): prodA, prodB.ULID.String(): prodB},
+			"team-1": {dev.ULID.String(): dev},
+		}
+		for userID, blocks := range expected {
+			idx, err := bucketindex.ReadIndex(ctx, dstBkt, userID, nil, log.NewNopLogger())
+			require.NoError(t, err)
+			require.Len(t, idx.Blocks, len(blocks))
+
+			for _, b := range idx.Blocks {
+				src, ok := blocks[b.ID.String()]
+				require.True(t, ok, b.ID.String())
+
+				meta, err := readMeta(ctx, bucket.NewUserBucketClient(userID, dstBkt, nil), b.ID)
+				require.NoError(t, err)
+				assert.Equal(t, map[string]string{mimir_tsdb.TenantIDExternalLabel: userID, "cluster": src.Thanos.Labels["cluster"]}, meta.Thanos.Labels)
+
+				exists, err := dstBkt.Exists(ctx, path.Join(userID, b.ID.String(), block.ChunksDirname, "000001"))
+				require.NoError(t, err)
+				assert.True(t, exists)
+			}
+		}
+
+		// The no-compact mark should have been copied, in both the block and global location.
+		for _, name := range []string{
+			path.Join("prod", prodB.ULID.String(), metadata.NoCompactMarkFilename),
+			path.Join("prod", bucketindex.NoCompactMarkFilepath(prodB.ULID)),
+		} {
+			exists, err := dstBkt.Exists(ctx, name)
+			require.NoError(t, err)
+			assert.True(t, exists, name)
+		}
+
+		for _, id := range []ulid.ULID{unmapped.ULID, downsampled.ULID, deleted.ULID, partial.ULID} {
+			for _, userID := range []string{"prod", "team-1"} {
+				exists, err := dstBkt.Exists(ctx, path.Join(userID, id.String(), block.IndexFilename))
+				require.NoError(t, err)
+				assert.False(t, exists)
+			}
+		}
+
+		// Running the migration again skips the blocks already migrated.
+		stats, err = NewThanosMigrator(cfg, srcBkt, dstBkt, log.NewNopLogger()).Migrate(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, stats.BlocksMigrated)
+		assert.Equal(t, 3, stats.BlocksAlreadyMigrated)
+		assert.Equal(t, 0, stats.MarkersCopied)
+		assert.Equal(t, 2, stats.IndexesGenerated)
+	})
+}
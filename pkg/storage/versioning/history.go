@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package versioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	util_math "github.com/grafana/mimir/pkg/util/math"
+)
+
+// AuthorHeader is the HTTP request header carrying the author of a change.
+const AuthorHeader = "X-Mimir-Author"
+
+// ErrVersionNotFound is returned if a version does not exist in the history.
+var ErrVersionNotFound = errors.New("version does not exist")
+
+type authorContextKey int
+
+const authorKey authorContextKey = 0
+
+// ContextWithAuthor returns a new context carrying the author of the changes made with it.
+func ContextWithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey, author)
+}
+
+// ContextWithAuthorFromRequest returns the context of the request, carrying the author set in the AuthorHeader.
+func ContextWithAuthorFromRequest(r *http.Request) context.Context {
+	return ContextWithAuthor(r.Context(), r.Header.Get(AuthorHeader))
+}
+
+// AuthorFromContext returns the author carried by the context, or an empty string.
+func AuthorFromContext(ctx context.Context) string {
+	author, _ := ctx.Value(authorKey).(string)
+	return author
+}
+
+// Version describes a version in the history of an object.
+type Version struct {
+	ID        int       `json:"id" yaml:"id"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	Author    string    `json:"author,omitempty" yaml:"author,omitempty"`
+
+	// Deleted is true if the version records the deletion of the object.
+	Deleted bool `json:"deleted,omitempty" yaml:"deleted,omitempty"`
+}
+
+// versionObject is the content of the bucket object storing a version.
+type versionObject struct {
+	Version
+	Data []byte `json:"data,omitempty"`
+}
+
+// History keeps up to maxVersions versions of an object in a bucket, one object per version
+// named after the version ID. The bucket is expected to be dedicated to the history of the object.
+//
+// Concurrent changes to the same object may get the same version ID, in which case only one of
+// them is kept in the history.
+type History struct {
+	bkt         objstore.Bucket
+	maxVersions int
+	logger      log.Logger
+}
+
+// NewHistory returns a History storing the versions in bkt. The history is disabled if maxVersions is 0.
+func NewHistory(bkt objstore.Bucket, maxVersions int, logger log.Logger) *History {
+	return &History{
+		bkt:         bkt,
+		maxVersions: maxVersions,
+		logger:      logger,
+	}
+}
+
+// Add adds a new version of the object to the history, authored by the author carried by the
+// context, and deletes the oldest versions exceeding the maximum number of versions. The data
+// is ignored if the version records the deletion of the object.
+func (h *History) Add(ctx context.Context, data []byte, deleted bool) (Version, error) {
+	if h.maxVersions <= 0 {
+		return Version{}, nil
+	}
+
+	ids, err := h.listIDs(ctx)
+	if err != nil {
+		return Version{}, err
+	}
+
+	obj := versionObject{
+		Version: Version{
+			ID:        1,
+			Timestamp: time.Now().UTC(),
+			Author:    AuthorFromContext(ctx),
+			Deleted:   deleted,
+		},
+	}
+	if len(ids) > 0 {
+		obj.ID = ids[len(ids)-1] + 1
+	}
+	if !deleted {
+		obj.Data = data
+	}
+
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return Version{}, err
+	}
+	if err := h.bkt.Upload(ctx, objectName(obj.ID), bytes.NewReader(buf)); err != nil {
+		return Version{}, errors.Wrapf(err, "failed to upload version %d", obj.ID)
+	}
+
+	ids = append(ids, obj.ID)
+	for _, id := range ids[:len(ids)-util_math.Min(len(ids), h.maxVersions)] {
+		if err := h.bkt.Delete(ctx, objectName(id)); err != nil && !h.bkt.IsObjNotFoundErr(err) {
+			// The version will be deleted the next time a version is added.
+			level.Warn(h.logger).Log("msg", "failed to delete old version", "version", id, "err", err)
+		}
+	}
+
+	return obj.Version, nil
+}
+
+// List returns the versions in the history, sorted by ID.
+func (h *History) List(ctx context.Context) ([]Version, error) {
+	ids, err := h.listIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]Version, 0, len(ids))
+	for _, id := range ids {
+		obj, err := h.get(ctx, id)
+		if errors.Is(err, ErrVersionNotFound) {
+			// The version has been deleted in the meantime.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, obj.Version)
+	}
+
+	return versions, nil
+}
+
+// Get returns the version with the given ID and its data. The data is nil if the version records
+// the deletion of the object.
+func (h *History) Get(ctx context.Context, id int) (Version, []byte, error) {
+	obj, err := h.get(ctx, id)
+	if err != nil {
+		return Version{}, nil, err
+	}
+	return obj.Version, obj.Data, nil
+}
+
+func (h *History) get(ctx context.Context, id int) (versionObject, error) {
+	obj := versionObject{}
+
+	reader, err := h.bkt.Get(ctx, objectName(id))
+	if h.bkt.IsObjNotFoundErr(err) {
+		return obj, ErrVersionNotFound
+	}
+	if err != nil {
+		return obj, errors.Wrapf(err, "failed to get version %d", id)
+	}
+	defer func() { _ = reader.Close() }()
+
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return obj, errors.Wrapf(err, "failed to read version %d", id)
+	}
+	if err := json.Unmarshal(buf, &obj); err != nil {
+		return obj, errors.Wrapf(err, "failed to unmarshal version %d", id)
+	}
+
+	return obj, nil
+}
+
+// listIDs returns the IDs of the versions in the history, sorted in ascending order.
+func (h *History) listIDs(ctx context.Context) ([]int, error) {
+	var ids []int
+	err := h.bkt.Iter(ctx, "", func(name string) error {
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			level.Warn(h.logger).Log("msg", "invalid version object found in history", "name", name)
+
+			// Do not fail just because of a spurious item in the bucket.
+			return nil
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list versions")
+	}
+
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// objectName returns the name of the object storing the version with the given ID. The IDs
+// are zero-padded so that the objects are listed in order.
+func objectName(id int) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+// Diff returns the unified diff between the text representations of two versions.
+func Diff(from Version, fromText string, to Version, toText string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(fromText),
+		B:        splitLines(toText),
+		FromFile: describe(from),
+		ToFile:   describe(to),
+		Context:  3,
+	})
+}
+
+// splitLines splits the text into lines, each one terminated by a newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+func describe(v Version) string {
+	s := fmt.Sprintf("version %d", v.ID)
+	if v.Author != "" {
+		s += " by " + v.Author
+	}
+	if v.Deleted {
+		s += " (deleted)"
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package versioning
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	h := NewHistory(bkt, 3, log.NewNopLogger())
+
+	versions, err := h.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	v, err := h.Add(ContextWithAuthor(ctx, "alice"), []byte("first"), false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v.ID)
+	assert.Equal(t, "alice", v.Author)
+	assert.False(t, v.Deleted)
+
+	_, err = h.Add(ctx, []byte("second"), false)
+	require.NoError(t, err)
+	_, err = h.Add(ContextWithAuthor(ctx, "bob"), []byte("ignored"), true)
+	require.NoError(t, err)
+
+	versions, err = h.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	for i, v := range versions {
+		assert.Equal(t, i+1, v.ID)
+	}
+	assert.Equal(t, []string{"alice", "", "bob"}, []string{versions[0].Author, versions[1].Author, versions[2].Author})
+	assert.True(t, versions[2].Deleted)
+
+	v, data, err := h.Get(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, v.ID)
+	assert.Equal(t, []byte("second"), data)
+
+	v, data, err = h.Get(ctx, 3)
+	require.NoError(t, err)
+	assert.True(t, v.Deleted)
+	assert.Nil(t, data)
+
+	// The oldest version is deleted once the maximum number of versions is exceeded.
+	v, err = h.Add(ctx, []byte("fourth"), false)
+	require.NoError(t, err)
+	assert.Equal(t, 4, v.ID)
+
+	versions, err = h.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, 2, versions[0].ID)
+	assert.Equal(t, 4, versions[2].ID)
+
+	_, _, err = h.Get(ctx, 1)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
+
+func TestHistory_Disabled(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	h := NewHistory(bkt, 0, log.NewNopLogger())
+
+	_, err := h.Add(ctx, []byte("data"), false)
+	require.NoError(t, err)
+	assert.Empty(t, bkt.Objects())
+
+	versions, err := h.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+func TestDiff(t *testing.T) {
+	diff, err := Diff(Version{ID: 1, Author: "alice"}, "a\nb\nc\n", Version{ID: 2}, "a\nc\nd\n")
+	require.NoError(t, err)
+
+	assert.Equal(t, strings.Join([]string{
+		"--- version 1 by alice",
+		"+++ version 2",
+		"@@ -1,3 +1,3 @@",
+		" a",
+		"-b",
+		" c",
+		"+d",
+		"",
+	}, "\n"), diff)
+}
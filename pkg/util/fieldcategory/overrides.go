@@ -63,6 +63,55 @@ var overrides = map[string]Category{
 	"server.log-source-ips-regex":                       Advanced,
 	"server.path-prefix":                                Advanced,
 	"server.register-instrumentation":                   Advanced,
+
+	// bucket.Config in compactor.ReplicationConfig
+	"compactor.replication.storage.azure.account-key":                Experimental,
+	"compactor.replication.storage.azure.account-name":               Experimental,
+	"compactor.replication.storage.azure.container-name":             Experimental,
+	"compactor.replication.storage.azure.endpoint-suffix":            Experimental,
+	"compactor.replication.storage.azure.max-retries":                Experimental,
+	"compactor.replication.storage.azure.msi-resource":               Experimental,
+	"compactor.replication.storage.azure.user-assigned-id":           Experimental,
+	"compactor.replication.storage.backend":                          Experimental,
+	"compactor.replication.storage.filesystem.dir":                   Experimental,
+	"compactor.replication.storage.gcs.bucket-name":                  Experimental,
+	"compactor.replication.storage.gcs.service-account":              Experimental,
+	"compactor.replication.storage.s3.access-key-id":                 Experimental,
+	"compactor.replication.storage.s3.bucket-name":                   Experimental,
+	"compactor.replication.storage.s3.endpoint":                      Experimental,
+	"compactor.replication.storage.s3.expect-continue-timeout":       Experimental,
+	"compactor.replication.storage.s3.http.idle-conn-timeout":        Experimental,
+	"compactor.replication.storage.s3.http.insecure-skip-verify":     Experimental,
+	"compactor.replication.storage.s3.http.response-header-timeout":  Experimental,
+	"compactor.replication.storage.s3.insecure":                      Experimental,
+	"compactor.replication.storage.s3.max-connections-per-host":      Experimental,
+	"compactor.replication.storage.s3.max-idle-connections":          Experimental,
+	"compactor.replication.storage.s3.max-idle-connections-per-host": Experimental,
+	"compactor.replication.storage.s3.region":                        Experimental,
+	"compactor.replication.storage.s3.secret-access-key":             Experimental,
+	"compactor.replication.storage.s3.signature-version":             Experimental,
+	"compactor.replication.storage.s3.sse.kms-encryption-context":    Experimental,
+	"compactor.replication.storage.s3.sse.kms-key-id":                Experimental,
+	"compactor.replication.storage.s3.sse.type":                      Experimental,
+	"compactor.replication.storage.s3.tls-handshake-timeout":         Experimental,
+	"compactor.replication.storage.swift.auth-url":                   Experimental,
+	"compactor.replication.storage.swift.auth-version":               Experimental,
+	"compactor.replication.storage.swift.connect-timeout":            Experimental,
+	"compactor.replication.storage.swift.container-name":             Experimental,
+	"compactor.replication.storage.swift.domain-id":                  Experimental,
+	"compactor.replication.storage.swift.domain-name":                Experimental,
+	"compactor.replication.storage.swift.max-retries":                Experimental,
+	"compactor.replication.storage.swift.password":                   Experimental,
+	"compactor.replication.storage.swift.project-domain-id":          Experimental,
+	"compactor.replication.storage.swift.project-domain-name":        Experimental,
+	"compactor.replication.storage.swift.project-id":                 Experimental,
+	"compactor.replication.storage.swift.project-name":               Experimental,
+	"compactor.replication.storage.swift.region-name":                Experimental,
+	"compactor.replication.storage.swift.request-timeout":            Experimental,
+	"compactor.replication.storage.swift.user-domain-id":             Experimental,
+	"compactor.replication.storage.swift.user-domain-name":           Experimental,
+	"compactor.replication.storage.swift.user-id":                    Experimental,
+	"compactor.replication.storage.swift.username":                   Experimental,
 }
 
 func GetOverride(fieldName string) (category Category, ok bool) {
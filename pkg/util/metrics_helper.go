@@ -332,6 +332,25 @@ func (d MetricFamiliesPerUser) SendSumOfHistogramsWithLabels(out chan<- promethe
 	}
 }
 
+// SendSumOfHistogramsPerUserWithLabels provides metrics with the provided label names on a per-user basis. This function assumes that `user` is the
+// first label on the provided metric Desc
+func (d MetricFamiliesPerUser) SendSumOfHistogramsPerUserWithLabels(out chan<- prometheus.Metric, desc *prometheus.Desc, histogramName string, labelNames ...string) {
+	for _, userEntry := range d {
+		if userEntry.user == "" {
+			continue
+		}
+
+		metricsPerLabelValue := getMetricsWithLabelNames(userEntry.metrics[histogramName], labelNames)
+		for _, mwl := range metricsPerLabelValue {
+			hd := HistogramData{}
+			for _, m := range mwl.metrics {
+				hd.AddHistogram(m.GetHistogram())
+			}
+			out <- hd.Metric(desc, append([]string{userEntry.user}, mwl.labelValues...)...)
+		}
+	}
+}
+
 // struct for holding metrics with same label values
 type metricsWithLabels struct {
 	labelValues []string
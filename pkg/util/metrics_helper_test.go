@@ -383,6 +383,44 @@ func TestSumOfCounterPerUserWithLabels(t *testing.T) {
 	}
 }
 
+func TestSendSumOfHistogramsPerUserWithLabels(t *testing.T) {
+	buckets := []float64{1, 2, 3}
+	user1Metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_metric", Buckets: buckets}, []string{"label_one", "label_two"})
+	user2Metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_metric", Buckets: buckets}, []string{"label_one", "label_two"})
+	user1Metric.WithLabelValues("a", "b").Observe(1)
+	user1Metric.WithLabelValues("a", "c").Observe(2)
+	user2Metric.WithLabelValues("a", "b").Observe(3)
+	user2Metric.WithLabelValues("a", "c").Observe(4)
+
+	user1Reg := prometheus.NewRegistry()
+	user2Reg := prometheus.NewRegistry()
+	user1Reg.MustRegister(user1Metric)
+	user2Reg.MustRegister(user2Metric)
+
+	regs := NewUserRegistries()
+	regs.AddUserRegistry("user-1", user1Reg)
+	regs.AddUserRegistry("user-2", user2Reg)
+	mf := regs.BuildMetricFamiliesPerUser()
+
+	desc := prometheus.NewDesc("test_metric", "", []string{"user", "label_one"}, nil)
+	actual := collectMetrics(t, func(out chan prometheus.Metric) {
+		mf.SendSumOfHistogramsPerUserWithLabels(out, desc, "test_metric", "label_one")
+	})
+	expected := []*dto.Metric{
+		{Label: makeLabels("label_one", "a", "user", "user-1"), Histogram: &dto.Histogram{SampleCount: uint64p(2), SampleSum: float64p(3), Bucket: []*dto.Bucket{
+			{UpperBound: float64p(1), CumulativeCount: uint64p(1)},
+			{UpperBound: float64p(2), CumulativeCount: uint64p(2)},
+			{UpperBound: float64p(3), CumulativeCount: uint64p(2)},
+		}}},
+		{Label: makeLabels("label_one", "a", "user", "user-2"), Histogram: &dto.Histogram{SampleCount: uint64p(2), SampleSum: float64p(7), Bucket: []*dto.Bucket{
+			{UpperBound: float64p(1), CumulativeCount: uint64p(0)},
+			{UpperBound: float64p(2), CumulativeCount: uint64p(0)},
+			{UpperBound: float64p(3), CumulativeCount: uint64p(1)},
+		}}},
+	}
+	require.ElementsMatch(t, expected, actual)
+}
+
 func TestSendSumOfSummariesPerUser(t *testing.T) {
 	objectives := map[float64]float64{0.25: 25, 0.5: 50, 0.75: 75}
 	user1Metric := prometheus.NewSummary(prometheus.SummaryOpts{Name: "test_metric", Objectives: objectives})
@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package push
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/middleware"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/log"
+)
+
+// maxInfluxParseErrors is the max number of parse errors reported in the response body.
+const maxInfluxParseErrors = 100
+
+// InfluxConfig configures how the Influx line protocol is converted to series.
+type InfluxConfig struct {
+	MetricNameSeparator string `yaml:"metric_name_separator" category:"advanced"`
+	SanitizeNames       bool   `yaml:"sanitize_names" category:"advanced"`
+}
+
+// RegisterFlags registers the flags of the Influx line protocol push endpoint.
+func (cfg *InfluxConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.MetricNameSeparator, "distributor.influx.metric-name-separator", "_", "Separator between the measurement and the field in the names of the metrics pushed with the Influx line protocol.")
+	f.BoolVar(&cfg.SanitizeNames, "distributor.influx.sanitize-names", true, "Replace the characters which aren't allowed in metric and label names with underscores, in the series pushed with the Influx line protocol. If disabled, the series with invalid names are rejected.")
+}
+
+// influxPrecisions maps the values of the precision parameter to the duration of their unit.
+var influxPrecisions = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"n":  time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// InfluxHandler is a http.Handler which accepts series in the Influx line protocol.
+// Each numeric or boolean field of a line is converted to a series named after the measurement and the field,
+// with the tags as labels. The lines which can't be parsed are reported in the response body, while the other
+// lines are still pushed.
+func InfluxHandler(
+	maxRecvMsgSize int,
+	sourceIPs *middleware.SourceIPExtractor,
+	cfg InfluxConfig,
+	push Func,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.WithContext(ctx, log.Logger)
+		if sourceIPs != nil {
+			source := sourceIPs.Get(r)
+			if source != "" {
+				ctx = util.AddSourceIPsToOutgoingContext(ctx, source)
+				logger = log.WithSourceIPs(source, logger)
+			}
+		}
+
+		precision, ok := influxPrecisions[r.URL.Query().Get("precision")]
+		if !ok {
+			http.Error(w, fmt.Sprintf("invalid precision %q", r.URL.Query().Get("precision")), http.StatusBadRequest)
+			return
+		}
+
+		body, err := readInfluxBody(r, maxRecvMsgSize)
+		if err != nil {
+			level.Error(logger).Log("err", err.Error())
+			code := http.StatusBadRequest
+			if errors.Is(err, errInfluxBodyTooLarge) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		series, parseErrs := influxToTimeseries(body, precision, time.Now(), cfg)
+
+		if len(series) > 0 {
+			req := &mimirpb.WriteRequest{Timeseries: series, Source: mimirpb.API}
+			cleanup := func() {
+				mimirpb.ReuseSlice(req.Timeseries)
+			}
+
+			if _, err := push(ctx, req, cleanup); err != nil {
+				resp, ok := httpgrpc.HTTPResponseFromError(err)
+				if !ok {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if resp.GetCode() != 202 {
+					level.Error(logger).Log("msg", "push error", "err", err)
+				}
+				http.Error(w, string(resp.Body), int(resp.Code))
+				return
+			}
+		}
+
+		if len(parseErrs) > 0 {
+			msg := strings.Join(parseErrs, "\n")
+			level.Warn(logger).Log("msg", "failed to parse Influx lines", "errors", len(parseErrs))
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+var errInfluxBodyTooLarge = errors.New("request body too large")
+
+// readInfluxBody reads the request body, decompressing it if gzip-encoded. The decompressed
+// body can't be larger than maxSize bytes.
+func readInfluxBody(r *http.Request, maxSize int) ([]byte, error) {
+	reader := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decompress the request body")
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the request body")
+	}
+	if len(body) > maxSize {
+		return nil, errors.Wrapf(errInfluxBodyTooLarge, "max size is %d bytes", maxSize)
+	}
+	return body, nil
+}
+
+// influxToTimeseries converts the lines of the body to series. The lines without a timestamp are timestamped at now.
+// It returns the errors of the lines which couldn't be parsed, prefixed by their line number.
+func influxToTimeseries(body []byte, precision time.Duration, now time.Time, cfg InfluxConfig) ([]mimirpb.PreallocTimeseries, []string) {
+	series := mimirpb.PreallocTimeseriesSliceFromPool()
+	var parseErrs []string
+
+	for i, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := parseInfluxLine(line)
+		if err != nil {
+			if len(parseErrs) < maxInfluxParseErrors {
+				parseErrs = append(parseErrs, fmt.Sprintf("line %d: %v", i+1, err))
+			} else if len(parseErrs) == maxInfluxParseErrors {
+				parseErrs = append(parseErrs, "too many errors, the next ones have been omitted")
+			}
+			continue
+		}
+
+		timestampMs := util.TimeToMillis(now)
+		if p.hasTimestamp {
+			timestampMs = p.timestamp * int64(precision) / int64(time.Millisecond)
+		}
+
+		for _, f := range p.fields {
+			ts := mimirpb.TimeseriesFromPool()
+			ts.Labels = append(ts.Labels, mimirpb.LabelAdapter{
+				Name:  labels.MetricName,
+				Value: influxMetricName(p.measurement+cfg.MetricNameSeparator+f.key, cfg.SanitizeNames),
+			})
+			for _, t := range p.tags {
+				ts.Labels = append(ts.Labels, mimirpb.LabelAdapter{
+					Name:  influxLabelName(t.key, cfg.SanitizeNames),
+					Value: unescapeInflux(t.value),
+				})
+			}
+			sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+			ts.Samples = append(ts.Samples, mimirpb.Sample{TimestampMs: timestampMs, Value: f.value})
+
+			series = append(series, mimirpb.PreallocTimeseries{TimeSeries: ts})
+		}
+	}
+
+	return series, parseErrs
+}
+
+// influxMetricName replaces the characters which aren't allowed in metric names with underscores, if sanitize is true.
+func influxMetricName(name string, sanitize bool) string {
+	if !sanitize {
+		return name
+	}
+	return sanitizeInfluxName(name, func(c byte) bool { return c == ':' })
+}
+
+// influxLabelName replaces the characters which aren't allowed in label names with underscores, if sanitize is true.
+func influxLabelName(name string, sanitize bool) string {
+	if !sanitize {
+		return name
+	}
+	return sanitizeInfluxName(name, func(byte) bool { return false })
+}
+
+func sanitizeInfluxName(name string, allowed func(c byte) bool) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || (c >= '0' && c <= '9' && i > 0) || allowed(c)) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
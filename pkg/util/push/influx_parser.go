@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package push
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// influxPoint is a single line of the Influx line protocol.
+type influxPoint struct {
+	measurement string
+	tags        []influxTag
+	fields      []influxField
+	// timestamp is in the precision of the request. hasTimestamp is false if the line has no timestamp.
+	timestamp    int64
+	hasTimestamp bool
+}
+
+type influxTag struct {
+	key, value string
+}
+
+type influxField struct {
+	key   string
+	value float64
+}
+
+// parseInfluxLine parses a line of the Influx line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// String fields can't be represented as samples, so they're skipped. It returns an error
+// if the line has no numeric or boolean field.
+func parseInfluxLine(line string) (influxPoint, error) {
+	var p influxPoint
+
+	measurement, rest, sep := scanInfluxToken(line, ", ")
+	if measurement == "" {
+		return p, errors.New("missing measurement")
+	}
+	p.measurement = unescapeInflux(measurement)
+
+	for sep == ',' {
+		var tag string
+		tag, rest, sep = scanInfluxToken(rest, ", ")
+		key, value, err := splitInfluxKeyValue(tag)
+		if err != nil {
+			return p, errors.Wrap(err, "invalid tag")
+		}
+		p.tags = append(p.tags, influxTag{key: key, value: value})
+	}
+	if sep != ' ' {
+		return p, errors.New("missing fields")
+	}
+
+	rest = strings.TrimLeft(rest, " ")
+	for {
+		var field string
+		field, rest, sep = scanInfluxField(rest)
+		key, raw, err := splitInfluxKeyValue(field)
+		if err != nil {
+			return p, errors.Wrap(err, "invalid field")
+		}
+		value, numeric, err := parseInfluxFieldValue(raw)
+		if err != nil {
+			return p, errors.Errorf("invalid value %q of field %q", raw, key)
+		}
+		if numeric {
+			p.fields = append(p.fields, influxField{key: key, value: value})
+		}
+		if sep != ',' {
+			break
+		}
+	}
+	if len(p.fields) == 0 {
+		return p, errors.New("no numeric or boolean field")
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" {
+		ts, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return p, errors.Errorf("invalid timestamp %q", rest)
+		}
+		p.timestamp = ts
+		p.hasTimestamp = true
+	}
+
+	return p, nil
+}
+
+// scanInfluxToken returns the input up to the first unescaped separator in seps, the remaining input
+// after it and the separator found, which is 0 if the end of the input was reached.
+func scanInfluxToken(s, seps string) (token, rest string, sep byte) {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+		case strings.IndexByte(seps, s[i]) >= 0:
+			return s[:i], s[i+1:], s[i]
+		}
+	}
+	return s, "", 0
+}
+
+// scanInfluxField is like scanInfluxToken for a field, whose string value can contain
+// unescaped commas and spaces between double quotes.
+func scanInfluxField(s string) (field, rest string, sep byte) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && (s[i] == ',' || s[i] == ' '):
+			return s[:i], s[i+1:], s[i]
+		}
+	}
+	return s, "", 0
+}
+
+func splitInfluxKeyValue(s string) (key, value string, err error) {
+	key, value, sep := scanInfluxToken(s, "=")
+	if sep != '=' || key == "" {
+		return "", "", errors.Errorf("%q is not a key=value pair", s)
+	}
+	if value == "" {
+		return "", "", errors.Errorf("missing value of %q", unescapeInflux(key))
+	}
+	// The value is returned escaped, because only tag values are unescaped once converted to labels.
+	return unescapeInflux(key), value, nil
+}
+
+// parseInfluxFieldValue parses the value of a field. It returns false if the value isn't numeric
+// nor boolean, and therefore can't be represented as a sample.
+func parseInfluxFieldValue(s string) (float64, bool, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			return 0, false, errors.New("unterminated string")
+		}
+		return 0, false, nil
+	case strings.HasSuffix(s, "i"):
+		v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		return float64(v), true, err
+	case strings.HasSuffix(s, "u"):
+		v, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+		return float64(v), true, err
+	}
+
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	return v, true, err
+}
+
+// unescapeInflux removes the backslashes escaping commas, equal signs and spaces.
+func unescapeInflux(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == ',' || s[i+1] == '=' || s[i+1] == ' ') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
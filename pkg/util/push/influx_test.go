@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package push
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestParseInfluxLine(t *testing.T) {
+	tests := map[string]struct {
+		line     string
+		expected influxPoint
+		err      string
+	}{
+		"fields of all types": {
+			line: `cpu,host=a,region=eu-west usage=0.5,count=3i,total=4u,up=true,down=F,msg="hello, world" 1650000000000000000`,
+			expected: influxPoint{
+				measurement: "cpu",
+				tags:        []influxTag{{key: "host", value: "a"}, {key: "region", value: "eu-west"}},
+				fields: []influxField{
+					{key: "usage", value: 0.5},
+					{key: "count", value: 3},
+					{key: "total", value: 4},
+					{key: "up", value: 1},
+					{key: "down", value: 0},
+				},
+				timestamp:    1650000000000000000,
+				hasTimestamp: true,
+			},
+		},
+		"escaped characters and no timestamp": {
+			line: `disk\ io,path=/var\,log,dev\=x=sda reads\ total=1e3`,
+			expected: influxPoint{
+				measurement: "disk io",
+				tags:        []influxTag{{key: "path", value: `/var\,log`}, {key: "dev=x", value: "sda"}},
+				fields:      []influxField{{key: "reads total", value: 1000}},
+			},
+		},
+		"missing fields": {
+			line: "cpu,host=a",
+			err:  "missing fields",
+		},
+		"invalid tag": {
+			line: "cpu,host usage=1",
+			err:  `invalid tag: "host" is not a key=value pair`,
+		},
+		"invalid field value": {
+			line: "cpu usage=abc",
+			err:  `invalid value "abc" of field "usage"`,
+		},
+		"only string fields": {
+			line: `cpu msg="hello"`,
+			err:  "no numeric or boolean field",
+		},
+		"invalid timestamp": {
+			line: "cpu usage=1 abc",
+			err:  `invalid timestamp "abc"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := parseInfluxLine(tc.line)
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, p)
+		})
+	}
+}
+
+func TestInfluxHandler(t *testing.T) {
+	cfg := InfluxConfig{MetricNameSeparator: "_", SanitizeNames: true}
+
+	tests := map[string]struct {
+		body           string
+		query          string
+		gzip           bool
+		cfg            InfluxConfig
+		pushErr        error
+		expectedSeries []string
+		expectedCode   int
+		expectedBody   string
+	}{
+		"valid lines": {
+			body:  "cpu,host=a usage=0.5,count=2i 1650000000\n\n# comment\nmem.used,k8s-pod=b value=10 1650000001\n",
+			query: "?precision=s",
+			cfg:   cfg,
+			expectedSeries: []string{
+				`{__name__="cpu_usage", host="a"} 0.5 @1650000000000`,
+				`{__name__="cpu_count", host="a"} 2 @1650000000000`,
+				`{__name__="mem_used_value", k8s_pod="b"} 10 @1650000001000`,
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		"gzip-encoded body": {
+			body:           "cpu usage=1 1650000000000",
+			query:          "?precision=ms",
+			gzip:           true,
+			cfg:            cfg,
+			expectedSeries: []string{`{__name__="cpu_usage"} 1 @1650000000000`},
+			expectedCode:   http.StatusNoContent,
+		},
+		"sanitization disabled": {
+			body:           "mem.used value=10 1650000000000000000",
+			cfg:            InfluxConfig{MetricNameSeparator: ":"},
+			expectedSeries: []string{`{__name__="mem.used:value"} 10 @1650000000000`},
+			expectedCode:   http.StatusNoContent,
+		},
+		"parse errors are reported by line, and the valid lines are pushed": {
+			body:           "cpu usage=1 1650000000000000000\ncpu,host usage=1\ncpu usage=abc",
+			cfg:            cfg,
+			expectedSeries: []string{`{__name__="cpu_usage"} 1 @1650000000000`},
+			expectedCode:   http.StatusBadRequest,
+			expectedBody:   "line 2: invalid tag: \"host\" is not a key=value pair\nline 3: invalid value \"abc\" of field \"usage\"",
+		},
+		"invalid precision": {
+			body:         "cpu usage=1",
+			query:        "?precision=d",
+			cfg:          cfg,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `invalid precision "d"`,
+		},
+		"body too large": {
+			body:         "cpu usage=1 " + strings.Repeat("1", 100),
+			cfg:          cfg,
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		"push error": {
+			body:           "cpu usage=1 1650000000000000000",
+			cfg:            cfg,
+			pushErr:        httpgrpc.Errorf(http.StatusTooManyRequests, "rate limited"),
+			expectedSeries: []string{`{__name__="cpu_usage"} 1 @1650000000000`},
+			expectedCode:   http.StatusTooManyRequests,
+			expectedBody:   "rate limited",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var pushed []string
+			push := func(_ context.Context, req *mimirpb.WriteRequest, cleanup func()) (*mimirpb.WriteResponse, error) {
+				defer cleanup()
+				assert.Equal(t, mimirpb.API, req.Source)
+				for _, ts := range req.Timeseries {
+					for _, s := range ts.Samples {
+						pushed = append(pushed, mimirpb.FromLabelAdaptersToLabels(ts.Labels).String()+" "+formatSample(s))
+					}
+				}
+				return &mimirpb.WriteResponse{}, tc.pushErr
+			}
+
+			body := []byte(tc.body)
+			if tc.gzip {
+				var buf bytes.Buffer
+				gz := gzip.NewWriter(&buf)
+				_, err := gz.Write(body)
+				require.NoError(t, err)
+				require.NoError(t, gz.Close())
+				body = buf.Bytes()
+			}
+
+			req := httptest.NewRequest("POST", "/api/v1/push/influx/write"+tc.query, bytes.NewReader(body))
+			if tc.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			resp := httptest.NewRecorder()
+			InfluxHandler(100, nil, tc.cfg, push).ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.expectedCode, resp.Code)
+			assert.Equal(t, tc.expectedSeries, pushed)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, strings.TrimSpace(resp.Body.String()))
+			}
+		})
+	}
+}
+
+func TestInfluxToTimeseries_DefaultTimestamp(t *testing.T) {
+	now := time.Unix(1650000000, 0)
+	series, errs := influxToTimeseries([]byte("cpu usage=1"), time.Nanosecond, now, InfluxConfig{MetricNameSeparator: "_"})
+	require.Empty(t, errs)
+	require.Len(t, series, 1)
+	assert.Equal(t, now.UnixNano()/int64(time.Millisecond), series[0].Samples[0].TimestampMs)
+}
+
+func formatSample(s mimirpb.Sample) string {
+	return fmt.Sprintf("%g @%d", s.Value, s.TimestampMs)
+}
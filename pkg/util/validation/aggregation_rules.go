@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// AggregationType is the type of the series aggregated by an AggregationRule.
+type AggregationType string
+
+const (
+	AggregationTypeCounter   AggregationType = "counter"
+	AggregationTypeGauge     AggregationType = "gauge"
+	AggregationTypeHistogram AggregationType = "histogram"
+
+	defaultAggregationInterval = model.Duration(time.Minute)
+)
+
+// AggregationRule defines how the distributor pre-aggregates the series matching a selector.
+// The matching series sharing the same labels, once the dropped labels are removed, are combined
+// into a single series written with the output metric name at the end of each interval.
+type AggregationRule struct {
+	// Match is the series selector, e.g. http_requests_total{job="api"}.
+	Match string `yaml:"match" json:"match"`
+
+	// DropLabels are removed from the matching series to compute the aggregated series.
+	DropLabels []string `yaml:"drop_labels" json:"drop_labels"`
+
+	// Type of the matching series: counter, gauge or histogram. Histograms are the classic
+	// ones, and the _bucket, _sum and _count suffixes are preserved in the output metric name.
+	Type AggregationType `yaml:"type" json:"type"`
+
+	// Interval is the time window after which the aggregated series is written.
+	Interval model.Duration `yaml:"interval" json:"interval"`
+
+	// OutputMetricName is the metric name of the aggregated series.
+	OutputMetricName string `yaml:"output_metric_name" json:"output_metric_name"`
+
+	// KeepRawSeries defines whether the matching series are still pushed to the ingesters.
+	KeepRawSeries bool `yaml:"keep_raw_series" json:"keep_raw_series"`
+
+	matchers []*labels.Matcher
+}
+
+// Matchers returns the matchers parsed from Match.
+func (r *AggregationRule) Matchers() []*labels.Matcher {
+	return r.matchers
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (r *AggregationRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain AggregationRule
+	if err := unmarshal((*plain)(r)); err != nil {
+		return err
+	}
+	return r.validate()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *AggregationRule) UnmarshalJSON(data []byte) error {
+	type plain AggregationRule
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	return r.validate()
+}
+
+func (r *AggregationRule) validate() error {
+	matchers, err := parser.ParseMetricSelector(r.Match)
+	if err != nil {
+		return errors.Wrapf(err, "invalid aggregation rule match %q", r.Match)
+	}
+	r.matchers = matchers
+
+	switch r.Type {
+	case AggregationTypeCounter, AggregationTypeGauge:
+	case AggregationTypeHistogram:
+		for _, name := range r.DropLabels {
+			if name == labels.BucketLabel {
+				return errors.Errorf("aggregation rule for %q can't drop the %s label of histograms", r.Match, labels.BucketLabel)
+			}
+		}
+	default:
+		return errors.Errorf("invalid aggregation rule type %q for %q, supported types are %s, %s and %s", r.Type, r.Match, AggregationTypeCounter, AggregationTypeGauge, AggregationTypeHistogram)
+	}
+
+	if !model.IsValidMetricName(model.LabelValue(r.OutputMetricName)) {
+		return errors.Errorf("invalid aggregation rule output metric name %q for %q", r.OutputMetricName, r.Match)
+	}
+
+	for _, name := range r.DropLabels {
+		if name == labels.MetricName {
+			return errors.Errorf("aggregation rule for %q can't drop the metric name, which is replaced by the output metric name", r.Match)
+		}
+	}
+
+	if r.Interval < 0 {
+		return errors.Errorf("invalid aggregation rule interval %s for %q", r.Interval, r.Match)
+	}
+	if r.Interval == 0 {
+		r.Interval = defaultAggregationInterval
+	}
+	return nil
+}
+
+// AggregationRules is a list of rules based on which the distributor pre-aggregates the incoming series.
+type AggregationRules []AggregationRule
+
+// ExampleDoc implements the ExamplerConfig interface used by the doc generator.
+func (r *AggregationRules) ExampleDoc() (comment string, yaml interface{}) {
+	return `The following configuration aggregates the per-pod request counters by service every minute, and drops the per-pod series.`,
+		AggregationRules{
+			{
+				Match:            `http_requests_total{job="api"}`,
+				DropLabels:       []string{"pod", "instance"},
+				Type:             AggregationTypeCounter,
+				Interval:         defaultAggregationInterval,
+				OutputMetricName: "service:http_requests_total:sum",
+			},
+		}
+}
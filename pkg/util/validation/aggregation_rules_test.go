@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestAggregationRules_Unmarshal(t *testing.T) {
+	var l Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+aggregation_rules:
+  - match: http_requests_total{job="api"}
+    drop_labels: [pod]
+    type: counter
+    output_metric_name: job:http_requests_total:sum
+`), &l))
+
+	require.Len(t, l.AggregationRules, 1)
+	rule := l.AggregationRules[0]
+	assert.Equal(t, AggregationTypeCounter, rule.Type)
+	assert.Equal(t, model.Duration(time.Minute), rule.Interval)
+	assert.Equal(t, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, "job", "api"),
+		labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "http_requests_total"),
+	}, rule.Matchers())
+
+	var fromJSON Limits
+	require.NoError(t, json.Unmarshal([]byte(`{"aggregation_rules": [{"match": "queue_length", "type": "gauge", "interval": "30s", "output_metric_name": "queue_length:sum", "keep_raw_series": true}]}`), &fromJSON))
+	require.Len(t, fromJSON.AggregationRules, 1)
+	assert.Equal(t, model.Duration(30*time.Second), fromJSON.AggregationRules[0].Interval)
+	assert.True(t, fromJSON.AggregationRules[0].KeepRawSeries)
+	assert.Len(t, fromJSON.AggregationRules[0].Matchers(), 1)
+
+	for cfg, expectedErr := range map[string]string{
+		`aggregation_rules: [{match: "metric{", type: counter, output_metric_name: out}]`:                     `invalid aggregation rule match "metric{"`,
+		`aggregation_rules: [{match: metric, type: summary, output_metric_name: out}]`:                        `invalid aggregation rule type "summary"`,
+		`aggregation_rules: [{match: metric, type: counter, output_metric_name: "1out"}]`:                     `invalid aggregation rule output metric name "1out"`,
+		`aggregation_rules: [{match: metric, type: histogram, drop_labels: [le], output_metric_name: out}]`:   `can't drop the le label`,
+		`aggregation_rules: [{match: metric, type: gauge, drop_labels: [__name__], output_metric_name: out}]`: `can't drop the metric name`,
+	} {
+		err := yaml.Unmarshal([]byte(cfg), &Limits{})
+		require.Error(t, err, cfg)
+		assert.Contains(t, err.Error(), expectedErr, cfg)
+	}
+}
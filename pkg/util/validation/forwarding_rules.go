@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// ForwardingRule defines the remote_write endpoint to which the distributor forwards the series matching a selector.
+type ForwardingRule struct {
+	// Match is the series selector, e.g. http_requests_total{env="prod"}.
+	Match string `yaml:"match" json:"match"`
+
+	// Endpoint is the URL of the remote_write endpoint to which the matching series are forwarded.
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+
+	// Ingest defines whether the matching series are still pushed to the ingesters despite being forwarded.
+	Ingest bool `yaml:"ingest" json:"ingest"`
+
+	matchers []*labels.Matcher
+}
+
+// Matchers returns the matchers parsed from Match.
+func (r *ForwardingRule) Matchers() []*labels.Matcher {
+	return r.matchers
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (r *ForwardingRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain ForwardingRule
+	if err := unmarshal((*plain)(r)); err != nil {
+		return err
+	}
+	return r.parseMatch()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *ForwardingRule) UnmarshalJSON(data []byte) error {
+	type plain ForwardingRule
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	return r.parseMatch()
+}
+
+func (r *ForwardingRule) parseMatch() error {
+	matchers, err := parser.ParseMetricSelector(r.Match)
+	if err != nil {
+		return errors.Wrapf(err, "invalid forwarding rule match %q", r.Match)
+	}
+	r.matchers = matchers
+	return nil
+}
+
+// ForwardingRules is a list of rules based on which the distributor forwards the incoming series.
+// All the rules are evaluated against each series.
+type ForwardingRules []ForwardingRule
+
+// legacyForwardingRules is the deprecated format of the forwarding rules, keyed by metric name.
+// The series of a metric could be further selected with the rule's match selector.
+type legacyForwardingRules map[string]struct {
+	Ingest   bool   `yaml:"ingest" json:"ingest"`
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+	Match    string `yaml:"match" json:"match"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. The deprecated format keyed by metric name is still supported.
+func (r *ForwardingRules) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	if _, ok := raw.([]interface{}); ok || raw == nil {
+		type plain ForwardingRules
+		return unmarshal((*plain)(r))
+	}
+
+	var legacy legacyForwardingRules
+	if err := unmarshal(&legacy); err != nil {
+		return err
+	}
+	rules, err := legacy.rules()
+	if err != nil {
+		return err
+	}
+	*r = rules
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The deprecated format keyed by metric name is still supported.
+func (r *ForwardingRules) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		type plain ForwardingRules
+		return json.Unmarshal(data, (*plain)(r))
+	}
+
+	var legacy legacyForwardingRules
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	rules, err := legacy.rules()
+	if err != nil {
+		return err
+	}
+	*r = rules
+	return nil
+}
+
+// rules converts the legacy rules to a list of rules, each selecting the series of its metric.
+func (l legacyForwardingRules) rules() (ForwardingRules, error) {
+	metrics := make([]string, 0, len(l))
+	for metric := range l {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	rules := make(ForwardingRules, 0, len(l))
+	for _, metric := range metrics {
+		legacy := l[metric]
+
+		matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metric)}
+		if legacy.Match != "" {
+			selected, err := parser.ParseMetricSelector(legacy.Match)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid forwarding rule match %q of metric %q", legacy.Match, metric)
+			}
+			matchers = append(matchers, selected...)
+		}
+
+		match := make([]string, 0, len(matchers))
+		for _, m := range matchers {
+			match = append(match, m.String())
+		}
+
+		rules = append(rules, ForwardingRule{
+			Match:    "{" + strings.Join(match, ", ") + "}",
+			Endpoint: legacy.Endpoint,
+			Ingest:   legacy.Ingest,
+			matchers: matchers,
+		})
+	}
+	return rules, nil
+}
+
+// ExampleDoc implements the ExamplerConfig interface used by the doc generator.
+func (r *ForwardingRules) ExampleDoc() (comment string, yaml interface{}) {
+	return `The following configuration forwards the production request counters to another remote_write endpoint, and still pushes them to the ingesters.`,
+		ForwardingRules{
+			{
+				Match:    `http_requests_total{env="prod"}`,
+				Endpoint: "http://remote-write.example.com/api/v1/push",
+				Ingest:   true,
+			},
+		}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestForwardingRules_Unmarshal(t *testing.T) {
+	var l Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+forwarding_rules:
+  - match: '{env="prod"}'
+    endpoint: http://prod
+  - match: http_requests_total
+    endpoint: http://requests
+    ingest: true
+`), &l))
+
+	require.Len(t, l.ForwardingRules, 2)
+	assert.Equal(t, "http://prod", l.ForwardingRules[0].Endpoint)
+	assert.False(t, l.ForwardingRules[0].Ingest)
+	assert.Equal(t, []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "env", "prod")}, l.ForwardingRules[0].Matchers())
+	assert.True(t, l.ForwardingRules[1].Ingest)
+	assert.Equal(t, []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "http_requests_total")}, l.ForwardingRules[1].Matchers())
+
+	var fromJSON Limits
+	require.NoError(t, json.Unmarshal([]byte(`{"forwarding_rules": [{"match": "queue_length", "endpoint": "http://queues", "ingest": true}]}`), &fromJSON))
+	require.Len(t, fromJSON.ForwardingRules, 1)
+	assert.True(t, fromJSON.ForwardingRules[0].Ingest)
+	assert.Len(t, fromJSON.ForwardingRules[0].Matchers(), 1)
+
+	for cfg, expectedErr := range map[string]string{
+		`forwarding_rules: [{match: "metric{", endpoint: http://endpoint}]`:   `invalid forwarding rule match "metric{"`,
+		`forwarding_rules: [{endpoint: http://endpoint}]`:                     `invalid forwarding rule match ""`,
+		`forwarding_rules: {metric: {match: "{", endpoint: http://endpoint}}`: `invalid forwarding rule match "{" of metric "metric"`,
+	} {
+		err := yaml.Unmarshal([]byte(cfg), &Limits{})
+		require.Error(t, err, cfg)
+		assert.Contains(t, err.Error(), expectedErr, cfg)
+	}
+}
+
+func TestForwardingRules_UnmarshalLegacyFormat(t *testing.T) {
+	expected := ForwardingRules{
+		{
+			Match:    `{__name__="metric1"}`,
+			Endpoint: "http://endpoint1",
+			matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "metric1")},
+		},
+		{
+			Match:    `{__name__="metric2", env="prod"}`,
+			Endpoint: "http://endpoint2",
+			Ingest:   true,
+			matchers: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "metric2"),
+				labels.MustNewMatcher(labels.MatchEqual, "env", "prod"),
+			},
+		},
+	}
+
+	var l Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+forwarding_rules:
+  metric2:
+    endpoint: http://endpoint2
+    ingest: true
+    match: '{env="prod"}'
+  metric1:
+    endpoint: http://endpoint1
+`), &l))
+	assert.Equal(t, expected, l.ForwardingRules)
+
+	var fromJSON Limits
+	require.NoError(t, json.Unmarshal([]byte(`{"forwarding_rules": {"metric2": {"endpoint": "http://endpoint2", "ingest": true, "match": "{env=\"prod\"}"}, "metric1": {"endpoint": "http://endpoint1"}}}`), &fromJSON))
+	assert.Equal(t, expected, fromJSON.ForwardingRules)
+
+	// The converted rules select the same series once parsed again.
+	for _, rule := range expected {
+		reparsed := ForwardingRule{Match: rule.Match}
+		require.NoError(t, reparsed.parseMatch())
+		assert.Equal(t, rule.Matchers(), reparsed.Matchers())
+	}
+}
@@ -10,15 +10,25 @@ import (
 	"encoding/json"
 	"flag"
 	"math"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/relabel"
 	"golang.org/x/time/rate"
 )
 
+var (
+	errNegativeIngestionRate         = errors.New("invalid ingestion_rate, the value must be greater or equal to zero")
+	errNegativeIngestionBurstSize    = errors.New("invalid ingestion_burst_size, the value must be greater or equal to zero")
+	errMissingHALabels               = errors.New("the ha_cluster_label and ha_replica_label must be set when accept_ha_samples is enabled")
+	errRulerAlertmanagerMultipleAuth = errors.New("the ruler_alertmanager_client bearer_token can't be set together with the basic authentication")
+)
+
 // LimitError are errors that do not comply with the limits specified.
 type LimitError string
 
@@ -26,17 +36,19 @@ func (e LimitError) Error() string {
 	return string(e)
 }
 
-type ForwardingRule struct {
-	// Ingest defines whether a metric should still be pushed to the Ingesters despite it being forwarded.
-	Ingest bool `yaml:"ingest" json:"ingest"`
-
-	// Endpoint is the URL of the remote_write endpoint to which a metric should be forwarded.
-	Endpoint string `yaml:"endpoint" json:"endpoint"`
+// RulerAlertmanagerClientConfig is the per-tenant client configuration of the Alertmanager which the ruler
+// sends the tenant's notifications to.
+type RulerAlertmanagerClientConfig struct {
+	BasicAuthUsername     string         `yaml:"basic_auth_username" json:"basic_auth_username" doc:"nocli|description=HTTP basic authentication username. It overrides the username set in the URL." category:"experimental"`
+	BasicAuthPassword     flagext.Secret `yaml:"basic_auth_password" json:"basic_auth_password" doc:"nocli|description=HTTP basic authentication password. It overrides the password set in the URL." category:"experimental"`
+	BearerToken           flagext.Secret `yaml:"bearer_token" json:"bearer_token" doc:"nocli|description=Bearer token sent in the Authorization header. It can't be set together with the basic authentication." category:"experimental"`
+	TLSCAPath             string         `yaml:"tls_ca_path" json:"tls_ca_path" doc:"nocli|description=Path to the CA certificates file to validate the server certificate against, relative to -ruler.alertmanager-client.tenant-tls-files-directory." category:"experimental"`
+	TLSCertPath           string         `yaml:"tls_cert_path" json:"tls_cert_path" doc:"nocli|description=Path to the client certificate file, relative to -ruler.alertmanager-client.tenant-tls-files-directory." category:"experimental"`
+	TLSKeyPath            string         `yaml:"tls_key_path" json:"tls_key_path" doc:"nocli|description=Path to the key file of the client certificate, relative to -ruler.alertmanager-client.tenant-tls-files-directory." category:"experimental"`
+	TLSServerName         string         `yaml:"tls_server_name" json:"tls_server_name" doc:"nocli|description=Override the expected name on the server certificate." category:"experimental"`
+	TLSInsecureSkipVerify bool           `yaml:"tls_insecure_skip_verify" json:"tls_insecure_skip_verify" doc:"nocli|description=Skip validating the server certificate." category:"experimental"`
 }
 
-// ForwardingRules are keyed by metric names, excluding labels.
-type ForwardingRules map[string]ForwardingRule
-
 // Limits describe all the limits for users; can be used to describe global default
 // limits via flags, or per-user limits via yaml config.
 type Limits struct {
@@ -47,6 +59,7 @@ type Limits struct {
 	HAClusterLabel            string              `yaml:"ha_cluster_label" json:"ha_cluster_label"`
 	HAReplicaLabel            string              `yaml:"ha_replica_label" json:"ha_replica_label"`
 	HAMaxClusters             int                 `yaml:"ha_max_clusters" json:"ha_max_clusters"`
+	HATrackerFailoverTimeout  model.Duration      `yaml:"ha_tracker_failover_timeout" json:"ha_tracker_failover_timeout" doc:"nocli|description=Failover timeout of the HA tracker for the tenant, overriding -distributor.ha-tracker.failover-timeout. 0 to use -distributor.ha-tracker.failover-timeout. The timeout is raised to the minimum failover timeout allowed by the HA tracker configuration if lower." category:"experimental"`
 	DropLabels                flagext.StringSlice `yaml:"drop_labels" json:"drop_labels" category:"advanced"`
 	MaxLabelNameLength        int                 `yaml:"max_label_name_length" json:"max_label_name_length"`
 	MaxLabelValueLength       int                 `yaml:"max_label_value_length" json:"max_label_value_length"`
@@ -56,11 +69,16 @@ type Limits struct {
 	EnforceMetadataMetricName bool                `yaml:"enforce_metadata_metric_name" json:"enforce_metadata_metric_name" category:"advanced"`
 	IngestionTenantShardSize  int                 `yaml:"ingestion_tenant_shard_size" json:"ingestion_tenant_shard_size"`
 	MetricRelabelConfigs      []*relabel.Config   `yaml:"metric_relabel_configs,omitempty" json:"metric_relabel_configs,omitempty" doc:"nocli|description=List of metric relabel configurations. Note that in most situations, it is more effective to use metrics relabeling directly in the Prometheus server, e.g. remote_write.write_relabel_configs." category:"experimental"`
+	AggregationRules          AggregationRules    `yaml:"aggregation_rules,omitempty" json:"aggregation_rules,omitempty" doc:"nocli|description=Rules based on which the distributor pre-aggregates the incoming series matching a selector over a time window, before pushing them to the ingesters. Requires -distributor.aggregation.enabled=true." category:"experimental"`
 
 	// Ingester enforced limits.
 	// Series
 	MaxGlobalSeriesPerUser   int `yaml:"max_global_series_per_user" json:"max_global_series_per_user"`
 	MaxGlobalSeriesPerMetric int `yaml:"max_global_series_per_metric" json:"max_global_series_per_metric"`
+	// Series per label value
+	MaxGlobalSeriesPerLabelValueLabelName string         `yaml:"max_global_series_per_label_value_label_name" json:"max_global_series_per_label_value_label_name" category:"experimental"`
+	MaxGlobalSeriesPerLabelValue          int            `yaml:"max_global_series_per_label_value" json:"max_global_series_per_label_value" category:"experimental"`
+	MaxGlobalSeriesPerLabelValueOverrides map[string]int `yaml:"max_global_series_per_label_value_overrides" json:"max_global_series_per_label_value_overrides" doc:"nocli|description=Per label value overrides of the max series per label value limit. The map key is the label value, and the map value is the limit (0 to disable the limit for the label value)." category:"experimental"`
 	// Metadata
 	MaxGlobalMetricsWithMetadataPerUser int `yaml:"max_global_metadata_per_user" json:"max_global_metadata_per_user"`
 	MaxGlobalMetadataPerMetric          int `yaml:"max_global_metadata_per_metric" json:"max_global_metadata_per_metric"`
@@ -68,17 +86,19 @@ type Limits struct {
 	MaxGlobalExemplarsPerUser int `yaml:"max_global_exemplars_per_user" json:"max_global_exemplars_per_user" category:"experimental"`
 
 	// Querier enforced limits.
-	MaxChunksPerQuery              int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
-	MaxFetchedSeriesPerQuery       int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
-	MaxFetchedChunkBytesPerQuery   int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
-	MaxQueryLookback               model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
-	MaxQueryLength                 model.Duration `yaml:"max_query_length" json:"max_query_length"`
-	MaxQueryParallelism            int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
-	MaxLabelsQueryLength           model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
-	MaxCacheFreshness              model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
-	MaxQueriersPerTenant           int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
-	QueryShardingTotalShards       int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
-	QueryShardingMaxShardedQueries int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
+	MaxChunksPerQuery               int                         `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
+	MaxFetchedSeriesPerQuery        int                         `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
+	MaxFetchedChunkBytesPerQuery    int                         `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
+	MaxQueryLookback                model.Duration              `yaml:"max_query_lookback" json:"max_query_lookback"`
+	MaxQueryLength                  model.Duration              `yaml:"max_query_length" json:"max_query_length"`
+	MaxQueryParallelism             int                         `yaml:"max_query_parallelism" json:"max_query_parallelism"`
+	MaxLabelsQueryLength            model.Duration              `yaml:"max_labels_query_length" json:"max_labels_query_length"`
+	MaxCacheFreshness               model.Duration              `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
+	MaxQueriersPerTenant            int                         `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	MaxConcurrentQueries            int                         `yaml:"max_concurrent_queries" json:"max_concurrent_queries" category:"experimental"`
+	MaxConcurrentQueriesTimeWindows QueryConcurrencyTimeWindows `yaml:"max_concurrent_queries_time_windows" json:"max_concurrent_queries_time_windows" doc:"nocli|description=Time windows, in UTC, overriding the max concurrent queries limit. The first window containing the current time of the day takes precedence." category:"experimental"`
+	QueryShardingTotalShards        int                         `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
+	QueryShardingMaxShardedQueries  int                         `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
 	// Cardinality
 	CardinalityAnalysisEnabled                    bool `yaml:"cardinality_analysis_enabled" json:"cardinality_analysis_enabled"`
 	LabelNamesAndValuesResultsMaxSizeBytes        int  `yaml:"label_names_and_values_results_max_size_bytes" json:"label_names_and_values_results_max_size_bytes"`
@@ -89,6 +109,12 @@ type Limits struct {
 	RulerTenantShardSize        int            `yaml:"ruler_tenant_shard_size" json:"ruler_tenant_shard_size"`
 	RulerMaxRulesPerRuleGroup   int            `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
 	RulerMaxRuleGroupsPerTenant int            `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
+	// Concurrent evaluation of the independent rules of the tenant's rule groups.
+	RulerMaxIndependentRuleEvaluationConcurrencyPerTenant int `yaml:"ruler_max_independent_rule_evaluation_concurrency_per_tenant" json:"ruler_max_independent_rule_evaluation_concurrency_per_tenant" category:"experimental"`
+	// Per-tenant Alertmanager to send the ruler notifications to.
+	RulerAlertmanagerURL          string                        `yaml:"ruler_alertmanager_url" json:"ruler_alertmanager_url" doc:"nocli|description=Comma-separated list of URL(s) of the Alertmanager(s) to send the tenant's notifications to, with the same format as -ruler.alertmanager-url. If not set, -ruler.alertmanager-url is used." category:"experimental"`
+	RulerAlertmanagerClientConfig RulerAlertmanagerClientConfig `yaml:"ruler_alertmanager_client" json:"ruler_alertmanager_client" doc:"description=Client configuration of the Alertmanager(s) set with ruler_alertmanager_url. Used only if ruler_alertmanager_url is set."`
+	RulerExternalLabels           map[string]string             `yaml:"ruler_external_labels" json:"ruler_external_labels" doc:"nocli|description=Labels added to the alerts of the tenant sent to the Alertmanager." category:"experimental"`
 
 	// Store-gateway.
 	StoreGatewayTenantShardSize int `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
@@ -119,7 +145,7 @@ type Limits struct {
 	AlertmanagerMaxAlertsCount                 int `yaml:"alertmanager_max_alerts_count" json:"alertmanager_max_alerts_count"`
 	AlertmanagerMaxAlertsSizeBytes             int `yaml:"alertmanager_max_alerts_size_bytes" json:"alertmanager_max_alerts_size_bytes"`
 
-	ForwardingRules ForwardingRules `yaml:"forwarding_rules" json:"forwarding_rules" doc:"nocli|description=Rules based on which the Distributor decides whether a metric should be forwarded to an alternative remote_write API endpoint."`
+	ForwardingRules ForwardingRules `yaml:"forwarding_rules" json:"forwarding_rules" doc:"nocli|description=Rules based on which the Distributor decides whether a series should be forwarded to an alternative remote_write API endpoint. Each rule forwards the series matching its series selector. The series matching several rules are forwarded to each of their endpoints, and are pushed to the ingesters if any of the matching rules ingests them."`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet
@@ -142,6 +168,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 
 	f.IntVar(&l.MaxGlobalSeriesPerUser, "ingester.max-global-series-per-user", 150000, "The maximum number of active series per tenant, across the cluster before replication. 0 to disable.")
 	f.IntVar(&l.MaxGlobalSeriesPerMetric, "ingester.max-global-series-per-metric", 20000, "The maximum number of active series per metric name, across the cluster before replication. 0 to disable.")
+	f.StringVar(&l.MaxGlobalSeriesPerLabelValueLabelName, "ingester.max-global-series-per-label-value-label-name", "", "Name of the label whose values are used to enforce the max series per label value limit, for example namespace or team. Series without this label are not subject to the limit. Empty to disable.")
+	f.IntVar(&l.MaxGlobalSeriesPerLabelValue, "ingester.max-global-series-per-label-value", 0, "The maximum number of active series per value of the label configured via -ingester.max-global-series-per-label-value-label-name, across the cluster before replication. 0 to disable.")
 
 	f.IntVar(&l.MaxGlobalMetricsWithMetadataPerUser, "ingester.max-global-metadata-per-user", 0, "The maximum number of active metrics with metadata per tenant, across the cluster. 0 to disable.")
 	f.IntVar(&l.MaxGlobalMetadataPerMetric, "ingester.max-global-metadata-per-metric", 0, "The maximum number of metadata per metric, across the cluster. 0 to disable.")
@@ -160,6 +188,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.MaxCacheFreshness.Set("1m")
 	f.Var(&l.MaxCacheFreshness, "query-frontend.max-cache-freshness", "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
 	f.IntVar(&l.MaxQueriersPerTenant, "query-frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
+	f.IntVar(&l.MaxConcurrentQueries, "query-scheduler.max-concurrent-queries-per-tenant", 0, "Maximum number of requests of a single tenant which each query-scheduler dispatches to queriers at the same time. Requests above this limit are kept in the queue until a running one completes. 0 to disable.")
 	f.IntVar(&l.QueryShardingTotalShards, "query-frontend.query-sharding-total-shards", 16, "The amount of shards to use when doing parallelisation via query sharding by tenant. 0 to disable query sharding for tenant. Query sharding implementation will adjust the number of query shards based on compactor shards. This allows querier to not search the blocks which cannot possibly have the series for given query shard.")
 	f.IntVar(&l.QueryShardingMaxShardedQueries, "query-frontend.query-sharding-max-sharded-queries", 128, "The max number of sharded queries that can be run for a given received query. 0 to disable limit.")
 
@@ -167,6 +196,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.RulerTenantShardSize, "ruler.tenant-shard-size", 0, "The tenant's shard size when sharding is used by ruler. Value of 0 disables shuffle sharding for the tenant, and tenant rules will be sharded across all ruler replicas.")
 	f.IntVar(&l.RulerMaxRulesPerRuleGroup, "ruler.max-rules-per-rule-group", 20, "Maximum number of rules per rule group per-tenant. 0 to disable.")
 	f.IntVar(&l.RulerMaxRuleGroupsPerTenant, "ruler.max-rule-groups-per-tenant", 70, "Maximum number of rule groups per-tenant. 0 to disable.")
+	f.IntVar(&l.RulerMaxIndependentRuleEvaluationConcurrencyPerTenant, "ruler.max-independent-rule-evaluation-concurrency-per-tenant", 0, "Maximum number of independent rules of the tenant whose query can be run concurrently, ahead of the sequential evaluation of their rule group. An independent rule doesn't select the output of other rules of its rule group. The concurrency is also limited across all tenants by -ruler.max-independent-rule-evaluation-concurrency. 0 to evaluate the rules sequentially.")
 
 	f.Var(&l.CompactorBlocksRetentionPeriod, "compactor.blocks-retention-period", "Delete blocks containing samples older than the specified retention period. 0 to disable.")
 	f.IntVar(&l.CompactorSplitAndMergeShards, "compactor.split-and-merge-shards", 0, "The number of shards to use when splitting blocks. 0 to disable splitting.")
@@ -228,6 +258,54 @@ func (l *Limits) UnmarshalJSON(data []byte) error {
 	return dec.Decode((*plain)(l))
 }
 
+// Validate returns an error if the limits are invalid.
+func (l *Limits) Validate() error {
+	if l.IngestionRate < 0 {
+		return errNegativeIngestionRate
+	}
+	if l.IngestionBurstSize < 0 {
+		return errNegativeIngestionBurstSize
+	}
+	if l.AcceptHASamples && (l.HAClusterLabel == "" || l.HAReplicaLabel == "") {
+		return errMissingHALabels
+	}
+	for name, size := range map[string]int{
+		"ingestion_tenant_shard_size":     l.IngestionTenantShardSize,
+		"ruler_tenant_shard_size":         l.RulerTenantShardSize,
+		"store_gateway_tenant_shard_size": l.StoreGatewayTenantShardSize,
+		"compactor_tenant_shard_size":     l.CompactorTenantShardSize,
+	} {
+		if size < 0 {
+			return errors.Errorf("invalid %s, the value must be greater or equal to zero", name)
+		}
+	}
+	if l.RulerMaxIndependentRuleEvaluationConcurrencyPerTenant < 0 {
+		return errors.New("invalid ruler_max_independent_rule_evaluation_concurrency_per_tenant, the value must be greater or equal to zero")
+	}
+	for _, rawURL := range strings.Split(l.RulerAlertmanagerURL, ",") {
+		if rawURL == "" {
+			continue
+		}
+		if _, err := url.Parse(rawURL); err != nil {
+			return errors.Wrapf(err, "invalid ruler_alertmanager_url %q", rawURL)
+		}
+	}
+	if l.RulerAlertmanagerClientConfig.BearerToken.String() != "" && (l.RulerAlertmanagerClientConfig.BasicAuthUsername != "" || l.RulerAlertmanagerClientConfig.BasicAuthPassword.String() != "") {
+		return errRulerAlertmanagerMultipleAuth
+	}
+	for name := range l.RulerExternalLabels {
+		if !model.LabelName(name).IsValid() {
+			return errors.Errorf("invalid ruler_external_labels label name %q", name)
+		}
+	}
+	for _, rule := range l.ForwardingRules {
+		if rule.Endpoint == "" {
+			return errors.Errorf("missing endpoint of the forwarding rule for %q", rule.Match)
+		}
+	}
+	return nil
+}
+
 func (l *Limits) copyNotificationIntegrationLimits(defaults NotificationRateLimitMap) {
 	l.NotificationRateLimitPerIntegration = make(map[string]float64, len(defaults))
 	for k, v := range defaults {
@@ -354,6 +432,22 @@ func (o *Overrides) MaxGlobalSeriesPerMetric(userID string) int {
 	return o.getOverridesForUser(userID).MaxGlobalSeriesPerMetric
 }
 
+// MaxGlobalSeriesPerLabelValueLabelName returns the name of the label whose values are used to enforce
+// the max series per label value limit, or an empty string if the limit is disabled.
+func (o *Overrides) MaxGlobalSeriesPerLabelValueLabelName(userID string) string {
+	return o.getOverridesForUser(userID).MaxGlobalSeriesPerLabelValueLabelName
+}
+
+// MaxGlobalSeriesPerLabelValue returns the maximum number of series allowed per value of the
+// MaxGlobalSeriesPerLabelValueLabelName label across the cluster.
+func (o *Overrides) MaxGlobalSeriesPerLabelValue(userID, labelValue string) int {
+	limits := o.getOverridesForUser(userID)
+	if limit, ok := limits.MaxGlobalSeriesPerLabelValueOverrides[labelValue]; ok {
+		return limit
+	}
+	return limits.MaxGlobalSeriesPerLabelValue
+}
+
 func (o *Overrides) MaxChunksPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxChunksPerQuery
 }
@@ -396,6 +490,12 @@ func (o *Overrides) MaxQueriersPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxQueriersPerTenant
 }
 
+// MaxConcurrentQueries returns the maximum number of requests of this user which can be
+// dispatched to queriers at the same time, taking into account the time windows overrides.
+func (o *Overrides) MaxConcurrentQueries(userID string) int {
+	return o.getOverridesForUser(userID).maxConcurrentQueriesAt(time.Now())
+}
+
 // MaxQueryParallelism returns the limit to the number of split queries the
 // frontend will process in parallel.
 func (o *Overrides) MaxQueryParallelism(userID string) int {
@@ -484,6 +584,28 @@ func (o *Overrides) RulerMaxRuleGroupsPerTenant(userID string) int {
 	return o.getOverridesForUser(userID).RulerMaxRuleGroupsPerTenant
 }
 
+// RulerMaxIndependentRuleEvaluationConcurrencyPerTenant returns the maximum number of independent rules of a given user
+// that can be evaluated concurrently.
+func (o *Overrides) RulerMaxIndependentRuleEvaluationConcurrencyPerTenant(userID string) int {
+	return o.getOverridesForUser(userID).RulerMaxIndependentRuleEvaluationConcurrencyPerTenant
+}
+
+// RulerAlertmanagerURL returns the URL(s) of the Alertmanager(s) to send the notifications of a given user to,
+// or an empty string to use the global ones.
+func (o *Overrides) RulerAlertmanagerURL(userID string) string {
+	return o.getOverridesForUser(userID).RulerAlertmanagerURL
+}
+
+// RulerAlertmanagerClientConfig returns the client config of the Alertmanager(s) set for a given user.
+func (o *Overrides) RulerAlertmanagerClientConfig(userID string) RulerAlertmanagerClientConfig {
+	return o.getOverridesForUser(userID).RulerAlertmanagerClientConfig
+}
+
+// RulerExternalLabels returns the labels added to the alerts of a given user sent to the Alertmanager.
+func (o *Overrides) RulerExternalLabels(userID string) labels.Labels {
+	return labels.FromMap(o.getOverridesForUser(userID).RulerExternalLabels)
+}
+
 // StoreGatewayTenantShardSize returns the store-gateway shard size for a given user.
 func (o *Overrides) StoreGatewayTenantShardSize(userID string) int {
 	return o.getOverridesForUser(userID).StoreGatewayTenantShardSize
@@ -494,6 +616,11 @@ func (o *Overrides) MaxHAClusters(user string) int {
 	return o.getOverridesForUser(user).HAMaxClusters
 }
 
+// HATrackerFailoverTimeout returns the failover timeout of the HA tracker for a user, or 0 to use the global one.
+func (o *Overrides) HATrackerFailoverTimeout(user string) time.Duration {
+	return time.Duration(o.getOverridesForUser(user).HATrackerFailoverTimeout)
+}
+
 // S3SSEType returns the per-tenant S3 SSE type.
 func (o *Overrides) S3SSEType(user string) string {
 	return o.getOverridesForUser(user).S3SSEType
@@ -593,6 +720,11 @@ func (o *Overrides) AlertmanagerMaxAlertsSizeBytes(userID string) int {
 	return o.getOverridesForUser(userID).AlertmanagerMaxAlertsSizeBytes
 }
 
+// AggregationRules returns the rules based on which the distributor pre-aggregates the series of a given user.
+func (o *Overrides) AggregationRules(userID string) AggregationRules {
+	return o.getOverridesForUser(userID).AggregationRules
+}
+
 func (o *Overrides) ForwardingRules(user string) ForwardingRules {
 	return o.getOverridesForUser(user).ForwardingRules
 }
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/grafana/dskit/flagext"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/stretchr/testify/assert"
@@ -489,3 +490,42 @@ testuser:
 		})
 	}
 }
+
+func TestLimits_Validate(t *testing.T) {
+	var defaults Limits
+	flagext.DefaultValues(&defaults)
+	require.NoError(t, defaults.Validate())
+
+	for name, tc := range map[string]struct {
+		limits      func(l *Limits)
+		expectedErr string
+	}{
+		"negative ingestion rate": {
+			limits:      func(l *Limits) { l.IngestionRate = -1 },
+			expectedErr: "invalid ingestion_rate",
+		},
+		"negative shard size": {
+			limits:      func(l *Limits) { l.StoreGatewayTenantShardSize = -1 },
+			expectedErr: "invalid store_gateway_tenant_shard_size",
+		},
+		"HA tracker without replica label": {
+			limits: func(l *Limits) {
+				l.AcceptHASamples = true
+				l.HAReplicaLabel = ""
+			},
+			expectedErr: "the ha_cluster_label and ha_replica_label must be set",
+		},
+		"forwarding rule without endpoint": {
+			limits:      func(l *Limits) { l.ForwardingRules = ForwardingRules{{Match: "metric"}} },
+			expectedErr: `missing endpoint of the forwarding rule for "metric"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := defaults
+			tc.limits(&l)
+			err := l.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
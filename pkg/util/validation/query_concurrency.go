@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const timeOfDayLayout = "15:04"
+
+// TimeOfDay is a time of the day in UTC, with minutes precision, expressed as
+// the duration elapsed since midnight. It's marshalled in the "HH:MM" format.
+type TimeOfDay time.Duration
+
+// ParseTimeOfDay parses a time of the day in the "HH:MM" format.
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse(timeOfDayLayout, s)
+	if err != nil {
+		return 0, errors.Errorf("invalid time of the day %q, expected format is HH:MM", s)
+	}
+	return TimeOfDay(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), nil
+}
+
+// String implements fmt.Stringer.
+func (t TimeOfDay) String() string {
+	d := time.Duration(t)
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (t *TimeOfDay) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return t.set(s)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (t TimeOfDay) MarshalYAML() (interface{}, error) {
+	return t.String(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return t.set(s)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeOfDay) set(s string) error {
+	parsed, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// QueryConcurrencyTimeWindow overrides the max concurrent queries limit during a window of the day.
+type QueryConcurrencyTimeWindow struct {
+	StartTime            TimeOfDay `yaml:"start_time" json:"start_time"`
+	EndTime              TimeOfDay `yaml:"end_time" json:"end_time"`
+	MaxConcurrentQueries int       `yaml:"max_concurrent_queries" json:"max_concurrent_queries"`
+}
+
+// Contains returns whether the time of the day of t (in UTC) is within the window.
+// The start time is inclusive, while the end time is exclusive. A window whose end
+// time is before the start time spans across midnight.
+func (w QueryConcurrencyTimeWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	tod := TimeOfDay(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second)
+
+	if w.StartTime <= w.EndTime {
+		return tod >= w.StartTime && tod < w.EndTime
+	}
+	return tod >= w.StartTime || tod < w.EndTime
+}
+
+// QueryConcurrencyTimeWindows is a list of time windows overriding the max concurrent queries limit.
+type QueryConcurrencyTimeWindows []QueryConcurrencyTimeWindow
+
+// ExampleDoc implements the ExamplerConfig interface used by the doc generator.
+func (w *QueryConcurrencyTimeWindows) ExampleDoc() (comment string, yaml interface{}) {
+	return `The following configuration limits the number of concurrent queries to 2 during business hours (UTC).`,
+		QueryConcurrencyTimeWindows{
+			{StartTime: TimeOfDay(9 * time.Hour), EndTime: TimeOfDay(18 * time.Hour), MaxConcurrentQueries: 2},
+		}
+}
+
+// maxConcurrentQueriesAt returns the max concurrent queries limit at the given time:
+// the limit of the first time window containing it, or the default limit otherwise.
+func (l *Limits) maxConcurrentQueriesAt(now time.Time) int {
+	for _, w := range l.MaxConcurrentQueriesTimeWindows {
+		if w.Contains(now) {
+			return w.MaxConcurrentQueries
+		}
+	}
+	return l.MaxConcurrentQueries
+}
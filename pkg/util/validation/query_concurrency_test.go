@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestQueryConcurrencyTimeWindows_Unmarshal(t *testing.T) {
+	var l Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+max_concurrent_queries: 10
+max_concurrent_queries_time_windows:
+  - start_time: "09:00"
+    end_time: "17:30"
+    max_concurrent_queries: 2
+`), &l))
+
+	expected := QueryConcurrencyTimeWindows{{StartTime: TimeOfDay(9 * time.Hour), EndTime: TimeOfDay(17*time.Hour + 30*time.Minute), MaxConcurrentQueries: 2}}
+	assert.Equal(t, 10, l.MaxConcurrentQueries)
+	assert.Equal(t, expected, l.MaxConcurrentQueriesTimeWindows)
+
+	out, err := yaml.Marshal(l.MaxConcurrentQueriesTimeWindows)
+	require.NoError(t, err)
+	assert.Equal(t, "- start_time: \"09:00\"\n  end_time: \"17:30\"\n  max_concurrent_queries: 2\n", string(out))
+
+	var fromJSON Limits
+	require.NoError(t, json.Unmarshal([]byte(`{"max_concurrent_queries_time_windows": [{"start_time": "09:00", "end_time": "17:30", "max_concurrent_queries": 2}]}`), &fromJSON))
+	assert.Equal(t, expected, fromJSON.MaxConcurrentQueriesTimeWindows)
+
+	assert.EqualError(t, yaml.Unmarshal([]byte(`max_concurrent_queries_time_windows: [{start_time: "9am"}]`), &l), `invalid time of the day "9am", expected format is HH:MM`)
+}
+
+func TestLimits_maxConcurrentQueriesAt(t *testing.T) {
+	l := Limits{
+		MaxConcurrentQueries: 10,
+		MaxConcurrentQueriesTimeWindows: QueryConcurrencyTimeWindows{
+			{StartTime: TimeOfDay(9 * time.Hour), EndTime: TimeOfDay(18 * time.Hour), MaxConcurrentQueries: 2},
+			{StartTime: TimeOfDay(22 * time.Hour), EndTime: TimeOfDay(2 * time.Hour), MaxConcurrentQueries: 0},
+		},
+	}
+
+	for at, expected := range map[string]int{
+		"08:59": 10,
+		"09:00": 2,
+		"17:59": 2,
+		"18:00": 10,
+		"23:00": 0,
+		"01:00": 0,
+		"02:00": 10,
+	} {
+		tod, err := ParseTimeOfDay(at)
+		require.NoError(t, err)
+
+		now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(tod))
+		assert.Equal(t, expected, l.maxConcurrentQueriesAt(now), at)
+	}
+}
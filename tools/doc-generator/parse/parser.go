@@ -344,6 +344,12 @@ func getFieldCustomType(t reflect.Type) (string, bool) {
 		return "relabel_config...", true
 	case reflect.TypeOf(ingester.ActiveSeriesCustomTrackersConfig{}).String():
 		return "map of tracker name (string) to matcher (string)", true
+	case reflect.TypeOf(validation.QueryConcurrencyTimeWindows{}).String():
+		return "list of time windows", true
+	case reflect.TypeOf(validation.AggregationRules{}).String():
+		return "list of aggregation rules", true
+	case reflect.TypeOf(validation.ForwardingRules{}).String():
+		return "list of forwarding rules", true
 	default:
 		return "", false
 	}
@@ -435,10 +441,16 @@ func ReflectType(typ string) reflect.Type {
 		return reflect.TypeOf([]*relabel.Config{})
 	case "map of string to float64":
 		return reflect.TypeOf(map[string]float64{})
+	case "map of string to int":
+		return reflect.TypeOf(map[string]int{})
 	case "list of duration":
 		return reflect.TypeOf(tsdb.DurationList{})
-	case "map of string to validation.ForwardingRule":
-		return reflect.TypeOf(map[string]validation.ForwardingRule{})
+	case "list of time windows":
+		return reflect.TypeOf(validation.QueryConcurrencyTimeWindows{})
+	case "list of aggregation rules":
+		return reflect.TypeOf(validation.AggregationRules{})
+	case "list of forwarding rules":
+		return reflect.TypeOf(validation.ForwardingRules{})
 	default:
 		panic("unknown field type " + typ)
 	}
@@ -509,6 +521,16 @@ func getCustomFieldEntry(field reflect.StructField, fieldValue reflect.Value, fl
 		if err != nil {
 			return nil, err
 		}
+		if fieldFlag == nil {
+			return &ConfigEntry{
+				Kind:          KindField,
+				Name:          getFieldName(field),
+				Required:      isFieldRequired(field),
+				FieldDesc:     getFieldDescription(field, ""),
+				FieldType:     "string",
+				FieldCategory: getFieldCategory(field, ""),
+			}, nil
+		}
 
 		return &ConfigEntry{
 			Kind:          KindField,
@@ -526,6 +548,17 @@ func getCustomFieldEntry(field reflect.StructField, fieldValue reflect.Value, fl
 		if err != nil {
 			return nil, err
 		}
+		if fieldFlag == nil {
+			return &ConfigEntry{
+				Kind:          KindField,
+				Name:          getFieldName(field),
+				Required:      isFieldRequired(field),
+				FieldDesc:     getFieldDescription(field, ""),
+				FieldType:     "duration",
+				FieldDefault:  getFieldDefault(field, model.Duration(0).String()),
+				FieldCategory: getFieldCategory(field, ""),
+			}, nil
+		}
 
 		return &ConfigEntry{
 			Kind:          KindField,
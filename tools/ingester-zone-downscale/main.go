@@ -0,0 +1,351 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/ring"
+	"github.com/pkg/errors"
+)
+
+const (
+	prepareDownscalePath = "/ingester/prepare_downscale"
+
+	stateActive    = "ACTIVE"
+	stateLeaving   = "LEAVING"
+	stateCompleted = "completed"
+	stateFailed    = "failed"
+)
+
+type config struct {
+	ringURL             string
+	zone                string
+	ingesterURLTemplate string
+	pollInterval        time.Duration
+	ingesterTimeout     time.Duration
+	heartbeatTimeout    time.Duration
+	dryRun              bool
+}
+
+func (cfg *config) registerFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.ringURL, "ring-url", "", "URL of the ingesters ring status page, exposed by any Mimir component watching the ingesters ring (eg. http://distributor:8080/ingester/ring). Required.")
+	f.StringVar(&cfg.zone, "zone", "", "Zone of the ingesters to drain. Required.")
+	f.StringVar(&cfg.ingesterURLTemplate, "ingester-url-template", "http://{{ .Host }}:8080", "Go template used to build the HTTP base URL of each ingester. Available fields are .ID (instance ID), .Address (gRPC address in the ring), .Host (host of the gRPC address) and .Zone.")
+	f.DurationVar(&cfg.pollInterval, "poll-interval", 10*time.Second, "How frequently the status of the ingester being drained is polled.")
+	f.DurationVar(&cfg.ingesterTimeout, "ingester-timeout", time.Hour, "Maximum time to wait for a single ingester to be drained.")
+	f.DurationVar(&cfg.heartbeatTimeout, "heartbeat-timeout", time.Minute, "The heartbeat timeout of the ingesters ring. Ingesters in other zones whose last heartbeat is older than the timeout are considered unhealthy. 0 to disable.")
+	f.BoolVar(&cfg.dryRun, "dry-run", false, "Only check the ring and print the ingesters which would be drained.")
+}
+
+func (cfg *config) validate() error {
+	if cfg.ringURL == "" {
+		return errors.New("-ring-url is required")
+	}
+	if cfg.zone == "" {
+		return errors.New("-zone is required")
+	}
+	if cfg.pollInterval <= 0 {
+		return errors.New("-poll-interval must be positive")
+	}
+	if cfg.heartbeatTimeout < 0 {
+		return errors.New("-heartbeat-timeout must not be negative")
+	}
+	return nil
+}
+
+// ringInstance is an instance listed in the ring status page.
+type ringInstance struct {
+	ID        string    `json:"id"`
+	State     string    `json:"state"`
+	Address   string    `json:"address"`
+	Timestamp time.Time `json:"timestamp"`
+	Zone      string    `json:"zone"`
+}
+
+// isHealthy returns whether the instance is healthy for writes, according to the ring.
+func (i ringInstance) isHealthy(heartbeatTimeout time.Duration, now time.Time) bool {
+	// The ring status page reports unhealthy instances with a state which doesn't exist in the ring.
+	state, ok := ring.InstanceState_value[i.State]
+	if !ok {
+		return false
+	}
+
+	desc := ring.InstanceDesc{State: ring.InstanceState(state), Timestamp: i.Timestamp.Unix()}
+	return desc.IsHealthy(ring.Write, heartbeatTimeout, now)
+}
+
+// Host returns the host of the instance address.
+func (i ringInstance) Host() string {
+	host, _, err := net.SplitHostPort(i.Address)
+	if err != nil {
+		return i.Address
+	}
+	return host
+}
+
+type ringStatus struct {
+	Instances []ringInstance `json:"shards"`
+	Now       time.Time      `json:"now"`
+}
+
+// downscaleStatus is the status returned by the ingester prepare downscale endpoint.
+type downscaleStatus struct {
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+type zoneDrainer struct {
+	cfg         config
+	client      *http.Client
+	urlTemplate *template.Template
+	logger      log.Logger
+}
+
+func newZoneDrainer(cfg config, logger log.Logger) (*zoneDrainer, error) {
+	tmpl, err := template.New("ingester-url").Parse(cfg.ingesterURLTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid -ingester-url-template")
+	}
+
+	return &zoneDrainer{
+		cfg:         cfg,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		urlTemplate: tmpl,
+		logger:      logger,
+	}, nil
+}
+
+// drainZone drains the ingesters of the configured zone, one at a time and in ring ID order.
+// Before draining each ingester, the ring is checked to make sure that all the ingesters in
+// the other zones are ACTIVE and heartbeating, so that the drain never reduces the availability below quorum.
+func (d *zoneDrainer) drainZone(ctx context.Context) ([]ringInstance, error) {
+	status, err := d.readRing(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zoneInstances, err := d.checkRing(status)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inst := range zoneInstances {
+		level.Info(d.logger).Log("msg", "Ingester to drain.", "ingester", inst.ID, "state", inst.State)
+	}
+	if d.cfg.dryRun {
+		level.Info(d.logger).Log("msg", "Dry-run, not draining the ingesters.", "zone", d.cfg.zone, "ingesters", len(zoneInstances))
+		return zoneInstances, nil
+	}
+
+	for n, inst := range zoneInstances {
+		// Check the ring again, because the other zones may have changed in the meanwhile.
+		if n > 0 {
+			status, err := d.readRing(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := d.checkRing(status); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := d.drainIngester(ctx, inst); err != nil {
+			return nil, errors.Wrapf(err, "failed to drain ingester %s", inst.ID)
+		}
+	}
+
+	return zoneInstances, nil
+}
+
+// checkRing returns the ingesters in the zone to drain, sorted by ID, or an error if it's not safe to drain the zone.
+func (d *zoneDrainer) checkRing(status ringStatus) ([]ringInstance, error) {
+	var zoneInstances []ringInstance
+	var notHealthy []string
+
+	// Prefer the time of the ring page, to not depend on the clock skew between the tool and Mimir.
+	now := status.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	instances := status.Instances
+	for _, inst := range instances {
+		if inst.Zone != d.cfg.zone {
+			if !inst.isHealthy(d.cfg.heartbeatTimeout, now) {
+				notHealthy = append(notHealthy, fmt.Sprintf("%s (zone: %s, state: %s, last heartbeat: %s)", inst.ID, inst.Zone, inst.State, inst.Timestamp.UTC().Format(time.RFC3339)))
+			}
+			continue
+		}
+
+		if inst.State != stateActive && inst.State != stateLeaving {
+			return nil, errors.Errorf("ingester %s in the zone to drain is in the %s state", inst.ID, inst.State)
+		}
+		zoneInstances = append(zoneInstances, inst)
+	}
+
+	if len(notHealthy) > 0 {
+		return nil, errors.Errorf("it's not safe to drain the zone %s because the following ingesters in other zones are not ACTIVE or healthy: %s", d.cfg.zone, strings.Join(notHealthy, ", "))
+	}
+	if len(zoneInstances) == 0 {
+		return nil, errors.Errorf("no ingesters found in the zone %s", d.cfg.zone)
+	}
+	if len(zoneInstances) == len(instances) {
+		return nil, errors.Errorf("all ingesters in the ring belong to the zone %s, draining it would make the ring unavailable", d.cfg.zone)
+	}
+
+	sort.Slice(zoneInstances, func(i, j int) bool {
+		return zoneInstances[i].ID < zoneInstances[j].ID
+	})
+	return zoneInstances, nil
+}
+
+// drainIngester triggers the downscale preparation of the ingester and waits until completed.
+func (d *zoneDrainer) drainIngester(ctx context.Context, inst ringInstance) error {
+	var baseURL bytes.Buffer
+	if err := d.urlTemplate.Execute(&baseURL, inst); err != nil {
+		return errors.Wrap(err, "failed to build the ingester URL")
+	}
+	url := strings.TrimSuffix(baseURL.String(), "/") + prepareDownscalePath
+
+	level.Info(d.logger).Log("msg", "Draining ingester.", "ingester", inst.ID, "url", url)
+	status, err := d.callIngester(ctx, http.MethodPost, url)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.ingesterTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(d.cfg.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		switch status.State {
+		case stateCompleted:
+			level.Info(d.logger).Log("msg", "Ingester drained.", "ingester", inst.ID)
+			return nil
+		case stateFailed:
+			return errors.Errorf("the ingester failed to prepare for downscale: %s", status.Error)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "timed out waiting for the ingester to be drained")
+		}
+
+		if status, err = d.callIngester(ctx, http.MethodGet, url); err != nil {
+			// The ingester may be temporarily unreachable, so we keep polling until the timeout.
+			level.Warn(d.logger).Log("msg", "Failed to get the ingester downscale status.", "ingester", inst.ID, "err", err)
+			status = downscaleStatus{}
+		}
+	}
+}
+
+func (d *zoneDrainer) readRing(ctx context.Context) (ringStatus, error) {
+	body, err := d.doRequest(ctx, http.MethodGet, d.cfg.ringURL)
+	if err != nil {
+		return ringStatus{}, errors.Wrap(err, "failed to read the ring")
+	}
+
+	var status ringStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return ringStatus{}, errors.Wrap(err, "failed to decode the ring status")
+	}
+	return status, nil
+}
+
+func (d *zoneDrainer) callIngester(ctx context.Context, method, url string) (downscaleStatus, error) {
+	body, err := d.doRequest(ctx, method, url)
+	if err != nil {
+		return downscaleStatus{}, err
+	}
+
+	var status downscaleStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return downscaleStatus{}, errors.Wrap(err, "failed to decode the ingester downscale status")
+	}
+	return status, nil
+}
+
+func (d *zoneDrainer) doRequest(ctx context.Context, method, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.Errorf("unexpected status code %d from %s %s: %s", resp.StatusCode, method, url, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func main() {
+	logger := log.WithPrefix(log.NewLogfmtLogger(os.Stderr), "time", log.DefaultTimestampUTC)
+
+	var cfg config
+	f := flag.NewFlagSet("ingester-zone-downscale", flag.ExitOnError)
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "This tool safely drains all the ingesters of a zone, one at a time, so that the zone can be scaled down.")
+		fmt.Fprintln(f.Output(), "Each ingester stops accepting new series and all its in-memory series are flushed and shipped to the storage.")
+		fmt.Fprintln(f.Output(), "Once the tool completes, the drained ingesters can be terminated.")
+		fmt.Fprintln(f.Output(), "")
+		fmt.Fprintln(f.Output(), "Usage:")
+		fmt.Fprintln(f.Output(), "        ingester-zone-downscale -ring-url <url> -zone <zone> [-ingester-url-template <template>] [-dry-run]")
+		fmt.Fprintln(f.Output(), "")
+		f.PrintDefaults()
+	}
+	cfg.registerFlags(f)
+
+	if err := f.Parse(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := cfg.validate(); err != nil {
+		level.Error(logger).Log("msg", "Invalid configuration.", "err", err)
+		os.Exit(1)
+	}
+
+	d, err := newZoneDrainer(cfg, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "Invalid configuration.", "err", err)
+		os.Exit(1)
+	}
+
+	drained, err := d.drainZone(context.Background())
+	if err != nil {
+		level.Error(logger).Log("msg", "Zone drain failed.", "zone", cfg.zone, "err", err)
+		os.Exit(1)
+	}
+
+	if !cfg.dryRun {
+		level.Info(logger).Log("msg", "Zone drained, the ingesters can now be terminated.", "zone", cfg.zone, "ingesters", len(drained))
+	}
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCluster serves the ring status page and the prepare downscale endpoint of all the ingesters.
+type fakeCluster struct {
+	mtx       sync.Mutex
+	now       time.Time
+	instances []ringInstance
+	polls     map[string]int
+	drained   []string
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if r.URL.Path == "/ingester/ring" {
+		_ = json.NewEncoder(w).Encode(ringStatus{Instances: c.instances, Now: c.now})
+		return
+	}
+
+	// Ingesters are addressed by ID in the path, eg. /ingester-zone-a-0/ingester/prepare_downscale.
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), prepareDownscalePath)
+	for n := range c.instances {
+		if c.instances[n].ID != id {
+			continue
+		}
+
+		if r.Method == http.MethodPost {
+			c.drained = append(c.drained, id)
+		}
+
+		// Complete the drain after a couple of polls.
+		state := "in_progress"
+		if c.polls[id]++; c.polls[id] > 2 {
+			state = stateCompleted
+		}
+		_ = json.NewEncoder(w).Encode(downscaleStatus{State: state})
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+func TestZoneDrainer_drainZone(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	tests := map[string]struct {
+		instances       []ringInstance
+		dryRun          bool
+		expectedDrained []string
+		expectedErr     string
+	}{
+		"should drain all ingesters of the zone in order": {
+			instances: []ringInstance{
+				{ID: "ingester-zone-a-1", State: stateActive, Timestamp: now, Zone: "zone-a"},
+				{ID: "ingester-zone-a-0", State: stateActive, Timestamp: now, Zone: "zone-a"},
+				{ID: "ingester-zone-b-0", State: stateActive, Timestamp: now, Zone: "zone-b"},
+				{ID: "ingester-zone-c-0", State: stateActive, Timestamp: now, Zone: "zone-c"},
+			},
+			expectedDrained: []string{"ingester-zone-a-0", "ingester-zone-a-1"},
+		},
+		"should not drain any ingester on dry-run": {
+			instances: []ringInstance{
+				{ID: "ingester-zone-a-0", State: stateActive, Timestamp: now, Zone: "zone-a"},
+				{ID: "ingester-zone-b-0", State: stateActive, Timestamp: now, Zone: "zone-b"},
+			},
+			dryRun: true,
+		},
+		"should fail if an ingester in another zone is not active": {
+			instances: []ringInstance{
+				{ID: "ingester-zone-a-0", State: stateActive, Timestamp: now, Zone: "zone-a"},
+				{ID: "ingester-zone-b-0", State: "UNHEALTHY", Timestamp: now, Zone: "zone-b"},
+			},
+			expectedErr: "it's not safe to drain the zone zone-a because the following ingesters in other zones are not ACTIVE or healthy: ingester-zone-b-0 (zone: zone-b, state: UNHEALTHY, last heartbeat: " + now.UTC().Format(time.RFC3339) + ")",
+		},
+		"should fail if an ingester in another zone is active but its heartbeat is too old": {
+			instances: []ringInstance{
+				{ID: "ingester-zone-a-0", State: stateActive, Timestamp: now, Zone: "zone-a"},
+				{ID: "ingester-zone-b-0", State: stateActive, Timestamp: now.Add(-2 * time.Minute), Zone: "zone-b"},
+			},
+			expectedErr: "it's not safe to drain the zone zone-a because the following ingesters in other zones are not ACTIVE or healthy: ingester-zone-b-0 (zone: zone-b, state: ACTIVE, last heartbeat: " + now.Add(-2*time.Minute).UTC().Format(time.RFC3339) + ")",
+		},
+		"should fail if an ingester in another zone is LEAVING": {
+			instances: []ringInstance{
+				{ID: "ingester-zone-a-0", State: stateActive, Timestamp: now, Zone: "zone-a"},
+				{ID: "ingester-zone-b-0", State: stateLeaving, Timestamp: now, Zone: "zone-b"},
+			},
+			expectedErr: "it's not safe to drain the zone zone-a because the following ingesters in other zones are not ACTIVE or healthy: ingester-zone-b-0 (zone: zone-b, state: LEAVING, last heartbeat: " + now.UTC().Format(time.RFC3339) + ")",
+		},
+		"should fail if an ingester in the zone to drain is unhealthy": {
+			instances: []ringInstance{
+				{ID: "ingester-zone-a-0", State: "UNHEALTHY", Timestamp: now, Zone: "zone-a"},
+				{ID: "ingester-zone-b-0", State: stateActive, Timestamp: now, Zone: "zone-b"},
+			},
+			expectedErr: "ingester ingester-zone-a-0 in the zone to drain is in the UNHEALTHY state",
+		},
+		"should fail if the zone is the only one in the ring": {
+			instances: []ringInstance{
+				{ID: "ingester-zone-a-0", State: stateActive, Timestamp: now, Zone: "zone-a"},
+			},
+			expectedErr: "all ingesters in the ring belong to the zone zone-a, draining it would make the ring unavailable",
+		},
+		"should fail if the zone has no ingesters": {
+			instances: []ringInstance{
+				{ID: "ingester-zone-b-0", State: stateActive, Timestamp: now, Zone: "zone-b"},
+			},
+			expectedErr: "no ingesters found in the zone zone-a",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cluster := &fakeCluster{now: now, instances: testData.instances, polls: map[string]int{}}
+			srv := httptest.NewServer(cluster)
+			defer srv.Close()
+
+			d, err := newZoneDrainer(config{
+				ringURL:             srv.URL + "/ingester/ring",
+				zone:                "zone-a",
+				ingesterURLTemplate: srv.URL + "/{{ .ID }}",
+				pollInterval:        time.Millisecond,
+				ingesterTimeout:     time.Second,
+				heartbeatTimeout:    time.Minute,
+				dryRun:              testData.dryRun,
+			}, log.NewNopLogger())
+			require.NoError(t, err)
+
+			_, err = d.drainZone(context.Background())
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedDrained, cluster.drained)
+		})
+	}
+}
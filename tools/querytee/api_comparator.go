@@ -0,0 +1,283 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// maxReportedDifferences is the max number of different items reported in a comparison error.
+const maxReportedDifferences = 10
+
+// APIResponse is a generic Prometheus API response.
+type APIResponse struct {
+	Status string
+	Data   json.RawMessage
+}
+
+// unmarshalAPIResponses unmarshals the expected and actual responses, and checks their status matches.
+func unmarshalAPIResponses(expectedResponse, actualResponse []byte) (expected, actual APIResponse, err error) {
+	if err = json.Unmarshal(expectedResponse, &expected); err != nil {
+		return expected, actual, errors.Wrap(err, "unable to unmarshal expected response")
+	}
+	if err = json.Unmarshal(actualResponse, &actual); err != nil {
+		return expected, actual, errors.Wrap(err, "unable to unmarshal actual response")
+	}
+	if expected.Status != actual.Status {
+		return expected, actual, fmt.Errorf("expected status %s but got %s", expected.Status, actual.Status)
+	}
+	return expected, actual, nil
+}
+
+// LabelsComparator compares the responses of the /api/v1/labels and /api/v1/label/{name}/values
+// routes. The order of the returned names or values is not compared.
+type LabelsComparator struct{}
+
+func NewLabelsComparator() *LabelsComparator {
+	return &LabelsComparator{}
+}
+
+func (c *LabelsComparator) Compare(expectedResponse, actualResponse []byte) error {
+	expected, actual, err := unmarshalAPIResponses(expectedResponse, actualResponse)
+	if err != nil {
+		return err
+	}
+
+	var expectedValues, actualValues []string
+	if err := unmarshalData(expected.Data, &expectedValues); err != nil {
+		return errors.Wrap(err, "unable to unmarshal expected labels")
+	}
+	if err := unmarshalData(actual.Data, &actualValues); err != nil {
+		return errors.Wrap(err, "unable to unmarshal actual labels")
+	}
+
+	return compareSets("values", expectedValues, actualValues)
+}
+
+// SeriesComparator compares the responses of the /api/v1/series route. The order of the returned series is not compared.
+type SeriesComparator struct{}
+
+func NewSeriesComparator() *SeriesComparator {
+	return &SeriesComparator{}
+}
+
+func (c *SeriesComparator) Compare(expectedResponse, actualResponse []byte) error {
+	expected, actual, err := unmarshalAPIResponses(expectedResponse, actualResponse)
+	if err != nil {
+		return err
+	}
+
+	var expectedSeries, actualSeries []model.LabelSet
+	if err := unmarshalData(expected.Data, &expectedSeries); err != nil {
+		return errors.Wrap(err, "unable to unmarshal expected series")
+	}
+	if err := unmarshalData(actual.Data, &actualSeries); err != nil {
+		return errors.Wrap(err, "unable to unmarshal actual series")
+	}
+
+	return compareSets("series", labelSetsToStrings(expectedSeries), labelSetsToStrings(actualSeries))
+}
+
+type metricMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+func (m metricMetadata) String() string {
+	return fmt.Sprintf("{type=%q, help=%q, unit=%q}", m.Type, m.Help, m.Unit)
+}
+
+// MetadataComparator compares the responses of the /api/v1/metadata route. The order of the metadata of each
+// metric is not compared.
+type MetadataComparator struct{}
+
+func NewMetadataComparator() *MetadataComparator {
+	return &MetadataComparator{}
+}
+
+func (c *MetadataComparator) Compare(expectedResponse, actualResponse []byte) error {
+	expected, actual, err := unmarshalAPIResponses(expectedResponse, actualResponse)
+	if err != nil {
+		return err
+	}
+
+	var expectedMetadata, actualMetadata map[string][]metricMetadata
+	if err := unmarshalData(expected.Data, &expectedMetadata); err != nil {
+		return errors.Wrap(err, "unable to unmarshal expected metadata")
+	}
+	if err := unmarshalData(actual.Data, &actualMetadata); err != nil {
+		return errors.Wrap(err, "unable to unmarshal actual metadata")
+	}
+
+	if err := compareSets("metrics", mapKeys(expectedMetadata), mapKeys(actualMetadata)); err != nil {
+		return err
+	}
+
+	for _, metric := range mapKeys(expectedMetadata) {
+		if err := compareSets("metadata", metadataToStrings(expectedMetadata[metric]), metadataToStrings(actualMetadata[metric])); err != nil {
+			return errors.Wrapf(err, "metadata not matching for metric %s", metric)
+		}
+	}
+	return nil
+}
+
+type exemplarsResult struct {
+	SeriesLabels model.LabelSet `json:"seriesLabels"`
+	Exemplars    []struct {
+		Labels    model.LabelSet    `json:"labels"`
+		Value     model.SampleValue `json:"value"`
+		Timestamp model.Time        `json:"timestamp"`
+	} `json:"exemplars"`
+}
+
+// ExemplarsComparator compares the responses of the /api/v1/query_exemplars route. Exemplar values are
+// compared with the same tolerance as samples.
+type ExemplarsComparator struct {
+	opts SampleComparisonOptions
+}
+
+func NewExemplarsComparator(opts SampleComparisonOptions) *ExemplarsComparator {
+	return &ExemplarsComparator{opts: opts}
+}
+
+func (c *ExemplarsComparator) Compare(expectedResponse, actualResponse []byte) error {
+	expected, actual, err := unmarshalAPIResponses(expectedResponse, actualResponse)
+	if err != nil {
+		return err
+	}
+
+	var expectedResults, actualResults []exemplarsResult
+	if err := unmarshalData(expected.Data, &expectedResults); err != nil {
+		return errors.Wrap(err, "unable to unmarshal expected exemplars")
+	}
+	if err := unmarshalData(actual.Data, &actualResults); err != nil {
+		return errors.Wrap(err, "unable to unmarshal actual exemplars")
+	}
+
+	expectedSeries := make([]model.LabelSet, 0, len(expectedResults))
+	for _, r := range expectedResults {
+		expectedSeries = append(expectedSeries, r.SeriesLabels)
+	}
+	actualSeries := make([]model.LabelSet, 0, len(actualResults))
+	actualByFingerprint := make(map[model.Fingerprint]exemplarsResult, len(actualResults))
+	for _, r := range actualResults {
+		actualSeries = append(actualSeries, r.SeriesLabels)
+		actualByFingerprint[r.SeriesLabels.Fingerprint()] = r
+	}
+
+	if err := compareSets("series", labelSetsToStrings(expectedSeries), labelSetsToStrings(actualSeries)); err != nil {
+		return err
+	}
+
+	for _, e := range expectedResults {
+		a := actualByFingerprint[e.SeriesLabels.Fingerprint()]
+		if len(e.Exemplars) != len(a.Exemplars) {
+			return fmt.Errorf("expected %d exemplars for series %s but got %d", len(e.Exemplars), e.SeriesLabels, len(a.Exemplars))
+		}
+
+		for i, expectedExemplar := range e.Exemplars {
+			actualExemplar := a.Exemplars[i]
+			if !expectedExemplar.Labels.Equal(actualExemplar.Labels) {
+				return fmt.Errorf("expected exemplar labels %s for series %s but got %s", expectedExemplar.Labels, e.SeriesLabels, actualExemplar.Labels)
+			}
+
+			err := compareSamplePair(model.SamplePair{
+				Timestamp: expectedExemplar.Timestamp,
+				Value:     expectedExemplar.Value,
+			}, model.SamplePair{
+				Timestamp: actualExemplar.Timestamp,
+				Value:     actualExemplar.Value,
+			}, c.opts)
+			if err != nil {
+				return errors.Wrapf(err, "exemplar not matching for series %s", e.SeriesLabels)
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalData unmarshals the data of an API response. A missing or null data is left empty.
+func unmarshalData(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// compareSets compares the expected and actual items regardless of their order, and returns
+// an error listing the missing and extra items if they don't match.
+func compareSets(kind string, expected, actual []string) error {
+	expectedSet := make(map[string]int, len(expected))
+	for _, v := range expected {
+		expectedSet[v]++
+	}
+	actualSet := make(map[string]int, len(actual))
+	for _, v := range actual {
+		actualSet[v]++
+	}
+
+	var missing, extra []string
+	for v, count := range expectedSet {
+		if actualSet[v] < count {
+			missing = append(missing, v)
+		}
+	}
+	for v, count := range actualSet {
+		if expectedSet[v] < count {
+			extra = append(extra, v)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("expected %d %s but got %d", len(expected), kind, len(actual))
+	if len(missing) > 0 {
+		msg += fmt.Sprintf(", missing from actual response: %s", formatDifferences(missing))
+	}
+	if len(extra) > 0 {
+		msg += fmt.Sprintf(", extra in actual response: %s", formatDifferences(extra))
+	}
+	return errors.New(msg)
+}
+
+func formatDifferences(items []string) string {
+	sort.Strings(items)
+
+	if len(items) > maxReportedDifferences {
+		return fmt.Sprintf("[%s ...and %d more]", strings.Join(items[:maxReportedDifferences], " "), len(items)-maxReportedDifferences)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(items, " "))
+}
+
+func labelSetsToStrings(sets []model.LabelSet) []string {
+	out := make([]string, 0, len(sets))
+	for _, s := range sets {
+		out = append(out, s.String())
+	}
+	return out
+}
+
+func metadataToStrings(metadata []metricMetadata) []string {
+	out := make([]string, 0, len(metadata))
+	for _, m := range metadata {
+		out = append(out, m.String())
+	}
+	return out
+}
+
+func mapKeys(m map[string][]metricMetadata) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
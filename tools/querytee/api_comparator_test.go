@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelsComparator(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expected string
+		actual   string
+		err      string
+	}{
+		"same values": {
+			expected: `{"status":"success","data":["a","b","c"]}`,
+			actual:   `{"status":"success","data":["a","b","c"]}`,
+		},
+		"same values in a different order": {
+			expected: `{"status":"success","data":["a","b","c"]}`,
+			actual:   `{"status":"success","data":["c","a","b"]}`,
+		},
+		"no values": {
+			expected: `{"status":"success","data":[]}`,
+			actual:   `{"status":"success"}`,
+		},
+		"missing and extra values": {
+			expected: `{"status":"success","data":["a","b","c"]}`,
+			actual:   `{"status":"success","data":["a","d"]}`,
+			err:      "expected 3 values but got 2, missing from actual response: [b c], extra in actual response: [d]",
+		},
+		"different status": {
+			expected: `{"status":"success","data":["a"]}`,
+			actual:   `{"status":"error","error":"failed"}`,
+			err:      "expected status success but got error",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := NewLabelsComparator().Compare([]byte(tc.expected), []byte(tc.actual))
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestSeriesComparator(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expected string
+		actual   string
+		err      string
+	}{
+		"same series in a different order": {
+			expected: `{"status":"success","data":[{"__name__":"up","job":"a"},{"__name__":"up","job":"b"}]}`,
+			actual:   `{"status":"success","data":[{"job":"b","__name__":"up"},{"__name__":"up","job":"a"}]}`,
+		},
+		"missing series": {
+			expected: `{"status":"success","data":[{"__name__":"up","job":"a"},{"__name__":"up","job":"b"}]}`,
+			actual:   `{"status":"success","data":[{"__name__":"up","job":"a"}]}`,
+			err:      `expected 2 series but got 1, missing from actual response: [{__name__="up", job="b"}]`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := NewSeriesComparator().Compare([]byte(tc.expected), []byte(tc.actual))
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestMetadataComparator(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expected string
+		actual   string
+		err      string
+	}{
+		"same metadata in a different order": {
+			expected: `{"status":"success","data":{"up":[{"type":"gauge","help":"a","unit":""},{"type":"gauge","help":"b","unit":""}]}}`,
+			actual:   `{"status":"success","data":{"up":[{"type":"gauge","help":"b","unit":""},{"type":"gauge","help":"a","unit":""}]}}`,
+		},
+		"missing metric": {
+			expected: `{"status":"success","data":{"up":[{"type":"gauge","help":"a","unit":""}],"down":[{"type":"gauge","help":"a","unit":""}]}}`,
+			actual:   `{"status":"success","data":{"up":[{"type":"gauge","help":"a","unit":""}]}}`,
+			err:      "expected 2 metrics but got 1, missing from actual response: [down]",
+		},
+		"different metadata": {
+			expected: `{"status":"success","data":{"up":[{"type":"gauge","help":"a","unit":""}]}}`,
+			actual:   `{"status":"success","data":{"up":[{"type":"counter","help":"a","unit":""}]}}`,
+			err:      `metadata not matching for metric up: expected 1 metadata but got 1, missing from actual response: [{type="gauge", help="a", unit=""}], extra in actual response: [{type="counter", help="a", unit=""}]`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := NewMetadataComparator().Compare([]byte(tc.expected), []byte(tc.actual))
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestExemplarsComparator(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expected string
+		actual   string
+		err      string
+	}{
+		"same exemplars": {
+			expected: `{"status":"success","data":[{"seriesLabels":{"__name__":"foo"},"exemplars":[{"labels":{"traceID":"1"},"value":"1","timestamp":1}]}]}`,
+			actual:   `{"status":"success","data":[{"seriesLabels":{"__name__":"foo"},"exemplars":[{"labels":{"traceID":"1"},"value":"1.0000000001","timestamp":1}]}]}`,
+		},
+		"missing series": {
+			expected: `{"status":"success","data":[{"seriesLabels":{"__name__":"foo"},"exemplars":[{"labels":{"traceID":"1"},"value":"1","timestamp":1}]}]}`,
+			actual:   `{"status":"success","data":[]}`,
+			err:      `expected 1 series but got 0, missing from actual response: [{__name__="foo"}]`,
+		},
+		"different exemplar labels": {
+			expected: `{"status":"success","data":[{"seriesLabels":{"__name__":"foo"},"exemplars":[{"labels":{"traceID":"1"},"value":"1","timestamp":1}]}]}`,
+			actual:   `{"status":"success","data":[{"seriesLabels":{"__name__":"foo"},"exemplars":[{"labels":{"traceID":"2"},"value":"1","timestamp":1}]}]}`,
+			err:      `expected exemplar labels {traceID="1"} for series {__name__="foo"} but got {traceID="2"}`,
+		},
+		"different exemplar value": {
+			expected: `{"status":"success","data":[{"seriesLabels":{"__name__":"foo"},"exemplars":[{"labels":{"traceID":"1"},"value":"1","timestamp":1}]}]}`,
+			actual:   `{"status":"success","data":[{"seriesLabels":{"__name__":"foo"},"exemplars":[{"labels":{"traceID":"1"},"value":"2","timestamp":1}]}]}`,
+			err:      `exemplar not matching for series {__name__="foo"}: expected value 1 for timestamp 1 but got 2`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := NewExemplarsComparator(SampleComparisonOptions{Tolerance: 0.000001}).Compare([]byte(tc.expected), []byte(tc.actual))
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
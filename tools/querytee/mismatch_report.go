@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	mismatchFileExtension = ".json"
+
+	// maxDiffBodySize is the max size of the responses for which a line diff is computed.
+	maxDiffBodySize = 1024 * 1024
+)
+
+type MismatchReportConfig struct {
+	Dir        string
+	MaxEntries int
+}
+
+func (cfg *MismatchReportConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Dir, "proxy.mismatch-report-dir", "", "Directory where the requests whose responses don't match are stored, along with both responses and their diff. The stored mismatches can be browsed at /mismatches. Empty to disable the mismatch report.")
+	f.IntVar(&cfg.MaxEntries, "proxy.mismatch-report-max-entries", 1000, "The max number of mismatches stored in the mismatch report. When the limit is reached, the oldest mismatches are deleted.")
+}
+
+// MismatchResponse is the response of a backend stored in the mismatch report.
+type MismatchResponse struct {
+	Backend string `json:"backend"`
+	Status  int    `json:"status"`
+	Body    string `json:"body"`
+}
+
+// Mismatch is a request whose responses didn't match.
+type Mismatch struct {
+	ID        string           `json:"id"`
+	Timestamp time.Time        `json:"timestamp"`
+	RouteName string           `json:"route_name"`
+	Method    string           `json:"method"`
+	Path      string           `json:"path"`
+	Query     string           `json:"query"`
+	Error     string           `json:"error"`
+	Expected  MismatchResponse `json:"expected"`
+	Actual    MismatchResponse `json:"actual"`
+	Diff      string           `json:"diff,omitempty"`
+}
+
+// MismatchReport stores the mismatches on disk, keeping up to a max number of them.
+// Each mismatch is stored in its own file, named after the mismatch ID.
+type MismatchReport struct {
+	cfg    MismatchReportConfig
+	logger log.Logger
+
+	mtx    sync.Mutex
+	ids    []string // Sorted from the oldest to the newest.
+	lastID string
+}
+
+func NewMismatchReport(cfg MismatchReportConfig, logger log.Logger) (*MismatchReport, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create the mismatch report directory")
+	}
+
+	files, err := ioutil.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the mismatch report directory")
+	}
+
+	r := &MismatchReport{cfg: cfg, logger: logger}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != mismatchFileExtension {
+			continue
+		}
+		r.ids = append(r.ids, strings.TrimSuffix(f.Name(), mismatchFileExtension))
+	}
+	sort.Strings(r.ids)
+	if len(r.ids) > 0 {
+		r.lastID = r.ids[len(r.ids)-1]
+	}
+	r.deleteOldest()
+
+	return r, nil
+}
+
+// Add stores the mismatch, computing its ID and diff.
+func (r *MismatchReport) Add(m Mismatch) {
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+	m.Diff = diffResponses(m.Expected.Body, m.Actual.Body)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// The ID is the zero-padded timestamp, so that IDs are sorted chronologically.
+	ts := m.Timestamp.UnixNano()
+	m.ID = fmt.Sprintf("%020d", ts)
+	for m.ID <= r.lastID {
+		ts++
+		m.ID = fmt.Sprintf("%020d", ts)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		level.Warn(r.logger).Log("msg", "Unable to marshal the mismatch", "err", err)
+		return
+	}
+	if err := ioutil.WriteFile(r.path(m.ID), data, 0o644); err != nil {
+		level.Warn(r.logger).Log("msg", "Unable to store the mismatch", "err", err)
+		return
+	}
+
+	r.ids = append(r.ids, m.ID)
+	r.lastID = m.ID
+	r.deleteOldest()
+}
+
+// Get returns the mismatch with the given ID.
+func (r *MismatchReport) Get(id string) (Mismatch, error) {
+	var m Mismatch
+
+	r.mtx.Lock()
+	idx := sort.SearchStrings(r.ids, id)
+	found := idx < len(r.ids) && r.ids[idx] == id
+	r.mtx.Unlock()
+
+	if !found {
+		return m, os.ErrNotExist
+	}
+
+	data, err := ioutil.ReadFile(r.path(id))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// List returns the stored mismatches, from the newest to the oldest. Responses bodies and diff are not returned.
+func (r *MismatchReport) List() []Mismatch {
+	r.mtx.Lock()
+	ids := append([]string(nil), r.ids...)
+	r.mtx.Unlock()
+
+	out := make([]Mismatch, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		m, err := r.Get(ids[i])
+		if err != nil {
+			// The mismatch may have been deleted in the meanwhile.
+			continue
+		}
+
+		m.Expected.Body, m.Actual.Body, m.Diff = "", "", ""
+		out = append(out, m)
+	}
+	return out
+}
+
+// deleteOldest deletes the oldest mismatches exceeding the max number of entries. Must be called with the lock held.
+func (r *MismatchReport) deleteOldest() {
+	for r.cfg.MaxEntries > 0 && len(r.ids) > r.cfg.MaxEntries {
+		if err := os.Remove(r.path(r.ids[0])); err != nil && !os.IsNotExist(err) {
+			level.Warn(r.logger).Log("msg", "Unable to delete the mismatch", "id", r.ids[0], "err", err)
+		}
+		r.ids = r.ids[1:]
+	}
+}
+
+func (r *MismatchReport) path(id string) string {
+	return filepath.Join(r.cfg.Dir, id+mismatchFileExtension)
+}
+
+// RegisterRoutes registers the HTTP pages to browse the mismatch report.
+func (r *MismatchReport) RegisterRoutes(router *mux.Router) {
+	router.Path("/mismatches").Methods("GET").HandlerFunc(r.listHandler)
+	router.Path("/mismatches/{id}").Methods("GET").HandlerFunc(r.getHandler)
+}
+
+func (r *MismatchReport) listHandler(w http.ResponseWriter, _ *http.Request) {
+	r.render(w, mismatchListTemplate, r.List())
+}
+
+func (r *MismatchReport) getHandler(w http.ResponseWriter, req *http.Request) {
+	m, err := r.Get(mux.Vars(req)["id"])
+	if os.IsNotExist(err) {
+		http.Error(w, "mismatch not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m); err != nil {
+			level.Warn(r.logger).Log("msg", "Unable to write response", "err", err)
+		}
+		return
+	}
+	r.render(w, mismatchTemplate, m)
+}
+
+func (r *MismatchReport) render(w http.ResponseWriter, t *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		level.Warn(r.logger).Log("msg", "Unable to render the mismatch report", "err", err)
+	}
+}
+
+// diffResponses returns a line diff of the responses, after indenting them if they're JSON.
+func diffResponses(expected, actual string) string {
+	if len(expected) > maxDiffBodySize || len(actual) > maxDiffBodySize {
+		return ""
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(indentJSON(expected)),
+		B:        difflib.SplitLines(indentJSON(actual)),
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  3,
+	})
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+func indentJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+var mismatchListTemplate = template.Must(template.New("mismatches").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Query-tee mismatches</title>
+	</head>
+	<body>
+		<h1>Query-tee mismatches</h1>
+		{{ if not . }}
+		<p>No mismatches.</p>
+		{{ else }}
+		<table border="1" cellpadding="4" cellspacing="0">
+			<thead>
+				<tr>
+					<th>Time</th>
+					<th>Route</th>
+					<th>Request</th>
+					<th>Status</th>
+					<th>Error</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range . }}
+				<tr>
+					<td><a href="mismatches/{{ .ID }}">{{ .Timestamp.Format "2006-01-02 15:04:05.000 MST" }}</a></td>
+					<td>{{ .RouteName }}</td>
+					<td><code>{{ .Method }} {{ .Path }}?{{ .Query }}</code></td>
+					<td>{{ .Expected.Status }} / {{ .Actual.Status }}</td>
+					<td>{{ .Error }}</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+		{{ end }}
+	</body>
+</html>`))
+
+var mismatchTemplate = template.Must(template.New("mismatch").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Query-tee mismatch {{ .ID }}</title>
+	</head>
+	<body>
+		<h1>Query-tee mismatch</h1>
+		<p><a href="../mismatches">Back to the list</a> | <a href="?format=json">JSON</a></p>
+		<table border="1" cellpadding="4" cellspacing="0">
+			<tr><th>Time</th><td>{{ .Timestamp.Format "2006-01-02 15:04:05.000 MST" }}</td></tr>
+			<tr><th>Route</th><td>{{ .RouteName }}</td></tr>
+			<tr><th>Request</th><td><code>{{ .Method }} {{ .Path }}?{{ .Query }}</code></td></tr>
+			<tr><th>Error</th><td>{{ .Error }}</td></tr>
+		</table>
+
+		<h2>Diff</h2>
+		{{ if .Diff }}<pre>{{ .Diff }}</pre>{{ else }}<p>No diff available.</p>{{ end }}
+
+		<h2>Expected response ({{ .Expected.Backend }}, status {{ .Expected.Status }})</h2>
+		<pre>{{ .Expected.Body }}</pre>
+
+		<h2>Actual response ({{ .Actual.Backend }}, status {{ .Actual.Status }})</h2>
+		<pre>{{ .Actual.Body }}</pre>
+	</body>
+</html>`))
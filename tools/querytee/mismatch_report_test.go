@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMismatchReport(t *testing.T) {
+	cfg := MismatchReportConfig{Dir: t.TempDir(), MaxEntries: 2}
+	r, err := NewMismatchReport(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		r.Add(Mismatch{
+			Timestamp: now,
+			RouteName: "api_v1_labels",
+			Method:    "GET",
+			Path:      "/api/v1/labels",
+			Error:     "expected 1 values but got 0",
+			Expected:  MismatchResponse{Backend: "a", Status: 200, Body: `{"status":"success","data":["a"]}`},
+			Actual:    MismatchResponse{Backend: "b", Status: 200, Body: `{"status":"success","data":[]}`},
+		})
+	}
+
+	// Only the newest mismatches are kept, sorted from the newest.
+	list := r.List()
+	require.Len(t, list, 2)
+	assert.Greater(t, list[0].ID, list[1].ID)
+	assert.Empty(t, list[0].Expected.Body)
+
+	m, err := r.Get(list[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "api_v1_labels", m.RouteName)
+	assert.Equal(t, `{"status":"success","data":["a"]}`, m.Expected.Body)
+	assert.Contains(t, m.Diff, "-    \"a\"")
+
+	// The mismatches are reloaded from disk.
+	r, err = NewMismatchReport(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, list, r.List())
+}
+
+func TestMismatchReport_HTTP(t *testing.T) {
+	r, err := NewMismatchReport(MismatchReportConfig{Dir: t.TempDir(), MaxEntries: 10}, log.NewNopLogger())
+	require.NoError(t, err)
+	r.Add(Mismatch{
+		RouteName: "api_v1_series",
+		Method:    "GET",
+		Path:      "/api/v1/series",
+		Query:     "match[]=up",
+		Error:     "<mismatch>",
+	})
+
+	router := mux.NewRouter()
+	r.RegisterRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/mismatches", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "api_v1_series")
+	assert.Contains(t, rec.Body.String(), "&lt;mismatch&gt;")
+
+	id := r.List()[0].ID
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/mismatches/"+id, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "match[]=up")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/mismatches/"+id+"?format=json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/mismatches/unknown", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
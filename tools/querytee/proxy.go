@@ -37,6 +37,8 @@ type ProxyConfig struct {
 	UseRelativeError               bool
 	PassThroughNonRegisteredRoutes bool
 	SkipRecentSamples              time.Duration
+	Write                          WriteConfig
+	MismatchReport                 MismatchReportConfig
 }
 
 func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
@@ -49,6 +51,8 @@ func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.UseRelativeError, "proxy.compare-use-relative-error", false, "Use relative error tolerance when comparing floating point values.")
 	f.DurationVar(&cfg.SkipRecentSamples, "proxy.compare-skip-recent-samples", 60*time.Second, "The window from now to skip comparing samples. 0 to disable.")
 	f.BoolVar(&cfg.PassThroughNonRegisteredRoutes, "proxy.passthrough-non-registered-routes", false, "Passthrough requests for non-registered routes to preferred backend.")
+	cfg.Write.RegisterFlags(f)
+	cfg.MismatchReport.RegisterFlags(f)
 }
 
 type Route struct {
@@ -65,6 +69,12 @@ type Proxy struct {
 	metrics  *ProxyMetrics
 	routes   []Route
 
+	// Sends the remote write requests to all backends, if enabled.
+	writeEndpoint *WriteEndpoint
+
+	// Stores the responses not matching, if enabled.
+	mismatches *MismatchReport
+
 	// The HTTP server used to run the proxy service.
 	srv         *http.Server
 	srvListener net.Listener
@@ -147,6 +157,17 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, routes []Route, registerer pro
 		level.Warn(p.logger).Log("msg", "The proxy is running with only 1 backend. At least 2 backends are required to fulfil the purpose of the proxy and compare results.")
 	}
 
+	if cfg.CompareResponses && cfg.MismatchReport.Dir != "" {
+		var err error
+		if p.mismatches, err = NewMismatchReport(cfg.MismatchReport, p.logger); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Write.Enabled {
+		p.writeEndpoint = NewWriteEndpoint(cfg.Write, p.backends, p.metrics, p.logger)
+	}
+
 	return p, nil
 }
 
@@ -170,7 +191,15 @@ func (p *Proxy) Start() error {
 		if p.cfg.CompareResponses {
 			comparator = route.ResponseComparator
 		}
-		router.Path(route.Path).Methods(route.Methods...).Handler(NewProxyEndpoint(p.backends, route.RouteName, p.metrics, p.logger, comparator))
+		router.Path(route.Path).Methods(route.Methods...).Handler(NewProxyEndpoint(p.backends, route.RouteName, p.metrics, p.logger, comparator, p.mismatches))
+	}
+
+	if p.mismatches != nil {
+		p.mismatches.RegisterRoutes(router)
+	}
+
+	if p.writeEndpoint != nil {
+		router.Path("/api/v1/push").Methods("POST").Handler(p.writeEndpoint)
 	}
 
 	if p.cfg.PassThroughNonRegisteredRoutes {
@@ -208,7 +237,11 @@ func (p *Proxy) Stop() error {
 		return nil
 	}
 
-	return p.srv.Shutdown(context.Background())
+	err := p.srv.Shutdown(context.Background())
+	if p.writeEndpoint != nil {
+		p.writeEndpoint.Stop()
+	}
+	return err
 }
 
 func (p *Proxy) Await() {
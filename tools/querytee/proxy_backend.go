@@ -57,12 +57,16 @@ func NewProxyBackend(name string, endpoint *url.URL, timeout time.Duration, pref
 }
 
 func (b *ProxyBackend) ForwardRequest(orig *http.Request, body io.ReadCloser) (int, []byte, error) {
+	return b.forwardRequestWithTimeout(orig, body, b.timeout)
+}
+
+func (b *ProxyBackend) forwardRequestWithTimeout(orig *http.Request, body io.ReadCloser, timeout time.Duration) (int, []byte, error) {
 	req, err := b.createBackendRequest(orig, body)
 	if err != nil {
 		return 0, nil, err
 	}
 
-	return b.doBackendRequest(req)
+	return b.doBackendRequest(req, timeout)
 }
 
 func (b *ProxyBackend) createBackendRequest(orig *http.Request, body io.ReadCloser) (*http.Request, error) {
@@ -103,9 +107,9 @@ func (b *ProxyBackend) createBackendRequest(orig *http.Request, body io.ReadClos
 	return req, nil
 }
 
-func (b *ProxyBackend) doBackendRequest(req *http.Request) (int, []byte, error) {
-	// Honor the read timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+func (b *ProxyBackend) doBackendRequest(req *http.Request, timeout time.Duration) (int, []byte, error) {
+	// Honor the timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	// Execute the request.
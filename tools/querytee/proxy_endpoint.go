@@ -31,6 +31,9 @@ type ProxyEndpoint struct {
 	logger     log.Logger
 	comparator ResponsesComparator
 
+	// Where to store the responses not matching, if enabled.
+	mismatches *MismatchReport
+
 	// Whether for this endpoint there's a preferred backend configured.
 	hasPreferredBackend bool
 
@@ -38,7 +41,7 @@ type ProxyEndpoint struct {
 	routeName string
 }
 
-func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *ProxyMetrics, logger log.Logger, comparator ResponsesComparator) *ProxyEndpoint {
+func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *ProxyMetrics, logger log.Logger, comparator ResponsesComparator, mismatches *MismatchReport) *ProxyEndpoint {
 	hasPreferredBackend := false
 	for _, backend := range backends {
 		if backend.preferred {
@@ -53,6 +56,7 @@ func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *Proxy
 		metrics:             metrics,
 		logger:              logger,
 		comparator:          comparator,
+		mismatches:          mismatches,
 		hasPreferredBackend: hasPreferredBackend,
 	}
 }
@@ -168,6 +172,18 @@ func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, resCh chan *back
 			level.Error(util_log.Logger).Log("msg", "response comparison failed", "route-name", p.routeName,
 				"query", r.URL.RawQuery, "err", err)
 			result = comparisonFailed
+
+			if p.mismatches != nil {
+				p.mismatches.Add(Mismatch{
+					RouteName: p.routeName,
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					Query:     query,
+					Error:     err.Error(),
+					Expected:  mismatchResponse(expectedResponse),
+					Actual:    mismatchResponse(actualResponse),
+				})
+			}
 		}
 
 		p.metrics.responsesComparedTotal.WithLabelValues(p.routeName, result).Inc()
@@ -226,6 +242,14 @@ func (p *ProxyEndpoint) compareResponses(expectedResponse, actualResponse *backe
 	return p.comparator.Compare(expectedResponse.body, actualResponse.body)
 }
 
+func mismatchResponse(res *backendResponse) MismatchResponse {
+	out := MismatchResponse{Backend: res.backend.name, Status: res.status, Body: string(res.body)}
+	if res.err != nil {
+		out.Body = res.err.Error()
+	}
+	return out
+}
+
 type backendResponse struct {
 	backend *ProxyBackend
 	status  int
@@ -19,6 +19,11 @@ type ProxyMetrics struct {
 	requestDuration        *prometheus.HistogramVec
 	responsesTotal         *prometheus.CounterVec
 	responsesComparedTotal *prometheus.CounterVec
+
+	writeRequestsTotal         *prometheus.CounterVec
+	writeRejectedRequestsTotal *prometheus.CounterVec
+	writeQueueLength           *prometheus.GaugeVec
+	writeRetriesTotal          *prometheus.CounterVec
 }
 
 func NewProxyMetrics(registerer prometheus.Registerer) *ProxyMetrics {
@@ -39,6 +44,26 @@ func NewProxyMetrics(registerer prometheus.Registerer) *ProxyMetrics {
 			Name:      "responses_compared_total",
 			Help:      "Total number of responses compared per route name by result.",
 		}, []string{"route", "result"}),
+		writeRequestsTotal: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex_querytee",
+			Name:      "write_requests_total",
+			Help:      "Total number of write requests sent to each backend by result.",
+		}, []string{"backend", "result"}),
+		writeRejectedRequestsTotal: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex_querytee",
+			Name:      "write_rejected_requests_total",
+			Help:      "Total number of write requests rejected by each backend by reason.",
+		}, []string{"backend", "reason"}),
+		writeQueueLength: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "cortex_querytee",
+			Name:      "write_queue_length",
+			Help:      "Number of write requests queued for each non-preferred backend.",
+		}, []string{"backend"}),
+		writeRetriesTotal: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex_querytee",
+			Name:      "write_retries_total",
+			Help:      "Total number of write requests retried to each non-preferred backend.",
+		}, []string{"backend"}),
 	}
 
 	return m
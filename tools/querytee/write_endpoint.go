@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+)
+
+const (
+	writeRouteName = "api_v1_push"
+
+	writeResultSuccess  = "success"
+	writeResultRejected = "rejected"
+	writeResultFailed   = "failed"
+	writeResultDropped  = "dropped"
+
+	rejectReasonOther = "other"
+)
+
+// rejectReasons maps the messages of the errors returned by Mimir when rejecting
+// a write request to the reason tracked in metrics. The first matching message wins.
+var rejectReasons = []struct {
+	message string
+	reason  string
+}{
+	{message: "ingestion rate limit", reason: "rate_limited"},
+	{message: "per-user series limit", reason: "per_user_series_limit"},
+	{message: "per-metric series limit", reason: "per_metric_series_limit"},
+	{message: "per-label-value series limit", reason: "per_label_value_series_limit"},
+	{message: "per-user metric metadata limit", reason: "per_user_metadata_limit"},
+	{message: "per-metric metadata limit", reason: "per_metric_metadata_limit"},
+	{message: "out of order sample", reason: "sample_out_of_order"},
+	{message: "duplicate sample for timestamp", reason: "new_value_for_timestamp"},
+	{message: "out of bounds", reason: "sample_out_of_bounds"},
+	{message: "timestamp too new", reason: "too_far_in_future"},
+	{message: "replicas did not mach", reason: "replicas_not_match"},
+	{message: "too many HA clusters", reason: "too_many_ha_clusters"},
+	{message: "series has too many labels", reason: "max_label_names_per_series"},
+	{message: "label name too long", reason: "label_name_too_long"},
+	{message: "label value too long", reason: "label_value_too_long"},
+	{message: "sample invalid label", reason: "label_invalid"},
+	{message: "duplicate label name", reason: "duplicate_label_names"},
+	{message: "labels not sorted", reason: "labels_not_sorted"},
+	{message: "sample missing metric name", reason: "missing_metric_name"},
+	{message: "sample invalid metric name", reason: "metric_name_invalid"},
+	{message: "exemplar", reason: "exemplar_invalid"},
+	{message: "metadata", reason: "metadata_invalid"},
+}
+
+type WriteConfig struct {
+	Enabled      bool
+	Timeout      time.Duration
+	QueueSize    int
+	QueueWorkers int
+	Backoff      backoff.Config
+}
+
+func (cfg *WriteConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "proxy.write-tee-enabled", false, "Accept remote write requests on /api/v1/push and send them to all backends. The response of the preferred backend is sent back to the client, while requests to the other backends are queued and retried independently.")
+	f.DurationVar(&cfg.Timeout, "backend.write-timeout", 30*time.Second, "The timeout when sending a write request to a backend.")
+	f.IntVar(&cfg.QueueSize, "proxy.write-queue-size", 1000, "The max number of write requests queued for each non-preferred backend. When the queue is full, new write requests to that backend are dropped.")
+	f.IntVar(&cfg.QueueWorkers, "proxy.write-queue-workers", 10, "The number of workers sending the queued write requests to each non-preferred backend.")
+	cfg.Backoff.RegisterFlagsWithPrefix("proxy.write", f)
+}
+
+// WriteEndpoint sends the remote write requests to all backends. The request is sent to the preferred
+// backend synchronously and its response is sent back to the client, while the requests to the other
+// backends are queued, each backend having its own queue, and retried on failure.
+type WriteEndpoint struct {
+	cfg       WriteConfig
+	preferred *ProxyBackend
+	queues    []*writeQueue
+	metrics   *ProxyMetrics
+	logger    log.Logger
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+func NewWriteEndpoint(cfg WriteConfig, backends []*ProxyBackend, metrics *ProxyMetrics, logger log.Logger) *WriteEndpoint {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &WriteEndpoint{
+		cfg:     cfg,
+		metrics: metrics,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	// If no preferred backend is configured, the first one is used.
+	e.preferred = backends[0]
+	for _, b := range backends {
+		if b.preferred {
+			e.preferred = b
+			break
+		}
+	}
+
+	for _, b := range backends {
+		if b == e.preferred {
+			continue
+		}
+
+		q := &writeQueue{
+			endpoint: e,
+			backend:  b,
+			requests: make(chan *writeRequest, cfg.QueueSize),
+		}
+		e.queues = append(e.queues, q)
+
+		e.workers.Add(cfg.QueueWorkers)
+		for i := 0; i < cfg.QueueWorkers; i++ {
+			go q.run()
+		}
+	}
+	return e
+}
+
+// Stop stops sending the queued requests to the backends. Requests still in the queues are dropped.
+func (e *WriteEndpoint) Stop() {
+	e.cancel()
+	e.workers.Wait()
+}
+
+func (e *WriteEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Warn(e.logger).Log("msg", "Unable to read request body", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Queue the request for the non-preferred backends first, so that they're sent concurrently.
+	req := &writeRequest{orig: r.Clone(context.Background()), body: body}
+	for _, q := range e.queues {
+		q.enqueue(req)
+	}
+
+	status, resBody, err := e.send(e.preferred, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(status)
+		if _, err := w.Write(resBody); err != nil {
+			level.Warn(e.logger).Log("msg", "Unable to write response", "err", err)
+		}
+	}
+
+	e.metrics.responsesTotal.WithLabelValues(e.preferred.name, r.Method, writeRouteName).Inc()
+	e.recordResult(e.preferred, status, resBody, err)
+}
+
+// send sends a write request to a backend, without retrying.
+func (e *WriteEndpoint) send(b *ProxyBackend, req *writeRequest) (int, []byte, error) {
+	start := time.Now()
+	status, body, err := b.forwardRequestWithTimeout(req.orig, ioutil.NopCloser(bytes.NewReader(req.body)), e.cfg.Timeout)
+	elapsed := time.Since(start)
+
+	res := &backendResponse{backend: b, status: status, body: body, err: err}
+	lvl := level.Debug
+	if !res.succeeded() {
+		lvl = level.Warn
+	}
+	lvl(e.logger).Log("msg", "Backend response", "path", req.orig.URL.Path, "backend", b.name, "status", status, "elapsed", elapsed, "err", err)
+	e.metrics.requestDuration.WithLabelValues(b.name, req.orig.Method, writeRouteName, strconv.Itoa(res.statusCode())).Observe(elapsed.Seconds())
+
+	return status, body, err
+}
+
+type writeRequest struct {
+	orig *http.Request
+	body []byte
+}
+
+type writeQueue struct {
+	endpoint *WriteEndpoint
+	backend  *ProxyBackend
+	requests chan *writeRequest
+}
+
+func (q *writeQueue) enqueue(req *writeRequest) {
+	select {
+	case q.requests <- req:
+		q.endpoint.metrics.writeQueueLength.WithLabelValues(q.backend.name).Inc()
+	default:
+		q.endpoint.metrics.writeRequestsTotal.WithLabelValues(q.backend.name, writeResultDropped).Inc()
+	}
+}
+
+func (q *writeQueue) run() {
+	defer q.endpoint.workers.Done()
+
+	for {
+		select {
+		case <-q.endpoint.ctx.Done():
+			return
+		case req := <-q.requests:
+			q.endpoint.metrics.writeQueueLength.WithLabelValues(q.backend.name).Dec()
+			q.send(req)
+		}
+	}
+}
+
+// send sends the request to the backend, retrying on network errors, 5xx and 429 responses.
+func (q *writeQueue) send(req *writeRequest) {
+	e := q.endpoint
+	retries := backoff.New(e.ctx, e.cfg.Backoff)
+
+	for retries.Ongoing() {
+		status, resBody, err := e.send(q.backend, req)
+		if result := writeResult(status, err); result == writeResultSuccess || (result == writeResultRejected && status != http.StatusTooManyRequests) {
+			e.recordResult(q.backend, status, resBody, err)
+			return
+		}
+
+		retries.Wait()
+		if !retries.Ongoing() {
+			e.recordResult(q.backend, status, resBody, err)
+			return
+		}
+		e.metrics.writeRetriesTotal.WithLabelValues(q.backend.name).Inc()
+	}
+}
+
+// recordResult tracks the final result of a write request to a backend.
+func (e *WriteEndpoint) recordResult(b *ProxyBackend, status int, body []byte, err error) {
+	e.metrics.writeRequestsTotal.WithLabelValues(b.name, writeResult(status, err)).Inc()
+	if err == nil && status/100 == 4 {
+		e.metrics.writeRejectedRequestsTotal.WithLabelValues(b.name, parseRejectReason(body)).Inc()
+	}
+}
+
+// writeResult returns the result of a write request, given the backend response.
+// Requests rejected because of rate limiting (429) are considered rejected too.
+func writeResult(status int, err error) string {
+	switch {
+	case err != nil:
+		return writeResultFailed
+	case status/100 == 2:
+		return writeResultSuccess
+	case status/100 == 4:
+		return writeResultRejected
+	default:
+		return writeResultFailed
+	}
+}
+
+// parseRejectReason returns the reason why a write request has been rejected, parsed from the error message.
+func parseRejectReason(body []byte) string {
+	msg := string(body)
+	for _, r := range rejectReasons {
+		if strings.Contains(msg, r.message) {
+			return r.reason
+		}
+	}
+	return rejectReasonOther
+}
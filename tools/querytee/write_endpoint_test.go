@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestWriteEndpoint(t *testing.T) {
+	const body = "remote-write-request"
+
+	var preferredCalls, secondaryCalls atomic.Int32
+	preferred := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		preferredCalls.Inc()
+		b, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, body, string(b))
+		assert.Equal(t, "/api/v1/push", r.URL.Path)
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("out of order sample"))
+	}))
+	defer preferred.Close()
+
+	// The secondary backend fails the first 2 requests, then succeeds.
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, body, string(b))
+		if secondaryCalls.Inc() <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer secondary.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	metrics := NewProxyMetrics(reg)
+	backends := []*ProxyBackend{
+		NewProxyBackend("secondary", mustParseURL(t, secondary.URL), time.Second, false),
+		NewProxyBackend("preferred", mustParseURL(t, preferred.URL), time.Second, true),
+	}
+
+	cfg := WriteConfig{
+		Timeout:      time.Second,
+		QueueSize:    10,
+		QueueWorkers: 1,
+		Backoff:      backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, MaxRetries: 5},
+	}
+	e := NewWriteEndpoint(cfg, backends, metrics, log.NewNopLogger())
+	defer e.Stop()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/push", strings.NewReader(body)))
+
+	// The response of the preferred backend is sent back to the client.
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "out of order sample", rec.Body.String())
+	assert.Equal(t, int32(1), preferredCalls.Load())
+
+	// The request to the secondary backend is retried until it succeeds.
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.writeRequestsTotal.WithLabelValues("secondary", writeResultSuccess)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), secondaryCalls.Load())
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_querytee_write_rejected_requests_total Total number of write requests rejected by each backend by reason.
+		# TYPE cortex_querytee_write_rejected_requests_total counter
+		cortex_querytee_write_rejected_requests_total{backend="preferred",reason="sample_out_of_order"} 1
+
+		# HELP cortex_querytee_write_requests_total Total number of write requests sent to each backend by result.
+		# TYPE cortex_querytee_write_requests_total counter
+		cortex_querytee_write_requests_total{backend="preferred",result="rejected"} 1
+		cortex_querytee_write_requests_total{backend="secondary",result="success"} 1
+
+		# HELP cortex_querytee_write_retries_total Total number of write requests retried to each non-preferred backend.
+		# TYPE cortex_querytee_write_retries_total counter
+		cortex_querytee_write_retries_total{backend="secondary"} 2
+
+		# HELP cortex_querytee_write_queue_length Number of write requests queued for each non-preferred backend.
+		# TYPE cortex_querytee_write_queue_length gauge
+		cortex_querytee_write_queue_length{backend="secondary"} 0
+	`), "cortex_querytee_write_requests_total", "cortex_querytee_write_rejected_requests_total", "cortex_querytee_write_retries_total", "cortex_querytee_write_queue_length"))
+}
+
+func TestWriteEndpoint_DropRequestsWhenQueueIsFull(t *testing.T) {
+	block := make(chan struct{})
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer secondary.Close()
+	defer close(block)
+
+	preferred := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer preferred.Close()
+
+	metrics := NewProxyMetrics(nil)
+	backends := []*ProxyBackend{
+		NewProxyBackend("preferred", mustParseURL(t, preferred.URL), time.Second, true),
+		NewProxyBackend("secondary", mustParseURL(t, secondary.URL), time.Second, false),
+	}
+
+	// No workers, so that queued requests are never consumed.
+	e := NewWriteEndpoint(WriteConfig{Timeout: time.Second, QueueSize: 2}, backends, metrics, log.NewNopLogger())
+	defer e.Stop()
+
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/push", strings.NewReader("request")))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.writeQueueLength.WithLabelValues("secondary")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.writeRequestsTotal.WithLabelValues("secondary", writeResultDropped)))
+	assert.Equal(t, 5.0, testutil.ToFloat64(metrics.writeRequestsTotal.WithLabelValues("preferred", writeResultSuccess)))
+}
+
+func TestParseRejectReason(t *testing.T) {
+	tests := map[string]string{
+		"ingestion rate limit (10000) exceeded while adding 100 samples and 0 metadata": "rate_limited",
+		"per-user series limit of 150000 exceeded":                                      "per_user_series_limit",
+		"user=1: err: out of bounds. timestamp=2021-01-01T00:00:00Z, series={}":         "sample_out_of_bounds",
+		"received a series whose label value length exceeds the limit":                  "other",
+		"": "other",
+	}
+
+	for body, expected := range tests {
+		assert.Equal(t, expected, parseRejectReason([]byte(body)), body)
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	return u
+}